@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configDirs returns the directories searched for config.yaml when --config
+// is not given, in order of preference
+func configDirs() (dirs []string) {
+
+	if configDir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(configDir, "udpz"))
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(homeDir, ".config", "udpz"))
+	}
+	return
+}
+
+// loadConfig applies the options in the config file to every flag that was not
+// set on the command line. Options are named after their long flags. Options
+// among the shared flags that the given flags lack, such as output options
+// when serving, are ignored so every command can share one config file.
+func loadConfig(flags *pflag.FlagSet, shared *pflag.FlagSet, path string) (usedPath string, err error) {
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+
+	if path != "" {
+		config.SetConfigFile(path)
+	} else {
+		config.SetConfigName("config")
+
+		for _, dir := range configDirs() {
+			config.AddConfigPath(dir)
+		}
+	}
+
+	if err = config.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+
+		if path == "" && errors.As(err, &notFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	usedPath = config.ConfigFileUsed()
+
+	for _, key := range config.AllKeys() {
+
+		flag := flags.Lookup(key)
+
+		if flag == nil && shared.Lookup(key) != nil {
+			continue
+		}
+		if flag == nil || key == "config" || key == "help" || key == "version" {
+			return usedPath, errors.New(usedPath + ": unknown option: " + key)
+		}
+		if flag.Changed {
+			continue
+		}
+
+		values := []string{config.GetString(key)}
+
+		if strings.HasSuffix(flag.Value.Type(), "Slice") {
+			values = []string{strings.Join(config.GetStringSlice(key), ",")}
+		} else if strings.HasSuffix(flag.Value.Type(), "Array") {
+			// Array values may hold commas, so each is set on its own
+			values = config.GetStringSlice(key)
+		}
+		for _, value := range values {
+			if err = flags.Set(key, value); err != nil {
+				return usedPath, errors.New(usedPath + ": invalid value for " + key + ": " + err.Error())
+			}
+		}
+	}
+	return
+}
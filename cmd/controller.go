@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"udpz/pkg/data"
+	"udpz/pkg/scan"
+	"udpz/pkg/server"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Controller options
+	controllerAgents      []string
+	controllerToken       string
+	controllerJobs        uint   = 2
+	controllerShardSize   uint64 = 256
+	controllerPortShards  uint   = 1
+	controllerMaxAttempts uint   = 3
+	controllerMaxFailures uint   = 3
+)
+
+func init() {
+
+	flags := controllerCmd.Flags()
+	flags.SortFlags = false
+
+	// Agents
+	flags.StringSliceVar(&controllerAgents, "agents", controllerAgents, "Base URLs of the agents running \"udpz serve\" (e.g. http://10.0.0.5:8080,http://10.0.1.5:8080)")
+	flags.StringVar(&controllerToken, "token", controllerToken, "Bearer token of the agents (default: $UDPZ_TOKEN)")
+	flags.UintVar(&controllerJobs, "agent-jobs", controllerJobs, "Number of shards to run on each agent at once")
+	flags.Uint64Var(&controllerShardSize, "shard-size", controllerShardSize, "Approximate number of hosts per shard")
+	flags.UintVar(&controllerPortShards, "port-shards", controllerPortShards, "Also split the ports of each target shard into this many shards")
+	flags.UintVar(&controllerMaxAttempts, "max-attempts", controllerMaxAttempts, "Number of agents to try a shard on before giving up on it")
+	flags.UintVar(&controllerMaxFailures, "max-failures", controllerMaxFailures, "Stop using an agent after this many consecutive failed shards")
+
+	// Output
+	flags.StringVarP(&outputPath, "output", "o", outputPath, "Save results to file")
+	flags.StringVarP(&outputFormat, "format", "f", outputFormat, "Output format [text, pretty, csv, tsv, markdown, json, jsonl, yaml, nmap, grepable, masscan, masscan-list, defectdojo, faraday, metasploit, ad, auto]")
+	flags.StringSliceVar(&outputFields, "fields", outputFields, "Columns and their order for text, pretty, csv, tsv and markdown output (e.g. host,port,service,state,banner)")
+
+	// Scan
+	flags.StringSliceVar(&excludeTargets, "exclude", excludeTargets, "Exclude hosts, CIDRs, or ranges from the scan")
+	flags.StringVarP(&portSpec, "ports", "P", portSpec, "Only scan these UDP ports, nmap-style (e.g. 53,161,500-520,U:1194)")
+	flags.UintVar(&topPorts, "top-ports", topPorts, "Only scan the N most common UDP ports")
+	flags.StringSliceVar(&serviceNames, "services", serviceNames, "Only use probes for these services or tags (e.g. dns,snmp,ntp)")
+	flags.UintVarP(&hostConcurrency, "host-tasks", "c", hostConcurrency, "Maximum Number of hosts each agent scans concurrently per shard")
+	flags.UintVarP(&portConcurrency, "port-tasks", "p", portConcurrency, "Number of Concurrent scan tasks per host")
+	flags.UintVarP(&retransmissions, "retries", "r", retransmissions, "Number of probe retransmissions per probe")
+	flags.StringVarP(&timeoutSpec, "timeout", "t", timeoutSpec, "UDP Probe timeout in milliseconds, or \"auto\" to adapt it to the round trip time of each host")
+	flags.UintVar(&rate, "rate", rate, "Maximum packets sent per second by each shard (0 for the agent default)")
+
+	// Logging
+	flags.BoolVarP(&debug, "debug", "D", debug, "Enable debug logging (Very noisy!)")
+	flags.BoolVarP(&quiet, "quiet", "q", quiet, "Disable info logging")
+
+	controllerCmd.MarkFlagRequired("agents")
+	controllerCmd.MarkFlagsMutuallyExclusive("ports", "top-ports")
+
+	rootCmd.AddCommand(controllerCmd)
+}
+
+var controllerCmd = &cobra.Command{
+	Use:   "controller --agents URL[,URL...] [flags] targets ...",
+	Short: "Split a scan into shards and run them on several udpz serve agents",
+	Long: `Split the targets, and optionally the ports, into shards and submit them as
+jobs to agents running "udpz serve", so one large scan is spread over several
+vantage points or network segments. Shards are handed to agents as they become
+free, shards whose job fails are retried on another agent, and agents that keep
+failing are no longer used. The results of every shard are merged and saved in
+any of the usual output formats.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, targets []string) (err error) {
+
+		var outputFile *os.File
+
+		logLevel := zerolog.InfoLevel
+		if debug {
+			logLevel = zerolog.DebugLevel
+		} else if quiet {
+			logLevel = zerolog.WarnLevel
+		}
+		log := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).
+			Level(logLevel).
+			With().
+			Timestamp().
+			Logger()
+
+		outputFormat = strings.ToLower(outputFormat)
+
+		if sup, ok := supportedOutputFormats[outputFormat]; !ok || !sup || outputFormat == "sqlite" || outputFormat == "template" {
+			return errors.New("invalid output format: " + outputFormat)
+		}
+		if err = scan.CheckFields(outputFields); err != nil {
+			return err
+		}
+		readTimeout, adaptiveTimeout, err := parseTimeout(timeoutSpec)
+
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		if controllerToken == "" {
+			controllerToken = os.Getenv("UDPZ_TOKEN")
+		}
+
+		template := server.JobRequest{
+			Exclude:   excludeTargets,
+			Ports:     portSpec,
+			Services:  serviceNames,
+			TopPorts:  int(topPorts),
+			HostTasks: hostConcurrency,
+			PortTasks: portConcurrency,
+			TimeoutMs: uint(readTimeout / time.Millisecond),
+			Rate:      float64(rate),
+
+			AdaptiveTimeout: adaptiveTimeout,
+		}
+		if cmd.Flags().Changed("retries") {
+			template.Retries = &retransmissions
+		}
+
+		// Ports are only split when probes are chosen by port, since probes for
+		// named services may be sent to ports of their own
+		portShards := []string{""}
+
+		if controllerPortShards > 1 && len(serviceNames) == 0 {
+			var ports map[uint16]bool
+
+			if portSpec != "" {
+				if ports, err = data.ParsePorts(portSpec); err != nil {
+					return err
+				}
+			} else if topPorts > 0 {
+				ports = data.TopPorts(data.UDP_SERVICES, int(topPorts))
+			} else {
+				ports = make(map[uint16]bool)
+
+				for _, service := range data.UDP_SERVICES {
+					for _, port := range service.Ports {
+						ports[port] = true
+					}
+				}
+			}
+			portShards = scan.ShardPorts(ports, int(controllerPortShards))
+		}
+
+		var shards []server.Shard
+
+		for _, targetShard := range scan.ShardTargets(targets, controllerShardSize) {
+			for _, ports := range portShards {
+				shards = append(shards, server.Shard{Targets: targetShard, Ports: ports})
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		controller := &server.Controller{
+			Agents:       controllerAgents,
+			Token:        controllerToken,
+			Template:     template,
+			JobsPerAgent: int(controllerJobs),
+			MaxAttempts:  int(controllerMaxAttempts),
+			MaxFailures:  int(controllerMaxFailures),
+			Logger:       log,
+		}
+
+		log.Info().
+			Strs("agents", controllerAgents).
+			Int("shard_count", len(shards)).
+			Msg("Starting distributed scan")
+
+		scanStartTime := time.Now()
+		results, runErr := controller.Run(ctx, shards)
+		scanEndTime := time.Now()
+
+		if runErr != nil {
+			log.Error().
+				Err(runErr).
+				Int("result_count", len(results)).
+				Msg("Distributed scan incomplete")
+		} else {
+			log.Info().
+				Time("start", scanStartTime).
+				Time("end", scanEndTime).
+				TimeDiff("duration", scanEndTime, scanStartTime).
+				Int("result_count", len(results)).
+				Msg("Distributed scan complete")
+		}
+
+		if len(results) > 0 {
+			scanner := scan.ScannerFromResults(results, scanStartTime, scanEndTime)
+
+			outputFile = openOutput(log, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+			if outputFile != os.Stdout {
+				defer outputFile.Close()
+			}
+
+			if outputFormat == "json" {
+				scanner.SaveJson(outputFile)
+			} else if outputFormat == "jsonl" {
+				encoder := json.NewEncoder(outputFile)
+				for _, result := range results {
+					encoder.Encode(result)
+				}
+			} else if outputFormat == "yml" || outputFormat == "yaml" {
+				scanner.SaveYAML(outputFile)
+			} else if outputFormat == "nmap" || outputFormat == "xml" {
+				scanner.SaveNmapXML(outputFile, redactedArgs())
+			} else if outputFormat == "grepable" || outputFormat == "gnmap" {
+				scanner.SaveGrepable(outputFile, redactedArgs())
+			} else if outputFormat == "masscan" {
+				scanner.SaveMasscanJSON(outputFile)
+			} else if outputFormat == "masscan-list" {
+				scanner.SaveMasscanList(outputFile)
+			} else if outputFormat == "defectdojo" {
+				scanner.SaveDefectDojo(outputFile)
+			} else if outputFormat == "faraday" {
+				scanner.SaveFaraday(outputFile, redactedArgs())
+			} else if outputFormat == "metasploit" || outputFormat == "msf" {
+				scanner.SaveMetasploit(outputFile, redactedArgs())
+			} else if outputFormat == "ad" {
+				scanner.SaveAD(outputFile)
+			} else if outputFormat == "markdown" || outputFormat == "md" {
+				scanner.SaveMarkdown(outputFields, outputFile)
+			} else {
+				scanner.SaveTable(outputFormat, outputFields, outputFile)
+			}
+		}
+		return runErr
+	},
+}
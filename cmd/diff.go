@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"udpz/pkg/scan"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// errChangesFound ends a diff run with --exit-code that found changes
+	errChangesFound = errors.New("changes found")
+
+	// Diff options
+	diffFormat   string = "pretty"
+	diffExitCode bool
+
+	supportedDiffFormats = map[string]bool{
+		"text": true, "txt": true,
+		"yaml": true, "yml": true,
+		"json": true, "jsonl": true,
+		"csv":    true,
+		"tsv":    true,
+		"pretty": true,
+	}
+)
+
+func init() {
+
+	diffCmd.Flags().SortFlags = false
+	diffCmd.Flags().StringVarP(&diffFormat, "format", "f", diffFormat, "Output format [text, pretty, csv, tsv, json, jsonl, yaml]")
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", diffExitCode, "Exit with status 1 if there are any changes")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff OLD NEW",
+	Short: "Report services opened, closed, or changed between two scans",
+	Long: `Compare two scan result files saved as JSON, JSON lines, or YAML, and report
+ports that were newly opened, ports that are no longer open, and open ports
+whose service or version changed.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		diffFormat = strings.ToLower(diffFormat)
+
+		if sup, ok := supportedDiffFormats[diffFormat]; !ok || !sup {
+			return errors.New("invalid output format: " + diffFormat)
+		}
+		var oldResults, newResults []scan.Result
+
+		if oldResults, err = scan.LoadResults(args[0]); err != nil {
+			return err
+		}
+		if newResults, err = scan.LoadResults(args[1]); err != nil {
+			return err
+		}
+		changes := scan.DiffResults(oldResults, newResults)
+
+		if len(changes) == 0 && (diffFormat == "pretty" || diffFormat == "text" || diffFormat == "txt") {
+			fmt.Println("No changes")
+		} else if err = writeChanges(os.Stdout, diffFormat, changes); err != nil {
+			return err
+		}
+
+		if diffExitCode && len(changes) > 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return errChangesFound
+		}
+		return
+	},
+}
+
+// writeChanges writes the changes in the given format, as a table with one row
+// per port unless a structured format is chosen
+func writeChanges(output io.Writer, format string, changes []scan.PortChange) (err error) {
+
+	switch format {
+	case "json":
+		if changes == nil {
+			changes = []scan.PortChange{}
+		}
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(changes)
+
+	case "jsonl":
+		encoder := json.NewEncoder(output)
+
+		for _, change := range changes {
+			if err = encoder.Encode(change); err != nil {
+				return
+			}
+		}
+		return
+
+	case "yaml", "yml":
+		return yaml.NewEncoder(output).Encode(changes)
+	}
+
+	changesTable := table.NewWriter()
+	changesTable.AppendHeader(table.Row{"Change", "Host", "Port", "State", "Service", "Version"})
+
+	for _, change := range changes {
+		changesTable.AppendRow(table.Row{
+			change.Change,
+			diffHost(change),
+			fmt.Sprintf("%d/UDP", change.Port),
+			diffValue(change.OldState, change.NewState),
+			diffValue(change.OldService, change.NewService),
+			diffValue(change.OldVersion, change.NewVersion),
+		})
+	}
+	changesTable.SetOutputMirror(output)
+
+	switch format {
+	case "csv":
+		changesTable.RenderCSV()
+	case "tsv":
+		changesTable.RenderTSV()
+	case "pretty":
+		changesTable.SetStyle(table.StyleRounded)
+		changesTable.Render()
+	default:
+		changesTable.Render()
+	}
+	return
+}
+
+// diffHost shows the host of a change followed by the host name it was
+// resolved from, if any
+func diffHost(change scan.PortChange) string {
+
+	if change.Hostname == "" {
+		return change.Host
+	}
+	return change.Host + " (" + change.Hostname + ")"
+}
+
+// diffValue shows a value that changed as "old -> new", and an unchanged one
+// as is
+func diffValue(oldValue string, newValue string) string {
+
+	if oldValue == newValue {
+		return newValue
+	}
+	if oldValue == "" {
+		oldValue = "-"
+	}
+	if newValue == "" {
+		newValue = "-"
+	}
+	return oldValue + " -> " + newValue
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	// estimateOnly reports the estimate of the scan instead of running it
+	estimateOnly bool
+)
+
+func init() {
+	rootCmd.AddCommand(estimateCmd)
+}
+
+var estimateCmd = &cobra.Command{
+	Use:   "estimate [flags] [targets ...]",
+	Short: "Estimate the probes, packets, bytes and duration of a scan without running it",
+	Long: `Work out how many probes, packets and bytes a scan with the same flags would
+send, and how long it would take at the configured rate, concurrency, timeout
+and retransmissions, from the size of each target. Targets are not expanded,
+so ranges as large as a /8 are estimated at once. Every probe is counted with
+all of its retransmissions, as if nothing answered.
+
+Use --dry-run to expand the targets and list each host instead.`,
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, targets []string) error {
+		estimateOnly = true
+		return rootCmd.RunE(cmd, targets)
+	},
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"strconv"
+)
+
+// Exit statuses of a scan, so scripts and CI jobs can tell its outcome apart
+// without parsing the output
+const (
+	exitNoOpenPorts = 0 // The scan completed without finding open ports
+	exitOpenPorts   = 1 // The scan completed and found open ports
+	exitErrors      = 2 // The scan could not start, or some of it failed
+	exitInterrupted = 3 // The scan was interrupted before it completed
+)
+
+var (
+	// exitCodePolicies are the ways --exit-code-policy can report a scan.
+	// Findings reports every outcome, errors does not fail on open ports, and
+	// none exits with 0 whenever the scan ran.
+	exitCodePolicies = map[string]bool{
+		"findings": true,
+		"errors":   true,
+		"none":     true,
+	}
+)
+
+// exitStatus ends a command with the exit status, after what went wrong has
+// been logged
+type exitStatus int
+
+func (status exitStatus) Error() string {
+	return "exit status " + strconv.Itoa(int(status))
+}
+
+// scanExitStatus returns the exit status of a scan under the policy. An
+// interrupt takes precedence over errors, and errors over open ports.
+func scanExitStatus(policy string, openPorts uint64, errorCount uint64, interrupted bool) int {
+
+	switch policy {
+	case "none":
+		return exitNoOpenPorts
+	case "errors":
+		openPorts = 0
+	}
+	if interrupted {
+		return exitInterrupted
+	}
+	if errorCount > 0 {
+		return exitErrors
+	}
+	if openPorts > 0 {
+		return exitOpenPorts
+	}
+	return exitNoOpenPorts
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"udpz/pkg/scan"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Merge options
+	mergeOutputPath string
+	mergeFormat     string = "json"
+
+	// supportedMergeFormats are the formats merged results can be written in,
+	// which can all be merged, compared and loaded again
+	supportedMergeFormats = map[string]bool{
+		"json": true, "jsonl": true,
+		"yaml": true, "yml": true,
+	}
+)
+
+func init() {
+
+	mergeCmd.Flags().SortFlags = false
+	mergeCmd.Flags().StringVarP(&mergeOutputPath, "output", "o", mergeOutputPath, "Save merged results to file instead of stdout")
+	mergeCmd.Flags().StringVarP(&mergeFormat, "format", "f", mergeFormat, "Output format [json, jsonl, yaml]")
+
+	rootCmd.AddCommand(mergeCmd)
+}
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge FILE...",
+	Short: "Merge the results of several scans, removing duplicates",
+	Long: `Merge scan result files saved as JSON, JSON lines, or YAML, such as the
+shards of a distributed scan or repeated scans of the same hosts. Results are
+deduplicated by host, port and service, keeping the richest record of each:
+the one with the most revealing state (OPEN over OPEN|FILTERED over CLOSED),
+then the one with the most details parsed from the response.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		mergeFormat = strings.ToLower(mergeFormat)
+
+		if sup, ok := supportedMergeFormats[mergeFormat]; !ok || !sup {
+			return errors.New("invalid output format: " + mergeFormat)
+		}
+		scans := make([][]scan.Result, len(args))
+
+		for i, path := range args {
+			if scans[i], err = scan.LoadResults(path); err != nil {
+				return err
+			}
+		}
+		merged := scan.MergeResults(scans...)
+
+		if merged == nil {
+			merged = []scan.Result{}
+		}
+		outputFile := os.Stdout
+
+		if mergeOutputPath != "" {
+			if outputFile, err = os.Create(mergeOutputPath); err != nil {
+				return err
+			}
+			defer outputFile.Close()
+		}
+		scanner := scan.ScannerFromResults(merged, time.Time{}, time.Time{})
+
+		switch mergeFormat {
+		case "jsonl":
+			encoder := json.NewEncoder(outputFile)
+
+			for _, result := range merged {
+				if err = encoder.Encode(result); err != nil {
+					return
+				}
+			}
+			return
+
+		case "yaml", "yml":
+			return scanner.SaveYAML(outputFile)
+		}
+		return scanner.SaveJson(outputFile)
+	},
+}
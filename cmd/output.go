@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"udpz/pkg/scan"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	// resultsTemplate renders template output, loaded from --template
+	resultsTemplate *template.Template
+
+	// outputExtensions are the formats results files are written in by
+	// default, by their extension
+	outputExtensions = map[string]string{
+		".txt":  "text",
+		".json": "json", ".jsonl": "jsonl",
+		".yaml": "yaml", ".yml": "yaml",
+		".csv": "csv",
+		".tsv": "tsv",
+		".xml": "nmap", ".gnmap": "grepable",
+		".md": "markdown",
+		".rc": "metasploit",
+		".db": "sqlite", ".sqlite": "sqlite",
+	}
+)
+
+// resultOutput is a destination of the results, given with -o as
+// [FORMAT:]PATH
+type resultOutput struct {
+	format string
+	path   string // Empty for stdout
+}
+
+// parseOutputs parses the destinations of the results. Outputs without a
+// format are written in the format given with -f, or when it is "auto", in the
+// format of their extension. Without any, the results are written to stdout.
+func parseOutputs(specs []string, format string) (outputs []resultOutput, err error) {
+
+	if len(specs) == 0 {
+		return []resultOutput{{format: format}}, nil
+	}
+	for _, spec := range specs {
+
+		output := resultOutput{format: format, path: spec}
+
+		if prefix, path, found := strings.Cut(spec, ":"); found && supportedOutputFormats[strings.ToLower(prefix)] {
+			if path == "" {
+				return nil, errors.New("invalid output, expected [FORMAT:]PATH: " + spec)
+			}
+			output.format, output.path = strings.ToLower(prefix), path
+
+		} else if extensionFormat, ok := outputExtensions[strings.ToLower(filepath.Ext(spec))]; ok && format == "auto" {
+			output.format = extensionFormat
+		}
+		if output.path == "-" {
+			output.path = ""
+		}
+		outputs = append(outputs, output)
+	}
+	return
+}
+
+// open opens the results file, falling back to stdout, and resolves the
+// "auto" output format accordingly
+func (output *resultOutput) open(log zerolog.Logger, outputFlags int) (outputFile *os.File) {
+
+	var err error
+
+	if output.path == "" {
+		outputFile = os.Stdout
+		if output.format == "auto" {
+			output.format = "pretty"
+		}
+
+	} else if outputFile, err = os.OpenFile(output.path, outputFlags, 0o644); err == nil {
+		if output.format == "auto" {
+			output.format = "json"
+		}
+
+	} else {
+		log.Error().
+			AnErr("error", err).
+			Str("outputPath", output.path).
+			Msg("Could not open output file for writing")
+		outputFile = os.Stdout
+		if output.format == "auto" {
+			output.format = "pretty"
+		}
+	}
+	return
+}
+
+// saveResults writes the results of the scan in the format
+func saveResults(scanner *scan.UdpProbeScanner, format string, outputFile *os.File) error {
+
+	if amplification {
+		return scanner.SaveAmplification(format, outputFile)
+	} else if hostSummary && format == "json" {
+		return scanner.SaveJsonReport(outputFile)
+	} else if hostSummary && (format == "yml" || format == "yaml") {
+		return scanner.SaveYAMLReport(outputFile)
+	} else if hostSummary && (format == "markdown" || format == "md") {
+		if err := scanner.SaveMarkdown(outputFields, outputFile); err != nil {
+			return err
+		}
+		return scanner.SaveHostTable(format, outputFile)
+	} else if hostSummary {
+		if err := scanner.SaveTable(format, outputFields, outputFile); err != nil {
+			return err
+		}
+		return scanner.SaveHostTable(format, outputFile)
+	} else if format == "json" {
+		return scanner.SaveJson(outputFile)
+	} else if format == "yml" || format == "yaml" {
+		return scanner.SaveYAML(outputFile)
+	} else if format == "nmap" || format == "xml" {
+		return scanner.SaveNmapXML(outputFile, redactedArgs())
+	} else if format == "markdown" || format == "md" {
+		return scanner.SaveMarkdown(outputFields, outputFile)
+	} else if format == "grepable" || format == "gnmap" {
+		return scanner.SaveGrepable(outputFile, redactedArgs())
+	} else if format == "masscan" {
+		return scanner.SaveMasscanJSON(outputFile)
+	} else if format == "masscan-list" {
+		return scanner.SaveMasscanList(outputFile)
+	} else if format == "defectdojo" {
+		return scanner.SaveDefectDojo(outputFile)
+	} else if format == "faraday" {
+		return scanner.SaveFaraday(outputFile, redactedArgs())
+	} else if format == "metasploit" || format == "msf" {
+		return scanner.SaveMetasploit(outputFile, redactedArgs())
+	} else if format == "ad" {
+		return scanner.SaveAD(outputFile)
+	} else if format == "template" {
+		return scanner.SaveTemplate(resultsTemplate, outputFile)
+	}
+	return scanner.SaveTable(format, outputFields, outputFile)
+}
+
+// saveNucleiTargets writes the targets of the open ports to a new file at path
+func saveNucleiTargets(scanner *scan.UdpProbeScanner, path string) error {
+
+	targetsFile, err := os.Create(path)
+
+	if err != nil {
+		return err
+	}
+	if err = scanner.SaveNucleiTargets(targetsFile); err != nil {
+		targetsFile.Close()
+		return err
+	}
+	return targetsFile.Close()
+}
@@ -0,0 +1,324 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"udpz/pkg/data"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Probe import options
+	importPayloadsPath      string
+	importServiceProbesPath string
+	importOutputPath        string
+
+	// Probe list options
+	listFormat          string = "pretty"
+	listProbeFiles      []string
+	listCommunitiesPath string
+	listRADIUSSecret    string
+	listTFTPFilesPath   string
+	listServiceNames    []string
+	listPortSpec        string
+
+	supportedListFormats = map[string]bool{
+		"text": true, "txt": true,
+		"yaml": true, "yml": true,
+		"json":   true,
+		"csv":    true,
+		"tsv":    true,
+		"pretty": true,
+	}
+)
+
+func init() {
+
+	probesImportCmd.Flags().SortFlags = false
+	probesImportCmd.Flags().StringVar(&importPayloadsPath, "nmap-payloads", importPayloadsPath, "Path to nmap-payloads (default: search nmap install locations)")
+	probesImportCmd.Flags().StringVar(&importServiceProbesPath, "nmap-service-probes", importServiceProbesPath, "Path to nmap-service-probes (default: search nmap install locations)")
+	probesImportCmd.Flags().StringVarP(&importOutputPath, "output", "o", importOutputPath, "Save the probe file to this path instead of stdout")
+
+	probesListCmd.Flags().SortFlags = false
+	probesListCmd.Flags().StringVarP(&listFormat, "format", "f", listFormat, "Output format [text, pretty, csv, tsv, json, yaml]")
+	probesListCmd.Flags().StringSliceVar(&listProbeFiles, "probe-file", listProbeFiles, "Include probes from a YAML file, as they would be merged for a scan")
+	probesListCmd.Flags().StringVar(&listCommunitiesPath, "snmp-communities", listCommunitiesPath, "Include a probe for each SNMP community string in this file, as a scan would")
+	probesListCmd.Flags().StringVar(&listTFTPFilesPath, "tftp-files", listTFTPFilesPath, "Request each file name in this file from TFTP servers, as a scan would")
+	probesListCmd.Flags().StringVar(&listRADIUSSecret, "radius-secret", listRADIUSSecret, "Sign RADIUS probes with this shared secret, as a scan would")
+	probesListCmd.Flags().StringSliceVar(&listServiceNames, "services", listServiceNames, "Only list probes for these services or tags")
+	probesListCmd.Flags().StringVarP(&listPortSpec, "ports", "P", listPortSpec, "Only list probes for these UDP ports")
+
+	probesCmd.AddCommand(probesListCmd)
+	probesCmd.AddCommand(probesImportCmd)
+	rootCmd.AddCommand(probesCmd)
+}
+
+var probesCmd = &cobra.Command{
+	Use:   "probes",
+	Short: "Manage the probe database",
+}
+
+var probesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the services and probes that would be used in a scan",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		listFormat = strings.ToLower(listFormat)
+
+		if sup, ok := supportedListFormats[listFormat]; !ok || !sup {
+			return errors.New("invalid output format: " + listFormat)
+		}
+		services := data.UDP_SERVICES
+
+		for _, probeFile := range listProbeFiles {
+			var fileServices map[string]data.UdpService
+
+			if fileServices, err = data.LoadProbeFile(probeFile); err != nil {
+				return errors.New(probeFile + ": " + err.Error())
+			}
+			services = data.MergeServices(services, fileServices)
+		}
+		if listCommunitiesPath != "" {
+			var communities []string
+
+			if communities, err = data.LoadCommunities(listCommunitiesPath); err != nil {
+				return errors.New(listCommunitiesPath + ": " + err.Error())
+			}
+			services = data.AddSNMPCommunities(services, communities)
+		}
+		if listRADIUSSecret != "" {
+			services = data.SetRADIUSSecret(services, listRADIUSSecret)
+		}
+		if listTFTPFilesPath != "" {
+			var files []string
+
+			if files, err = data.LoadTFTPFiles(listTFTPFilesPath); err != nil {
+				return errors.New(listTFTPFilesPath + ": " + err.Error())
+			}
+			services = data.SetTFTPFiles(services, files)
+		}
+		if len(listServiceNames) > 0 {
+			if services, err = data.FilterServices(services, listServiceNames); err != nil {
+				return
+			}
+		}
+		if listPortSpec != "" {
+			var ports map[uint16]bool
+
+			if ports, err = data.ParsePorts(listPortSpec); err != nil {
+				return
+			}
+			services = data.FilterPorts(services, ports)
+		}
+
+		switch listFormat {
+		case "json":
+			return listProbesJson(services)
+		case "yaml", "yml":
+			return data.WriteProbeFile(os.Stdout, services)
+		default:
+			listProbesTable(services)
+		}
+		return
+	},
+}
+
+var probesImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Convert nmap UDP payloads and service probes into a probe file",
+	Long: `Convert the UDP payloads in nmap-payloads and the UDP probes in nmap-service-probes
+into a udpz probe file, which can then be loaded with --probe-file.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		log := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).
+			With().
+			Timestamp().
+			Logger()
+
+		services := make(map[string]data.UdpService)
+
+		if importPayloadsPath == "" {
+			importPayloadsPath = findNmapData("nmap-payloads")
+		}
+		if importServiceProbesPath == "" {
+			importServiceProbesPath = findNmapData("nmap-service-probes")
+		}
+		if importPayloadsPath == "" && importServiceProbesPath == "" {
+			return errors.New("could not find nmap data files, use --nmap-payloads or --nmap-service-probes")
+		}
+
+		if importPayloadsPath != "" {
+			var file *os.File
+			var payloads map[string]data.UdpService
+
+			if file, err = os.Open(importPayloadsPath); err != nil {
+				return
+			}
+			defer file.Close()
+
+			if payloads, err = data.ParseNmapPayloads(file); err != nil {
+				return errors.New(importPayloadsPath + ": " + err.Error())
+			}
+			log.Info().
+				Str("path", importPayloadsPath).
+				Int("service_count", len(payloads)).
+				Msg("Imported nmap payloads")
+
+			services = data.MergeServices(services, payloads)
+		}
+
+		if importServiceProbesPath != "" {
+			var file *os.File
+			var probes map[string]data.UdpService
+			var warnings []string
+
+			if file, err = os.Open(importServiceProbesPath); err != nil {
+				return
+			}
+			defer file.Close()
+
+			if probes, warnings, err = data.ParseNmapServiceProbes(file); err != nil {
+				return errors.New(importServiceProbesPath + ": " + err.Error())
+			}
+			for _, warning := range warnings {
+				log.Debug().
+					Str("path", importServiceProbesPath).
+					Msg(warning)
+			}
+			log.Info().
+				Str("path", importServiceProbesPath).
+				Int("service_count", len(probes)).
+				Int("skipped", len(warnings)).
+				Msg("Imported nmap service probes")
+
+			services = data.MergeServices(services, probes)
+		}
+
+		output := os.Stdout
+
+		if importOutputPath != "" {
+			if output, err = os.Create(importOutputPath); err != nil {
+				return
+			}
+			defer output.Close()
+		}
+		return data.WriteProbeFile(output, services)
+	},
+}
+
+// findNmapData returns the path of an installed nmap data file, or an empty
+// string if it cannot be found
+func findNmapData(name string) string {
+
+	if nmapDir := os.Getenv("NMAPDIR"); nmapDir != "" {
+		if _, err := os.Stat(filepath.Join(nmapDir, name)); err == nil {
+			return filepath.Join(nmapDir, name)
+		}
+	}
+	for _, dir := range data.NMAP_DATA_PATHS {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// sortedServices returns the services ordered by slug
+func sortedServices(services map[string]data.UdpService) []data.UdpService {
+
+	serviceList := make([]data.UdpService, 0, len(services))
+
+	for _, service := range services {
+		serviceList = append(serviceList, service)
+	}
+	sort.Slice(serviceList, func(i, j int) bool {
+		return serviceList[i].Slug < serviceList[j].Slug
+	})
+	return serviceList
+}
+
+// payloadSummary describes a base64 encoded payload by its length and a
+// short, escaped preview of its first bytes
+func payloadSummary(encoded string) string {
+
+	const previewLength = 16
+
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return "invalid payload"
+	}
+	if len(payload) == 0 {
+		return "0 bytes"
+	}
+	preview := payload
+	suffix := ""
+
+	if len(preview) > previewLength {
+		preview = preview[:previewLength]
+		suffix = "..."
+	}
+	quoted := strconv.QuoteToASCII(string(preview))
+
+	return fmt.Sprintf("%d bytes: %s%s", len(payload), quoted[1:len(quoted)-1], suffix)
+}
+
+// listProbesTable prints one row per probe in the selected table format
+func listProbesTable(services map[string]data.UdpService) {
+
+	probesTable := table.NewWriter()
+	probesTable.AppendHeader(table.Row{"Service", "Ports", "Probe", "Payload", "Tags"})
+
+	for _, service := range sortedServices(services) {
+
+		ports := make([]string, len(service.Ports))
+
+		for i, port := range service.Ports {
+			ports[i] = strconv.Itoa(int(port))
+		}
+		for _, probe := range service.Probes {
+			probesTable.AppendRow(table.Row{
+				service.Slug,
+				strings.Join(ports, ","),
+				probe.Slug,
+				payloadSummary(probe.EncodedData),
+				strings.Join(service.Tags, ","),
+			})
+		}
+	}
+	probesTable.SetOutputMirror(os.Stdout)
+
+	switch listFormat {
+	case "csv":
+		probesTable.RenderCSV()
+	case "tsv":
+		probesTable.RenderTSV()
+	case "pretty":
+		probesTable.SetStyle(table.StyleRounded)
+		probesTable.Render()
+	default:
+		probesTable.Render()
+	}
+}
+
+// listProbesJson prints the services and their probes as a JSON array
+func listProbesJson(services map[string]data.UdpService) error {
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(sortedServices(services))
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// scanProfile presets the options of a kind of scan, given as the values of
+// their flags
+type scanProfile [][2]string
+
+var (
+	// scanProfiles are the presets selected with --profile
+	scanProfiles = map[string]scanProfile{
+		// A few packets at a time, spread out at random, with only the first
+		// probe of the common services
+		"stealth": {
+			{"services", "common"},
+			{"probes-per-service", "1"},
+			{"rate", "5"},
+			{"jitter", "2000"},
+			{"retries", "0"},
+			{"timeout", "5000"},
+			{"host-tasks", "1"},
+			{"port-tasks", "1"},
+		},
+		"default": {},
+		// Many hosts and probes at once with timeouts adapted to each host, for
+		// fast local networks
+		"aggressive": {
+			{"host-tasks", "64"},
+			{"port-tasks", "200"},
+			{"retries", "1"},
+			{"timeout", "auto"},
+		},
+		// The services commonly exposed to the Internet over a pool of shared
+		// sockets, without retransmissions, for large address ranges
+		"internet": {
+			{"services", "internet"},
+			{"sockets", "16"},
+			{"retries", "0"},
+			{"timeout", "1500"},
+			{"host-tasks", "512"},
+			{"port-tasks", "32"},
+			{"rate", "5000"},
+		},
+	}
+)
+
+// profileNames returns the names of the scan profiles in order
+func profileNames() (names []string) {
+
+	for name := range scanProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return
+}
+
+// applyProfile sets the options of the profile that were not given on the
+// command line or in the config file. Options that would conflict with given
+// ones are left out, such as services when ports are given, or shared sockets
+// when probes are relayed through a proxy.
+func applyProfile(flags *pflag.FlagSet, name string) error {
+
+	profile, ok := scanProfiles[strings.ToLower(name)]
+
+	if !ok {
+		return errors.New("unknown profile: " + name + " (valid profiles: " + strings.Join(profileNames(), ", ") + ")")
+	}
+	for _, option := range profile {
+
+		flag := flags.Lookup(option[0])
+
+		if flag == nil || flag.Changed || profileConflicts(flags, flag) {
+			continue
+		}
+		if err := flags.Set(option[0], option[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// profileConflicts reports whether a given flag excludes the flag, or would
+// change what it means
+func profileConflicts(flags *pflag.FlagSet, flag *pflag.Flag) bool {
+
+	conflicting := map[string][]string{
+		"services": {"ports", "top-ports"},
+	}[flag.Name]
+
+	// Flags marked mutually exclusive are annotated with their groups
+	for _, group := range flag.Annotations["cobra_annotation_mutually_exclusive"] {
+		conflicting = append(conflicting, strings.Fields(group)...)
+	}
+	for _, name := range conflicting {
+		if other := flags.Lookup(name); other != nil && other != flag && other.Changed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"udpz/pkg/scan"
+)
+
+// progressBar renders the progress of a scan on the last line of a terminal.
+// Log messages are written through it so they appear above the bar instead of
+// being broken up by it.
+type progressBar struct {
+	mu      sync.Mutex
+	output  io.Writer
+	scanner *scan.UdpProbeScanner
+	line    string
+	done    chan struct{}
+	stopped chan struct{}
+
+	lastSent uint64
+	lastTime time.Time
+	pps      float64
+}
+
+// isTerminal reports whether the file is a character device such as a TTY
+func isTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+func newProgressBar(output io.Writer) *progressBar {
+	return &progressBar{output: output}
+}
+
+// Write prints a log message above the progress bar
+func (pb *progressBar) Write(message []byte) (int, error) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	if pb.line != "" {
+		fmt.Fprint(pb.output, "\r\033[K")
+	}
+	n, err := pb.output.Write(message)
+
+	if pb.line != "" {
+		fmt.Fprint(pb.output, pb.line)
+	}
+	return n, err
+}
+
+// start redraws the progress bar until stop is called
+func (pb *progressBar) start(scanner *scan.UdpProbeScanner) {
+
+	pb.scanner = scanner
+	pb.lastTime = time.Now()
+	pb.lastSent = 0
+	pb.pps = 0
+	pb.done = make(chan struct{})
+	pb.stopped = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		defer close(pb.stopped)
+
+		for {
+			select {
+			case <-ticker.C:
+				pb.render()
+			case <-pb.done:
+				pb.mu.Lock()
+				fmt.Fprint(pb.output, "\r\033[K")
+				pb.line = ""
+				pb.mu.Unlock()
+				return
+			}
+		}
+	}()
+}
+
+// stop clears the progress bar
+func (pb *progressBar) stop() {
+	close(pb.done)
+	<-pb.stopped
+}
+
+func (pb *progressBar) render() {
+
+	const width = 30
+
+	progress := pb.scanner.Progress()
+	now := time.Now()
+
+	// Smooth the packet rate so the display does not jitter
+	if interval := now.Sub(pb.lastTime).Seconds(); interval > 0 {
+		current := float64(progress.PacketsSent-pb.lastSent) / interval
+		pb.pps = 0.7*pb.pps + 0.3*current
+	}
+	pb.lastSent = progress.PacketsSent
+	pb.lastTime = now
+
+	ratio := 0.0
+	if progress.ProbesTotal > 0 {
+		ratio = float64(progress.ProbesDone) / float64(progress.ProbesTotal)
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * width)
+
+	eta := "--"
+	if progress.ProbesDone > 0 && ratio < 1 {
+		remaining := time.Duration(float64(progress.Elapsed) * (1 - ratio) / ratio)
+		eta = remaining.Round(time.Second).String()
+	}
+
+	line := fmt.Sprintf("[%s%s] %5.1f%% %d/%d probes | %d open | %.0f pps | ETA %s",
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", width-filled),
+		ratio*100,
+		progress.ProbesDone,
+		progress.ProbesTotal,
+		progress.OpenPorts,
+		pb.pps,
+		eta)
+
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	pb.line = line
+	fmt.Fprint(pb.output, "\r\033[K"+line)
+}
@@ -1,43 +1,132 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"udpz/pkg/data"
 	"udpz/pkg/scan"
 
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
+	// Config options
+	configPath string
+
 	// Scan options
-	hostConcurrency uint = 10
-	portConcurrency uint = 50
-	timeoutMs       uint = 3000
-	retransmissions uint = 2
+	hostConcurrency uint    = 10
+	portConcurrency uint    = 50
+	timeoutSpec     string  = "3000"
+	retransmissions uint    = 2
+	retryBackoff    float64 = 1
+	jitterMs        uint
+	rate            uint
+	minRate         uint
+	maxRate         uint
+	otMode          bool
+	profileName     string
+
+	// Conservative defaults of --ot, for ICS equipment that misbehaves under
+	// load
+	otRate            uint     = 20
+	otRetransmissions uint     = 1
+	otServices        []string = []string{"ics"}
+
+	// Probe options
+	probeFiles          []string
+	proberPlugins       []string
+	snmpCommunitiesPath string
+	radiusSecret        string
+	tftpFilesPath       string
+	upnpDescribe        bool
+	vulnLookup          string
+	portSpec            string
+	serviceNames        []string
+	topPorts            uint
+	probesPerService    uint
+
+	// Target options
+	inputListPath   string
+	importPaths     []string
+	importPorts     bool
+	excludeTargets  []string
+	excludeListPath string
+	roePath         string
+	overrideSpecs   []string
+
+	// Discovery options
+	discoveryMethods []string
+	skipDiscovery    bool
 
 	// DNS options
-	scanAllAddresses bool = true
+	scanAllAddresses   bool = true
+	ipv4Only           bool
+	ipv6Only           bool
+	resolvers          []string
+	dohURL             string
+	noResolve          bool
+	resolveConcurrency uint = 16
+
+	// Source options
+	interfaceName string
+	sourceIP      string
+	sourcePort    string
+	ttl           uint
+	ttlSweep      uint
+	sockets       uint
+	batch         bool
 
 	// Logging options
 	quiet  bool = false // Disable info logging output (non-errors)
 	silent bool = false // Disable logging entirely
 
-	info  bool = true // Default log level
-	debug bool = false
-	trace bool = false
+	info bool = true // Default log level
+
+	showProgress bool = false
+	debug        bool = false
+	trace        bool = false
 
 	// Output options
 	outputPath   string
+	outputSpecs  []string
 	logPath      string
 	outputFormat string = "auto"
-	logFormat    string = "auto"
-	outputAppend bool   = true
+	outputFields []string
+	hostSummary  bool
+	openOnly     bool
+	allStates    bool
+	templatePath string
+	dbPath       string
+	nucleiPath   string
+	esURL        string
+	esIndex      string = "udpz"
+	esUser       string
+	esPassword   string
+	syslogTarget string
 
+	// Watch options
+	watchInterval  time.Duration
+	watchStatePath string
+	logFormat      string = "auto"
+	outputAppend   bool   = true
+	pcapPath       string
+	capture        bool
+	captureLength  uint = 512
+	amplification  bool
+	dryRun         bool
+	exitCodePolicy string = "findings"
 	// Proxy options
 	socks5Address  string
 	socks5User     string
@@ -57,7 +146,50 @@ var (
 		"csv":    true,
 		"tsv":    true,
 		"pretty": true,
-		"auto":   true,
+		"nmap":   true, "xml": true,
+		"sqlite":   true,
+		"markdown": true, "md": true,
+		"template": true,
+		"grepable": true, "gnmap": true,
+		"masscan": true, "masscan-list": true,
+		"defectdojo": true, "faraday": true,
+		"metasploit": true, "msf": true,
+		"ad":   true,
+		"auto": true,
+	}
+	// supportedAmplificationFormats are the output formats the amplification
+	// report can be written in
+	supportedAmplificationFormats = map[string]bool{
+		"text": true, "txt": true,
+		"yaml": true, "yml": true,
+		"json": true, "jsonl": true,
+		"csv":      true,
+		"tsv":      true,
+		"pretty":   true,
+		"markdown": true, "md": true,
+		"auto": true,
+	}
+	// supportedHostSummaryFormats are the output formats --host-summary adds
+	// a summary of each host to
+	supportedHostSummaryFormats = map[string]bool{
+		"text": true, "txt": true,
+		"yaml": true, "yml": true,
+		"json":     true,
+		"pretty":   true,
+		"markdown": true, "md": true,
+		"auto": true,
+	}
+	// supportedPlanFormats are the output formats --dry-run can report the
+	// plan of the scan in
+	supportedPlanFormats = map[string]bool{
+		"text": true, "txt": true,
+		"yaml": true, "yml": true,
+		"json": true, "jsonl": true,
+		"csv":      true,
+		"tsv":      true,
+		"pretty":   true,
+		"markdown": true, "md": true,
+		"auto": true,
 	}
 )
 
@@ -67,42 +199,139 @@ func init() {
 	rootCmd.InitDefaultVersionFlag()
 	rootCmd.InitDefaultCompletionCmd()
 
+	// Config
+	rootCmd.Flags().StringVar(&configPath, "config", configPath, "Load default options from this YAML file (default: ~/.config/udpz/config.yaml)")
+
 	// Output
-	rootCmd.Flags().StringVarP(&outputPath, "output", "o", outputPath, "Save results to file")
+	rootCmd.Flags().StringArrayVarP(&outputSpecs, "output", "o", outputSpecs, "Save results to file as [FORMAT:]PATH (\"-\" for stdout), in the format of its extension unless --format is given. Repeat to write several outputs (e.g. -o results.json -o pretty:-)")
 	rootCmd.Flags().StringVarP(&logPath, "log", "O", logPath, "Output log messages to file")
 	rootCmd.Flags().BoolVarP(&outputAppend, "append", "a", outputAppend, "Append results to output file")
-	rootCmd.Flags().StringVarP(&outputFormat, "format", "f", outputFormat, "Output format [text, pretty, csv, tsv, json, yaml, auto]")
+	rootCmd.Flags().StringVarP(&outputFormat, "format", "f", outputFormat, "Output format [text, pretty, csv, tsv, markdown, json, jsonl, yaml, nmap, grepable, masscan, masscan-list, defectdojo, faraday, metasploit, ad, sqlite, template, auto]")
+	rootCmd.Flags().StringSliceVar(&outputFields, "fields", outputFields, "Columns and their order for text, pretty, csv, tsv and markdown output (e.g. host,port,service,state,banner)")
+	rootCmd.Flags().BoolVar(&openOnly, "open", openOnly, "Only report ports confirmed open, leaving out open|filtered ports in every output")
+	rootCmd.Flags().BoolVar(&allStates, "all-states", allStates, "Also report closed ports, which are left out of every output by default")
+	rootCmd.Flags().BoolVar(&hostSummary, "host-summary", hostSummary, "Add a summary of each host (probes sent, open, open|filtered and closed ports, round trip times and errors) to text, pretty, markdown, json and yaml output")
+	rootCmd.Flags().StringVar(&templatePath, "template", templatePath, "Render results through this Go text/template file for template output, given .Results, .Version, .Start and .End")
+	rootCmd.Flags().StringVar(&dbPath, "db", dbPath, "Also record the scan and its results in a SQLite database")
+	rootCmd.Flags().StringVar(&nucleiPath, "nuclei-out", nucleiPath, "Also write udp://HOST:PORT targets of open ports, and targets tagged with their protocol (e.g. snmp://HOST:PORT), to this file for nuclei or other follow-up tools")
+	rootCmd.Flags().StringVar(&esURL, "es-url", esURL, "Also index open ports into Elasticsearch or OpenSearch at this URL (e.g. http://localhost:9200)")
+	rootCmd.Flags().StringVar(&esIndex, "es-index", esIndex, "Elasticsearch index to write results to")
+	rootCmd.Flags().StringVar(&esUser, "es-user", esUser, "Elasticsearch username")
+	rootCmd.Flags().StringVar(&esPassword, "es-pass", esPassword, "Elasticsearch password")
+	rootCmd.Flags().StringVar(&syslogTarget, "syslog", syslogTarget, "Also send results and scan events to a syslog server as RFC 5424 messages (udp://HOST:PORT, tcp://HOST:PORT or unix:///PATH)")
 	rootCmd.Flags().StringVarP(&logFormat, "log-format", "L", logFormat, `Output log format [pretty, json, auto]`)
+	rootCmd.Flags().StringVar(&pcapPath, "pcap", pcapPath, "Write every probe and response to a pcap file")
+	rootCmd.Flags().BoolVar(&capture, "capture", capture, "Include the raw response bytes of open ports in JSON and YAML output")
+	rootCmd.Flags().UintVar(&captureLength, "capture-length", captureLength, "Maximum number of response bytes to capture")
+	rootCmd.Flags().BoolVar(&amplification, "amplification", amplification, "Instead of the results, report every probe that got a response, ranked by how many times larger the response was than the request")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", dryRun, "Instead of scanning, report the hosts, probes, packets and bytes the scan would send and how long it would take, without sending anything")
+	rootCmd.Flags().StringVar(&exitCodePolicy, "exit-code-policy", exitCodePolicy, "How the exit status reports a scan [findings, errors, none]: findings exits with 1 when open ports are found, 2 on errors and 3 when interrupted, errors exits with 0 when open ports are found, none always exits with 0")
+
+	// Targets
+	rootCmd.Flags().StringVar(&inputListPath, "input-list", inputListPath, "Read targets from file, one per line (\"-\" for stdin). Also accepted as -iL")
+	rootCmd.Flags().StringArrayVar(&importPaths, "import", importPaths, "Scan the live hosts of a previous nmap XML (-oX) or masscan JSON (-oJ) scan. Repeat to import several")
+	rootCmd.Flags().BoolVar(&importPorts, "import-ports", importPorts, "Only scan the UDP ports found open or open|filtered on any imported host")
+	rootCmd.Flags().StringSliceVar(&excludeTargets, "exclude", excludeTargets, "Exclude hosts, CIDRs, or ranges from the scan")
+	rootCmd.Flags().StringVar(&excludeListPath, "exclude-file", excludeListPath, "Read excluded targets from file, one per line")
+	rootCmd.Flags().StringVar(&roePath, "roe", roePath, "Enforce the allowed networks, maximum rate, per-host probe cap and blackout windows of this rules of engagement YAML file")
+	rootCmd.Flags().StringArrayVar(&overrideSpecs, "override", overrideSpecs, "Scan the hosts of a target with another rate, retries, timeout or port-tasks, as TARGET:KEY=VALUE,... (e.g. 10.1.0.0/24:rate=50,retries=1)")
+
+	// Discovery
+	rootCmd.Flags().StringSliceVar(&discoveryMethods, "discovery", discoveryMethods, "Only scan hosts that answer ICMP echo requests or probes for these services first (e.g. icmp,dns,ntp)")
+	rootCmd.Flags().BoolVar(&skipDiscovery, "skip-discovery", skipDiscovery, "Scan every host without discovering them first, overriding --discovery. Also accepted as -Pn")
+
+	// Probes
+	rootCmd.Flags().StringSliceVar(&probeFiles, "probe-file", probeFiles, "Load additional or overriding probes from a YAML file")
+	rootCmd.Flags().StringSliceVar(&proberPlugins, "prober", proberPlugins, "Load services whose exchanges are driven in code from a Go plugin (.so) exporting Probers")
+	rootCmd.Flags().StringVar(&snmpCommunitiesPath, "snmp-communities", snmpCommunitiesPath, "Also try each SNMP community string in this file, one per line, and report which ones agents accept")
+	rootCmd.Flags().StringVar(&tftpFilesPath, "tftp-files", tftpFilesPath, "Request each file name in this file, one per line, from TFTP servers instead of running-config and startup-config, and report which ones they serve")
+	rootCmd.Flags().StringVar(&radiusSecret, "radius-secret", radiusSecret, "Sign RADIUS probes with this shared secret guess instead of \"testing123\"; servers only answer requests signed with their secret")
+	rootCmd.Flags().BoolVar(&upnpDescribe, "upnp-describe", upnpDescribe, "Fetch the device description of UPnP devices from the LOCATION of their SSDP responses, to report their model and services")
+	rootCmd.Flags().StringVar(&vulnLookup, "vuln-lookup", vulnLookup, "Annotate results with the CVEs of their CPE names from an NVD CVE API 2.0 JSON file (gzipped if .gz), or from the NVD API at this http(s) URL (API key: $NVD_API_KEY)")
+	rootCmd.Flags().StringVarP(&portSpec, "ports", "P", portSpec, "Only scan these UDP ports, nmap-style (e.g. 53,161,500-520,U:1194)")
+	rootCmd.Flags().UintVar(&topPorts, "top-ports", topPorts, "Only scan the N most common UDP ports")
+	rootCmd.Flags().StringSliceVar(&serviceNames, "services", serviceNames, "Only use probes for these services or tags (e.g. dns,snmp,ntp). Combined with --ports, probes are sent to those ports instead")
+	rootCmd.Flags().UintVar(&probesPerService, "probes-per-service", probesPerService, "Only send the first N probes of each service (0 for all)")
+
+	rootCmd.MarkFlagsMutuallyExclusive("ports", "top-ports")
+	rootCmd.MarkFlagsMutuallyExclusive("import-ports", "ports")
+	rootCmd.MarkFlagsMutuallyExclusive("import-ports", "top-ports")
 
 	// Performance
 	rootCmd.Flags().UintVarP(&hostConcurrency, "host-tasks", "c", hostConcurrency, "Maximum Number of hosts to scan concurrently")
 	rootCmd.Flags().UintVarP(&portConcurrency, "port-tasks", "p", portConcurrency, "Number of Concurrent scan tasks per host")
 	rootCmd.Flags().UintVarP(&retransmissions, "retries", "r", retransmissions, "Number of probe retransmissions per probe")
-	rootCmd.Flags().UintVarP(&timeoutMs, "timeout", "t", timeoutMs, "UDP Probe timeout in milliseconds")
+	rootCmd.Flags().StringVarP(&timeoutSpec, "timeout", "t", timeoutSpec, "UDP Probe timeout in milliseconds, or \"auto\" to adapt it to the round trip time of each host")
+	rootCmd.Flags().Float64Var(&retryBackoff, "retry-backoff", retryBackoff, "Multiply the probe timeout by this factor on each retransmission (1 for a fixed interval)")
+	rootCmd.Flags().UintVar(&jitterMs, "jitter", jitterMs, "Wait a random delay of up to this many milliseconds before each probe")
+	rootCmd.Flags().UintVar(&rate, "rate", rate, "Maximum packets sent per second across all tasks (0 for unlimited)")
+	rootCmd.Flags().UintVar(&maxRate, "max-rate", maxRate, "Adapt the send rate to packet loss, ramping up to at most this many packets per second")
+	rootCmd.Flags().UintVar(&minRate, "min-rate", minRate, "Send rate to start at and lowest rate to back off to when packets are lost (default: a tenth of --max-rate)")
+	rootCmd.Flags().StringVar(&profileName, "profile", profileName, "Preset the timeout, retries, rate, jitter, concurrency and probes of options not given otherwise [stealth, default, aggressive, internet]")
+	rootCmd.Flags().BoolVar(&otMode, "ot", otMode, "Scan OT/ICS services (the ics tag, unless --services or --ports are given) at 20 packets per second with 1 retransmission, unless --rate, --max-rate or --retries are given")
 
 	// DNS
 	rootCmd.Flags().BoolVarP(&scanAllAddresses, "all", "A", scanAllAddresses, "Scan all resolved addresses instead of just the first")
+	rootCmd.Flags().BoolVarP(&ipv4Only, "ipv4", "4", ipv4Only, "Only scan IPv4 addresses")
+	rootCmd.Flags().BoolVarP(&ipv6Only, "ipv6", "6", ipv6Only, "Only scan IPv6 addresses")
+
+	rootCmd.Flags().StringSliceVar(&resolvers, "resolvers", resolvers, "Resolve host names with these DNS servers, as IP or IP:PORT, instead of the system's (e.g. 1.1.1.1,10.0.0.53)")
+	rootCmd.Flags().StringVar(&dohURL, "doh", dohURL, "Resolve host names with this DNS over HTTPS server instead of the system's resolver (e.g. https://cloudflare-dns.com/dns-query)")
+	rootCmd.Flags().BoolVarP(&noResolve, "no-resolve", "n", noResolve, "Never resolve host names, only scan targets given as addresses")
+	rootCmd.Flags().UintVar(&resolveConcurrency, "resolve-tasks", resolveConcurrency, "Maximum number of target host names to resolve concurrently, ahead of the scan")
+
+	rootCmd.MarkFlagsMutuallyExclusive("ipv4", "ipv6")
+	rootCmd.MarkFlagsMutuallyExclusive("resolvers", "doh", "no-resolve")
+
+	// Source
+	rootCmd.Flags().StringVarP(&interfaceName, "interface", "e", interfaceName, "Send probes from this network interface (e.g. eth1)")
+	rootCmd.Flags().StringVar(&sourceIP, "source-ip", sourceIP, "Send probes from this local IP address")
+	rootCmd.Flags().StringVar(&sourcePort, "source-port", sourcePort, "Send probes from this local UDP port or range of ports (e.g. 53 or 40000-40100)")
+	rootCmd.Flags().UintVar(&ttl, "ttl", ttl, "Set the IP TTL (IPv6 hop limit) of probes, 0 keeps the system default")
+	rootCmd.Flags().UintVar(&ttlSweep, "ttl-sweep", ttlSweep, "Instead of scanning, send probes with TTLs from 1 to N and report where along the path they are dropped (requires root)")
+
+	rootCmd.Flags().UintVar(&sockets, "sockets", sockets, "Send probes from a pool of N shared sockets per address family instead of a socket per probe, to keep file descriptors low at high concurrency")
+	rootCmd.Flags().BoolVar(&batch, "batch", batch, "Send and receive probes of shared sockets in batches with sendmmsg and recvmmsg, for fewer system calls on large scans (Linux only)")
+
+	rootCmd.MarkFlagsMutuallyExclusive("ttl", "ttl-sweep")
+	rootCmd.MarkFlagsMutuallyExclusive("sockets", "ttl-sweep")
+	rootCmd.MarkFlagsMutuallyExclusive("batch", "ttl-sweep")
+
+	// Proxy
+	rootCmd.Flags().StringVarP(&socks5Address, "socks", "S", socks5Address, "Relay probes through a SOCKS5 proxy with UDP ASSOCIATE support, as HOST:PORT")
+	rootCmd.Flags().StringVar(&socks5User, "socks-user", socks5User, "SOCKS5 proxy username")
+	rootCmd.Flags().StringVar(&socks5Password, "socks-pass", socks5Password, "SOCKS5 proxy password")
+	rootCmd.Flags().UintVar(&socks5Timeout, "socks-timeout", socks5Timeout, "SOCKS5 proxy connection timeout in milliseconds")
 
-	/*
-		TODO
-		// Proxy
-		rootCmd.Flags().StringVarP(&socks5Address, "socks", "S", socks5Address, "SOCKS5 proxy address as HOST:PORT")
-		rootCmd.Flags().StringVar(&socks5User, "socks-user", socks5User, "SOCKS5 proxy username")
-		rootCmd.Flags().StringVar(&socks5Password, "socks-pass", socks5Password, "SOCKS5 proxy password")
-		rootCmd.Flags().UintVar(&socks5Timeout, "socks-timeout", socks5Timeout, "SOCKS5 proxy timeout")
-	*/
+	rootCmd.MarkFlagsMutuallyExclusive("socks", "interface")
+	rootCmd.MarkFlagsMutuallyExclusive("socks", "source-ip")
+	rootCmd.MarkFlagsMutuallyExclusive("socks", "source-port")
+	rootCmd.MarkFlagsMutuallyExclusive("socks", "ttl")
+	rootCmd.MarkFlagsMutuallyExclusive("socks", "ttl-sweep")
+	rootCmd.MarkFlagsMutuallyExclusive("socks", "sockets")
+	rootCmd.MarkFlagsMutuallyExclusive("socks", "batch")
+	rootCmd.MarkFlagsMutuallyExclusive("socks", "upnp-describe")
 
 	// Logging
 	rootCmd.Flags().BoolVarP(&debug, "debug", "D", debug, "Enable debug logging (Very noisy!)")
 	rootCmd.Flags().BoolVarP(&trace, "trace", "T", trace, "Enable trace logging (Very noisy!)")
 	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", quiet, "Disable info logging")
 	rootCmd.Flags().BoolVarP(&silent, "silent", "s", silent, "Disable ALL logging")
+	rootCmd.Flags().DurationVar(&watchInterval, "watch", watchInterval, "Rescan on this interval (e.g. 1h) and only report ports that changed")
+	rootCmd.Flags().StringVar(&watchStatePath, "watch-state", watchStatePath, "Keep the latest results of --watch in this file, so a restarted watch only reports new changes")
+	rootCmd.Flags().BoolVar(&showProgress, "progress", showProgress, "Show a progress bar on stderr (ignored when stderr is not a terminal)")
+
+	rootCmd.MarkFlagsMutuallyExclusive("watch", "ttl-sweep")
+	rootCmd.MarkFlagsMutuallyExclusive("open", "all-states")
+
+	// The estimate command takes the options of the scan it estimates
+	estimateCmd.Flags().AddFlagSet(rootCmd.Flags())
 }
 
 var rootCmd = &cobra.Command{
 	Use:     "udpz [flags] [targets ...]",
 	Short:   "Speedy probe-oriented UDP port scanner",
-	Version: "0.0.1-beta",
+	Version: scan.VERSION,
 	Long: `
   ┳┳  ┳┓  ┏┓  ┏┓
   ┃┃━━┃┃━━┃┃━━┏┛
@@ -111,7 +340,7 @@ var rootCmd = &cobra.Command{
   Author: Bryan McNulty (@bryanmcnulty)
   Source: https://github.com/FalconOps-Cybersecurity/udpz`,
 
-	Args: cobra.MinimumNArgs(1),
+	Args: cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, targets []string) (err error) {
 
 		var outputFile *os.File
@@ -120,6 +349,24 @@ var rootCmd = &cobra.Command{
 		var outputFlags int = os.O_WRONLY | os.O_CREATE
 		var logFileFlags int = os.O_WRONLY | os.O_CREATE | os.O_APPEND
 
+		var usedConfigPath string
+
+		openGiven, allStatesGiven := cmd.Flags().Changed("open"), cmd.Flags().Changed("all-states")
+
+		if usedConfigPath, err = loadConfig(cmd.Flags(), cmd.Flags(), configPath); err != nil {
+			return err
+		}
+		if profileName != "" {
+			if err = applyProfile(cmd.Flags(), profileName); err != nil {
+				return err
+			}
+		}
+		if otMode {
+			applyOTDefaults(cmd.Flags())
+		}
+		if err = settleStates(openGiven, allStatesGiven); err != nil {
+			return err
+		}
 		outputFormat = strings.ToLower(outputFormat)
 
 		if sup, ok := supportedOutputFormats[outputFormat]; !ok || !sup {
@@ -128,11 +375,127 @@ var rootCmd = &cobra.Command{
 		if sup, ok := supportedLogFormats[logFormat]; !ok || !sup {
 			return errors.New("invalid log format: " + logFormat)
 		}
-		if portConcurrency < 1 || hostConcurrency < 1 {
+		outputs, err := parseOutputs(outputSpecs, outputFormat)
+
+		if err != nil {
+			return err
+		}
+		if len(outputs) > 1 && (watchInterval > 0 || ttlSweep > 0 || dryRun || estimateOnly) {
+			return errors.New("--watch, --ttl-sweep, --dry-run and estimate write a single output")
+		}
+		outputPath, outputFormat = outputs[0].path, outputs[0].format
+
+		if nucleiPath != "" && (watchInterval > 0 || ttlSweep > 0 || dryRun || estimateOnly || amplification) {
+			return errors.New("--nuclei-out cannot be combined with --watch, --ttl-sweep, --dry-run, estimate or --amplification")
+		}
+
+		if hostSummary {
+			if watchInterval > 0 || ttlSweep > 0 || dryRun || estimateOnly || amplification {
+				return errors.New("--host-summary cannot be combined with --watch, --ttl-sweep, --dry-run, estimate or --amplification")
+			}
+			for _, output := range outputs {
+				if sup, ok := supportedHostSummaryFormats[output.format]; !ok || !sup {
+					return errors.New("invalid output format for --host-summary: " + output.format)
+				}
+			}
+		}
+
+		exitCodePolicy = strings.ToLower(exitCodePolicy)
+
+		if sup, ok := exitCodePolicies[exitCodePolicy]; !ok || !sup {
+			return errors.New("invalid exit code policy: " + exitCodePolicy)
+		}
+		if len(targets) == 0 && inputListPath == "" && len(importPaths) == 0 {
+			return errors.New("requires at least 1 target")
+		}
+		if importPorts && len(importPaths) == 0 {
+			return errors.New("--import-ports requires --import")
+		}
+		var ports map[uint16]bool
+
+		if portSpec != "" {
+			if ports, err = data.ParsePorts(portSpec); err != nil {
+				return err
+			}
+		}
+		if portConcurrency < 1 || hostConcurrency < 1 || resolveConcurrency < 1 {
 			return errors.New("concurrency value must be > 0")
 		}
-		if timeoutMs < 1 {
-			return errors.New("timeout value must be > 0")
+		readTimeout, adaptiveTimeout, err := parseTimeout(timeoutSpec)
+
+		if err != nil {
+			return err
+		}
+		if retryBackoff < 1 {
+			return errors.New("retry backoff factor must be >= 1")
+		}
+		if err = scan.CheckFields(outputFields); err != nil {
+			return err
+		}
+		overrides, err := targetOverrides(overrideSpecs)
+
+		if err != nil {
+			return err
+		}
+		if watchInterval < 0 {
+			return errors.New("watch interval must be > 0")
+		}
+		if watchInterval > 0 {
+			if sup, ok := supportedWatchFormats[outputFormat]; !ok || !sup {
+				return errors.New("invalid output format for --watch: " + outputFormat)
+			}
+			if outputFormat == "auto" && outputPath != "" {
+				outputFormat = "jsonl"
+			}
+		}
+		if ttlSweep > 0 {
+			if sup, ok := supportedWatchFormats[outputFormat]; !ok || !sup {
+				return errors.New("invalid output format for --ttl-sweep: " + outputFormat)
+			}
+		}
+		if dryRun || estimateOnly {
+			if watchInterval > 0 || ttlSweep > 0 || amplification {
+				return errors.New("--dry-run and estimate cannot be combined with --watch, --ttl-sweep or --amplification")
+			}
+			if sup, ok := supportedPlanFormats[outputFormat]; !ok || !sup {
+				return errors.New("invalid output format for --dry-run and estimate: " + outputFormat)
+			}
+		}
+		if amplification {
+			if watchInterval > 0 || ttlSweep > 0 {
+				return errors.New("--amplification cannot be combined with --watch or --ttl-sweep")
+			}
+			for _, output := range outputs {
+				if sup, ok := supportedAmplificationFormats[output.format]; !ok || !sup {
+					return errors.New("invalid output format for --amplification: " + output.format)
+				}
+			}
+		}
+		// SQLite outputs name the database the scan is recorded in
+		for _, output := range outputs {
+			if output.format != "sqlite" {
+				continue
+			}
+			if output.path == "" && dbPath == "" {
+				return errors.New("sqlite output requires a database path (--output or --db)")
+			}
+			if output.path != "" && dbPath != "" && output.path != dbPath {
+				return errors.New("only one SQLite database can be written: " + output.path)
+			}
+			if output.path != "" {
+				dbPath = output.path
+			}
+		}
+		for _, output := range outputs {
+			if output.format != "template" || resultsTemplate != nil {
+				continue
+			}
+			if templatePath == "" {
+				return errors.New("template output requires a template (--template)")
+			}
+			if resultsTemplate, err = scan.LoadTemplate(templatePath); err != nil {
+				return err
+			}
 		}
 		if outputAppend {
 			outputFlags |= os.O_APPEND
@@ -152,14 +515,22 @@ var rootCmd = &cobra.Command{
 
 		zerolog.TimeFieldFormat = zerolog.TimeFormatUnixNano
 
+		var bar *progressBar
+		var logOutput io.Writer = os.Stderr
+
+		if showProgress && isTerminal(os.Stderr) {
+			bar = newProgressBar(os.Stderr)
+			logOutput = bar
+		}
+
 		if logPath == "" {
-			log = zerolog.New(os.Stderr).
+			log = zerolog.New(logOutput).
 				With().
 				Timestamp().
 				Caller().
 				Logger()
 			if logFormat == "auto" || logFormat == "pretty" {
-				log = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+				log = log.Output(zerolog.ConsoleWriter{Out: logOutput})
 			}
 		} else if logFile, err = os.OpenFile(logPath, logFileFlags, 0o644); err == nil {
 
@@ -174,13 +545,13 @@ var rootCmd = &cobra.Command{
 			}
 
 		} else {
-			log = zerolog.New(os.Stderr).
+			log = zerolog.New(logOutput).
 				With().
 				Timestamp().
 				Caller().
 				Logger()
 			if logFormat == "auto" || logFormat == "pretty" {
-				log = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+				log = log.Output(zerolog.ConsoleWriter{Out: logOutput})
 			}
 			log.Error().
 				AnErr("error", err).
@@ -188,33 +559,471 @@ var rootCmd = &cobra.Command{
 				Msg("Could not open log file for writing")
 		}
 
-		var scanner scan.UdpProbeScanner
+		// Failures from here on are logged, and only end with an exit status
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+
+		if usedConfigPath != "" {
+			log.Debug().
+				Str("config", usedConfigPath).
+				Msg("Loaded config file")
+		}
+		if profileName != "" {
+			log.Debug().
+				Str("profile", profileName).
+				Msg("Applied scan profile")
+		}
+
+		if inputListPath != "" {
+			var inputList []string
+
+			if inputList, err = readInputList(inputListPath); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("input_list", inputListPath).
+					Msg("Failed to read targets from input list")
+				return exitStatus(exitErrors)
+			}
+			log.Debug().
+				Str("input_list", inputListPath).
+				Int("target_count", len(inputList)).
+				Msg("Loaded targets from input list")
+
+			targets = append(targets, inputList...)
+		}
+		for _, importPath := range importPaths {
+
+			imported, err := importTargets(importPath)
+
+			if err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("import", importPath).
+					Msg("Failed to import targets from scan results")
+				return exitStatus(exitErrors)
+			}
+			log.Debug().
+				Str("import", importPath).
+				Int("host_count", len(imported.Hosts)).
+				Int("port_count", len(imported.Ports)).
+				Msg("Imported targets from scan results")
+
+			targets = append(targets, imported.Hosts...)
+
+			if importPorts {
+				if ports == nil {
+					ports = map[uint16]bool{}
+				}
+				for port := range imported.Ports {
+					ports[port] = true
+				}
+			}
+		}
+		if importPorts {
+			if len(ports) == 0 {
+				log.WithLevel(zerolog.FatalLevel).
+					Strs("import", importPaths).
+					Msg("No open or open|filtered UDP ports were imported")
+				return exitStatus(exitErrors)
+			}
+			portSpec = portList(ports)
+		}
+
+		options := scanOptions(log, readTimeout, adaptiveTimeout)
+		options.Overrides = overrides
+		options.Socks5Address = socks5Address
+		options.Socks5User = socks5User
+		options.Socks5Password = socks5Password
+		options.Socks5Timeout = time.Duration(socks5Timeout) * time.Millisecond
+
+		if capture {
+			options.CaptureLength = int(captureLength)
+		}
+
+		if vulnLookup != "" {
+			if options.Vulnerabilities, err = vulnerabilitySource(vulnLookup); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("vuln_lookup", vulnLookup).
+					Msg("Failed to load vulnerability feed")
+				return exitStatus(exitErrors)
+			}
+			log.Debug().
+				Str("vuln_lookup", vulnLookup).
+				Msg("Looking up vulnerabilities of identified versions")
+		}
+
+		if pcapPath != "" {
+			var pcapFile *os.File
+
+			if pcapFile, err = os.Create(pcapPath); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("pcap_path", pcapPath).
+					Msg("Could not open pcap file for writing")
+				return exitStatus(exitErrors)
+			}
+			defer pcapFile.Close()
+
+			if options.Pcap, err = scan.NewPcapWriter(pcapFile); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("pcap_path", pcapPath).
+					Msg("Failed to write pcap header")
+				return exitStatus(exitErrors)
+			}
+		}
+
+		if rate > 0 {
+			log.Debug().
+				Uint("rate", rate).
+				Msg("Limiting packet rate")
+		}
+
+		services := data.UDP_SERVICES
+
+		for _, probeFile := range probeFiles {
+			var fileServices map[string]data.UdpService
+
+			if fileServices, err = data.LoadProbeFile(probeFile); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("probe_file", probeFile).
+					Msg("Failed to load probe file")
+				return exitStatus(exitErrors)
+			}
+			services = data.MergeServices(services, fileServices)
+
+			log.Debug().
+				Str("probe_file", probeFile).
+				Int("service_count", len(fileServices)).
+				Msg("Loaded probe file")
+		}
+
+		for _, proberPlugin := range proberPlugins {
+			var probers []scan.Prober
+
+			if probers, err = scan.LoadProberPlugin(proberPlugin); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("prober", proberPlugin).
+					Msg("Failed to load prober plugin")
+				return exitStatus(exitErrors)
+			}
+			for _, prober := range probers {
+				if services, err = scan.RegisterProber(services, prober); err != nil {
+					log.WithLevel(zerolog.FatalLevel).
+						Err(err).
+						Str("prober", proberPlugin).
+						Msg("Failed to register prober")
+					return exitStatus(exitErrors)
+				}
+			}
+			log.Debug().
+				Str("prober", proberPlugin).
+				Int("prober_count", len(probers)).
+				Msg("Loaded prober plugin")
+		}
+
+		if snmpCommunitiesPath != "" {
+			var communities []string
+
+			if communities, err = data.LoadCommunities(snmpCommunitiesPath); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("snmp_communities", snmpCommunitiesPath).
+					Msg("Failed to read SNMP community strings")
+				return exitStatus(exitErrors)
+			}
+			services = data.AddSNMPCommunities(services, communities)
+
+			log.Debug().
+				Str("snmp_communities", snmpCommunitiesPath).
+				Int("community_count", len(communities)).
+				Msg("Loaded SNMP community strings")
+		}
+
+		if radiusSecret != "" {
+			services = data.SetRADIUSSecret(services, radiusSecret)
+		}
+
+		if tftpFilesPath != "" {
+			var files []string
+
+			if files, err = data.LoadTFTPFiles(tftpFilesPath); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("tftp_files", tftpFilesPath).
+					Msg("Failed to read TFTP file names")
+				return exitStatus(exitErrors)
+			}
+			services = data.SetTFTPFiles(services, files)
+
+			log.Debug().
+				Str("tftp_files", tftpFilesPath).
+				Int("file_count", len(files)).
+				Msg("Loaded TFTP file names")
+		}
+
+		if excludeListPath != "" {
+			var excludeList []string
+
+			if excludeList, err = readInputList(excludeListPath); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("exclude_file", excludeListPath).
+					Msg("Failed to read excluded targets")
+				return exitStatus(exitErrors)
+			}
+			excludeTargets = append(excludeTargets, excludeList...)
+		}
+		if len(excludeTargets) > 0 {
+			options.Exclude = excludeTargets
+
+			log.Debug().
+				Int("exclude_count", len(excludeTargets)).
+				Msg("Loaded excluded targets")
+		}
+
+		if roePath != "" {
+			if options.RulesOfEngagement, err = scan.LoadRulesOfEngagement(roePath); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("roe", roePath).
+					Msg("Failed to load rules of engagement")
+				return exitStatus(exitErrors)
+			}
+			log.Info().
+				Str("roe", roePath).
+				Int("allowed_count", len(options.RulesOfEngagement.Allowed)).
+				Float64("max_rate", options.RulesOfEngagement.MaxRate).
+				Uint64("max_probes_per_host", options.RulesOfEngagement.MaxProbesPerHost).
+				Int("blackout_count", len(options.RulesOfEngagement.Blackouts)).
+				Msg("Enforcing rules of engagement")
+		}
+
+		if len(serviceNames) > 0 {
+			if services, err = data.FilterServices(services, serviceNames); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Msg("Failed to select probes by service")
+				return exitStatus(exitErrors)
+			}
+			log.Debug().
+				Strs("services", serviceNames).
+				Int("service_count", len(services)).
+				Msg("Filtered probes by service")
+		}
+		if topPorts > 0 {
+			services = data.FilterPorts(services, data.TopPorts(services, int(topPorts)))
+
+			log.Debug().
+				Uint("top_ports", topPorts).
+				Int("service_count", len(services)).
+				Msg("Filtered probes by most common ports")
 
-		if scanner, err = scan.NewUdpProbeScanner(
-			log,
-			scanAllAddresses,
-			hostConcurrency,
-			portConcurrency,
-			retransmissions,
-			time.Duration(timeoutMs)*time.Millisecond,
-			socks5Address,
-			socks5User,
-			socks5Password,
-			int(socks5Timeout)); err != nil {
+		} else if ports != nil && len(serviceNames) > 0 {
+			services = data.AssignPorts(services, ports)
 
-			log.Fatal().
+			log.Debug().
+				Str("ports", portSpec).
+				Msg("Assigned ports to selected services")
+
+		} else if ports != nil {
+			services = data.FilterPorts(services, ports)
+
+			log.Debug().
+				Str("ports", portSpec).
+				Int("service_count", len(services)).
+				Msg("Filtered probes by port")
+		}
+		if probesPerService > 0 {
+			services = data.LimitProbes(services, int(probesPerService))
+
+			log.Debug().
+				Uint("probes_per_service", probesPerService).
+				Msg("Limited probes per service")
+		}
+		if len(services) == 0 {
+			log.WithLevel(zerolog.FatalLevel).
+				Msg("No probes match the selected ports")
+			return exitStatus(exitErrors)
+		}
+		options.Services = services
+
+		// JSON lines are written as soon as each result is confirmed
+		streaming := watchInterval == 0 && ttlSweep == 0 && !amplification && !dryRun && !estimateOnly
+
+		for _, output := range outputs {
+			if streaming && output.format == "jsonl" {
+				outputFile = output.open(log, outputFlags)
+				if outputFile != os.Stdout {
+					defer outputFile.Close()
+				}
+				options.Sinks = append(options.Sinks, scan.NewJsonlStream(outputFile))
+			}
+		}
+
+		// Watching only sends the changes between scans, not every result
+		var changeSinks []scan.ChangeSink
+
+		if esURL != "" {
+			started := time.Now().UTC()
+
+			elasticSink := scan.NewElasticSink(esURL, esIndex, esUser, esPassword, scan.ElasticScan{
+				ID:      started.Format("20060102T150405.000Z"),
+				Started: started,
+				Command: strings.Join(redactedArgs(), " "),
+				Version: scan.VERSION,
+			})
+			if watchInterval > 0 {
+				changeSinks = append(changeSinks, elasticSink)
+			} else {
+				options.Sinks = append(options.Sinks, elasticSink)
+			}
+			log.Debug().
+				Str("es_url", esURL).
+				Str("es_index", esIndex).
+				Msg("Indexing results into Elasticsearch")
+		}
+
+		var syslogSink *scan.SyslogSink
+
+		if syslogTarget != "" {
+			if syslogSink, err = scan.NewSyslogSink(syslogTarget); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Str("syslog", syslogTarget).
+					Msg("Could not connect to syslog server")
+				return exitStatus(exitErrors)
+			}
+			defer syslogSink.Close()
+
+			if watchInterval > 0 {
+				changeSinks = append(changeSinks, syslogSink)
+			} else {
+				options.Sinks = append(options.Sinks, syslogSink)
+			}
+		}
+
+		var scanner *scan.UdpProbeScanner
+
+		if scanner, err = scan.NewUdpProbeScanner(options); err != nil {
+			log.WithLevel(zerolog.FatalLevel).
 				Err(err).
 				Msg("Failed to initialize scanner")
+			return exitStatus(exitErrors)
+		}
+
+		if dryRun || estimateOnly {
+			var plan scan.Plan
+
+			if estimateOnly {
+				plan = scanner.Estimate(targets)
+
+			} else if plan, err = scanner.Plan(context.Background(), targets); err != nil {
+				log.WithLevel(zerolog.FatalLevel).
+					Err(err).
+					Msg("Failed to plan scan")
+				return exitStatus(exitErrors)
+			}
+			log.Info().
+				Uint64("host_count", plan.HostCount).
+				Uint64("packets", plan.Packets).
+				Uint64("bytes", plan.Bytes).
+				Float64("duration", plan.DurationSeconds).
+				Msg("Planned scan, nothing was sent")
+
+			outputFile = openOutput(log, outputFlags)
+			if outputFile != os.Stdout {
+				defer outputFile.Close()
+			}
+			return plan.Save(outputFormat, outputFile)
 		}
 
 		var scanStartTime, scanEndTime time.Time
 
+		// Summing up the scan first gives a chance to stop one that is far
+		// larger than intended
+		if ttlSweep == 0 {
+			estimate := scanner.Estimate(targets)
+
+			log.Info().
+				Uint64("host_count", estimate.HostCount).
+				Uint64("probes", estimate.Probes).
+				Uint64("packets", estimate.Packets).
+				Uint64("bytes", estimate.Bytes).
+				Float64("duration", estimate.DurationSeconds).
+				Msg("Estimated scan")
+		}
+
 		log.Info().
 			Msg("Starting scanner")
 
+		// Stop sending probes on the first interrupt so partial results can be
+		// saved. Later interrupts fall through to the default handler and exit.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+
+		if watchInterval > 0 {
+			outputFile = openOutput(log, outputFlags)
+			if outputFile != os.Stdout {
+				defer outputFile.Close()
+			}
+			watch := &watcher{
+				log:       log,
+				scanner:   scanner,
+				targets:   targets,
+				interval:  watchInterval,
+				statePath: watchStatePath,
+				bar:       bar,
+				syslog:    syslogSink,
+				sinks:     changeSinks,
+				output:    outputFile,
+			}
+			log.Info().
+				Dur("interval", watchInterval).
+				Msg("Watching for changes")
+
+			return watch.run(ctx)
+		}
+
+		if ttlSweep > 0 {
+			outputFile = openOutput(log, outputFlags)
+			if outputFile != os.Stdout {
+				defer outputFile.Close()
+			}
+			return runTTLSweep(ctx, log, scanner, targets, outputFile)
+		}
+
+		if bar != nil {
+			bar.start(scanner)
+		}
+		if syslogSink != nil {
+			syslogSink.Event("scan-start", "Scan started", map[string]string{
+				"targets": strings.Join(targets, ","),
+			})
+		}
 		scanStartTime = time.Now()
-		scanner.Scan(targets)
+		_, err = scanner.Scan(ctx, targets)
 		scanEndTime = time.Now()
+		interrupted := err != nil
+		stop()
+
+		if syslogSink != nil {
+			syslogSink.Event("scan-end", "Scan complete", map[string]string{
+				"results":  strconv.Itoa(scanner.Length()),
+				"duration": scanEndTime.Sub(scanStartTime).String(),
+			})
+		}
+
+		if bar != nil {
+			bar.stop()
+		}
 
 		log.Info().
 			Time("start", scanStartTime).
@@ -222,47 +1031,297 @@ var rootCmd = &cobra.Command{
 			TimeDiff("duration", scanEndTime, scanStartTime).
 			Msg("Scan complete")
 
-		if scanner.Length() > 0 {
+		// Results that could not be saved count as errors of the scan
+		var saveErrors uint64
 
-			if outputPath == "" {
-				outputFile = os.Stdout
-				if outputFormat == "auto" {
-					outputFormat = "pretty"
-				}
-
-			} else if outputFile, err = os.OpenFile(outputPath, outputFlags, 0o644); err == nil {
-				if outputFormat == "auto" {
-					outputFormat = "json"
-				}
-				defer outputFile.Close()
+		if dbPath != "" {
+			if err := scanner.SaveSQLite(dbPath, redactedArgs()); err != nil {
+				log.Error().
+					Err(err).
+					Str("db_path", dbPath).
+					Msg("Failed to save results to database")
 
+				saveErrors++
 			} else {
-				log.Error().
-					AnErr("error", err).
-					Str("outputPath", outputPath).
-					Msg("Could not open output file for writing")
-				outputFile = os.Stdout
-				if outputFormat == "auto" {
-					outputFormat = "pretty"
-				}
-			}
-			if outputFormat == "json" || outputFormat == "jsonl" {
 				log.Info().
-					Str("format", "json")
-				scanner.SaveJson(outputFile)
-			} else if outputFormat == "yml" || outputFormat == "yaml" {
-				scanner.SaveYAML(outputFile)
+					Str("db_path", dbPath).
+					Int("result_count", scanner.Length()).
+					Msg("Saved results to database")
+			}
+		}
+		if nucleiPath != "" {
+			if err := saveNucleiTargets(scanner, nucleiPath); err != nil {
+				log.Error().
+					Err(err).
+					Str("nuclei_path", nucleiPath).
+					Msg("Failed to write nuclei targets")
+
+				saveErrors++
 			} else {
-				scanner.SaveTable(outputFormat, outputFile)
+				log.Info().
+					Str("nuclei_path", nucleiPath).
+					Msg("Saved nuclei targets")
 			}
 		}
-		return
+
+		for _, output := range outputs {
+
+			// Host summaries count the ports left out of the results, so they
+			// are written even when no results were kept
+			if (scanner.Length() == 0 && !hostSummary) || output.format == "sqlite" || (streaming && output.format == "jsonl") {
+				continue
+			}
+			outputFile = output.open(log, outputFlags)
+			if outputFile != os.Stdout {
+				defer outputFile.Close()
+			}
+
+			if err = saveResults(scanner, output.format, outputFile); err != nil {
+				log.Error().
+					Err(err).
+					Str("format", output.format).
+					Msg("Failed to write results")
+
+				saveErrors++
+			}
+		}
+		progress := scanner.Progress()
+
+		if status := scanExitStatus(exitCodePolicy, progress.OpenPorts, progress.Errors+saveErrors, interrupted); status != exitNoOpenPorts {
+			return exitStatus(status)
+		}
+		return nil
 	},
 }
 
+// openOutput opens the results file, falling back to stdout, and resolves the
+// "auto" output format accordingly
+func openOutput(log zerolog.Logger, outputFlags int) (outputFile *os.File) {
+
+	output := resultOutput{format: outputFormat, path: outputPath}
+	outputFile = output.open(log, outputFlags)
+	outputFormat = output.format
+
+	return
+}
+
+// readInputList reads targets from the file at path, or from stdin if path is "-"
+func readInputList(path string) (targets []string, err error) {
+
+	var inputFile *os.File
+
+	if path == "-" {
+		inputFile = os.Stdin
+	} else if inputFile, err = os.Open(path); err == nil {
+		defer inputFile.Close()
+	} else {
+		return
+	}
+	return scan.ReadTargets(inputFile)
+}
+
+// importTargets reads the live hosts and UDP ports of previous scan results
+func importTargets(path string) (imported scan.ImportedTargets, err error) {
+
+	importFile, err := os.Open(path)
+
+	if err != nil {
+		return
+	}
+	defer importFile.Close()
+
+	return scan.ImportTargets(importFile)
+}
+
+// portList formats ports as a sorted, comma separated list
+func portList(ports map[uint16]bool) string {
+
+	sorted := make([]int, 0, len(ports))
+
+	for port := range ports {
+		sorted = append(sorted, int(port))
+	}
+	sort.Ints(sorted)
+
+	list := make([]string, len(sorted))
+
+	for i, port := range sorted {
+		list[i] = strconv.Itoa(port)
+	}
+	return strings.Join(list, ",")
+}
+
+// normalizeArgs translates nmap-style flags that pflag cannot parse natively
+func normalizeArgs(args []string) []string {
+
+	normalized := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		if arg == "--" {
+			return append(normalized, args[len(normalized):]...)
+		} else if arg == "-iL" {
+			arg = "--input-list"
+		} else if strings.HasPrefix(arg, "-iL=") {
+			arg = "--input-list=" + strings.TrimPrefix(arg, "-iL=")
+		} else if arg == "-Pn" {
+			arg = "--skip-discovery"
+		}
+		normalized = append(normalized, arg)
+	}
+	return normalized
+}
+
+// applyOTDefaults selects the ICS services and limits the packet rate and
+// retransmissions of scans of OT networks, whose controllers can fault on
+// unexpected or excessive traffic. Options given on the command line or in the
+// config file are kept.
+func applyOTDefaults(flags *pflag.FlagSet) {
+
+	if !flags.Changed("services") && !flags.Changed("ports") && !flags.Changed("top-ports") {
+		serviceNames = otServices
+	}
+	if !flags.Changed("rate") && !flags.Changed("max-rate") {
+		rate = otRate
+	}
+	if !flags.Changed("retries") {
+		retransmissions = otRetransmissions
+	}
+}
+
+// parseTimeout parses the --timeout option, which is either a number of
+// milliseconds or "auto" to adapt the timeout to each host, waiting at most
+// the default timeout
+func parseTimeout(spec string) (timeout time.Duration, adaptive bool, err error) {
+
+	if strings.EqualFold(spec, "auto") {
+		return scan.DefaultOptions().ReadTimeout, true, nil
+	}
+	milliseconds, err := strconv.ParseUint(spec, 10, 32)
+
+	if err != nil || milliseconds < 1 {
+		return 0, false, errors.New("timeout value must be > 0 or \"auto\"")
+	}
+	return time.Duration(milliseconds) * time.Millisecond, false, nil
+}
+
+// settleStates keeps whichever of --open and --all-states was given on the
+// command line when the config file sets the other
+func settleStates(openGiven bool, allStatesGiven bool) error {
+
+	switch {
+	case !openOnly || !allStates:
+	case allStatesGiven && !openGiven:
+		openOnly = false
+	case openGiven && !allStatesGiven:
+		allStates = false
+	default:
+		return errors.New("open and all-states cannot be used together")
+	}
+	return nil
+}
+
+// scanOptions returns the scanner options set by the scan, source and
+// discovery flags shared by the scan and serve commands
+func scanOptions(log zerolog.Logger, readTimeout time.Duration, adaptiveTimeout bool) scan.Options {
+
+	options := scan.DefaultOptions()
+	options.Logger = log
+	options.ScanAllAddresses = scanAllAddresses
+	options.HostConcurrency = hostConcurrency
+	options.PortConcurrency = portConcurrency
+	options.Retransmissions = retransmissions
+	options.RetryBackoff = retryBackoff
+	options.Jitter = time.Duration(jitterMs) * time.Millisecond
+	options.ReadTimeout = readTimeout
+	options.AdaptiveTimeout = adaptiveTimeout
+	options.Rate = float64(rate)
+	options.MinRate = float64(minRate)
+	options.MaxRate = float64(maxRate)
+	options.Interface = interfaceName
+	options.SourceIP = sourceIP
+	options.SourcePort = sourcePort
+	options.TTL = int(ttl)
+	options.Sockets = int(sockets)
+	options.Batch = batch
+	options.UPnPDescriptions = upnpDescribe
+	options.Resolvers = resolvers
+	options.DoHURL = dohURL
+	options.NoResolve = noResolve
+	options.ResolveConcurrency = resolveConcurrency
+	options.OpenOnly = openOnly
+	options.AllStates = allStates
+
+	if !skipDiscovery {
+		options.Discovery = discoveryMethods
+	}
+	if ipv4Only {
+		options.AddressFamily = 4
+	} else if ipv6Only {
+		options.AddressFamily = 6
+	}
+	return options
+}
+
+// targetOverrides parses the --override options
+func targetOverrides(specs []string) (overrides []scan.TargetOverride, err error) {
+
+	for _, spec := range specs {
+
+		var override scan.TargetOverride
+
+		if override, err = scan.ParseTargetOverride(spec); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override)
+	}
+	return
+}
+
+// vulnerabilitySource returns the NVD API client for http(s) URLs, and the
+// feed loaded from the file otherwise
+func vulnerabilitySource(source string) (scan.VulnerabilitySource, error) {
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return scan.NewNVDClient(source, os.Getenv("NVD_API_KEY")), nil
+	}
+	feed, err := scan.LoadNVDFeed(source)
+
+	if err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// redactedArgs returns the command line with password values masked, so it can
+// be stored alongside results
+func redactedArgs() []string {
+
+	secrets := map[string]bool{"--socks-pass": true, "--es-pass": true}
+	args := append([]string(nil), os.Args...)
+
+	for i, arg := range args {
+		if name, _, found := strings.Cut(arg, "="); found && secrets[name] {
+			args[i] = name + "=***"
+		} else if secrets[arg] && i+1 < len(args) {
+			args[i+1] = "***"
+		}
+	}
+	return args
+}
+
 func Execute() {
+	rootCmd.SetArgs(normalizeArgs(os.Args[1:]))
+
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		var status exitStatus
+
+		if errors.As(err, &status) {
+			os.Exit(int(status))
+		}
+		if err != errChangesFound {
+			fmt.Println(err)
+			os.Exit(exitErrors)
+		}
 		os.Exit(1)
 	}
 }
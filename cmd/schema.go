@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"os"
+
+	"udpz/pkg/scan"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON schema of the results",
+	Long: `Print the JSON schema of the results written by the json and jsonl output
+formats, so consumers can validate them. The version of the schema is part of
+its $id; fields may be added within a version, but are only removed, renamed
+or retyped in a new one.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		_, err = os.Stdout.Write(scan.RESULTS_SCHEMA)
+		return
+	},
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"udpz/pkg/data"
+	"udpz/pkg/scan"
+	"udpz/pkg/server"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Serve options
+	serveListen  string = "127.0.0.1:8080"
+	serveToken   string
+	serveMaxJobs uint = 4
+
+	serveJobRetention    time.Duration = time.Hour
+	serveMaxFinishedJobs uint          = 100
+)
+
+func init() {
+
+	flags := serveCmd.Flags()
+	flags.SortFlags = false
+
+	// Server
+	flags.StringVar(&serveListen, "listen", serveListen, "Address to listen on as HOST:PORT")
+	flags.StringVar(&serveToken, "token", serveToken, "Require this bearer token on every request (default: $UDPZ_TOKEN)")
+	flags.UintVar(&serveMaxJobs, "max-jobs", serveMaxJobs, "Maximum number of scans to run at once, later jobs are queued")
+	flags.DurationVar(&serveJobRetention, "job-retention", serveJobRetention, "Remove finished jobs and their results this long after they finish (0 to keep them until deleted)")
+	flags.UintVar(&serveMaxFinishedJobs, "max-finished-jobs", serveMaxFinishedJobs, "Keep at most this many finished jobs, removing the oldest first (0 for no limit)")
+	flags.StringVar(&configPath, "config", configPath, "Load default options from this YAML file (default: ~/.config/udpz/config.yaml)")
+
+	// Job defaults. Jobs may lower the concurrency, retries, timeout and rate,
+	// but not raise them.
+	flags.StringSliceVar(&excludeTargets, "exclude", excludeTargets, "Exclude hosts, CIDRs, or ranges from every job")
+	flags.StringVar(&excludeListPath, "exclude-file", excludeListPath, "Read excluded targets from file, one per line")
+	flags.StringVar(&roePath, "roe", roePath, "Enforce the allowed networks, maximum rate, per-host probe cap and blackout windows of this rules of engagement YAML file on every job")
+	flags.StringArrayVar(&overrideSpecs, "override", overrideSpecs, "Scan the hosts of a target with another rate, retries, timeout or port-tasks in every job, as TARGET:KEY=VALUE,... (e.g. 10.1.0.0/24:rate=50,retries=1)")
+	flags.StringSliceVar(&probeFiles, "probe-file", probeFiles, "Load additional or overriding probes from a YAML file")
+	flags.StringSliceVar(&proberPlugins, "prober", proberPlugins, "Load services whose exchanges are driven in code from a Go plugin (.so) exporting Probers")
+	flags.StringVar(&snmpCommunitiesPath, "snmp-communities", snmpCommunitiesPath, "Also try each SNMP community string in this file, one per line, and report which ones agents accept")
+	flags.StringVar(&tftpFilesPath, "tftp-files", tftpFilesPath, "Request each file name in this file, one per line, from TFTP servers instead of running-config and startup-config, and report which ones they serve")
+	flags.StringVar(&radiusSecret, "radius-secret", radiusSecret, "Sign RADIUS probes with this shared secret guess instead of \"testing123\"; servers only answer requests signed with their secret")
+	flags.BoolVar(&upnpDescribe, "upnp-describe", upnpDescribe, "Fetch the device description of UPnP devices from the LOCATION of their SSDP responses, to report their model and services")
+	flags.StringVar(&vulnLookup, "vuln-lookup", vulnLookup, "Annotate results with the CVEs of their CPE names from an NVD CVE API 2.0 JSON file (gzipped if .gz), or from the NVD API at this http(s) URL (API key: $NVD_API_KEY)")
+	flags.UintVarP(&hostConcurrency, "host-tasks", "c", hostConcurrency, "Maximum Number of hosts each job scans concurrently")
+	flags.UintVarP(&portConcurrency, "port-tasks", "p", portConcurrency, "Maximum Number of Concurrent scan tasks per host")
+	flags.UintVarP(&retransmissions, "retries", "r", retransmissions, "Number of probe retransmissions per probe")
+	flags.StringVarP(&timeoutSpec, "timeout", "t", timeoutSpec, "UDP Probe timeout in milliseconds, or \"auto\" to adapt it to the round trip time of each host")
+	flags.Float64Var(&retryBackoff, "retry-backoff", retryBackoff, "Multiply the probe timeout by this factor on each retransmission (1 for a fixed interval)")
+	flags.UintVar(&jitterMs, "jitter", jitterMs, "Wait a random delay of up to this many milliseconds before each probe")
+	flags.UintVar(&rate, "rate", rate, "Maximum packets sent per second by each job (0 for unlimited)")
+	flags.UintVar(&maxRate, "max-rate", maxRate, "Adapt the send rate of each job to packet loss, ramping up to at most this many packets per second")
+	flags.UintVar(&minRate, "min-rate", minRate, "Send rate to start at and lowest rate to back off to when packets are lost (default: a tenth of --max-rate)")
+	flags.BoolVarP(&scanAllAddresses, "all", "A", scanAllAddresses, "Scan all resolved addresses instead of just the first")
+	flags.BoolVarP(&ipv4Only, "ipv4", "4", ipv4Only, "Only scan IPv4 addresses")
+	flags.BoolVarP(&ipv6Only, "ipv6", "6", ipv6Only, "Only scan IPv6 addresses")
+	flags.StringSliceVar(&resolvers, "resolvers", resolvers, "Resolve host names with these DNS servers, as IP or IP:PORT, instead of the system's (e.g. 1.1.1.1,10.0.0.53)")
+	flags.StringVar(&dohURL, "doh", dohURL, "Resolve host names with this DNS over HTTPS server instead of the system's resolver (e.g. https://cloudflare-dns.com/dns-query)")
+	flags.BoolVarP(&noResolve, "no-resolve", "n", noResolve, "Never resolve host names, only scan targets given as addresses")
+	flags.UintVar(&resolveConcurrency, "resolve-tasks", resolveConcurrency, "Maximum number of target host names each job resolves concurrently, ahead of the scan")
+	flags.StringVarP(&interfaceName, "interface", "e", interfaceName, "Send probes from this network interface (e.g. eth1)")
+	flags.StringVar(&sourceIP, "source-ip", sourceIP, "Send probes from this local IP address")
+	flags.StringVar(&sourcePort, "source-port", sourcePort, "Send probes from this local UDP port or range of ports (e.g. 53 or 40000-40100)")
+	flags.UintVar(&ttl, "ttl", ttl, "Set the IP TTL (IPv6 hop limit) of probes, 0 keeps the system default")
+	flags.UintVar(&sockets, "sockets", sockets, "Send probes from a pool of N shared sockets per address family instead of a socket per probe, to keep file descriptors low at high concurrency")
+	flags.BoolVar(&batch, "batch", batch, "Send and receive probes of shared sockets in batches with sendmmsg and recvmmsg, for fewer system calls on large scans (Linux only)")
+	flags.BoolVar(&openOnly, "open", openOnly, "Only report ports confirmed open in the results of every job, leaving out open|filtered ports")
+	flags.BoolVar(&allStates, "all-states", allStates, "Also report closed ports in the results of every job, which are left out by default")
+	flags.StringSliceVar(&discoveryMethods, "discovery", discoveryMethods, "Only scan hosts that answer ICMP echo requests or probes for these services first (e.g. icmp,dns,ntp)")
+	flags.BoolVar(&skipDiscovery, "skip-discovery", skipDiscovery, "Scan every host without discovering them first, overriding --discovery. Also accepted as -Pn")
+
+	serveCmd.MarkFlagsMutuallyExclusive("ipv4", "ipv6")
+	serveCmd.MarkFlagsMutuallyExclusive("resolvers", "doh", "no-resolve")
+	serveCmd.MarkFlagsMutuallyExclusive("open", "all-states")
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run a REST API to submit scans and stream their results",
+	Long: `Run an HTTP server exposing a job API, so orchestration platforms can drive
+udpz without parsing its output:
+
+  POST   /jobs              submit a scan, e.g. {"targets": ["10.0.0.0/24"], "ports": "53,161"}
+  GET    /jobs              list jobs
+  GET    /jobs/{id}         job status and progress
+  GET    /jobs/{id}/results results as a JSON array
+  GET    /jobs/{id}/stream  results as JSON lines while the scan runs
+  DELETE /jobs/{id}         cancel a running job, or remove a finished one`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		log := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).
+			With().
+			Timestamp().
+			Logger()
+
+		openGiven, allStatesGiven := cmd.Flags().Changed("open"), cmd.Flags().Changed("all-states")
+		usedConfigPath, err := loadConfig(cmd.Flags(), cmd.Root().Flags(), configPath)
+
+		if err != nil {
+			return err
+		}
+		if err = settleStates(openGiven, allStatesGiven); err != nil {
+			return err
+		}
+		readTimeout, adaptiveTimeout, err := parseTimeout(timeoutSpec)
+
+		if err != nil {
+			return err
+		}
+		if portConcurrency < 1 || hostConcurrency < 1 || resolveConcurrency < 1 {
+			return errors.New("concurrency value must be > 0")
+		}
+		if retryBackoff < 1 {
+			return errors.New("retry backoff factor must be >= 1")
+		}
+		options := scanOptions(log.Level(zerolog.WarnLevel), readTimeout, adaptiveTimeout)
+
+		if options.Overrides, err = targetOverrides(overrideSpecs); err != nil {
+			return err
+		}
+
+		if vulnLookup != "" {
+			if options.Vulnerabilities, err = vulnerabilitySource(vulnLookup); err != nil {
+				return err
+			}
+		}
+
+		if usedConfigPath != "" {
+			log.Info().
+				Str("config", usedConfigPath).
+				Msg("Loaded config file")
+		}
+		if excludeListPath != "" {
+			var excludeList []string
+
+			if excludeList, err = readInputList(excludeListPath); err != nil {
+				return err
+			}
+			excludeTargets = append(excludeTargets, excludeList...)
+		}
+		options.Exclude = excludeTargets
+
+		if roePath != "" {
+			if options.RulesOfEngagement, err = scan.LoadRulesOfEngagement(roePath); err != nil {
+				return err
+			}
+		}
+
+		if len(probeFiles) > 0 {
+			options.Services = data.UDP_SERVICES
+
+			for _, probeFile := range probeFiles {
+				var fileServices map[string]data.UdpService
+
+				if fileServices, err = data.LoadProbeFile(probeFile); err != nil {
+					return err
+				}
+				options.Services = data.MergeServices(options.Services, fileServices)
+			}
+		}
+		if len(proberPlugins) > 0 {
+			if options.Services == nil {
+				options.Services = data.UDP_SERVICES
+			}
+			for _, proberPlugin := range proberPlugins {
+				var probers []scan.Prober
+
+				if probers, err = scan.LoadProberPlugin(proberPlugin); err != nil {
+					return err
+				}
+				for _, prober := range probers {
+					if options.Services, err = scan.RegisterProber(options.Services, prober); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if snmpCommunitiesPath != "" {
+			var communities []string
+
+			if communities, err = data.LoadCommunities(snmpCommunitiesPath); err != nil {
+				return err
+			}
+			if options.Services == nil {
+				options.Services = data.UDP_SERVICES
+			}
+			options.Services = data.AddSNMPCommunities(options.Services, communities)
+		}
+		if radiusSecret != "" {
+			if options.Services == nil {
+				options.Services = data.UDP_SERVICES
+			}
+			options.Services = data.SetRADIUSSecret(options.Services, radiusSecret)
+		}
+		if tftpFilesPath != "" {
+			var files []string
+
+			if files, err = data.LoadTFTPFiles(tftpFilesPath); err != nil {
+				return err
+			}
+			if options.Services == nil {
+				options.Services = data.UDP_SERVICES
+			}
+			options.Services = data.SetTFTPFiles(options.Services, files)
+		}
+
+		// Check the options once, rather than failing every job
+		if _, err = scan.NewUdpProbeScanner(options); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		if serveToken == "" {
+			serveToken = os.Getenv("UDPZ_TOKEN")
+		}
+		if serveToken == "" {
+			log.Warn().
+				Msg("No token set, anyone who can reach the server can run scans")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		httpServer := &http.Server{
+			Addr:              serveListen,
+			Handler:           server.NewServer(ctx, log, options, serveToken, int(serveMaxJobs), serveJobRetention, int(serveMaxFinishedJobs)),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		go func() {
+			<-ctx.Done()
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			httpServer.Shutdown(shutdownCtx)
+		}()
+
+		log.Info().
+			Str("listen", serveListen).
+			Uint("max_jobs", serveMaxJobs).
+			Dur("job_retention", serveJobRetention).
+			Msg("Serving job API")
+
+		if err = httpServer.ListenAndServe(); errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		return
+	},
+}
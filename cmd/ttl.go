@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"udpz/pkg/scan"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// runTTLSweep sweeps the TTL of probes to every target and writes the outcome
+// of each hop, instead of running a normal scan
+func runTTLSweep(ctx context.Context, log zerolog.Logger, scanner *scan.UdpProbeScanner, targets []string, output io.Writer) error {
+
+	log.Info().
+		Uint("max_ttl", ttlSweep).
+		Msg("Starting TTL sweep")
+
+	startTime := time.Now()
+	hops, err := scanner.SweepTTL(ctx, targets, int(ttlSweep))
+	endTime := time.Now()
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	log.Info().
+		TimeDiff("duration", endTime, startTime).
+		Int("hop_count", len(hops)).
+		Msg("TTL sweep complete")
+
+	return writeHops(output, outputFormat, hops)
+}
+
+// writeHops writes the outcome of every probe of a TTL sweep in the given
+// format, as a table with one row per hop unless a structured format is chosen
+func writeHops(output io.Writer, format string, hops []scan.Hop) (err error) {
+
+	switch format {
+	case "json":
+		if hops == nil {
+			hops = []scan.Hop{}
+		}
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(hops)
+
+	case "jsonl":
+		encoder := json.NewEncoder(output)
+
+		for _, hop := range hops {
+			if err = encoder.Encode(hop); err != nil {
+				return
+			}
+		}
+		return
+
+	case "yaml", "yml":
+		return yaml.NewEncoder(output).Encode(hops)
+	}
+
+	hopsTable := table.NewWriter()
+	hopsTable.AppendHeader(table.Row{"Host", "Port", "Service", "TTL", "Router", "Result"})
+
+	for i, hop := range hops {
+		if i > 0 && (hop.Host.Host != hops[i-1].Host.Host || hop.Port != hops[i-1].Port) {
+			hopsTable.AppendSeparator()
+		}
+		hopsTable.AppendRow(table.Row{
+			hop.Host.Host,
+			fmt.Sprintf("%d/UDP", hop.Port),
+			hop.Service,
+			hop.TTL,
+			hop.Router,
+			hop.Result,
+		})
+	}
+	hopsTable.SetOutputMirror(output)
+
+	switch format {
+	case "csv":
+		hopsTable.RenderCSV()
+	case "tsv":
+		hopsTable.RenderTSV()
+	case "pretty":
+		hopsTable.SetStyle(table.StyleRounded)
+		hopsTable.Render()
+	default:
+		hopsTable.Render()
+	}
+	return
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"udpz/pkg/scan"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	// supportedWatchFormats are the output formats changes can be written in
+	supportedWatchFormats = map[string]bool{
+		"text": true, "txt": true,
+		"yaml": true, "yml": true,
+		"json": true, "jsonl": true,
+		"csv":    true,
+		"tsv":    true,
+		"pretty": true,
+		"auto":   true,
+	}
+
+	CHANGE_MESSAGES = map[string]string{
+		scan.CHANGE_OPENED:  "Port opened",
+		scan.CHANGE_CLOSED:  "Port closed",
+		scan.CHANGE_CHANGED: "Service changed",
+	}
+)
+
+// watcher reruns a scan on an interval and reports only the ports that changed
+// since the previous scan
+type watcher struct {
+	log       zerolog.Logger
+	scanner   *scan.UdpProbeScanner
+	targets   []string
+	interval  time.Duration
+	statePath string
+
+	bar    *progressBar
+	syslog *scan.SyslogSink
+	sinks  []scan.ChangeSink
+	output *os.File
+}
+
+// run scans until the context is canceled. The results of the previous scan
+// are kept in memory, and in the state file if one is given so that a
+// restarted watch continues where it left off.
+func (w *watcher) run(ctx context.Context) (err error) {
+
+	var previous []scan.Result
+
+	if w.statePath != "" {
+		if previous, err = scan.LoadResults(w.statePath); err == nil {
+			w.log.Info().
+				Str("state_path", w.statePath).
+				Int("result_count", len(previous)).
+				Msg("Loaded previous results")
+
+		} else if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		} else {
+			return err
+		}
+	}
+
+	for round := 1; ; round++ {
+
+		if w.syslog != nil {
+			w.syslog.Event("scan-start", "Scan started", map[string]string{
+				"round": strconv.Itoa(round),
+			})
+		}
+		if w.bar != nil {
+			w.bar.start(w.scanner)
+		}
+		startTime := time.Now()
+		results, _ := w.scanner.Scan(ctx, w.targets)
+
+		if w.bar != nil {
+			w.bar.stop()
+		}
+
+		// Results of an interrupted scan are incomplete and would be reported
+		// as closed ports
+		if ctx.Err() != nil {
+			return nil
+		}
+		changes := scan.DiffResults(previous, results)
+
+		w.log.Info().
+			Int("round", round).
+			Int("result_count", len(results)).
+			Int("change_count", len(changes)).
+			TimeDiff("duration", time.Now(), startTime).
+			Time("next", time.Now().Add(w.interval)).
+			Msg("Scan complete")
+
+		if w.syslog != nil {
+			w.syslog.Event("scan-end", "Scan complete", map[string]string{
+				"round":   strconv.Itoa(round),
+				"results": strconv.Itoa(len(results)),
+				"changes": strconv.Itoa(len(changes)),
+			})
+		}
+		w.report(changes)
+
+		if dbPath != "" {
+			if err := w.scanner.SaveSQLite(dbPath, redactedArgs()); err != nil {
+				w.log.Error().
+					Err(err).
+					Str("db_path", dbPath).
+					Msg("Failed to save results to database")
+			}
+		}
+		if w.statePath != "" {
+			if err := w.saveState(); err != nil {
+				w.log.Error().
+					Err(err).
+					Str("state_path", w.statePath).
+					Msg("Failed to save results")
+			}
+		}
+		previous = results
+
+		timer := time.NewTimer(w.interval)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// report logs each change and sends it to the output and the sinks
+func (w *watcher) report(changes []scan.PortChange) {
+
+	for _, change := range changes {
+		w.log.Info().
+			Str("change", change.Change).
+			Str("host", change.Host).
+			Uint16("port", change.Port).
+			Str("service", change.NewService).
+			Str("version", change.NewVersion).
+			Msg(CHANGE_MESSAGES[change.Change])
+
+		for _, sink := range w.sinks {
+			if err := sink.WriteChange(change); err != nil {
+				w.log.Error().
+					Err(err).
+					Str("host", change.Host).
+					Uint16("port", change.Port).
+					Msg("Failed to send change")
+			}
+		}
+	}
+	for _, sink := range w.sinks {
+		if flusher, ok := sink.(scan.FlushingSink); ok {
+			if err := flusher.Flush(); err != nil {
+				w.log.Error().
+					Err(err).
+					Msg("Failed to flush changes")
+			}
+		}
+	}
+	if len(changes) > 0 {
+		if err := writeChanges(w.output, outputFormat, changes); err != nil {
+			w.log.Error().
+				Err(err).
+				Msg("Failed to write changes")
+		}
+	}
+}
+
+// saveState writes the latest results to the state file, replacing it only
+// once the new results are completely written
+func (w *watcher) saveState() (err error) {
+
+	var stateFile *os.File
+
+	if stateFile, err = os.Create(w.statePath + ".tmp"); err != nil {
+		return
+	}
+	if err = w.scanner.SaveJson(stateFile); err != nil {
+		stateFile.Close()
+		return
+	}
+	if err = stateFile.Close(); err != nil {
+		return
+	}
+	return os.Rename(w.statePath+".tmp", w.statePath)
+}
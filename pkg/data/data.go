@@ -47,7 +47,19 @@ var (
 					Slug:        "bacnet-readpropertymultiple",
 					Name:        "BACNet ReadPropertyMultiple request",
 					Service:     "bacnet",
-					EncodedData: "gQoAJQEEAgUBDgwCAAAAHgkMCRwJLAk4CTkJOglGCU0JeAl5Hw==",
+					EncodedData: "gQoAJQEEAgUBDgwCP///HgkMCRwJLAk4CTkJOglGCU0JeAl5Hw==",
+				},
+				{
+					Slug:        "bacnet-readproperty",
+					Name:        "BACNet ReadProperty request (vendor name)",
+					Service:     "bacnet",
+					EncodedData: "gQoAEQEEAAUCDAwCP///GXk=",
+				},
+				{
+					Slug:        "bacnet-who-is",
+					Name:        "BACNet Who-Is request",
+					Service:     "bacnet",
+					EncodedData: "gQoACAEAEAg=",
 				},
 			},
 			Tags: []string{
@@ -59,6 +71,37 @@ var (
 				"https://wikipedia.org/wiki/BACnet",
 			},
 		},
+		"knxnet-ip": {
+			Slug:        "knxnet-ip",
+			NameShort:   "KNXnet/IP",
+			Name:        "KNXnet/IP",
+			Description: `KNXnet/IP connects IP networks to the KNX bus of building automation systems, which controls lighting, blinds, heating and access control. Routers and interfaces answer search and description requests with their name, individual address, serial number and MAC address, and those in programming mode accept a new address from anyone.`,
+			Ports: []uint16{
+				3671,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "knxnet-ip-description",
+					Name:        "KNXnet/IP description request",
+					Service:     "knxnet-ip",
+					EncodedData: "BhACAwAOCAEAAAAAAAA=",
+				},
+				{
+					Slug:        "knxnet-ip-search",
+					Name:        "KNXnet/IP search request",
+					Service:     "knxnet-ip",
+					EncodedData: "BhACAQAOCAEAAAAAAAA=",
+				},
+			},
+			Tags: []string{
+				"ics",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=3671",
+				"https://wikipedia.org/wiki/KNX",
+				"https://nmap.org/nsedoc/scripts/knx-gateway-discover.html",
+			},
+		},
 		"chargen": {
 			Slug:        "chargen",
 			NameShort:   "CharGen",
@@ -81,6 +124,81 @@ var (
 				"https://datatracker.ietf.org/doc/html/rfc864",
 			},
 		},
+		"echo": {
+			Slug:        "echo",
+			NameShort:   "Echo",
+			Name:        "Echo Protocol",
+			Description: `The Echo service sends back any datagram it receives. Like the other simple services of old Unix hosts and Windows Simple TCP/IP Services, it should be disabled, since it can be made to reflect traffic at a spoofed address or loop with another simple service.`,
+			Ports: []uint16{
+				7,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "echo-generic",
+					Name:        "Echo generic",
+					Service:     "echo",
+					EncodedData: "dXBkeg==",
+				},
+			},
+			Tags: []string{
+				"unix",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=7",
+				"https://wikipedia.org/wiki/Echo_Protocol",
+				"https://datatracker.ietf.org/doc/html/rfc862",
+			},
+		},
+		"daytime": {
+			Slug:        "daytime",
+			NameShort:   "Daytime",
+			Name:        "Daytime Protocol",
+			Description: `The Daytime service answers any datagram with the current date and time as text, in a format of its own choosing. It should be disabled, since it can be made to reflect traffic at a spoofed address.`,
+			Ports: []uint16{
+				13,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "daytime-generic",
+					Name:        "Daytime generic",
+					Service:     "daytime",
+					EncodedData: "AQ==",
+				},
+			},
+			Tags: []string{
+				"unix",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=13",
+				"https://wikipedia.org/wiki/Daytime_Protocol",
+				"https://datatracker.ietf.org/doc/html/rfc867",
+			},
+		},
+		"time": {
+			Slug:        "time",
+			NameShort:   "Time",
+			Name:        "Time Protocol",
+			Description: `The Time service answers any datagram with the current time as the number of seconds since 1900. It was superseded by NTP, and should be disabled, since it can be made to reflect traffic at a spoofed address.`,
+			Ports: []uint16{
+				37,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "time-generic",
+					Name:        "Time generic",
+					Service:     "time",
+					EncodedData: "AQ==",
+				},
+			},
+			Tags: []string{
+				"unix",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=37",
+				"https://wikipedia.org/wiki/Time_Protocol",
+				"https://datatracker.ietf.org/doc/html/rfc868",
+			},
+		},
 		"winframe": {
 			Slug:        "winframe",
 			NameShort:   "WinFrame",
@@ -208,6 +326,18 @@ var (
 					Service:     "dns",
 					EncodedData: "nkABIAABAAAAAAABB3ZlcnNpb24EYmluZAAAEAADAAApBNAAAAAAAAwACgAI0How5NhZLqA=",
 				},
+				{
+					Slug:        "dns-hostname",
+					Name:        "DNS hostname.bind query with NSID",
+					Service:     "dns",
+					EncodedData: "Ol4AAAABAAAAAAABCGhvc3RuYW1lBGJpbmQAABAAAwAAKQTQAAAAAAAEAAMAAA==",
+				},
+				{
+					Slug:        "dns-recursion",
+					Name:        "DNS recursive A query (example.com) with NSID",
+					Service:     "dns",
+					EncodedData: "fDsBAAABAAAAAAABB2V4YW1wbGUDY29tAAABAAEAACkE0AAAAAAABAADAAA=",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -234,6 +364,18 @@ var (
 					Service:     "ipmi",
 					EncodedData: "BgD/BwAAAAAAAAAAAAkgGMiBADiOBLU=",
 				},
+				{
+					Slug:        "ipmi-rmcp-ping",
+					Name:        "ASF RMCP presence ping",
+					Service:     "ipmi",
+					EncodedData: "BgD/BgAAEb6AdQAA",
+				},
+				{
+					Slug:        "ipmi-cipher-zero",
+					Name:        "IPMI RMCP+ open session (cipher suite 0)",
+					Service:     "ipmi",
+					EncodedData: "BgD/BwYQAAAAAAAAAAAgAAAAAAB1ZHB6AAAACAAAAAABAAAIAAAAAAIAAAgAAAAA",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -259,6 +401,12 @@ var (
 					Service:     "cldap",
 					EncodedData: "MIQAAAAtAgEBY4QAAAAkBAAKAQAKAQACAQACAQABAQCHC29iamVjdGNsYXNzMIQAAAAAAAo=",
 				},
+				{
+					Slug:        "cldap-netlogon",
+					Name:        "CLDAP Netlogon query",
+					Service:     "cldap",
+					EncodedData: "MDMCAQJjLgQACgEACgEAAgEAAgEAAQEAoA+jDQQFTnRWZXIEBAYAAAAwCgQITmV0bG9nb24=",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -286,6 +434,12 @@ var (
 					Service:     "mdns",
 					EncodedData: "G2wBIAABAAAAAAABATEBMAEwAzEyNwdpbi1hZGRyBGFycGEAAAwAAQAAKQTQAAAAAAAMAAoACH8B+nAODI6w",
 				},
+				{
+					Slug:        "mdns-services",
+					Name:        "mDNS service enumeration",
+					Service:     "mdns",
+					EncodedData: "AAAAAAABAAAAAAAACV9zZXJ2aWNlcwdfZG5zLXNkBF91ZHAFbG9jYWwAAAwAAQ==",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -316,6 +470,12 @@ var (
 					Service:     "memcache",
 					EncodedData: "Wk0AAAABAABzdGF0cyBpdGVtcw0K",
 				},
+				{
+					Slug:        "memcache-stats-general",
+					Name:        "Memcache General Stats",
+					Service:     "memcache",
+					EncodedData: "dXoAAAABAABzdGF0cw0K",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -381,14 +541,14 @@ var (
 			Slug:        "mssql",
 			NameShort:   "MSSQL",
 			Name:        "Microsoft Structured Query Language (SQL) Server",
-			Description: `MSSQL is a proprietary relational database management system developed by Microsoft. As a database server, it is a software product with the primary function of storing and retrieving data`,
+			Description: `MSSQL is a proprietary relational database management system developed by Microsoft. As a database server, it is a software product with the primary function of storing and retrieving data. The SQL Server Browser service answers the SQL Server Resolution Protocol (SSRP) on UDP port 1434 with the instances of the server, their versions and the ports they listen on.`,
 			Ports: []uint16{
 				1434,
 			},
 			Probes: []UdpProbe{
 				{
 					Slug:        "mssql-ping",
-					Name:        "MSSQL ping",
+					Name:        "SQL Server Browser (SSRP) CLNT_BCAST_EX",
 					Service:     "mssql",
 					EncodedData: "Ag==",
 				},
@@ -402,13 +562,14 @@ var (
 			References: []string{
 				"https://www.speedguide.net/port.php?port=1434",
 				"https://wikipedia.org/wiki/Microsoft_SQL_Server",
+				"https://learn.microsoft.com/en-us/openspecs/windows_protocols/mc-sqlr/1ea6e25f-bff9-4364-ba21-5dc449a601b7",
 			},
 		},
 		"nat-pmp": {
 			Slug:        "nat-pmp",
 			NameShort:   "NAT-PMP",
 			Name:        "Network Address Translation Port Mapping Protocol (NAT-PMP)",
-			Description: `NAT Port Mapping Protocol (NAT-PMP) is a network protocol for automatically establishing network address translation (NAT) settings and port forwarding configurations`,
+			Description: `NAT Port Mapping Protocol (NAT-PMP) is a network protocol for automatically establishing network address translation (NAT) settings and port forwarding configurations. Its successor, the Port Control Protocol (PCP), shares the port. Home and SOHO routers that answer on their internal network give away their external address, and let any host open ports to itself from the Internet`,
 			Ports: []uint16{
 				5351,
 			},
@@ -419,6 +580,18 @@ var (
 					Service:     "nat-pmp",
 					EncodedData: "AAA=",
 				},
+				{
+					Slug:        "pcp-announce",
+					Name:        "PCP ANNOUNCE",
+					Service:     "nat-pmp",
+					EncodedData: "AgAAAAAAAAAAAAAAAAAAAAAA//8AAAAA",
+				},
+				{
+					Slug:        "pcp-map",
+					Name:        "PCP MAP (lifetime 0)",
+					Service:     "nat-pmp",
+					EncodedData: "AgEAAAAAAAAAAAAAAAAAAAAA//8AAAAAdWRweiBwY3AgbWFwEQAAAAAJAAAAAAAAAAAAAAAA//8AAAAA",
+				},
 			},
 			Tags: []string{
 				"internet",
@@ -427,6 +600,8 @@ var (
 				"https://www.speedguide.net/port.php?port=5351",
 				"https://wikipedia.org/wiki/NAT_Port_Mapping_Protocol",
 				"https://nmap.org/nsedoc/scripts/nat-pmp-info.html",
+				"https://www.rfc-editor.org/rfc/rfc6886",
+				"https://www.rfc-editor.org/rfc/rfc6887",
 			},
 		},
 		"netbios": {
@@ -476,6 +651,18 @@ var (
 					Service:     "ntp",
 					EncodedData: "FwADKgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
 				},
+				{
+					Slug:        "ntp-readvar",
+					Name:        "NTP control read variables",
+					Service:     "ntp",
+					EncodedData: "FgIAAQAAAAAAAAAA",
+				},
+				{
+					Slug:        "ntp-monlist",
+					Name:        "NTP private monlist",
+					Service:     "ntp",
+					EncodedData: "FwADKgAAAAA=",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -535,6 +722,103 @@ var (
 				"https://wikipedia.org/wiki/OpenVPN",
 			},
 		},
+		"wireguard": {
+			Slug:        "wireguard",
+			NameShort:   "WireGuard",
+			Name:        "WireGuard VPN",
+			Description: `WireGuard is a virtual private network (VPN) protocol built on modern cryptography. Endpoints silently drop any handshake that is not addressed to their public key, so they never answer scans and are only told apart from closed ports by that silence.`,
+			Ports: []uint16{
+				51820,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "wireguard-handshake-initiation",
+					Name:        "WireGuard handshake initiation",
+					Service:     "wireguard",
+					EncodedData: "AQAAAHVkcHr1uyypfUgPF5SFMu1eh8K7575a0Vh3+8Ciar84nqzCTb00di9/YFK38UY8Kp+ANbfeixVuZw79BCjLdxFuopHB37sF7sjblKEpD31uYAUFT0TQuZxvhbhi5+awGay7u23jtP7OAlKqaxtriz/XHRhlXmzteifmsEzBMCcbAAAAAAAAAAAAAAAAAAAAAA==",
+					Matches: []UdpMatch{
+						{Pattern: `^[\x02\x03]\x00\x00\x00`},
+					},
+				},
+			},
+			Tags: []string{
+				"internet",
+			},
+			References: []string{
+				"https://www.wireguard.com/protocol/",
+				"https://wikipedia.org/wiki/WireGuard",
+			},
+		},
+		"vxlan": {
+			Slug:        "vxlan",
+			NameShort:   "VXLAN",
+			Name:        "Virtual Extensible LAN (VXLAN)",
+			Description: `VXLAN carries Ethernet frames between the tunnel endpoints of an overlay network, such as those of hypervisors, container hosts and data center switches. Endpoints decapsulate any frame they receive without authenticating it, so one reachable from outside the underlay lets anyone inject frames into the overlay. They do not answer the frames they decapsulate over the same port, so they are mostly told apart from closed ports by their silence.`,
+			Ports: []uint16{
+				4789,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "vxlan-arp",
+					Name:        "VXLAN encapsulated ARP request",
+					Service:     "vxlan",
+					EncodedData: "CAAAAAAAAQD///////8CdXBkegAIBgABCAAGBAABAnVwZHoAAAAAAAAAAAAAAAoAAAE=",
+				},
+			},
+			Tags: []string{
+				"internet",
+			},
+			References: []string{
+				"https://www.rfc-editor.org/rfc/rfc7348",
+				"https://wikipedia.org/wiki/Virtual_Extensible_LAN",
+			},
+		},
+		"geneve": {
+			Slug:        "geneve",
+			NameShort:   "Geneve",
+			Name:        "Generic Network Virtualization Encapsulation (Geneve)",
+			Description: `Geneve carries Ethernet frames between the tunnel endpoints of an overlay network, such as those of Open vSwitch, OVN and cloud load balancers. Like VXLAN, endpoints decapsulate frames without authenticating them, so one reachable from outside the underlay lets anyone inject frames into the overlay.`,
+			Ports: []uint16{
+				6081,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "geneve-arp",
+					Name:        "Geneve encapsulated ARP request",
+					Service:     "geneve",
+					EncodedData: "AABlWAAAAQD///////8CdXBkegAIBgABCAAGBAABAnVwZHoAAAAAAAAAAAAAAAoAAAE=",
+				},
+			},
+			Tags: []string{
+				"internet",
+			},
+			References: []string{
+				"https://www.rfc-editor.org/rfc/rfc8926",
+			},
+		},
+		"gre-udp": {
+			Slug:        "gre-udp",
+			NameShort:   "GRE-in-UDP",
+			Name:        "Generic Routing Encapsulation (GRE) in UDP",
+			Description: `GRE-in-UDP carries GRE packets, and the Ethernet frames or IP packets they encapsulate, over UDP so that they can be balanced across paths like other UDP flows. Endpoints decapsulate packets without authenticating them, so one reachable from outside the underlay lets anyone inject packets into the tunnel.`,
+			Ports: []uint16{
+				4754,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "gre-udp-arp",
+					Name:        "GRE-in-UDP encapsulated ARP request",
+					Service:     "gre-udp",
+					EncodedData: "AABlWP///////wJ1cGR6AAgGAAEIAAYEAAECdXBkegAAAAAAAAAAAAAACgAAAQ==",
+				},
+			},
+			Tags: []string{
+				"internet",
+			},
+			References: []string{
+				"https://www.rfc-editor.org/rfc/rfc8086",
+			},
+		},
 		"pca": {
 			Slug:        "pca",
 			NameShort:   "PCAnywhere",
@@ -695,6 +979,15 @@ var (
 				2543,
 			},
 			Probes: []UdpProbe{
+				{
+					Slug:        "sip-options",
+					Name:        "SIP OPTIONS request",
+					Service:     "sip",
+					EncodedData: "T1BUSU9OUyBzaXA6bm0gU0lQLzIuMA0KVmlhOiBTSVAvMi4wL1VEUCB1ZHB6LmludmFsaWQ7YnJhbmNoPXo5aEc0YkstdWRwei1vcHRpb25zO3Jwb3J0DQpNYXgtRm9yd2FyZHM6IDcwDQpGcm9tOiA8c2lwOnVkcHpAdWRwei5pbnZhbGlkPjt0YWc9dWRweg0KVG86IDxzaXA6bm1Abm0+DQpDYWxsLUlEOiB1ZHB6LW9wdGlvbnMtMDAwMUB1ZHB6LmludmFsaWQNCkNTZXE6IDQyIE9QVElPTlMNCkNvbnRhY3Q6IDxzaXA6dWRwekB1ZHB6LmludmFsaWQ+DQpBY2NlcHQ6IGFwcGxpY2F0aW9uL3NkcA0KQ29udGVudC1MZW5ndGg6IDANCg0K",
+					Matches: []UdpMatch{
+						{Pattern: `^SIP/2\.0 `},
+					},
+				},
 				{
 					Slug:        "sip-invite",
 					Name:        "SIP INVITE request",
@@ -717,7 +1010,6 @@ var (
 			Description: `Simple Network Management Protocol (SNMP) is an Internet Standard protocol for collecting and organizing information about managed network devices, and modifying that information to change device behavior. Devices that typically support SNMP include cable modems, routers, switches, servers, workstations, printers, and more.`,
 			Ports: []uint16{
 				161,
-				162,
 				6161,
 				8161,
 				10161,
@@ -739,7 +1031,7 @@ var (
 				},
 				{
 					Slug:        "snmp-v3",
-					Name:        "SNMPv3 get-request",
+					Name:        "SNMPv3 engine discovery",
 					Service:     "snmp",
 					EncodedData: "MDoCAQMwDwICSmkCAwD/4wQBBAIBAwQQMA4EAAIBAAIBAAQABAAEADASBAAEAKAMAgI38AIBAAIBADAA",
 				},
@@ -753,6 +1045,51 @@ var (
 				"https://wikipedia.org/wiki/Simple_Network_Management_Protocol",
 			},
 		},
+		"snmptrap": {
+			Slug:        "snmptrap",
+			NameShort:   "SNMP Trap",
+			Name:        "Simple Network Management Protocol (SNMP) notification receiver",
+			Description: `SNMP notification receivers, such as snmptrapd and network management systems, collect the traps and informs devices send them. They never answer traps, and only acknowledge informs whose community they accept, so receivers are mostly told apart from closed ports by their silence.`,
+			Ports: []uint16{
+				162,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "snmptrap-inform",
+					Name:        "SNMPv2c inform-request",
+					Service:     "snmptrap",
+					EncodedData: "MEUCAQEEBnB1YmxpY6Y4AgR1ZHp0AgEAAgEAMCowDQYIKwYBAgEBAwBDAQAwGQYKKwYBBgMBAQQBAAYLKwYBBAG/CAIDAAE=",
+				},
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=162",
+				"https://www.rfc-editor.org/rfc/rfc3416",
+			},
+		},
+		"syslog": {
+			Slug:        "syslog",
+			NameShort:   "Syslog",
+			Name:        "Syslog",
+			Description: `Syslog servers collect the log messages of hosts and network devices. They never answer, so they are mostly told apart from closed ports by their silence, and the message the probe leaves in the log is the only trace the scan reached them.`,
+			Ports: []uint16{
+				514,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "syslog-message",
+					Name:        "Syslog user.info message",
+					Service:     "syslog",
+					EncodedData: "PDE0PnVkcHo6IHVkcHogc3lzbG9nIGxpc3RlbmVyIGNoZWNr",
+				},
+			},
+			Tags: []string{
+				"unix",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=514",
+				"https://www.rfc-editor.org/rfc/rfc5426",
+			},
+		},
 		"stun": {
 			Slug:        "stun",
 			NameShort:   "STUN",
@@ -761,6 +1098,7 @@ var (
 			Ports: []uint16{
 				3478,
 				3470,
+				5349,
 				19302,
 			},
 			Probes: []UdpProbe{
@@ -768,7 +1106,13 @@ var (
 					Slug:        "stun-bind",
 					Name:        "STUN binding request",
 					Service:     "stun",
-					EncodedData: "AAEAACESpEIAAAAAAAAAAAAAAAA=",
+					EncodedData: "AAEAACESpEJ1ZHB6AAAAAAAAAAY=",
+				},
+				{
+					Slug:        "turn-allocate",
+					Name:        "TURN allocate request",
+					Service:     "stun",
+					EncodedData: "AAMACCESpEJ1ZHB6AAAAAAAAAAcAGQAEEQAAAA==",
 				},
 			},
 			Tags: []string{
@@ -779,6 +1123,66 @@ var (
 				"https://www.speedguide.net/port.php?port=19302",
 				"https://www.shadowserver.org/what-we-do/network-reporting/accessible-stun-service-report/",
 				"https://www.rfc-editor.org/rfc/rfc5389",
+				"https://www.rfc-editor.org/rfc/rfc8656",
+			},
+		},
+		"dhcp": {
+			Slug:        "dhcp",
+			NameShort:   "DHCP",
+			Name:        "Dynamic Host Configuration Protocol (DHCP)",
+			Description: `Dynamic Host Configuration Protocol (DHCP) assigns addresses and network settings, such as routers, DNS servers and domains, to hosts joining a network. Servers answer on the client port 68, so replies are only received when probes are sent from it, and servers other than the expected ones are rogue.`,
+			Ports: []uint16{
+				67,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "dhcp-inform",
+					Name:        "DHCP INFORM",
+					Service:     "dhcp",
+					EncodedData: "AQEGAHVkcHkAAAAAAAAAAAAAAAAAAAAAAAAAAAJ1ZHB6AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABjglNjNQEINwkBAwYPKkJDd/z/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+				},
+				{
+					Slug:        "dhcp-discover",
+					Name:        "DHCP DISCOVER",
+					Service:     "dhcp",
+					EncodedData: "AQEGAHVkcHoAAIAAAAAAAAAAAAAAAAAAAAAAAAJ1ZHB6AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABjglNjNQEBNwkBAwYPKkJDd/z/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+				},
+			},
+			Tags: []string{
+				"common",
+			},
+			References: []string{
+				"https://www.rfc-editor.org/rfc/rfc2131",
+				"https://www.rfc-editor.org/rfc/rfc2132",
+			},
+		},
+		"dhcpv6": {
+			Slug:        "dhcpv6",
+			NameShort:   "DHCPv6",
+			Name:        "Dynamic Host Configuration Protocol for IPv6 (DHCPv6)",
+			Description: `Dynamic Host Configuration Protocol for IPv6 (DHCPv6) assigns addresses, DNS servers and domains to IPv6 hosts. Servers answer unicast requests to the port they came from, either with the configuration asked for or with a status code telling clients to use multicast.`,
+			Ports: []uint16{
+				547,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "dhcpv6-solicit",
+					Name:        "DHCPv6 SOLICIT",
+					Service:     "dhcpv6",
+					EncodedData: "AXVkcAABAAoAAwABAnVkcHoAAAgAAgAAAAYACAAXABgAHwA4AAMADHVkcHoAAAAAAAAAAA==",
+				},
+				{
+					Slug:        "dhcpv6-information-request",
+					Name:        "DHCPv6 INFORMATION-REQUEST",
+					Service:     "dhcpv6",
+					EncodedData: "C3VkcQABAAoAAwABAnVkcHoAAAgAAgAAAAYACAAXABgAHwA4",
+				},
+			},
+			Tags: []string{
+				"common",
+			},
+			References: []string{
+				"https://www.rfc-editor.org/rfc/rfc8415",
 			},
 		},
 		"tftp": {
@@ -798,6 +1202,18 @@ var (
 					Service:     "tftp",
 					EncodedData: "AAEvYQBuZXRhc2NpaQA=",
 				},
+				{
+					Slug:        "tftp-file-1",
+					Name:        "TFTP read request (\"running-config\")",
+					Service:     "tftp",
+					EncodedData: "AAFydW5uaW5nLWNvbmZpZwBvY3RldAA=",
+				},
+				{
+					Slug:        "tftp-file-2",
+					Name:        "TFTP read request (\"startup-config\")",
+					Service:     "tftp",
+					EncodedData: "AAFzdGFydHVwLWNvbmZpZwBvY3RldAA=",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -805,6 +1221,7 @@ var (
 			References: []string{
 				"https://www.speedguide.net/port.php?port=69",
 				"wikipedia.org/wiki/Trivial_File_Transfer_Protocol",
+				"https://www.rfc-editor.org/rfc/rfc1350",
 			},
 		},
 		"ubiquiti": {
@@ -851,6 +1268,12 @@ var (
 					Service:     "upnp",
 					EncodedData: "TS1TRUFSQ0ggKiBIVFRQLzEuMQ0KSE9TVDoyMzkuMjU1LjI1NS4yNTA6MTkwMA0KU1Q6c3NkcDphbGwNCk1BTjoic3NkcDpkaXNjb3ZlciINCg0K",
 				},
+				{
+					Slug:        "upnp-rootdevice",
+					Name:        "SSDP M-SEARCH for root devices",
+					Service:     "upnp",
+					EncodedData: "TS1TRUFSQ0ggKiBIVFRQLzEuMQ0KSE9TVDogMjM5LjI1NS4yNTUuMjUwOjE5MDANCk1BTjogInNzZHA6ZGlzY292ZXIiDQpNWDogMQ0KU1Q6IHVwbnA6cm9vdGRldmljZQ0KDQo=",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -899,6 +1322,12 @@ var (
 					Service:     "wsd",
 					EncodedData: "PD94bWwgdmVyc2lvbj0iMS4wIiBlbmNvZGluZz0idXRmLTgiPz4KPHNvYXA6RW52ZWxvcGUgeG1sbnM6c29hcD0iaHR0cDovL3d3dy53My5vcmcvMjAwMy8wNS9zb2FwLWVudmVsb3BlIiB4bWxuczp3c2E9Imh0dHA6Ly9zY2hlbWFzLnhtbHNvYXAub3JnL3dzLzIwMDQvMDgvYWRkcmVzc2luZyIgeG1sbnM6d3NkPSJodHRwOi8vc2NoZW1hcy54bWxzb2FwLm9yZy93cy8yMDA1LzA0L2Rpc2NvdmVyeSIgeG1sbnM6d3NkcD0iaHR0cDovL3NjaGVtYXMueG1sc29hcC5vcmcvd3MvMjAwNi8wMi9kZXZwcm9mIj4KPHNvYXA6SGVhZGVyPjx3c2E6VG8+dXJuOnNjaGVtYXMteG1sc29hcC1vcmc6d3M6MjAwNTowNDpkaXNjb3Zlcnk8L3dzYTpUbz48d3NhOkFjdGlvbj5odHRwOi8vc2NoZW1hcy54bWxzb2FwLm9yZy93cy8yMDA1LzA0L2Rpc2NvdmVyeS9Qcm9iZTwvd3NhOkFjdGlvbj48d3NhOk1lc3NhZ2VJRD51cm46dXVpZDpjZTA0ZGFkMC01ZDJjLTQwMjYtOTE0Ni0xYWFiZmMxZTQxMTE8L3dzYTpNZXNzYWdlSUQ+PC9zb2FwOkhlYWRlcj48c29hcDpCb2R5Pjx3c2Q6UHJvYmU+PHdzZDpUeXBlcz53c2RwOkRldmljZTwvd3NkOlR5cGVzPjwvd3NkOlByb2JlPjwvc29hcDpCb2R5Pjwvc29hcDpFbnZlbG9wZT4K",
 				},
+				{
+					Slug:        "wsd-probe-any",
+					Name:        "WSD discovery of any type",
+					Service:     "wsd",
+					EncodedData: "PD94bWwgdmVyc2lvbj0iMS4wIiBlbmNvZGluZz0idXRmLTgiPz4KPHNvYXA6RW52ZWxvcGUgeG1sbnM6c29hcD0iaHR0cDovL3d3dy53My5vcmcvMjAwMy8wNS9zb2FwLWVudmVsb3BlIiB4bWxuczp3c2E9Imh0dHA6Ly9zY2hlbWFzLnhtbHNvYXAub3JnL3dzLzIwMDQvMDgvYWRkcmVzc2luZyIgeG1sbnM6d3NkPSJodHRwOi8vc2NoZW1hcy54bWxzb2FwLm9yZy93cy8yMDA1LzA0L2Rpc2NvdmVyeSIgeG1sbnM6d3NkcD0iaHR0cDovL3NjaGVtYXMueG1sc29hcC5vcmcvd3MvMjAwNi8wMi9kZXZwcm9mIj4KPHNvYXA6SGVhZGVyPjx3c2E6VG8+dXJuOnNjaGVtYXMteG1sc29hcC1vcmc6d3M6MjAwNTowNDpkaXNjb3Zlcnk8L3dzYTpUbz48d3NhOkFjdGlvbj5odHRwOi8vc2NoZW1hcy54bWxzb2FwLm9yZy93cy8yMDA1LzA0L2Rpc2NvdmVyeS9Qcm9iZTwvd3NhOkFjdGlvbj48d3NhOk1lc3NhZ2VJRD51cm46dXVpZDpjZTA0ZGFkMC01ZDJjLTQwMjYtOTE0Ni0xYWFiZmMxZTQxMTI8L3dzYTpNZXNzYWdlSUQ+PC9zb2FwOkhlYWRlcj48c29hcDpCb2R5Pjx3c2Q6UHJvYmUvPjwvc29hcDpCb2R5Pjwvc29hcDpFbnZlbG9wZT4K",
+				},
 				{
 					Slug:        "wsd-blank",
 					Name:        "WSD blank SOAP",
@@ -913,6 +1342,33 @@ var (
 			References: []string{
 				"https://www.speedguide.net/port.php?port=3702",
 				"https://wikipedia.org/wiki/Web_Services_Discovery",
+				"https://docs.oasis-open.org/ws-dd/discovery/1.1/os/wsdd-discovery-1.1-spec-os.html",
+			},
+		},
+		"llmnr": {
+			Slug:        "llmnr",
+			NameShort:   "LLMNR",
+			Name:        "Link-Local Multicast Name Resolution (LLMNR)",
+			Description: `Link-Local Multicast Name Resolution (LLMNR) lets Windows hosts, and Linux hosts running systemd-resolved, resolve the names of their neighbors without a DNS server. Any host on the network can answer for any name, so poisoners such as Responder answer every query to capture the credentials of the hosts that trust them. Responders only answer queries for their own names, so hosts with LLMNR enabled are mostly told apart from closed ports by their silence.`,
+			Ports: []uint16{
+				5355,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "llmnr-spoof-check",
+					Name:        "LLMNR query for a made up name",
+					Service:     "llmnr",
+					EncodedData: "dWQAAAABAAAAAAAAEHVkcHotbGxtbnItY2hlY2sAAAEAAQ==",
+				},
+			},
+			Tags: []string{
+				"windows",
+				"active-directory",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=5355",
+				"https://www.rfc-editor.org/rfc/rfc4795",
+				"https://wikipedia.org/wiki/Link-Local_Multicast_Name_Resolution",
 			},
 		},
 		"xdmcp": {
@@ -997,7 +1453,6 @@ var (
 			Description: `Internet Key Exchange (IKE) is a protocol used in IPsec to establish secure, authenticated communication channels between devices over the internet. It manages the exchange of cryptographic keys and negotiates security associations, ensuring secure data transmission in VPNs and other encrypted network communications.`,
 			Ports: []uint16{
 				500,
-				4500, // Alt
 			},
 			Probes: []UdpProbe{
 				{
@@ -1006,6 +1461,60 @@ var (
 					Service:     "ike",
 					EncodedData: "W15kwD6ZtREAAAAAAAAAAAEQAgAAAAAAAAABUAAAATQAAAABAAAAAQAAASgBAQAIAwAAJAEB",
 				},
+				{
+					Slug:        "ike-main-mode",
+					Name:        "IKEv1 main mode",
+					Service:     "ike",
+					EncodedData: "dWRwegAAAAEAAAAAAAAAAAEQAgAAAAAAAAACkAAAAnQAAAABAAAAAQAAAmgBAQAQAwAAKAEBAACAAQAHgA4BAIACAAKAAwABgAQAAoALAAEADAAEAABwgAMAACgCAQAAgAEAB4AOAQCAAgABgAMAAYAEAAKACwABAAwABAAAcIADAAAoAwEAAIABAAeADgCAgAIAAoADAAGABAACgAsAAQAMAAQAAHCAAwAAKAQBAACAAQAHgA4AgIACAAGAAwABgAQAAoALAAEADAAEAABwgAMAACQFAQAAgAEABYACAAKAAwABgAQAAoALAAEADAAEAABwgAMAACQGAQAAgAEABYACAAGAAwABgAQAAoALAAEADAAEAABwgAMAACQHAQAAgAEAAYACAAKAAwABgAQAAoALAAEADAAEAABwgAMAACQIAQAAgAEAAYACAAGAAwABgAQAAoALAAEADAAEAABwgAMAACgJAQAAgAEAB4AOAQCAAgACgAMAAYAEAA6ACwABAAwABAAAcIADAAAoCgEAAIABAAeADgEAgAIAAYADAAGABAAOgAsAAQAMAAQAAHCAAwAAKAsBAACAAQAHgA4AgIACAAKAAwABgAQADoALAAEADAAEAABwgAMAACgMAQAAgAEAB4AOAICAAgABgAMAAYAEAA6ACwABAAwABAAAcIADAAAkDQEAAIABAAWAAgACgAMAAYAEAA6ACwABAAwABAAAcIADAAAkDgEAAIABAAWAAgABgAMAAYAEAA6ACwABAAwABAAAcIADAAAkDwEAAIABAAGAAgACgAMAAYAEAA6ACwABAAwABAAAcIAAAAAkEAEAAIABAAGAAgABgAMAAYAEAA6ACwABAAwABAAAcIA=",
+				},
+				{
+					Slug:        "ike-aggressive-mode",
+					Name:        "IKEv1 aggressive mode",
+					Service:     "ike",
+					EncodedData: "dWRwegAAAAIAAAAAAAAAAAEQBAAAAAAAAAACCAQAAUQAAAABAAAAAQAAATgBAQAIAwAAKAEBAACAAQAHgA4BAIACAAKAAwABgAQAAoALAAEADAAEAABwgAMAACgCAQAAgAEAB4AOAQCAAgABgAMAAYAEAAKACwABAAwABAAAcIADAAAoAwEAAIABAAeADgCAgAIAAoADAAGABAACgAsAAQAMAAQAAHCAAwAAKAQBAACAAQAHgA4AgIACAAGAAwABgAQAAoALAAEADAAEAABwgAMAACQFAQAAgAEABYACAAKAAwABgAQAAoALAAEADAAEAABwgAMAACQGAQAAgAEABYACAAGAAwABgAQAAoALAAEADAAEAABwgAMAACQHAQAAgAEAAYACAAKAAwABgAQAAoALAAEADAAEAABwgAAAACQIAQAAgAEAAYACAAGAAwABgAQAAoALAAEADAAEAABwgAoAAIRaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWgUAABhOTk5OTk5OTk5OTk5OTk5OTk5OTgAAAAwDAAAAdWRweg==",
+				},
+				{
+					Slug:        "ike-v2-sa-init",
+					Name:        "IKEv2 SA_INIT",
+					Service:     "ike",
+					EncodedData: "dWRwegAAAAMAAAAAAAAAACEgIggAAAAAAAABpCIAAFwAAABYAQEACQMAAAwBAAAMgA4BAAMAAAwBAAAMgA4AgAMAAAgBAAADAwAACAIAAAUDAAAIAgAAAgMAAAgDAAAMAwAACAMAAAIDAAAIBAAADgAAAAgEAAACKAABCAAOAABaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaAAAAJE5OTk5OTk5OTk5OTk5OTk5OTk5OTk5OTk5OTk5OTk5O",
+				},
+			},
+			Tags: []string{
+				"common",
+				"internet",
+			},
+			References: []string{
+				"https://en.wikipedia.org/wiki/Internet_Key_Exchange",
+			},
+		},
+		"ike-natt": {
+			Slug:        "ike-natt",
+			NameShort:   "IKE NAT-T",
+			Name:        "Internet Key Exchange NAT traversal (IKE NAT-T)",
+			Description: `IKE NAT traversal carries Internet Key Exchange (IKE) messages, preceded by a non-ESP marker, over the port that IPsec peers behind NAT devices also send encapsulated ESP traffic to.`,
+			Ports: []uint16{
+				4500,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "ike-natt-main-mode",
+					Name:        "IKEv1 main mode (NAT-T)",
+					Service:     "ike-natt",
+					EncodedData: "AAAAAHVkcHoAAAARAAAAAAAAAAABEAIAAAAAAAAAApAAAAJ0AAAAAQAAAAEAAAJoAQEAEAMAACgBAQAAgAEAB4AOAQCAAgACgAMAAYAEAAKACwABAAwABAAAcIADAAAoAgEAAIABAAeADgEAgAIAAYADAAGABAACgAsAAQAMAAQAAHCAAwAAKAMBAACAAQAHgA4AgIACAAKAAwABgAQAAoALAAEADAAEAABwgAMAACgEAQAAgAEAB4AOAICAAgABgAMAAYAEAAKACwABAAwABAAAcIADAAAkBQEAAIABAAWAAgACgAMAAYAEAAKACwABAAwABAAAcIADAAAkBgEAAIABAAWAAgABgAMAAYAEAAKACwABAAwABAAAcIADAAAkBwEAAIABAAGAAgACgAMAAYAEAAKACwABAAwABAAAcIADAAAkCAEAAIABAAGAAgABgAMAAYAEAAKACwABAAwABAAAcIADAAAoCQEAAIABAAeADgEAgAIAAoADAAGABAAOgAsAAQAMAAQAAHCAAwAAKAoBAACAAQAHgA4BAIACAAGAAwABgAQADoALAAEADAAEAABwgAMAACgLAQAAgAEAB4AOAICAAgACgAMAAYAEAA6ACwABAAwABAAAcIADAAAoDAEAAIABAAeADgCAgAIAAYADAAGABAAOgAsAAQAMAAQAAHCAAwAAJA0BAACAAQAFgAIAAoADAAGABAAOgAsAAQAMAAQAAHCAAwAAJA4BAACAAQAFgAIAAYADAAGABAAOgAsAAQAMAAQAAHCAAwAAJA8BAACAAQABgAIAAoADAAGABAAOgAsAAQAMAAQAAHCAAAAAJBABAACAAQABgAIAAYADAAGABAAOgAsAAQAMAAQAAHCA",
+				},
+				{
+					Slug:        "ike-natt-aggressive-mode",
+					Name:        "IKEv1 aggressive mode (NAT-T)",
+					Service:     "ike-natt",
+					EncodedData: "AAAAAHVkcHoAAAASAAAAAAAAAAABEAQAAAAAAAAAAggEAAFEAAAAAQAAAAEAAAE4AQEACAMAACgBAQAAgAEAB4AOAQCAAgACgAMAAYAEAAKACwABAAwABAAAcIADAAAoAgEAAIABAAeADgEAgAIAAYADAAGABAACgAsAAQAMAAQAAHCAAwAAKAMBAACAAQAHgA4AgIACAAKAAwABgAQAAoALAAEADAAEAABwgAMAACgEAQAAgAEAB4AOAICAAgABgAMAAYAEAAKACwABAAwABAAAcIADAAAkBQEAAIABAAWAAgACgAMAAYAEAAKACwABAAwABAAAcIADAAAkBgEAAIABAAWAAgABgAMAAYAEAAKACwABAAwABAAAcIADAAAkBwEAAIABAAGAAgACgAMAAYAEAAKACwABAAwABAAAcIAAAAAkCAEAAIABAAGAAgABgAMAAYAEAAKACwABAAwABAAAcIAKAACEWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWloFAAAYTk5OTk5OTk5OTk5OTk5OTk5OTk4AAAAMAwAAAHVkcHo=",
+				},
+				{
+					Slug:        "ike-natt-v2-sa-init",
+					Name:        "IKEv2 SA_INIT (NAT-T)",
+					Service:     "ike-natt",
+					EncodedData: "AAAAAHVkcHoAAAATAAAAAAAAAAAhICIIAAAAAAAAAaQiAABcAAAAWAEBAAkDAAAMAQAADIAOAQADAAAMAQAADIAOAIADAAAIAQAAAwMAAAgCAAAFAwAACAIAAAIDAAAIAwAADAMAAAgDAAACAwAACAQAAA4AAAAIBAAAAigAAQgADgAAWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWlpaWgAAACROTk5OTk5OTk5OTk5OTk5OTk5OTk5OTk5OTk5OTk5OTg==",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -1013,6 +1522,7 @@ var (
 			},
 			References: []string{
 				"https://en.wikipedia.org/wiki/Internet_Key_Exchange",
+				"https://datatracker.ietf.org/doc/html/rfc3948",
 			},
 		},
 		"radius": {
@@ -1031,12 +1541,25 @@ var (
 					Service:     "radius",
 					EncodedData: "AWcAV0C2ZNv11oGyrb0XaVFRGMgBB3N0ZXZlAhLbxsS3WL4U8AWzh3yeL7YBBAbAqAAcBQYAAAB7UBJfD4ZH6Mib2IE2Qmj80EUyTwwCZgAKAXN0ZXZl",
 				},
+				{
+					Slug:        "radius-access-request",
+					Name:        "RADIUS Access-Request (secret \"testing123\")",
+					Service:     "radius",
+					EncodedData: "AXUARHVkcHogcmFkaXVzIGF1dGgBBnVkcHoCEvZtQU9EPOX+UuHVs5ly5Q4gBnVkcHpQErWBsslFUCYxdsC20Yuv+wQ=",
+				},
+				{
+					Slug:        "radius-status-server",
+					Name:        "RADIUS Status-Server (secret \"testing123\")",
+					Service:     "radius",
+					EncodedData: "DHUALHVkcHogcmFkaXVzIGF1dGggBnVkcHpQEviRnDZe59wpEjtzQn+u62U=",
+				},
 			},
 			Tags: []string{
 				"common",
 			},
 			References: []string{
 				"https://en.wikipedia.org/wiki/RADIUS",
+				"https://www.rfc-editor.org/rfc/rfc5997",
 			},
 		},
 		"dtls": {
@@ -1056,6 +1579,12 @@ var (
 					Service:     "dtls",
 					EncodedData: "Fv7/AAAAAAAAAAAAwAEAALQAAAAAAAAAtP79+Cy0aiJvA+Bs0BWn891pJkw5IbKS1EQIvqHUgDv3g/UAAAA4wCzAMACfzKnMqMyqwCvALwCewCTAKABrwCPAJwBnwArAFAA5wAnAEwAzAJ0AnAA9ADwANQAvAP8BAABSAAsABAMAAQIACgAMAAoAHQAXAB4AGQAYACMAAAAWAAAAFwAAAA0AKgAoBAMFAwYDCAcICAgJCAoICwgECAUIBgQBBQEGAQMDAwEDAgQCBQIGAg==",
 				},
+				{
+					Slug:        "dtls-v1-client-hello",
+					Name:        "DTLS 1.0 client hello",
+					Service:     "dtls",
+					EncodedData: "Fv7/AAAAAAAAAAAAYAEAAFQAAAAAAAAAVP7/i1TtYygo6apUE5rYu3593buqooDJRFqAoszKX+J6/EIAAAAUwBTACsATwAkAOQAzADUALwAKAP8BAAAWAAsAAgEAAAoACAAGAB0AFwAYACMAAA==",
+				},
 				{
 					Slug:        "dtls-app-data",
 					Name:        "DTLS application data",
@@ -1070,6 +1599,44 @@ var (
 				"https://wikipedia.org/wiki/Datagram_Transport_Layer_Security",
 			},
 		},
+		"quic": {
+			Slug:        "quic",
+			NameShort:   "QUIC",
+			Name:        "QUIC and HTTP/3",
+			Description: `QUIC is a multiplexed, encrypted transport protocol over UDP that carries HTTP/3, DNS over QUIC and SMB over QUIC. Servers answer unknown versions with the list of versions they support, and Initial packets are protected with keys anyone can derive, so the version, cipher suite and application protocol of a server can be read without completing a handshake.`,
+			Ports: []uint16{
+				443,
+				8443,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "quic-version-negotiation",
+					Name:        "QUIC version negotiation",
+					Service:     "quic",
+					EncodedData: "yhoqOkoIdWRwelFVSUMIdWRwegAAAAUAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+					Matches: []UdpMatch{
+						{Pattern: `^[\x80-\xff]\x00\x00\x00\x00`},
+					},
+				},
+				{
+					Slug:        "quic-initial-h3",
+					Name:        "QUIC Initial (HTTP/3)",
+					Service:     "quic",
+					EncodedData: "wwAAAAEIdWRwelFVSUMIdWRwegAAAAUARJY+RjiQbzz5TntU5H5FUx4dQEUx4eok11NkQkeZdIVtD7hX2VwPOIgEcYlX/ONeDGP9yF9U6NvYkAVilXTyK9VUCvHtu0UA5yXW+209y5a/Xh5XdMg/LOBhcXzIi+xQhcsNygtno4585kAyJxlBL+y2vKBBp+R+Do7IIM+qa9wGFqrrp4PDwpwEdrBRL9YFpe7vDNQt/BOntlPuvACI9c+daQemwaOsIA07/eG/7CoXgtYv8Bx7v2GVajl8sXj4B80cwDI4jxdj6tVXcVG716uvLUzsIdOSq/R9gA5VsrDu2A1jm48lbiT8CaKJ5/NE15TwpSHOKR9b3qu3EPXPWGHciub8aIFMvGncpVxB9Wdc9vDeSK2SaCZaVbSTSKBnPOOkdB3T80qksPwvn0/PP8CPRZUvG+zH0ZqYL41hHcFr77OiCoRxAOdpF0TSf9dDxzC3iPiHmtY3+HyA0keEIJwcNtT6aC6AA8DpmYKrVn9ZKU96PySik6L9zcrlDRwEn0xxXmEA/jb8nYkksIC8bktfVpG9FiF5FiyxPscqlo+gpmwDN7QLPgEGGtmEm2bYDaCxJGlglSJRZheLnFaY5iqxwDnVUVE+4Se/+ZlINTeWKLh5EYuAcoRYouzshd5YQ/utD/IupY4VhQz0+HAnXCZVo2Thwrmj7bd3xJ5lM1FNb2vy/nDvggG5oDoiCPW4B+4MvHC+6D6dTe5safnyq6jsjd17mWoL9AQFm/QugkdOWR9tCo0YiFT/uUDSUGjNFspJMNBxCzzdOieL4curNpFvzjPwI3xEqI6kIhagYhLjtm9MhSpJXXZvkRIQPUWrVIFUN2ptfrnNq7D8WtjdScX7kdXekpJmZpLry4wFV0vLm7+7/9s6XRt2O58bJtzd5Wpwwa6gSf9m0ipvRwv3O+gHJp3tb1AbpMCRDQ6+110cz9kGiZkHeFkLr8TLaw6wH5Uc+JX7NTT7VbJW3uwJi51OZSY8ESOW/UgXF9D9R0bgR/wnkuLE1usOK3yobzLBfYXdxs7QV2J/yI9VXZ9KkVrf35+IvBPIqD1Avs2UlOeF/3Rb4GyZzcaHuiM+SHlRUdjsr3mRCEPCJIod4vNw6mmRZ5KzxX+L0ezbeUh2pY/GmbGsYtGu/cP664WEAIhcDp2K/3d4oA62ZQuo7UF9DxuixdhJVdr4YC4lAKNqG/Aq+CdcRv4pPMvG0P5tB+9b3YgfBlqeyh+DFeT6iWQnLhUuCqdjoy6aTPD65dj/GEUNfNT8AqcXV0dhdYmMvbPKgy9prJJs5UK/IHhz3ZBRH7EZdC63v+uEUljX2Y/6ZzMUzRBxwzmHMYKciW5M7eH8basKYsoo+KdBwSm7QcZMjM+nNDKn2sXw+rVVTisjjKDK9pJkoSAPG9ZLXsE2MZVwXPxUvQ0bsbkCKsM6Z8ytiITdSA9B7kz0uhEU3+OuwQnn+or4y2BqmRtP7yPD44SnRm3FHShRRalE/aI3UyM7oE298WntPzZGjWh4eWllc5u6Bdg8YTTxyXlovrpIXwY2KeIx0MLc8tGyIj68zexpFk2R8+OZ+a/1",
+					Matches: []UdpMatch{
+						{Pattern: `^[\x80-\xff]`},
+					},
+				},
+			},
+			Tags: []string{
+				"internet",
+			},
+			References: []string{
+				"https://www.rfc-editor.org/rfc/rfc9000",
+				"https://www.rfc-editor.org/rfc/rfc9001",
+				"https://www.rfc-editor.org/rfc/rfc9114",
+			},
+		},
 		"enip": {
 			Slug:        "enip",
 			NameShort:   "EtherNet/IP",
@@ -1097,6 +1664,30 @@ var (
 				"https://wikipedia.org/wiki/EtherNet/IP",
 			},
 		},
+		"profinet": {
+			Slug:        "profinet",
+			NameShort:   "PROFINET",
+			Name:        "PROFINET IO Context Manager",
+			Description: `PROFINET is an industrial Ethernet standard for exchanging data with controllers and field devices. PROFINET IO devices, such as Siemens PLCs, register their context manager with a DCE/RPC endpoint mapper, which returns an annotation with their device type, order number and revisions. PROFINET DCP identification runs over Ethernet frames, so it is not available over UDP.`,
+			Ports: []uint16{
+				34964,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "profinet-cm-lookup",
+					Name:        "PROFINET IO endpoint mapper lookup",
+					Service:     "profinet",
+					EncodedData: "BAAgABAAAAAAAAAAAAAAAAAAAAAAAAAACIOv4R9dyRGRpAgAKxSg+nVkcHogcHJvZmluZXQgY20AAAAAAwAAAAAAAAACAP////9MAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAAAAAAAACAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABAAAA",
+				},
+			},
+			Tags: []string{
+				"ics",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=34964",
+				"https://wikipedia.org/wiki/PROFINET",
+			},
+		},
 		"bittorrent": {
 			Slug:        "bittorrent",
 			NameShort:   "BitTorrent DHT",
@@ -1121,6 +1712,111 @@ var (
 				"http://www.bittorrent.org/beps/bep_0005.html",
 			},
 		},
+		"steam": {
+			Slug:        "steam",
+			NameShort:   "Source Query",
+			Name:        "Steam (Valve gaming platform) Source Engine Query",
+			Description: `Game servers built on Valve's Source and GoldSource engines, such as those of Counter-Strike, Team Fortress 2, Rust and ARK, answer the Source Engine Query (A2S) protocol with their name, map, game, player counts and version so the Steam server browser can list them.`,
+			Ports: []uint16{
+				27015,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "steam-a2s-info",
+					Name:        "Steam A2S_INFO query",
+					Service:     "steam",
+					EncodedData: "/////1RTb3VyY2UgRW5naW5lIFF1ZXJ5AA==",
+					Matches: []UdpMatch{
+						{Bytes: "ffffffff", MinLength: 5},
+					},
+				},
+			},
+			Tags: []string{
+				"games",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=27015",
+				"https://developer.valvesoftware.com/wiki/Server_queries",
+			},
+		},
+		"quake3": {
+			Slug:        "quake3",
+			NameShort:   "Quake III",
+			Name:        "Quake III Arena engine server",
+			Description: `Game servers built on the Quake III engine, such as those of Quake III Arena, Wolfenstein: Enemy Territory, Call of Duty and their open source ports, answer getstatus with their server variables, including the host name, map and version, followed by a line for each player.`,
+			Ports: []uint16{
+				27960,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "quake3-getstatus",
+					Name:        "Quake III getstatus",
+					Service:     "quake3",
+					EncodedData: "/////2dldHN0YXR1cwo=",
+					Matches: []UdpMatch{
+						{Bytes: "ffffffff", MinLength: 5},
+					},
+				},
+			},
+			Tags: []string{
+				"games",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=27960",
+				"https://github.com/ioquake/ioq3/blob/main/code/server/sv_main.c",
+			},
+		},
+		"minecraft-bedrock": {
+			Slug:        "minecraft-bedrock",
+			NameShort:   "Minecraft Bedrock",
+			Name:        "Minecraft Bedrock Edition server",
+			Description: `Minecraft Bedrock Edition servers speak RakNet, a reliable transport over UDP, and answer the unconnected pings clients send to list servers with their message of the day, version, player counts, world and game mode.`,
+			Ports: []uint16{
+				19132,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "minecraft-bedrock-ping",
+					Name:        "RakNet unconnected ping",
+					Service:     "minecraft-bedrock",
+					EncodedData: "AXVkcHoAAAABAP//AP7+/v79/f39EjRWeHVkcHp1ZHB6",
+					Matches: []UdpMatch{
+						{Offset: 17, Bytes: "00ffff00fefefefefdfdfdfd12345678"},
+					},
+				},
+			},
+			Tags: []string{
+				"games",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=19132",
+				"https://wiki.vg/Raknet_Protocol#Unconnected_Ping",
+			},
+		},
+		"teamspeak": {
+			Slug:        "teamspeak",
+			NameShort:   "TeamSpeak 3",
+			Name:        "TeamSpeak 3 voice server",
+			Description: `TeamSpeak 3 is a voice chat server popular with gaming communities. Its voice port answers the first packet of the client handshake with a cookie before any authentication.`,
+			Ports: []uint16{
+				9987,
+			},
+			Probes: []UdpProbe{
+				{
+					Slug:        "teamspeak-init",
+					Name:        "TeamSpeak 3 handshake init",
+					Service:     "teamspeak",
+					EncodedData: "VFMzSU5JVDEAZQAAiF4L4QAAdWRwenVkcHoAAAAAAAAAAA==",
+				},
+			},
+			Tags: []string{
+				"games",
+			},
+			References: []string{
+				"https://www.speedguide.net/port.php?port=9987",
+				"https://github.com/ReSpeak/tsdeclarations/blob/master/ts3protocol.md",
+			},
+		},
 		"msrpc": {
 			Slug:        "msrpc",
 			NameShort:   "MSRPC",
@@ -1152,7 +1848,7 @@ var (
 			Name:        "Layer 2 Tunneling Protocol (L2TP)",
 			Description: `Layer 2 Tunneling Protocol (L2TP) is a protocol used to create secure tunnels for data transmission over the internet, often in VPN setups. Operating at Layer 2, it encapsulates data to enable secure, private connections between networks, though it typically relies on IPsec to provide encryption.`,
 			Ports: []uint16{
-				1702,
+				1701,
 			},
 			Probes: []UdpProbe{
 				{
@@ -1161,6 +1857,12 @@ var (
 					Service:     "l2tp",
 					EncodedData: "yAIAawAAAAAAAAAAgAgAAAAAAAGACAAAAAIBAIAKAAAAAwAAAAOACgAAAAQAAAAAgAgAAAAGBpCAEAAAAAcxMDkuNi4xLjcygBMAAAAIeGVsZXJhbmNlLmNvbYAIAAAACUoygAgAAAAKAAQ=",
 				},
+				{
+					Slug:        "l2tp-sccrq",
+					Name:        "L2TP Start-Control-Connection-Request",
+					Service:     "l2tp",
+					EncodedData: "yAIASgAAAAAAAAAAgAgAAAAAAAGACAAAAAIBAIAKAAAAAwAAAAOACgAAAAd1ZHB6AAoAAAAIdWRweoAIAAAACXV6gAgAAAAKAAQ=",
+				},
 			},
 			Tags: []string{
 				"common",
@@ -1169,6 +1871,7 @@ var (
 			References: []string{
 				"https://www.speedguide.net/port.php?port=1701",
 				"https://wikipedia.org/wiki/Layer_2_Tunneling_Protocol",
+				"https://www.rfc-editor.org/rfc/rfc2661",
 			},
 		},
 		"nfs": {
@@ -1311,20 +2014,6 @@ var (
 					"https://wiki.wireshark.org/SampleCaptures#ethernet-powerlink-v2",
 				},
 			},
-				"steam-hltv": {
-					Slug:        "steam-hltv",
-					NameShort:   "Steam HLTV",
-					Name:        "Steam (Valve gaming platform) Half-Life TV",
-					Description: `Half-Life TV offers the ability to have an unlimited number of spectators watching online games. They can follow the game just like they would as a spectator on the game server.`,
-					References: []string{
-						"https://www.speedguide.net/port.php?port=27015",
-						"https://help.steampowered.com/en/faqs/view/558D-FD60-531D-98BC",
-					},
-					Ports: []uint16{
-						27015,
-					},
-					Probes: []UdpProbe{},
-				},
 		*/
 		/*
 			"sentinel": {
@@ -0,0 +1,254 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProbeFile is the format of user-defined probe files. It mirrors UdpService
+// and UdpProbe, but also accepts probe payloads as hex.
+//
+//	services:
+//	  - slug: example
+//	    name: Example Protocol
+//	    short: Example
+//	    ports: [9999]
+//	    tags: [custom]
+//	    probes:
+//	      - slug: example-hello
+//	        name: Example hello
+//	        hex: "48454c4c4f0a"
+//	        matches:
+//	          - pattern: '^OK'
+//	        steps:
+//	          - extract:
+//	              - name: cookie
+//	                pattern: '^OK (\w+)'
+//	            template: "434f4f4b494520 {{cookie}} 0a"
+type ProbeFile struct {
+	Services []ProbeFileService `yaml:"services" json:"services"`
+}
+
+type ProbeFileService struct {
+	Slug        string           `yaml:"slug" json:"slug"`
+	Name        string           `yaml:"name,omitempty" json:"name,omitempty"`
+	NameShort   string           `yaml:"short,omitempty" json:"short,omitempty"`
+	Description string           `yaml:"description,omitempty" json:"description,omitempty"`
+	Ports       []uint16         `yaml:"ports,omitempty" json:"ports,omitempty"`
+	Probes      []ProbeFileProbe `yaml:"probes,omitempty" json:"probes,omitempty"`
+	Tags        []string         `yaml:"tags,omitempty" json:"tags,omitempty"`
+	References  []string         `yaml:"references,omitempty" json:"references,omitempty"`
+}
+
+type ProbeFileProbe struct {
+	Slug        string     `yaml:"slug" json:"slug"`
+	Name        string     `yaml:"name,omitempty" json:"name,omitempty"`
+	EncodedData string     `yaml:"data,omitempty" json:"data,omitempty"`
+	Hex         string     `yaml:"hex,omitempty" json:"hex,omitempty"`
+	Matches     []UdpMatch `yaml:"matches,omitempty" json:"matches,omitempty"`
+	Steps       []UdpStep  `yaml:"steps,omitempty" json:"steps,omitempty"`
+}
+
+// LoadProbeFile reads user-defined services and probes from a YAML file
+func LoadProbeFile(path string) (services map[string]UdpService, err error) {
+
+	var file *os.File
+
+	if file, err = os.Open(path); err != nil {
+		return
+	}
+	defer file.Close()
+
+	return ReadProbeFile(file)
+}
+
+// ReadProbeFile parses user-defined services and probes in the ProbeFile
+// format, validating their payloads and match rules
+func ReadProbeFile(reader io.Reader) (services map[string]UdpService, err error) {
+
+	var probeFile ProbeFile
+
+	if err = yaml.NewDecoder(reader).Decode(&probeFile); err != nil {
+		return
+	}
+	services = make(map[string]UdpService)
+
+	for _, fileService := range probeFile.Services {
+
+		if fileService.Slug == "" {
+			return nil, errors.New("probe file service is missing a slug")
+		}
+		service := UdpService{
+			Slug:        fileService.Slug,
+			Name:        fileService.Name,
+			NameShort:   fileService.NameShort,
+			Description: fileService.Description,
+			Ports:       fileService.Ports,
+			Tags:        fileService.Tags,
+			References:  fileService.References,
+		}
+		for _, fileProbe := range fileService.Probes {
+
+			probe := UdpProbe{
+				Slug:        fileProbe.Slug,
+				Name:        fileProbe.Name,
+				Service:     service.Slug,
+				EncodedData: fileProbe.EncodedData,
+				Matches:     fileProbe.Matches,
+				Steps:       fileProbe.Steps,
+			}
+			if probe.Slug == "" {
+				return nil, errors.New("probe file probe is missing a slug in service: " + service.Slug)
+			}
+			if probe.Name == "" {
+				probe.Name = probe.Slug
+			}
+			if fileProbe.Hex != "" {
+				payload, err := hex.DecodeString(strings.Join(strings.Fields(fileProbe.Hex), ""))
+
+				if err != nil {
+					return nil, errors.New("invalid hex payload in probe " + probe.Slug + ": " + err.Error())
+				}
+				probe.EncodedData = base64.StdEncoding.EncodeToString(payload)
+
+			} else if _, err := base64.StdEncoding.DecodeString(probe.EncodedData); err != nil {
+				return nil, errors.New("invalid base64 payload in probe " + probe.Slug + ": " + err.Error())
+			}
+			for _, match := range probe.Matches {
+				if err := match.Validate(); err != nil {
+					return nil, errors.New("invalid match pattern in probe " + probe.Slug + ": " + err.Error())
+				}
+			}
+			names := make(map[string]bool)
+
+			for _, step := range probe.Steps {
+				if err := step.Validate(names); err != nil {
+					return nil, errors.New("invalid step in probe " + probe.Slug + ": " + err.Error())
+				}
+			}
+			service.Probes = append(service.Probes, probe)
+		}
+		services[service.Slug] = service
+	}
+	return
+}
+
+// MergeServices returns a copy of the base services with the overrides
+// applied. Services are matched by slug; fields set in an override replace the
+// base values, and probes replace base probes with the same slug or are added.
+func MergeServices(base map[string]UdpService, overrides map[string]UdpService) map[string]UdpService {
+
+	merged := make(map[string]UdpService, len(base)+len(overrides))
+
+	for slug, service := range base {
+		merged[slug] = service
+	}
+	for slug, override := range overrides {
+
+		service, ok := merged[slug]
+
+		if !ok {
+			if override.Name == "" {
+				override.Name = slug
+			}
+			if override.NameShort == "" {
+				override.NameShort = override.Name
+			}
+			merged[slug] = override
+			continue
+		}
+		if override.Name != "" {
+			service.Name = override.Name
+		}
+		if override.NameShort != "" {
+			service.NameShort = override.NameShort
+		}
+		if override.Description != "" {
+			service.Description = override.Description
+		}
+		if len(override.Ports) > 0 {
+			service.Ports = override.Ports
+		}
+		if len(override.Tags) > 0 {
+			service.Tags = override.Tags
+		}
+		if len(override.References) > 0 {
+			service.References = override.References
+		}
+
+		probes := append([]UdpProbe(nil), service.Probes...)
+
+	overrideProbes:
+		for _, probe := range override.Probes {
+			for i := range probes {
+				if probes[i].Slug == probe.Slug {
+					probes[i] = probe
+					continue overrideProbes
+				}
+			}
+			probes = append(probes, probe)
+		}
+		service.Probes = probes
+		merged[slug] = service
+	}
+	return merged
+}
+
+// WriteProbeFile writes services in the ProbeFile format, with payloads
+// encoded as hex so they remain easy to read and edit
+func WriteProbeFile(writer io.Writer, services map[string]UdpService) error {
+
+	var probeFile ProbeFile
+
+	slugs := make([]string, 0, len(services))
+
+	for slug := range services {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+
+	for _, slug := range slugs {
+
+		service := services[slug]
+		fileService := ProbeFileService{
+			Slug:        service.Slug,
+			Name:        service.Name,
+			NameShort:   service.NameShort,
+			Description: service.Description,
+			Ports:       service.Ports,
+			Tags:        service.Tags,
+			References:  service.References,
+		}
+		for _, probe := range service.Probes {
+
+			payload, err := base64.StdEncoding.DecodeString(probe.EncodedData)
+
+			if err != nil {
+				return errors.New("invalid base64 payload in probe " + probe.Slug + ": " + err.Error())
+			}
+			fileService.Probes = append(fileService.Probes, ProbeFileProbe{
+				Slug:    probe.Slug,
+				Name:    probe.Name,
+				Hex:     hex.EncodeToString(payload),
+				Matches: probe.Matches,
+				Steps:   probe.Steps,
+			})
+		}
+		probeFile.Services = append(probeFile.Services, fileService)
+	}
+
+	encoder := yaml.NewEncoder(writer)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(&probeFile); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
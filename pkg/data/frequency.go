@@ -0,0 +1,96 @@
+package data
+
+import "sort"
+
+var (
+	// UDP_PORT_FREQUENCY holds the approximate fraction of hosts found with each
+	// UDP port open, as recorded in nmap's nmap-services file. Ports missing from
+	// this table are treated as the least common.
+	UDP_PORT_FREQUENCY = map[uint16]float64{
+		7:     0.024679,
+		9:     0.015048,
+		13:    0.004062,
+		17:    0.009209,
+		19:    0.015020,
+		37:    0.006170,
+		53:    0.213496,
+		67:    0.228010,
+		68:    0.140118,
+		69:    0.102835,
+		88:    0.004013,
+		111:   0.093988,
+		123:   0.330879,
+		135:   0.244452,
+		137:   0.365163,
+		138:   0.297830,
+		139:   0.193391,
+		161:   0.433467,
+		162:   0.103296,
+		177:   0.010135,
+		389:   0.004103,
+		427:   0.005118,
+		443:   0.005374,
+		445:   0.253118,
+		500:   0.163742,
+		514:   0.119804,
+		520:   0.139376,
+		521:   0.001083,
+		523:   0.000957,
+		623:   0.002316,
+		631:   0.450281,
+		1194:  0.001229,
+		1434:  0.293184,
+		1604:  0.001127,
+		1645:  0.008193,
+		1701:  0.052243,
+		1812:  0.045288,
+		1813:  0.041364,
+		1900:  0.136543,
+		2049:  0.032528,
+		3283:  0.061022,
+		3389:  0.005286,
+		3478:  0.001217,
+		3702:  0.002051,
+		4500:  0.124467,
+		5060:  0.043829,
+		5353:  0.100166,
+		5632:  0.019287,
+		11211: 0.000650,
+		49152: 0.116002,
+	}
+)
+
+// TopPorts returns the n most common ports probed by the given services,
+// ranked by UDP_PORT_FREQUENCY.
+func TopPorts(services map[string]UdpService, n int) map[uint16]bool {
+
+	var ranked []uint16
+
+	seen := make(map[uint16]bool)
+
+	for _, service := range services {
+		for _, port := range service.Ports {
+			if !seen[port] {
+				seen[port] = true
+				ranked = append(ranked, port)
+			}
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		fi, fj := UDP_PORT_FREQUENCY[ranked[i]], UDP_PORT_FREQUENCY[ranked[j]]
+		if fi != fj {
+			return fi > fj
+		}
+		return ranked[i] < ranked[j]
+	})
+	if n < len(ranked) {
+		ranked = ranked[:n]
+	}
+
+	top := make(map[uint16]bool, len(ranked))
+
+	for _, port := range ranked {
+		top[port] = true
+	}
+	return top
+}
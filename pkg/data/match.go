@@ -0,0 +1,132 @@
+package data
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	matchPatterns sync.Map // Compiled patterns keyed by their source
+)
+
+// compilePattern compiles a match pattern, caching the result
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+
+	if compiled, ok := matchPatterns.Load(pattern); ok {
+		return compiled.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(pattern)
+
+	if err == nil {
+		matchPatterns.Store(pattern, compiled)
+	}
+	return compiled, err
+}
+
+// latin1 maps every byte of a payload to a single character so that patterns
+// such as `\x00\xff` match raw bytes rather than UTF-8 sequences
+func latin1(payload []byte) string {
+
+	var builder strings.Builder
+
+	builder.Grow(len(payload))
+
+	for _, b := range payload {
+		builder.WriteRune(rune(b))
+	}
+	return builder.String()
+}
+
+// Validate reports whether the rule's pattern compiles and its bytes and mask
+// are hex of the same length
+func (m UdpMatch) Validate() error {
+
+	if m.Pattern != "" {
+		if _, err := compilePattern(m.Pattern); err != nil {
+			return err
+		}
+	}
+	if m.Offset < 0 {
+		return errors.New("negative match offset")
+	}
+	expected, err := decodeHex(m.Bytes)
+
+	if err != nil {
+		return err
+	}
+	if m.Mask != "" {
+		mask, err := decodeHex(m.Mask)
+
+		if err != nil {
+			return err
+		}
+		if len(mask) != len(expected) {
+			return errors.New("match mask and bytes differ in length")
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the response satisfies every condition of the rule
+func (m UdpMatch) Matches(response []byte) bool {
+
+	if len(response) < m.MinLength {
+		return false
+	}
+	if m.Pattern != "" {
+		compiled, err := compilePattern(m.Pattern)
+
+		if err != nil || !compiled.MatchString(latin1(response)) {
+			return false
+		}
+	}
+	if m.Bytes != "" && !m.matchesBytes(response) {
+		return false
+	}
+	return true
+}
+
+// matchesBytes compares the bytes of the response at the rule's offset with
+// the expected ones, ignoring the bits cleared in the mask
+func (m UdpMatch) matchesBytes(response []byte) bool {
+
+	expected, err := decodeHex(m.Bytes)
+
+	if err != nil || m.Offset+len(expected) > len(response) {
+		return false
+	}
+	mask, err := decodeHex(m.Mask)
+
+	if err != nil || (len(mask) != 0 && len(mask) != len(expected)) {
+		return false
+	}
+	for i, b := range expected {
+
+		actual := response[m.Offset+i]
+
+		if len(mask) != 0 {
+			actual, b = actual&mask[i], b&mask[i]
+		}
+		if actual != b {
+			return false
+		}
+	}
+	return true
+}
+
+// Accepts reports whether a response can be attributed to the probe. A
+// response is accepted if it satisfies any of the probe's rules.
+func (p UdpProbe) Accepts(response []byte) bool {
+
+	if len(p.Matches) == 0 {
+		return true
+	}
+	for _, match := range p.Matches {
+		if match.Matches(response) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package data
+
+import "testing"
+
+func TestUdpMatch(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		match    UdpMatch
+		response string
+		want     bool
+	}{
+		{"pattern", UdpMatch{Pattern: `^OK`}, "OK ready", true},
+		{"pattern miss", UdpMatch{Pattern: `^OK`}, "ERR", false},
+		{"too short", UdpMatch{Pattern: `^OK`, MinLength: 4}, "OK", false},
+		{"bytes", UdpMatch{Offset: 1, Bytes: "ffee"}, "\x00\xff\xee\x01", true},
+		{"bytes differ", UdpMatch{Offset: 1, Bytes: "ffee"}, "\x00\xff\xef\x01", false},
+		{"bytes past the end", UdpMatch{Offset: 3, Bytes: "ffee"}, "\x00\xff\xee\x01", false},
+		{"masked", UdpMatch{Bytes: "80", Mask: "f0"}, "\x8f", true},
+		{"masked differ", UdpMatch{Bytes: "80", Mask: "f0"}, "\x7f", false},
+		{"every condition", UdpMatch{Pattern: `ready`, Bytes: "4f4b"}, "OK ready", true},
+		{"one condition fails", UdpMatch{Pattern: `ready`, Bytes: "4f4c"}, "OK ready", false},
+	}
+	for _, test := range tests {
+		if got := test.match.Matches([]byte(test.response)); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUdpMatchValidate(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		match UdpMatch
+		ok    bool
+	}{
+		{"valid", UdpMatch{Pattern: `^OK`, Offset: 2, Bytes: "00 ff", Mask: "0f ff"}, true},
+		{"bad pattern", UdpMatch{Pattern: `(`}, false},
+		{"bad bytes", UdpMatch{Bytes: "zz"}, false},
+		{"mask length", UdpMatch{Bytes: "00ff", Mask: "ff"}, false},
+		{"negative offset", UdpMatch{Offset: -1, Bytes: "00"}, false},
+	}
+	for _, test := range tests {
+		if err := test.match.Validate(); (err == nil) != test.ok {
+			t.Errorf("%s: got %v, want ok %v", test.name, err, test.ok)
+		}
+	}
+	for slug, service := range UDP_SERVICES {
+		for _, probe := range service.Probes {
+			for _, match := range probe.Matches {
+				if err := match.Validate(); err != nil {
+					t.Errorf("%s: invalid match rule in probe %s: %v", slug, probe.Slug, err)
+				}
+			}
+		}
+	}
+}
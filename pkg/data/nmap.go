@@ -0,0 +1,363 @@
+package data
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// NMAP_DATA_PATHS are the locations nmap data files are commonly installed to
+	NMAP_DATA_PATHS = []string{
+		"/usr/share/nmap",
+		"/usr/local/share/nmap",
+		"/opt/homebrew/share/nmap",
+		`C:\Program Files (x86)\Nmap`,
+		`C:\Program Files\Nmap`,
+	}
+)
+
+// unescapeNmap decodes the C-style escape sequences used by nmap data files
+func unescapeNmap(escaped string) (string, error) {
+
+	var builder strings.Builder
+
+	for i := 0; i < len(escaped); i++ {
+
+		if escaped[i] != '\\' {
+			builder.WriteByte(escaped[i])
+			continue
+		}
+		if i++; i >= len(escaped) {
+			return "", errors.New("trailing backslash")
+		}
+		switch escaped[i] {
+		case '0':
+			builder.WriteByte(0)
+		case 'a':
+			builder.WriteByte('\a')
+		case 'b':
+			builder.WriteByte('\b')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 't':
+			builder.WriteByte('\t')
+		case 'v':
+			builder.WriteByte('\v')
+		case 'x':
+			if i+2 >= len(escaped) {
+				return "", errors.New("truncated hex escape")
+			}
+			b, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8)
+
+			if err != nil {
+				return "", errors.New("invalid hex escape: \\x" + escaped[i+1:i+3])
+			}
+			builder.WriteByte(byte(b))
+			i += 2
+		default:
+			builder.WriteByte(escaped[i])
+		}
+	}
+	return builder.String(), nil
+}
+
+// nmapToken is a bare word or a quoted string from an nmap-payloads file
+type nmapToken struct {
+	value  string
+	quoted bool
+	line   int
+}
+
+// tokenizeNmapPayloads splits an nmap-payloads file into tokens, dropping
+// comments and decoding quoted strings
+func tokenizeNmapPayloads(reader io.Reader) (tokens []nmapToken, err error) {
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0x10000), 0x100000)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+
+		line := scanner.Text()
+
+		for i := 0; i < len(line); {
+			switch {
+			case line[i] == '#':
+				i = len(line)
+
+			case line[i] == ' ' || line[i] == '\t':
+				i++
+
+			case line[i] == '"':
+				end := i + 1
+
+				for ; end < len(line) && line[end] != '"'; end++ {
+					if line[end] == '\\' {
+						end++
+					}
+				}
+				if end >= len(line) {
+					return nil, fmt.Errorf("line %d: unterminated string", lineNumber)
+				}
+				value, err := unescapeNmap(line[i+1 : end])
+
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+				}
+				tokens = append(tokens, nmapToken{value, true, lineNumber})
+				i = end + 1
+
+			default:
+				end := strings.IndexAny(line[i:], " \t#\"")
+
+				if end < 0 {
+					end = len(line) - i
+				}
+				tokens = append(tokens, nmapToken{line[i : i+end], false, lineNumber})
+				i += end
+			}
+		}
+	}
+	return tokens, scanner.Err()
+}
+
+// sortedPorts returns the ports of a port set in ascending order
+func sortedPorts(ports map[uint16]bool) []uint16 {
+
+	portList := make([]uint16, 0, len(ports))
+
+	for port := range ports {
+		portList = append(portList, port)
+	}
+	sort.Slice(portList, func(i, j int) bool {
+		return portList[i] < portList[j]
+	})
+	return portList
+}
+
+// ParseNmapPayloads converts the UDP payloads of an nmap-payloads file into
+// services, one per distinct set of ports
+func ParseNmapPayloads(reader io.Reader) (services map[string]UdpService, err error) {
+
+	var tokens []nmapToken
+
+	if tokens, err = tokenizeNmapPayloads(reader); err != nil {
+		return
+	}
+	services = make(map[string]UdpService)
+
+	for i := 0; i < len(tokens); {
+
+		if tokens[i].quoted || strings.ToLower(tokens[i].value) != "udp" {
+			return nil, fmt.Errorf("line %d: expected protocol, found %q", tokens[i].line, tokens[i].value)
+		}
+		if i+1 >= len(tokens) || tokens[i+1].quoted {
+			return nil, fmt.Errorf("line %d: expected port list", tokens[i].line)
+		}
+		portSpec := tokens[i+1].value
+		ports, err := ParsePorts(portSpec)
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", tokens[i+1].line, err)
+		}
+
+		var payload strings.Builder
+
+		for i += 2; i < len(tokens) && tokens[i].quoted; i++ {
+			payload.WriteString(tokens[i].value)
+		}
+		if i+1 < len(tokens) && tokens[i].value == "source" && !tokens[i+1].quoted {
+			i += 2
+		}
+
+		portList := sortedPorts(ports)
+		slug := fmt.Sprintf("nmap-payload-%d", portList[0])
+		service, ok := services[slug]
+
+		if !ok {
+			service = UdpService{
+				Slug:      slug,
+				Name:      "nmap payload for UDP " + portSpec,
+				NameShort: "nmap-" + strconv.Itoa(int(portList[0])),
+				Ports:     portList,
+				Tags:      []string{"nmap"},
+				References: []string{
+					"https://nmap.org/book/nmap-payloads.html",
+				},
+			}
+		}
+		service.Probes = append(service.Probes, UdpProbe{
+			Slug:        fmt.Sprintf("%s-%d", slug, len(service.Probes)+1),
+			Name:        "nmap payload " + portSpec,
+			Service:     slug,
+			EncodedData: base64.StdEncoding.EncodeToString([]byte(payload.String())),
+		})
+		services[slug] = service
+	}
+	return
+}
+
+// nmapDelimited splits a field such as "q|payload|" or "m=regex=si" into its
+// delimited body and the text following the closing delimiter
+func nmapDelimited(field string) (body string, rest string, ok bool) {
+
+	if len(field) < 3 {
+		return
+	}
+	delimiter := field[1]
+	end := strings.IndexByte(field[2:], delimiter)
+
+	if end < 0 {
+		return
+	}
+	return field[2 : 2+end], field[3+end:], true
+}
+
+// nmapPattern converts a PCRE pattern from nmap-service-probes to Go regexp
+// syntax where possible
+func nmapPattern(pattern string, flags string) string {
+
+	var builder strings.Builder
+
+	for _, flag := range flags {
+		if flag == 'i' || flag == 's' {
+			builder.WriteString("(?" + string(flag) + ")")
+		}
+	}
+	for i := 0; i < len(pattern); i++ {
+
+		// Go only accepts \0 as part of a three digit octal escape
+		if pattern[i] == '\\' && i+1 < len(pattern) && pattern[i+1] == '0' &&
+			(i+2 >= len(pattern) || pattern[i+2] < '0' || pattern[i+2] > '7') {
+
+			builder.WriteString(`\x00`)
+			i++
+			continue
+		}
+		builder.WriteByte(pattern[i])
+
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			builder.WriteByte(pattern[i+1])
+			i++
+		}
+	}
+	return builder.String()
+}
+
+// ParseNmapServiceProbes converts the UDP probes of an nmap-service-probes file
+// into services. Match patterns that Go cannot compile, such as those using
+// backreferences or lookarounds, are skipped and reported as warnings.
+func ParseNmapServiceProbes(reader io.Reader) (services map[string]UdpService, warnings []string, err error) {
+
+	var service *UdpService
+
+	services = make(map[string]UdpService)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0x10000), 0x100000)
+
+	save := func() {
+		if service == nil {
+			return
+		}
+		if len(service.Ports) == 0 {
+			warnings = append(warnings, "skipped probe without ports: "+service.Slug)
+		} else {
+			services[service.Slug] = *service
+		}
+		service = nil
+	}
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		directive, arguments, _ := strings.Cut(line, " ")
+
+		switch directive {
+		case "Probe":
+			save()
+			fields := strings.SplitN(arguments, " ", 3)
+
+			if len(fields) < 3 || fields[0] != "UDP" {
+				continue
+			}
+			body, _, ok := nmapDelimited(fields[2])
+
+			if !ok || fields[2][0] != 'q' {
+				return nil, nil, fmt.Errorf("line %d: invalid probe string", lineNumber)
+			}
+			payload, err := unescapeNmap(body)
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			slug := "nmap-" + strings.ToLower(fields[1])
+
+			service = &UdpService{
+				Slug:      slug,
+				Name:      "nmap " + fields[1] + " probe",
+				NameShort: fields[1],
+				Tags:      []string{"nmap"},
+				References: []string{
+					"https://nmap.org/book/vscan-fileformat.html",
+				},
+				Probes: []UdpProbe{
+					{
+						Slug:        slug,
+						Name:        fields[1],
+						Service:     slug,
+						EncodedData: base64.StdEncoding.EncodeToString([]byte(payload)),
+					},
+				},
+			}
+
+		case "ports":
+			if service == nil {
+				continue
+			}
+			ports, err := ParsePorts(arguments)
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			service.Ports = sortedPorts(ports)
+
+		case "match", "softmatch":
+			if service == nil {
+				continue
+			}
+			_, expression, _ := strings.Cut(arguments, " ")
+			body, rest, ok := nmapDelimited(expression)
+
+			if !ok || expression[0] != 'm' {
+				warnings = append(warnings, fmt.Sprintf("line %d: invalid match expression", lineNumber))
+				continue
+			}
+			flags, _, _ := strings.Cut(rest, " ")
+			match := UdpMatch{Pattern: nmapPattern(body, flags)}
+
+			if err := match.Validate(); err != nil {
+				warnings = append(warnings, fmt.Sprintf("line %d: skipped incompatible pattern: %s", lineNumber, err))
+				continue
+			}
+			probe := &service.Probes[0]
+			probe.Matches = append(probe.Matches, match)
+		}
+	}
+	save()
+
+	err = scanner.Err()
+	return
+}
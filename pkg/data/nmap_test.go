@@ -0,0 +1,205 @@
+package data
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnescapeNmap(t *testing.T) {
+
+	tests := []struct {
+		escaped   string
+		unescaped string
+		invalid   bool
+	}{
+		{escaped: `plain`, unescaped: "plain"},
+		{escaped: `\0\a\b\f\n\r\t\v`, unescaped: "\x00\a\b\f\n\r\t\v"},
+		{escaped: `\x00\xFF\x7f`, unescaped: "\x00\xff\x7f"},
+		{escaped: `\"\\`, unescaped: `"\`},
+		{escaped: `\`, invalid: true},
+		{escaped: `\x0`, invalid: true},
+		{escaped: `\xzz`, invalid: true},
+	}
+
+	for _, test := range tests {
+		unescaped, err := unescapeNmap(test.escaped)
+
+		if test.invalid {
+			if err == nil {
+				t.Errorf("unescapeNmap(%q) = %q, want an error", test.escaped, unescaped)
+			}
+		} else if err != nil || unescaped != test.unescaped {
+			t.Errorf("unescapeNmap(%q) = %q, %v, want %q", test.escaped, unescaped, err, test.unescaped)
+		}
+	}
+}
+
+func TestParseNmapPayloads(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		file     string
+		services map[string][]string // Payloads of each service
+		ports    map[string][]uint16
+		invalid  bool
+	}{
+		{
+			name: "single payload",
+			file: "udp 7 \"\\x0d\\x0a\"\n",
+			services: map[string][]string{
+				"nmap-payload-7": {"\r\n"},
+			},
+			ports: map[string][]uint16{"nmap-payload-7": {7}},
+		},
+		{
+			name: "continued strings, comments and source ports",
+			file: "# Comment\n" +
+				"udp 53,5353 \"ab\" # trailing\n" +
+				"  \"cd\"\n" +
+				"  source 53\n" +
+				"udp 123 \"\\xe3\"\n",
+			services: map[string][]string{
+				"nmap-payload-53":  {"abcd"},
+				"nmap-payload-123": {"\xe3"},
+			},
+			ports: map[string][]uint16{
+				"nmap-payload-53":  {53, 5353},
+				"nmap-payload-123": {123},
+			},
+		},
+		{
+			name: "payloads sharing a first port",
+			file: "udp 161 \"a\"\nudp 161 \"b\"\n",
+			services: map[string][]string{
+				"nmap-payload-161": {"a", "b"},
+			},
+			ports: map[string][]uint16{"nmap-payload-161": {161}},
+		},
+		{name: "unknown protocol", file: "tcp 80 \"x\"\n", invalid: true},
+		{name: "missing ports", file: "udp \"x\"\n", invalid: true},
+		{name: "invalid ports", file: "udp dns \"x\"\n", invalid: true},
+		{name: "unterminated string", file: "udp 53 \"x\n", invalid: true},
+	}
+
+	for _, test := range tests {
+		services, err := ParseNmapPayloads(strings.NewReader(test.file))
+
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%s: ParseNmapPayloads succeeded, want an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ParseNmapPayloads failed: %s", test.name, err)
+			continue
+		}
+		if len(services) != len(test.services) {
+			t.Errorf("%s: got %d services, want %d", test.name, len(services), len(test.services))
+		}
+		for slug, payloads := range test.services {
+			service, ok := services[slug]
+
+			if !ok {
+				t.Errorf("%s: missing service %s", test.name, slug)
+				continue
+			}
+			if !reflect.DeepEqual(service.Ports, test.ports[slug]) {
+				t.Errorf("%s: %s ports = %v, want %v", test.name, slug, service.Ports, test.ports[slug])
+			}
+			if got := probePayloads(t, service); !reflect.DeepEqual(got, payloads) {
+				t.Errorf("%s: %s payloads = %q, want %q", test.name, slug, got, payloads)
+			}
+		}
+	}
+}
+
+func TestParseNmapServiceProbes(t *testing.T) {
+
+	file := `# nmap-service-probes excerpt
+Probe TCP NULL q||
+ports 1-100
+match ftp m|^220|
+
+Probe UDP DNSStatusRequest q|\0\0\x10\0\0\0\0\0\0\0\0\0|
+rarity 1
+ports 53,135
+match domain m|^\0\0\x90\x04\0\0\0\0\0\0\0\0| p/ISC BIND/
+softmatch domain m|^\0\0\x90|si
+match backref m|^(a)\1|
+
+Probe UDP Help q|help\r\n\r\n|
+match help m|^help|
+`
+	services, warnings, err := ParseNmapServiceProbes(strings.NewReader(file))
+
+	if err != nil {
+		t.Fatalf("ParseNmapServiceProbes failed: %s", err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("got %d services, want 1", len(services))
+	}
+	service, ok := services["nmap-dnsstatusrequest"]
+
+	if !ok {
+		t.Fatalf("missing service nmap-dnsstatusrequest, got %v", services)
+	}
+	if want := []uint16{53, 135}; !reflect.DeepEqual(service.Ports, want) {
+		t.Errorf("ports = %v, want %v", service.Ports, want)
+	}
+	if got, want := probePayloads(t, service), []string{"\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00\x00\x00"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("payloads = %q, want %q", got, want)
+	}
+
+	matches := service.Probes[0].Matches
+
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want 2: %v", len(matches), matches)
+	}
+	if !matches[0].Matches([]byte("\x00\x00\x90\x04\x00\x00\x00\x00\x00\x00\x00\x00")) {
+		t.Errorf("match %q does not accept a BIND status reply", matches[0].Pattern)
+	}
+	if matches[0].Matches([]byte("\x00\x00\x80\x04")) {
+		t.Errorf("match %q accepts an unrelated reply", matches[0].Pattern)
+	}
+	if matches[1].Pattern != `(?s)(?i)^\x00\x00\x90` {
+		t.Errorf("softmatch pattern = %q, want flags and a Go null escape", matches[1].Pattern)
+	}
+
+	// The backreference cannot be compiled, and the last probe has no ports
+	if len(warnings) != 2 {
+		t.Errorf("got warnings %q, want 2", warnings)
+	}
+}
+
+func TestParseNmapServiceProbesInvalid(t *testing.T) {
+
+	tests := []string{
+		"Probe UDP Bad x|payload|\n",
+		"Probe UDP Bad q|payload\n",
+		"Probe UDP Bad q|\\x0|\n",
+		"Probe UDP Good q|x|\nports dns\n",
+	}
+
+	for _, file := range tests {
+		if _, _, err := ParseNmapServiceProbes(strings.NewReader(file)); err == nil {
+			t.Errorf("ParseNmapServiceProbes(%q) succeeded, want an error", file)
+		}
+	}
+}
+
+// probePayloads decodes the payloads of every probe of a service
+func probePayloads(t *testing.T, service UdpService) (payloads []string) {
+
+	for _, probe := range service.Probes {
+		payload, err := base64.StdEncoding.DecodeString(probe.EncodedData)
+
+		if err != nil {
+			t.Fatalf("probe %s has invalid data: %s", probe.Slug, err)
+		}
+		payloads = append(payloads, string(payload))
+	}
+	return
+}
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+)
+
+const (
+	RADIUS_SECRET     = "testing123" // Shared secret guessed by default, that of the FreeRADIUS example client
+	RADIUS_IDENTIFIER = 0x75         // Identifier of the probes, which servers echo
+
+	RADIUS_ACCESS_REQUEST = 1
+	RADIUS_STATUS_SERVER  = 12
+
+	RADIUS_ATTRIBUTE_USER_NAME             = 1
+	RADIUS_ATTRIBUTE_USER_PASSWORD         = 2
+	RADIUS_ATTRIBUTE_NAS_IDENTIFIER        = 32
+	RADIUS_ATTRIBUTE_MESSAGE_AUTHENTICATOR = 80
+)
+
+var (
+	// RADIUS_AUTHENTICATOR is the request authenticator of the probes
+	RADIUS_AUTHENTICATOR = []byte("udpz radius auth")
+)
+
+// radiusAttribute encodes an attribute
+func radiusAttribute(kind byte, value []byte) []byte {
+	return append([]byte{kind, byte(2 + len(value))}, value...)
+}
+
+// radiusRequest encodes a request with the given attributes, followed by a
+// Message-Authenticator computed with the shared secret, which servers
+// require before answering
+func radiusRequest(code byte, secret string, attributes ...[]byte) []byte {
+
+	request := []byte{code, RADIUS_IDENTIFIER, 0, 0}
+	request = append(request, RADIUS_AUTHENTICATOR...)
+
+	for _, attribute := range attributes {
+		request = append(request, attribute...)
+	}
+	request = append(request, radiusAttribute(RADIUS_ATTRIBUTE_MESSAGE_AUTHENTICATOR, make([]byte, md5.Size))...)
+	request[2], request[3] = byte(len(request)>>8), byte(len(request))
+
+	mac := hmac.New(md5.New, []byte(secret))
+	mac.Write(request)
+	copy(request[len(request)-md5.Size:], mac.Sum(nil))
+
+	return request
+}
+
+// radiusPassword hides a password of up to 16 bytes with the shared secret
+// and the request authenticator
+func radiusPassword(password string, secret string) []byte {
+
+	hidden := make([]byte, md5.Size)
+	copy(hidden, password)
+	key := md5.Sum(append([]byte(secret), RADIUS_AUTHENTICATOR...))
+
+	for i := range hidden {
+		hidden[i] ^= key[i]
+	}
+	return hidden
+}
+
+// RADIUSProbes returns an Access-Request for the user udpz, and a
+// Status-Server request that accounting ports answer too, both signed with
+// the shared secret. Servers silently drop requests from clients they do not
+// know or that are signed with another secret, so any answer means the secret
+// was guessed.
+func RADIUSProbes(secret string) []UdpProbe {
+
+	access := radiusRequest(RADIUS_ACCESS_REQUEST, secret,
+		radiusAttribute(RADIUS_ATTRIBUTE_USER_NAME, []byte("udpz")),
+		radiusAttribute(RADIUS_ATTRIBUTE_USER_PASSWORD, radiusPassword("udpz", secret)),
+		radiusAttribute(RADIUS_ATTRIBUTE_NAS_IDENTIFIER, []byte("udpz")))
+	status := radiusRequest(RADIUS_STATUS_SERVER, secret,
+		radiusAttribute(RADIUS_ATTRIBUTE_NAS_IDENTIFIER, []byte("udpz")))
+
+	return []UdpProbe{
+		{
+			Slug:        "radius-access-request",
+			Name:        fmt.Sprintf("RADIUS Access-Request (secret %q)", secret),
+			Service:     "radius",
+			EncodedData: base64.StdEncoding.EncodeToString(access),
+		},
+		{
+			Slug:        "radius-status-server",
+			Name:        fmt.Sprintf("RADIUS Status-Server (secret %q)", secret),
+			Service:     "radius",
+			EncodedData: base64.StdEncoding.EncodeToString(status),
+		},
+	}
+}
+
+// SetRADIUSSecret returns a copy of services whose RADIUS probes are signed
+// with the shared secret instead of the default one. Services without RADIUS
+// are returned unchanged.
+func SetRADIUSSecret(services map[string]UdpService, secret string) map[string]UdpService {
+
+	service, ok := services["radius"]
+
+	if !ok {
+		return services
+	}
+	extended := make(map[string]UdpService, len(services))
+
+	for slug, other := range services {
+		extended[slug] = other
+	}
+	signed := make(map[string]UdpProbe)
+
+	for _, probe := range RADIUSProbes(secret) {
+		signed[probe.Slug] = probe
+	}
+	probes := make([]UdpProbe, 0, len(service.Probes))
+
+	for _, probe := range service.Probes {
+		if replacement, found := signed[probe.Slug]; found {
+			probe = replacement
+		}
+		probes = append(probes, probe)
+	}
+	service.Probes = probes
+	extended["radius"] = service
+
+	return extended
+}
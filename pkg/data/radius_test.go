@@ -0,0 +1,62 @@
+package data
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestRADIUSProbes(t *testing.T) {
+
+	builtin := make(map[string]UdpProbe)
+
+	for _, probe := range UDP_SERVICES["radius"].Probes {
+		builtin[probe.Slug] = probe
+	}
+
+	for _, probe := range RADIUSProbes(RADIUS_SECRET) {
+		if !reflect.DeepEqual(builtin[probe.Slug], probe) {
+			t.Errorf("got probe %+v, want the built-in %+v", probe, builtin[probe.Slug])
+		}
+		payload, err := base64.StdEncoding.DecodeString(probe.EncodedData)
+
+		if err != nil || len(payload) < 20+2+md5.Size {
+			t.Fatalf("%s: payload = %x, %v", probe.Slug, payload, err)
+		}
+
+		// The Message-Authenticator is computed over the request with its
+		// value zeroed
+		signed := payload[len(payload)-md5.Size:]
+		unsigned := append([]byte(nil), payload...)
+		copy(unsigned[len(unsigned)-md5.Size:], make([]byte, md5.Size))
+
+		mac := hmac.New(md5.New, []byte(RADIUS_SECRET))
+		mac.Write(unsigned)
+
+		if !bytes.Equal(signed, mac.Sum(nil)) {
+			t.Errorf("%s: Message-Authenticator %x does not verify", probe.Slug, signed)
+		}
+	}
+}
+
+func TestSetRADIUSSecret(t *testing.T) {
+
+	services := map[string]UdpService{
+		"radius": {Slug: "radius", Probes: []UdpProbe{{Slug: "radius-generic"}, {Slug: "radius-access-request"}}},
+		"dns":    {Slug: "dns"},
+	}
+	signed := SetRADIUSSecret(services, "s3cret")
+
+	if probes := signed["radius"].Probes; len(probes) != 2 || probes[0].Slug != "radius-generic" || probes[1].Name != `RADIUS Access-Request (secret "s3cret")` {
+		t.Errorf("got RADIUS probes %+v, want the Access-Request signed with the secret", probes)
+	}
+	if probes := services["radius"].Probes; probes[1].Name != "" {
+		t.Errorf("original services were modified: %+v", probes)
+	}
+	if _, ok := signed["dns"]; !ok {
+		t.Errorf("got services %+v, want other services kept", signed)
+	}
+}
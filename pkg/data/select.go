@@ -0,0 +1,180 @@
+package data
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParsePorts parses an nmap-style port specification such as
+// "53,161,500-520,U:1194" into a set of UDP ports. Protocol prefixes apply to
+// every following entry until the next prefix, and entries under a non-UDP
+// prefix (T:, S:) are ignored.
+func ParsePorts(spec string) (ports map[uint16]bool, err error) {
+
+	ports = make(map[uint16]bool)
+	udp := true
+
+	for _, entry := range strings.Split(spec, ",") {
+
+		entry = strings.TrimSpace(entry)
+
+		if proto, rest, ok := strings.Cut(entry, ":"); ok {
+			switch strings.ToUpper(proto) {
+			case "U":
+				udp = true
+			case "T", "S":
+				udp = false
+			default:
+				return nil, errors.New("invalid port protocol: " + proto)
+			}
+			entry = rest
+		}
+		if entry == "" {
+			continue
+		}
+
+		var low, high uint64 = 1, 65535
+
+		if lowSource, highSource, isRange := strings.Cut(entry, "-"); isRange {
+			if lowSource != "" {
+				if low, err = strconv.ParseUint(lowSource, 10, 16); err != nil {
+					return nil, errors.New("invalid port: " + lowSource)
+				}
+			}
+			if highSource != "" {
+				if high, err = strconv.ParseUint(highSource, 10, 16); err != nil {
+					return nil, errors.New("invalid port: " + highSource)
+				}
+			}
+		} else if low, err = strconv.ParseUint(entry, 10, 16); err == nil {
+			high = low
+		} else {
+			return nil, errors.New("invalid port: " + entry)
+		}
+		if low > high {
+			return nil, errors.New("invalid port range: " + entry)
+		}
+		if !udp {
+			continue
+		}
+		for port := low; port <= high; port++ {
+			ports[uint16(port)] = true
+		}
+	}
+	if len(ports) == 0 {
+		return nil, errors.New("no UDP ports in specification: " + spec)
+	}
+	return
+}
+
+// FilterPorts returns the services that listen on any of the given ports. Each
+// returned service only retains its matching ports.
+func FilterPorts(services map[string]UdpService, ports map[uint16]bool) map[string]UdpService {
+
+	filtered := make(map[string]UdpService)
+
+	for slug, service := range services {
+
+		var servicePorts []uint16
+
+		for _, port := range service.Ports {
+			if ports[port] {
+				servicePorts = append(servicePorts, port)
+			}
+		}
+		if len(servicePorts) > 0 {
+			service.Ports = servicePorts
+			filtered[slug] = service
+		}
+	}
+	return filtered
+}
+
+// FilterServices returns the services whose slug or tags match any of the given
+// names. An error is returned if a name matches nothing.
+func FilterServices(services map[string]UdpService, names []string) (map[string]UdpService, error) {
+
+	filtered := make(map[string]UdpService)
+
+	for _, name := range names {
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		matched := false
+
+		for slug, service := range services {
+			if serviceMatches(slug, service, name) {
+				filtered[slug] = service
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, errors.New("unknown service or tag: " + name)
+		}
+	}
+	return filtered, nil
+}
+
+func serviceMatches(slug string, service UdpService, name string) bool {
+	if slug == name || service.Slug == name {
+		return true
+	}
+	for _, tag := range service.Tags {
+		if tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupService returns the service registered under slug, or the service
+// whose own slug it is when it is registered under another name
+func LookupService(services map[string]UdpService, slug string) (UdpService, bool) {
+
+	if service, ok := services[slug]; ok {
+		return service, true
+	}
+	for _, service := range services {
+		if service.Slug == slug {
+			return service, true
+		}
+	}
+	return UdpService{}, false
+}
+
+// AssignPorts returns a copy of the services with every service probing the
+// given ports instead of its default ports.
+func AssignPorts(services map[string]UdpService, ports map[uint16]bool) map[string]UdpService {
+
+	assigned := make(map[string]UdpService)
+	portList := make([]uint16, 0, len(ports))
+
+	for port := range ports {
+		portList = append(portList, port)
+	}
+	sort.Slice(portList, func(i, j int) bool {
+		return portList[i] < portList[j]
+	})
+
+	for slug, service := range services {
+		service.Ports = portList
+		assigned[slug] = service
+	}
+	return assigned
+}
+
+// LimitProbes returns a copy of the services with each one keeping only its
+// first n probes
+func LimitProbes(services map[string]UdpService, n int) map[string]UdpService {
+
+	limited := make(map[string]UdpService)
+
+	for slug, service := range services {
+		if len(service.Probes) > n {
+			service.Probes = service.Probes[:n:n]
+		}
+		limited[slug] = service
+	}
+	return limited
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+
+	tests := []struct {
+		spec    string
+		ports   []uint16
+		invalid bool
+	}{
+		{spec: "53", ports: []uint16{53}},
+		{spec: "53,161, 500", ports: []uint16{53, 161, 500}},
+		{spec: "10-13", ports: []uint16{10, 11, 12, 13}},
+		{spec: "-3", ports: []uint16{1, 2, 3}},
+		{spec: "65533-", ports: []uint16{65533, 65534, 65535}},
+		{spec: "U:53,T:80,443", ports: []uint16{53}},
+		{spec: "T:80,U:123,161", ports: []uint16{123, 161}},
+		{spec: "u:7", ports: []uint16{7}},
+		{spec: "53,,54", ports: []uint16{53, 54}},
+		{spec: "", invalid: true},
+		{spec: "T:80", invalid: true},
+		{spec: "X:80", invalid: true},
+		{spec: "dns", invalid: true},
+		{spec: "65536", invalid: true},
+		{spec: "20-10", invalid: true},
+		{spec: "1-x", invalid: true},
+	}
+
+	for _, test := range tests {
+		ports, err := ParsePorts(test.spec)
+
+		if test.invalid {
+			if err == nil {
+				t.Errorf("ParsePorts(%q) = %v, want an error", test.spec, ports)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePorts(%q) failed: %s", test.spec, err)
+			continue
+		}
+		if got := sortedPorts(ports); !reflect.DeepEqual(got, test.ports) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", test.spec, got, test.ports)
+		}
+	}
+}
+
+func TestLookupService(t *testing.T) {
+
+	services := map[string]UdpService{
+		"rdp": {Slug: "rdpudp"},
+		"dns": {Slug: "dns"},
+	}
+
+	tests := []struct {
+		name string
+		slug string
+		ok   bool
+	}{
+		{name: "rdp", slug: "rdpudp", ok: true},
+		{name: "rdpudp", slug: "rdpudp", ok: true},
+		{name: "dns", slug: "dns", ok: true},
+		{name: "snmp", ok: false},
+	}
+
+	for _, test := range tests {
+		service, ok := LookupService(services, test.name)
+
+		if ok != test.ok || service.Slug != test.slug {
+			t.Errorf("LookupService(%q) = %q, %v, want %q, %v", test.name, service.Slug, ok, test.slug, test.ok)
+		}
+	}
+}
+
+func TestLimitProbes(t *testing.T) {
+
+	services := map[string]UdpService{
+		"dns":  {Slug: "dns", Probes: []UdpProbe{{Slug: "dns-a"}, {Slug: "dns-b"}, {Slug: "dns-c"}}},
+		"snmp": {Slug: "snmp", Probes: []UdpProbe{{Slug: "snmp-a"}}},
+	}
+
+	tests := []struct {
+		n    int
+		want map[string]int
+	}{
+		{n: 1, want: map[string]int{"dns": 1, "snmp": 1}},
+		{n: 2, want: map[string]int{"dns": 2, "snmp": 1}},
+		{n: 5, want: map[string]int{"dns": 3, "snmp": 1}},
+	}
+
+	for _, test := range tests {
+		limited := LimitProbes(services, test.n)
+
+		for slug, count := range test.want {
+			if got := len(limited[slug].Probes); got != count || limited[slug].Probes[0].Slug != slug+"-a" {
+				t.Errorf("LimitProbes(%d)[%q] = %d probes, want %d", test.n, slug, got, count)
+			}
+		}
+	}
+	if len(services["dns"].Probes) != 3 {
+		t.Errorf("LimitProbes changed the given services")
+	}
+}
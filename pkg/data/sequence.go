@@ -0,0 +1,169 @@
+package data
+
+import (
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	// STEP_PLACEHOLDER matches the {{name}} placeholders of step templates
+	STEP_PLACEHOLDER = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// UdpStep is a request a probe sends in answer to the previous response of
+// its exchange. Its template is hex in which {{name}} placeholders are
+// replaced with the bytes extracted from that response, or from an earlier
+// one, such as the block number a TFTP ACK repeats.
+type UdpStep struct {
+	Extract  []UdpExtract `yaml:"extract,omitempty" json:"extract,omitempty"`
+	Template string       `yaml:"template" json:"template"`
+}
+
+// UdpExtract names a field of a response, given either by its offset and
+// length (to the end of the response if zero) or by the first group of a
+// pattern matched the way UdpMatch patterns are
+type UdpExtract struct {
+	Name    string `yaml:"name" json:"name"`
+	Offset  int    `yaml:"offset,omitempty" json:"offset,omitempty"`
+	Length  int    `yaml:"length,omitempty" json:"length,omitempty"`
+	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+}
+
+// Validate reports whether the template is hex once its placeholders are
+// removed, and whether every placeholder names a field extracted by this step
+// or an earlier one
+func (s UdpStep) Validate(names map[string]bool) error {
+
+	for _, extract := range s.Extract {
+
+		if extract.Name == "" {
+			return errors.New("step field is missing a name")
+		}
+		if extract.Offset < 0 || extract.Length < 0 {
+			return errors.New("negative offset or length in step field: " + extract.Name)
+		}
+		if extract.Pattern != "" {
+			compiled, err := compilePattern(extract.Pattern)
+
+			if err != nil {
+				return err
+			}
+			if compiled.NumSubexp() < 1 {
+				return errors.New("pattern has no group to extract in step field: " + extract.Name)
+			}
+		}
+		names[extract.Name] = true
+	}
+	parts, err := parseTemplate(s.Template)
+
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if part.name != "" && !names[part.name] {
+			return errors.New("step template uses an unknown field: " + part.name)
+		}
+	}
+	return nil
+}
+
+// Request extracts the fields of the step from the response into the values
+// and returns the request its template gives. It returns false if a field is
+// missing from the response.
+func (s UdpStep) Request(response []byte, values map[string][]byte) ([]byte, bool) {
+
+	for _, extract := range s.Extract {
+
+		value, ok := extract.From(response)
+
+		if !ok {
+			return nil, false
+		}
+		values[extract.Name] = value
+	}
+	parts, err := parseTemplate(s.Template)
+
+	if err != nil {
+		return nil, false
+	}
+	var request []byte
+
+	for _, part := range parts {
+
+		if part.name == "" {
+			request = append(request, part.literal...)
+			continue
+		}
+		value, ok := values[part.name]
+
+		if !ok {
+			return nil, false
+		}
+		request = append(request, value...)
+	}
+	return request, true
+}
+
+// templatePart is either the bytes of a run of hex or the name of a field
+type templatePart struct {
+	literal []byte
+	name    string
+}
+
+// parseTemplate splits a step template into runs of hex and placeholders
+func parseTemplate(template string) (parts []templatePart, err error) {
+
+	last := 0
+
+	for _, bounds := range STEP_PLACEHOLDER.FindAllStringSubmatchIndex(template, -1) {
+
+		var literal []byte
+
+		if literal, err = decodeHex(template[last:bounds[0]]); err != nil {
+			return
+		}
+		parts = append(parts, templatePart{literal: literal}, templatePart{name: template[bounds[2]:bounds[3]]})
+		last = bounds[1]
+	}
+	literal, err := decodeHex(template[last:])
+
+	return append(parts, templatePart{literal: literal}), err
+}
+
+// decodeHex decodes hex separated by any whitespace
+func decodeHex(text string) ([]byte, error) {
+	return hex.DecodeString(strings.Join(strings.Fields(text), ""))
+}
+
+// From returns the field of the response
+func (e UdpExtract) From(response []byte) ([]byte, bool) {
+
+	if e.Pattern != "" {
+		compiled, err := compilePattern(e.Pattern)
+
+		if err != nil {
+			return nil, false
+		}
+		match := compiled.FindStringSubmatch(latin1(response))
+
+		if len(match) < 2 {
+			return nil, false
+		}
+		// Each character of the latin1 string stands for a byte
+		value := make([]byte, 0, len(match[1]))
+
+		for _, char := range match[1] {
+			value = append(value, byte(char))
+		}
+		return value, true
+	}
+	if e.Offset > len(response) || (e.Length > 0 && e.Offset+e.Length > len(response)) {
+		return nil, false
+	}
+	if e.Length == 0 {
+		return append([]byte(nil), response[e.Offset:]...), true
+	}
+	return append([]byte(nil), response[e.Offset:e.Offset+e.Length]...), true
+}
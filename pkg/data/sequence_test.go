@@ -0,0 +1,154 @@
+package data
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStepRequest(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		step     UdpStep
+		response []byte
+		values   map[string][]byte
+		want     []byte
+		ok       bool
+	}{
+		{
+			"TFTP ACK",
+			UdpStep{Extract: []UdpExtract{{Name: "block", Offset: 2, Length: 2}}, Template: "0004 {{block}}"},
+			[]byte("\x00\x03\x00\x01data"),
+			nil,
+			[]byte("\x00\x04\x00\x01"),
+			true,
+		},
+		{
+			"pattern with spaced placeholder",
+			UdpStep{Extract: []UdpExtract{{Name: "cookie", Pattern: `^OK (\w+)`}}, Template: "434f4f4b494520{{ cookie }}0a"},
+			[]byte("OK abc123\n"),
+			nil,
+			[]byte("COOKIE abc123\n"),
+			true,
+		},
+		{
+			"raw bytes",
+			UdpStep{Extract: []UdpExtract{{Name: "id", Pattern: `^\xff(..)`}}, Template: "{{id}}"},
+			[]byte("\xff\x80\xfe"),
+			nil,
+			[]byte("\x80\xfe"),
+			true,
+		},
+		{
+			"earlier field",
+			UdpStep{Template: "01{{session}}"},
+			[]byte("ignored"),
+			map[string][]byte{"session": []byte("\x02\x03")},
+			[]byte("\x01\x02\x03"),
+			true,
+		},
+		{
+			"rest of response",
+			UdpStep{Extract: []UdpExtract{{Name: "tail", Offset: 4}}, Template: "{{tail}}"},
+			[]byte("headbody"),
+			nil,
+			[]byte("body"),
+			true,
+		},
+		{
+			"short response",
+			UdpStep{Extract: []UdpExtract{{Name: "block", Offset: 2, Length: 2}}, Template: "0004 {{block}}"},
+			[]byte("\x00\x05"),
+			nil,
+			nil,
+			false,
+		},
+		{
+			"pattern miss",
+			UdpStep{Extract: []UdpExtract{{Name: "cookie", Pattern: `^OK (\w+)`}}, Template: "{{cookie}}"},
+			[]byte("ERR"),
+			nil,
+			nil,
+			false,
+		},
+	}
+	for _, test := range tests {
+
+		values := make(map[string][]byte)
+
+		for name, value := range test.values {
+			values[name] = value
+		}
+		request, ok := test.step.Request(test.response, values)
+
+		if ok != test.ok || !bytes.Equal(request, test.want) {
+			t.Errorf("%s: got %q, %v, want %q, %v", test.name, request, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestStepValidate(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		steps []UdpStep
+		ok    bool
+	}{
+		{"valid", []UdpStep{{Extract: []UdpExtract{{Name: "block", Offset: 2, Length: 2}}, Template: "0004 {{block}}"}, {Template: "0004{{block}}"}}, true},
+		{"unknown field", []UdpStep{{Template: "0004 {{block}}"}}, false},
+		{"bad hex", []UdpStep{{Template: "0z"}}, false},
+		{"split byte", []UdpStep{{Extract: []UdpExtract{{Name: "a"}}, Template: "0{{a}}0"}}, false},
+		{"no group", []UdpStep{{Extract: []UdpExtract{{Name: "a", Pattern: "^OK"}}, Template: "{{a}}"}}, false},
+		{"no name", []UdpStep{{Extract: []UdpExtract{{Offset: 1}}, Template: "00"}}, false},
+	}
+	for _, test := range tests {
+
+		var err error
+
+		names := make(map[string]bool)
+
+		for _, step := range test.steps {
+			if err = step.Validate(names); err != nil {
+				break
+			}
+		}
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got %v, want ok %v", test.name, err, test.ok)
+		}
+	}
+}
+
+func TestReadProbeFileSteps(t *testing.T) {
+
+	services, err := ReadProbeFile(strings.NewReader(`
+services:
+  - slug: example
+    ports: [9999]
+    probes:
+      - slug: example-hello
+        hex: "48454c4c4f0a"
+        steps:
+          - extract:
+              - name: cookie
+                pattern: '^OK (\w+)'
+            template: "434f4f4b494520 {{cookie}} 0a"
+`))
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if steps := services["example"].Probes[0].Steps; len(steps) != 1 || steps[0].Extract[0].Name != "cookie" {
+		t.Errorf("got steps %+v, want the cookie step", steps)
+	}
+	if _, err = ReadProbeFile(strings.NewReader(`
+services:
+  - slug: example
+    probes:
+      - slug: example-hello
+        hex: "00"
+        steps:
+          - template: "{{cookie}}"
+`)); err == nil {
+		t.Errorf("got no error for a step using an unknown field")
+	}
+}
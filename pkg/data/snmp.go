@@ -0,0 +1,142 @@
+package data
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	SNMP_COMMUNITY_REQUEST_ID = 0x75647a00 // Request ID of the first community probe, incremented for each
+)
+
+var (
+	// SNMP_COMMUNITY_OIDS are requested by community probes, encoded: sysDescr.0
+	// and sysName.0
+	SNMP_COMMUNITY_OIDS = [][]byte{
+		{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00},
+		{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x05, 0x00},
+	}
+)
+
+// ber encodes an element with the given contents
+func ber(tag byte, contents ...[]byte) []byte {
+
+	var value []byte
+
+	for _, content := range contents {
+		value = append(value, content...)
+	}
+	length := len(value)
+
+	switch {
+	case length < 0x80:
+		return append([]byte{tag, byte(length)}, value...)
+	case length < 0x100:
+		return append([]byte{tag, 0x81, byte(length)}, value...)
+	default:
+		return append([]byte{tag, 0x82, byte(length >> 8), byte(length)}, value...)
+	}
+}
+
+// snmpGetRequest encodes an SNMPv1 get-request for sysDescr.0 and sysName.0
+func snmpGetRequest(community string, requestID uint32) []byte {
+
+	var bindings [][]byte
+
+	for _, oid := range SNMP_COMMUNITY_OIDS {
+		bindings = append(bindings, ber(0x30, ber(0x06, oid), []byte{0x05, 0x00}))
+	}
+	return ber(0x30,
+		[]byte{0x02, 0x01, 0x00},
+		ber(0x04, []byte(community)),
+		ber(0xa0,
+			ber(0x02, []byte{byte(requestID >> 24), byte(requestID >> 16), byte(requestID >> 8), byte(requestID)}),
+			[]byte{0x02, 0x01, 0x00},
+			[]byte{0x02, 0x01, 0x00},
+			ber(0x30, bindings...)))
+}
+
+// SNMPCommunityProbes returns an SNMPv1 get-request probe for sysDescr.0 and
+// sysName.0 with each community string. Responding agents echo the community,
+// so results show which one they accepted.
+func SNMPCommunityProbes(communities []string) []UdpProbe {
+
+	probes := make([]UdpProbe, 0, len(communities))
+
+	for i, community := range communities {
+		probes = append(probes, UdpProbe{
+			Slug:        fmt.Sprintf("snmp-community-%d", i+1),
+			Name:        fmt.Sprintf("SNMPv1 get-request (community %q)", community),
+			Service:     "snmp",
+			EncodedData: base64.StdEncoding.EncodeToString(snmpGetRequest(community, SNMP_COMMUNITY_REQUEST_ID+uint32(i))),
+		})
+	}
+	return probes
+}
+
+// AddSNMPCommunities returns a copy of services whose SNMP service also tries
+// each community string. Services without SNMP are returned unchanged.
+func AddSNMPCommunities(services map[string]UdpService, communities []string) map[string]UdpService {
+
+	service, ok := services["snmp"]
+
+	if !ok || len(communities) == 0 {
+		return services
+	}
+	extended := make(map[string]UdpService, len(services))
+
+	for slug, other := range services {
+		extended[slug] = other
+	}
+	service.Probes = append(append([]UdpProbe(nil), service.Probes...), SNMPCommunityProbes(communities)...)
+	extended["snmp"] = service
+
+	return extended
+}
+
+// LoadCommunities reads community strings from a file, one per line
+func LoadCommunities(path string) (communities []string, err error) {
+	return loadList(path)
+}
+
+// ReadCommunities reads community strings, one per line. Blank lines, lines
+// starting with # and repeated communities are skipped.
+func ReadCommunities(reader io.Reader) (communities []string, err error) {
+	return readList(reader)
+}
+
+// loadList reads a list from a file, one entry per line
+func loadList(path string) (list []string, err error) {
+
+	var file *os.File
+
+	if file, err = os.Open(path); err != nil {
+		return
+	}
+	defer file.Close()
+
+	return readList(file)
+}
+
+// readList reads a list, one entry per line. Blank lines, lines starting with
+// # and repeated entries are skipped.
+func readList(reader io.Reader) (list []string, err error) {
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(reader)
+
+	for scanner.Scan() {
+		entry := strings.TrimRight(scanner.Text(), "\r")
+
+		if strings.TrimSpace(entry) == "" || strings.HasPrefix(entry, "#") || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		list = append(list, entry)
+	}
+	return list, scanner.Err()
+}
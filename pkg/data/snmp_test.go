@@ -0,0 +1,66 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSNMPCommunityProbes(t *testing.T) {
+
+	probes := SNMPCommunityProbes([]string{"public", "private"})
+
+	if len(probes) != 2 {
+		t.Fatalf("got %d probes, want 2", len(probes))
+	}
+	if probes[1].Slug != "snmp-community-2" || probes[1].Name != `SNMPv1 get-request (community "private")` || probes[1].Service != "snmp" {
+		t.Errorf("got probe %+v", probes[1])
+	}
+
+	// A get-request for sysDescr.0 and sysName.0 with the second request ID
+	want := "3038020100040770726976617465a02a020475647a01020100020100301c300c06082b060102010101000500300c06082b060102010105000500"
+
+	if payload, err := base64.StdEncoding.DecodeString(probes[1].EncodedData); err != nil || hex.EncodeToString(payload) != want {
+		t.Errorf("payload = %x, want %s", payload, want)
+	}
+}
+
+func TestAddSNMPCommunities(t *testing.T) {
+
+	services := map[string]UdpService{
+		"snmp": {Slug: "snmp", Probes: []UdpProbe{{Slug: "snmp-v1"}}},
+		"dns":  {Slug: "dns"},
+	}
+	extended := AddSNMPCommunities(services, []string{"private"})
+
+	if probes := extended["snmp"].Probes; len(probes) != 2 || probes[1].Slug != "snmp-community-1" {
+		t.Errorf("got SNMP probes %+v, want the community probe appended", probes)
+	}
+	if len(services["snmp"].Probes) != 1 {
+		t.Errorf("original services were modified")
+	}
+	if _, ok := extended["dns"]; !ok {
+		t.Errorf("other services were dropped")
+	}
+
+	withoutSNMP := map[string]UdpService{"dns": {Slug: "dns"}}
+
+	if got := AddSNMPCommunities(withoutSNMP, []string{"private"}); !reflect.DeepEqual(got, withoutSNMP) {
+		t.Errorf("got %+v without an SNMP service, want it unchanged", got)
+	}
+}
+
+func TestReadCommunities(t *testing.T) {
+
+	input := "public\r\n\n# defaults\nprivate\n  \npublic\nread only\n"
+	communities, err := ReadCommunities(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("ReadCommunities failed: %s", err)
+	}
+	if want := []string{"public", "private", "read only"}; !reflect.DeepEqual(communities, want) {
+		t.Errorf("got %q, want %q", communities, want)
+	}
+}
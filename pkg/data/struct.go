@@ -13,8 +13,21 @@ type UdpService struct {
 }
 
 type UdpProbe struct {
-	Slug        string `yaml:"slug" json:"slug"`
-	Name        string `yaml:"name" json:"name"`
-	Service     string `yaml:"service" json:"service"`
-	EncodedData string `yaml:"data" json:"data"`
+	Slug        string     `yaml:"slug" json:"slug"`
+	Name        string     `yaml:"name" json:"name"`
+	Service     string     `yaml:"service" json:"service"`
+	EncodedData string     `yaml:"data" json:"data"`
+	Matches     []UdpMatch `yaml:"matches,omitempty" json:"matches,omitempty"`
+	Steps       []UdpStep  `yaml:"steps,omitempty" json:"steps,omitempty"`
+}
+
+// UdpMatch is a rule a response must satisfy to be attributed to a probe's
+// service. A probe without rules accepts any response. Bytes compares the
+// response at Offset with hex, under an optional mask of the same length.
+type UdpMatch struct {
+	Pattern   string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	MinLength int    `yaml:"min_length,omitempty" json:"min_length,omitempty"`
+	Offset    int    `yaml:"offset,omitempty" json:"offset,omitempty"`
+	Bytes     string `yaml:"bytes,omitempty" json:"bytes,omitempty"`
+	Mask      string `yaml:"mask,omitempty" json:"mask,omitempty"`
 }
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const (
+	TFTP_READ_REQUEST = 1
+	TFTP_FILE_PREFIX  = "tftp-file-" // Slug prefix of the probes reading a file, numbered from 1
+)
+
+var (
+	// TFTP_FILES are the files read by default: the configurations network
+	// devices back up to or boot from TFTP servers
+	TFTP_FILES = []string{
+		"running-config",
+		"startup-config",
+	}
+)
+
+// tftpReadRequest encodes a read request for a file in octet mode
+func tftpReadRequest(file string) []byte {
+
+	request := []byte{0, TFTP_READ_REQUEST}
+	request = append(request, file...)
+	request = append(request, 0)
+	request = append(request, "octet"...)
+
+	return append(request, 0)
+}
+
+// TFTPFileProbes returns a TFTP read request probe for each file. Servers
+// answer with the first block of the files they serve, and with an error
+// otherwise.
+func TFTPFileProbes(files []string) []UdpProbe {
+
+	probes := make([]UdpProbe, 0, len(files))
+
+	for i, file := range files {
+		probes = append(probes, UdpProbe{
+			Slug:        fmt.Sprintf("%s%d", TFTP_FILE_PREFIX, i+1),
+			Name:        fmt.Sprintf("TFTP read request (%q)", file),
+			Service:     "tftp",
+			EncodedData: base64.StdEncoding.EncodeToString(tftpReadRequest(file)),
+		})
+	}
+	return probes
+}
+
+// SetTFTPFiles returns a copy of services whose TFTP service reads the files
+// instead of the default ones. Services without TFTP are returned unchanged.
+func SetTFTPFiles(services map[string]UdpService, files []string) map[string]UdpService {
+
+	service, ok := services["tftp"]
+
+	if !ok {
+		return services
+	}
+	extended := make(map[string]UdpService, len(services))
+
+	for slug, other := range services {
+		extended[slug] = other
+	}
+	probes := make([]UdpProbe, 0, len(service.Probes)+len(files))
+
+	for _, probe := range service.Probes {
+		if !strings.HasPrefix(probe.Slug, TFTP_FILE_PREFIX) {
+			probes = append(probes, probe)
+		}
+	}
+	service.Probes = append(probes, TFTPFileProbes(files)...)
+	extended["tftp"] = service
+
+	return extended
+}
+
+// LoadTFTPFiles reads file names from a file, one per line
+func LoadTFTPFiles(path string) (files []string, err error) {
+	return loadList(path)
+}
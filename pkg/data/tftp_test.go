@@ -0,0 +1,39 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTFTPFileProbes(t *testing.T) {
+
+	var builtin []UdpProbe
+
+	for _, probe := range UDP_SERVICES["tftp"].Probes {
+		if probe.Slug != "tftp-read" {
+			builtin = append(builtin, probe)
+		}
+	}
+	if probes := TFTPFileProbes(TFTP_FILES); !reflect.DeepEqual(probes, builtin) {
+		t.Errorf("got probes %+v, want the built-in %+v", probes, builtin)
+	}
+}
+
+func TestSetTFTPFiles(t *testing.T) {
+
+	services := map[string]UdpService{
+		"tftp": {Slug: "tftp", Probes: []UdpProbe{{Slug: "tftp-read"}, {Slug: "tftp-file-1"}, {Slug: "tftp-file-2"}}},
+		"dns":  {Slug: "dns"},
+	}
+	extended := SetTFTPFiles(services, []string{"pxelinux.0"})
+
+	if probes := extended["tftp"].Probes; len(probes) != 2 || probes[0].Slug != "tftp-read" || probes[1].Name != `TFTP read request ("pxelinux.0")` {
+		t.Errorf("got TFTP probes %+v, want the default file probes replaced", probes)
+	}
+	if probes := services["tftp"].Probes; len(probes) != 3 {
+		t.Errorf("original services were modified: %+v", probes)
+	}
+	if _, ok := extended["dns"]; !ok {
+		t.Errorf("got services %+v, want other services kept", extended)
+	}
+}
@@ -0,0 +1,126 @@
+package proto
+
+import (
+	"bytes"
+	"strconv"
+)
+
+const (
+	A2S_HEADER_LENGTH    = 5
+	A2S_CHALLENGE_LENGTH = 9
+	A2S_INFO_REQUEST     = 'T'
+	A2S_INFO_RESPONSE    = 'I'
+	A2S_CHALLENGE        = 'A'
+	A2S_THE_SHIP         = 2400
+)
+
+var (
+	// A2S_PREFIX starts the packets of the Source query protocol that fit in a
+	// single datagram
+	A2S_PREFIX = []byte{0xff, 0xff, 0xff, 0xff}
+	// A2S_INFO_PAYLOAD ends A2S_INFO queries, before the challenge
+	A2S_INFO_PAYLOAD = []byte("Source Engine Query\x00")
+	// A2S_SERVER_TYPES maps the server types of A2S_INFO responses to their
+	// names
+	A2S_SERVER_TYPES = map[byte]string{
+		'd': "dedicated",
+		'l': "listen",
+		'p': "SourceTV",
+	}
+	// A2S_ENVIRONMENTS maps the operating systems of A2S_INFO responses to
+	// their names
+	A2S_ENVIRONMENTS = map[byte]string{
+		'l': "Linux",
+		'w': "Windows",
+		'm': "macOS",
+		'o': "macOS",
+	}
+)
+
+// ParseA2S reports the name, map, game, player counts and version of Source
+// and GoldSource engine game servers answering an A2S_INFO query. Servers that
+// answer with a challenge first are queried again with it.
+func ParseA2S(request []byte, response []byte) (info Info, ok bool) {
+
+	// The challenge precedes the answer to the request sent with it
+	if a2sChallenge(response) != nil {
+		response = response[A2S_CHALLENGE_LENGTH:]
+	}
+	if len(response) < A2S_HEADER_LENGTH+2 || !bytes.HasPrefix(response, A2S_PREFIX) || response[4] != A2S_INFO_RESPONSE {
+		return
+	}
+	fields := response[A2S_HEADER_LENGTH+1:]
+	var values [4]string
+
+	for i := range values {
+		value, rest, found := bytes.Cut(fields, []byte{0})
+
+		if !found {
+			return
+		}
+		values[i], fields = printable(string(value)), rest
+	}
+	info.Banner = values[0]
+	info.setMetadata("map", values[1])
+	info.setMetadata("folder", values[2])
+	info.setMetadata("game", values[3])
+
+	// The Steam application ID, then the player counts, server type,
+	// environment, visibility and VAC status
+	if len(fields) < 9 {
+		return info, true
+	}
+	app := int(fields[0]) | int(fields[1])<<8
+
+	info.setMetadata("app_id", strconv.Itoa(app))
+	info.setMetadata("players", strconv.Itoa(int(fields[2])))
+	info.setMetadata("max_players", strconv.Itoa(int(fields[3])))
+	info.setMetadata("bots", strconv.Itoa(int(fields[4])))
+	info.setMetadata("server_type", A2S_SERVER_TYPES[fields[5]])
+	info.setMetadata("environment", A2S_ENVIRONMENTS[fields[6]])
+
+	if fields[7] != 0 {
+		info.setMetadata("password", "true")
+	}
+	if fields[8] != 0 {
+		info.setMetadata("vac", "true")
+	}
+	fields = fields[9:]
+
+	// The Ship gives its game mode, witness count and duration
+	if app == A2S_THE_SHIP && len(fields) >= 3 {
+		fields = fields[3:]
+	}
+	if version, _, found := bytes.Cut(fields, []byte{0}); found {
+		info.Version = printable(string(version))
+	}
+	return info, true
+}
+
+// NextA2S resends an A2S_INFO query with the challenge the server answered
+// the first one with
+func NextA2S(request []byte, response []byte) (next []byte, ok bool) {
+
+	challenge := a2sChallenge(response)
+
+	// Only the query without a challenge is resent
+	if challenge == nil || len(request) < A2S_HEADER_LENGTH || request[4] != A2S_INFO_REQUEST || !bytes.HasSuffix(request, A2S_INFO_PAYLOAD) {
+		return nil, false
+	}
+	return append(append([]byte(nil), request...), challenge...), true
+}
+
+// DoneA2S reports whether the responses hold more than a challenge
+func DoneA2S(responses []byte) bool {
+	return a2sChallenge(responses) == nil || len(responses) > A2S_CHALLENGE_LENGTH
+}
+
+// a2sChallenge returns the challenge of an S2C_CHALLENGE response, if the
+// response starts with one
+func a2sChallenge(response []byte) []byte {
+
+	if len(response) < A2S_CHALLENGE_LENGTH || !bytes.HasPrefix(response, A2S_PREFIX) || response[4] != A2S_CHALLENGE {
+		return nil
+	}
+	return response[A2S_HEADER_LENGTH:A2S_CHALLENGE_LENGTH]
+}
@@ -0,0 +1,104 @@
+package proto
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// a2sInfo builds an A2S_INFO response from the strings and the bytes that
+// follow them
+func a2sInfo(name, game string, app int, rest string) []byte {
+
+	response := append([]byte{0xff, 0xff, 0xff, 0xff, A2S_INFO_RESPONSE, 17}, name+"\x00de_dust2\x00cstrike\x00"+game+"\x00"...)
+	response = append(response, byte(app), byte(app>>8), 12, 32, 2, 'd', 'l', 0, 1)
+	return append(response, rest...)
+}
+
+func TestParseA2S(t *testing.T) {
+
+	request := []byte("\xff\xff\xff\xffTSource Engine Query\x00")
+	challenge := []byte{0xff, 0xff, 0xff, 0xff, A2S_CHALLENGE, 0x12, 0x34, 0x56, 0x78}
+	metadata := map[string]string{
+		"map":         "de_dust2",
+		"folder":      "cstrike",
+		"game":        "Counter-Strike 2",
+		"app_id":      "730",
+		"players":     "12",
+		"max_players": "32",
+		"bots":        "2",
+		"server_type": "dedicated",
+		"environment": "Linux",
+		"vac":         "true",
+	}
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "info",
+			response: a2sInfo("udpz ^1test", "Counter-Strike 2", 730, "1.40.2.4\x00"),
+			info:     Info{Version: "1.40.2.4", Banner: "udpz ^1test", Metadata: metadata},
+			ok:       true,
+		},
+		{
+			name:     "info after challenge",
+			response: append(append([]byte(nil), challenge...), a2sInfo("udpz ^1test", "Counter-Strike 2", 730, "1.40.2.4\x00")...),
+			info:     Info{Version: "1.40.2.4", Banner: "udpz ^1test", Metadata: metadata},
+			ok:       true,
+		},
+		{
+			name:     "the ship",
+			response: a2sInfo("ship", "The Ship", A2S_THE_SHIP, "\x01\x03\xb41.0.0.4\x00"),
+			info: Info{Version: "1.0.0.4", Banner: "ship", Metadata: map[string]string{
+				"map": "de_dust2", "folder": "cstrike", "game": "The Ship", "app_id": "2400", "players": "12", "max_players": "32",
+				"bots": "2", "server_type": "dedicated", "environment": "Linux", "vac": "true",
+			}},
+			ok: true,
+		},
+		{
+			name:     "challenge only",
+			response: challenge,
+			ok:       false,
+		},
+		{
+			name:     "truncated name",
+			response: []byte("\xff\xff\xff\xffI\x11udpz"),
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseA2S(request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+	if DoneA2S(challenge) {
+		t.Errorf("challenge: got done, want not done")
+	}
+	if !DoneA2S(append(challenge, a2sInfo("udpz", "", 730, "")...)) {
+		t.Errorf("challenge and info: got not done, want done")
+	}
+}
+
+func TestNextA2S(t *testing.T) {
+
+	request := []byte("\xff\xff\xff\xffTSource Engine Query\x00")
+	challenge := []byte{0xff, 0xff, 0xff, 0xff, A2S_CHALLENGE, 0x12, 0x34, 0x56, 0x78}
+	want := append(append([]byte(nil), request...), 0x12, 0x34, 0x56, 0x78)
+
+	if next, ok := NextA2S(request, challenge); !ok || !bytes.Equal(next, want) {
+		t.Errorf("challenge: got %x, %v, want %x, true", next, ok, want)
+	}
+	if next, ok := NextA2S(want, challenge); ok {
+		t.Errorf("challenge to challenged query: got %x, true, want false", next)
+	}
+	if next, ok := NextA2S(request, a2sInfo("udpz", "", 730, "")); ok {
+		t.Errorf("info: got %x, true, want false", next)
+	}
+}
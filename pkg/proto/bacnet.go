@@ -0,0 +1,388 @@
+package proto
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	BVLC_TYPE_BACNET_IP    = 0x81
+	BVLC_FORWARDED_NPDU    = 0x04
+	BVLC_HEADER_LENGTH     = 4
+	BVLC_FORWARDED_ADDRESS = 6
+
+	BACNET_NPDU_VERSION       = 1
+	BACNET_NPDU_NETWORK_LAYER = 0x80
+	BACNET_NPDU_DESTINATION   = 0x20
+	BACNET_NPDU_SOURCE        = 0x08
+
+	BACNET_PDU_CONFIRMED   = 0
+	BACNET_PDU_UNCONFIRMED = 1
+	BACNET_PDU_SIMPLE_ACK  = 2
+	BACNET_PDU_COMPLEX_ACK = 3
+	BACNET_PDU_ERROR       = 5
+	BACNET_PDU_REJECT      = 6
+	BACNET_PDU_ABORT       = 7
+	BACNET_PDU_SEGMENTED   = 0x08
+
+	BACNET_SERVICE_I_AM       = 0
+	BACNET_SERVICE_READ       = 0x0c
+	BACNET_SERVICE_READ_MULTI = 0x0e
+
+	BACNET_TAG_BOOLEAN    = 1
+	BACNET_TAG_UNSIGNED   = 2
+	BACNET_TAG_CHARACTER  = 7
+	BACNET_TAG_ENUMERATED = 9
+	BACNET_TAG_OBJECT_ID  = 12
+
+	BACNET_CHARSET_UCS2       = 4
+	BACNET_CHARSET_ISO_8859_1 = 5
+
+	BACNET_OBJECT_INSTANCE_MAX = 0x3fffff
+)
+
+var (
+	// BACNET_PROPERTIES maps the device properties kept as metadata to their
+	// key
+	BACNET_PROPERTIES = map[uint32]string{
+		12:  "application_software_version",
+		28:  "description",
+		44:  "firmware_revision",
+		58:  "location",
+		70:  "model_name",
+		75:  "device_instance",
+		77:  "object_name",
+		120: "vendor_id",
+		121: "vendor_name",
+	}
+	// BACNET_REJECT_REASONS maps the reasons devices reject requests for to
+	// their names
+	BACNET_REJECT_REASONS = map[byte]string{
+		0: "other",
+		1: "buffer overflow",
+		2: "inconsistent parameters",
+		3: "invalid parameter data type",
+		4: "invalid tag",
+		5: "missing required parameter",
+		6: "parameter out of range",
+		7: "too many arguments",
+		8: "undefined enumeration",
+		9: "unrecognized service",
+	}
+)
+
+// bacnetTag is a tag of the BACnet encoding of application data, and the
+// value it holds
+type bacnetTag struct {
+	number  byte
+	context bool
+	opening bool
+	closing bool
+	length  uint32 // Length of the value, or the value of booleans
+	value   []byte
+}
+
+// ParseBACnet reports the instance number, vendor, model, name and firmware
+// and software revisions of BACnet devices, read from the device object
+// properties returned by ReadPropertyMultiple and ReadProperty, and the
+// instance number and vendor ID of their I-Am answer to Who-Is. Devices that
+// do not support a request reject it, which is reported too, and devices
+// without the property return an error.
+func ParseBACnet(request []byte, response []byte) (info Info, ok bool) {
+
+	apdu, found := bacnetAPDU(response)
+
+	if !found || len(apdu) < 2 {
+		return
+	}
+	if requested, found := bacnetAPDU(request); found && bacnetInvokeID(requested) != bacnetInvokeID(apdu) {
+		return
+	}
+	switch apdu[0] >> 4 {
+	case BACNET_PDU_UNCONFIRMED:
+		if apdu[1] != BACNET_SERVICE_I_AM {
+			return
+		}
+		tags := bacnetTags(apdu[2:])
+
+		if len(tags) < 4 || tags[0].context || tags[0].number != BACNET_TAG_OBJECT_ID || len(tags[0].value) != 4 {
+			return
+		}
+		info.setMetadata("device_instance", bacnetValue(tags[0]))
+		info.setMetadata("vendor_id", bacnetValue(tags[3]))
+
+	case BACNET_PDU_COMPLEX_ACK:
+		if apdu[0]&BACNET_PDU_SEGMENTED != 0 || len(apdu) < 3 {
+			return
+		}
+		switch apdu[2] {
+		case BACNET_SERVICE_READ, BACNET_SERVICE_READ_MULTI:
+			for property, value := range bacnetProperties(apdu[3:]) {
+				if key, found := BACNET_PROPERTIES[property]; found {
+					info.setMetadata(key, value)
+				}
+			}
+		default:
+			return
+		}
+
+	case BACNET_PDU_ERROR:
+		if len(apdu) < 3 {
+			return
+		}
+		tags := bacnetTags(apdu[3:])
+
+		if len(tags) < 2 {
+			return
+		}
+		info.setMetadata("error", "class "+bacnetValue(tags[0])+" code "+bacnetValue(tags[1]))
+
+	case BACNET_PDU_REJECT:
+		if len(apdu) < 3 {
+			return
+		}
+		reason, found := BACNET_REJECT_REASONS[apdu[2]]
+
+		if !found {
+			reason = strconv.Itoa(int(apdu[2]))
+		}
+		info.setMetadata("rejected", reason)
+
+	case BACNET_PDU_ABORT:
+		if len(apdu) < 3 {
+			return
+		}
+		info.setMetadata("aborted", strconv.Itoa(int(apdu[2])))
+
+	default:
+		return
+	}
+	info.Version = info.Metadata["firmware_revision"]
+
+	return info, true
+}
+
+// MatchBACnet compares the invoke IDs of BACnet confirmed requests and the
+// acknowledgements, errors, rejections and aborts answering them
+func MatchBACnet(request []byte, response []byte) bool {
+
+	requested, found := bacnetAPDU(request)
+
+	if !found {
+		return true
+	}
+	responded, found := bacnetAPDU(response)
+
+	return !found || bacnetInvokeID(requested) == bacnetInvokeID(responded)
+}
+
+// bacnetAPDU returns the application layer message of a BACnet/IP packet,
+// after its BVLC and network layer headers. It returns false for network layer
+// messages.
+func bacnetAPDU(packet []byte) ([]byte, bool) {
+
+	if len(packet) < BVLC_HEADER_LENGTH || packet[0] != BVLC_TYPE_BACNET_IP {
+		return nil, false
+	}
+	length := int(binary.BigEndian.Uint16(packet[2:4]))
+
+	if length < BVLC_HEADER_LENGTH || length > len(packet) {
+		return nil, false
+	}
+	npdu := packet[BVLC_HEADER_LENGTH:length]
+
+	// Forwarded messages give the address of the device that sent them
+	if packet[1] == BVLC_FORWARDED_NPDU {
+		if len(npdu) < BVLC_FORWARDED_ADDRESS {
+			return nil, false
+		}
+		npdu = npdu[BVLC_FORWARDED_ADDRESS:]
+	}
+	if len(npdu) < 2 || npdu[0] != BACNET_NPDU_VERSION || npdu[1]&BACNET_NPDU_NETWORK_LAYER != 0 {
+		return nil, false
+	}
+	control, offset := npdu[1], 2
+
+	// The destination network and address, then the source ones, precede
+	// the hop count of messages with a destination
+	for _, flag := range []byte{BACNET_NPDU_DESTINATION, BACNET_NPDU_SOURCE} {
+		if control&flag == 0 {
+			continue
+		}
+		if len(npdu) < offset+3 {
+			return nil, false
+		}
+		offset += 3 + int(npdu[offset+2])
+	}
+	if control&BACNET_NPDU_DESTINATION != 0 {
+		offset++
+	}
+	if len(npdu) < offset {
+		return nil, false
+	}
+	return npdu[offset:], true
+}
+
+// bacnetInvokeID returns the invoke ID of confirmed requests and the messages
+// answering them, or -1 for other messages
+func bacnetInvokeID(apdu []byte) int {
+
+	switch {
+	case len(apdu) >= 3 && apdu[0]>>4 == BACNET_PDU_CONFIRMED:
+		return int(apdu[2])
+	case len(apdu) >= 2 && apdu[0]>>4 >= BACNET_PDU_SIMPLE_ACK && apdu[0]>>4 <= BACNET_PDU_ABORT:
+		return int(apdu[1])
+	}
+	return -1
+}
+
+// bacnetTags decodes the tags of application data, up to the first one that
+// is malformed
+func bacnetTags(data []byte) []bacnetTag {
+
+	var tags []bacnetTag
+
+	for len(data) > 0 {
+		var tag bacnetTag
+		header := data[0]
+		tag.number, tag.context, data = header>>4, header&0x08 != 0, data[1:]
+
+		if tag.number == 0x0f {
+			if len(data) < 1 {
+				break
+			}
+			tag.number, data = data[0], data[1:]
+		}
+		tag.length = uint32(header & 0x07)
+
+		switch {
+		case tag.context && tag.length == 6:
+			tag.opening = true
+			tags = append(tags, tag)
+			continue
+		case tag.context && tag.length == 7:
+			tag.closing = true
+			tags = append(tags, tag)
+			continue
+		case tag.length == 5:
+			if len(data) < 1 {
+				return tags
+			}
+			tag.length, data = uint32(data[0]), data[1:]
+
+			if tag.length == 254 && len(data) >= 2 {
+				tag.length, data = uint32(binary.BigEndian.Uint16(data[0:2])), data[2:]
+			} else if tag.length == 255 && len(data) >= 4 {
+				tag.length, data = binary.BigEndian.Uint32(data[0:4]), data[4:]
+			}
+		}
+		// Booleans hold their value in the length
+		if !tag.context && tag.number == BACNET_TAG_BOOLEAN {
+			tags = append(tags, tag)
+			continue
+		}
+		if uint32(len(data)) < tag.length {
+			break
+		}
+		tag.value, data = data[:tag.length], data[tag.length:]
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// bacnetProperties returns the values of the properties of the device object
+// in a ReadProperty or ReadPropertyMultiple acknowledgement. Both give the
+// object identifier in context tag 0, then the property identifier and its
+// value in opening and closing tags, which ReadPropertyMultiple repeats for
+// each property in a list.
+func bacnetProperties(data []byte) map[uint32]string {
+
+	properties := make(map[uint32]string)
+	tags := bacnetTags(data)
+
+	var property uint32
+	depth := 0
+
+	for i, tag := range tags {
+		switch {
+		case tag.opening:
+			depth++
+
+			// A property value opens with context tag 3 in ReadProperty and
+			// 4 in ReadPropertyMultiple, nested in its list
+			if (tag.number == 3 && depth == 1) || (tag.number == 4 && depth == 2) {
+				if i+1 < len(tags) && !tags[i+1].context && !tags[i+1].opening {
+					if _, found := properties[property]; !found {
+						properties[property] = bacnetValue(tags[i+1])
+					}
+				}
+			}
+		case tag.closing:
+			depth--
+
+		// The property identifier is in context tag 1 of ReadProperty, and
+		// 2 of the list of ReadPropertyMultiple
+		case tag.context && ((tag.number == 1 && depth == 0) || (tag.number == 2 && depth == 1)):
+			property = bacnetUnsigned(tag.value)
+		}
+	}
+	return properties
+}
+
+// bacnetValue formats the value of an application tag
+func bacnetValue(tag bacnetTag) string {
+
+	switch tag.number {
+	case BACNET_TAG_UNSIGNED, BACNET_TAG_ENUMERATED:
+		return strconv.FormatUint(uint64(bacnetUnsigned(tag.value)), 10)
+
+	case BACNET_TAG_OBJECT_ID:
+		if len(tag.value) != 4 {
+			return ""
+		}
+		return strconv.FormatUint(uint64(binary.BigEndian.Uint32(tag.value)&BACNET_OBJECT_INSTANCE_MAX), 10)
+
+	case BACNET_TAG_CHARACTER:
+		if len(tag.value) < 1 {
+			return ""
+		}
+		return printable(bacnetString(tag.value[0], tag.value[1:]))
+	}
+	return ""
+}
+
+// bacnetString decodes a character string in UTF-8, UCS-2 or ISO 8859-1
+func bacnetString(charset byte, text []byte) string {
+
+	switch charset {
+	case BACNET_CHARSET_UCS2:
+		units := make([]uint16, 0, len(text)/2)
+
+		for ; len(text) >= 2; text = text[2:] {
+			units = append(units, binary.BigEndian.Uint16(text))
+		}
+		return string(utf16.Decode(units))
+
+	case BACNET_CHARSET_ISO_8859_1:
+		var decoded strings.Builder
+
+		for _, b := range text {
+			decoded.WriteRune(rune(b))
+		}
+		return decoded.String()
+	}
+	return string(text)
+}
+
+// bacnetUnsigned decodes an unsigned integer of up to 4 bytes
+func bacnetUnsigned(value []byte) uint32 {
+
+	var number uint32
+
+	for _, b := range value {
+		number = number<<8 | uint32(b)
+	}
+	return number
+}
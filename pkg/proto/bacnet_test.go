@@ -0,0 +1,115 @@
+package proto
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+// bacnetPacket frames an APDU in the BVLC and network layer headers of an
+// original unicast message
+func bacnetPacket(apdu ...byte) []byte {
+
+	packet := append([]byte{0x81, 0x0a, 0, 0, 0x01, 0x00}, apdu...)
+	packet[2], packet[3] = byte(len(packet)>>8), byte(len(packet))
+
+	return packet
+}
+
+// bacnetText encodes an application tagged character string in UTF-8
+func bacnetText(text string) []byte {
+	return append([]byte{0x75, byte(1 + len(text)), 0}, text...)
+}
+
+func TestParseBACnet(t *testing.T) {
+
+	readMultiple, _ := base64.StdEncoding.DecodeString("gQoAJQEEAgUBDgwCP///HgkMCRwJLAk4CTkJOglGCU0JeAl5Hw==")
+	read, _ := base64.StdEncoding.DecodeString("gQoAEQEEAAUCDAwCP///GXk=")
+	whoIs, _ := base64.StdEncoding.DecodeString("gQoACAEAEAg=")
+
+	// Device 1234 answers with its vendor name, firmware revision, vendor
+	// ID and identifier, and an error for its description
+	properties := []byte{0x30, 0x01, 0x0e, 0x0c, 0x02, 0x00, 0x04, 0xd2, 0x1e}
+	properties = append(append(append(properties, 0x29, 121, 0x4e), bacnetText("Acme Controls")...), 0x4f)
+	properties = append(append(append(properties, 0x29, 44, 0x4e), bacnetText("4.2.1")...), 0x4f)
+	properties = append(properties, 0x29, 120, 0x4e, 0x22, 0x01, 0x04, 0x4f)
+	properties = append(properties, 0x29, 75, 0x4e, 0xc4, 0x02, 0x00, 0x04, 0xd2, 0x4f)
+	properties = append(properties, 0x29, 28, 0x5e, 0x91, 0x02, 0x91, 0x20, 0x5f, 0x1f)
+
+	vendor := append(append([]byte{0x30, 0x02, 0x0c, 0x0c, 0x02, 0x3f, 0xff, 0xff, 0x19, 121, 0x3e}, bacnetText("Acme Controls")...), 0x3f)
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "read property multiple",
+			request:  readMultiple,
+			response: bacnetPacket(properties...),
+			info: Info{Version: "4.2.1", Metadata: map[string]string{
+				"vendor_name":       "Acme Controls",
+				"firmware_revision": "4.2.1",
+				"vendor_id":         "260",
+				"device_instance":   "1234",
+			}},
+			ok: true,
+		},
+		{
+			name:     "read property",
+			request:  read,
+			response: bacnetPacket(vendor...),
+			info:     Info{Metadata: map[string]string{"vendor_name": "Acme Controls"}},
+			ok:       true,
+		},
+		{
+			name:     "I-Am",
+			request:  whoIs,
+			response: bacnetPacket(0x10, 0x00, 0xc4, 0x02, 0x00, 0x04, 0xd2, 0x22, 0x05, 0xc4, 0x91, 0x00, 0x21, 0x05),
+			info:     Info{Metadata: map[string]string{"device_instance": "1234", "vendor_id": "5"}},
+			ok:       true,
+		},
+		{
+			name:     "rejected read property multiple",
+			request:  readMultiple,
+			response: bacnetPacket(0x60, 0x01, 0x09),
+			info:     Info{Metadata: map[string]string{"rejected": "unrecognized service"}},
+			ok:       true,
+		},
+		{
+			name:     "unknown object",
+			request:  read,
+			response: bacnetPacket(0x50, 0x02, 0x0c, 0x91, 0x01, 0x91, 0x1f),
+			info:     Info{Metadata: map[string]string{"error": "class 1 code 31"}},
+			ok:       true,
+		},
+		{
+			name:     "network layer message",
+			request:  whoIs,
+			response: []byte{0x81, 0x0b, 0x00, 0x07, 0x01, 0x80, 0x01},
+			ok:       false,
+		},
+		{
+			name:     "other invoke ID",
+			request:  readMultiple,
+			response: bacnetPacket(vendor...),
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			request:  whoIs,
+			response: whoIs,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseBACnet(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	BER_SET = 0x31
+
+	LDAP_SEARCH_RESULT_ENTRY = 0x64
+
+	NETLOGON_HEADER_LENGTH = 24 // Opcode, flags and domain GUID preceding the names
+)
+
+var (
+	// NETLOGON_OPCODES are the opcodes of the Netlogon responses that carry a
+	// domain controller's names
+	NETLOGON_OPCODES = map[uint16]bool{
+		23: true, // LOGON_SAM_LOGON_RESPONSE_EX
+		25: true, // LOGON_SAM_USER_UNKNOWN_EX
+	}
+	// NETLOGON_NAMES are the names of a Netlogon response, in order, as
+	// metadata keys. The user name is not of interest but must be read.
+	NETLOGON_NAMES = []string{
+		"forest",
+		"domain",
+		"hostname",
+		"netbios_domain",
+		"netbios_name",
+		"",
+		"site",
+		"client_site",
+	}
+	// NETLOGON_FLAGS maps the server type flags of Netlogon responses to the
+	// roles they stand for
+	NETLOGON_FLAGS = []struct {
+		flag uint32
+		role string
+	}{
+		{0x00000001, "pdc"},
+		{0x00000004, "gc"},
+		{0x00000008, "ldap"},
+		{0x00000010, "ds"},
+		{0x00000020, "kdc"},
+		{0x00000040, "timeserv"},
+		{0x00000100, "writable"},
+		{0x00000800, "rodc"},
+	}
+	// LDAP_FUNCTIONALITY maps Active Directory functional levels to the
+	// Windows Server releases that introduced them
+	LDAP_FUNCTIONALITY = map[string]string{
+		"0":  "2000",
+		"1":  "2003 interim",
+		"2":  "2003",
+		"3":  "2008",
+		"4":  "2008 R2",
+		"5":  "2012",
+		"6":  "2012 R2",
+		"7":  "2016",
+		"10": "2025",
+	}
+	// LDAP_FUNCTIONALITY_LEVELS maps the root DSE attributes holding
+	// functional levels to metadata keys
+	LDAP_FUNCTIONALITY_LEVELS = map[string]string{
+		"domainfunctionality":           "domain_functionality",
+		"forestfunctionality":           "forest_functionality",
+		"domaincontrollerfunctionality": "dc_functionality",
+	}
+	// LDAP_ROOT_DSE maps the root DSE attributes kept as they are to metadata
+	// keys
+	LDAP_ROOT_DSE = map[string]string{
+		"dnshostname":     "hostname",
+		"ldapservicename": "ldap_service_name",
+		"vendorname":      "vendor",
+	}
+)
+
+// ParseCLDAP extracts the forest, domain, domain controller host name and
+// site from connectionless LDAP search results, either from the attributes of
+// the root DSE or from the Netlogon response of an Active Directory domain
+// controller
+func ParseCLDAP(request []byte, response []byte) (info Info, ok bool) {
+
+	attributes := make(map[string][]string)
+
+	// A datagram holds the entry and the search result done message
+	for message := response; len(message) > 0; {
+		var tag byte
+		var value, operation []byte
+
+		if tag, value, message, ok = readBER(message); !ok || tag != BER_SEQUENCE {
+			break
+		}
+		if tag, _, value, ok = readBER(value); !ok || tag != BER_INTEGER {
+			break
+		}
+		if tag, operation, _, ok = readBER(value); !ok || tag != LDAP_SEARCH_RESULT_ENTRY {
+			continue
+		}
+		if tag, _, operation, ok = readBER(operation); !ok || tag != BER_OCTET_STRING {
+			continue
+		}
+		if tag, operation, _, ok = readBER(operation); !ok || tag != BER_SEQUENCE {
+			continue
+		}
+		for len(operation) > 0 {
+			var attribute, name, values []byte
+
+			if tag, attribute, operation, ok = readBER(operation); !ok || tag != BER_SEQUENCE {
+				break
+			}
+			if tag, name, attribute, ok = readBER(attribute); !ok || tag != BER_OCTET_STRING {
+				break
+			}
+			if tag, values, _, ok = readBER(attribute); !ok || tag != BER_SET {
+				break
+			}
+			key := strings.ToLower(string(name))
+
+			for len(values) > 0 {
+				if tag, value, values, ok = readBER(values); !ok || tag != BER_OCTET_STRING {
+					break
+				}
+				attributes[key] = append(attributes[key], string(value))
+			}
+		}
+	}
+
+	for _, netlogon := range attributes["netlogon"] {
+		parseNetlogon([]byte(netlogon), &info)
+	}
+	for attribute, key := range LDAP_ROOT_DSE {
+		if values := attributes[attribute]; len(values) > 0 {
+			info.setMetadata(key, printable(values[0]))
+		}
+	}
+	if values := attributes["defaultnamingcontext"]; len(values) > 0 {
+		info.setMetadata("naming_context", printable(values[0]))
+		info.setMetadata("domain", ldapDomain(values[0]))
+	}
+	if values := attributes["rootdomainnamingcontext"]; len(values) > 0 {
+		info.setMetadata("forest", ldapDomain(values[0]))
+	}
+	if values := attributes["servername"]; len(values) > 0 {
+		info.setMetadata("site", ldapSite(values[0]))
+	}
+	for attribute, key := range LDAP_FUNCTIONALITY_LEVELS {
+		if values := attributes[attribute]; len(values) > 0 {
+			info.setMetadata(key, LDAP_FUNCTIONALITY[values[0]])
+		}
+	}
+	if values := attributes["vendorversion"]; len(values) > 0 {
+		info.Version = printable(values[0])
+	}
+	info.Banner = info.Metadata["hostname"]
+
+	return info, !info.Empty()
+}
+
+// parseNetlogon reads the names, flags and domain GUID of a
+// NETLOGON_SAM_LOGON_RESPONSE_EX, whose names are compressed like DNS names
+func parseNetlogon(netlogon []byte, info *Info) {
+
+	if len(netlogon) < NETLOGON_HEADER_LENGTH || !NETLOGON_OPCODES[binary.LittleEndian.Uint16(netlogon[0:2])] {
+		return
+	}
+	flags := binary.LittleEndian.Uint32(netlogon[4:8])
+	var roles []string
+
+	for _, flag := range NETLOGON_FLAGS {
+		if flags&flag.flag != 0 {
+			roles = append(roles, flag.role)
+		}
+	}
+	info.setMetadata("dc_roles", strings.Join(roles, ","))
+	info.setMetadata("domain_guid", netlogonGUID(netlogon[8:24]))
+
+	offset := NETLOGON_HEADER_LENGTH
+
+	for _, key := range NETLOGON_NAMES {
+		var name string
+		var ok bool
+
+		if name, offset, ok = readDNSName(netlogon, offset); !ok {
+			return
+		}
+		if key != "" {
+			info.setMetadata(key, printable(name))
+		}
+	}
+}
+
+// netlogonGUID formats a GUID in its mixed endian binary form
+func netlogonGUID(guid []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(guid[0:4]),
+		binary.LittleEndian.Uint16(guid[4:6]),
+		binary.LittleEndian.Uint16(guid[6:8]),
+		guid[8:10], guid[10:16])
+}
+
+// ldapDomain converts a distinguished name such as DC=corp,DC=example,DC=com
+// to the DNS domain name corp.example.com
+func ldapDomain(dn string) string {
+
+	var labels []string
+
+	for _, component := range strings.Split(dn, ",") {
+		if key, value, found := strings.Cut(strings.TrimSpace(component), "="); found && strings.EqualFold(key, "DC") {
+			labels = append(labels, value)
+		}
+	}
+	return printable(strings.Join(labels, "."))
+}
+
+// ldapSite returns the site of a server object name, such as
+// CN=DC01,CN=Servers,CN=Default-First-Site-Name,CN=Sites,CN=Configuration,...
+func ldapSite(dn string) string {
+
+	components := strings.Split(dn, ",")
+
+	for i := 1; i+1 < len(components); i++ {
+		if strings.EqualFold(strings.TrimSpace(components[i+1]), "CN=Sites") {
+			if key, value, found := strings.Cut(strings.TrimSpace(components[i]), "="); found && strings.EqualFold(key, "CN") {
+				return printable(value)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+// cldapEntry builds a datagram holding a search result entry with the given
+// attributes, and the search result done message following it
+func cldapEntry(attributes ...[]string) []byte {
+
+	var list [][]byte
+
+	for _, attribute := range attributes {
+		var values [][]byte
+
+		for _, value := range attribute[1:] {
+			values = append(values, ber(BER_OCTET_STRING, []byte(value)))
+		}
+		list = append(list, ber(BER_SEQUENCE, ber(BER_OCTET_STRING, []byte(attribute[0])), ber(BER_SET, values...)))
+	}
+	entry := ber(BER_SEQUENCE,
+		ber(BER_INTEGER, []byte{1}),
+		ber(LDAP_SEARCH_RESULT_ENTRY, ber(BER_OCTET_STRING), ber(BER_SEQUENCE, list...)))
+	done := ber(BER_SEQUENCE,
+		ber(BER_INTEGER, []byte{1}),
+		ber(0x65, []byte{0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00}))
+
+	return append(entry, done...)
+}
+
+func TestParseCLDAP(t *testing.T) {
+
+	// A LOGON_SAM_LOGON_RESPONSE_EX of a writable PDC and global catalog,
+	// whose names point back to the forest name
+	netlogon := []byte{
+		0x17, 0x00, 0x00, 0x00, 0x3d, 0x01, 0x00, 0x00,
+		0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x34, 0x12, 0x12, 0x34, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc,
+	}
+	netlogon = append(netlogon, "\x04corp\x07example\x03com\x00"...)
+	netlogon = append(netlogon, 0xc0, 0x18)
+	netlogon = append(netlogon, "\x04DC01\xc0\x18"...)
+	netlogon = append(netlogon, "\x04CORP\x00"...)
+	netlogon = append(netlogon, "\x04DC01\x00"...)
+	netlogon = append(netlogon, 0x00)
+	netlogon = append(netlogon, "\x17Default-First-Site-Name\x00"...)
+	netlogon = append(netlogon, 0xc0, 0x40)
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "Netlogon",
+			response: cldapEntry([]string{"netlogon", string(netlogon)}),
+			info: Info{
+				Banner: "DC01.corp.example.com",
+				Metadata: map[string]string{
+					"forest":         "corp.example.com",
+					"domain":         "corp.example.com",
+					"hostname":       "DC01.corp.example.com",
+					"netbios_domain": "CORP",
+					"netbios_name":   "DC01",
+					"site":           "Default-First-Site-Name",
+					"client_site":    "Default-First-Site-Name",
+					"dc_roles":       "pdc,gc,ldap,ds,kdc,writable",
+					"domain_guid":    "12345678-1234-1234-1234-123456789abc",
+				},
+			},
+			ok: true,
+		},
+		{
+			name: "root DSE",
+			response: cldapEntry(
+				[]string{"defaultNamingContext", "DC=child,DC=corp,DC=example,DC=com"},
+				[]string{"rootDomainNamingContext", "DC=corp,DC=example,DC=com"},
+				[]string{"dnsHostName", "dc02.child.corp.example.com"},
+				[]string{"serverName", "CN=DC02,CN=Servers,CN=Branch,CN=Sites,CN=Configuration,DC=corp,DC=example,DC=com"},
+				[]string{"domainControllerFunctionality", "7"},
+				[]string{"forestFunctionality", "6"},
+				[]string{"supportedLDAPVersion", "3", "2"},
+			),
+			info: Info{
+				Banner: "dc02.child.corp.example.com",
+				Metadata: map[string]string{
+					"naming_context":       "DC=child,DC=corp,DC=example,DC=com",
+					"domain":               "child.corp.example.com",
+					"forest":               "corp.example.com",
+					"hostname":             "dc02.child.corp.example.com",
+					"site":                 "Branch",
+					"dc_functionality":     "2016",
+					"forest_functionality": "2012 R2",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "truncated Netlogon",
+			response: cldapEntry([]string{"Netlogon", string(netlogon[:30])}),
+			info:     Info{Metadata: map[string]string{"dc_roles": "pdc,gc,ldap,ds,kdc,writable", "domain_guid": "12345678-1234-1234-1234-123456789abc"}},
+			ok:       true,
+		},
+		{
+			name:     "entry without attributes",
+			response: cldapEntry(),
+			ok:       false,
+		},
+		{
+			name:     "not LDAP",
+			response: []byte("garbage"),
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseCLDAP(nil, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	COAP_VERSION       = 1
+	COAP_HEADER_LENGTH = 4
+	COAP_TYPE_ACK      = 2
+	COAP_TYPE_RESET    = 3
+	COAP_PAYLOAD       = 0xff
+
+	COAP_OPTION_CONTENT_FORMAT = 12
+	COAP_OPTION_BLOCK2         = 23
+
+	COAP_FORMAT_LINK = 40
+)
+
+var (
+	// COAP_CODES maps the response codes of CoAP servers to their names
+	COAP_CODES = map[byte]string{
+		0x00: "Empty",
+		0x41: "Created",
+		0x42: "Deleted",
+		0x43: "Valid",
+		0x44: "Changed",
+		0x45: "Content",
+		0x80: "Bad Request",
+		0x81: "Unauthorized",
+		0x82: "Bad Option",
+		0x83: "Forbidden",
+		0x84: "Not Found",
+		0x85: "Method Not Allowed",
+		0x86: "Not Acceptable",
+		0xa0: "Internal Server Error",
+		0xa1: "Not Implemented",
+		0xa3: "Service Unavailable",
+	}
+)
+
+// ParseCoAP reports the response code of CoAP servers and the resources they
+// list at /.well-known/core in the CoRE link format, along with the resource
+// types given by their rt attributes. Servers sending their resources over
+// several blocks only have the first one parsed, and set more_resources.
+func ParseCoAP(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < COAP_HEADER_LENGTH || response[0]>>6 != COAP_VERSION || bytes.Equal(request, response) {
+		return
+	}
+	kind, tokenLength, code := response[0]>>4&0x03, int(response[0]&0x0f), response[1]
+
+	if tokenLength > 8 || len(response) < COAP_HEADER_LENGTH+tokenLength {
+		return
+	}
+	// Servers reset requests they cannot process
+	if kind == COAP_TYPE_RESET {
+		info.setMetadata("reset", "true")
+
+		return info, true
+	}
+	// Requests have a code class of 0, like empty messages
+	if code>>5 == 0 && code != 0 {
+		return
+	}
+	options, payload, found := coapOptions(response[COAP_HEADER_LENGTH+tokenLength:])
+
+	if !found {
+		return
+	}
+	info.setMetadata("code", coapCode(code))
+
+	if block := options[COAP_OPTION_BLOCK2]; coapUint(block)&0x08 != 0 {
+		info.setMetadata("more_resources", "true")
+	}
+	if format, found := options[COAP_OPTION_CONTENT_FORMAT]; (found && coapUint(format) != COAP_FORMAT_LINK) || code != 0x45 {
+		return info, true
+	}
+	var resources, types []string
+	seen := make(map[string]bool)
+
+	for _, link := range coapLinks(string(payload)) {
+		attributes := splitQuoted(link, ';')
+		target := strings.TrimSpace(attributes[0])
+
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		resources = append(resources, printable(strings.Trim(target, "<>")))
+
+		for _, attribute := range attributes[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(attribute), "=")
+
+			if name != "rt" {
+				continue
+			}
+			for _, kind := range strings.Fields(strings.Trim(value, `"`)) {
+				if kind = printable(kind); !seen[kind] {
+					seen[kind] = true
+					types = append(types, kind)
+				}
+			}
+		}
+	}
+	info.setMetadata("resources", strings.Join(resources, ","))
+	info.setMetadata("resource_types", strings.Join(types, ","))
+
+	return info, true
+}
+
+// MatchCoAP compares the message IDs of CoAP requests and the
+// acknowledgements or resets answering them. Separate responses carry the
+// token of the request instead, which the probes leave empty.
+func MatchCoAP(request []byte, response []byte) bool {
+
+	if len(request) < COAP_HEADER_LENGTH || len(response) < COAP_HEADER_LENGTH {
+		return true
+	}
+	if kind := response[0] >> 4 & 0x03; kind != COAP_TYPE_ACK && kind != COAP_TYPE_RESET {
+		return true
+	}
+	return bytes.Equal(request[2:4], response[2:4])
+}
+
+// coapOptions returns the values of the options of a CoAP message, which
+// follow its token, and the payload after them. Options are numbered by the
+// difference with the previous one, and both that delta and the length of the
+// value use four bits, extended by one or two bytes.
+func coapOptions(data []byte) (options map[uint16][]byte, payload []byte, ok bool) {
+
+	options = make(map[uint16][]byte)
+	var number uint16
+
+	for len(data) > 0 {
+		if data[0] == COAP_PAYLOAD {
+			return options, data[1:], true
+		}
+		delta, length := int(data[0]>>4), int(data[0]&0x0f)
+		data = data[1:]
+
+		if delta, data, ok = coapExtended(delta, data); !ok {
+			return nil, nil, false
+		}
+		if length, data, ok = coapExtended(length, data); !ok || length > len(data) {
+			return nil, nil, false
+		}
+		number += uint16(delta)
+
+		if _, found := options[number]; !found {
+			options[number] = data[:length]
+		}
+		data = data[length:]
+	}
+	return options, nil, true
+}
+
+// coapExtended reads the extended delta or length of an option
+func coapExtended(value int, data []byte) (int, []byte, bool) {
+
+	switch value {
+	case 13:
+		if len(data) < 1 {
+			return 0, nil, false
+		}
+		return 13 + int(data[0]), data[1:], true
+	case 14:
+		if len(data) < 2 {
+			return 0, nil, false
+		}
+		return 269 + int(binary.BigEndian.Uint16(data[0:2])), data[2:], true
+	case 15:
+		return 0, nil, false
+	}
+	return value, data, true
+}
+
+// coapUint decodes an option holding an unsigned integer of up to 4 bytes
+func coapUint(value []byte) uint32 {
+
+	var number uint32
+
+	for _, b := range value {
+		number = number<<8 | uint32(b)
+	}
+	return number
+}
+
+// coapCode formats a response code as its class and detail, followed by its
+// name
+func coapCode(code byte) string {
+
+	formatted := fmt.Sprintf("%d.%02d", code>>5, code&0x1f)
+
+	if name, found := COAP_CODES[code]; found {
+		formatted += " " + name
+	}
+	return formatted
+}
+
+// coapLinks splits a document in the CoRE link format into its links
+func coapLinks(document string) []string {
+
+	var links []string
+
+	for _, link := range splitQuoted(document, ',') {
+		if link = strings.TrimSpace(link); link != "" {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// splitQuoted splits text around a separator, except within double quotes
+func splitQuoted(text string, separator byte) []string {
+
+	var parts []string
+	quoted, start := false, 0
+
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"':
+			quoted = !quoted
+		case separator:
+			if !quoted {
+				parts = append(parts, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, text[start:])
+}
@@ -0,0 +1,97 @@
+package proto
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestParseCoAP(t *testing.T) {
+
+	request, _ := base64.StdEncoding.DecodeString("QAF9cLsud2VsbC1rbm93bgRjb3Jl")
+
+	// Acknowledgements carry the message ID of the request and its empty
+	// token, then the Content-Format option
+	links := func(code byte, options []byte, document string) []byte {
+		response := append([]byte{0x60, code, 0x7d, 0x70}, options...)
+
+		return append(append(response, 0xff), document...)
+	}
+	linkFormat := []byte{0xc1, 40}
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "resources",
+			request:  request,
+			response: links(0x45, linkFormat, `</sensors/temp>;rt="temperature-c";if="sensor",</sensors/light>;rt="light-lux core.s";title="Light, lux",</fw>;ct=0`),
+			info: Info{Metadata: map[string]string{
+				"code":           "2.05 Content",
+				"resources":      "/sensors/temp,/sensors/light,/fw",
+				"resource_types": "temperature-c,light-lux,core.s",
+			}},
+			ok: true,
+		},
+		{
+			name:    "first block of resources",
+			request: request,
+			// Block2 follows Content-Format, 11 options later, with block 0
+			// of 64 bytes and more to come
+			response: links(0x45, append(linkFormat, 0xb1, 0x0a), `</a>,</b>`),
+			info:     Info{Metadata: map[string]string{"code": "2.05 Content", "more_resources": "true", "resources": "/a,/b"}},
+			ok:       true,
+		},
+		{
+			name:     "not found",
+			request:  request,
+			response: []byte{0x60, 0x84, 0x7d, 0x70},
+			info:     Info{Metadata: map[string]string{"code": "4.04 Not Found"}},
+			ok:       true,
+		},
+		{
+			name:     "other content format",
+			request:  request,
+			response: links(0x45, []byte{0xc1, 50}, `{"a": "<b>"}`),
+			info:     Info{Metadata: map[string]string{"code": "2.05 Content"}},
+			ok:       true,
+		},
+		{
+			name:     "reset",
+			request:  request,
+			response: []byte{0x70, 0x00, 0x7d, 0x70},
+			info:     Info{Metadata: map[string]string{"reset": "true"}},
+			ok:       true,
+		},
+		{
+			name:     "echoed request",
+			request:  request,
+			response: request,
+			ok:       false,
+		},
+		{
+			name:     "request",
+			request:  request,
+			response: []byte{0x40, 0x01, 0x12, 0x34},
+			ok:       false,
+		},
+		{
+			name:     "truncated option",
+			request:  request,
+			response: []byte{0x60, 0x45, 0x7d, 0x70, 0xc2, 40},
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseCoAP(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package proto
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CpeRule maps the versions or banners a service reports to a CPE 2.3 name.
+// The version and update groups of the pattern fill in the {version} and
+// {update} fields of the name, which are * if the pattern lacks them.
+type CpeRule struct {
+	Services []string
+	Pattern  *regexp.Regexp
+	CPE      string
+}
+
+var (
+	// CPE_RULES are matched against the version, then the banner, of the
+	// results of their services, so that fingerprinted versions can be
+	// correlated with vulnerability databases
+	CPE_RULES = []CpeRule{
+		{[]string{"ntp"}, regexp.MustCompile(`^ntpd (?P<version>\d+\.\d+\.\d+)(?P<update>p\d+)?`), "cpe:2.3:a:ntp:ntp:{version}:{update}:*:*:*:*:*:*"},
+		{[]string{"dns"}, regexp.MustCompile(`^dnsmasq-(?P<version>\d+\.\d+(?:\.\d+)?)`), "cpe:2.3:a:thekelleys:dnsmasq:{version}:*:*:*:*:*:*:*"},
+		{[]string{"dns"}, regexp.MustCompile(`(?i)^(?:BIND )?(?P<version>9\.\d+\.\d+)(?:-(?P<update>[ps]\d+))?`), "cpe:2.3:a:isc:bind:{version}:{update}:*:*:*:*:*:*"},
+		{[]string{"dns"}, regexp.MustCompile(`^unbound (?P<version>\d+\.\d+\.\d+)`), "cpe:2.3:a:nlnetlabs:unbound:{version}:*:*:*:*:*:*:*"},
+		{[]string{"dns"}, regexp.MustCompile(`^PowerDNS Recursor (?P<version>\d+\.\d+\.\d+)`), "cpe:2.3:a:powerdns:recursor:{version}:*:*:*:*:*:*:*"},
+		{[]string{"dns"}, regexp.MustCompile(`^PowerDNS Authoritative Server (?P<version>\d+\.\d+\.\d+)`), "cpe:2.3:a:powerdns:authoritative:{version}:*:*:*:*:*:*:*"},
+		{[]string{"memcache"}, regexp.MustCompile(`^(?P<version>\d+\.\d+\.\d+)$`), "cpe:2.3:a:memcached:memcached:{version}:*:*:*:*:*:*:*"},
+		{[]string{"sip"}, regexp.MustCompile(`Asterisk(?: PBX)? (?P<version>\d+\.\d+\.\d+)`), "cpe:2.3:a:digium:asterisk:{version}:*:*:*:*:*:*:*"},
+		{[]string{"sip"}, regexp.MustCompile(`FreeSWITCH(?:-mod_sofia)?/(?P<version>\d+\.\d+\.\d+)`), "cpe:2.3:a:freeswitch:freeswitch:{version}:*:*:*:*:*:*:*"},
+		{[]string{"sip"}, regexp.MustCompile(`(?i)^kamailio \((?P<version>\d+\.\d+\.\d+)`), "cpe:2.3:a:kamailio:kamailio:{version}:*:*:*:*:*:*:*"},
+		{[]string{"sip"}, regexp.MustCompile(`^OpenSIPS \((?P<version>\d+\.\d+\.\d+)`), "cpe:2.3:a:opensips:opensips:{version}:*:*:*:*:*:*:*"},
+		// IOS versions hold the release in parentheses, unlike IOS XE ones
+		{[]string{"snmp"}, regexp.MustCompile(`^Cisco IOS Software,.*Version (?P<version>\d+\.\d+\(\w+\)\w*)`), "cpe:2.3:o:cisco:ios:{version}:*:*:*:*:*:*:*"},
+		{[]string{"snmp"}, regexp.MustCompile(`^Linux \S+ (?P<version>\d+\.\d+(?:\.\d+)?)`), "cpe:2.3:o:linux:linux_kernel:{version}:*:*:*:*:*:*:*"},
+		{[]string{"upnp"}, regexp.MustCompile(`MiniUPnPd/(?P<version>\d+\.\d+(?:\.\d+)?)`), "cpe:2.3:a:miniupnp_project:miniupnpd:{version}:*:*:*:*:*:*:*"},
+		{[]string{"upnp"}, regexp.MustCompile(`Portable SDK for UPnP devices/(?P<version>\d+\.\d+\.\d+)`), "cpe:2.3:a:libupnp_project:libupnp:{version}:*:*:*:*:*:*:*"},
+		{[]string{"stun"}, regexp.MustCompile(`^Coturn-(?P<version>\d+\.\d+\.\d+)`), "cpe:2.3:a:coturn_project:coturn:{version}:*:*:*:*:*:*:*"},
+	}
+)
+
+// CPEs returns the CPE names of the rules of the service that match the
+// version or banner of the information
+func CPEs(service string, info Info) (cpes []string) {
+
+	for _, rule := range CPE_RULES {
+
+		if !rule.applies(service) {
+			continue
+		}
+		for _, text := range []string{info.Version, info.Banner} {
+
+			if cpe, ok := rule.name(text); ok {
+				cpes = append(cpes, cpe)
+				break
+			}
+		}
+	}
+	return
+}
+
+// applies reports whether the rule is for the service
+func (rule CpeRule) applies(service string) bool {
+
+	for _, slug := range rule.Services {
+		if slug == service {
+			return true
+		}
+	}
+	return false
+}
+
+// name returns the CPE name of the rule filled in from the text
+func (rule CpeRule) name(text string) (string, bool) {
+
+	match := rule.Pattern.FindStringSubmatch(text)
+
+	if match == nil {
+		return "", false
+	}
+	fields := map[string]string{"version": "*", "update": "*"}
+
+	for i, group := range rule.Pattern.SubexpNames() {
+		if _, known := fields[group]; known && match[i] != "" {
+			fields[group] = cpeEscape(match[i])
+		}
+	}
+	return strings.NewReplacer("{version}", fields["version"], "{update}", fields["update"]).Replace(rule.CPE), true
+}
+
+// cpeEscape lowercases a value and quotes the characters CPE 2.3 formatted
+// strings reserve
+func cpeEscape(value string) string {
+
+	var builder strings.Builder
+
+	for _, char := range strings.ToLower(value) {
+
+		if !(char >= 'a' && char <= 'z') && !(char >= '0' && char <= '9') && char != '.' && char != '-' && char != '_' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(char)
+	}
+	return builder.String()
+}
@@ -0,0 +1,39 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCPEs(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		service string
+		info    Info
+		cpes    []string
+	}{
+		{"ntpd", "ntp", Info{Version: "ntpd 4.2.8p15@1.3728-o Wed Sep 23 11:46:38 UTC 2020 (1)"}, []string{"cpe:2.3:a:ntp:ntp:4.2.8:p15:*:*:*:*:*:*"}},
+		{"dnsmasq", "dns", Info{Version: "dnsmasq-2.80"}, []string{"cpe:2.3:a:thekelleys:dnsmasq:2.80:*:*:*:*:*:*:*"}},
+		{"BIND", "dns", Info{Version: "9.11.4-P2-RedHat-9.11.4-26.P2.el7"}, []string{"cpe:2.3:a:isc:bind:9.11.4:p2:*:*:*:*:*:*"}},
+		{"BIND without patch", "dns", Info{Version: "9.16.1-Ubuntu"}, []string{"cpe:2.3:a:isc:bind:9.16.1:*:*:*:*:*:*:*"}},
+		{"memcached", "memcache", Info{Version: "1.6.9"}, []string{"cpe:2.3:a:memcached:memcached:1.6.9:*:*:*:*:*:*:*"}},
+		{"Asterisk banner", "sip", Info{Banner: "Asterisk PBX 16.2.1"}, []string{"cpe:2.3:a:digium:asterisk:16.2.1:*:*:*:*:*:*:*"}},
+		{
+			"Cisco IOS",
+			"snmp",
+			Info{Banner: "Cisco IOS Software, C2960 Software (C2960-LANBASEK9-M), Version 15.0(2)SE11, RELEASE SOFTWARE (fc3)"},
+			[]string{`cpe:2.3:o:cisco:ios:15.0\(2\)se11:*:*:*:*:*:*:*`},
+		},
+		{"Cisco IOS XE", "snmp", Info{Banner: "Cisco IOS Software [Fuji], Catalyst L3 Switch Software (CAT9K_IOSXE), Version 16.9.4, RELEASE SOFTWARE (fc2)"}, nil},
+		{"Linux", "snmp", Info{Banner: "Linux gateway 5.4.0-42-generic #46-Ubuntu SMP x86_64"}, []string{"cpe:2.3:o:linux:linux_kernel:5.4.0:*:*:*:*:*:*:*"}},
+		{"MiniUPnPd", "upnp", Info{Banner: "Linux/3.14 UPnP/1.0 MiniUPnPd/2.1"}, []string{"cpe:2.3:a:miniupnp_project:miniupnpd:2.1:*:*:*:*:*:*:*"}},
+		{"other service", "ntp", Info{Version: "dnsmasq-2.80"}, nil},
+		{"unknown version", "dns", Info{Version: "none of your business"}, nil},
+	}
+	for _, test := range tests {
+		if cpes := CPEs(test.service, test.info); !reflect.DeepEqual(cpes, test.cpes) {
+			t.Errorf("%s: got %v, want %v", test.name, cpes, test.cpes)
+		}
+	}
+}
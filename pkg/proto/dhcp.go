@@ -0,0 +1,240 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	DHCP_HEADER_LENGTH = 236
+	DHCP_MAGIC_COOKIE  = 0x63825363
+	DHCP_BOOTREPLY     = 2
+
+	DHCP_OPTION_PAD          = 0
+	DHCP_OPTION_MESSAGE_TYPE = 53
+	DHCP_OPTION_END          = 255
+
+	DHCPV6_HEADER_LENGTH = 4
+
+	DHCPV6_OPTION_SERVER_ID   = 2
+	DHCPV6_OPTION_IA_NA       = 3
+	DHCPV6_OPTION_IAADDR      = 5
+	DHCPV6_OPTION_STATUS_CODE = 13
+	DHCPV6_OPTION_DNS_SERVERS = 23
+	DHCPV6_OPTION_DOMAIN_LIST = 24
+	DHCPV6_OPTION_SNTP        = 31
+)
+
+var (
+	// DHCP_MESSAGES maps the types of the messages DHCP servers answer with
+	// to their names
+	DHCP_MESSAGES = map[byte]string{
+		2: "DHCPOFFER",
+		5: "DHCPACK",
+		6: "DHCPNAK",
+	}
+	// DHCP_OPTIONS maps the DHCP options kept as metadata to their key and
+	// how their value is formatted
+	DHCP_OPTIONS = map[byte]struct {
+		key    string
+		format func(value []byte) string
+	}{
+		1:   {"subnet_mask", dhcpAddresses},
+		3:   {"routers", dhcpAddresses},
+		6:   {"dns_servers", dhcpAddresses},
+		15:  {"domain", dhcpText},
+		42:  {"ntp_servers", dhcpAddresses},
+		51:  {"lease_time", dhcpSeconds},
+		54:  {"server_id", dhcpAddresses},
+		66:  {"tftp_server", dhcpText},
+		67:  {"bootfile", dhcpText},
+		119: {"domain_search", dhcpDomains},
+		252: {"wpad", dhcpText},
+	}
+	// DHCPV6_MESSAGES maps the types of the messages DHCPv6 servers answer
+	// with to their names
+	DHCPV6_MESSAGES = map[byte]string{
+		2: "ADVERTISE",
+		7: "REPLY",
+	}
+)
+
+// ParseDHCP reports the server identifier, offered address and options of
+// DHCP servers answering a DISCOVER or INFORM, such as the routers, DNS
+// servers and domain of the network. Servers that are not the expected ones
+// are rogue.
+func ParseDHCP(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < DHCP_HEADER_LENGTH+4 || response[0] != DHCP_BOOTREPLY ||
+		binary.BigEndian.Uint32(response[DHCP_HEADER_LENGTH:DHCP_HEADER_LENGTH+4]) != DHCP_MAGIC_COOKIE {
+		return
+	}
+	if len(request) >= 8 && !bytes.Equal(request[4:8], response[4:8]) {
+		return
+	}
+	options := response[DHCP_HEADER_LENGTH+4:]
+	var codes []string
+
+	for len(options) > 0 && options[0] != DHCP_OPTION_END {
+		if options[0] == DHCP_OPTION_PAD {
+			options = options[1:]
+			continue
+		}
+		if len(options) < 2 || 2+int(options[1]) > len(options) {
+			break
+		}
+		code, value := options[0], options[2:2+int(options[1])]
+		options = options[2+len(value):]
+
+		codes = append(codes, strconv.Itoa(int(code)))
+
+		if code == DHCP_OPTION_MESSAGE_TYPE && len(value) == 1 {
+			info.setMetadata("dhcp_message", DHCP_MESSAGES[value[0]])
+		}
+		if option, found := DHCP_OPTIONS[code]; found {
+			info.setMetadata(option.key, option.format(value))
+		}
+	}
+	if offered := net.IP(response[16:20]); !offered.IsUnspecified() {
+		info.setMetadata("offered_address", offered.String())
+	}
+	info.setMetadata("options", strings.Join(codes, ","))
+
+	return info, true
+}
+
+// MatchDHCP compares the transaction IDs of DHCP requests and responses
+func MatchDHCP(request []byte, response []byte) bool {
+
+	if len(request) < 8 || len(response) < 8 {
+		return true
+	}
+	return bytes.Equal(request[4:8], response[4:8])
+}
+
+// ParseDHCPv6 reports the DUID, DNS servers and domains of DHCPv6 servers
+// answering a SOLICIT or INFORMATION-REQUEST, and the address they offer.
+// Servers that only accept multicast answer unicast requests with a status
+// code.
+func ParseDHCPv6(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < DHCPV6_HEADER_LENGTH {
+		return
+	}
+	message, found := DHCPV6_MESSAGES[response[0]]
+
+	if !found || (len(request) >= DHCPV6_HEADER_LENGTH && !bytes.Equal(request[1:4], response[1:4])) {
+		return
+	}
+	info.setMetadata("dhcp_message", message)
+
+	for options := response[DHCPV6_HEADER_LENGTH:]; len(options) >= 4; {
+		code, length := binary.BigEndian.Uint16(options[0:2]), int(binary.BigEndian.Uint16(options[2:4]))
+
+		if 4+length > len(options) {
+			break
+		}
+		value := options[4 : 4+length]
+		options = options[4+length:]
+
+		switch code {
+		case DHCPV6_OPTION_SERVER_ID:
+			info.setMetadata("server_id", hex.EncodeToString(value))
+
+		case DHCPV6_OPTION_DNS_SERVERS:
+			info.setMetadata("dns_servers", addressList(value, net.IPv6len))
+
+		case DHCPV6_OPTION_SNTP:
+			info.setMetadata("ntp_servers", addressList(value, net.IPv6len))
+
+		case DHCPV6_OPTION_DOMAIN_LIST:
+			info.setMetadata("domain_search", dhcpDomains(value))
+
+		case DHCPV6_OPTION_STATUS_CODE:
+			if len(value) >= 2 {
+				info.setMetadata("status", printable(strconv.Itoa(int(binary.BigEndian.Uint16(value[0:2])))+" "+string(value[2:])))
+			}
+
+		case DHCPV6_OPTION_IA_NA:
+			if len(value) < 12 {
+				continue
+			}
+			// The identity association holds the offered addresses after
+			// its IAID and renewal times
+			for suboptions := value[12:]; len(suboptions) >= 4; {
+				code, length := binary.BigEndian.Uint16(suboptions[0:2]), int(binary.BigEndian.Uint16(suboptions[2:4]))
+
+				if 4+length > len(suboptions) {
+					break
+				}
+				if code == DHCPV6_OPTION_IAADDR && length >= net.IPv6len {
+					info.setMetadata("offered_address", net.IP(suboptions[4:4+net.IPv6len]).String())
+				}
+				suboptions = suboptions[4+length:]
+			}
+		}
+	}
+	return info, true
+}
+
+// MatchDHCPv6 compares the transaction IDs of DHCPv6 requests and responses
+func MatchDHCPv6(request []byte, response []byte) bool {
+
+	if len(request) < DHCPV6_HEADER_LENGTH || len(response) < DHCPV6_HEADER_LENGTH {
+		return true
+	}
+	return bytes.Equal(request[1:4], response[1:4])
+}
+
+// dhcpAddresses formats a list of IPv4 addresses
+func dhcpAddresses(value []byte) string {
+	return addressList(value, net.IPv4len)
+}
+
+// addressList formats a list of addresses of the given size
+func addressList(value []byte, size int) string {
+
+	var addresses []string
+
+	for ; len(value) >= size; value = value[size:] {
+		addresses = append(addresses, net.IP(value[:size]).String())
+	}
+	return strings.Join(addresses, ",")
+}
+
+// dhcpText formats a text option
+func dhcpText(value []byte) string {
+	return printable(strings.TrimRight(string(value), "\x00"))
+}
+
+// dhcpSeconds formats a duration in seconds
+func dhcpSeconds(value []byte) string {
+
+	if len(value) != 4 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(binary.BigEndian.Uint32(value)), 10)
+}
+
+// dhcpDomains formats a list of domain names encoded like in DNS messages
+func dhcpDomains(value []byte) string {
+
+	var domains []string
+
+	for offset := 0; offset < len(value); {
+		name, next, ok := readDNSName(value, offset)
+
+		if !ok {
+			break
+		}
+		if name != "" {
+			domains = append(domains, printable(name))
+		}
+		offset = next
+	}
+	return strings.Join(domains, ",")
+}
@@ -0,0 +1,140 @@
+package proto
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+// dhcpReply builds a BOOTREPLY to the transaction with the given options
+func dhcpReply(xid string, offered []byte, options ...byte) []byte {
+
+	reply := make([]byte, DHCP_HEADER_LENGTH)
+	reply[0], reply[1], reply[2] = DHCP_BOOTREPLY, 1, 6
+	copy(reply[4:8], xid)
+	copy(reply[16:20], offered)
+	reply = append(reply, 0x63, 0x82, 0x53, 0x63)
+	reply = append(reply, options...)
+
+	return append(reply, DHCP_OPTION_END)
+}
+
+func TestParseDHCP(t *testing.T) {
+
+	discover, _ := base64.StdEncoding.DecodeString("AQEGAHVkcHoAAIAAAAAAAAAAAAAAAAAAAAAAAAJ1ZHB6AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABjglNjNQEBNwkBAwYPKkJDd/z/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+
+	offer := dhcpReply("udpz", []byte{192, 168, 1, 50},
+		53, 1, 2,
+		54, 4, 192, 168, 1, 1,
+		51, 4, 0, 0, 0x0e, 0x10,
+		1, 4, 255, 255, 255, 0,
+		0,
+		3, 4, 192, 168, 1, 1,
+		6, 8, 192, 168, 1, 1, 8, 8, 8, 8,
+		15, 9, 'c', 'o', 'r', 'p', '.', 'l', 'a', 'n', 0,
+		// A search list whose second name points into the first
+		119, 15, 4, 'c', 'o', 'r', 'p', 3, 'l', 'a', 'n', 0, 2, 'a', 'd', 0xc0, 0)
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "offer",
+			request:  discover,
+			response: offer,
+			info: Info{Metadata: map[string]string{
+				"dhcp_message":    "DHCPOFFER",
+				"server_id":       "192.168.1.1",
+				"offered_address": "192.168.1.50",
+				"lease_time":      "3600",
+				"subnet_mask":     "255.255.255.0",
+				"routers":         "192.168.1.1",
+				"dns_servers":     "192.168.1.1,8.8.8.8",
+				"domain":          "corp.lan",
+				"domain_search":   "corp.lan,ad.corp.lan",
+				"options":         "53,54,51,1,3,6,15,119",
+			}},
+			ok: true,
+		},
+		{
+			name:     "other transaction",
+			request:  discover,
+			response: dhcpReply("xxxx", nil, 53, 1, 5),
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			request:  discover,
+			response: discover,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseDHCP(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
+
+func TestParseDHCPv6(t *testing.T) {
+
+	solicit, _ := base64.StdEncoding.DecodeString("AXVkcAABAAoAAwABAnVkcHoAAAgAAgAAAAYACAAXABgAHwA4AAMADHVkcHoAAAAAAAAAAA==")
+
+	advertise := []byte{2, 'u', 'd', 'p',
+		0, 2, 0, 10, 0, 3, 0, 1, 0x52, 0x54, 0, 0x12, 0x34, 0x56,
+		0, 3, 0, 40, 'u', 'd', 'p', 'z', 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 5, 0, 24, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10, 0, 0, 0, 0x0e, 0x10, 0, 0, 0x1c, 0x20,
+		0, 23, 0, 16, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x53,
+		0, 24, 0, 10, 4, 'c', 'o', 'r', 'p', 3, 'l', 'a', 'n', 0}
+	refused := []byte{7, 'u', 'd', 'p', 0, 13, 0, 15, 0, 5, 'u', 's', 'e', ' ', 'm', 'u', 'l', 't', 'i', 'c', 'a', 's', 't'}
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "advertise",
+			request:  solicit,
+			response: advertise,
+			info: Info{Metadata: map[string]string{
+				"dhcp_message":    "ADVERTISE",
+				"server_id":       "00030001525400123456",
+				"offered_address": "2001:db8::1000",
+				"dns_servers":     "2001:db8::53",
+				"domain_search":   "corp.lan",
+			}},
+			ok: true,
+		},
+		{
+			name:     "use multicast",
+			request:  solicit,
+			response: refused,
+			info:     Info{Metadata: map[string]string{"dhcp_message": "REPLY", "status": "5 use multicast"}},
+			ok:       true,
+		},
+		{
+			name:     "echoed request",
+			request:  solicit,
+			response: solicit,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseDHCPv6(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package proto
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+const (
+	DNP3_START         = 0x0564
+	DNP3_HEADER_LENGTH = 10
+	DNP3_DIRECTION     = 0x80
+	DNP3_PRIMARY       = 0x40
+)
+
+var (
+	// DNP3_SECONDARY_FUNCTIONS maps the function codes of the link layer
+	// frames outstations answer a master with to their names
+	DNP3_SECONDARY_FUNCTIONS = map[byte]string{
+		0:  "ACK",
+		1:  "NACK",
+		11: "LINK_STATUS",
+		15: "NOT_SUPPORTED",
+	}
+)
+
+// ParseDNP3 reports the link layer address of DNP3 outstations, such as RTUs
+// and IEDs, answering a Request Link Status addressed to them, and the master
+// address they answer. The probe asks each of the lowest addresses in turn,
+// since outstations ignore frames addressed to others.
+func ParseDNP3(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < DNP3_HEADER_LENGTH || binary.BigEndian.Uint16(response[0:2]) != DNP3_START || response[2] < 5 {
+		return
+	}
+	control := response[3]
+
+	// Frames from masters, such as the request echoed back, set both bits
+	if control&DNP3_DIRECTION != 0 && control&DNP3_PRIMARY != 0 {
+		return
+	}
+	info.setMetadata("dnp3_address", strconv.Itoa(int(binary.LittleEndian.Uint16(response[6:8]))))
+	info.setMetadata("master_address", strconv.Itoa(int(binary.LittleEndian.Uint16(response[4:6]))))
+
+	if control&DNP3_PRIMARY == 0 {
+		function, found := DNP3_SECONDARY_FUNCTIONS[control&0x0f]
+
+		if !found {
+			function = strconv.Itoa(int(control & 0x0f))
+		}
+		info.setMetadata("link_function", function)
+	}
+	return info, true
+}
@@ -0,0 +1,55 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDNP3(t *testing.T) {
+
+	// Request Link Status frames from master 0 to outstations 0 and 1
+	request := []byte{0x05, 0x64, 0x05, 0xc9, 0x00, 0x00, 0x00, 0x00, 0x36, 0x4c, 0x05, 0x64, 0x05, 0xc9, 0x01, 0x00, 0x00, 0x00, 0xde, 0x8e}
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "link status",
+			request:  request,
+			response: []byte{0x05, 0x64, 0x05, 0x0b, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00},
+			info:     Info{Metadata: map[string]string{"dnp3_address": "1", "master_address": "0", "link_function": "LINK_STATUS"}},
+			ok:       true,
+		},
+		{
+			name:     "unsupported function",
+			request:  request,
+			response: []byte{0x05, 0x64, 0x05, 0x0f, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00},
+			info:     Info{Metadata: map[string]string{"dnp3_address": "1", "master_address": "0", "link_function": "NOT_SUPPORTED"}},
+			ok:       true,
+		},
+		{
+			name:     "echoed request",
+			request:  request,
+			response: request,
+			ok:       false,
+		},
+		{
+			name:     "other protocol",
+			request:  request,
+			response: []byte("HTTP/1.1 400 Bad Request\r\n"),
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseDNP3(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package proto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DNS_TYPE_A    = 1
+	DNS_TYPE_PTR  = 12
+	DNS_TYPE_TXT  = 16
+	DNS_TYPE_AAAA = 28
+	DNS_TYPE_SRV  = 33
+	DNS_TYPE_OPT  = 41
+
+	DNS_CLASS_INTERNET = 1
+	DNS_CLASS_CHAOS    = 3
+
+	DNS_OPTION_NSID = 3
+
+	DNS_FLAG_RESPONSE      = 0x8000
+	DNS_FLAG_AUTHORITATIVE = 0x0400
+	DNS_FLAG_RECURSION     = 0x0100 // Recursion desired
+	DNS_FLAG_RECURSIVE     = 0x0080 // Recursion available
+	DNS_OPCODE_MASK        = 0x7800
+
+	DNS_HEADER_LENGTH = 12
+)
+
+var (
+	DNS_RCODES = map[int]string{
+		0: "NOERROR",
+		1: "FORMERR",
+		2: "SERVFAIL",
+		3: "NXDOMAIN",
+		4: "NOTIMP",
+		5: "REFUSED",
+	}
+)
+
+// dnsRecord is a resource record from a DNS message
+type dnsRecord struct {
+	name    string
+	rtype   uint16
+	class   uint16
+	data    string            // Decoded name for PTR records, and target for SRV records
+	port    uint16            // Port of SRV records
+	texts   []string          // Character strings for TXT records
+	options map[uint16][]byte // EDNS options of OPT records
+	raw     []byte            // Record data as received
+}
+
+// dnsMessage is the subset of a DNS response needed to extract information
+type dnsMessage struct {
+	flags   uint16
+	answers int
+	records []dnsRecord // Answer, authority and additional records
+}
+
+// readDNSName decodes a possibly compressed domain name starting at offset,
+// returning the name and the offset following it
+func readDNSName(message []byte, offset int) (name string, next int, ok bool) {
+
+	var labels []string
+
+	next = -1
+
+	// Bound the number of labels so compression loops cannot hang the parser
+	for jumps := 0; jumps < 128; jumps++ {
+
+		if offset >= len(message) {
+			return
+		}
+		length := int(message[offset])
+
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, true
+
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(message) {
+				return
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(message[offset:offset+2]) & 0x3fff)
+
+		default:
+			if offset+1+length > len(message) {
+				return
+			}
+			labels = append(labels, string(message[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+	return
+}
+
+// parseDNS reads the header and records of a DNS message, stopping at the
+// first malformed record
+func parseDNS(message []byte) (parsed dnsMessage, ok bool) {
+
+	if len(message) < DNS_HEADER_LENGTH {
+		return
+	}
+	parsed.flags = binary.BigEndian.Uint16(message[2:4])
+
+	// Only responses are of interest
+	if parsed.flags&DNS_FLAG_RESPONSE == 0 {
+		return
+	}
+	questions := int(binary.BigEndian.Uint16(message[4:6]))
+	parsed.answers = int(binary.BigEndian.Uint16(message[6:8]))
+	records := parsed.answers +
+		int(binary.BigEndian.Uint16(message[8:10])) +
+		int(binary.BigEndian.Uint16(message[10:12]))
+	offset := DNS_HEADER_LENGTH
+
+	for i := 0; i < questions; i++ {
+		if _, offset, ok = readDNSName(message, offset); !ok || offset+4 > len(message) {
+			return parsed, true
+		}
+		offset += 4
+	}
+
+	for i := 0; i < records; i++ {
+		var record dnsRecord
+
+		if record.name, offset, ok = readDNSName(message, offset); !ok || offset+10 > len(message) {
+			break
+		}
+		record.rtype = binary.BigEndian.Uint16(message[offset : offset+2])
+		record.class = binary.BigEndian.Uint16(message[offset+2:offset+4]) & 0x7fff
+		length := int(binary.BigEndian.Uint16(message[offset+8 : offset+10]))
+		offset += 10
+
+		if offset+length > len(message) {
+			break
+		}
+		data := message[offset : offset+length]
+		record.raw = data
+
+		switch record.rtype {
+		case DNS_TYPE_PTR:
+			record.data, _, _ = readDNSName(message, offset)
+
+		case DNS_TYPE_SRV:
+			if len(data) >= 6 {
+				record.port = binary.BigEndian.Uint16(data[4:6])
+				record.data, _, _ = readDNSName(message, offset+6)
+			}
+
+		case DNS_TYPE_TXT:
+			for len(data) > 0 && 1+int(data[0]) <= len(data) {
+				record.texts = append(record.texts, printable(string(data[1:1+int(data[0])])))
+				data = data[1+int(data[0]):]
+			}
+
+		case DNS_TYPE_OPT:
+			record.options = make(map[uint16][]byte)
+
+			for len(data) >= 4 && 4+int(binary.BigEndian.Uint16(data[2:4])) <= len(data) {
+				end := 4 + int(binary.BigEndian.Uint16(data[2:4]))
+				record.options[binary.BigEndian.Uint16(data[0:2])] = data[4:end]
+				data = data[end:]
+			}
+		}
+		parsed.records = append(parsed.records, record)
+		offset += length
+	}
+	return parsed, true
+}
+
+// dnsQuestionClass returns the class of the first question of a DNS message
+func dnsQuestionClass(message []byte) (class uint16, ok bool) {
+
+	if len(message) < DNS_HEADER_LENGTH || binary.BigEndian.Uint16(message[4:6]) == 0 {
+		return
+	}
+	_, offset, ok := readDNSName(message, DNS_HEADER_LENGTH)
+
+	if !ok || offset+4 > len(message) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(message[offset+2 : offset+4]), true
+}
+
+// ParseDNS extracts the server version and host name from version.bind and
+// hostname.bind responses, and the server identifier of NSID options. It notes
+// the response code, whether recursion is offered, and whether the server
+// answered a recursive query, making it an open resolver, or authoritatively.
+func ParseDNS(request []byte, response []byte) (info Info, ok bool) {
+
+	message, ok := parseDNS(response)
+
+	if !ok {
+		return
+	}
+	rcode := int(message.flags & 0x000f)
+
+	if name, known := DNS_RCODES[rcode]; known {
+		info.setMetadata("rcode", name)
+	}
+	if message.flags&DNS_FLAG_RECURSIVE != 0 {
+		info.setMetadata("recursion", "available")
+	}
+
+	// Only internet class questions tell resolvers and authoritative servers
+	// apart, since servers answer CHAOS queries about themselves either way
+	class, known := dnsQuestionClass(request)
+
+	if !known {
+		class, _ = dnsQuestionClass(response)
+	}
+	if class == DNS_CLASS_INTERNET {
+		if message.flags&DNS_FLAG_AUTHORITATIVE != 0 {
+			info.setMetadata("authoritative", "true")
+
+		} else if len(request) >= 4 && binary.BigEndian.Uint16(request[2:4])&DNS_FLAG_RECURSION != 0 &&
+			message.flags&DNS_FLAG_RECURSIVE != 0 && rcode == 0 && message.answers > 0 {
+
+			info.setMetadata("open_resolver", "true")
+		}
+	}
+
+	for _, record := range message.records {
+		switch {
+		case record.rtype == DNS_TYPE_TXT && record.class == DNS_CLASS_CHAOS && strings.EqualFold(record.name, "version.bind"):
+			info.Version = strings.Join(record.texts, " ")
+
+		case record.rtype == DNS_TYPE_TXT && record.class == DNS_CLASS_CHAOS &&
+			(strings.EqualFold(record.name, "hostname.bind") || strings.EqualFold(record.name, "id.server")):
+
+			info.setMetadata("hostname", strings.Join(record.texts, " "))
+
+		case record.rtype == DNS_TYPE_OPT:
+			if nsid := record.options[DNS_OPTION_NSID]; len(nsid) > 0 {
+				if text := printable(string(nsid)); len(text) == len(nsid) {
+					info.setMetadata("nsid", text)
+				} else {
+					info.setMetadata("nsid", hex.EncodeToString(nsid))
+				}
+			}
+		}
+	}
+	return info, true
+}
+
+// ParseMDNS extracts host names, advertised services, the hosts and ports of
+// service instances and TXT record contents from mDNS responses
+func ParseMDNS(request []byte, response []byte) (info Info, ok bool) {
+
+	message, ok := parseDNS(response)
+
+	if !ok {
+		return
+	}
+	var services, instances, texts []string
+
+	for _, record := range message.records {
+		switch record.rtype {
+		case DNS_TYPE_PTR:
+			if strings.HasSuffix(record.name, ".in-addr.arpa") || strings.HasSuffix(record.name, ".ip6.arpa") {
+				info.setMetadata("hostname", record.data)
+			} else if record.data != "" {
+				services = append(services, record.data)
+			}
+
+		case DNS_TYPE_SRV:
+			if record.data != "" {
+				instances = append(instances, record.name+"="+record.data+":"+strconv.Itoa(int(record.port)))
+			}
+
+		case DNS_TYPE_TXT:
+			for _, text := range record.texts {
+				if text != "" {
+					texts = append(texts, text)
+				}
+			}
+		}
+	}
+	sort.Strings(services)
+	sort.Strings(instances)
+
+	info.setMetadata("services", strings.Join(services, ","))
+	info.setMetadata("instances", strings.Join(instances, ","))
+	info.Banner = strings.Join(texts, "; ")
+
+	return info, true
+}
@@ -0,0 +1,228 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dnsName encodes a domain name without compression
+func dnsName(name string) []byte {
+
+	var encoded []byte
+
+	for _, label := range strings.Split(name, ".") {
+		if label != "" {
+			encoded = append(encoded, byte(len(label)))
+			encoded = append(encoded, label...)
+		}
+	}
+	return append(encoded, 0)
+}
+
+// dnsResponse builds a response with one question and the given records
+func dnsResponse(flags uint16, question string, records ...[]byte) []byte {
+
+	header := make([]byte, DNS_HEADER_LENGTH)
+	binary.BigEndian.PutUint16(header[2:4], flags)
+	binary.BigEndian.PutUint16(header[4:6], 1)
+	binary.BigEndian.PutUint16(header[6:8], uint16(len(records)))
+
+	message := append(header, dnsName(question)...)
+	message = append(message, 0x00, 0x10, 0x00, 0x03)
+
+	for _, record := range records {
+		message = append(message, record...)
+	}
+	return message
+}
+
+// dnsQuery builds a query with one question
+func dnsQuery(flags uint16, question string, qtype uint16, class uint16) []byte {
+
+	header := make([]byte, DNS_HEADER_LENGTH)
+	binary.BigEndian.PutUint16(header[2:4], flags)
+	binary.BigEndian.PutUint16(header[4:6], 1)
+
+	message := append(header, dnsName(question)...)
+	return append(message, byte(qtype>>8), byte(qtype), byte(class>>8), byte(class))
+}
+
+// dnsRR encodes a resource record with an already encoded name
+func dnsRR(name []byte, rtype uint16, class uint16, data []byte) []byte {
+
+	record := append([]byte(nil), name...)
+	fields := make([]byte, 10)
+	binary.BigEndian.PutUint16(fields[0:2], rtype)
+	binary.BigEndian.PutUint16(fields[2:4], class)
+	binary.BigEndian.PutUint16(fields[8:10], uint16(len(data)))
+
+	return append(append(record, fields...), data...)
+}
+
+func TestReadDNSName(t *testing.T) {
+
+	// A name at offset 2, then a pointer to it at offset 15, then a label
+	// followed by a pointer at offset 17, then a pointer to itself at 25
+	message := append([]byte{0xff, 0xff}, dnsName("example.com")...)
+	message = append(message, 0xc0, 0x02)
+	message = append(message, 3, 'w', 'w', 'w', 0xc0, 0x02)
+	message = append(message, 0xc0, 25)
+
+	tests := []struct {
+		offset int
+		name   string
+		next   int
+		ok     bool
+	}{
+		{offset: 2, name: "example.com", next: 15, ok: true},
+		{offset: 15, name: "example.com", next: 17, ok: true},
+		{offset: 17, name: "www.example.com", next: 23, ok: true},
+		{offset: 25, ok: false},
+		{offset: 6, ok: false},
+		{offset: len(message), ok: false},
+	}
+
+	for _, test := range tests {
+		name, next, ok := readDNSName(message, test.offset)
+
+		if ok != test.ok || (ok && (name != test.name || next != test.next)) {
+			t.Errorf("readDNSName at %d = %q, %d, %v, want %q, %d, %v", test.offset, name, next, ok, test.name, test.next, test.ok)
+		}
+	}
+}
+
+func TestParseDNS(t *testing.T) {
+
+	versionBind := dnsRR(dnsName("version.bind"), DNS_TYPE_TXT, DNS_CLASS_CHAOS, []byte("\x069.18.1\x06ubuntu"))
+	hostnameBind := dnsRR(dnsName("hostname.bind"), DNS_TYPE_TXT, DNS_CLASS_CHAOS, []byte("\x03ns1"))
+	exampleA := dnsRR(dnsName("example.com"), 1, DNS_CLASS_INTERNET, []byte{93, 184, 215, 14})
+	nsid := func(id string) []byte {
+		return dnsRR([]byte{0}, DNS_TYPE_OPT, 1232, append([]byte{0x00, 0x03, 0x00, byte(len(id))}, id...))
+	}
+	recursiveQuery := dnsQuery(0x0100, "example.com", 1, DNS_CLASS_INTERNET)
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "version.bind",
+			response: dnsResponse(0x8580, "version.bind", versionBind),
+			info: Info{
+				Version:  "9.18.1 ubuntu",
+				Metadata: map[string]string{"rcode": "NOERROR", "recursion": "available"},
+			},
+			ok: true,
+		},
+		{
+			name:     "refused",
+			response: dnsResponse(0x8105, "version.bind"),
+			info:     Info{Metadata: map[string]string{"rcode": "REFUSED"}},
+			ok:       true,
+		},
+		{
+			name:     "internet class TXT",
+			response: dnsResponse(0x8400, "version.bind", dnsRR(dnsName("version.bind"), DNS_TYPE_TXT, 1, []byte("\x03abc"))),
+			info:     Info{Metadata: map[string]string{"rcode": "NOERROR"}},
+			ok:       true,
+		},
+		{
+			name:     "hostname.bind with NSID",
+			request:  dnsQuery(0x0000, "hostname.bind", DNS_TYPE_TXT, DNS_CLASS_CHAOS),
+			response: dnsResponse(0x8400, "hostname.bind", hostnameBind, nsid("ns1.ams")),
+			info:     Info{Metadata: map[string]string{"rcode": "NOERROR", "hostname": "ns1", "nsid": "ns1.ams"}},
+			ok:       true,
+		},
+		{
+			name:     "binary NSID",
+			request:  dnsQuery(0x0000, "hostname.bind", DNS_TYPE_TXT, DNS_CLASS_CHAOS),
+			response: dnsResponse(0x8400, "hostname.bind", nsid("\x01\x02")),
+			info:     Info{Metadata: map[string]string{"rcode": "NOERROR", "nsid": "0102"}},
+			ok:       true,
+		},
+		{
+			name:     "open resolver",
+			request:  recursiveQuery,
+			response: dnsResponse(0x8180, "example.com", exampleA),
+			info:     Info{Metadata: map[string]string{"rcode": "NOERROR", "recursion": "available", "open_resolver": "true"}},
+			ok:       true,
+		},
+		{
+			name:     "recursion refused",
+			request:  recursiveQuery,
+			response: dnsResponse(0x8185, "example.com"),
+			info:     Info{Metadata: map[string]string{"rcode": "REFUSED", "recursion": "available"}},
+			ok:       true,
+		},
+		{
+			name:     "authoritative answer",
+			request:  recursiveQuery,
+			response: dnsResponse(0x8500, "example.com", exampleA),
+			info:     Info{Metadata: map[string]string{"rcode": "NOERROR", "authoritative": "true"}},
+			ok:       true,
+		},
+		{
+			name:     "truncated record",
+			response: dnsResponse(0x8400, "version.bind", versionBind[:20]),
+			info:     Info{Metadata: map[string]string{"rcode": "NOERROR"}},
+			ok:       true,
+		},
+		{name: "query", response: dnsResponse(0x0100, "version.bind"), ok: false},
+		{name: "short", response: []byte{0x00, 0x01, 0x80}, ok: false},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseDNS(test.request, test.response)
+
+		if ok != test.ok {
+			t.Errorf("%s: ok = %v, want %v", test.name, ok, test.ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, want %+v", test.name, info, test.info)
+		}
+	}
+}
+
+func TestParseMDNS(t *testing.T) {
+
+	ptrName := dnsName("1.0.168.192.in-addr.arpa")
+	services := dnsName("_services._dns-sd._udp.local")
+
+	response := dnsResponse(0x8400, "1.0.168.192.in-addr.arpa",
+		dnsRR(ptrName, DNS_TYPE_PTR, 1, dnsName("printer.local")),
+		dnsRR(services, DNS_TYPE_PTR, 1, dnsName("_ipp._tcp.local")),
+		dnsRR(services, DNS_TYPE_PTR, 1, dnsName("_http._tcp.local")),
+		dnsRR(dnsName("printer._ipp._tcp.local"), DNS_TYPE_TXT, 1, []byte("\x0dty=LaserJet 4\x00\x06rp=ipp")),
+		dnsRR(dnsName("printer._ipp._tcp.local"), DNS_TYPE_SRV, 1, append([]byte{0, 0, 0, 0, 0x02, 0x77}, dnsName("printer.local")...)),
+		dnsRR(dnsName("web._http._tcp.local"), DNS_TYPE_SRV, 1, append([]byte{0, 0, 0, 0, 0x00, 0x50}, dnsName("printer.local")...)),
+		dnsRR(dnsName("broken._http._tcp.local"), DNS_TYPE_SRV, 1, []byte{0, 0, 0, 0}),
+	)
+
+	info, ok := ParseMDNS(nil, response)
+
+	if !ok {
+		t.Fatal("ParseMDNS failed")
+	}
+	want := Info{
+		Banner: "ty=LaserJet 4; rp=ipp",
+		Metadata: map[string]string{
+			"hostname":  "printer.local",
+			"services":  "_http._tcp.local,_ipp._tcp.local",
+			"instances": "printer._ipp._tcp.local=printer.local:631,web._http._tcp.local=printer.local:80",
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+
+	if _, ok := ParseMDNS(nil, bytes.Repeat([]byte{0}, DNS_HEADER_LENGTH)); ok {
+		t.Error("ParseMDNS accepted a query")
+	}
+}
@@ -0,0 +1,283 @@
+package proto
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DTLS_RECORD_HEADER_LENGTH    = 13
+	DTLS_HANDSHAKE_HEADER_LENGTH = 12
+	DTLS_MESSAGE_LIMIT           = 0x10000 // Largest handshake message reassembled
+
+	DTLS_CONTENT_ALERT     = 21
+	DTLS_CONTENT_HANDSHAKE = 22
+
+	DTLS_HANDSHAKE_CLIENT_HELLO         = 1
+	DTLS_HANDSHAKE_SERVER_HELLO         = 2
+	DTLS_HANDSHAKE_HELLO_VERIFY_REQUEST = 3
+	DTLS_HANDSHAKE_CERTIFICATE          = 11
+	DTLS_HANDSHAKE_SERVER_HELLO_DONE    = 14
+)
+
+var (
+	// DTLS_VERSIONS maps DTLS protocol versions to their names
+	DTLS_VERSIONS = map[uint16]string{
+		0xfeff: "1.0",
+		0xfefd: "1.2",
+		0xfefc: "1.3",
+	}
+	// TLS_ALERTS maps the descriptions of the alerts servers commonly reject a
+	// handshake with to their names
+	TLS_ALERTS = map[byte]string{
+		10:  "unexpected_message",
+		40:  "handshake_failure",
+		47:  "illegal_parameter",
+		70:  "protocol_version",
+		71:  "insufficient_security",
+		80:  "internal_error",
+		110: "unsupported_extension",
+		112: "unrecognized_name",
+		120: "no_application_protocol",
+	}
+)
+
+// dtlsHandshake is a reassembled handshake message
+type dtlsHandshake struct {
+	kind byte
+	body []byte
+}
+
+// ParseDTLS extracts the DTLS version and cipher suite the server chose, and
+// the subject, issuer, expiry and names of its certificate, from the flight of
+// records answering a ClientHello. Alerts rejecting the handshake are reported
+// too.
+func ParseDTLS(request []byte, response []byte) (info Info, ok bool) {
+
+	handshakes, alert, ok := readDTLS(response)
+
+	if !ok {
+		return
+	}
+	info.setMetadata("alert", alert)
+
+	for _, handshake := range handshakes {
+		body := handshake.body
+
+		switch handshake.kind {
+		case DTLS_HANDSHAKE_SERVER_HELLO:
+			// The version and random precede the session ID and cipher suite
+			if len(body) < 35 {
+				continue
+			}
+			info.setMetadata("dtls_version", dtlsVersion(binary.BigEndian.Uint16(body[0:2])))
+
+			if offset := 35 + int(body[34]); len(body) >= offset+2 {
+				info.setMetadata("cipher", tls.CipherSuiteName(binary.BigEndian.Uint16(body[offset:offset+2])))
+			}
+
+		case DTLS_HANDSHAKE_CERTIFICATE:
+			// The server's own certificate comes first in the chain
+			if len(body) < 6 {
+				continue
+			}
+			length := int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+
+			if length > len(body)-6 {
+				continue
+			}
+			certificate, err := x509.ParseCertificate(body[6 : 6+length])
+
+			if err != nil {
+				continue
+			}
+			info.setMetadata("subject", printable(certificate.Subject.String()))
+			info.setMetadata("issuer", printable(certificate.Issuer.String()))
+			info.setMetadata("not_after", certificate.NotAfter.UTC().Format("2006-01-02"))
+			info.setMetadata("sans", printable(strings.Join(certificate.DNSNames, ",")))
+
+			if info.Banner = printable(certificate.Subject.CommonName); info.Banner == "" {
+				info.Banner = info.Metadata["subject"]
+			}
+		}
+	}
+	return info, !info.Empty()
+}
+
+// NextDTLS answers a HelloVerifyRequest by resending the ClientHello with the
+// cookie the server asked for, as the next message of the handshake
+func NextDTLS(request []byte, response []byte) (next []byte, ok bool) {
+
+	handshakes, _, _ := readDTLS(response)
+	var cookie []byte
+
+	for _, handshake := range handshakes {
+		if handshake.kind == DTLS_HANDSHAKE_HELLO_VERIFY_REQUEST && len(handshake.body) >= 3 && len(handshake.body) >= 3+int(handshake.body[2]) {
+			cookie, ok = handshake.body[3:3+int(handshake.body[2])], true
+			break
+		}
+	}
+	if !ok || len(request) < DTLS_RECORD_HEADER_LENGTH+DTLS_HANDSHAKE_HEADER_LENGTH {
+		return nil, false
+	}
+	record, message := request[:DTLS_RECORD_HEADER_LENGTH], request[DTLS_RECORD_HEADER_LENGTH:]
+	length := int(message[1])<<16 | int(message[2])<<8 | int(message[3])
+
+	// Only unfragmented ClientHellos are resent
+	if record[0] != DTLS_CONTENT_HANDSHAKE || message[0] != DTLS_HANDSHAKE_CLIENT_HELLO ||
+		length != len(message)-DTLS_HANDSHAKE_HEADER_LENGTH || !allZero(message[6:9]) {
+		return nil, false
+	}
+	body := message[DTLS_HANDSHAKE_HEADER_LENGTH:]
+
+	// The version and random precede the session ID and the cookie
+	if len(body) < 35 || len(body) < 35+int(body[34])+1 {
+		return nil, false
+	}
+	offset := 35 + int(body[34])
+	cookieEnd := offset + 1 + int(body[offset])
+
+	if cookieEnd > len(body) {
+		return nil, false
+	}
+	hello := make([]byte, 0, offset+1+len(cookie)+len(body)-cookieEnd)
+	hello = append(hello, body[:offset]...)
+	hello = append(hello, byte(len(cookie)))
+	hello = append(hello, cookie...)
+	hello = append(hello, body[cookieEnd:]...)
+
+	next = make([]byte, DTLS_RECORD_HEADER_LENGTH+DTLS_HANDSHAKE_HEADER_LENGTH, DTLS_RECORD_HEADER_LENGTH+DTLS_HANDSHAKE_HEADER_LENGTH+len(hello))
+	copy(next, request[:DTLS_RECORD_HEADER_LENGTH+DTLS_HANDSHAKE_HEADER_LENGTH])
+	next = append(next, hello...)
+
+	// The record and message sequence numbers both advance
+	sequence := uint64(record[5])<<40 | uint64(binary.BigEndian.Uint32(record[6:10]))<<8 | uint64(record[10]) + 1
+	next[5] = byte(sequence >> 40)
+	binary.BigEndian.PutUint32(next[6:10], uint32(sequence>>8))
+	next[10] = byte(sequence)
+	binary.BigEndian.PutUint16(next[11:13], uint16(DTLS_HANDSHAKE_HEADER_LENGTH+len(hello)))
+
+	handshake := next[DTLS_RECORD_HEADER_LENGTH:]
+	putUint24(handshake[1:4], len(hello))
+	binary.BigEndian.PutUint16(handshake[4:6], binary.BigEndian.Uint16(message[4:6])+1)
+	putUint24(handshake[9:12], len(hello))
+
+	return next, true
+}
+
+// DoneDTLS reports whether the server finished its flight of handshake
+// messages, or rejected the handshake with an alert
+func DoneDTLS(responses []byte) bool {
+
+	handshakes, alert, _ := readDTLS(responses)
+
+	if alert != "" {
+		return true
+	}
+	for _, handshake := range handshakes {
+		if handshake.kind == DTLS_HANDSHAKE_SERVER_HELLO_DONE {
+			return true
+		}
+	}
+	return false
+}
+
+// readDTLS reassembles the unencrypted handshake messages of a sequence of
+// DTLS records, in the order of their message sequence numbers, and returns
+// the description of the first alert. It returns false if data does not start
+// with a DTLS record.
+func readDTLS(data []byte) (handshakes []dtlsHandshake, alert string, ok bool) {
+
+	type fragments struct {
+		kind     byte
+		body     []byte
+		received []bool
+	}
+	messages := make(map[uint16]*fragments)
+
+	for len(data) >= DTLS_RECORD_HEADER_LENGTH {
+		kind, version := data[0], binary.BigEndian.Uint16(data[1:3])
+		length := int(binary.BigEndian.Uint16(data[11:13]))
+
+		if (kind < 20 || kind > 25) || version>>8 != 0xfe || DTLS_RECORD_HEADER_LENGTH+length > len(data) {
+			break
+		}
+		epoch, fragment := binary.BigEndian.Uint16(data[3:5]), data[DTLS_RECORD_HEADER_LENGTH:DTLS_RECORD_HEADER_LENGTH+length]
+		data = data[DTLS_RECORD_HEADER_LENGTH+length:]
+		ok = true
+
+		// Records of later epochs are encrypted
+		if epoch != 0 {
+			continue
+		}
+		if kind == DTLS_CONTENT_ALERT && len(fragment) >= 2 && alert == "" {
+			alert = tlsAlert(fragment[1])
+		}
+		// A record can hold several handshake messages, or fragments of them
+		for kind == DTLS_CONTENT_HANDSHAKE && len(fragment) >= DTLS_HANDSHAKE_HEADER_LENGTH {
+			length := int(fragment[1])<<16 | int(fragment[2])<<8 | int(fragment[3])
+			sequence := binary.BigEndian.Uint16(fragment[4:6])
+			offset := int(fragment[6])<<16 | int(fragment[7])<<8 | int(fragment[8])
+			size := int(fragment[9])<<16 | int(fragment[10])<<8 | int(fragment[11])
+
+			if length > DTLS_MESSAGE_LIMIT || offset+size > length || DTLS_HANDSHAKE_HEADER_LENGTH+size > len(fragment) {
+				break
+			}
+			message, found := messages[sequence]
+
+			if !found {
+				message = &fragments{kind: fragment[0], body: make([]byte, length), received: make([]bool, length)}
+				messages[sequence] = message
+			}
+			if message.kind == fragment[0] && len(message.body) == length {
+				copy(message.body[offset:], fragment[DTLS_HANDSHAKE_HEADER_LENGTH:DTLS_HANDSHAKE_HEADER_LENGTH+size])
+
+				for i := offset; i < offset+size; i++ {
+					message.received[i] = true
+				}
+			}
+			fragment = fragment[DTLS_HANDSHAKE_HEADER_LENGTH+size:]
+		}
+	}
+
+	sequences := make([]int, 0, len(messages))
+
+	for sequence := range messages {
+		sequences = append(sequences, int(sequence))
+	}
+	sort.Ints(sequences)
+
+	for _, sequence := range sequences {
+		message := messages[uint16(sequence)]
+		complete := true
+
+		for _, received := range message.received {
+			complete = complete && received
+		}
+		if complete {
+			handshakes = append(handshakes, dtlsHandshake{kind: message.kind, body: message.body})
+		}
+	}
+	return handshakes, alert, ok
+}
+
+// dtlsVersion names a DTLS protocol version
+func dtlsVersion(version uint16) string {
+
+	if name, found := DTLS_VERSIONS[version]; found {
+		return name
+	}
+	return strconv.Itoa(int(version>>8)) + "." + strconv.Itoa(int(version&0xff))
+}
+
+// putUint24 writes a 24 bit big endian length
+func putUint24(b []byte, value int) {
+
+	b[0] = byte(value >> 16)
+	b[1] = byte(value >> 8)
+	b[2] = byte(value)
+}
@@ -0,0 +1,176 @@
+package proto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// dtlsRecord builds a DTLS record of the given epoch and sequence number
+func dtlsRecord(kind byte, version uint16, epoch uint16, sequence byte, fragment []byte) []byte {
+
+	record := []byte{kind, byte(version >> 8), byte(version), byte(epoch >> 8), byte(epoch), 0, 0, 0, 0, 0, sequence, 0, 0}
+	binary.BigEndian.PutUint16(record[11:13], uint16(len(fragment)))
+
+	return append(record, fragment...)
+}
+
+// dtlsFragment builds a fragment of a handshake message
+func dtlsFragment(kind byte, sequence uint16, body []byte, offset int, size int) []byte {
+
+	header := make([]byte, DTLS_HANDSHAKE_HEADER_LENGTH)
+	header[0] = kind
+	putUint24(header[1:4], len(body))
+	binary.BigEndian.PutUint16(header[4:6], sequence)
+	putUint24(header[6:9], offset)
+	putUint24(header[9:12], size)
+
+	return append(header, body[offset:offset+size]...)
+}
+
+// dtlsMessage builds an unfragmented handshake message
+func dtlsMessage(kind byte, sequence uint16, body []byte) []byte {
+	return dtlsFragment(kind, sequence, body, 0, len(body))
+}
+
+// dtlsClientHello builds a ClientHello with the given cookie
+func dtlsClientHello(cookie []byte) []byte {
+
+	body := append([]byte{0xfe, 0xfd}, make([]byte, 32)...)
+	body = append(body, 0, byte(len(cookie)))
+	body = append(body, cookie...)
+	body = append(body, 0x00, 0x02, 0xc0, 0x2f, 0x01, 0x00)
+
+	return dtlsRecord(DTLS_CONTENT_HANDSHAKE, 0xfeff, 0, 0, dtlsMessage(DTLS_HANDSHAKE_CLIENT_HELLO, 0, body))
+}
+
+func TestParseDTLS(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gw.example.com", Organization: []string{"Example"}},
+		NotBefore:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		DNSNames:     []string{"gw.example.com", "vpn.example.com"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificates := make([]byte, 6, 6+len(der))
+	putUint24(certificates[0:3], 3+len(der))
+	putUint24(certificates[3:6], len(der))
+	certificates = append(certificates, der...)
+
+	serverHello := append([]byte{0xfe, 0xfd}, make([]byte, 32)...)
+	serverHello = append(serverHello, 0, 0xc0, 0x2b, 0x00)
+
+	// The certificate is split across two datagrams, the second of which
+	// also holds the ServerHelloDone
+	half := len(certificates) / 2
+	flight := dtlsRecord(DTLS_CONTENT_HANDSHAKE, 0xfefd, 0, 1, append(
+		dtlsMessage(DTLS_HANDSHAKE_SERVER_HELLO, 1, serverHello),
+		dtlsFragment(DTLS_HANDSHAKE_CERTIFICATE, 2, certificates, 0, half)...))
+	flight = append(flight, dtlsRecord(DTLS_CONTENT_HANDSHAKE, 0xfefd, 0, 2, append(
+		dtlsFragment(DTLS_HANDSHAKE_CERTIFICATE, 2, certificates, half, len(certificates)-half),
+		dtlsMessage(DTLS_HANDSHAKE_SERVER_HELLO_DONE, 3, nil)...))...)
+
+	certificate := map[string]string{
+		"dtls_version": "1.2",
+		"cipher":       "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"subject":      "CN=gw.example.com,O=Example",
+		"issuer":       "CN=gw.example.com,O=Example",
+		"not_after":    "2030-01-01",
+		"sans":         "gw.example.com,vpn.example.com",
+	}
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "server flight",
+			response: flight,
+			info:     Info{Banner: "gw.example.com", Metadata: certificate},
+			ok:       true,
+		},
+		{
+			name:     "server hello without the rest of the certificate",
+			response: flight[:DTLS_RECORD_HEADER_LENGTH+DTLS_HANDSHAKE_HEADER_LENGTH+len(serverHello)+DTLS_HANDSHAKE_HEADER_LENGTH+half],
+			info:     Info{Metadata: map[string]string{"dtls_version": "1.2", "cipher": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}},
+			ok:       true,
+		},
+		{
+			name:     "handshake failure",
+			response: dtlsRecord(DTLS_CONTENT_ALERT, 0xfeff, 0, 0, []byte{2, 40}),
+			info:     Info{Metadata: map[string]string{"alert": "handshake_failure"}},
+			ok:       true,
+		},
+		{
+			name:     "encrypted alert",
+			response: dtlsRecord(DTLS_CONTENT_ALERT, 0xfefd, 1, 0, []byte{0x8f, 0x12, 0x5a, 0x03}),
+			ok:       false,
+		},
+		{
+			name:     "hello verify request",
+			response: dtlsRecord(DTLS_CONTENT_HANDSHAKE, 0xfeff, 0, 0, dtlsMessage(DTLS_HANDSHAKE_HELLO_VERIFY_REQUEST, 0, []byte{0xfe, 0xff, 2, 0xca, 0xfe})),
+			ok:       false,
+		},
+		{
+			name:     "TLS record",
+			response: []byte{0x16, 0x03, 0x03, 0x00, 0x02, 0x02, 0x00},
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseDTLS(nil, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+	if !DoneDTLS(flight) {
+		t.Errorf("server flight: got not done, want done")
+	}
+	if DoneDTLS(flight[:len(flight)/2]) {
+		t.Errorf("half of server flight: got done, want not done")
+	}
+}
+
+func TestNextDTLS(t *testing.T) {
+
+	cookie := []byte{0xca, 0xfe, 0xba, 0xbe}
+	hello := dtlsClientHello(nil)
+	verify := dtlsRecord(DTLS_CONTENT_HANDSHAKE, 0xfeff, 0, 0, dtlsMessage(DTLS_HANDSHAKE_HELLO_VERIFY_REQUEST, 0, append([]byte{0xfe, 0xff, byte(len(cookie))}, cookie...)))
+
+	// The resent ClientHello is the next record and message
+	want := dtlsClientHello(cookie)
+	want[10] = 1
+	want[DTLS_RECORD_HEADER_LENGTH+5] = 1
+
+	if next, ok := NextDTLS(hello, verify); !ok || !bytes.Equal(next, want) {
+		t.Errorf("hello verify request: got %x, %v, want %x, true", next, ok, want)
+	}
+	if next, ok := NextDTLS(hello, dtlsRecord(DTLS_CONTENT_ALERT, 0xfeff, 0, 0, []byte{2, 40})); ok {
+		t.Errorf("alert: got %x, true, want false", next)
+	}
+	if next, ok := NextDTLS(hello[:20], verify); ok {
+		t.Errorf("truncated request: got %x, true, want false", next)
+	}
+}
@@ -0,0 +1,106 @@
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	ENIP_HEADER_LENGTH    = 24
+	ENIP_LIST_IDENTITY    = 0x0063
+	ENIP_ITEM_IDENTITY    = 0x000c
+	ENIP_IDENTITY_LENGTH  = 33
+	ENIP_SOCKET_ADDRESS   = 2
+	ENIP_IDENTITY_PRODUCT = 32
+)
+
+var (
+	// CIP_DEVICE_TYPES maps the CIP device profiles of common devices to their
+	// names
+	CIP_DEVICE_TYPES = map[uint16]string{
+		0x02: "AC Drive",
+		0x07: "General Purpose Discrete I/O",
+		0x0c: "Communications Adapter",
+		0x0e: "Programmable Logic Controller",
+		0x18: "Human-Machine Interface",
+		0x2b: "Generic Device",
+	}
+	// CIP_VENDORS maps the IDs of common CIP vendors to their names
+	CIP_VENDORS = map[uint16]string{
+		1: "Rockwell Automation/Allen-Bradley",
+	}
+)
+
+// ParseENIP reports the product name, revision, vendor, device type, serial
+// number and IP address that EtherNet/IP devices, such as PLCs and I/O
+// adapters, give in their identity
+func ParseENIP(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < ENIP_HEADER_LENGTH+2 || binary.LittleEndian.Uint16(response[0:2]) != ENIP_LIST_IDENTITY {
+		return
+	}
+	length := int(binary.LittleEndian.Uint16(response[2:4]))
+
+	// Requests carry no data
+	if length == 0 || ENIP_HEADER_LENGTH+length > len(response) || binary.LittleEndian.Uint32(response[8:12]) != 0 {
+		return
+	}
+	items := response[ENIP_HEADER_LENGTH : ENIP_HEADER_LENGTH+length]
+	count := int(binary.LittleEndian.Uint16(items[0:2]))
+	items = items[2:]
+
+	for ; count > 0 && len(items) >= 4; count-- {
+		kind, size := binary.LittleEndian.Uint16(items[0:2]), int(binary.LittleEndian.Uint16(items[2:4]))
+
+		if 4+size > len(items) {
+			break
+		}
+		identity := items[4 : 4+size]
+		items = items[4+size:]
+
+		if kind != ENIP_ITEM_IDENTITY || len(identity) < ENIP_IDENTITY_LENGTH {
+			continue
+		}
+		// The socket address is in network byte order, unlike the rest
+		address := identity[ENIP_SOCKET_ADDRESS : ENIP_SOCKET_ADDRESS+16]
+
+		if ip := net.IP(address[4:8]); !ip.IsUnspecified() {
+			info.setMetadata("device_ip", ip.String())
+		}
+		vendor := binary.LittleEndian.Uint16(identity[18:20])
+		device := binary.LittleEndian.Uint16(identity[20:22])
+
+		info.setMetadata("vendor", cipVendor(vendor))
+		info.setMetadata("device_type", cipDeviceType(device))
+		info.setMetadata("product_code", strconv.Itoa(int(binary.LittleEndian.Uint16(identity[22:24]))))
+		info.setMetadata("serial_number", fmt.Sprintf("0x%08x", binary.LittleEndian.Uint32(identity[28:32])))
+
+		info.Version = fmt.Sprintf("%d.%d", identity[24], identity[25])
+
+		if name := identity[ENIP_IDENTITY_PRODUCT:]; len(name) > int(name[0]) {
+			info.Banner = printable(string(name[1 : 1+int(name[0])]))
+		}
+		return info, true
+	}
+	return
+}
+
+// cipVendor names a CIP vendor by its ID
+func cipVendor(vendor uint16) string {
+
+	if name, found := CIP_VENDORS[vendor]; found {
+		return name
+	}
+	return strconv.Itoa(int(vendor))
+}
+
+// cipDeviceType names a CIP device profile
+func cipDeviceType(device uint16) string {
+
+	if name, found := CIP_DEVICE_TYPES[device]; found {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", device)
+}
@@ -0,0 +1,68 @@
+package proto
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+// enipIdentity encodes a List Identity response with one identity item, of a
+// 1756-L61 ControlLogix controller at 192.168.1.20
+func enipIdentity(name string) []byte {
+
+	identity := []byte{0x01, 0x00, 0x00, 0x02, 0xaf, 0x12, 192, 168, 1, 20, 0, 0, 0, 0, 0, 0, 0, 0}
+	identity = append(identity, 0x01, 0x00, 0x0e, 0x00, 0x36, 0x00, 20, 11, 0x60, 0x30, 0x78, 0x56, 0x34, 0x12)
+	identity = append(append(append(identity, byte(len(name))), name...), 0x03)
+
+	items := append([]byte{0x01, 0x00, 0x0c, 0x00, byte(len(identity)), 0x00}, identity...)
+	header := append([]byte{0x63, 0x00, byte(len(items)), 0x00}, make([]byte, 20)...)
+
+	return append(header, items...)
+}
+
+func TestParseENIP(t *testing.T) {
+
+	request, _ := base64.StdEncoding.DecodeString("YwAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "identity",
+			request:  request,
+			response: enipIdentity("1756-L61/B LOGIX5561"),
+			info: Info{Version: "20.11", Banner: "1756-L61/B LOGIX5561", Metadata: map[string]string{
+				"device_ip":     "192.168.1.20",
+				"vendor":        "Rockwell Automation/Allen-Bradley",
+				"device_type":   "Programmable Logic Controller",
+				"product_code":  "54",
+				"serial_number": "0x12345678",
+			}},
+			ok: true,
+		},
+		{
+			name:     "truncated product name",
+			request:  request,
+			response: enipIdentity("1756-L61/B LOGIX5561")[:60],
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			request:  request,
+			response: request,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseENIP(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
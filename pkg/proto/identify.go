@@ -0,0 +1,126 @@
+package proto
+
+import (
+	"encoding/binary"
+)
+
+// Identifier recognizes the responses of a service without the request they
+// answer
+type Identifier struct {
+	Service string
+	Parse   Parser
+}
+
+var (
+	// IDENTIFIERS are tried in turn on responses that do not match the
+	// service they were meant for, the way nmap soft matches them. Services
+	// whose parsers accept most payloads, such as daytime, QOTD, CoAP or IKE,
+	// are left out, and DNS and NTP responses are checked more strictly.
+	IDENTIFIERS = []Identifier{
+		{"bacnet", ParseBACnet},
+		{"cldap", ParseCLDAP},
+		{"dhcp", ParseDHCP},
+		{"dnp3", ParseDNP3},
+		{"dns", identifyDNS},
+		{"dtls", ParseDTLS},
+		{"enip", ParseENIP},
+		{"geneve", ParseGeneve},
+		{"gre-udp", ParseGREUDP},
+		{"ipmi", ParseIPMI},
+		{"knxnet-ip", ParseKNXnet},
+		{"l2tp", ParseL2TP},
+		{"memcache", ParseMemcache},
+		{"minecraft-bedrock", ParseRakNet},
+		{"mssql", ParseMSSQL},
+		{"nat-pmp", ParseNATPMP},
+		{"netbios", ParseNetBIOS},
+		{"ntp", identifyNTP},
+		{"profinet", ParsePROFINET},
+		{"quake3", ParseQuake},
+		{"radius", ParseRADIUS},
+		{"sip", ParseSIP},
+		{"snmp", ParseSNMP},
+		{"steam", ParseA2S},
+		{"stun", ParseSTUN},
+		{"teamspeak", ParseTeamSpeak},
+		{"tftp", ParseTFTP},
+		{"upnp", ParseUPnP},
+		{"wireguard", ParseWireGuard},
+		{"wsd", ParseWSD},
+	}
+	// PROTOCOL_FAMILIES maps services to the service whose message format they
+	// share, whose responses are not told apart from theirs
+	PROTOCOL_FAMILIES = map[string]string{
+		"ike-natt": "ike",
+		"llmnr":    "dns",
+		"mdns":     "dns",
+		"netbios":  "dns",
+		"snmptrap": "snmp",
+	}
+)
+
+// Identify returns the service of the first identifier that recognizes the
+// response, other than the service it was meant for and those of its family,
+// and records the service it was meant for as the probed service. Empty
+// responses are never identified.
+func Identify(expected string, response []byte) (service string, info Info, ok bool) {
+
+	if len(response) == 0 {
+		return "", Info{}, false
+	}
+	for _, identifier := range IDENTIFIERS {
+
+		if protocolFamily(identifier.Service) == protocolFamily(expected) {
+			continue
+		}
+		if info, ok = identifier.Parse(nil, response); ok {
+			info.setMetadata("probed_service", expected)
+
+			return identifier.Service, info, true
+		}
+	}
+	return "", Info{}, false
+}
+
+// protocolFamily returns the service whose message format the service uses
+func protocolFamily(service string) string {
+
+	if family, ok := PROTOCOL_FAMILIES[service]; ok {
+		return family
+	}
+	return service
+}
+
+// identifyDNS only reads standard query responses holding a single internet
+// or CHAOS class question, since the DNS parser accepts most headers
+func identifyDNS(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < DNS_HEADER_LENGTH || binary.BigEndian.Uint16(response[2:4])&DNS_OPCODE_MASK != 0 ||
+		binary.BigEndian.Uint16(response[4:6]) != 1 {
+		return
+	}
+	if class, known := dnsQuestionClass(response); !known || (class != DNS_CLASS_INTERNET && class != DNS_CLASS_CHAOS) {
+		return
+	}
+	return ParseDNS(request, response)
+}
+
+// identifyNTP only reads server and broadcast packets of a known version with
+// a valid stratum and transmit time, with or without a MAC, since the NTP
+// parser accepts any mode
+func identifyNTP(request []byte, response []byte) (info Info, ok bool) {
+
+	// The key ID and an MD5 or SHA-1 digest may follow the header
+	if length := len(response); length != NTP_HEADER_LENGTH && length != NTP_HEADER_LENGTH+20 && length != NTP_HEADER_LENGTH+24 {
+		return
+	}
+	if binary.BigEndian.Uint64(response[40:48]) == 0 {
+		return
+	}
+	version, mode := int(response[0]>>3)&0x07, int(response[0])&0x07
+
+	if version < 1 || version > 4 || (mode != NTP_MODE_SERVER && mode != NTP_MODE_BROADCAST) || response[1] > 16 {
+		return
+	}
+	return ParseNTP(request, response)
+}
@@ -0,0 +1,77 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIdentify(t *testing.T) {
+
+	ntp := ntpServerResponse(2, []byte{10, 0, 0, 1})
+	ntp[47] = 1
+
+	tests := []struct {
+		name     string
+		expected string
+		response []byte
+		service  string
+		info     Info
+		ok       bool
+	}{
+		{
+			"NTP answering SNMP",
+			"snmp",
+			ntp,
+			"ntp",
+			Info{Metadata: map[string]string{"ntp_version": "4", "stratum": "2", "refid": "10.0.0.1", "probed_service": "snmp"}},
+			true,
+		},
+		{
+			"NTP without a transmit time",
+			"snmp",
+			ntpServerResponse(2, []byte{10, 0, 0, 1}),
+			"",
+			Info{},
+			false,
+		},
+		{
+			"DNS answering SIP",
+			"sip",
+			dnsResponse(DNS_FLAG_RESPONSE, "version.bind"),
+			"dns",
+			Info{Metadata: map[string]string{"rcode": "NOERROR", "probed_service": "sip"}},
+			true,
+		},
+		{
+			"DNS answering mDNS",
+			"mdns",
+			dnsResponse(DNS_FLAG_RESPONSE, "version.bind"),
+			"",
+			Info{},
+			false,
+		},
+		{
+			"empty response",
+			"dns",
+			nil,
+			"",
+			Info{},
+			false,
+		},
+		{
+			"text",
+			"snmp",
+			[]byte("Thu Oct 16 12:00:00 2026\r\n"),
+			"",
+			Info{},
+			false,
+		},
+	}
+	for _, test := range tests {
+		service, info, ok := Identify(test.expected, test.response)
+
+		if service != test.service || ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %s, %+v, %v, want %s, %+v, %v", test.name, service, info, ok, test.service, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package proto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+const (
+	IKE_HEADER_LENGTH = 28
+	IKE_NAT_T_MARKER  = 4 // Zero bytes preceding IKE messages on the NAT traversal port
+
+	IKE_EXCHANGE_AGGRESSIVE = 4
+
+	IKE_ATTRIBUTE_KEY_LENGTH = 14
+
+	IKE_PAYLOAD_SA        = 1
+	IKE_PAYLOAD_KE        = 4
+	IKE_PAYLOAD_HASH      = 8
+	IKE_PAYLOAD_NOTIFY    = 11
+	IKE_PAYLOAD_VENDOR_ID = 13
+
+	IKEV2_PAYLOAD_SA        = 33
+	IKEV2_PAYLOAD_NOTIFY    = 41
+	IKEV2_PAYLOAD_VENDOR_ID = 43
+)
+
+var (
+	// IKE_VENDOR_IDS maps the prefixes of well known vendor IDs to the vendor
+	// or capability they announce
+	IKE_VENDOR_IDS = []struct {
+		prefix string
+		name   string
+	}{
+		{"1e2b516905991c7d7c96fcbfb587e461", "Microsoft Windows"},
+		{"12f5f28c457168a9702d9fe274cc", "Cisco Unity"},
+		{"1f07f70eaa6514d3b0fa96542a50", "Cisco VPN Concentrator"},
+		{"f4ed19e0c114eb516faaac0ee37daf28", "Check Point Firewall-1"},
+		{"882fe56d6fd20dbc2251613b2ebe5beb", "strongSwan"},
+		{"4865617274426561745f4e6f74696679", "Heartbeat Notify"},
+		{"09002689dfd6b712", "XAUTH"},
+		{"afcad71368a1f1c96b8696fc775701", "Dead Peer Detection"},
+		{"4a131c81070358455c5728f20e95452f", "RFC 3947 NAT-T"},
+		{"90cb80913ebb696e086381b5ec427b1f", "draft-ietf-ipsec-nat-t-ike-02"},
+		{"4048b7d56ebce88525e7de7f00d6c2d3", "IKE Fragmentation"},
+	}
+	// IKE_ATTRIBUTES maps IKEv1 transform attribute types to their names and
+	// the names of their values
+	IKE_ATTRIBUTES = map[uint16]struct {
+		name   string
+		values map[int]string
+	}{
+		1: {"Enc", map[int]string{1: "DES", 5: "3DES", 7: "AES"}},
+		2: {"Hash", map[int]string{1: "MD5", 2: "SHA1", 4: "SHA2-256", 5: "SHA2-384", 6: "SHA2-512"}},
+		3: {"Auth", map[int]string{1: "PSK", 3: "RSA_Sig", 64221: "Hybrid_RSA", 65001: "XAUTH_PSK", 65005: "XAUTH_RSA"}},
+		4: {"Group", map[int]string{1: "1:modp768", 2: "2:modp1024", 5: "5:modp1536", 14: "14:modp2048", 19: "19:ecp256"}},
+	}
+	// IKEV2_TRANSFORMS maps IKEv2 transform types to their names and the names
+	// of their IDs
+	IKEV2_TRANSFORMS = map[byte]struct {
+		name string
+		ids  map[int]string
+	}{
+		1: {"Encr", map[int]string{3: "3DES", 12: "AES-CBC", 20: "AES-GCM-16"}},
+		2: {"Prf", map[int]string{2: "HMAC-SHA1", 5: "HMAC-SHA2-256", 7: "HMAC-SHA2-512"}},
+		3: {"Integ", map[int]string{2: "HMAC-SHA1-96", 12: "HMAC-SHA2-256-128", 14: "HMAC-SHA2-512-256"}},
+		4: {"DH", map[int]string{2: "2:modp1024", 14: "14:modp2048", 19: "19:ecp256", 20: "20:ecp384"}},
+	}
+	// IKE_NOTIFICATIONS maps the error notifications of IKEv1 and IKEv2 to
+	// their names
+	IKE_NOTIFICATIONS = map[uint16]string{
+		7:     "INVALID_SYNTAX",
+		14:    "NO_PROPOSAL_CHOSEN",
+		17:    "INVALID_KE_PAYLOAD",
+		24:    "AUTHENTICATION_FAILED",
+		16390: "COOKIE",
+	}
+)
+
+// ParseIKE extracts the IKE version, the transform the responder chose, its
+// vendor IDs and error notifications from IKEv1 and IKEv2 responses. IKEv1
+// responders that answer aggressive mode are flagged, as they reveal a hash of
+// the pre-shared key that can be cracked offline.
+func ParseIKE(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < IKE_HEADER_LENGTH {
+		return
+	}
+	major := int(response[17] >> 4)
+	exchange := response[18]
+
+	if major != 1 && major != 2 {
+		return
+	}
+	info.setMetadata("ike_version", strconv.Itoa(major))
+
+	var vendors, names []string
+	var handshake bool
+	next, message := response[16], response[IKE_HEADER_LENGTH:]
+
+	for next != 0 && len(message) >= 4 {
+		length := int(binary.BigEndian.Uint16(message[2:4]))
+
+		if length < 4 || length > len(message) {
+			break
+		}
+		payload := message[4:length]
+
+		switch next {
+		case IKE_PAYLOAD_SA:
+			info.setMetadata("transform", ikeTransform(payload))
+		case IKEV2_PAYLOAD_SA:
+			info.setMetadata("transform", ikev2Transform(payload))
+
+		case IKE_PAYLOAD_NOTIFY:
+			if len(payload) >= 8 {
+				info.setMetadata("notify", ikeNotification(binary.BigEndian.Uint16(payload[6:8])))
+			}
+		case IKEV2_PAYLOAD_NOTIFY:
+			if len(payload) >= 4 {
+				info.setMetadata("notify", ikeNotification(binary.BigEndian.Uint16(payload[2:4])))
+			}
+
+		// A key exchange or hash answering aggressive mode is the
+		// responder's half of the handshake, which includes the hash of the
+		// pre-shared key
+		case IKE_PAYLOAD_KE, IKE_PAYLOAD_HASH:
+			handshake = true
+
+		case IKE_PAYLOAD_VENDOR_ID, IKEV2_PAYLOAD_VENDOR_ID:
+			vendor := ikeVendor(payload)
+			vendors = append(vendors, vendor)
+
+			if !strings.HasPrefix(vendor, "unknown:") {
+				names = append(names, vendor)
+			}
+		}
+		next, message = message[0], message[length:]
+	}
+	info.setMetadata("vendor_ids", strings.Join(vendors, ","))
+	info.Banner = strings.Join(names, ", ")
+
+	// Responders set their own cookie, unlike an echo of the request
+	if major == 1 && exchange == IKE_EXCHANGE_AGGRESSIVE && handshake && !allZero(response[8:16]) {
+		info.setMetadata("aggressive_mode", "true")
+	}
+	return info, true
+}
+
+// ParseIKENATT parses IKE responses from the NAT traversal port, which follow
+// a non-ESP marker
+func ParseIKENATT(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < IKE_NAT_T_MARKER || !allZero(response[:IKE_NAT_T_MARKER]) {
+		return
+	}
+	return ParseIKE(request, response[IKE_NAT_T_MARKER:])
+}
+
+// ikeTransform describes the first transform of the first proposal of an
+// IKEv1 SA payload, which is the one a responder chose
+func ikeTransform(sa []byte) string {
+
+	// Skip the DOI, situation and the headers of the proposal and transform
+	if len(sa) < 8+8+8 {
+		return ""
+	}
+	proposal := sa[8:]
+	spiSize := int(proposal[6])
+
+	if len(proposal) < 8+spiSize+8 {
+		return ""
+	}
+	transform := proposal[8+spiSize:]
+	length := int(binary.BigEndian.Uint16(transform[2:4]))
+
+	if length < 8 || length > len(transform) {
+		return ""
+	}
+	attributes := transform[8:length]
+	var keyLength string
+	var parts []string
+
+	for len(attributes) >= 4 {
+		kind := binary.BigEndian.Uint16(attributes[0:2])
+		value, size := 0, 4
+
+		if kind&0x8000 != 0 {
+			value = int(binary.BigEndian.Uint16(attributes[2:4]))
+		} else if size += int(binary.BigEndian.Uint16(attributes[2:4])); size > len(attributes) {
+			break
+		} else {
+			for _, b := range attributes[4:size] {
+				value = value<<8 | int(b)
+			}
+		}
+		attributes = attributes[size:]
+		kind &= 0x7fff
+
+		if kind == 14 {
+			keyLength = strconv.Itoa(value)
+			continue
+		}
+		if attribute, found := IKE_ATTRIBUTES[kind]; found {
+			name, found := attribute.values[value]
+
+			if !found {
+				name = strconv.Itoa(value)
+			}
+			parts = append(parts, attribute.name+"="+name)
+		}
+	}
+	// The key length qualifies the cipher
+	for i := range parts {
+		if keyLength != "" && strings.HasPrefix(parts[i], "Enc=") {
+			parts[i] += "/" + keyLength
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ikev2Transform describes the transforms of the first proposal of an IKEv2
+// SA payload, which a responder narrows down to one of each type
+func ikev2Transform(sa []byte) string {
+
+	if len(sa) < 8 {
+		return ""
+	}
+	length := int(binary.BigEndian.Uint16(sa[2:4]))
+	spiSize := int(sa[6])
+
+	if length < 8+spiSize || length > len(sa) {
+		return ""
+	}
+	transforms := sa[8+spiSize : length]
+	var parts []string
+
+	for len(transforms) >= 8 {
+		length := int(binary.BigEndian.Uint16(transforms[2:4]))
+
+		if length < 8 || length > len(transforms) {
+			break
+		}
+		kind, id := transforms[4], int(binary.BigEndian.Uint16(transforms[6:8]))
+
+		if transform, found := IKEV2_TRANSFORMS[kind]; found {
+			name, found := transform.ids[id]
+
+			if !found {
+				name = strconv.Itoa(id)
+			}
+			// The only attribute defined is the key length
+			if length >= 12 && binary.BigEndian.Uint16(transforms[8:10]) == 0x800e {
+				name += "/" + strconv.Itoa(int(binary.BigEndian.Uint16(transforms[10:12])))
+			}
+			parts = append(parts, transform.name+"="+name)
+		}
+		transforms = transforms[length:]
+	}
+	return strings.Join(parts, " ")
+}
+
+// ikeNotification names a notification type
+func ikeNotification(kind uint16) string {
+
+	if name, found := IKE_NOTIFICATIONS[kind]; found {
+		return name
+	}
+	return strconv.Itoa(int(kind))
+}
+
+// ikeVendor names a vendor ID, or returns it in hex prefixed with "unknown:"
+func ikeVendor(id []byte) string {
+
+	encoded := hex.EncodeToString(id)
+
+	for _, vendor := range IKE_VENDOR_IDS {
+		if strings.HasPrefix(encoded, vendor.prefix) {
+			return vendor.name
+		}
+	}
+	return "unknown:" + encoded
+}
@@ -0,0 +1,181 @@
+package proto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// ikePayload builds a generic payload header and body
+func ikePayload(next byte, body []byte) []byte {
+
+	payload := []byte{next, 0, 0, 0}
+	binary.BigEndian.PutUint16(payload[2:4], uint16(4+len(body)))
+
+	return append(payload, body...)
+}
+
+// ikeMessage builds an IKE message of the version and exchange from payloads
+// already chained together
+func ikeMessage(version byte, exchange byte, first byte, payloads ...[]byte) []byte {
+
+	message := []byte("udpz\x00\x00\x00\x01\x5e\x0f\x8a\x6b\x4c\x1d\x2e\x3f")
+	message = append(message, first, version<<4, exchange, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	for _, payload := range payloads {
+		message = append(message, payload...)
+	}
+	binary.BigEndian.PutUint32(message[24:28], uint32(len(message)))
+
+	return message
+}
+
+// ikeVendorID decodes a vendor ID payload body from hex
+func ikeVendorID(id string) []byte {
+
+	decoded, _ := hex.DecodeString(id)
+	return decoded
+}
+
+func TestParseIKE(t *testing.T) {
+
+	// An SA with the AES-256, SHA1, PSK and group 2 transform of main mode
+	sa := []byte{
+		0, 0, 0, 1, 0, 0, 0, 1,
+		0, 0, 0, 0x24, 1, 1, 0, 1,
+		0, 0, 0, 0x1c, 1, 1, 0, 0,
+		0x80, 0x01, 0x00, 0x07, 0x80, 0x0e, 0x01, 0x00, 0x80, 0x02, 0x00, 0x02,
+		0x80, 0x03, 0x00, 0x01, 0x80, 0x04, 0x00, 0x02,
+	}
+	// An IKEv2 proposal narrowed down to AES-CBC-128, SHA2-256 and group 14
+	saV2 := []byte{
+		0, 0, 0, 0x2c, 1, 1, 0, 4,
+		3, 0, 0, 0x0c, 1, 0, 0, 12, 0x80, 0x0e, 0x00, 0x80,
+		3, 0, 0, 0x08, 2, 0, 0, 5,
+		3, 0, 0, 0x08, 3, 0, 0, 12,
+		0, 0, 0, 0x08, 4, 0, 0, 14,
+	}
+	// A NO_PROPOSAL_CHOSEN notification
+	notify := []byte{0, 0, 0, 1, 1, 0, 0, 14}
+
+	mainMode := ikeMessage(1, 2, IKE_PAYLOAD_SA,
+		ikePayload(IKE_PAYLOAD_VENDOR_ID, sa),
+		ikePayload(IKE_PAYLOAD_VENDOR_ID, ikeVendorID("afcad71368a1f1c96b8696fc77570100")),
+		ikePayload(IKE_PAYLOAD_VENDOR_ID, ikeVendorID("1e2b516905991c7d7c96fcbfb587e46100000009")),
+		ikePayload(0, ikeVendorID("0123456789abcdef")),
+	)
+
+	// Requests carry no responder cookie
+	echoed := ikeMessage(1, IKE_EXCHANGE_AGGRESSIVE, IKE_PAYLOAD_KE, ikePayload(0, make([]byte, 128)))
+	copy(echoed[8:16], make([]byte, 8))
+
+	tests := []struct {
+		name     string
+		service  string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "main mode",
+			service:  "ike",
+			response: mainMode,
+			info: Info{
+				Banner: "Dead Peer Detection, Microsoft Windows",
+				Metadata: map[string]string{
+					"ike_version": "1",
+					"transform":   "Enc=AES/256 Hash=SHA1 Auth=PSK Group=2:modp1024",
+					"vendor_ids":  "Dead Peer Detection,Microsoft Windows,unknown:0123456789abcdef",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "main mode over NAT-T",
+			service:  "ike-natt",
+			response: append([]byte{0, 0, 0, 0}, mainMode...),
+			info: Info{
+				Banner: "Dead Peer Detection, Microsoft Windows",
+				Metadata: map[string]string{
+					"ike_version": "1",
+					"transform":   "Enc=AES/256 Hash=SHA1 Auth=PSK Group=2:modp1024",
+					"vendor_ids":  "Dead Peer Detection,Microsoft Windows,unknown:0123456789abcdef",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:    "aggressive mode",
+			service: "ike",
+			response: ikeMessage(1, IKE_EXCHANGE_AGGRESSIVE, IKE_PAYLOAD_SA,
+				ikePayload(IKE_PAYLOAD_KE, sa),
+				ikePayload(IKE_PAYLOAD_HASH, make([]byte, 128)),
+				ikePayload(0, make([]byte, 20)),
+			),
+			info: Info{
+				Metadata: map[string]string{
+					"ike_version":     "1",
+					"transform":       "Enc=AES/256 Hash=SHA1 Auth=PSK Group=2:modp1024",
+					"aggressive_mode": "true",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "echoed aggressive mode request",
+			service:  "ike",
+			response: echoed,
+			info:     Info{Metadata: map[string]string{"ike_version": "1"}},
+			ok:       true,
+		},
+		{
+			name:     "aggressive mode refused",
+			service:  "ike",
+			response: ikeMessage(1, IKE_EXCHANGE_AGGRESSIVE, IKE_PAYLOAD_NOTIFY, ikePayload(0, notify)),
+			info:     Info{Metadata: map[string]string{"ike_version": "1", "notify": "NO_PROPOSAL_CHOSEN"}},
+			ok:       true,
+		},
+		{
+			name:     "IKEv2 SA_INIT",
+			service:  "ike",
+			response: ikeMessage(2, 34, IKEV2_PAYLOAD_SA, ikePayload(IKEV2_PAYLOAD_VENDOR_ID, saV2), ikePayload(0, ikeVendorID("882fe56d6fd20dbc2251613b2ebe5beb"))),
+			info: Info{
+				Banner: "strongSwan",
+				Metadata: map[string]string{
+					"ike_version": "2",
+					"transform":   "Encr=AES-CBC/128 Prf=HMAC-SHA2-256 Integ=HMAC-SHA2-256-128 DH=14:modp2048",
+					"vendor_ids":  "strongSwan",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "IKEv2 invalid key exchange",
+			service:  "ike",
+			response: ikeMessage(2, 34, IKEV2_PAYLOAD_NOTIFY, ikePayload(0, []byte{0, 0, 0, 17, 0, 2})),
+			info:     Info{Metadata: map[string]string{"ike_version": "2", "notify": "INVALID_KE_PAYLOAD"}},
+			ok:       true,
+		},
+		{
+			name:     "NAT-T without marker",
+			service:  "ike-natt",
+			response: mainMode,
+			ok:       false,
+		},
+		{
+			name:     "short response",
+			service:  "ike",
+			response: mainMode[:20],
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := Parse(test.service, nil, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
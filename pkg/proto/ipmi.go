@@ -0,0 +1,243 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	RMCP_HEADER_LENGTH = 4
+	RMCP_CLASS_ASF     = 0x06
+	RMCP_CLASS_IPMI    = 0x07
+
+	ASF_IANA          = 4542
+	ASF_PRESENCE_PONG = 0x40
+
+	IPMI_AUTH_RMCP_PLUS = 0x06
+
+	IPMI_PAYLOAD_OPEN_SESSION_REQUEST  = 0x10
+	IPMI_PAYLOAD_OPEN_SESSION_RESPONSE = 0x11
+
+	IPMI_COMMAND_GET_CHANNEL_AUTH = 0x38
+)
+
+var (
+	// IPMI_AUTH_TYPES maps the bits of the authentication types a channel
+	// supports for IPMI 1.5 sessions to their names
+	IPMI_AUTH_TYPES = []struct {
+		bit  byte
+		name string
+	}{
+		{0x01, "none"},
+		{0x02, "MD2"},
+		{0x04, "MD5"},
+		{0x10, "password"},
+		{0x20, "OEM"},
+	}
+	// IPMI_CIPHER_REJECTIONS are the status codes of RMCP+ open session
+	// responses rejecting the algorithms of a cipher suite
+	IPMI_CIPHER_REJECTIONS = map[byte]bool{
+		0x04: true, // Invalid authentication algorithm
+		0x05: true, // Invalid integrity algorithm
+		0x10: true, // Invalid confidentiality algorithm
+		0x11: true, // No cipher suite match with proposed security algorithms
+	}
+)
+
+// ParseIPMI identifies BMCs from their answers to RMCP presence pings, Get
+// Channel Authentication Capabilities requests and RMCP+ open session
+// requests, and reports the IPMI version they support. BMCs that open a
+// session without authentication, integrity or confidentiality algorithms
+// accept cipher suite 0, which lets anyone log in as any user with any
+// password.
+func ParseIPMI(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < RMCP_HEADER_LENGTH || len(request) < RMCP_HEADER_LENGTH || response[0] != 0x06 ||
+		response[3] != request[3] {
+		return
+	}
+	switch response[3] {
+	case RMCP_CLASS_ASF:
+		return parseASFPong(request, response)
+
+	case RMCP_CLASS_IPMI:
+		if len(response) > RMCP_HEADER_LENGTH && response[RMCP_HEADER_LENGTH] == IPMI_AUTH_RMCP_PLUS {
+			return parseIPMIOpenSession(request, response)
+		}
+		return parseIPMIChannelAuth(request, response)
+	}
+	return
+}
+
+// MatchIPMI compares the message tag of presence pings, the sequence number
+// of IPMI 1.5 requests and the remote console session ID of RMCP+ requests
+// with those of the responses
+func MatchIPMI(request []byte, response []byte) bool {
+
+	if len(request) < RMCP_HEADER_LENGTH || len(response) < RMCP_HEADER_LENGTH {
+		return true
+	}
+	if request[3] != response[3] {
+		return false
+	}
+	switch {
+	case request[3] == RMCP_CLASS_ASF:
+		return len(request) < 10 || len(response) < 10 || request[9] == response[9]
+
+	case len(request) > RMCP_HEADER_LENGTH && request[RMCP_HEADER_LENGTH] == IPMI_AUTH_RMCP_PLUS:
+		requested, responded := ipmiRMCPPlusPayload(request), ipmiRMCPPlusPayload(response)
+
+		if len(requested) < 8 || len(responded) < 8 {
+			return true
+		}
+		return bytes.Equal(requested[4:8], responded[4:8])
+
+	default:
+		requested, responded := ipmiMessage(request), ipmiMessage(response)
+
+		if len(requested) < 5 || len(responded) < 5 {
+			return true
+		}
+		return requested[4] == responded[4]
+	}
+}
+
+// parseASFPong reports whether a presence pong advertises IPMI
+func parseASFPong(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < 28 || binary.BigEndian.Uint32(response[4:8]) != ASF_IANA || response[8] != ASF_PRESENCE_PONG ||
+		(len(request) >= 10 && response[9] != request[9]) {
+		return
+	}
+	// The supported entities follow the IANA and OEM numbers, with the high
+	// bit set for IPMI
+	entities := response[20]
+
+	if entities&0x80 != 0 {
+		info.setMetadata("ipmi", "true")
+	} else {
+		info.setMetadata("ipmi", "false")
+	}
+	if entities&0x0f == 1 {
+		info.setMetadata("asf_version", "1.0")
+	}
+	return info, true
+}
+
+// parseIPMIChannelAuth reports the IPMI version and authentication types of
+// a Get Channel Authentication Capabilities response
+func parseIPMIChannelAuth(request []byte, response []byte) (info Info, ok bool) {
+
+	requested, message := ipmiMessage(request), ipmiMessage(response)
+
+	// The response starts with the requester's address, then the network
+	// function, the sequence number, the command and the completion code
+	if len(message) < 7 || message[5] != IPMI_COMMAND_GET_CHANNEL_AUTH || message[1]>>2 != 0x07 {
+		return
+	}
+	if len(requested) >= 5 && message[4] != requested[4] {
+		return
+	}
+	// BMCs that only support IPMI 1.5 fail requests for extended data
+	if message[6] != 0 {
+		info.setMetadata("ipmi_version", "1.5")
+
+		return info, true
+	}
+	if len(message) < 15 {
+		return
+	}
+	support, status, extended := message[8], message[9], message[10]
+
+	switch {
+	case support&0x80 != 0 && extended&0x02 != 0:
+		info.setMetadata("ipmi_version", "2.0")
+	default:
+		info.setMetadata("ipmi_version", "1.5")
+	}
+	var types []string
+
+	for _, auth := range IPMI_AUTH_TYPES {
+		if support&auth.bit != 0 {
+			types = append(types, auth.name)
+		}
+	}
+	info.setMetadata("auth_types", strings.Join(types, ","))
+
+	if status&0x01 != 0 {
+		info.setMetadata("anonymous_login", "true")
+	}
+	return info, true
+}
+
+// parseIPMIOpenSession reports whether an RMCP+ open session response
+// accepts the algorithms of the request
+func parseIPMIOpenSession(request []byte, response []byte) (info Info, ok bool) {
+
+	requested, payload := ipmiRMCPPlusPayload(request), ipmiRMCPPlusPayload(response)
+
+	if len(payload) < 8 || response[RMCP_HEADER_LENGTH+1]&0x3f != IPMI_PAYLOAD_OPEN_SESSION_RESPONSE {
+		return
+	}
+	if len(requested) >= 8 && !bytes.Equal(payload[4:8], requested[4:8]) {
+		return
+	}
+	info.setMetadata("ipmi_version", "2.0")
+
+	// Requests with all algorithms set to none propose cipher suite 0
+	cipherZero := len(requested) >= 32 && requested[12] == 0 && requested[20] == 0 && requested[28] == 0
+
+	status := payload[1]
+
+	switch {
+	case cipherZero && status == 0:
+		info.setMetadata("cipher_zero", "true")
+	case cipherZero && IPMI_CIPHER_REJECTIONS[status]:
+		info.setMetadata("cipher_zero", "false")
+	}
+	if status != 0 {
+		info.setMetadata("rmcp_plus_status", fmt.Sprintf("0x%02x", status))
+	}
+	return info, true
+}
+
+// ipmiMessage returns the message of an IPMI 1.5 session, after the session
+// header and its authentication code
+func ipmiMessage(packet []byte) []byte {
+
+	offset := RMCP_HEADER_LENGTH + 9
+
+	if len(packet) < offset+1 {
+		return nil
+	}
+	if packet[RMCP_HEADER_LENGTH] != 0 {
+		offset += 16
+	}
+	if len(packet) < offset+1 {
+		return nil
+	}
+	length := int(packet[offset])
+
+	if len(packet) < offset+1+length {
+		return nil
+	}
+	return packet[offset+1 : offset+1+length]
+}
+
+// ipmiRMCPPlusPayload returns the payload of an unauthenticated RMCP+ session
+func ipmiRMCPPlusPayload(packet []byte) []byte {
+
+	offset := RMCP_HEADER_LENGTH + 12
+
+	if len(packet) < offset {
+		return nil
+	}
+	length := int(binary.LittleEndian.Uint16(packet[offset-2 : offset]))
+
+	if len(packet) < offset+length {
+		return nil
+	}
+	return packet[offset : offset+length]
+}
@@ -0,0 +1,112 @@
+package proto
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// ipmiPacket decodes a packet written in hexadecimal
+func ipmiPacket(packet string) []byte {
+
+	decoded, _ := hex.DecodeString(packet)
+
+	return decoded
+}
+
+func TestParseIPMI(t *testing.T) {
+
+	ping, _ := base64.StdEncoding.DecodeString("BgD/BgAAEb6AdQAA")
+	channelAuth, _ := base64.StdEncoding.DecodeString("BgD/BwAAAAAAAAAAAAkgGMiBADiOBLU=")
+	openSession, _ := base64.StdEncoding.DecodeString("BgD/BwYQAAAAAAAAAAAgAAAAAAB1ZHB6AAAACAAAAAABAAAIAAAAAAIAAAgAAAAA")
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "presence pong",
+			request:  ping,
+			response: ipmiPacket("0600ff06000011be40750010000011be0000000081000000000000000000"),
+			info:     Info{Metadata: map[string]string{"ipmi": "true", "asf_version": "1.0"}},
+			ok:       true,
+		},
+		{
+			name:     "presence pong to another ping",
+			request:  ping,
+			response: ipmiPacket("0600ff06000011be40760010000011be0000000081000000000000000000"),
+			ok:       false,
+		},
+		{
+			name:     "channel authentication capabilities",
+			request:  channelAuth,
+			response: ipmiPacket("0600ff0700000000000000000010811c63200038000197040300000000a0"),
+			info:     Info{Metadata: map[string]string{"ipmi_version": "2.0", "auth_types": "none,MD2,MD5,password"}},
+			ok:       true,
+		},
+		{
+			name:     "anonymous login",
+			request:  channelAuth,
+			response: ipmiPacket("0600ff0700000000000000000010811c63200038000114010100000000a0"),
+			info:     Info{Metadata: map[string]string{"ipmi_version": "1.5", "auth_types": "MD5,password", "anonymous_login": "true"}},
+			ok:       true,
+		},
+		{
+			name:     "extended data unsupported",
+			request:  channelAuth,
+			response: ipmiPacket("0600ff0700000000000000000008811c632000380cc0"),
+			info:     Info{Metadata: map[string]string{"ipmi_version": "1.5"}},
+			ok:       true,
+		},
+		{
+			name:     "cipher suite 0 accepted",
+			request:  openSession,
+			response: ipmiPacket("0600ff07061100000000000000002400000004007564707a626d6331000000080000000001000008000000000200000800000000"),
+			info:     Info{Metadata: map[string]string{"ipmi_version": "2.0", "cipher_zero": "true"}},
+			ok:       true,
+		},
+		{
+			name:     "cipher suite 0 rejected",
+			request:  openSession,
+			response: ipmiPacket("0600ff07061100000000000000000800001100007564707a"),
+			info:     Info{Metadata: map[string]string{"ipmi_version": "2.0", "cipher_zero": "false", "rmcp_plus_status": "0x11"}},
+			ok:       true,
+		},
+		{
+			name:     "open session for another console",
+			request:  openSession,
+			response: ipmiPacket("0600ff07061100000000000000000800000000006f746872"),
+			ok:       false,
+		},
+		{
+			name:     "echoed ping",
+			request:  ping,
+			response: ping,
+			ok:       false,
+		},
+		{
+			name:     "echoed channel authentication request",
+			request:  channelAuth,
+			response: channelAuth,
+			ok:       false,
+		},
+		{
+			name:     "echoed open session request",
+			request:  openSession,
+			response: openSession,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseIPMI(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package proto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	KNXNET_HEADER_LENGTH = 6
+	KNXNET_VERSION       = 0x10
+	KNXNET_HPAI_LENGTH   = 8
+
+	KNXNET_SEARCH_RESPONSE          = 0x0202
+	KNXNET_DESCRIPTION_RESPONSE     = 0x0204
+	KNXNET_SEARCH_RESPONSE_EXTENDED = 0x020c
+
+	KNXNET_DIB_DEVICE_INFO      = 0x01
+	KNXNET_DIB_SERVICE_FAMILIES = 0x02
+	KNXNET_DEVICE_INFO_LENGTH   = 54
+)
+
+var (
+	// KNX_MEDIA maps the KNX media devices are connected to to their names
+	KNX_MEDIA = map[byte]string{
+		0x02: "TP1",
+		0x04: "PL110",
+		0x10: "RF",
+		0x20: "IP",
+	}
+	// KNXNET_SERVICE_FAMILIES maps the service families of KNXnet/IP to their
+	// names
+	KNXNET_SERVICE_FAMILIES = map[byte]string{
+		0x02: "core",
+		0x03: "device management",
+		0x04: "tunnelling",
+		0x05: "routing",
+		0x06: "remote logging",
+		0x07: "remote configuration",
+		0x08: "object server",
+		0x09: "security",
+	}
+	// KNX_MANUFACTURERS maps the codes of common KNX manufacturers, which
+	// start the serial numbers of their devices, to their names
+	KNX_MANUFACTURERS = map[uint16]string{
+		0x0001: "Siemens",
+		0x0002: "ABB",
+		0x0004: "Albrecht Jung",
+		0x0005: "Bticino",
+		0x0006: "Berker",
+		0x0007: "Busch-Jaeger Elektro",
+		0x0008: "GIRA Giersiepen",
+		0x0009: "Hager Electro",
+		0x000a: "Insta",
+		0x000b: "Legrand",
+		0x000c: "Merten",
+	}
+)
+
+// ParseKNXnet reports the friendly name, individual address, serial number,
+// manufacturer, MAC address and service families of KNXnet/IP routers and
+// interfaces answering a search or description request, which connect IP
+// networks to the KNX bus of building automation systems. Devices in
+// programming mode accept a new individual address from anyone.
+func ParseKNXnet(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < KNXNET_HEADER_LENGTH || response[0] != KNXNET_HEADER_LENGTH || response[1] != KNXNET_VERSION {
+		return
+	}
+	service, length := binary.BigEndian.Uint16(response[2:4]), int(binary.BigEndian.Uint16(response[4:6]))
+
+	if length < KNXNET_HEADER_LENGTH || length > len(response) {
+		return
+	}
+	dibs := response[KNXNET_HEADER_LENGTH:length]
+
+	switch service {
+	case KNXNET_SEARCH_RESPONSE, KNXNET_SEARCH_RESPONSE_EXTENDED:
+		// Search responses give the control endpoint first
+		if len(dibs) < KNXNET_HPAI_LENGTH {
+			return
+		}
+		dibs = dibs[KNXNET_HPAI_LENGTH:]
+
+	case KNXNET_DESCRIPTION_RESPONSE:
+		// Description responses start with the device information
+
+	default:
+		return
+	}
+	for len(dibs) >= 2 {
+		size, kind := int(dibs[0]), dibs[1]
+
+		if size < 2 || size > len(dibs) {
+			break
+		}
+		dib := dibs[2:size]
+		dibs = dibs[size:]
+
+		switch kind {
+		case KNXNET_DIB_DEVICE_INFO:
+			if len(dib)+2 < KNXNET_DEVICE_INFO_LENGTH {
+				continue
+			}
+			info.setMetadata("knx_medium", knxMedium(dib[0]))
+
+			if dib[1]&0x01 != 0 {
+				info.setMetadata("programming_mode", "true")
+			}
+			address := binary.BigEndian.Uint16(dib[2:4])
+			info.setMetadata("knx_address", fmt.Sprintf("%d.%d.%d", address>>12, address>>8&0x0f, address&0xff))
+
+			serial := dib[6:12]
+			info.setMetadata("serial_number", hex.EncodeToString(serial))
+			info.setMetadata("manufacturer", knxManufacturer(binary.BigEndian.Uint16(serial[0:2])))
+
+			if multicast := net.IP(dib[12:16]); !multicast.IsUnspecified() {
+				info.setMetadata("multicast_address", multicast.String())
+			}
+			info.setMetadata("mac_address", net.HardwareAddr(dib[16:22]).String())
+			info.Banner = printable(strings.TrimRight(string(dib[22:52]), "\x00"))
+
+		case KNXNET_DIB_SERVICE_FAMILIES:
+			var families []string
+
+			for ; len(dib) >= 2; dib = dib[2:] {
+				name, found := KNXNET_SERVICE_FAMILIES[dib[0]]
+
+				if !found {
+					name = fmt.Sprintf("0x%02x", dib[0])
+				}
+				families = append(families, name+" v"+strconv.Itoa(int(dib[1])))
+			}
+			info.setMetadata("service_families", strings.Join(families, ","))
+		}
+	}
+	return info, info.Metadata != nil
+}
+
+// knxMedium names the KNX medium of a device
+func knxMedium(medium byte) string {
+
+	if name, found := KNX_MEDIA[medium]; found {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", medium)
+}
+
+// knxManufacturer names a KNX manufacturer by its code
+func knxManufacturer(code uint16) string {
+
+	if name, found := KNX_MANUFACTURERS[code]; found {
+		return name
+	}
+	return strconv.Itoa(int(code))
+}
@@ -0,0 +1,90 @@
+package proto
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+// knxDeviceInfo encodes the device information of a KNX IP router at 1.1.0
+// in programming mode, made by ABB
+func knxDeviceInfo(name string) []byte {
+
+	dib := []byte{54, 0x01, 0x20, 0x01, 0x11, 0x00, 0x00, 0x00, 0x00, 0x02, 0x75, 0x64, 0x70, 0x7a, 224, 0, 23, 12, 0x02, 0x75, 0x64, 0x70, 0x7a, 0x00}
+	name30 := make([]byte, 30)
+	copy(name30, name)
+
+	return append(dib, name30...)
+}
+
+// knxPacket frames the body of a KNXnet/IP message of the service
+func knxPacket(service uint16, body []byte) []byte {
+
+	packet := append([]byte{0x06, 0x10, byte(service >> 8), byte(service), 0, 0}, body...)
+	packet[4], packet[5] = byte(len(packet)>>8), byte(len(packet))
+
+	return packet
+}
+
+func TestParseKNXnet(t *testing.T) {
+
+	search, _ := base64.StdEncoding.DecodeString("BhACAQAOCAEAAAAAAAA=")
+	description, _ := base64.StdEncoding.DecodeString("BhACAwAOCAEAAAAAAAA=")
+
+	families := []byte{8, 0x02, 0x02, 0x01, 0x04, 0x01, 0x05, 0x01}
+	endpoint := []byte{8, 0x01, 192, 168, 1, 10, 0x0e, 0x57}
+
+	router := map[string]string{
+		"knx_medium":        "IP",
+		"programming_mode":  "true",
+		"knx_address":       "1.1.0",
+		"serial_number":     "00027564707a",
+		"manufacturer":      "ABB",
+		"multicast_address": "224.0.23.12",
+		"mac_address":       "02:75:64:70:7a:00",
+		"service_families":  "core v1,tunnelling v1,routing v1",
+	}
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "search response",
+			request:  search,
+			response: knxPacket(0x0202, append(append(endpoint, knxDeviceInfo("Line coupler")...), families...)),
+			info:     Info{Banner: "Line coupler", Metadata: router},
+			ok:       true,
+		},
+		{
+			name:     "description response",
+			request:  description,
+			response: knxPacket(0x0204, append(knxDeviceInfo("Line coupler"), families...)),
+			info:     Info{Banner: "Line coupler", Metadata: router},
+			ok:       true,
+		},
+		{
+			name:     "truncated device information",
+			request:  description,
+			response: knxPacket(0x0204, knxDeviceInfo("Line coupler")[:40]),
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			request:  search,
+			response: search,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseKNXnet(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
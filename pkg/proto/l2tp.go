@@ -0,0 +1,178 @@
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+const (
+	L2TP_VERSION = 2
+
+	L2TP_FLAG_TYPE     = 0x8000
+	L2TP_FLAG_LENGTH   = 0x4000
+	L2TP_FLAG_SEQUENCE = 0x0800
+	L2TP_FLAG_OFFSET   = 0x0200
+
+	L2TP_AVP_HIDDEN = 0x4000
+
+	L2TP_AVP_MESSAGE_TYPE       = 0
+	L2TP_AVP_RESULT_CODE        = 1
+	L2TP_AVP_PROTOCOL_VERSION   = 2
+	L2TP_AVP_FIRMWARE_REVISION  = 6
+	L2TP_AVP_HOST_NAME          = 7
+	L2TP_AVP_VENDOR_NAME        = 8
+	L2TP_AVP_ASSIGNED_TUNNEL_ID = 9
+)
+
+var (
+	// L2TP_MESSAGES maps the types of the control messages LNSs answer a
+	// Start-Control-Connection-Request with to their names
+	L2TP_MESSAGES = map[uint16]string{
+		2: "SCCRP",
+		4: "StopCCN",
+	}
+)
+
+// ParseL2TP reports the host and vendor names, firmware revision and
+// protocol version that L2TP network servers, such as VPN concentrators, give
+// in the Start-Control-Connection-Reply to a request, or the result code of
+// the StopCCN they tear the tunnel down with
+func ParseL2TP(request []byte, response []byte) (info Info, ok bool) {
+
+	tunnel, avps, found := l2tpControl(response)
+
+	if !found {
+		return
+	}
+	attributes := l2tpAttributes(avps)
+
+	// Replies are sent to the tunnel ID the request assigned
+	if assigned, found := l2tpAssignedTunnel(request); found && tunnel != assigned {
+		return
+	}
+	kind := attributes[L2TP_AVP_MESSAGE_TYPE]
+
+	if len(kind) != 2 {
+		return
+	}
+	message, found := L2TP_MESSAGES[binary.BigEndian.Uint16(kind)]
+
+	if !found {
+		return
+	}
+	info.setMetadata("message", message)
+	info.setMetadata("hostname", printable(string(attributes[L2TP_AVP_HOST_NAME])))
+	info.Banner = printable(string(attributes[L2TP_AVP_VENDOR_NAME]))
+
+	if version := attributes[L2TP_AVP_PROTOCOL_VERSION]; len(version) == 2 {
+		info.setMetadata("protocol_version", fmt.Sprintf("%d.%d", version[0], version[1]))
+	}
+	if firmware := attributes[L2TP_AVP_FIRMWARE_REVISION]; len(firmware) == 2 {
+		info.setMetadata("firmware_revision", fmt.Sprintf("%#04x", binary.BigEndian.Uint16(firmware)))
+	}
+	if tunnel := attributes[L2TP_AVP_ASSIGNED_TUNNEL_ID]; len(tunnel) == 2 {
+		info.setMetadata("tunnel_id", strconv.Itoa(int(binary.BigEndian.Uint16(tunnel))))
+	}
+	if result := attributes[L2TP_AVP_RESULT_CODE]; len(result) >= 2 {
+		code := strconv.Itoa(int(binary.BigEndian.Uint16(result)))
+
+		// An error code and message may follow the result code
+		if len(result) > 4 {
+			code += " " + printable(string(result[4:]))
+		}
+		info.setMetadata("result", code)
+	}
+	return info, true
+}
+
+// MatchL2TP compares the tunnel ID a request assigned with the one of the
+// response
+func MatchL2TP(request []byte, response []byte) bool {
+
+	assigned, found := l2tpAssignedTunnel(request)
+
+	if !found {
+		return true
+	}
+	tunnel, _, found := l2tpControl(response)
+
+	return !found || tunnel == assigned
+}
+
+// l2tpControl returns the tunnel ID and AVPs of an L2TP control message
+func l2tpControl(packet []byte) (tunnel uint16, avps []byte, ok bool) {
+
+	if len(packet) < 2 {
+		return
+	}
+	flags := binary.BigEndian.Uint16(packet[0:2])
+
+	if flags&L2TP_FLAG_TYPE == 0 || flags&0x000f != L2TP_VERSION || flags&L2TP_FLAG_OFFSET != 0 {
+		return
+	}
+	offset := 2
+
+	if flags&L2TP_FLAG_LENGTH != 0 {
+		if len(packet) < 4 {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(packet[2:4]))
+
+		if length > len(packet) {
+			return
+		}
+		packet = packet[:length]
+		offset += 2
+	}
+	// The tunnel and session IDs, then the sequence numbers
+	header := offset + 4
+
+	if flags&L2TP_FLAG_SEQUENCE != 0 {
+		header += 4
+	}
+	if len(packet) < header {
+		return
+	}
+	return binary.BigEndian.Uint16(packet[offset : offset+2]), packet[header:], true
+}
+
+// l2tpAttributes returns the values of the AVPs of the IETF, skipping those
+// hidden with the tunnel secret
+func l2tpAttributes(avps []byte) map[uint16][]byte {
+
+	attributes := make(map[uint16][]byte)
+
+	for len(avps) >= 6 {
+		flags := binary.BigEndian.Uint16(avps[0:2])
+		length := int(flags & 0x03ff)
+
+		if length < 6 || length > len(avps) {
+			break
+		}
+		vendor, kind := binary.BigEndian.Uint16(avps[2:4]), binary.BigEndian.Uint16(avps[4:6])
+
+		if vendor == 0 && flags&L2TP_AVP_HIDDEN == 0 {
+			attributes[kind] = avps[6:length]
+		}
+		avps = avps[length:]
+	}
+	return attributes
+}
+
+// l2tpAssignedTunnel returns the tunnel ID a request assigned, which replies
+// are addressed to
+func l2tpAssignedTunnel(request []byte) (uint16, bool) {
+
+	_, avps, found := l2tpControl(request)
+
+	if !found {
+		return 0, false
+	}
+	tunnel := l2tpAttributes(avps)[L2TP_AVP_ASSIGNED_TUNNEL_ID]
+
+	if len(tunnel) != 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(tunnel), true
+}
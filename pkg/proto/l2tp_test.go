@@ -0,0 +1,105 @@
+package proto
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// l2tpAVP encodes a mandatory AVP of the IETF
+func l2tpAVP(kind uint16, value string) []byte {
+
+	avp := []byte{0x80, byte(6 + len(value)), 0, 0, 0, byte(kind)}
+
+	return append(avp, value...)
+}
+
+// l2tpMessage encodes a control message to the tunnel
+func l2tpMessage(tunnel uint16, avps ...[]byte) []byte {
+
+	message := []byte{0xc8, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(message[4:6], tunnel)
+
+	for _, avp := range avps {
+		message = append(message, avp...)
+	}
+	binary.BigEndian.PutUint16(message[2:4], uint16(len(message)))
+
+	return message
+}
+
+func TestParseL2TP(t *testing.T) {
+
+	request := l2tpMessage(0, l2tpAVP(L2TP_AVP_MESSAGE_TYPE, "\x00\x01"), l2tpAVP(L2TP_AVP_ASSIGNED_TUNNEL_ID, "uz"))
+
+	// A hidden AVP precedes the host name
+	hidden := l2tpAVP(L2TP_AVP_HOST_NAME, "secret")
+	hidden[0] |= 0x40
+
+	reply := l2tpMessage(0x757a,
+		l2tpAVP(L2TP_AVP_MESSAGE_TYPE, "\x00\x02"),
+		l2tpAVP(L2TP_AVP_PROTOCOL_VERSION, "\x01\x00"),
+		l2tpAVP(L2TP_AVP_FIRMWARE_REVISION, "\x06\x90"),
+		hidden,
+		l2tpAVP(L2TP_AVP_HOST_NAME, "vpn1"),
+		l2tpAVP(L2TP_AVP_VENDOR_NAME, "Cisco Systems, Inc."),
+		l2tpAVP(L2TP_AVP_ASSIGNED_TUNNEL_ID, "\x00\x2a"))
+	stop := l2tpMessage(0x757a,
+		l2tpAVP(L2TP_AVP_MESSAGE_TYPE, "\x00\x04"),
+		l2tpAVP(L2TP_AVP_RESULT_CODE, "\x00\x02\x00\x06Not authorized"))
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "start control connection reply",
+			request:  request,
+			response: reply,
+			info: Info{Banner: "Cisco Systems, Inc.", Metadata: map[string]string{
+				"message":           "SCCRP",
+				"hostname":          "vpn1",
+				"protocol_version":  "1.0",
+				"firmware_revision": "0x0690",
+				"tunnel_id":         "42",
+			}},
+			ok: true,
+		},
+		{
+			name:     "stop control connection notification",
+			request:  request,
+			response: stop,
+			info:     Info{Metadata: map[string]string{"message": "StopCCN", "result": "2 Not authorized"}},
+			ok:       true,
+		},
+		{
+			name:     "reply to another tunnel",
+			request:  request,
+			response: l2tpMessage(1, l2tpAVP(L2TP_AVP_MESSAGE_TYPE, "\x00\x02")),
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			request:  request,
+			response: request,
+			ok:       false,
+		},
+		{
+			name:     "data message",
+			request:  request,
+			response: []byte{0x40, 0x02, 0, 12, 0x75, 0x7a, 0, 0, 0, 0, 0, 0},
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseL2TP(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
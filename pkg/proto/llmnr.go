@@ -0,0 +1,56 @@
+package proto
+
+import (
+	"net"
+	"strings"
+)
+
+const (
+	LLMNR_FLAG_CONFLICT = 0x0400
+
+	// LLMNR_PROBE_NAME is the name the LLMNR probe asks for, which no host
+	// should answer to
+	LLMNR_PROBE_NAME = "udpz-llmnr-check"
+)
+
+// ParseLLMNR reports the host names and addresses LLMNR responders answer
+// with. Responders only answer queries for their own names, so an answer for
+// the made up name of the probe comes from a poisoner such as Responder or
+// Inveigh, and is flagged as llmnr_spoofer. Hosts that listen without
+// answering are flagged by their silence instead, as their names can still be
+// spoofed by anyone on the network.
+func ParseLLMNR(request []byte, response []byte) (info Info, ok bool) {
+
+	message, ok := parseDNS(response)
+
+	if !ok || (len(request) >= 2 && (request[0] != response[0] || request[1] != response[1])) {
+		return Info{}, false
+	}
+	var names, addresses []string
+	seen := make(map[string]bool)
+
+	for _, record := range message.records {
+		if (record.rtype != DNS_TYPE_A || len(record.raw) != net.IPv4len) && (record.rtype != DNS_TYPE_AAAA || len(record.raw) != net.IPv6len) {
+			continue
+		}
+		if name := printable(record.name); name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			names = append(names, name)
+		}
+		addresses = append(addresses, net.IP(record.raw).String())
+	}
+	if len(addresses) == 0 {
+		return Info{}, false
+	}
+	if seen[LLMNR_PROBE_NAME] {
+		info.setMetadata("llmnr_spoofer", "true")
+	} else {
+		info.setMetadata("hostname", strings.Join(names, ","))
+	}
+	info.setMetadata("addresses", strings.Join(addresses, ","))
+
+	if message.flags&LLMNR_FLAG_CONFLICT != 0 {
+		info.setMetadata("conflict", "true")
+	}
+	return info, true
+}
@@ -0,0 +1,52 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLLMNR(t *testing.T) {
+
+	request := dnsQuery(0, LLMNR_PROBE_NAME, DNS_TYPE_A, DNS_CLASS_INTERNET)
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name: "spoofer",
+			response: dnsResponse(DNS_FLAG_RESPONSE, LLMNR_PROBE_NAME,
+				dnsRR(dnsName(LLMNR_PROBE_NAME), DNS_TYPE_A, DNS_CLASS_INTERNET, []byte{10, 10, 14, 5})),
+			info: Info{Metadata: map[string]string{"llmnr_spoofer": "true", "addresses": "10.10.14.5"}},
+			ok:   true,
+		},
+		{
+			name: "host",
+			response: dnsResponse(DNS_FLAG_RESPONSE|LLMNR_FLAG_CONFLICT, "WS01",
+				dnsRR(dnsName("WS01"), DNS_TYPE_A, DNS_CLASS_INTERNET, []byte{10, 10, 14, 7}),
+				dnsRR(dnsName("WS01"), DNS_TYPE_AAAA, DNS_CLASS_INTERNET, []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 7})),
+			info: Info{Metadata: map[string]string{"hostname": "WS01", "addresses": "10.10.14.7,fe80::7", "conflict": "true"}},
+			ok:   true,
+		},
+		{
+			name:     "no answer",
+			response: dnsResponse(DNS_FLAG_RESPONSE, LLMNR_PROBE_NAME),
+			ok:       false,
+		},
+		{
+			name:     "echoed query",
+			response: request,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseLLMNR(request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
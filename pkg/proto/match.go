@@ -0,0 +1,148 @@
+package proto
+
+import (
+	"bytes"
+)
+
+// Matcher reports whether a response answers the request, by comparing the
+// transaction IDs both carry. It returns true if either has none.
+type Matcher func(request []byte, response []byte) bool
+
+var (
+	// MATCHERS maps service slugs to the matcher for their responses
+	MATCHERS = map[string]Matcher{
+		"bacnet":   MatchBACnet,
+		"cldap":    MatchCLDAP,
+		"coap":     MatchCoAP,
+		"dhcp":     MatchDHCP,
+		"dhcpv6":   MatchDHCPv6,
+		"dns":      MatchDNS,
+		"ike":      MatchIKE,
+		"ike-natt": MatchIKENATT,
+		"ipmi":     MatchIPMI,
+		"l2tp":     MatchL2TP,
+		"mdns":     MatchDNS,
+		"memcache": MatchMemcache,
+		"nat-pmp":  MatchNATPMP,
+		"netbios":  MatchDNS,
+		"openvpn":  MatchOpenVPN,
+		"profinet": MatchPROFINET,
+		"quic":     MatchQUIC,
+		"radius":   MatchRADIUS,
+		"sip":      MatchSIP,
+		"snmp":     MatchSNMP,
+		"stun":     MatchSTUN,
+	}
+)
+
+// Matches reports whether a response answers the request, using the matcher
+// registered for the service. Responses of services without one always match.
+func Matches(service string, request []byte, response []byte) bool {
+
+	matcher, ok := MATCHERS[service]
+
+	if !ok {
+		return true
+	}
+	return matcher(request, response)
+}
+
+// MatchDNS compares the message IDs of DNS requests and responses, which mDNS
+// responders also echo to queries sent from ports other than 5353, and the
+// transaction IDs of NetBIOS name service messages, which share the format
+func MatchDNS(request []byte, response []byte) bool {
+
+	if len(request) < 2 || len(response) < 2 {
+		return true
+	}
+	return bytes.Equal(request[:2], response[:2])
+}
+
+// MatchSNMP compares the request IDs of SNMPv1 and SNMPv2c PDUs
+func MatchSNMP(request []byte, response []byte) bool {
+
+	requestID, ok := snmpRequestID(request)
+
+	if !ok {
+		return true
+	}
+	responseID, ok := snmpRequestID(response)
+
+	if !ok {
+		return true
+	}
+	return bytes.Equal(requestID, responseID)
+}
+
+// snmpRequestID returns the encoded request ID of an SNMPv1 or SNMPv2c message
+func snmpRequestID(message []byte) (id []byte, ok bool) {
+
+	var tag byte
+	var value []byte
+
+	if tag, message, _, ok = readBER(message); !ok || tag != BER_SEQUENCE {
+		return nil, false
+	}
+	if tag, value, message, ok = readBER(message); !ok || tag != BER_INTEGER || berInteger(value) == 3 {
+		return nil, false
+	}
+	if tag, _, message, ok = readBER(message); !ok || tag != BER_OCTET_STRING {
+		return nil, false
+	}
+	if _, message, _, ok = readBER(message); !ok {
+		return nil, false
+	}
+	if tag, id, _, ok = readBER(message); !ok || tag != BER_INTEGER {
+		return nil, false
+	}
+	return id, true
+}
+
+// MatchIKE compares the initiator cookies, or SPIs, of IKE messages
+func MatchIKE(request []byte, response []byte) bool {
+
+	if len(request) < 8 || len(response) < 8 {
+		return true
+	}
+	return bytes.Equal(request[:8], response[:8])
+}
+
+// MatchIKENATT compares the initiator cookies of IKE messages following a
+// non-ESP marker
+func MatchIKENATT(request []byte, response []byte) bool {
+
+	if len(request) < 4 || len(response) < 4 {
+		return true
+	}
+	return MatchIKE(request[4:], response[4:])
+}
+
+// MatchCLDAP compares the message IDs of connectionless LDAP messages
+func MatchCLDAP(request []byte, response []byte) bool {
+
+	requestID, ok := ldapMessageID(request)
+
+	if !ok {
+		return true
+	}
+	responseID, ok := ldapMessageID(response)
+
+	if !ok {
+		return true
+	}
+	return bytes.Equal(requestID, responseID)
+}
+
+// ldapMessageID returns the encoded message ID of the first LDAP message
+func ldapMessageID(message []byte) (id []byte, ok bool) {
+
+	var tag byte
+
+	if tag, message, _, ok = readBER(message); !ok || tag != BER_SEQUENCE {
+		return nil, false
+	}
+	if tag, id, _, ok = readBER(message); !ok || tag != BER_INTEGER {
+		return nil, false
+	}
+	return id, true
+}
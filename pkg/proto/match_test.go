@@ -0,0 +1,73 @@
+package proto
+
+import (
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+
+	// snmpGet builds a get request of the given version and request ID
+	snmpGet := func(version byte, id byte) []byte {
+		return ber(BER_SEQUENCE,
+			ber(BER_INTEGER, []byte{version}),
+			ber(BER_OCTET_STRING, []byte("public")),
+			ber(0xa0,
+				ber(BER_INTEGER, []byte{id}),
+				ber(BER_INTEGER, []byte{0}),
+				ber(BER_INTEGER, []byte{0}),
+				ber(BER_SEQUENCE)))
+	}
+
+	tests := []struct {
+		name     string
+		service  string
+		request  []byte
+		response []byte
+		match    bool
+	}{
+		{name: "same DNS ID", service: "dns", request: []byte{0x12, 0x34, 0x01}, response: []byte{0x12, 0x34, 0x81}, match: true},
+		{name: "other DNS ID", service: "dns", request: []byte{0x12, 0x34, 0x01}, response: []byte{0x43, 0x21, 0x81}},
+		{name: "mDNS legacy unicast", service: "mdns", request: []byte{0x00, 0x07}, response: []byte{0x00, 0x08}},
+		{name: "other NetBIOS transaction ID", service: "netbios", request: []byte{0xe5, 0xd8, 0x00}, response: []byte{0xe5, 0xd9, 0x84}},
+		{name: "short DNS response", service: "dns", request: []byte{0x12, 0x34}, response: []byte{0x12}, match: true},
+		{name: "same SNMP request ID", service: "snmp", request: snmpGet(1, 7), response: snmpGet(1, 7), match: true},
+		{name: "other SNMP request ID", service: "snmp", request: snmpGet(1, 7), response: snmpGet(1, 8)},
+		{name: "SNMPv3", service: "snmp", request: snmpGet(3, 7), response: snmpGet(3, 8), match: true},
+		{name: "unparsable SNMP response", service: "snmp", request: snmpGet(1, 7), response: []byte{0x30}, match: true},
+		{name: "same CLDAP message ID", service: "cldap", request: ber(BER_SEQUENCE, ber(BER_INTEGER, []byte{2})), response: ber(BER_SEQUENCE, ber(BER_INTEGER, []byte{2})), match: true},
+		{name: "other CLDAP message ID", service: "cldap", request: ber(BER_SEQUENCE, ber(BER_INTEGER, []byte{2})), response: ber(BER_SEQUENCE, ber(BER_INTEGER, []byte{1}))},
+		{name: "same IKE cookie", service: "ike", request: []byte("udpz\x00\x00\x00\x01"), response: []byte("udpz\x00\x00\x00\x01\xff"), match: true},
+		{name: "other IKE cookie", service: "ike", request: []byte("udpz\x00\x00\x00\x01"), response: []byte("udpz\x00\x00\x00\x02")},
+		{name: "other IKE NAT-T cookie", service: "ike-natt", request: []byte("\x00\x00\x00\x00udpz\x00\x00\x00\x11"), response: []byte("\x00\x00\x00\x00udpz\x00\x00\x00\x12")},
+		{name: "QUIC packet to the client", service: "quic", request: []byte("\xc0\x00\x00\x00\x01\x02ab\x02cd"), response: []byte("\x80\x00\x00\x00\x00\x02cd\x02ab\x00\x00\x00\x01"), match: true},
+		{name: "QUIC packet to another client", service: "quic", request: []byte("\xc0\x00\x00\x00\x01\x02ab\x02cd"), response: []byte("\x80\x00\x00\x00\x00\x02ab\x02cd\x00\x00\x00\x01")},
+		{name: "other STUN transaction ID", service: "stun", request: []byte("\x00\x01\x00\x00\x21\x12\xa4\x42udpz\x00\x00\x00\x00\x00\x00\x00\x06"), response: []byte("\x01\x01\x00\x00\x21\x12\xa4\x42udpz\x00\x00\x00\x00\x00\x00\x00\x07")},
+		{name: "same SIP Call-ID", service: "sip", request: []byte("OPTIONS sip:nm SIP/2.0\r\nCall-ID: a@b\r\n\r\n"), response: []byte("SIP/2.0 200 OK\r\ni: a@b\r\n\r\n"), match: true},
+		{name: "other SIP Call-ID", service: "sip", request: []byte("OPTIONS sip:nm SIP/2.0\r\nCall-ID: a@b\r\n\r\n"), response: []byte("SIP/2.0 200 OK\r\nCall-ID: c@d\r\n\r\n")},
+		{name: "same RMCP+ console session", service: "ipmi", request: []byte{6, 0, 0xff, 7, 6, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 8, 0, 0, 0, 0, 0, 'u', 'd', 'p', 'z'}, response: []byte{6, 0, 0xff, 7, 6, 0x11, 0, 0, 0, 0, 0, 0, 0, 0, 8, 0, 0, 0x11, 0, 0, 'u', 'd', 'p', 'z'}, match: true},
+		{name: "presence pong to an RMCP+ request", service: "ipmi", request: []byte{6, 0, 0xff, 7, 6, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 8, 0, 0, 0, 0, 0, 'u', 'd', 'p', 'z'}, response: []byte{6, 0, 0xff, 6, 0, 0, 0x11, 0xbe, 0x40, 0x75, 0, 0x10}},
+		{name: "same L2TP tunnel", service: "l2tp", request: []byte{0xc8, 0x02, 0, 20, 0, 0, 0, 0, 0, 0, 0, 0, 0x80, 8, 0, 0, 0, 9, 0x75, 0x7a}, response: []byte{0xc8, 0x02, 0, 12, 0x75, 0x7a, 0, 0, 0, 1, 0, 1}, match: true},
+		{name: "other L2TP tunnel", service: "l2tp", request: []byte{0xc8, 0x02, 0, 20, 0, 0, 0, 0, 0, 0, 0, 0, 0x80, 8, 0, 0, 0, 9, 0x75, 0x7a}, response: []byte{0xc8, 0x02, 0, 12, 0x4a, 0x32, 0, 0, 0, 1, 0, 1}},
+		{name: "same DHCPv6 transaction", service: "dhcpv6", request: []byte{1, 'u', 'd', 'p'}, response: []byte{2, 'u', 'd', 'p'}, match: true},
+		{name: "other DHCPv6 transaction", service: "dhcpv6", request: []byte{1, 'u', 'd', 'p'}, response: []byte{2, 'u', 'd', 'q'}},
+		{name: "same memcached request ID", service: "memcache", request: []byte{0x75, 0x7a, 0, 0, 0, 1, 0, 0}, response: []byte{0x75, 0x7a, 0, 0, 0, 1, 0, 0}, match: true},
+		{name: "other memcached request ID", service: "memcache", request: []byte{0x75, 0x7a, 0, 0, 0, 1, 0, 0}, response: []byte{0x5a, 0x4d, 0, 0, 0, 1, 0, 0}},
+		{name: "same RADIUS identifier", service: "radius", request: []byte{1, 0x75, 0, 20}, response: []byte{3, 0x75, 0, 20}, match: true},
+		{name: "other RADIUS identifier", service: "radius", request: []byte{1, 0x75, 0, 20}, response: []byte{3, 0x76, 0, 20}},
+		{name: "same PCP MAP nonce", service: "nat-pmp", request: append([]byte{2, 1}, append(make([]byte, 22), "udpz pcp map"...)...), response: append([]byte{2, 0x81}, append(make([]byte, 22), "udpz pcp map"...)...), match: true},
+		{name: "other PCP MAP nonce", service: "nat-pmp", request: append([]byte{2, 1}, append(make([]byte, 22), "udpz pcp map"...)...), response: append([]byte{2, 0x81}, append(make([]byte, 22), "udpz pcp mop"...)...)},
+		{name: "NAT-PMP error to a PCP request", service: "nat-pmp", request: []byte{2, 1, 0, 0}, response: []byte{0, 0x81, 0, 1}, match: true},
+		{name: "CoAP acknowledgement of another message", service: "coap", request: []byte{0x40, 0x01, 0x7d, 0x70}, response: []byte{0x60, 0x45, 0x7d, 0x71}},
+		{name: "separate CoAP response", service: "coap", request: []byte{0x40, 0x01, 0x7d, 0x70}, response: []byte{0x40, 0x45, 0x12, 0x34}, match: true},
+		{name: "same BACnet invoke ID", service: "bacnet", request: []byte{0x81, 0x0a, 0, 10, 1, 4, 0, 5, 2, 12}, response: []byte{0x81, 0x0a, 0, 9, 1, 0, 0x30, 2, 12}, match: true},
+		{name: "other BACnet invoke ID", service: "bacnet", request: []byte{0x81, 0x0a, 0, 10, 1, 4, 0, 5, 2, 12}, response: []byte{0x81, 0x0a, 0, 9, 1, 0, 0x30, 1, 14}},
+		{name: "other DCE/RPC activity", service: "profinet", request: append(make([]byte, 40), "udpz profinet cm"...), response: append(make([]byte, 40), "udpz profinet cn"...)},
+		{name: "service without matcher", service: "ntp", request: []byte{1}, response: []byte{2}, match: true},
+	}
+
+	for _, test := range tests {
+		if match := Matches(test.service, test.request, test.response); match != test.match {
+			t.Errorf("%s: Matches = %v, want %v", test.name, match, test.match)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	MEMCACHE_HEADER_LENGTH = 8
+)
+
+var (
+	// MEMCACHE_STATS are the general statistics kept as metadata
+	MEMCACHE_STATS = []string{
+		"curr_items",
+		"total_items",
+		"curr_connections",
+	}
+)
+
+// ParseMemcache extracts the version and item counts from memcached
+// responses to version and stats commands. Any memcached that answers over UDP
+// can be made to reflect the values stored in it, up to a megabyte per
+// request, so responses are flagged for DDoS reflection along with the
+// amplification of the one received.
+func ParseMemcache(request []byte, response []byte) (info Info, ok bool) {
+
+	text, _, found := readMemcache(response)
+
+	if !found || (len(request) >= 2 && !bytes.Equal(request[0:2], response[0:2])) {
+		return
+	}
+	answered := false
+
+	for _, line := range strings.Split(text, "\r\n") {
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 2 && fields[0] == "VERSION":
+			info.Version = printable(fields[1])
+			answered = true
+
+		case len(fields) == 3 && fields[0] == "STAT":
+			if fields[1] == "version" {
+				info.Version = printable(fields[2])
+			}
+			for _, name := range MEMCACHE_STATS {
+				if fields[1] == name {
+					info.setMetadata(name, printable(fields[2]))
+				}
+			}
+			answered = true
+
+		case len(fields) == 1 && fields[0] == "END":
+			answered = true
+		}
+	}
+	if !answered {
+		return
+	}
+	if factor := amplification(request, response); factor > 1 {
+		info.setMetadata("amplification", strconv.FormatFloat(factor, 'f', 1, 64))
+	}
+	info.setMetadata("ddos_reflection", "memcached")
+
+	return info, true
+}
+
+// MatchMemcache compares the request IDs of memcached requests and responses
+func MatchMemcache(request []byte, response []byte) bool {
+
+	if len(request) < 2 || len(response) < 2 {
+		return true
+	}
+	return bytes.Equal(request[0:2], response[0:2])
+}
+
+// DoneMemcache reports whether every datagram of a response arrived
+func DoneMemcache(responses []byte) bool {
+
+	_, complete, found := readMemcache(responses)
+
+	return !found || complete
+}
+
+// readMemcache reassembles the text of a response sent over several
+// datagrams, in the order of their sequence numbers, and reports whether
+// every datagram arrived. Each datagram starts with a frame header holding the
+// request ID, its sequence number and the number of datagrams.
+func readMemcache(responses []byte) (text string, complete bool, ok bool) {
+
+	if len(responses) < MEMCACHE_HEADER_LENGTH || !allZero(responses[6:8]) {
+		return
+	}
+	total := int(binary.BigEndian.Uint16(responses[4:6]))
+
+	if total == 0 {
+		return
+	}
+	header := append([]byte(nil), responses[0:MEMCACHE_HEADER_LENGTH]...)
+	starts := make(map[int]int)
+	var offsets []int
+
+	for sequence := 0; sequence < total; sequence++ {
+		binary.BigEndian.PutUint16(header[2:4], uint16(sequence))
+
+		if offset := bytes.Index(responses, header); offset >= 0 {
+			starts[offset] = sequence
+			offsets = append(offsets, offset)
+		}
+	}
+	sort.Ints(offsets)
+	datagrams := make([]string, total)
+
+	for i, offset := range offsets {
+		end := len(responses)
+
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		datagrams[starts[offset]] = string(responses[offset+MEMCACHE_HEADER_LENGTH : end])
+	}
+	return strings.Join(datagrams, ""), len(offsets) == total, true
+}
@@ -0,0 +1,92 @@
+package proto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// memcacheDatagram frames text as one of the datagrams answering request 0x757a
+func memcacheDatagram(sequence byte, total byte, text string) []byte {
+	return append([]byte{0x75, 0x7a, 0, sequence, 0, total, 0, 0}, text...)
+}
+
+func TestParseMemcache(t *testing.T) {
+
+	stats := append([]byte{0x75, 0x7a, 0, 0, 0, 1, 0, 0}, "stats\r\n"...)
+	version := append([]byte{0x75, 0x7a, 0, 0, 0, 1, 0, 0}, "version\r\n"...)
+
+	// The second datagram arrives before the first
+	first := "STAT pid 1\r\nSTAT version 1.6.21\r\nSTAT curr_connections 2\r\n" + strings.Repeat("STAT padding 0\r\n", 8)
+	second := "STAT curr_items 42\r\nSTAT total_items 1337\r\nEND\r\n"
+	split := append(memcacheDatagram(1, 2, second), memcacheDatagram(0, 2, first)...)
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "version",
+			request:  version,
+			response: memcacheDatagram(0, 1, "VERSION 1.4.15\r\n"),
+			info:     Info{Version: "1.4.15", Metadata: map[string]string{"amplification": "1.4", "ddos_reflection": "memcached"}},
+			ok:       true,
+		},
+		{
+			name:     "stats over two datagrams",
+			request:  stats,
+			response: split,
+			info: Info{Version: "1.6.21", Metadata: map[string]string{
+				"curr_connections": "2",
+				"curr_items":       "42",
+				"total_items":      "1337",
+				"amplification":    "16.7",
+				"ddos_reflection":  "memcached",
+			}},
+			ok: true,
+		},
+		{
+			name:     "other request",
+			request:  version,
+			response: append([]byte{0x75, 0x7b, 0, 0, 0, 1, 0, 0}, "VERSION 1.4.15\r\n"...),
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			request:  stats,
+			response: stats,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseMemcache(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
+
+func TestDoneMemcache(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		responses []byte
+		done      bool
+	}{
+		{name: "single datagram", responses: memcacheDatagram(0, 1, "END\r\n"), done: true},
+		{name: "first of two datagrams", responses: memcacheDatagram(0, 2, "STAT pid 1\r\n"), done: false},
+		{name: "both datagrams", responses: append(memcacheDatagram(0, 2, "STAT pid 1\r\n"), memcacheDatagram(1, 2, "END\r\n")...), done: true},
+		{name: "not memcached", responses: []byte("HTTP/1.1 400 Bad Request\r\n"), done: true},
+	}
+
+	for _, test := range tests {
+		if done := DoneMemcache(test.responses); done != test.done {
+			t.Errorf("%s: got %v, want %v", test.name, done, test.done)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package proto
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	SSRP_SVR_RESP      = 0x05
+	SSRP_HEADER_LENGTH = 3
+)
+
+// ParseMSSQL reports the instances SQL Server Browser lists in its answer to
+// an SSRP request, with the version and TCP port of each. Each instance is
+// given as semicolon separated keys and values, and ends with an empty one.
+// The banner is the name of the server, and the version that of its first
+// instance.
+func ParseMSSQL(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < SSRP_HEADER_LENGTH || response[0] != SSRP_SVR_RESP {
+		return
+	}
+	length := int(binary.LittleEndian.Uint16(response[1:3]))
+	text := string(response[SSRP_HEADER_LENGTH:])
+
+	// Servers with many instances may not fit in the response buffer, so
+	// the instances received in full are kept
+	if length < len(text) {
+		text = text[:length]
+	} else if length > len(text) {
+		text = text[:strings.LastIndex(text, ";;")+1]
+	}
+	var instances, ports []string
+
+	for _, listing := range strings.Split(text, ";;") {
+		fields := strings.Split(listing, ";")
+		instance := map[string]string{}
+
+		for i := 0; i+1 < len(fields); i += 2 {
+			instance[strings.ToLower(fields[i])] = printable(fields[i+1])
+		}
+		name := instance["instancename"]
+
+		if name == "" {
+			continue
+		}
+		info.setMetadata("hostname", instance["servername"])
+
+		if info.Version == "" {
+			info.Version = instance["version"]
+		}
+		if strings.EqualFold(instance["isclustered"], "yes") {
+			info.setMetadata("clustered", "true")
+		}
+		entry := name
+
+		if instance["version"] != "" {
+			entry += " " + instance["version"]
+		}
+		if port := instance["tcp"]; port != "" {
+			entry += " tcp/" + port
+			ports = append(ports, port)
+		}
+		instances = append(instances, entry)
+	}
+	if len(instances) == 0 {
+		return
+	}
+	info.setMetadata("instances", strings.Join(instances, ","))
+	info.setMetadata("tcp_ports", strings.Join(ports, ","))
+	info.Banner = info.Metadata["hostname"]
+
+	return info, true
+}
@@ -0,0 +1,66 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+// ssrpResponse builds an SVR_RESP message listing the instances
+func ssrpResponse(instances string) []byte {
+	return append([]byte{SSRP_SVR_RESP, byte(len(instances)), byte(len(instances) >> 8)}, instances...)
+}
+
+func TestParseMSSQL(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "instances",
+			response: ssrpResponse("ServerName;SQL01;InstanceName;MSSQLSERVER;IsClustered;No;Version;15.0.2000.5;tcp;1433;;ServerName;SQL01;InstanceName;SQLEXPRESS;IsClustered;No;Version;16.0.1000.6;tcp;49712;np;\\\\SQL01\\pipe\\MSSQL$SQLEXPRESS\\sql\\query;;"),
+			info: Info{Version: "15.0.2000.5", Banner: "SQL01", Metadata: map[string]string{
+				"hostname":  "SQL01",
+				"instances": "MSSQLSERVER 15.0.2000.5 tcp/1433,SQLEXPRESS 16.0.1000.6 tcp/49712",
+				"tcp_ports": "1433,49712",
+			}},
+			ok: true,
+		},
+		{
+			name:     "clustered without tcp",
+			response: ssrpResponse("ServerName;SQLCLUSTER;InstanceName;PROD;IsClustered;Yes;Version;13.0.5026.0;np;\\\\SQLCLUSTER\\pipe\\sql\\query;;"),
+			info: Info{Version: "13.0.5026.0", Banner: "SQLCLUSTER", Metadata: map[string]string{
+				"hostname":  "SQLCLUSTER",
+				"clustered": "true",
+				"instances": "PROD 13.0.5026.0",
+			}},
+			ok: true,
+		},
+		{
+			name:     "truncated",
+			response: ssrpResponse("ServerName;SQL01;InstanceName;MSSQLSERVER;tcp;1433;;ServerName;SQL01;InstanceName;SQLEXPRESS;tcp;49712;;")[:70],
+			info:     Info{Banner: "SQL01", Metadata: map[string]string{"hostname": "SQL01", "instances": "MSSQLSERVER tcp/1433", "tcp_ports": "1433"}},
+			ok:       true,
+		},
+		{
+			name:     "truncated instance",
+			response: ssrpResponse("ServerName;SQL01;InstanceName;MSSQLSERVER;;")[:20],
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			response: []byte{0x02},
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseMSSQL([]byte{0x02}, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+)
+
+const (
+	NATPMP_VERSION          = 0
+	NATPMP_HEADER_LENGTH    = 8
+	NATPMP_OPCODE_RESPONSE  = 0x80
+	NATPMP_OPCODE_ADDRESS   = 0
+	NATPMP_RESULT_VERSION   = 1
+	NATPMP_ADDRESS_RESPONSE = 12
+
+	PCP_VERSION         = 2
+	PCP_HEADER_LENGTH   = 24
+	PCP_OPCODE_RESPONSE = 0x80
+	PCP_OPCODE_MAP      = 1
+	PCP_MAP_LENGTH      = 36
+	PCP_NONCE_LENGTH    = 12
+
+	PCP_RESULT_UNSUPP_VERSION = 1
+	PCP_RESULT_UNSUPP_OPCODE  = 4
+)
+
+var (
+	// NATPMP_RESULTS maps the result codes of NAT-PMP responses to their names
+	NATPMP_RESULTS = map[uint16]string{
+		0: "success",
+		1: "unsupported version",
+		2: "not authorized",
+		3: "network failure",
+		4: "out of resources",
+		5: "unsupported opcode",
+	}
+	// PCP_RESULTS maps the result codes of PCP responses to their names
+	PCP_RESULTS = map[byte]string{
+		0:  "SUCCESS",
+		1:  "UNSUPP_VERSION",
+		2:  "NOT_AUTHORIZED",
+		3:  "MALFORMED_REQUEST",
+		4:  "UNSUPP_OPCODE",
+		5:  "UNSUPP_OPTION",
+		6:  "MALFORMED_OPTION",
+		7:  "NETWORK_FAILURE",
+		8:  "NO_RESOURCES",
+		9:  "UNSUPP_PROTOCOL",
+		10: "USER_EX_QUOTA",
+		11: "CANNOT_PROVIDE_EXTERNAL",
+		12: "ADDRESS_MISMATCH",
+		13: "EXCESSIVE_REMOTE_PEERS",
+	}
+)
+
+// ParseNATPMP reports the external address NAT-PMP gateways give, and whether
+// gateways speak NAT-PMP, PCP or both, and accept PCP requests for port
+// mappings. Gateways that answer on an internal network let any host open
+// ports to itself, or to others, from the Internet. Both protocols share the
+// port and tell their messages apart by version: gateways answer a version
+// they do not speak with an error in one they do.
+func ParseNATPMP(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < 4 || len(request) < 2 {
+		return
+	}
+	switch response[0] {
+	case NATPMP_VERSION:
+		return parseNATPMP(request, response)
+	case PCP_VERSION:
+		return parsePCP(request, response)
+	}
+	return
+}
+
+// MatchNATPMP compares the opcodes of NAT-PMP and PCP requests and responses,
+// and the nonces of PCP MAP requests and responses. Responses in the other
+// protocol than the request always match.
+func MatchNATPMP(request []byte, response []byte) bool {
+
+	if len(request) < 2 || len(response) < 2 || request[0] != response[0] {
+		return true
+	}
+	if response[1] != request[1]|NATPMP_OPCODE_RESPONSE {
+		return false
+	}
+	requested, responded := pcpNonce(request), pcpNonce(response)
+
+	return requested == nil || responded == nil || bytes.Equal(requested, responded)
+}
+
+// parseNATPMP reports the external address and result of a NAT-PMP response
+func parseNATPMP(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < NATPMP_HEADER_LENGTH || response[1]&NATPMP_OPCODE_RESPONSE == 0 {
+		return
+	}
+	result := binary.BigEndian.Uint16(response[2:4])
+
+	info.setMetadata("protocol", "NAT-PMP")
+	info.setMetadata("result", natpmpResult(result))
+
+	// The seconds since the gateway started or lost its mappings
+	info.setMetadata("epoch", strconv.FormatUint(uint64(binary.BigEndian.Uint32(response[4:8])), 10))
+
+	if request[0] == PCP_VERSION && result == NATPMP_RESULT_VERSION {
+		info.setMetadata("pcp", "unsupported")
+	}
+	if response[1] == NATPMP_OPCODE_RESPONSE|NATPMP_OPCODE_ADDRESS && result == 0 && len(response) >= NATPMP_ADDRESS_RESPONSE {
+		info.setMetadata("external_address", net.IP(response[8:12]).String())
+	}
+	return info, true
+}
+
+// parsePCP reports the result of a PCP response, and the external address and
+// port of those to MAP requests
+func parsePCP(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < PCP_HEADER_LENGTH || response[1]&PCP_OPCODE_RESPONSE == 0 {
+		return
+	}
+	opcode, result := response[1]&^PCP_OPCODE_RESPONSE, response[3]
+
+	info.setMetadata("protocol", "PCP")
+	info.setMetadata("result", pcpResult(result))
+	info.setMetadata("epoch", strconv.FormatUint(uint64(binary.BigEndian.Uint32(response[8:12])), 10))
+
+	switch {
+	case request[0] == NATPMP_VERSION && result == PCP_RESULT_UNSUPP_VERSION:
+		info.setMetadata("nat_pmp", "unsupported")
+
+	case opcode == PCP_OPCODE_MAP && result == PCP_RESULT_UNSUPP_OPCODE:
+		info.setMetadata("pcp_map", "unsupported")
+
+	case opcode == PCP_OPCODE_MAP && len(response) >= PCP_HEADER_LENGTH+PCP_MAP_LENGTH:
+		info.setMetadata("pcp_map", "supported")
+
+		// Gateways give the external address and port of the mapping in
+		// the same place the request suggested them
+		mapping := response[PCP_HEADER_LENGTH : PCP_HEADER_LENGTH+PCP_MAP_LENGTH]
+
+		if result == 0 {
+			if port := binary.BigEndian.Uint16(mapping[18:20]); port != 0 {
+				info.setMetadata("external_port", strconv.Itoa(int(port)))
+			}
+			if address := net.IP(mapping[20:36]); !address.IsUnspecified() {
+				info.setMetadata("external_address", address.String())
+			}
+		}
+	}
+	return info, true
+}
+
+// pcpNonce returns the mapping nonce of PCP MAP requests and responses
+func pcpNonce(packet []byte) []byte {
+
+	if len(packet) < PCP_HEADER_LENGTH+PCP_NONCE_LENGTH || packet[0] != PCP_VERSION ||
+		packet[1]&^PCP_OPCODE_RESPONSE != PCP_OPCODE_MAP {
+		return nil
+	}
+	return packet[PCP_HEADER_LENGTH : PCP_HEADER_LENGTH+PCP_NONCE_LENGTH]
+}
+
+// natpmpResult names the result code of a NAT-PMP response
+func natpmpResult(result uint16) string {
+
+	if name, found := NATPMP_RESULTS[result]; found {
+		return name
+	}
+	return strconv.Itoa(int(result))
+}
+
+// pcpResult names the result code of a PCP response
+func pcpResult(result byte) string {
+
+	if name, found := PCP_RESULTS[result]; found {
+		return name
+	}
+	return strconv.Itoa(int(result))
+}
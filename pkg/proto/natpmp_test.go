@@ -0,0 +1,125 @@
+package proto
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestParseNATPMP(t *testing.T) {
+
+	address := []byte{0, 0}
+	announce, _ := base64.StdEncoding.DecodeString("AgAAAAAAAAAAAAAAAAAAAAAA//8AAAAA")
+	mapping, _ := base64.StdEncoding.DecodeString("AgEAAAAAAAAAAAAAAAAAAAAA//8AAAAAdWRweiBwY3AgbWFwEQAAAAAJAAAAAAAAAAAAAAAA//8AAAAA")
+
+	// pcpResponse answers a request with the result code, an epoch of 3600
+	// seconds, and the opcode data of the request with the external port and
+	// address given
+	pcpResponse := func(request []byte, result byte, port uint16, external []byte) []byte {
+		response := append([]byte{2, request[1] | 0x80, 0, result, 0, 0, 0, 0, 0, 0, 0x0e, 0x10}, make([]byte, 12)...)
+
+		if len(request) < 24+36 {
+			return response
+		}
+		data := append([]byte(nil), request[24:]...)
+
+		if external != nil {
+			data[18], data[19] = byte(port>>8), byte(port)
+			copy(data[20:36], external)
+		}
+		return append(response, data...)
+	}
+	mappedAddress := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 198, 51, 100, 7}
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "external address",
+			request:  address,
+			response: []byte{0, 0x80, 0, 0, 0, 0, 0x0e, 0x10, 198, 51, 100, 7},
+			info:     Info{Metadata: map[string]string{"protocol": "NAT-PMP", "result": "success", "epoch": "3600", "external_address": "198.51.100.7"}},
+			ok:       true,
+		},
+		{
+			name:     "refused external address",
+			request:  address,
+			response: []byte{0, 0x80, 0, 2, 0, 0, 0x0e, 0x10, 0, 0, 0, 0},
+			info:     Info{Metadata: map[string]string{"protocol": "NAT-PMP", "result": "not authorized", "epoch": "3600"}},
+			ok:       true,
+		},
+		{
+			name:     "NAT-PMP gateway answering PCP",
+			request:  announce,
+			response: []byte{0, 0x80, 0, 1, 0, 0, 0x0e, 0x10},
+			info:     Info{Metadata: map[string]string{"protocol": "NAT-PMP", "result": "unsupported version", "epoch": "3600", "pcp": "unsupported"}},
+			ok:       true,
+		},
+		{
+			name:     "PCP gateway answering NAT-PMP",
+			request:  address,
+			response: pcpResponse([]byte{2, 0}, 1, 0, nil),
+			info:     Info{Metadata: map[string]string{"protocol": "PCP", "result": "UNSUPP_VERSION", "epoch": "3600", "nat_pmp": "unsupported"}},
+			ok:       true,
+		},
+		{
+			name:     "PCP announce",
+			request:  announce,
+			response: pcpResponse(announce, 0, 0, nil),
+			info:     Info{Metadata: map[string]string{"protocol": "PCP", "result": "SUCCESS", "epoch": "3600"}},
+			ok:       true,
+		},
+		{
+			name:     "PCP mapping",
+			request:  mapping,
+			response: pcpResponse(mapping, 0, 40009, mappedAddress),
+			info: Info{Metadata: map[string]string{
+				"protocol":         "PCP",
+				"result":           "SUCCESS",
+				"epoch":            "3600",
+				"pcp_map":          "supported",
+				"external_port":    "40009",
+				"external_address": "198.51.100.7",
+			}},
+			ok: true,
+		},
+		{
+			name:     "PCP mapping from another address",
+			request:  mapping,
+			response: pcpResponse(mapping, 12, 0, nil),
+			info:     Info{Metadata: map[string]string{"protocol": "PCP", "result": "ADDRESS_MISMATCH", "epoch": "3600", "pcp_map": "supported"}},
+			ok:       true,
+		},
+		{
+			name:     "PCP without mappings",
+			request:  mapping,
+			response: pcpResponse(mapping[:24], 4, 0, nil),
+			info:     Info{Metadata: map[string]string{"protocol": "PCP", "result": "UNSUPP_OPCODE", "epoch": "3600", "pcp_map": "unsupported"}},
+			ok:       true,
+		},
+		{
+			name:     "echoed request",
+			request:  mapping,
+			response: mapping,
+			ok:       false,
+		},
+		{
+			name:     "short response",
+			request:  address,
+			response: []byte{0, 0x80, 0, 0},
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseNATPMP(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	NETBIOS_TYPE_NBSTAT = 0x21
+
+	NETBIOS_NAME_LENGTH = 18 // Name, suffix and flags of each entry of a name table
+	NETBIOS_FLAG_GROUP  = 0x8000
+
+	NETBIOS_SUFFIX_WORKSTATION       = 0x00
+	NETBIOS_SUFFIX_MESSENGER         = 0x03
+	NETBIOS_SUFFIX_DOMAIN_CONTROLLER = 0x1c
+	NETBIOS_SUFFIX_FILE_SERVER       = 0x20
+)
+
+// ParseNetBIOS decodes the name table and MAC address of node status (NBSTAT)
+// responses. The unique workstation name is the host name, the group
+// workstation name its domain or workgroup, and a group domain controller name
+// marks domain controllers. The banner names the host as DOMAIN\HOST.
+func ParseNetBIOS(request []byte, response []byte) (info Info, ok bool) {
+
+	message, ok := parseDNS(response)
+
+	if !ok {
+		return
+	}
+	for _, record := range message.records {
+		if record.rtype != NETBIOS_TYPE_NBSTAT || len(record.raw) < 1 {
+			continue
+		}
+		data := record.raw
+		count := int(data[0])
+		data = data[1:]
+
+		if len(data) < count*NETBIOS_NAME_LENGTH {
+			count = len(data) / NETBIOS_NAME_LENGTH
+		}
+		var names, users []string
+
+		for i := 0; i < count; i++ {
+			entry := data[i*NETBIOS_NAME_LENGTH : (i+1)*NETBIOS_NAME_LENGTH]
+			name := printable(strings.TrimRight(string(entry[:15]), " \x00"))
+			suffix := entry[15]
+			group := binary.BigEndian.Uint16(entry[16:18])&NETBIOS_FLAG_GROUP != 0
+
+			if group {
+				names = append(names, fmt.Sprintf("%s<%02x group>", name, suffix))
+			} else {
+				names = append(names, fmt.Sprintf("%s<%02x>", name, suffix))
+			}
+			switch {
+			case suffix == NETBIOS_SUFFIX_WORKSTATION && !group:
+				if _, found := info.Metadata["hostname"]; !found {
+					info.setMetadata("hostname", name)
+				}
+			case suffix == NETBIOS_SUFFIX_WORKSTATION && group:
+				info.setMetadata("workgroup", name)
+			case suffix == NETBIOS_SUFFIX_DOMAIN_CONTROLLER && group:
+				info.setMetadata("domain_controller", "true")
+			case suffix == NETBIOS_SUFFIX_FILE_SERVER && !group:
+				info.setMetadata("file_server", "true")
+			case suffix == NETBIOS_SUFFIX_MESSENGER && !group:
+				users = append(users, name)
+			}
+		}
+		info.setMetadata("names", strings.Join(names, ","))
+
+		if info.Banner = info.Metadata["hostname"]; info.Banner != "" && info.Metadata["workgroup"] != "" {
+			info.Banner = info.Metadata["workgroup"] + "\\" + info.Banner
+		}
+
+		// The messenger service registers the host name too, and the names of
+		// logged on users
+		for _, user := range users {
+			if user != info.Metadata["hostname"] {
+				info.setMetadata("user", user)
+				break
+			}
+		}
+
+		// Samba reports an all zero address
+		if data = data[count*NETBIOS_NAME_LENGTH:]; len(data) >= 6 && !allZero(data[:6]) {
+			info.setMetadata("mac", net.HardwareAddr(data[:6]).String())
+		}
+		break
+	}
+	return info, !info.Empty()
+}
+
+// allZero reports whether every byte is zero
+func allZero(data []byte) bool {
+
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+// nbstatResponse builds a node status response with a name table entry for
+// each name, of the form "NAME<suffix>" with a flag for group names, followed
+// by the MAC address
+func nbstatResponse(mac []byte, entries ...[]byte) []byte {
+
+	response := []byte{0xe5, 0xd8, 0x84, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+	response = append(response, 0x20)
+	response = append(response, "CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"...)
+	response = append(response, 0x00, 0x00, NETBIOS_TYPE_NBSTAT, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00)
+
+	data := []byte{byte(len(entries))}
+
+	for _, entry := range entries {
+		data = append(data, entry...)
+	}
+	data = append(data, mac...)
+	data = append(data, make([]byte, 40)...)
+
+	response = append(response, byte(len(data)>>8), byte(len(data)))
+
+	return append(response, data...)
+}
+
+// nbstatName builds a name table entry
+func nbstatName(name string, suffix byte, group bool) []byte {
+
+	entry := []byte(name + "               ")[:15]
+	entry = append(entry, suffix, 0x04, 0x00)
+
+	if group {
+		entry[16] |= 0x80
+	}
+	return entry
+}
+
+func TestParseNetBIOS(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name: "domain controller",
+			response: nbstatResponse([]byte{0x00, 0x15, 0x5d, 0x01, 0x02, 0x03},
+				nbstatName("DC01", 0x00, false),
+				nbstatName("CORP", 0x00, true),
+				nbstatName("CORP", 0x1c, true),
+				nbstatName("DC01", 0x20, false),
+				nbstatName("DC01", 0x03, false),
+				nbstatName("ADMINISTRATOR", 0x03, false),
+			),
+			info: Info{
+				Banner: `CORP\DC01`,
+				Metadata: map[string]string{
+					"hostname":          "DC01",
+					"workgroup":         "CORP",
+					"domain_controller": "true",
+					"file_server":       "true",
+					"user":              "ADMINISTRATOR",
+					"mac":               "00:15:5d:01:02:03",
+					"names":             "DC01<00>,CORP<00 group>,CORP<1c group>,DC01<20>,DC01<03>,ADMINISTRATOR<03>",
+				},
+			},
+			ok: true,
+		},
+		{
+			name: "Samba",
+			response: nbstatResponse(make([]byte, 6),
+				nbstatName("FILES", 0x00, false),
+				nbstatName("\x01\x02__MSBROWSE__\x02", 0x01, true),
+			),
+			info: Info{
+				Banner: "FILES",
+				Metadata: map[string]string{
+					"hostname": "FILES",
+					"names":    "FILES<00>,__MSBROWSE__<01 group>",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "query",
+			response: []byte{0xe5, 0xd8, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseNetBIOS(nil, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package proto
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	NTP_MODE_SERVER    = 4
+	NTP_MODE_BROADCAST = 5
+	NTP_MODE_CONTROL   = 6
+	NTP_MODE_PRIVATE   = 7
+
+	NTP_HEADER_LENGTH         = 48
+	NTP_CONTROL_HEADER_LENGTH = 12
+	NTP_PRIVATE_HEADER_LENGTH = 8
+
+	NTP_REFLECTION_FACTOR = 2 // Amplification from which a control response is flagged as usable for reflection
+)
+
+var (
+	// NTP_CONTROL_VARIABLES are the system variables kept from control
+	// (mode 6) read variable responses
+	NTP_CONTROL_VARIABLES = []string{
+		"processor",
+		"leap",
+		"stratum",
+		"refid",
+		"peer",
+	}
+)
+
+// ParseNTP extracts the reference clock from server responses, the version and
+// system strings from control variable responses, and notes when private mode
+// requests such as monlist are answered. Control and private mode responses
+// also report how many times larger than the request they are, and are flagged
+// when the server could be used to reflect and amplify DDoS traffic.
+func ParseNTP(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < 1 {
+		return
+	}
+	version := int(response[0]>>3) & 0x07
+	mode := int(response[0]) & 0x07
+
+	switch mode {
+	case NTP_MODE_SERVER, NTP_MODE_BROADCAST:
+		if len(response) < NTP_HEADER_LENGTH {
+			return
+		}
+		stratum := int(response[1])
+
+		info.setMetadata("ntp_version", strconv.Itoa(version))
+		info.setMetadata("stratum", strconv.Itoa(stratum))
+
+		// The reference ID is a clock source name for primary servers, and
+		// the address of the upstream server otherwise
+		if stratum <= 1 {
+			info.setMetadata("refid", printable(strings.TrimRight(string(response[12:16]), "\x00")))
+		} else {
+			info.setMetadata("refid", net.IP(response[12:16]).String())
+		}
+		return info, true
+
+	case NTP_MODE_CONTROL:
+		if len(response) < NTP_CONTROL_HEADER_LENGTH || response[1]&0x80 == 0 {
+			return
+		}
+		count := int(binary.BigEndian.Uint16(response[10:12]))
+
+		if len(response) < NTP_CONTROL_HEADER_LENGTH+count {
+			count = len(response) - NTP_CONTROL_HEADER_LENGTH
+		}
+		variables := parseNTPVariables(string(response[NTP_CONTROL_HEADER_LENGTH : NTP_CONTROL_HEADER_LENGTH+count]))
+
+		info.Version = variables["version"]
+		info.Banner = variables["system"]
+		info.setMetadata("ntp_version", strconv.Itoa(version))
+
+		for _, name := range NTP_CONTROL_VARIABLES {
+			info.setMetadata(name, variables[name])
+		}
+		if factor := amplification(request, response); factor >= NTP_REFLECTION_FACTOR {
+			info.setMetadata("amplification", strconv.FormatFloat(factor, 'f', 1, 64))
+			info.setMetadata("ddos_reflection", "readvar")
+		}
+		return info, true
+
+	case NTP_MODE_PRIVATE:
+		if len(response) < NTP_PRIVATE_HEADER_LENGTH || response[0]&0x80 == 0 {
+			return
+		}
+		if errorCode := response[4] >> 4; errorCode != 0 {
+			info.setMetadata("mode7_error", strconv.Itoa(int(errorCode)))
+			return info, true
+		}
+		items := int(binary.BigEndian.Uint16(response[4:6]) & 0x0fff)
+
+		info.setMetadata("mode7", "enabled")
+		info.setMetadata("mode7_items", strconv.Itoa(items))
+
+		// Servers answer monlist with up to 100 packets of recent clients,
+		// of which only the first is measured
+		if factor := amplification(request, response); factor > 0 && items > 0 {
+			info.setMetadata("amplification", strconv.FormatFloat(factor, 'f', 1, 64))
+			info.setMetadata("ddos_reflection", "monlist")
+		}
+		return info, true
+	}
+	return
+}
+
+// amplification returns how many times larger the response is than the
+// request, or 0 without a request
+func amplification(request []byte, response []byte) float64 {
+
+	if len(request) == 0 {
+		return 0
+	}
+	return float64(len(response)) / float64(len(request))
+}
+
+// parseNTPVariables splits the comma separated name=value list of a control
+// response, removing quotes from values
+func parseNTPVariables(text string) (variables map[string]string) {
+
+	variables = make(map[string]string)
+
+	for len(text) > 0 {
+		var name, value string
+		var found bool
+
+		name, text, found = strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+
+		if !found {
+			break
+		}
+		text = strings.TrimLeft(text, " ")
+
+		if strings.HasPrefix(text, "\"") {
+			end := strings.IndexByte(text[1:], '"')
+
+			if end < 0 {
+				value, text = text[1:], ""
+			} else {
+				value, text = text[1:end+1], text[end+2:]
+			}
+			_, text, _ = strings.Cut(text, ",")
+
+		} else {
+			value, text, _ = strings.Cut(text, ",")
+		}
+		variables[name] = printable(value)
+	}
+	return
+}
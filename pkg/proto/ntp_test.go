@@ -0,0 +1,170 @@
+package proto
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// ntpServerResponse builds a mode 4 response from a server at the stratum
+func ntpServerResponse(stratum byte, refid []byte) []byte {
+
+	response := make([]byte, NTP_HEADER_LENGTH)
+	response[0] = 4<<3 | NTP_MODE_SERVER
+	response[1] = stratum
+	copy(response[12:16], refid)
+
+	return response
+}
+
+// ntpControlResponse builds a mode 6 read variables response
+func ntpControlResponse(variables string) []byte {
+
+	response := make([]byte, NTP_CONTROL_HEADER_LENGTH)
+	response[0] = 2<<3 | NTP_MODE_CONTROL
+	response[1] = 0x82
+	binary.BigEndian.PutUint16(response[10:12], uint16(len(variables)))
+
+	return append(response, variables...)
+}
+
+func TestParseNTP(t *testing.T) {
+
+	readvar := []byte{0x16, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	monlist := []byte{0x17, 0x00, 0x03, 0x2a, 0x00, 0x00, 0x00, 0x00}
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "primary server",
+			response: ntpServerResponse(1, []byte("GPS\x00")),
+			info:     Info{Metadata: map[string]string{"ntp_version": "4", "stratum": "1", "refid": "GPS"}},
+			ok:       true,
+		},
+		{
+			name:     "secondary server",
+			response: ntpServerResponse(3, []byte{192, 0, 2, 1}),
+			info:     Info{Metadata: map[string]string{"ntp_version": "4", "stratum": "3", "refid": "192.0.2.1"}},
+			ok:       true,
+		},
+		{
+			name:     "short server response",
+			response: ntpServerResponse(2, nil)[:20],
+			ok:       false,
+		},
+		{
+			name:     "control variables",
+			response: ntpControlResponse(`version="ntpd 4.2.8p15@1.3728-o", processor="x86_64", system="Linux/5.4.0", leap=0, stratum=2, refid=192.0.2.1`),
+			info: Info{
+				Version: "ntpd 4.2.8p15@1.3728-o",
+				Banner:  "Linux/5.4.0",
+				Metadata: map[string]string{
+					"ntp_version": "2",
+					"processor":   "x86_64",
+					"leap":        "0",
+					"stratum":     "2",
+					"refid":       "192.0.2.1",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "control variables amplified",
+			request:  readvar,
+			response: ntpControlResponse(`version="ntpd 4.2.8p15", system="Linux"`),
+			info: Info{
+				Version: "ntpd 4.2.8p15",
+				Banner:  "Linux",
+				Metadata: map[string]string{
+					"ntp_version":     "2",
+					"amplification":   "4.2",
+					"ddos_reflection": "readvar",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "control variables not amplified",
+			request:  append(readvar, make([]byte, 36)...),
+			response: ntpControlResponse(`stratum=2`),
+			info:     Info{Metadata: map[string]string{"ntp_version": "2", "stratum": "2"}},
+			ok:       true,
+		},
+		{
+			name:     "control request",
+			response: append([]byte{2<<3 | NTP_MODE_CONTROL, 0x02}, make([]byte, 10)...),
+			ok:       false,
+		},
+		{
+			name:     "private mode answered",
+			response: []byte{0x97, 0x00, 0x03, 0x2a, 0x00, 0x06, 0x00, 0x48},
+			info:     Info{Metadata: map[string]string{"mode7": "enabled", "mode7_items": "6"}},
+			ok:       true,
+		},
+		{
+			name:     "monlist answered",
+			request:  monlist,
+			response: append([]byte{0xd7, 0x00, 0x03, 0x2a, 0x00, 0x06, 0x00, 0x48}, make([]byte, 6*0x48)...),
+			info: Info{Metadata: map[string]string{
+				"mode7":           "enabled",
+				"mode7_items":     "6",
+				"amplification":   "55.0",
+				"ddos_reflection": "monlist",
+			}},
+			ok: true,
+		},
+		{
+			name:     "monlist without clients",
+			request:  monlist,
+			response: []byte{0x97, 0x00, 0x03, 0x2a, 0x00, 0x00, 0x00, 0x48},
+			info:     Info{Metadata: map[string]string{"mode7": "enabled", "mode7_items": "0"}},
+			ok:       true,
+		},
+		{
+			name:     "private mode refused",
+			response: []byte{0x97, 0x00, 0x03, 0x2a, 0x40, 0x00, 0x00, 0x00},
+			info:     Info{Metadata: map[string]string{"mode7_error": "4"}},
+			ok:       true,
+		},
+		{name: "client mode", response: []byte{0x23}, ok: false},
+		{name: "empty", response: []byte{}, ok: false},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseNTP(test.request, test.response)
+
+		if ok != test.ok {
+			t.Errorf("%s: ok = %v, want %v", test.name, ok, test.ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, want %+v", test.name, info, test.info)
+		}
+	}
+}
+
+func TestParseNTPVariables(t *testing.T) {
+
+	tests := []struct {
+		text      string
+		variables map[string]string
+	}{
+		{text: "", variables: map[string]string{}},
+		{text: "a=1, b=2", variables: map[string]string{"a": "1", "b": "2"}},
+		{text: `a="x, y", b=2`, variables: map[string]string{"a": "x, y", "b": "2"}},
+		{text: `a="unterminated`, variables: map[string]string{"a": "unterminated"}},
+		{text: "a=1,\r\nb=2\r\n", variables: map[string]string{"a": "1", "b": "2"}},
+		{text: "garbage", variables: map[string]string{}},
+	}
+
+	for _, test := range tests {
+		if variables := parseNTPVariables(test.text); !reflect.DeepEqual(variables, test.variables) {
+			t.Errorf("parseNTPVariables(%q) = %v, want %v", test.text, variables, test.variables)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/hex"
+)
+
+const (
+	OPENVPN_HARD_RESET_SERVER_V1 = 2
+	OPENVPN_HARD_RESET_SERVER_V2 = 8
+
+	OPENVPN_SESSION_ID_LENGTH = 8
+	OPENVPN_PACKET_ID_LENGTH  = 4
+)
+
+var (
+	// OPENVPN_PROTOCOLS maps the opcodes of server hard resets to the version
+	// of the key method they answer
+	OPENVPN_PROTOCOLS = map[byte]string{
+		OPENVPN_HARD_RESET_SERVER_V1: "1",
+		OPENVPN_HARD_RESET_SERVER_V2: "2",
+	}
+)
+
+// ParseOpenVPN accepts the hard reset servers answer a client's with, which
+// acknowledges the client's session ID, and reports the protocol version and
+// the server's session ID
+func ParseOpenVPN(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < 1+OPENVPN_SESSION_ID_LENGTH+1 {
+		return
+	}
+	protocol, found := OPENVPN_PROTOCOLS[response[0]>>3]
+
+	if !found {
+		return
+	}
+	if remote, found := openVPNRemoteSession(response); found && len(request) > OPENVPN_SESSION_ID_LENGTH &&
+		!bytes.Equal(remote, request[1:1+OPENVPN_SESSION_ID_LENGTH]) {
+		return
+	}
+	info.setMetadata("protocol_version", protocol)
+	info.setMetadata("session_id", hex.EncodeToString(response[1:1+OPENVPN_SESSION_ID_LENGTH]))
+
+	return info, true
+}
+
+// MatchOpenVPN compares the session ID of a client's packet with the one a
+// server's acknowledges
+func MatchOpenVPN(request []byte, response []byte) bool {
+
+	remote, found := openVPNRemoteSession(response)
+
+	if !found || len(request) < 1+OPENVPN_SESSION_ID_LENGTH {
+		return true
+	}
+	return bytes.Equal(remote, request[1:1+OPENVPN_SESSION_ID_LENGTH])
+}
+
+// openVPNRemoteSession returns the session ID of the peer that the
+// acknowledgements of a control packet are for, if it has any
+func openVPNRemoteSession(packet []byte) (session []byte, ok bool) {
+
+	offset := 1 + OPENVPN_SESSION_ID_LENGTH
+
+	if len(packet) <= offset || packet[offset] == 0 {
+		return nil, false
+	}
+	offset += 1 + int(packet[offset])*OPENVPN_PACKET_ID_LENGTH
+
+	if len(packet) < offset+OPENVPN_SESSION_ID_LENGTH {
+		return nil, false
+	}
+	return packet[offset : offset+OPENVPN_SESSION_ID_LENGTH], true
+}
@@ -0,0 +1,79 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOpenVPN(t *testing.T) {
+
+	// A P_CONTROL_HARD_RESET_CLIENT_V2 with session ID 1212121212121212
+	request := []byte{0x38, 0x12, 0x12, 0x12, 0x12, 0x12, 0x12, 0x12, 0x12, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	// serverReset builds a server hard reset of the opcode, acknowledging
+	// packet 0 of the session
+	serverReset := func(opcode byte, session byte) []byte {
+		return []byte{
+			opcode << 3, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8,
+			0x01, 0x00, 0x00, 0x00, 0x00,
+			session, session, session, session, session, session, session, session,
+			0x00, 0x00, 0x00, 0x00,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "hard reset v2",
+			response: serverReset(OPENVPN_HARD_RESET_SERVER_V2, 0x12),
+			info:     Info{Metadata: map[string]string{"protocol_version": "2", "session_id": "a1a2a3a4a5a6a7a8"}},
+			ok:       true,
+		},
+		{
+			name:     "hard reset v1",
+			response: serverReset(OPENVPN_HARD_RESET_SERVER_V1, 0x12),
+			info:     Info{Metadata: map[string]string{"protocol_version": "1", "session_id": "a1a2a3a4a5a6a7a8"}},
+			ok:       true,
+		},
+		{
+			name:     "reset for another session",
+			response: serverReset(OPENVPN_HARD_RESET_SERVER_V2, 0x34),
+			ok:       false,
+		},
+		{
+			name:     "other opcode",
+			response: serverReset(4, 0x12),
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			response: request,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseOpenVPN(request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+	if MatchOpenVPN(request, serverReset(OPENVPN_HARD_RESET_SERVER_V2, 0x34)) {
+		t.Errorf("reset for another session matched")
+	}
+}
+
+func TestSilent(t *testing.T) {
+
+	if info, ok := Silent("openvpn"); !ok || info.Metadata["tls_auth"] != "likely" {
+		t.Errorf("got %+v, %v for OpenVPN, want a tls_auth hint", info, ok)
+	}
+	if info, ok := Silent("dns"); ok {
+		t.Errorf("got %+v, true for DNS, want no hint", info)
+	}
+}
@@ -0,0 +1,128 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	DCERPC_HEADER_LENGTH   = 80
+	DCERPC_VERSION         = 4
+	DCERPC_RESPONSE        = 2
+	DCERPC_LITTLE_ENDIAN   = 0x10
+	DCERPC_ACTIVITY_OFFSET = 40
+	DCERPC_UUID_LENGTH     = 16
+
+	EPM_HANDLE_LENGTH = 20
+
+	// Columns of the annotation PROFINET IO devices register their
+	// endpoint with
+	PNIO_DEVICE_TYPE_LENGTH = 25
+	PNIO_ORDER_ID_LENGTH    = 20
+	PNIO_HW_REVISION_LENGTH = 5
+	PNIO_ANNOTATION_LENGTH  = 63
+)
+
+// ParsePROFINET reports the device type, order number and hardware and
+// software revisions of PROFINET IO devices, such as Siemens PLCs, from the
+// annotation of the endpoint their context manager registers, which they
+// return to an endpoint mapper lookup over DCE/RPC. PROFINET DCP
+// identification runs over Ethernet frames rather than UDP, so it cannot be
+// used across routers.
+func ParsePROFINET(request []byte, response []byte) (info Info, ok bool) {
+
+	body, found := dcerpcResponse(request, response)
+
+	if !found {
+		return
+	}
+	// The entry handle, the number of entries, then the bounds of the array
+	// of entries precede the first entry
+	offset := EPM_HANDLE_LENGTH
+
+	if len(body) < offset+16 {
+		return info, true
+	}
+	order := dcerpcOrder(response)
+
+	if order.Uint32(body[offset:offset+4]) == 0 {
+		return info, true
+	}
+	offset += 16
+
+	// The object UUID and the referent of the tower precede the annotation
+	offset += DCERPC_UUID_LENGTH + 4
+
+	if len(body) < offset+8 {
+		return info, true
+	}
+	length := int(order.Uint32(body[offset+4 : offset+8]))
+	offset += 8
+
+	if length > len(body)-offset {
+		return info, true
+	}
+	annotation := strings.TrimRight(string(body[offset:offset+length]), "\x00")
+
+	if len(annotation) < PNIO_ANNOTATION_LENGTH {
+		info.Banner = printable(annotation)
+
+		return info, true
+	}
+	column := 0
+	next := func(width int) string {
+		field := annotation[column : column+width]
+		column += width + 1
+
+		return strings.TrimSpace(field)
+	}
+	info.setMetadata("device_type", printable(next(PNIO_DEVICE_TYPE_LENGTH)))
+	info.setMetadata("order_id", printable(next(PNIO_ORDER_ID_LENGTH)))
+	info.setMetadata("hardware_revision", printable(next(PNIO_HW_REVISION_LENGTH)))
+
+	// The software revision is a prefix, such as V for released versions,
+	// followed by three numbers of three characters each
+	software := annotation[column:PNIO_ANNOTATION_LENGTH]
+	info.Version = printable(software[0:1] + strings.Join(strings.Fields(software[1:]), "."))
+	info.Banner = info.Metadata["device_type"]
+
+	return info, true
+}
+
+// MatchPROFINET compares the activity UUIDs of DCE/RPC requests and responses
+func MatchPROFINET(request []byte, response []byte) bool {
+
+	end := DCERPC_ACTIVITY_OFFSET + DCERPC_UUID_LENGTH
+
+	if len(request) < end || len(response) < end {
+		return true
+	}
+	return bytes.Equal(request[DCERPC_ACTIVITY_OFFSET:end], response[DCERPC_ACTIVITY_OFFSET:end])
+}
+
+// dcerpcResponse returns the body of a connectionless DCE/RPC response to the
+// request
+func dcerpcResponse(request []byte, response []byte) ([]byte, bool) {
+
+	if len(response) < DCERPC_HEADER_LENGTH || response[0] != DCERPC_VERSION || response[1] != DCERPC_RESPONSE ||
+		!MatchPROFINET(request, response) {
+		return nil, false
+	}
+	length := int(dcerpcOrder(response).Uint16(response[74:76]))
+
+	if DCERPC_HEADER_LENGTH+length > len(response) {
+		return nil, false
+	}
+	return response[DCERPC_HEADER_LENGTH : DCERPC_HEADER_LENGTH+length], true
+}
+
+// dcerpcOrder returns the byte order of the integers of a DCE/RPC packet, as
+// given by its data representation
+func dcerpcOrder(packet []byte) binary.ByteOrder {
+
+	if packet[4]&DCERPC_LITTLE_ENDIAN != 0 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
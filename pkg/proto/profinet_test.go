@@ -0,0 +1,94 @@
+package proto
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// epmResponse answers an endpoint mapper lookup with one entry annotated with
+// the text, or with no entries if it is empty
+func epmResponse(request []byte, annotation string) []byte {
+
+	body := make([]byte, EPM_HANDLE_LENGTH+16)
+
+	if annotation != "" {
+		binary.LittleEndian.PutUint32(body[20:24], 1)
+		binary.LittleEndian.PutUint32(body[24:28], 1)
+		binary.LittleEndian.PutUint32(body[32:36], 1)
+
+		entry := make([]byte, 28)
+		binary.LittleEndian.PutUint32(entry[16:20], 3)
+		binary.LittleEndian.PutUint32(entry[24:28], uint32(len(annotation)+1))
+		body = append(append(append(body, entry...), annotation...), 0)
+	}
+	response := append([]byte(nil), request[:DCERPC_HEADER_LENGTH]...)
+	response[1], response[2] = DCERPC_RESPONSE, 0x0a
+	binary.LittleEndian.PutUint16(response[74:76], uint16(len(body)))
+
+	return append(response, body...)
+}
+
+func TestParsePROFINET(t *testing.T) {
+
+	request, _ := base64.StdEncoding.DecodeString("BAAgABAAAAAAAAAAAAAAAAAAAAAAAAAACIOv4R9dyRGRpAgAKxSg+nVkcHogcHJvZmluZXQgY20AAAAAAwAAAAAAAAACAP////9MAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAAAAAAAACAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABAAAA")
+	annotation := fmt.Sprintf("%-25s %-20s %5d %c%3d%3d%3d", "S7-1500", "6ES7 516-3AN01-0AB0", 3, 'V', 2, 9, 4)
+
+	other := epmResponse(request, annotation)
+	other[DCERPC_ACTIVITY_OFFSET] ^= 0xff
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "device annotation",
+			request:  request,
+			response: epmResponse(request, annotation),
+			info: Info{Version: "V2.9.4", Banner: "S7-1500", Metadata: map[string]string{
+				"device_type":       "S7-1500",
+				"order_id":          "6ES7 516-3AN01-0AB0",
+				"hardware_revision": "3",
+			}},
+			ok: true,
+		},
+		{
+			name:     "other annotation",
+			request:  request,
+			response: epmResponse(request, "Remote Fw API"),
+			info:     Info{Banner: "Remote Fw API"},
+			ok:       true,
+		},
+		{
+			name:     "no entries",
+			request:  request,
+			response: epmResponse(request, ""),
+			ok:       true,
+		},
+		{
+			name:     "other activity",
+			request:  request,
+			response: other,
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			request:  request,
+			response: request,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParsePROFINET(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package proto
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Info is the version and banner information extracted from a response
+type Info struct {
+	Version  string            `yaml:"version,omitempty" json:"version,omitempty"`
+	Banner   string            `yaml:"banner,omitempty" json:"banner,omitempty"`
+	Metadata map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+}
+
+// Parser extracts information from the response to a probe. It returns false
+// if the response could not be parsed or contained nothing of interest.
+type Parser func(request []byte, response []byte) (info Info, ok bool)
+
+// FollowUp continues the exchange with services that only reveal themselves
+// after more than one round trip, such as handshakes that need a cookie
+type FollowUp struct {
+	// Next returns the request answering the latest response, if one is due.
+	// Services that answer with several datagrams on their own have none.
+	Next func(request []byte, response []byte) (next []byte, ok bool)
+	// Done reports whether the responses received so far end the exchange
+	Done func(responses []byte) bool
+}
+
+var (
+	// PARSERS maps service slugs to the parser for their responses
+	PARSERS = map[string]Parser{
+		"bacnet":            ParseBACnet,
+		"chargen":           ParseCharGen,
+		"cldap":             ParseCLDAP,
+		"coap":              ParseCoAP,
+		"daytime":           ParseDaytime,
+		"dhcp":              ParseDHCP,
+		"dhcpv6":            ParseDHCPv6,
+		"dnp3":              ParseDNP3,
+		"dns":               ParseDNS,
+		"dtls":              ParseDTLS,
+		"echo":              ParseEcho,
+		"enip":              ParseENIP,
+		"geneve":            ParseGeneve,
+		"gre-udp":           ParseGREUDP,
+		"ike":               ParseIKE,
+		"ike-natt":          ParseIKENATT,
+		"ipmi":              ParseIPMI,
+		"knxnet-ip":         ParseKNXnet,
+		"l2tp":              ParseL2TP,
+		"llmnr":             ParseLLMNR,
+		"mdns":              ParseMDNS,
+		"memcache":          ParseMemcache,
+		"minecraft-bedrock": ParseRakNet,
+		"mssql":             ParseMSSQL,
+		"nat-pmp":           ParseNATPMP,
+		"netbios":           ParseNetBIOS,
+		"ntp":               ParseNTP,
+		"openvpn":           ParseOpenVPN,
+		"profinet":          ParsePROFINET,
+		"qotd":              ParseQOTD,
+		"quake3":            ParseQuake,
+		"quic":              ParseQUIC,
+		"radius":            ParseRADIUS,
+		"sip":               ParseSIP,
+		"snmp":              ParseSNMP,
+		"snmptrap":          ParseSNMPTrap,
+		"steam":             ParseA2S,
+		"stun":              ParseSTUN,
+		"teamspeak":         ParseTeamSpeak,
+		"tftp":              ParseTFTP,
+		"time":              ParseTime,
+		"upnp":              ParseUPnP,
+		"vxlan":             ParseVXLAN,
+		"wireguard":         ParseWireGuard,
+		"wsd":               ParseWSD,
+	}
+	// SILENT_HINTS maps services that silently drop probes they cannot
+	// authenticate to the metadata a port of theirs that never answers gets
+	SILENT_HINTS = map[string]map[string]string{
+		"geneve":    {"tunnel_endpoint": "likely", "tunnel_injection": "possible"},
+		"gre-udp":   {"tunnel_endpoint": "likely", "tunnel_injection": "possible"},
+		"llmnr":     {"llmnr": "likely"},
+		"openvpn":   {"tls_auth": "likely"},
+		"snmptrap":  {"snmptrap": "likely"},
+		"syslog":    {"syslog": "likely"},
+		"vxlan":     {"tunnel_endpoint": "likely", "tunnel_injection": "possible"},
+		"wireguard": {"wireguard": "likely"},
+	}
+	// FOLLOW_UPS maps service slugs to how the exchange with them continues
+	// after the first response
+	FOLLOW_UPS = map[string]FollowUp{
+		"dtls":     {Next: NextDTLS, Done: DoneDTLS},
+		"memcache": {Done: DoneMemcache},
+		"steam":    {Next: NextA2S, Done: DoneA2S},
+	}
+	// OTHER_PORT_REPLIES are the services whose servers answer from another
+	// port than the one probed, such as TFTP servers answering from the port
+	// of a new transfer
+	OTHER_PORT_REPLIES = map[string]bool{
+		"tftp": true,
+	}
+)
+
+// Parse extracts information from a response using the parser registered for
+// the service, if there is one
+func Parse(service string, request []byte, response []byte) (info Info, ok bool) {
+
+	parser, ok := PARSERS[service]
+
+	if !ok || len(response) == 0 {
+		return Info{}, false
+	}
+	return parser(request, response)
+}
+
+// Silent returns what a port of the service staying silent suggests, for
+// services that drop probes they cannot authenticate. It only means something
+// on hosts that report their closed ports.
+func Silent(service string) (info Info, ok bool) {
+
+	hint, ok := SILENT_HINTS[service]
+
+	if !ok {
+		return Info{}, false
+	}
+	for key, value := range hint {
+		info.setMetadata(key, value)
+	}
+	return info, true
+}
+
+// Empty reports whether no information was extracted
+func (info Info) Empty() bool {
+	return info.Version == "" && info.Banner == "" && len(info.Metadata) == 0
+}
+
+// Merge fills in the fields of info that are missing from other, which is
+// useful when several probes to the same port each reveal something different
+func (info Info) Merge(other Info) Info {
+
+	if info.Version == "" {
+		info.Version = other.Version
+	}
+	if info.Banner == "" {
+		info.Banner = other.Banner
+	}
+	if len(other.Metadata) > 0 {
+		metadata := make(map[string]string, len(info.Metadata)+len(other.Metadata))
+
+		for key, value := range other.Metadata {
+			metadata[key] = value
+		}
+		for key, value := range info.Metadata {
+			metadata[key] = value
+		}
+		info.Metadata = metadata
+	}
+	return info
+}
+
+// setMetadata records a metadata value, ignoring empty values
+func (info *Info) setMetadata(key string, value string) {
+
+	if value == "" {
+		return
+	}
+	if info.Metadata == nil {
+		info.Metadata = make(map[string]string)
+	}
+	info.Metadata[key] = value
+}
+
+// printable strips control characters and surrounding whitespace from text
+// taken from a response
+func printable(text string) string {
+
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return ' '
+		}
+		if r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, text))
+}
@@ -0,0 +1,79 @@
+package proto
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// QUAKE_STATUS_RESPONSE starts the answers of Quake III engine servers to
+	// getstatus
+	QUAKE_STATUS_RESPONSE = []byte("\xff\xff\xff\xffstatusResponse")
+	// QUAKE_COLORS matches the color codes of names
+	QUAKE_COLORS = regexp.MustCompile(`\^[0-9A-Za-z]`)
+	// QUAKE_VARIABLES maps the server variables kept as metadata to their key
+	QUAKE_VARIABLES = map[string]string{
+		"mapname":       "map",
+		"gamename":      "game",
+		"g_gametype":    "game_type",
+		"sv_maxclients": "max_players",
+		"g_needpass":    "password",
+	}
+)
+
+// ParseQuake reports the host name, map, game, player counts and version of
+// Quake III engine game servers, such as those of Quake III Arena, Wolfenstein:
+// Enemy Territory and Call of Duty, from their answer to getstatus. The server
+// variables are followed by a line for each player.
+func ParseQuake(request []byte, response []byte) (info Info, ok bool) {
+
+	if !bytes.HasPrefix(response, QUAKE_STATUS_RESPONSE) {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(response[len(QUAKE_STATUS_RESPONSE):]), "\n\x00"), "\n")
+
+	if len(lines) < 2 || !strings.HasPrefix(lines[1], `\`) {
+		return
+	}
+	// Keys and values alternate after a backslash each
+	variables := strings.Split(lines[1][1:], `\`)
+
+	for i := 0; i+1 < len(variables); i += 2 {
+		name, value := strings.ToLower(variables[i]), quakeText(variables[i+1])
+
+		switch name {
+		case "sv_hostname", "hostname":
+			info.Banner = value
+		case "version", "shortversion":
+			if info.Version == "" || name == "version" {
+				info.Version = value
+			}
+		}
+		if key, found := QUAKE_VARIABLES[name]; found {
+			info.setMetadata(key, value)
+		}
+	}
+	if info.Metadata["password"] == "0" {
+		delete(info.Metadata, "password")
+	} else if info.Metadata["password"] != "" {
+		info.Metadata["password"] = "true"
+	}
+	players := 0
+
+	for _, line := range lines[2:] {
+		if strings.TrimSpace(line) != "" {
+			players++
+		}
+	}
+	info.setMetadata("players", strconv.Itoa(players))
+
+	return info, true
+}
+
+// quakeText strips the color codes and control characters of a server
+// variable
+func quakeText(text string) string {
+	return printable(QUAKE_COLORS.ReplaceAllString(text, ""))
+}
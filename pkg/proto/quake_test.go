@@ -0,0 +1,49 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuake(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "status",
+			response: []byte("\xff\xff\xff\xffstatusResponse\n\\sv_hostname\\^1udpz ^7test\\mapname\\q3dm17\\gamename\\baseq3\\g_gametype\\0\\sv_maxclients\\16\\g_needpass\\0\\version\\ioq3 1.36_GIT linux-x86_64\n0 48 \"^2bot\"\n12 35 \"player\"\n"),
+			info: Info{Version: "ioq3 1.36_GIT linux-x86_64", Banner: "udpz test", Metadata: map[string]string{
+				"map": "q3dm17", "game": "baseq3", "game_type": "0", "max_players": "16", "players": "2",
+			}},
+			ok: true,
+		},
+		{
+			name:     "password and short version",
+			response: []byte("\xff\xff\xff\xffstatusResponse\n\\hostname\\udpz\\shortversion\\2.60b\\g_needpass\\1\n"),
+			info:     Info{Version: "2.60b", Banner: "udpz", Metadata: map[string]string{"password": "true", "players": "0"}},
+			ok:       true,
+		},
+		{
+			name:     "no variables",
+			response: []byte("\xff\xff\xff\xffstatusResponse\n"),
+			ok:       false,
+		},
+		{
+			name:     "other protocol",
+			response: []byte("\xff\xff\xff\xffprint\nunknown command\n"),
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseQuake(nil, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package proto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	QUIC_VERSION_NEGOTIATION = 0
+	QUIC_VERSION_1           = 0x00000001
+	QUIC_VERSION_2           = 0x6b3343cf
+
+	QUIC_PACKET_INITIAL   = 0 // Long header packet types, as numbered by QUIC version 1
+	QUIC_PACKET_0RTT      = 1
+	QUIC_PACKET_HANDSHAKE = 2
+	QUIC_PACKET_RETRY     = 3
+
+	QUIC_FRAME_PADDING              = 0x00
+	QUIC_FRAME_PING                 = 0x01
+	QUIC_FRAME_ACK                  = 0x02
+	QUIC_FRAME_ACK_ECN              = 0x03
+	QUIC_FRAME_CRYPTO               = 0x06
+	QUIC_FRAME_CONNECTION_CLOSE     = 0x1c
+	QUIC_FRAME_CONNECTION_CLOSE_APP = 0x1d
+	QUIC_CRYPTO_ERROR               = 0x100 // Transport error codes of TLS alerts start here
+	QUIC_SAMPLE_LENGTH              = 16    // Ciphertext sampled for header protection
+	QUIC_HANDSHAKE_LIMIT            = 0x4000
+
+	TLS_HANDSHAKE_CLIENT_HELLO = 1
+	TLS_HANDSHAKE_SERVER_HELLO = 2
+	TLS_EXTENSION_ALPN         = 16
+)
+
+var (
+	// QUIC_VERSIONS maps the QUIC versions servers commonly announce to their
+	// names
+	QUIC_VERSIONS = map[uint32]string{
+		QUIC_VERSION_1: "1",
+		QUIC_VERSION_2: "2",
+		0x51303433:     "Q043",
+		0x51303436:     "Q046",
+		0x51303530:     "Q050",
+		0x54303531:     "T051",
+	}
+	// QUIC_INITIAL_KEYS are the salt and labels Initial packet protection keys
+	// of each QUIC version derive from
+	QUIC_INITIAL_KEYS = map[uint32]struct {
+		salt   []byte
+		prefix string
+	}{
+		QUIC_VERSION_1: {[]byte("\x38\x76\x2c\xf7\xf5\x59\x34\xb3\x4d\x17\x9a\xe6\xa4\xc8\x0c\xad\xcc\xbb\x7f\x0a"), "quic "},
+		QUIC_VERSION_2: {[]byte("\x0d\xed\xe3\xde\xf7\x00\xa6\xdb\x81\x93\x81\xbe\x6e\x26\x9d\xcb\xf9\xbd\x2e\xd9"), "quicv2 "},
+	}
+	// QUIC_ERRORS maps the transport error codes servers commonly close
+	// connections with to their names
+	QUIC_ERRORS = map[uint64]string{
+		0x0: "NO_ERROR",
+		0x1: "INTERNAL_ERROR",
+		0x2: "CONNECTION_REFUSED",
+		0x7: "FRAME_ENCODING_ERROR",
+		0x8: "TRANSPORT_PARAMETER_ERROR",
+		0xa: "PROTOCOL_VIOLATION",
+		0xb: "INVALID_TOKEN",
+	}
+)
+
+// quicPacket is a long header packet
+type quicPacket struct {
+	kind     int // Packet type, as numbered by QUIC version 1
+	version  uint32
+	dcid     []byte
+	scid     []byte
+	header   int    // Offset of the packet number, or of the payload of packets without one
+	data     []byte // The whole packet
+	versions []uint32
+}
+
+// ParseQUIC reports the versions QUIC servers announce in response to a
+// version they do not support, and the version, TLS cipher suite and ALPN
+// protocol of servers answering an Initial packet. The Initial packets of
+// both ends are protected with keys derived from the connection ID the client
+// chose, so the ServerHello can be read from the response. A server only
+// sends one if it accepted the application protocol offered, which is taken
+// from the request when it offered only one.
+func ParseQUIC(request []byte, response []byte) (info Info, ok bool) {
+
+	packets := readQUIC(response)
+
+	if len(packets) == 0 || !MatchQUIC(request, response) {
+		return
+	}
+	if packets[0].version == QUIC_VERSION_NEGOTIATION {
+		var versions []string
+
+		for _, version := range packets[0].versions {
+			if name := quicVersion(version); name != "" {
+				versions = append(versions, name)
+			}
+		}
+		info.setMetadata("quic_versions", strings.Join(versions, ","))
+
+		return info, !info.Empty()
+	}
+	info.setMetadata("quic_version", quicVersion(packets[0].version))
+
+	var dcid []byte
+	var offered []string
+
+	if requests := readQUIC(request); len(requests) > 0 && requests[0].kind == QUIC_PACKET_INITIAL {
+		dcid = requests[0].dcid
+
+		if frames, ok := quicOpenInitial(requests[0], dcid, "client in"); ok {
+			crypto, _, _ := quicFrames(frames)
+			offered = tlsALPN(crypto)
+		}
+	}
+
+	for _, packet := range packets {
+		switch packet.kind {
+		case QUIC_PACKET_RETRY:
+			info.setMetadata("retry", "true")
+
+		case QUIC_PACKET_INITIAL:
+			frames, ok := quicOpenInitial(packet, dcid, "server in")
+
+			if !ok {
+				continue
+			}
+			crypto, code, closed := quicFrames(frames)
+
+			if closed {
+				if code >= QUIC_CRYPTO_ERROR && code < QUIC_CRYPTO_ERROR+0x100 {
+					info.setMetadata("alert", tlsAlert(byte(code-QUIC_CRYPTO_ERROR)))
+				} else if name, found := QUIC_ERRORS[code]; found {
+					info.setMetadata("error", name)
+				} else {
+					info.setMetadata("error", fmt.Sprintf("0x%x", code))
+				}
+			}
+			if suite, ok := tlsServerHello(crypto); ok {
+				info.setMetadata("cipher", tls.CipherSuiteName(suite))
+
+				if len(offered) == 1 {
+					info.setMetadata("alpn", printable(offered[0]))
+				}
+			}
+		}
+	}
+	return info, true
+}
+
+// MatchQUIC compares the destination connection ID of long header responses
+// with the source connection ID of the request, which servers address their
+// packets to
+func MatchQUIC(request []byte, response []byte) bool {
+
+	_, scid, ok := quicConnectionIDs(request)
+	dcid, _, found := quicConnectionIDs(response)
+
+	if !ok || !found {
+		return true
+	}
+	return bytes.Equal(scid, dcid)
+}
+
+// quicConnectionIDs returns the connection IDs of a long header packet
+func quicConnectionIDs(data []byte) (dcid []byte, scid []byte, ok bool) {
+
+	if len(data) < 7 || data[0]&0x80 == 0 {
+		return nil, nil, false
+	}
+	offset := 6 + int(data[5])
+
+	if offset >= len(data) || offset+1+int(data[offset]) > len(data) {
+		return nil, nil, false
+	}
+	return data[6:offset], data[offset+1 : offset+1+int(data[offset])], true
+}
+
+// readQUIC reads the long header packets coalesced in a datagram. Version
+// negotiation and retry packets take up the rest of the datagram.
+func readQUIC(data []byte) (packets []quicPacket) {
+
+	for {
+		dcid, scid, ok := quicConnectionIDs(data)
+
+		if !ok {
+			break
+		}
+		packet := quicPacket{version: binary.BigEndian.Uint32(data[1:5]), dcid: dcid, scid: scid, data: data}
+		offset := 7 + len(dcid) + len(scid)
+
+		if packet.version == QUIC_VERSION_NEGOTIATION {
+			for versions := data[offset:]; len(versions) >= 4; versions = versions[4:] {
+				packet.versions = append(packet.versions, binary.BigEndian.Uint32(versions[0:4]))
+			}
+			return append(packets, packet)
+		}
+
+		// QUIC version 2 shifts the packet types by one
+		packet.kind = int(data[0]>>4) & 0x03
+
+		if packet.version == QUIC_VERSION_2 {
+			packet.kind = (packet.kind + 3) & 0x03
+		}
+		if packet.kind == QUIC_PACKET_RETRY {
+			packet.header = offset
+			return append(packets, packet)
+		}
+		// Initial packets carry a token ahead of their length
+		if packet.kind == QUIC_PACKET_INITIAL {
+			token, size, ok := readQUICVarint(data[offset:])
+
+			if !ok || uint64(len(data)-offset-size) < token {
+				break
+			}
+			offset += size + int(token)
+		}
+		length, size, ok := readQUICVarint(data[offset:])
+
+		if !ok || uint64(len(data)-offset-size) < length {
+			break
+		}
+		packet.header = offset + size
+		packet.data = data[:packet.header+int(length)]
+		packets = append(packets, packet)
+		data = data[packet.header+int(length):]
+	}
+	return packets
+}
+
+// quicOpenInitial removes the header and packet protection of an Initial
+// packet, whose keys derive from the destination connection ID the client
+// chose for it, and returns its frames
+func quicOpenInitial(packet quicPacket, dcid []byte, label string) (frames []byte, ok bool) {
+
+	keys, found := QUIC_INITIAL_KEYS[packet.version]
+
+	if !found || packet.header+4+QUIC_SAMPLE_LENGTH > len(packet.data) {
+		return nil, false
+	}
+	secret := hkdfExpandLabel(hkdfExtract(keys.salt, dcid), label, 32)
+	key := hkdfExpandLabel(secret, keys.prefix+"key", 16)
+	iv := hkdfExpandLabel(secret, keys.prefix+"iv", 12)
+	hp := hkdfExpandLabel(secret, keys.prefix+"hp", 16)
+
+	protection, err := aes.NewCipher(hp)
+
+	if err != nil {
+		return nil, false
+	}
+	mask := make([]byte, aes.BlockSize)
+	protection.Encrypt(mask, packet.data[packet.header+4:packet.header+4+QUIC_SAMPLE_LENGTH])
+
+	header := append([]byte(nil), packet.data[:packet.header+4]...)
+	header[0] ^= mask[0] & 0x0f
+	length := int(header[0]&0x03) + 1
+	nonce := append([]byte(nil), iv...)
+
+	for i := 0; i < length; i++ {
+		header[packet.header+i] ^= mask[1+i]
+		nonce[len(nonce)-length+i] ^= header[packet.header+i]
+	}
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, false
+	}
+	aead, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, false
+	}
+	frames, err = aead.Open(nil, nonce, packet.data[packet.header+length:], header[:packet.header+length])
+
+	return frames, err == nil
+}
+
+// quicFrames returns the handshake messages of the CRYPTO frames of a packet
+// and the error code of its CONNECTION_CLOSE frame, if it has one. Frames that
+// cannot carry either end the packet as far as it is read.
+func quicFrames(frames []byte) (crypto []byte, code uint64, closed bool) {
+
+	for len(frames) > 0 {
+		kind := frames[0]
+		frames = frames[1:]
+		var fields []uint64
+
+		switch kind {
+		case QUIC_FRAME_PADDING, QUIC_FRAME_PING:
+			continue
+		case QUIC_FRAME_ACK, QUIC_FRAME_ACK_ECN:
+			// The largest acknowledged, the delay and the range count precede
+			// the first range and the rest
+			if fields, frames = readQUICVarints(frames, 4); len(fields) < 4 {
+				return
+			}
+			count := int(fields[2]) * 2
+
+			if kind == QUIC_FRAME_ACK_ECN {
+				count += 3
+			}
+			if fields, frames = readQUICVarints(frames, count); len(fields) < count {
+				return
+			}
+		case QUIC_FRAME_CRYPTO:
+			if fields, frames = readQUICVarints(frames, 2); len(fields) < 2 || fields[1] > uint64(len(frames)) {
+				return
+			}
+			offset, length := fields[0], fields[1]
+
+			if offset+length <= QUIC_HANDSHAKE_LIMIT {
+				if uint64(len(crypto)) < offset+length {
+					crypto = append(crypto, make([]byte, int(offset+length)-len(crypto))...)
+				}
+				copy(crypto[offset:], frames[:length])
+			}
+			frames = frames[length:]
+		case QUIC_FRAME_CONNECTION_CLOSE, QUIC_FRAME_CONNECTION_CLOSE_APP:
+			if fields, _ = readQUICVarints(frames, 1); len(fields) < 1 {
+				return
+			}
+			return crypto, fields[0], true
+		default:
+			return
+		}
+	}
+	return
+}
+
+// readQUICVarint reads a variable length integer, whose first two bits give
+// its length
+func readQUICVarint(data []byte) (value uint64, size int, ok bool) {
+
+	if len(data) == 0 {
+		return 0, 0, false
+	}
+	size = 1 << (data[0] >> 6)
+
+	if len(data) < size {
+		return 0, 0, false
+	}
+	value = uint64(data[0] & 0x3f)
+
+	for _, b := range data[1:size] {
+		value = value<<8 | uint64(b)
+	}
+	return value, size, true
+}
+
+// readQUICVarints reads up to count variable length integers
+func readQUICVarints(data []byte, count int) (values []uint64, rest []byte) {
+
+	for len(values) < count {
+		value, size, ok := readQUICVarint(data)
+
+		if !ok {
+			break
+		}
+		values = append(values, value)
+		data = data[size:]
+	}
+	return values, data
+}
+
+// quicVersion names a QUIC version, or returns an empty name for the reserved
+// versions servers announce to keep clients from relying on the list
+func quicVersion(version uint32) string {
+
+	if name, found := QUIC_VERSIONS[version]; found {
+		return name
+	}
+	if version&0x0f0f0f0f == 0x0a0a0a0a {
+		return ""
+	}
+	if version>>8 == 0xff0000 {
+		return fmt.Sprintf("draft-%d", version&0xff)
+	}
+	return fmt.Sprintf("0x%08x", version)
+}
+
+// tlsMessage returns the body of the first handshake message of the given type
+func tlsMessage(messages []byte, kind byte) (body []byte, ok bool) {
+
+	for len(messages) >= 4 {
+		length := int(messages[1])<<16 | int(messages[2])<<8 | int(messages[3])
+
+		if 4+length > len(messages) {
+			break
+		}
+		if messages[0] == kind {
+			return messages[4 : 4+length], true
+		}
+		messages = messages[4+length:]
+	}
+	return nil, false
+}
+
+// tlsServerHello returns the cipher suite of a ServerHello
+func tlsServerHello(messages []byte) (suite uint16, ok bool) {
+
+	body, ok := tlsMessage(messages, TLS_HANDSHAKE_SERVER_HELLO)
+
+	// The version and random precede the session ID and cipher suite
+	if !ok || len(body) < 35 || len(body) < 35+int(body[34])+2 {
+		return 0, false
+	}
+	offset := 35 + int(body[34])
+
+	return binary.BigEndian.Uint16(body[offset : offset+2]), true
+}
+
+// tlsALPN returns the application protocols a ClientHello offers
+func tlsALPN(messages []byte) (protocols []string) {
+
+	body, ok := tlsMessage(messages, TLS_HANDSHAKE_CLIENT_HELLO)
+
+	if !ok || len(body) < 35 {
+		return nil
+	}
+	// Skip the version, random, session ID, cipher suites and compression
+	// methods
+	offset := 35 + int(body[34])
+
+	if offset+2 > len(body) {
+		return nil
+	}
+	offset += 2 + int(binary.BigEndian.Uint16(body[offset:offset+2]))
+
+	if offset+1 > len(body) {
+		return nil
+	}
+	offset += 1 + int(body[offset])
+
+	if offset+2 > len(body) {
+		return nil
+	}
+	extensions := body[offset+2:]
+
+	for len(extensions) >= 4 {
+		kind, length := binary.BigEndian.Uint16(extensions[0:2]), int(binary.BigEndian.Uint16(extensions[2:4]))
+
+		if 4+length > len(extensions) {
+			break
+		}
+		if kind == TLS_EXTENSION_ALPN && length >= 2 {
+			for list := extensions[6 : 4+length]; len(list) > 0 && 1+int(list[0]) <= len(list); list = list[1+int(list[0]):] {
+				protocols = append(protocols, string(list[1:1+int(list[0])]))
+			}
+		}
+		extensions = extensions[4+length:]
+	}
+	return protocols
+}
+
+// tlsAlert names a TLS alert description
+func tlsAlert(description byte) string {
+
+	if name, found := TLS_ALERTS[description]; found {
+		return name
+	}
+	return strconv.Itoa(int(description))
+}
+
+// hkdfExtract derives a pseudorandom key from a secret with HMAC-SHA256
+func hkdfExtract(salt []byte, secret []byte) []byte {
+
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(secret)
+
+	return mac.Sum(nil)
+}
+
+// hkdfExpandLabel derives a key of the given length from a secret, as TLS 1.3
+// does, with an empty context
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+
+	info := []byte{byte(length >> 8), byte(length), byte(len("tls13 " + label))}
+	info = append(info, "tls13 "+label...)
+	info = append(info, 0)
+
+	var output, block []byte
+
+	for counter := byte(1); len(output) < length; counter++ {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(block)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+		block = mac.Sum(nil)
+		output = append(output, block...)
+	}
+	return output[:length]
+}
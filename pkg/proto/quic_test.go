@@ -0,0 +1,180 @@
+package proto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// quicInitial builds an Initial packet of QUIC version 1, protected with the
+// keys derived from the connection ID the client chose
+func quicInitial(client []byte, label string, dcid []byte, scid []byte, frames []byte) []byte {
+
+	// Header protection samples 16 bytes from 4 bytes past the packet number
+	for len(frames) < 4 {
+		frames = append(frames, QUIC_FRAME_PADDING)
+	}
+	keys := QUIC_INITIAL_KEYS[QUIC_VERSION_1]
+	secret := hkdfExpandLabel(hkdfExtract(keys.salt, client), label, 32)
+
+	packet := []byte{0xc0, 0, 0, 0, 1, byte(len(dcid))}
+	packet = append(packet, dcid...)
+	packet = append(packet, byte(len(scid)))
+	packet = append(packet, scid...)
+	packet = append(packet, 0, 0, 0)
+	binary.BigEndian.PutUint16(packet[len(packet)-2:], 0x4000|uint16(1+len(frames)+16))
+	header := len(packet)
+	packet = append(packet, 0)
+
+	block, _ := aes.NewCipher(hkdfExpandLabel(secret, keys.prefix+"key", 16))
+	aead, _ := cipher.NewGCM(block)
+	packet = aead.Seal(packet, hkdfExpandLabel(secret, keys.prefix+"iv", 12), frames, packet)
+
+	protection, _ := aes.NewCipher(hkdfExpandLabel(secret, keys.prefix+"hp", 16))
+	mask := make([]byte, aes.BlockSize)
+	protection.Encrypt(mask, packet[header+4:header+4+QUIC_SAMPLE_LENGTH])
+	packet[0] ^= mask[0] & 0x0f
+	packet[header] ^= mask[1]
+
+	return packet
+}
+
+// quicCrypto builds a CRYPTO frame holding a handshake message
+func quicCrypto(kind byte, body []byte) []byte {
+
+	frame := []byte{QUIC_FRAME_CRYPTO, 0, 0x40, 0, kind, 0, 0, 0}
+	binary.BigEndian.PutUint16(frame[2:4], 0x4000|uint16(4+len(body)))
+	putUint24(frame[5:8], len(body))
+
+	return append(frame, body...)
+}
+
+// quicClientHello builds a ClientHello offering the given application
+// protocols
+func quicClientHello(protocols ...string) []byte {
+
+	var list []byte
+
+	for _, protocol := range protocols {
+		list = append(list, byte(len(protocol)))
+		list = append(list, protocol...)
+	}
+	alpn := []byte{0, TLS_EXTENSION_ALPN, 0, byte(2 + len(list)), 0, byte(len(list))}
+	alpn = append(alpn, list...)
+
+	body := append([]byte{0x03, 0x03}, make([]byte, 32)...)
+	body = append(body, 0, 0, 2, 0x13, 0x01, 1, 0, 0, byte(len(alpn)))
+
+	return append(body, alpn...)
+}
+
+func TestParseQUIC(t *testing.T) {
+
+	client, server := []byte("udpzdcid"), []byte("serverid")
+	scid := []byte("udpzscid")
+	request := quicInitial(client, "client in", client, scid, quicCrypto(TLS_HANDSHAKE_CLIENT_HELLO, quicClientHello("h3")))
+	unsure := quicInitial(client, "client in", client, scid, quicCrypto(TLS_HANDSHAKE_CLIENT_HELLO, quicClientHello("h3", "h3-29")))
+
+	serverHello := append([]byte{0x03, 0x03}, make([]byte, 32)...)
+	serverHello = append(serverHello, 0, 0x13, 0x01, 0, 0, 0)
+
+	// An acknowledgment precedes the ServerHello, and a Handshake packet
+	// protected with other keys follows it
+	accepted := quicInitial(client, "server in", scid, server, append([]byte{QUIC_FRAME_ACK, 0, 0, 0, 0}, quicCrypto(TLS_HANDSHAKE_SERVER_HELLO, serverHello)...))
+	accepted = append(accepted, 0xe0, 0, 0, 0, 1, 8, 'u', 'd', 'p', 'z', 's', 'c', 'i', 'd', 8, 's', 'e', 'r', 'v', 'e', 'r', 'i', 'd', 0x04, 1, 2, 3, 4)
+
+	// A CONNECTION_CLOSE of transport error 0x178 is the TLS alert
+	// no_application_protocol
+	rejected := quicInitial(client, "server in", scid, server, []byte{QUIC_FRAME_CONNECTION_CLOSE, 0x41, 0x78, 0x06, 0})
+	refused := quicInitial(client, "server in", scid, server, []byte{QUIC_FRAME_CONNECTION_CLOSE, 0x02, 0x00, 0})
+
+	negotiation := []byte{0x80, 0, 0, 0, 0, 8, 'u', 'd', 'p', 'z', 's', 'c', 'i', 'd', 8, 's', 'e', 'r', 'v', 'e', 'r', 'i', 'd',
+		0, 0, 0, 1, 0x6b, 0x33, 0x43, 0xcf, 0xff, 0, 0, 0x1d, 0x3a, 0x4a, 0x5a, 0x6a}
+	misdirected := append([]byte(nil), negotiation...)
+	misdirected[6] = 'x'
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "version negotiation",
+			request:  request,
+			response: negotiation,
+			info:     Info{Metadata: map[string]string{"quic_versions": "1,2,draft-29"}},
+			ok:       true,
+		},
+		{
+			name:     "version negotiation for another client",
+			request:  request,
+			response: misdirected,
+			ok:       false,
+		},
+		{
+			name:     "server hello",
+			request:  request,
+			response: accepted,
+			info:     Info{Metadata: map[string]string{"quic_version": "1", "cipher": "TLS_AES_128_GCM_SHA256", "alpn": "h3"}},
+			ok:       true,
+		},
+		{
+			name:     "server hello to several protocols",
+			request:  unsure,
+			response: accepted,
+			info:     Info{Metadata: map[string]string{"quic_version": "1", "cipher": "TLS_AES_128_GCM_SHA256"}},
+			ok:       true,
+		},
+		{
+			name:     "protocol rejected",
+			request:  request,
+			response: rejected,
+			info:     Info{Metadata: map[string]string{"quic_version": "1", "alert": "no_application_protocol"}},
+			ok:       true,
+		},
+		{
+			name:     "connection refused",
+			request:  request,
+			response: refused,
+			info:     Info{Metadata: map[string]string{"quic_version": "1", "error": "CONNECTION_REFUSED"}},
+			ok:       true,
+		},
+		{
+			name:     "echoed request",
+			request:  request,
+			response: request,
+			ok:       false,
+		},
+		{
+			name:     "short header",
+			request:  request,
+			response: []byte{0x40, 1, 2, 3, 4, 5, 6, 7, 8},
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseQUIC(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
+
+func TestHKDFExpandLabel(t *testing.T) {
+
+	// The client Initial secret of the example in RFC 9001 appendix A.1
+	keys := QUIC_INITIAL_KEYS[QUIC_VERSION_1]
+	secret := hkdfExpandLabel(hkdfExtract(keys.salt, []byte("\x83\x94\xc8\xf0\x3e\x51\x57\x08")), "client in", 32)
+	want := "c00cf151ca5be075ed0ebfb5c80323c42d6b7db67881289af4008f1f6c357aea"
+
+	if got := hex.EncodeToString(secret); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
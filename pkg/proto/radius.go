@@ -0,0 +1,147 @@
+package proto
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+const (
+	RADIUS_HEADER_LENGTH = 20
+
+	RADIUS_ATTRIBUTE_REPLY_MESSAGE   = 18
+	RADIUS_ATTRIBUTE_VENDOR_SPECIFIC = 26
+	RADIUS_ATTRIBUTE_EAP_MESSAGE     = 79
+)
+
+var (
+	// RADIUS_CODES maps the codes of RADIUS responses to their names
+	RADIUS_CODES = map[byte]string{
+		2:  "Access-Accept",
+		3:  "Access-Reject",
+		5:  "Accounting-Response",
+		11: "Access-Challenge",
+	}
+	// RADIUS_VENDORS maps the enterprise numbers of common vendors of RADIUS
+	// servers and network equipment to their names
+	RADIUS_VENDORS = map[uint32]string{
+		9:     "Cisco",
+		311:   "Microsoft",
+		2011:  "Huawei",
+		2636:  "Juniper",
+		3076:  "Cisco VPN 3000",
+		4874:  "Juniper ERX",
+		11344: "FreeRADIUS",
+		12356: "Fortinet",
+		14122: "WISPr",
+		14823: "Aruba",
+		25506: "H3C",
+		25622: "Palo Alto Networks",
+	}
+	// EAP_METHODS maps the EAP methods servers challenge clients with to
+	// their names
+	EAP_METHODS = map[byte]string{
+		1:  "Identity",
+		4:  "MD5-Challenge",
+		6:  "GTC",
+		13: "EAP-TLS",
+		21: "EAP-TTLS",
+		25: "PEAP",
+		26: "MSCHAPv2",
+		43: "EAP-FAST",
+	}
+)
+
+// ParseRADIUS reports the kind of answer of RADIUS servers, their reply
+// message, the EAP method they challenge clients with, and their
+// vendor-specific attributes, whose vendors fingerprint the server. Text
+// values of vendor-specific attributes are kept, such as Cisco AV pairs.
+func ParseRADIUS(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < RADIUS_HEADER_LENGTH || len(request) < RADIUS_HEADER_LENGTH || response[1] != request[1] {
+		return
+	}
+	code, found := RADIUS_CODES[response[0]]
+	length := int(binary.BigEndian.Uint16(response[2:4]))
+
+	if !found || length < RADIUS_HEADER_LENGTH || length > len(response) {
+		return
+	}
+	info.setMetadata("response", code)
+
+	var vendors, attributes []string
+	seen := make(map[string]bool)
+
+	for data := response[RADIUS_HEADER_LENGTH:length]; len(data) >= 2; {
+		kind, size := data[0], int(data[1])
+
+		if size < 2 || size > len(data) {
+			break
+		}
+		value := data[2:size]
+		data = data[size:]
+
+		switch kind {
+		case RADIUS_ATTRIBUTE_REPLY_MESSAGE:
+			info.setMetadata("reply_message", printable(string(value)))
+
+		case RADIUS_ATTRIBUTE_EAP_MESSAGE:
+			// A request of the server names the method in its type
+			if len(value) >= 5 && value[0] == 1 {
+				info.setMetadata("eap_method", eapMethod(value[4]))
+			}
+
+		case RADIUS_ATTRIBUTE_VENDOR_SPECIFIC:
+			if len(value) < 4 {
+				continue
+			}
+			vendor := radiusVendor(binary.BigEndian.Uint32(value[0:4]))
+
+			if !seen[vendor] {
+				seen[vendor] = true
+				vendors = append(vendors, vendor)
+			}
+			// Most vendors follow the type and length layout of attributes
+			if len(value) >= 6 && int(value[5]) == len(value)-4 {
+				attribute := vendor + ":" + strconv.Itoa(int(value[4]))
+
+				if text := string(value[6:]); text != "" && printable(text) == text {
+					attribute += "=" + text
+				}
+				attributes = append(attributes, attribute)
+			}
+		}
+	}
+	info.setMetadata("vendors", strings.Join(vendors, ","))
+	info.setMetadata("vendor_attributes", strings.Join(attributes, ","))
+	info.Banner = info.Metadata["reply_message"]
+
+	return info, true
+}
+
+// MatchRADIUS compares the identifiers of RADIUS requests and responses
+func MatchRADIUS(request []byte, response []byte) bool {
+
+	if len(request) < 2 || len(response) < 2 {
+		return true
+	}
+	return request[1] == response[1]
+}
+
+// radiusVendor names a vendor by its enterprise number
+func radiusVendor(vendor uint32) string {
+
+	if name, found := RADIUS_VENDORS[vendor]; found {
+		return name
+	}
+	return strconv.FormatUint(uint64(vendor), 10)
+}
+
+// eapMethod names an EAP method
+func eapMethod(method byte) string {
+
+	if name, found := EAP_METHODS[method]; found {
+		return name
+	}
+	return strconv.Itoa(int(method))
+}
@@ -0,0 +1,78 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRADIUS(t *testing.T) {
+
+	request := append([]byte{1, 0x75, 0, 20}, "udpz radius auth"...)
+
+	// An Access-Reject with a reply message, a Cisco AV pair and a Microsoft
+	// attribute holding binary data
+	reject := append([]byte{3, 0x75, 0, 0}, make([]byte, 16)...)
+	reject = append(reject, 18, 9, 'D', 'e', 'n', 'i', 'e', 'd', '.')
+	reject = append(reject, 26, 21, 0, 0, 0, 9, 1, 15, 's', 'h', 'e', 'l', 'l', ':', 'p', 'r', 'i', 'v', '=', '1', '5')
+	reject = append(reject, 26, 10, 0, 0, 1, 0x37, 7, 4, 0x00, 0xff)
+	reject[3] = byte(len(reject))
+
+	// An Access-Challenge starting a PEAP conversation
+	challenge := append([]byte{11, 0x75, 0, 0}, make([]byte, 16)...)
+	challenge = append(challenge, 79, 8, 1, 2, 0, 6, 25, 0x20)
+	challenge[3] = byte(len(challenge))
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "access reject",
+			request:  request,
+			response: reject,
+			info: Info{Banner: "Denied.", Metadata: map[string]string{
+				"response":          "Access-Reject",
+				"reply_message":     "Denied.",
+				"vendors":           "Cisco,Microsoft",
+				"vendor_attributes": "Cisco:1=shell:priv=15,Microsoft:7",
+			}},
+			ok: true,
+		},
+		{
+			name:     "access challenge",
+			request:  request,
+			response: challenge,
+			info:     Info{Metadata: map[string]string{"response": "Access-Challenge", "eap_method": "PEAP"}},
+			ok:       true,
+		},
+		{
+			name:     "other identifier",
+			request:  request,
+			response: append([]byte{3, 0x76, 0, 20}, make([]byte, 16)...),
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			request:  request,
+			response: request,
+			ok:       false,
+		},
+		{
+			name:     "truncated",
+			request:  request,
+			response: reject[:30],
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseRADIUS(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
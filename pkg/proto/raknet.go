@@ -0,0 +1,65 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+const (
+	RAKNET_UNCONNECTED_PING = 0x01
+	RAKNET_UNCONNECTED_PONG = 0x1c
+	RAKNET_PONG_HEADER      = 35
+)
+
+var (
+	// RAKNET_MAGIC identifies the offline messages of RakNet
+	RAKNET_MAGIC = []byte{0x00, 0xff, 0xff, 0x00, 0xfe, 0xfe, 0xfe, 0xfe, 0xfd, 0xfd, 0xfd, 0xfd, 0x12, 0x34, 0x56, 0x78}
+	// MINECRAFT_EDITIONS maps the editions of Minecraft servers advertise to
+	// their names
+	MINECRAFT_EDITIONS = map[string]string{
+		"MCPE": "Bedrock",
+		"MCEE": "Education",
+	}
+)
+
+// ParseRakNet reports the message of the day, version, player counts, world
+// and game mode that Minecraft Bedrock Edition servers advertise in their
+// RakNet unconnected pong, and the server ID of other RakNet servers
+func ParseRakNet(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < RAKNET_PONG_HEADER || response[0] != RAKNET_UNCONNECTED_PONG || !bytes.Equal(response[17:33], RAKNET_MAGIC) {
+		return
+	}
+	// Pongs echo the time of the ping
+	if len(request) >= 9 && request[0] == RAKNET_UNCONNECTED_PING && !bytes.Equal(request[1:9], response[1:9]) {
+		return
+	}
+	info.setMetadata("server_guid", strconv.FormatUint(binary.BigEndian.Uint64(response[9:17]), 10))
+
+	length := int(binary.BigEndian.Uint16(response[33:35]))
+
+	if RAKNET_PONG_HEADER+length > len(response) {
+		return info, true
+	}
+	// Minecraft servers give their details separated by semicolons
+	fields := strings.Split(string(response[RAKNET_PONG_HEADER:RAKNET_PONG_HEADER+length]), ";")
+
+	if edition, found := MINECRAFT_EDITIONS[fields[0]]; found && len(fields) >= 6 {
+		info.setMetadata("edition", edition)
+		info.Banner = printable(fields[1])
+		info.setMetadata("protocol", printable(fields[2]))
+		info.Version = printable(fields[3])
+		info.setMetadata("players", printable(fields[4]))
+		info.setMetadata("max_players", printable(fields[5]))
+
+		if len(fields) >= 9 {
+			info.setMetadata("world", printable(fields[7]))
+			info.setMetadata("game_mode", printable(fields[8]))
+		}
+	} else {
+		info.Banner = printable(strings.Join(fields, ";"))
+	}
+	return info, true
+}
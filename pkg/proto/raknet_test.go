@@ -0,0 +1,63 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+// raknetPong builds an unconnected pong echoing the time of the ping
+func raknetPong(time []byte, id string) []byte {
+
+	response := append([]byte{RAKNET_UNCONNECTED_PONG}, time...)
+	response = append(response, 0, 0, 0, 0, 0, 0, 0x30, 0x39)
+	response = append(response, RAKNET_MAGIC...)
+	response = append(response, byte(len(id)>>8), byte(len(id)))
+	return append(response, id...)
+}
+
+func TestParseRakNet(t *testing.T) {
+
+	time := []byte("udpz\x00\x00\x00\x01")
+	request := append(append([]byte{RAKNET_UNCONNECTED_PING}, time...), RAKNET_MAGIC...)
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "bedrock",
+			response: raknetPong(time, "MCPE;§audpz test;766;1.21.50;3;10;13253860892328930865;Bedrock level;Survival;1;19132;19133;"),
+			info: Info{Version: "1.21.50", Banner: "§audpz test", Metadata: map[string]string{
+				"server_guid": "12345", "edition": "Bedrock", "protocol": "766", "players": "3", "max_players": "10",
+				"world": "Bedrock level", "game_mode": "Survival",
+			}},
+			ok: true,
+		},
+		{
+			name:     "other server",
+			response: raknetPong(time, "udpz"),
+			info:     Info{Banner: "udpz", Metadata: map[string]string{"server_guid": "12345"}},
+			ok:       true,
+		},
+		{
+			name:     "other ping",
+			response: raknetPong([]byte("otherpng"), "udpz"),
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			response: request,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseRakNet(request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CHARGEN_MIN_LINE = 10
+	DAYTIME_MAX      = 256
+	TIME_LENGTH      = 4
+	TIME_EPOCH       = 2208988800 // Seconds from 1900 to 1970
+)
+
+// The simple services of old Unix and Windows hosts answer any datagram, and
+// attackers spoofing the address of a victim use them to reflect traffic at it,
+// or at another simple service to make them answer each other endlessly. So
+// every response is flagged for DDoS reflection with its amplification.
+
+// ParseEcho accepts the request sent back unchanged by echo servers
+func ParseEcho(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(request) == 0 || !bytes.Equal(request, response) {
+		return
+	}
+	setReflection(&info, "echo", request, response)
+
+	return info, true
+}
+
+// ParseCharGen accepts the lines of characters chargen servers answer with,
+// each one following the previous in the printable ASCII characters
+func ParseCharGen(request []byte, response []byte) (info Info, ok bool) {
+
+	line, _, _ := bytes.Cut(response, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+
+	if len(line) < CHARGEN_MIN_LINE {
+		return
+	}
+	for i, c := range line {
+		if c < ' ' || c > '~' || (i > 0 && c != line[i-1]+1 && !(line[i-1] == '~' && c == ' ')) {
+			return
+		}
+	}
+	setReflection(&info, "chargen", request, response)
+
+	return info, true
+}
+
+// ParseQOTD reports the quote of the day as the banner
+func ParseQOTD(request []byte, response []byte) (info Info, ok bool) {
+
+	if bytes.Equal(request, response) {
+		return
+	}
+	if info.Banner = printable(string(response)); info.Banner == "" {
+		return
+	}
+	setReflection(&info, "qotd", request, response)
+
+	return info, true
+}
+
+// ParseDaytime reports the date and time daytime servers answer with as the
+// banner. Its format is up to each server.
+func ParseDaytime(request []byte, response []byte) (info Info, ok bool) {
+
+	text := printable(string(response))
+
+	if len(response) > DAYTIME_MAX || bytes.Equal(request, response) || !strings.ContainsAny(text, "0123456789") {
+		return
+	}
+	info.Banner = text
+	setReflection(&info, "daytime", request, response)
+
+	return info, true
+}
+
+// ParseTime reports the time time servers answer with, as the number of
+// seconds since 1900. The count wraps around in 2036, so earlier times are
+// taken to be after then.
+func ParseTime(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) != TIME_LENGTH || bytes.Equal(request, response) {
+		return
+	}
+	seconds := int64(binary.BigEndian.Uint32(response)) - TIME_EPOCH
+
+	if seconds < 0 {
+		seconds += 1 << 32
+	}
+	info.setMetadata("time", time.Unix(seconds, 0).UTC().Format(time.RFC3339))
+	setReflection(&info, "time", request, response)
+
+	return info, true
+}
+
+// setReflection flags the response of a simple service for DDoS reflection
+// with its amplification
+func setReflection(info *Info, service string, request []byte, response []byte) {
+
+	info.setMetadata("ddos_reflection", service)
+
+	if factor := amplification(request, response); factor > 0 {
+		info.setMetadata("amplification", strconv.FormatFloat(factor, 'f', 1, 64))
+	}
+}
@@ -0,0 +1,105 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSimple(t *testing.T) {
+
+	request := []byte{0x01}
+
+	tests := []struct {
+		name     string
+		parser   Parser
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "echo",
+			parser:   ParseEcho,
+			request:  []byte("udpz"),
+			response: []byte("udpz"),
+			info:     Info{Metadata: map[string]string{"ddos_reflection": "echo", "amplification": "1.0"}},
+			ok:       true,
+		},
+		{
+			name:     "echo of something else",
+			parser:   ParseEcho,
+			request:  []byte("udpz"),
+			response: []byte("zpdu"),
+			ok:       false,
+		},
+		{
+			name:     "chargen",
+			parser:   ParseCharGen,
+			request:  request,
+			response: []byte("xyz{|}~ !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`ab\r\nyz{|}~ !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abc\r\n"),
+			info:     Info{Metadata: map[string]string{"ddos_reflection": "chargen", "amplification": "152.0"}},
+			ok:       true,
+		},
+		{
+			name:     "chargen out of order",
+			parser:   ParseCharGen,
+			request:  request,
+			response: []byte("HTTP/1.1 400 Bad Request\r\n"),
+			ok:       false,
+		},
+		{
+			name:     "qotd",
+			parser:   ParseQOTD,
+			request:  request,
+			response: []byte("\"Nothing is more dangerous than an idea.\"\r\n Emile Chartier (1868-1951)\r\n"),
+			info:     Info{Banner: "\"Nothing is more dangerous than an idea.\"  Emile Chartier (1868-1951)", Metadata: map[string]string{"ddos_reflection": "qotd", "amplification": "72.0"}},
+			ok:       true,
+		},
+		{
+			name:     "qotd echo",
+			parser:   ParseQOTD,
+			request:  request,
+			response: request,
+			ok:       false,
+		},
+		{
+			name:     "daytime",
+			parser:   ParseDaytime,
+			request:  request,
+			response: []byte("Friday, October 16, 2026 12:31:07-UTC\n"),
+			info:     Info{Banner: "Friday, October 16, 2026 12:31:07-UTC", Metadata: map[string]string{"ddos_reflection": "daytime", "amplification": "38.0"}},
+			ok:       true,
+		},
+		{
+			name:     "time",
+			parser:   ParseTime,
+			request:  request,
+			response: []byte{0xed, 0x00, 0x37, 0x80},
+			info:     Info{Metadata: map[string]string{"time": "2026-01-01T00:00:00Z", "ddos_reflection": "time", "amplification": "4.0"}},
+			ok:       true,
+		},
+		{
+			name:     "time after 2036",
+			parser:   ParseTime,
+			request:  request,
+			response: []byte{0x00, 0x00, 0x00, 0x00},
+			info:     Info{Metadata: map[string]string{"time": "2036-02-07T06:28:16Z", "ddos_reflection": "time", "amplification": "4.0"}},
+			ok:       true,
+		},
+		{
+			name:     "time too long",
+			parser:   ParseTime,
+			request:  request,
+			response: []byte("udpz udpz"),
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := test.parser(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package proto
+
+import (
+	"strings"
+)
+
+var (
+	// SIP_HEADERS are the SIP response headers kept as metadata, by their full
+	// and compact names. The methods and extensions are listed without spaces.
+	SIP_HEADERS = map[string]string{
+		"allow":     "allow",
+		"supported": "supported",
+		"k":         "supported",
+		"accept":    "accept",
+	}
+)
+
+// ParseSIP extracts the Server or User-Agent header as the banner, and the
+// status, allowed methods and supported extensions from SIP responses, which
+// fingerprint PBXs, proxies and phones
+func ParseSIP(request []byte, response []byte) (info Info, ok bool) {
+
+	lines := strings.Split(string(response), "\n")
+	status := strings.TrimSpace(lines[0])
+
+	if !strings.HasPrefix(status, "SIP/2.0 ") {
+		return
+	}
+	info.setMetadata("status", printable(strings.TrimPrefix(status, "SIP/2.0 ")))
+
+	var agent string
+
+	for _, line := range lines[1:] {
+		// The headers end at the first empty line
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+
+		if !found {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = printable(value)
+
+		switch name {
+		case "server":
+			info.Banner = value
+		case "user-agent":
+			agent = value
+		default:
+			if key, ok := SIP_HEADERS[name]; ok {
+				info.setMetadata(key, strings.ReplaceAll(value, " ", ""))
+			}
+		}
+	}
+	if info.Banner == "" {
+		info.Banner = agent
+	}
+	return info, true
+}
+
+// MatchSIP compares the Call-ID of SIP requests and responses
+func MatchSIP(request []byte, response []byte) bool {
+
+	requested, responded := sipCallID(request), sipCallID(response)
+
+	if requested == "" || responded == "" {
+		return true
+	}
+	return requested == responded
+}
+
+// sipCallID returns the Call-ID header of a SIP message, by its full or
+// compact name
+func sipCallID(message []byte) string {
+
+	for _, line := range strings.Split(string(message), "\n") {
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+
+		if !found {
+			continue
+		}
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "call-id" || name == "i" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
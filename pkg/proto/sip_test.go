@@ -0,0 +1,56 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSIP(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		response string
+		info     Info
+		ok       bool
+	}{
+		{
+			name: "options response",
+			response: "SIP/2.0 200 OK\r\nVia: SIP/2.0/UDP udpz.invalid;branch=z9hG4bK-udpz-options;rport=5060\r\n" +
+				"Call-ID: udpz-options-0001@udpz.invalid\r\nCSeq: 42 OPTIONS\r\nServer: Asterisk PBX 18.10.0\r\n" +
+				"Allow: INVITE, ACK, CANCEL, OPTIONS, BYE, REFER, SUBSCRIBE, NOTIFY, INFO, PUBLISH, MESSAGE\r\n" +
+				"Supported: replaces, timer\r\nAccept: application/sdp\r\nContent-Length: 0\r\n\r\n",
+			info: Info{
+				Banner: "Asterisk PBX 18.10.0",
+				Metadata: map[string]string{
+					"status":    "200 OK",
+					"allow":     "INVITE,ACK,CANCEL,OPTIONS,BYE,REFER,SUBSCRIBE,NOTIFY,INFO,PUBLISH,MESSAGE",
+					"supported": "replaces,timer",
+					"accept":    "application/sdp",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "phone with compact headers",
+			response: "SIP/2.0 404 Not Found\r\ni: udpz-options-0001@udpz.invalid\r\nUser-Agent: Yealink SIP-T46S 66.86.0.15\r\nk: path\r\n\r\nServer: body\r\n",
+			info: Info{
+				Banner:   "Yealink SIP-T46S 66.86.0.15",
+				Metadata: map[string]string{"status": "404 Not Found", "supported": "path"},
+			},
+			ok: true,
+		},
+		{
+			name:     "echoed request",
+			response: "OPTIONS sip:nm SIP/2.0\r\nCall-ID: udpz-options-0001@udpz.invalid\r\n\r\n",
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseSIP(nil, []byte(test.response))
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
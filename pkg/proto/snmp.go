@@ -0,0 +1,353 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	BER_INTEGER      = 0x02
+	BER_OCTET_STRING = 0x04
+	BER_OID          = 0x06
+	BER_SEQUENCE     = 0x30
+
+	SNMP_GET_RESPONSE = 0xa2
+	SNMP_INFORM       = 0xa6
+	SNMP_REPORT       = 0xa8
+)
+
+var (
+	// SNMP_OIDS maps the MIB-2 system objects of interest to metadata keys
+	SNMP_OIDS = map[string]string{
+		"1.3.6.1.2.1.1.1.0": "sysDescr",
+		"1.3.6.1.2.1.1.2.0": "sysObjectID",
+		"1.3.6.1.2.1.1.4.0": "sysContact",
+		"1.3.6.1.2.1.1.5.0": "sysName",
+		"1.3.6.1.2.1.1.6.0": "sysLocation",
+	}
+	// SNMP_REPORTS maps the USM statistics that SNMPv3 report PDUs carry to
+	// metadata values
+	SNMP_REPORTS = map[string]string{
+		"1.3.6.1.6.3.15.1.1.1.0": "unsupported_security_level",
+		"1.3.6.1.6.3.15.1.1.2.0": "not_in_time_window",
+		"1.3.6.1.6.3.15.1.1.3.0": "unknown_user_name",
+		"1.3.6.1.6.3.15.1.1.4.0": "unknown_engine_id",
+		"1.3.6.1.6.3.15.1.1.5.0": "wrong_digest",
+		"1.3.6.1.6.3.15.1.1.6.0": "decryption_error",
+	}
+	// SNMP_ENTERPRISES maps the private enterprise numbers most often found in
+	// engine IDs to vendor names
+	SNMP_ENTERPRISES = map[int]string{
+		9:     "Cisco",
+		11:    "Hewlett-Packard",
+		311:   "Microsoft",
+		1991:  "Brocade",
+		2011:  "Huawei",
+		2021:  "UC Davis (ucd-snmp)",
+		2636:  "Juniper",
+		4526:  "Netgear",
+		6876:  "VMware",
+		8072:  "net-snmp",
+		12356: "Fortinet",
+		14988: "MikroTik",
+		25461: "Palo Alto Networks",
+		30065: "Arista",
+	}
+	SNMP_VERSIONS = map[int]string{
+		0: "1",
+		1: "2c",
+		3: "3",
+	}
+)
+
+// readBER reads a single BER encoded element, returning its tag, contents and
+// the data following it
+func readBER(data []byte) (tag byte, value []byte, rest []byte, ok bool) {
+
+	if len(data) < 2 {
+		return
+	}
+	tag = data[0]
+	length := int(data[1])
+	offset := 2
+
+	if length&0x80 != 0 {
+		count := length & 0x7f
+
+		if count == 0 || count > 4 || len(data) < offset+count {
+			return
+		}
+		length = 0
+
+		for _, b := range data[offset : offset+count] {
+			length = length<<8 | int(b)
+		}
+		offset += count
+	}
+	if length < 0 || len(data) < offset+length {
+		return
+	}
+	return tag, data[offset : offset+length], data[offset+length:], true
+}
+
+// berInteger decodes the contents of a BER integer
+func berInteger(value []byte) (integer int) {
+
+	if len(value) > 0 && value[0]&0x80 != 0 {
+		integer = -1
+	}
+	for _, b := range value {
+		integer = integer<<8 | int(b)
+	}
+	return
+}
+
+// berOID decodes the contents of a BER object identifier into dotted form
+func berOID(value []byte) string {
+
+	if len(value) == 0 {
+		return ""
+	}
+	parts := []string{
+		strconv.Itoa(int(value[0]) / 40),
+		strconv.Itoa(int(value[0]) % 40),
+	}
+	component := 0
+
+	for _, b := range value[1:] {
+		component = component<<7 | int(b&0x7f)
+
+		if b&0x80 == 0 {
+			parts = append(parts, strconv.Itoa(component))
+			component = 0
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// ParseSNMP extracts system information from SNMPv1 and SNMPv2c responses, and
+// the authoritative engine details and errors that SNMPv3 discovery reports
+// reveal
+func ParseSNMP(request []byte, response []byte) (info Info, ok bool) {
+
+	var tag byte
+	var message, value []byte
+
+	if tag, message, _, ok = readBER(response); !ok || tag != BER_SEQUENCE {
+		return Info{}, false
+	}
+	if tag, value, message, ok = readBER(message); !ok || tag != BER_INTEGER {
+		return Info{}, false
+	}
+	version := berInteger(value)
+	info.setMetadata("snmp_version", SNMP_VERSIONS[version])
+
+	if version == 3 {
+		return parseSNMPv3(message, info), true
+	}
+
+	if tag, value, message, ok = readBER(message); !ok || tag != BER_OCTET_STRING {
+		return Info{}, false
+	}
+	info.setMetadata("community", printable(string(value)))
+
+	if tag, message, _, ok = readBER(message); !ok || tag != SNMP_GET_RESPONSE {
+		return info, true
+	}
+
+	// Skip the request ID, error status and error index
+	for i := 0; i < 3; i++ {
+		if _, _, message, ok = readBER(message); !ok {
+			return info, true
+		}
+	}
+	if tag, message, _, ok = readBER(message); !ok || tag != BER_SEQUENCE {
+		return info, true
+	}
+
+	for len(message) > 0 {
+		var binding, oid []byte
+
+		if tag, binding, message, ok = readBER(message); !ok || tag != BER_SEQUENCE {
+			break
+		}
+		if tag, oid, binding, ok = readBER(binding); !ok || tag != BER_OID {
+			continue
+		}
+		name, known := SNMP_OIDS[berOID(oid)]
+
+		if !known {
+			continue
+		}
+		if tag, value, _, ok = readBER(binding); !ok {
+			continue
+		}
+		switch tag {
+		case BER_OCTET_STRING:
+			info.setMetadata(name, printable(string(value)))
+		case BER_OID:
+			info.setMetadata(name, berOID(value))
+		}
+	}
+	info.Banner = info.Metadata["sysDescr"]
+	delete(info.Metadata, "sysDescr")
+
+	return info, true
+}
+
+// ParseSNMPTrap accepts the response SNMP notification receivers, such as
+// snmptrapd and network management systems, acknowledge an InformRequest with.
+// Receivers never answer traps, and only acknowledge informs whose community
+// they accept, so most stay silent.
+func ParseSNMPTrap(request []byte, response []byte) (info Info, ok bool) {
+
+	version, community, pdu, requestID, ok := snmpPDU(response)
+
+	if !ok || pdu != SNMP_GET_RESPONSE {
+		return Info{}, false
+	}
+	if _, _, _, sent, found := snmpPDU(request); found && !bytes.Equal(sent, requestID) {
+		return Info{}, false
+	}
+	info.setMetadata("snmp_version", SNMP_VERSIONS[version])
+	info.setMetadata("community", printable(community))
+	info.setMetadata("inform_acknowledged", "true")
+
+	return info, true
+}
+
+// snmpPDU reads the version and community of an SNMPv1 or SNMPv2c message, and
+// the type and request ID of its PDU
+func snmpPDU(message []byte) (version int, community string, pdu byte, requestID []byte, ok bool) {
+
+	var tag byte
+	var value []byte
+
+	if tag, message, _, ok = readBER(message); !ok || tag != BER_SEQUENCE {
+		return 0, "", 0, nil, false
+	}
+	if tag, value, message, ok = readBER(message); !ok || tag != BER_INTEGER {
+		return 0, "", 0, nil, false
+	}
+	version = berInteger(value)
+
+	if tag, value, message, ok = readBER(message); !ok || tag != BER_OCTET_STRING {
+		return 0, "", 0, nil, false
+	}
+	community = string(value)
+
+	if pdu, message, _, ok = readBER(message); !ok {
+		return 0, "", 0, nil, false
+	}
+	if tag, requestID, _, ok = readBER(message); !ok || tag != BER_INTEGER {
+		return 0, "", 0, nil, false
+	}
+	return version, community, pdu, requestID, true
+}
+
+// parseSNMPv3 reads the engine ID, boot count and uptime from the security
+// parameters of an SNMPv3 message, and the error a report PDU carries
+func parseSNMPv3(message []byte, info Info) Info {
+
+	var tag byte
+	var value, parameters []byte
+	var ok bool
+
+	// Skip the global header
+	if _, _, message, ok = readBER(message); !ok {
+		return info
+	}
+	if tag, value, message, ok = readBER(message); !ok || tag != BER_OCTET_STRING {
+		return info
+	}
+	if tag, parameters, _, ok = readBER(value); !ok || tag != BER_SEQUENCE {
+		return info
+	}
+	if tag, value, parameters, ok = readBER(parameters); !ok || tag != BER_OCTET_STRING {
+		return info
+	}
+	parseEngineID(value, &info)
+
+	if tag, value, parameters, ok = readBER(parameters); ok && tag == BER_INTEGER {
+		info.setMetadata("engine_boots", strconv.Itoa(berInteger(value)))
+	}
+	if tag, value, parameters, ok = readBER(parameters); ok && tag == BER_INTEGER {
+		info.setMetadata("engine_time", strconv.Itoa(berInteger(value)))
+	}
+	if tag, value, _, ok = readBER(parameters); ok && tag == BER_OCTET_STRING {
+		info.setMetadata("user", printable(string(value)))
+	}
+
+	// Reports are sent in plaintext scoped PDUs, holding the context engine
+	// ID, the context name and the PDU
+	if tag, message, _, ok = readBER(message); !ok || tag != BER_SEQUENCE {
+		return info
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, message, ok = readBER(message); !ok {
+			return info
+		}
+	}
+	if tag, message, _, ok = readBER(message); !ok || tag != SNMP_REPORT {
+		return info
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, message, ok = readBER(message); !ok {
+			return info
+		}
+	}
+	if tag, message, _, ok = readBER(message); !ok || tag != BER_SEQUENCE {
+		return info
+	}
+	if tag, message, _, ok = readBER(message); !ok || tag != BER_SEQUENCE {
+		return info
+	}
+	if tag, value, _, ok = readBER(message); ok && tag == BER_OID {
+		if report, known := SNMP_REPORTS[berOID(value)]; known {
+			info.setMetadata("report", report)
+		} else {
+			info.setMetadata("report", berOID(value))
+		}
+	}
+	return info
+}
+
+// parseEngineID records an SNMP engine ID, and the vendor and address RFC 3411
+// engine IDs embed
+func parseEngineID(engineID []byte, info *Info) {
+
+	info.setMetadata("engine_id", hex.EncodeToString(engineID))
+
+	if len(engineID) < 5 {
+		return
+	}
+	enterprise := (int(engineID[0])&0x7f)<<24 | int(engineID[1])<<16 | int(engineID[2])<<8 | int(engineID[3])
+	info.setMetadata("engine_enterprise", strconv.Itoa(enterprise))
+	info.setMetadata("engine_vendor", SNMP_ENTERPRISES[enterprise])
+
+	// Engine IDs with the high bit clear hold 8 bytes of vendor data
+	if engineID[0]&0x80 == 0 {
+		return
+	}
+	data := engineID[5:]
+
+	switch engineID[4] {
+	case 1:
+		if len(data) == net.IPv4len {
+			info.setMetadata("engine_ip", net.IP(data).String())
+		}
+	case 2:
+		if len(data) == net.IPv6len {
+			info.setMetadata("engine_ip", net.IP(data).String())
+		}
+	case 3:
+		if len(data) == 6 {
+			info.setMetadata("engine_mac", net.HardwareAddr(data).String())
+		}
+	case 4:
+		info.setMetadata("engine_text", printable(string(data)))
+	}
+}
@@ -0,0 +1,282 @@
+package proto
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// ber encodes an element with the given contents, using the long length form
+// when needed
+func ber(tag byte, contents ...[]byte) []byte {
+
+	value := bytes.Join(contents, nil)
+	length := len(value)
+
+	switch {
+	case length < 0x80:
+		return append([]byte{tag, byte(length)}, value...)
+	case length < 0x100:
+		return append([]byte{tag, 0x81, byte(length)}, value...)
+	default:
+		return append([]byte{tag, 0x82, byte(length >> 8), byte(length)}, value...)
+	}
+}
+
+func TestReadBER(t *testing.T) {
+
+	long := bytes.Repeat([]byte{'a'}, 300)
+
+	tests := []struct {
+		name  string
+		data  []byte
+		tag   byte
+		value []byte
+		rest  []byte
+		ok    bool
+	}{
+		{name: "short form", data: []byte{0x02, 0x01, 0x05, 0xff}, tag: 0x02, value: []byte{0x05}, rest: []byte{0xff}, ok: true},
+		{name: "long form", data: ber(0x04, long), tag: 0x04, value: long, rest: []byte{}, ok: true},
+		{name: "empty", data: []byte{0x05, 0x00}, tag: 0x05, value: []byte{}, rest: []byte{}, ok: true},
+		{name: "truncated header", data: []byte{0x02}},
+		{name: "truncated value", data: []byte{0x04, 0x05, 'a'}},
+		{name: "truncated length", data: []byte{0x04, 0x82, 0x01}},
+		{name: "indefinite length", data: []byte{0x30, 0x80, 0x00, 0x00}},
+		{name: "oversized length", data: []byte{0x04, 0x85, 0x01, 0x01, 0x01, 0x01, 0x01}},
+	}
+
+	for _, test := range tests {
+		tag, value, rest, ok := readBER(test.data)
+
+		if ok != test.ok {
+			t.Errorf("%s: ok = %v, want %v", test.name, ok, test.ok)
+			continue
+		}
+		if ok && (tag != test.tag || !bytes.Equal(value, test.value) || !bytes.Equal(rest, test.rest)) {
+			t.Errorf("%s: got %#x %q %q, want %#x %q %q", test.name, tag, value, rest, test.tag, test.value, test.rest)
+		}
+	}
+}
+
+func TestBERInteger(t *testing.T) {
+
+	tests := []struct {
+		value   []byte
+		integer int
+	}{
+		{value: []byte{}, integer: 0},
+		{value: []byte{0x00}, integer: 0},
+		{value: []byte{0x7f}, integer: 127},
+		{value: []byte{0x00, 0x80}, integer: 128},
+		{value: []byte{0x01, 0x00, 0x00}, integer: 65536},
+		{value: []byte{0xff}, integer: -1},
+		{value: []byte{0x80}, integer: -128},
+		{value: []byte{0xff, 0x7f}, integer: -129},
+	}
+
+	for _, test := range tests {
+		if integer := berInteger(test.value); integer != test.integer {
+			t.Errorf("berInteger(%#v) = %d, want %d", test.value, integer, test.integer)
+		}
+	}
+}
+
+func TestBEROID(t *testing.T) {
+
+	tests := []struct {
+		value []byte
+		oid   string
+	}{
+		{value: []byte{}, oid: ""},
+		{value: []byte{0x2b}, oid: "1.3"},
+		{value: []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00}, oid: "1.3.6.1.2.1.1.1.0"},
+		{value: []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0x89, 0x37}, oid: "1.3.6.1.4.1.1207"},
+	}
+
+	for _, test := range tests {
+		if oid := berOID(test.value); oid != test.oid {
+			t.Errorf("berOID(%#v) = %q, want %q", test.value, oid, test.oid)
+		}
+	}
+}
+
+func TestParseSNMP(t *testing.T) {
+
+	integer := func(value byte) []byte { return ber(BER_INTEGER, []byte{value}) }
+	binding := func(oid []byte, value []byte) []byte { return ber(BER_SEQUENCE, ber(BER_OID, oid), value) }
+
+	sysDescr := []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00}
+	sysObjectID := []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x02, 0x00}
+	sysName := []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x05, 0x00}
+	sysUpTime := []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03, 0x00}
+
+	response := func(version byte, pdu []byte) []byte {
+		return ber(BER_SEQUENCE, integer(version), ber(BER_OCTET_STRING, []byte("public")), pdu)
+	}
+	getResponse := func(bindings ...[]byte) []byte {
+		return ber(SNMP_GET_RESPONSE, integer(1), integer(0), integer(0), ber(BER_SEQUENCE, bindings...))
+	}
+
+	usmStatsUnknownEngineIDs := []byte{0x2b, 0x06, 0x01, 0x06, 0x03, 0x0f, 0x01, 0x01, 0x04, 0x00}
+
+	// v3Report builds the report an agent answers an engine discovery with
+	v3Report := func(engineID []byte, bindings ...[]byte) []byte {
+		parameters := ber(BER_SEQUENCE,
+			ber(BER_OCTET_STRING, engineID),
+			integer(7),
+			ber(BER_INTEGER, []byte{0x01, 0x2c}),
+			ber(BER_OCTET_STRING),
+			ber(BER_OCTET_STRING),
+			ber(BER_OCTET_STRING))
+		return ber(BER_SEQUENCE,
+			integer(3),
+			ber(BER_SEQUENCE, integer(1), ber(BER_INTEGER, []byte{0x00, 0xff, 0xe3}), ber(BER_OCTET_STRING, []byte{0x00}), integer(3)),
+			ber(BER_OCTET_STRING, parameters),
+			ber(BER_SEQUENCE,
+				ber(BER_OCTET_STRING, engineID),
+				ber(BER_OCTET_STRING),
+				ber(SNMP_REPORT, integer(1), integer(0), integer(0), ber(BER_SEQUENCE, bindings...))))
+	}
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name: "v2c system variables",
+			response: response(1, getResponse(
+				binding(sysDescr, ber(BER_OCTET_STRING, []byte("Linux router 5.10\n"))),
+				binding(sysObjectID, ber(BER_OID, []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0x89, 0x37})),
+				binding(sysName, ber(BER_OCTET_STRING, []byte("router"))),
+				binding(sysUpTime, ber(0x43, []byte{0x01})),
+			)),
+			info: Info{
+				Banner: "Linux router 5.10",
+				Metadata: map[string]string{
+					"snmp_version": "2c",
+					"community":    "public",
+					"sysObjectID":  "1.3.6.1.4.1.1207",
+					"sysName":      "router",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "v1 without variables",
+			response: response(0, getResponse()),
+			info:     Info{Metadata: map[string]string{"snmp_version": "1", "community": "public"}},
+			ok:       true,
+		},
+		{
+			name:     "v3 without security parameters",
+			response: ber(BER_SEQUENCE, integer(3), ber(BER_SEQUENCE, integer(1))),
+			info:     Info{Metadata: map[string]string{"snmp_version": "3"}},
+			ok:       true,
+		},
+		{
+			name: "v3 discovery report",
+			response: v3Report(
+				[]byte{0x80, 0x00, 0x00, 0x09, 0x03, 0x00, 0x1b, 0x54, 0x12, 0x34, 0x56},
+				binding(usmStatsUnknownEngineIDs, ber(0x41, []byte{0x05}))),
+			info: Info{Metadata: map[string]string{
+				"snmp_version":      "3",
+				"engine_id":         "8000000903001b54123456",
+				"engine_enterprise": "9",
+				"engine_vendor":     "Cisco",
+				"engine_mac":        "00:1b:54:12:34:56",
+				"engine_boots":      "7",
+				"engine_time":       "300",
+				"report":            "unknown_engine_id",
+			}},
+			ok: true,
+		},
+		{
+			name: "v3 text engine ID of an unknown vendor",
+			response: v3Report(
+				append([]byte{0x80, 0x00, 0x00, 0x63, 0x04}, "core-sw1"...),
+				binding([]byte{0x2b, 0x06, 0x01, 0x06, 0x03, 0x0f, 0x01, 0x01, 0x03, 0x00}, ber(0x41, []byte{0x01}))),
+			info: Info{Metadata: map[string]string{
+				"snmp_version":      "3",
+				"engine_id":         "80000063" + "04" + "636f72652d737731",
+				"engine_enterprise": "99",
+				"engine_text":       "core-sw1",
+				"engine_boots":      "7",
+				"engine_time":       "300",
+				"report":            "unknown_user_name",
+			}},
+			ok: true,
+		},
+		{
+			name:     "truncated message",
+			response: response(1, getResponse(binding(sysName, ber(BER_OCTET_STRING, []byte("router")))))[:20],
+			ok:       false,
+		},
+		{name: "not a sequence", response: []byte("hello"), ok: false},
+		{name: "missing community", response: ber(BER_SEQUENCE, integer(1)), ok: false},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseSNMP(nil, test.response)
+
+		if ok != test.ok {
+			t.Errorf("%s: ok = %v, want %v", test.name, ok, test.ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, want %+v", test.name, info, test.info)
+		}
+	}
+}
+
+func TestParseSNMPTrap(t *testing.T) {
+
+	// An SNMPv2c inform and its acknowledgement, which only differ in the
+	// type of their PDU
+	message := func(pdu byte, requestID byte) []byte {
+		return ber(BER_SEQUENCE, []byte{BER_INTEGER, 1, 1}, ber(BER_OCTET_STRING, []byte("public")),
+			ber(pdu, []byte{BER_INTEGER, 1, requestID}, []byte{BER_INTEGER, 1, 0}, []byte{BER_INTEGER, 1, 0}, ber(BER_SEQUENCE)))
+	}
+	request := message(SNMP_INFORM, 0x74)
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "acknowledged",
+			response: message(SNMP_GET_RESPONSE, 0x74),
+			info:     Info{Metadata: map[string]string{"snmp_version": "2c", "community": "public", "inform_acknowledged": "true"}},
+			ok:       true,
+		},
+		{
+			name:     "other request",
+			response: message(SNMP_GET_RESPONSE, 0x75),
+			ok:       false,
+		},
+		{
+			name:     "echoed inform",
+			response: request,
+			ok:       false,
+		},
+		{
+			name:     "truncated",
+			response: message(SNMP_GET_RESPONSE, 0x74)[:10],
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseSNMPTrap(request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+	if info, ok := Silent("snmptrap"); !ok || info.Metadata["snmptrap"] != "likely" {
+		t.Errorf("silent snmptrap: got %+v, %v, want snmptrap likely", info, ok)
+	}
+}
@@ -0,0 +1,147 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+)
+
+const (
+	STUN_HEADER_LENGTH = 20
+	STUN_MAGIC_COOKIE  = 0x2112a442
+
+	STUN_METHOD_BINDING  = 0x001
+	STUN_METHOD_ALLOCATE = 0x003
+
+	STUN_CLASS_SUCCESS = 0x100
+	STUN_CLASS_ERROR   = 0x110
+
+	STUN_ATTRIBUTE_MAPPED_ADDRESS      = 0x0001
+	STUN_ATTRIBUTE_ERROR_CODE          = 0x0009
+	STUN_ATTRIBUTE_REALM               = 0x0014
+	STUN_ATTRIBUTE_XOR_RELAYED_ADDRESS = 0x0016
+	STUN_ATTRIBUTE_XOR_MAPPED_ADDRESS  = 0x0020
+	STUN_ATTRIBUTE_SOFTWARE            = 0x8022
+	STUN_ATTRIBUTE_OTHER_ADDRESS       = 0x802c
+
+	STUN_ERROR_UNAUTHORIZED = 401
+)
+
+// ParseSTUN extracts the address a STUN server saw the request come from,
+// which is the public address of scanners behind NAT, and its software.
+// Answers to TURN allocations mark TURN servers: an error asking for
+// credentials reports their realm, while a relayed address means they relay
+// traffic for anyone.
+func ParseSTUN(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < STUN_HEADER_LENGTH || binary.BigEndian.Uint32(response[4:8]) != STUN_MAGIC_COOKIE {
+		return
+	}
+	kind := binary.BigEndian.Uint16(response[0:2])
+	length := int(binary.BigEndian.Uint16(response[2:4]))
+
+	if kind&0xc000 != 0 || STUN_HEADER_LENGTH+length > len(response) {
+		return
+	}
+	// The class bits are interleaved with those of the method
+	class, method := kind&0x110, kind&^0x110
+
+	if class != STUN_CLASS_SUCCESS && class != STUN_CLASS_ERROR {
+		return
+	}
+	var code int
+
+	for attributes := response[STUN_HEADER_LENGTH : STUN_HEADER_LENGTH+length]; len(attributes) >= 4; {
+		kind, length := binary.BigEndian.Uint16(attributes[0:2]), int(binary.BigEndian.Uint16(attributes[2:4]))
+
+		if 4+length > len(attributes) {
+			break
+		}
+		value := attributes[4 : 4+length]
+
+		switch kind {
+		case STUN_ATTRIBUTE_MAPPED_ADDRESS:
+			if _, found := info.Metadata["mapped_address"]; !found {
+				info.setMetadata("mapped_address", stunAddress(value, nil))
+			}
+		case STUN_ATTRIBUTE_XOR_MAPPED_ADDRESS:
+			info.setMetadata("mapped_address", stunAddress(value, response[4:20]))
+		case STUN_ATTRIBUTE_XOR_RELAYED_ADDRESS:
+			info.setMetadata("relayed_address", stunAddress(value, response[4:20]))
+		case STUN_ATTRIBUTE_OTHER_ADDRESS:
+			info.setMetadata("other_address", stunAddress(value, nil))
+		case STUN_ATTRIBUTE_REALM:
+			info.setMetadata("realm", printable(string(value)))
+		case STUN_ATTRIBUTE_SOFTWARE:
+			info.Banner = printable(string(value))
+		case STUN_ATTRIBUTE_ERROR_CODE:
+			if len(value) >= 4 {
+				code = int(value[2]&0x07)*100 + int(value[3])
+				info.setMetadata("error", printable(strconv.Itoa(code)+" "+string(value[4:])))
+			}
+		}
+		// Attributes are padded to four bytes
+		attributes = attributes[4+length:]
+
+		if padding := (4 - length%4) % 4; padding <= len(attributes) {
+			attributes = attributes[padding:]
+		} else {
+			break
+		}
+	}
+
+	if method == STUN_METHOD_ALLOCATE {
+		info.setMetadata("turn", "true")
+
+		switch {
+		case class == STUN_CLASS_SUCCESS:
+			info.setMetadata("turn_auth", "none")
+		case code == STUN_ERROR_UNAUTHORIZED:
+			info.setMetadata("turn_auth", "required")
+		}
+	}
+	return info, !info.Empty()
+}
+
+// MatchSTUN compares the transaction IDs of STUN requests and responses
+func MatchSTUN(request []byte, response []byte) bool {
+
+	if len(request) < STUN_HEADER_LENGTH || len(response) < STUN_HEADER_LENGTH {
+		return true
+	}
+	return bytes.Equal(request[8:20], response[8:20])
+}
+
+// stunAddress formats an address attribute, whose port and address are
+// obfuscated by the magic cookie and transaction ID when mask is given
+func stunAddress(value []byte, mask []byte) string {
+
+	if len(value) < 4 {
+		return ""
+	}
+	var address net.IP
+
+	switch value[1] {
+	case 0x01:
+		address = make(net.IP, net.IPv4len)
+	case 0x02:
+		address = make(net.IP, net.IPv6len)
+	default:
+		return ""
+	}
+	if len(value) < 4+len(address) {
+		return ""
+	}
+	port := binary.BigEndian.Uint16(value[2:4])
+	copy(address, value[4:])
+
+	if mask != nil {
+		port ^= binary.BigEndian.Uint16(mask[0:2])
+
+		for i := range address {
+			address[i] ^= mask[i]
+		}
+	}
+	return net.JoinHostPort(address.String(), strconv.Itoa(int(port)))
+}
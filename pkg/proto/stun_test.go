@@ -0,0 +1,110 @@
+package proto
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// stunMessage builds a STUN message with the transaction ID of the probes
+func stunMessage(kind uint16, attributes ...[]byte) []byte {
+
+	message := []byte{0, 0, 0, 0, 0x21, 0x12, 0xa4, 0x42, 'u', 'd', 'p', 'z', 0, 0, 0, 0, 0, 0, 0, 6}
+	binary.BigEndian.PutUint16(message[0:2], kind)
+
+	for _, attribute := range attributes {
+		message = append(message, attribute...)
+	}
+	binary.BigEndian.PutUint16(message[2:4], uint16(len(message)-STUN_HEADER_LENGTH))
+
+	return message
+}
+
+// stunAttribute builds an attribute, padded to four bytes
+func stunAttribute(kind uint16, value []byte) []byte {
+
+	attribute := []byte{byte(kind >> 8), byte(kind), byte(len(value) >> 8), byte(len(value))}
+	attribute = append(attribute, value...)
+
+	for len(attribute)%4 != 0 {
+		attribute = append(attribute, 0)
+	}
+	return attribute
+}
+
+func TestParseSTUN(t *testing.T) {
+
+	// 203.0.113.7:40000 obfuscated by the magic cookie
+	mapped := stunAttribute(STUN_ATTRIBUTE_XOR_MAPPED_ADDRESS, []byte{0, 0x01, 0x9c ^ 0x21, 0x40 ^ 0x12, 203 ^ 0x21, 0 ^ 0x12, 113 ^ 0xa4, 7 ^ 0x42})
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name: "binding response",
+			response: stunMessage(0x0101,
+				stunAttribute(STUN_ATTRIBUTE_MAPPED_ADDRESS, []byte{0, 0x01, 0x9c, 0x40, 10, 0, 0, 1}),
+				mapped,
+				stunAttribute(STUN_ATTRIBUTE_OTHER_ADDRESS, []byte{0, 0x01, 0x0d, 0x97, 198, 51, 100, 2}),
+				stunAttribute(STUN_ATTRIBUTE_SOFTWARE, []byte("Coturn-4.6.2 'Gorst'"))),
+			info: Info{
+				Banner: "Coturn-4.6.2 'Gorst'",
+				Metadata: map[string]string{
+					"mapped_address": "203.0.113.7:40000",
+					"other_address":  "198.51.100.2:3479",
+				},
+			},
+			ok: true,
+		},
+		{
+			name: "allocate requiring credentials",
+			response: stunMessage(0x0113,
+				stunAttribute(STUN_ATTRIBUTE_ERROR_CODE, append([]byte{0, 0, 4, 1}, "Unauthorized"...)),
+				stunAttribute(STUN_ATTRIBUTE_REALM, []byte("example.org")),
+				stunAttribute(0x0015, []byte("nonce"))),
+			info: Info{
+				Metadata: map[string]string{
+					"error":     "401 Unauthorized",
+					"realm":     "example.org",
+					"turn":      "true",
+					"turn_auth": "required",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "open relay",
+			response: stunMessage(0x0103, stunAttribute(STUN_ATTRIBUTE_XOR_RELAYED_ADDRESS, mapped[4:]), mapped),
+			info: Info{
+				Metadata: map[string]string{
+					"mapped_address":  "203.0.113.7:40000",
+					"relayed_address": "203.0.113.7:40000",
+					"turn":            "true",
+					"turn_auth":       "none",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "echoed request",
+			response: stunMessage(0x0001),
+			ok:       false,
+		},
+		{
+			name:     "truncated attributes",
+			response: stunMessage(0x0101, mapped)[:STUN_HEADER_LENGTH+4],
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseSTUN(nil, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package proto
+
+import (
+	"bytes"
+	"strconv"
+)
+
+const (
+	TS3_HEADER_LENGTH = 11
+	TS3_PACKET_INIT   = 0x08
+)
+
+var (
+	// TS3_INIT_MAC is the message authentication code of the unencrypted
+	// packets TeamSpeak 3 clients and servers start the handshake with
+	TS3_INIT_MAC = []byte("TS3INIT1")
+)
+
+// ParseTeamSpeak reports the step TeamSpeak 3 servers answer the first packet
+// of a client handshake with. Servers answer step 0 with step 1 and a cookie,
+// and ask clients they consider too old to start over with step 127.
+func ParseTeamSpeak(request []byte, response []byte) (info Info, ok bool) {
+
+	// Servers leave out the client ID clients give after the packet ID
+	if len(response) < TS3_HEADER_LENGTH+1 || !bytes.HasPrefix(response, TS3_INIT_MAC) || response[10]&0x0f != TS3_PACKET_INIT {
+		return
+	}
+	if bytes.Equal(request, response) {
+		return
+	}
+	info.setMetadata("init_step", strconv.Itoa(int(response[TS3_HEADER_LENGTH])))
+
+	return info, true
+}
@@ -0,0 +1,49 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTeamSpeak(t *testing.T) {
+
+	request := append([]byte("TS3INIT1\x00\x65\x00\x00\x88\x5e\x0b\xe1\x00\x00udpzudpz"), make([]byte, 8)...)
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "cookie",
+			response: append([]byte("TS3INIT1\x00\x65\x88\x01"), make([]byte, 20)...),
+			info:     Info{Metadata: map[string]string{"init_step": "1"}},
+			ok:       true,
+		},
+		{
+			name:     "client too old",
+			response: []byte("TS3INIT1\x00\x65\x88\x7f"),
+			info:     Info{Metadata: map[string]string{"init_step": "127"}},
+			ok:       true,
+		},
+		{
+			name:     "echoed request",
+			response: request,
+			ok:       false,
+		},
+		{
+			name:     "other packet",
+			response: []byte("TS3INIT1\x00\x65\x82\x01"),
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseTeamSpeak(request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+)
+
+const (
+	TFTP_READ_REQUEST = 1
+	TFTP_DATA         = 3
+	TFTP_ERROR        = 5
+	TFTP_OACK         = 6
+)
+
+var (
+	// TFTP_ERRORS maps the codes of TFTP errors to their names, for servers
+	// that send no message
+	TFTP_ERRORS = map[uint16]string{
+		0: "Not defined",
+		1: "File not found",
+		2: "Access violation",
+		3: "Disk full",
+		4: "Illegal TFTP operation",
+		5: "Unknown transfer ID",
+		6: "File already exists",
+		7: "No such user",
+		8: "Option negotiation failed",
+	}
+)
+
+// ParseTFTP reports whether a TFTP server serves the file a read request
+// asked for: servers send the first block of the files they serve, and an
+// error otherwise. Each file served is reported under its own key, such as
+// file:startup-config, so that results for several files can be merged.
+func ParseTFTP(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(request) < 4 || binary.BigEndian.Uint16(request[0:2]) != TFTP_READ_REQUEST || len(response) < 4 {
+		return
+	}
+	file, _, _ := bytes.Cut(request[2:], []byte{0})
+
+	switch binary.BigEndian.Uint16(response[0:2]) {
+	case TFTP_DATA:
+		if binary.BigEndian.Uint16(response[2:4]) != 1 {
+			return
+		}
+		info.setMetadata("file:"+printable(string(file)), strconv.Itoa(len(response)-4)+" bytes")
+
+	case TFTP_OACK:
+		info.setMetadata("file:"+printable(string(file)), "acknowledged")
+
+	case TFTP_ERROR:
+		code := binary.BigEndian.Uint16(response[2:4])
+		message, _, _ := bytes.Cut(response[4:], []byte{0})
+
+		if len(message) == 0 {
+			message = []byte(TFTP_ERRORS[code])
+		}
+		info.setMetadata("error", printable(string(message)))
+
+	default:
+		return
+	}
+	return info, true
+}
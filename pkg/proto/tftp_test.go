@@ -0,0 +1,68 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTFTP(t *testing.T) {
+
+	request := []byte("\x00\x01startup-config\x00octet\x00")
+
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "first block",
+			request:  request,
+			response: []byte("\x00\x03\x00\x01version 15.2\n"),
+			info:     Info{Metadata: map[string]string{"file:startup-config": "13 bytes"}},
+			ok:       true,
+		},
+		{
+			name:     "option acknowledgment",
+			request:  request,
+			response: []byte("\x00\x06tsize\x002048\x00"),
+			info:     Info{Metadata: map[string]string{"file:startup-config": "acknowledged"}},
+			ok:       true,
+		},
+		{
+			name:     "file not found",
+			request:  request,
+			response: []byte("\x00\x05\x00\x01File not found\x00"),
+			info:     Info{Metadata: map[string]string{"error": "File not found"}},
+			ok:       true,
+		},
+		{
+			name:     "error without message",
+			request:  request,
+			response: []byte("\x00\x05\x00\x02\x00"),
+			info:     Info{Metadata: map[string]string{"error": "Access violation"}},
+			ok:       true,
+		},
+		{
+			name:     "later block",
+			request:  request,
+			response: []byte("\x00\x03\x00\x02end\n"),
+			ok:       false,
+		},
+		{
+			name:     "echoed request",
+			request:  request,
+			response: request,
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseTFTP(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+)
+
+const (
+	VXLAN_HEADER_LENGTH  = 8
+	VXLAN_FLAG_VNI       = 0x08
+	GENEVE_HEADER_LENGTH = 8
+	GRE_HEADER_LENGTH    = 4
+	GRE_FLAG_CHECKSUM    = 0x8000
+	GRE_FLAG_KEY         = 0x2000
+	GRE_FLAG_SEQUENCE    = 0x1000
+
+	ETHERTYPE_BRIDGING = 0x6558
+	ETHERTYPE_ARP      = 0x0806
+	ETHERNET_LENGTH    = 14
+	ARP_LENGTH         = 28
+	ARP_REPLY          = 2
+)
+
+// ParseVXLAN accepts frames VXLAN tunnel endpoints encapsulate back to the
+// scanner, which show that they decapsulated the ARP request of the probe and
+// bridged it into the overlay network. Most endpoints send their replies to
+// port 4789 rather than the port of the probe, or only learn where to send them
+// from their configuration, so they rarely answer at all.
+func ParseVXLAN(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < VXLAN_HEADER_LENGTH+ETHERNET_LENGTH || response[0]&VXLAN_FLAG_VNI == 0 || bytes.Equal(request, response) {
+		return
+	}
+	return tunnelFrame(response[4:7], response[VXLAN_HEADER_LENGTH:])
+}
+
+// ParseGeneve accepts Ethernet frames Geneve tunnel endpoints encapsulate back
+// to the scanner, as ParseVXLAN does for VXLAN
+func ParseGeneve(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < GENEVE_HEADER_LENGTH || response[0]>>6 != 0 || bytes.Equal(request, response) {
+		return
+	}
+	// The options follow the header, in multiples of four bytes
+	length := GENEVE_HEADER_LENGTH + int(response[0]&0x3f)*4
+
+	if len(response) < length || binary.BigEndian.Uint16(response[2:4]) != ETHERTYPE_BRIDGING {
+		return
+	}
+	return tunnelFrame(response[4:7], response[length:])
+}
+
+// ParseGREUDP accepts Ethernet frames GRE-in-UDP tunnel endpoints encapsulate
+// back to the scanner, as ParseVXLAN does for VXLAN. The key of the tunnel, if
+// it has one, stands in for the network identifier.
+func ParseGREUDP(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < GRE_HEADER_LENGTH || bytes.Equal(request, response) {
+		return
+	}
+	flags := binary.BigEndian.Uint16(response[0:2])
+
+	// Only version 0 carries packets
+	if flags&0x0007 != 0 || binary.BigEndian.Uint16(response[2:4]) != ETHERTYPE_BRIDGING {
+		return
+	}
+	length := GRE_HEADER_LENGTH
+	var key []byte
+
+	if flags&GRE_FLAG_CHECKSUM != 0 {
+		length += 4
+	}
+	if flags&GRE_FLAG_KEY != 0 {
+		if len(response) < length+4 {
+			return
+		}
+		key = response[length : length+4]
+		length += 4
+	}
+	if flags&GRE_FLAG_SEQUENCE != 0 {
+		length += 4
+	}
+	if len(response) < length {
+		return
+	}
+	return tunnelFrame(key, response[length:])
+}
+
+// tunnelFrame reports the network identifier of a tunnel and the source of the
+// Ethernet frame it carried, with the address an ARP reply gives for it
+func tunnelFrame(identifier []byte, frame []byte) (info Info, ok bool) {
+
+	if len(frame) < ETHERNET_LENGTH {
+		return
+	}
+	if identifier != nil {
+		id := 0
+
+		for _, b := range identifier {
+			id = id<<8 | int(b)
+		}
+		info.setMetadata("vni", strconv.Itoa(id))
+	}
+	info.setMetadata("inner_mac", net.HardwareAddr(frame[6:12]).String())
+
+	arp := frame[ETHERNET_LENGTH:]
+
+	if binary.BigEndian.Uint16(frame[12:14]) == ETHERTYPE_ARP && len(arp) >= ARP_LENGTH && binary.BigEndian.Uint16(arp[6:8]) == ARP_REPLY {
+		info.setMetadata("inner_ip", net.IP(arp[14:18]).String())
+	}
+	info.setMetadata("tunnel_injection", "true")
+
+	return info, true
+}
@@ -0,0 +1,94 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+// arpFrame builds an Ethernet frame carrying an ARP message from the MAC and
+// IPv4 address
+func arpFrame(operation byte, mac []byte, ip []byte) []byte {
+
+	frame := append([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, mac...)
+	frame = append(frame, 0x08, 0x06, 0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, operation)
+	frame = append(frame, mac...)
+	frame = append(frame, ip...)
+	return append(frame, make([]byte, 10)...)
+}
+
+func TestParseTunnels(t *testing.T) {
+
+	mac := []byte{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}
+	reply := arpFrame(ARP_REPLY, mac, []byte{10, 0, 0, 1})
+	request := arpFrame(1, []byte{0x02, 0x75, 0x70, 0x64, 0x7a, 0x00}, []byte{0, 0, 0, 0})
+	injected := map[string]string{"vni": "4096", "inner_mac": "02:42:ac:11:00:02", "inner_ip": "10.0.0.1", "tunnel_injection": "true"}
+
+	tests := []struct {
+		name     string
+		parser   Parser
+		request  []byte
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "vxlan arp reply",
+			parser:   ParseVXLAN,
+			request:  append([]byte{0x08, 0, 0, 0, 0, 0, 0x01, 0}, request...),
+			response: append([]byte{0x08, 0, 0, 0, 0, 0x10, 0x00, 0}, reply...),
+			info:     Info{Metadata: injected},
+			ok:       true,
+		},
+		{
+			name:     "vxlan echoed request",
+			parser:   ParseVXLAN,
+			request:  append([]byte{0x08, 0, 0, 0, 0, 0, 0x01, 0}, request...),
+			response: append([]byte{0x08, 0, 0, 0, 0, 0, 0x01, 0}, request...),
+			ok:       false,
+		},
+		{
+			name:     "geneve arp reply with option",
+			parser:   ParseGeneve,
+			response: append([]byte{0x01, 0, 0x65, 0x58, 0, 0x10, 0x00, 0, 0x01, 0x02, 0x80, 0x00}, reply...),
+			info:     Info{Metadata: injected},
+			ok:       true,
+		},
+		{
+			name:     "geneve ipv4",
+			parser:   ParseGeneve,
+			response: append([]byte{0, 0, 0x08, 0x00, 0, 0x10, 0x00, 0}, reply...),
+			ok:       false,
+		},
+		{
+			name:     "gre with key",
+			parser:   ParseGREUDP,
+			response: append([]byte{0x20, 0, 0x65, 0x58, 0, 0, 0x10, 0x00}, reply...),
+			info:     Info{Metadata: injected},
+			ok:       true,
+		},
+		{
+			name:     "gre without key",
+			parser:   ParseGREUDP,
+			response: append([]byte{0, 0, 0x65, 0x58}, request...),
+			info:     Info{Metadata: map[string]string{"inner_mac": "02:75:70:64:7a:00", "tunnel_injection": "true"}},
+			ok:       true,
+		},
+		{
+			name:     "other protocol",
+			parser:   ParseGREUDP,
+			response: []byte("HTTP/1.1 400 Bad Request\r\n"),
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := test.parser(test.request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+	if info, ok := Silent("vxlan"); !ok || info.Metadata["tunnel_injection"] != "possible" {
+		t.Errorf("silent vxlan: got %+v, %v, want tunnel_injection possible", info, ok)
+	}
+}
@@ -0,0 +1,119 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+var (
+	// UPNP_HEADERS are the SSDP response headers kept as metadata
+	UPNP_HEADERS = map[string]string{
+		"location": "location",
+		"st":       "search_target",
+		"nt":       "search_target",
+		"usn":      "usn",
+	}
+)
+
+// upnpDevice is a device of a UPnP device description, with its services and
+// embedded devices
+type upnpDevice struct {
+	DeviceType       string        `xml:"deviceType"`
+	FriendlyName     string        `xml:"friendlyName"`
+	Manufacturer     string        `xml:"manufacturer"`
+	ModelName        string        `xml:"modelName"`
+	ModelNumber      string        `xml:"modelNumber"`
+	ModelDescription string        `xml:"modelDescription"`
+	SerialNumber     string        `xml:"serialNumber"`
+	Services         []upnpService `xml:"serviceList>service"`
+	Devices          []upnpDevice  `xml:"deviceList>device"`
+}
+
+// upnpService is a service offered by a device of a UPnP device description
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+}
+
+// ParseUPnP extracts the SERVER header as the banner, and the description URL,
+// search target and unique service name from SSDP responses and
+// announcements
+func ParseUPnP(request []byte, response []byte) (info Info, ok bool) {
+
+	lines := strings.Split(string(response), "\n")
+	status := strings.TrimSpace(lines[0])
+
+	if !strings.HasPrefix(status, "HTTP/1.") && !strings.HasPrefix(status, "NOTIFY ") {
+		return
+	}
+	for _, line := range lines[1:] {
+		name, value, found := strings.Cut(line, ":")
+
+		if !found {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = printable(value)
+
+		if name == "server" {
+			info.Banner = value
+		} else if key, ok := UPNP_HEADERS[name]; ok {
+			info.setMetadata(key, value)
+		}
+	}
+	return info, !info.Empty()
+}
+
+// ParseUPnPDescription extracts the model, manufacturer and the services of
+// every device from a UPnP device description document, as fetched from the
+// LOCATION of an SSDP response
+func ParseUPnPDescription(document []byte) (info Info, ok bool) {
+
+	var root struct {
+		Device upnpDevice `xml:"device"`
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+
+	// Descriptions are meant to be UTF-8, but some devices declare another
+	// charset, which is read as is rather than rejected
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(&root); err != nil {
+		return
+	}
+	device := root.Device
+
+	info.Version = printable(device.ModelName + " " + device.ModelNumber)
+	info.setMetadata("device_type", printable(device.DeviceType))
+	info.setMetadata("friendly_name", printable(device.FriendlyName))
+	info.setMetadata("manufacturer", printable(device.Manufacturer))
+	info.setMetadata("model", printable(device.ModelName))
+	info.setMetadata("model_number", printable(device.ModelNumber))
+	info.setMetadata("model_description", printable(device.ModelDescription))
+	info.setMetadata("serial_number", printable(device.SerialNumber))
+
+	seen := make(map[string]bool)
+	var services []string
+
+	var walk func(device upnpDevice)
+	walk = func(device upnpDevice) {
+		for _, service := range device.Services {
+			if serviceType := printable(service.ServiceType); serviceType != "" && !seen[serviceType] {
+				seen[serviceType] = true
+				services = append(services, serviceType)
+			}
+		}
+		for _, embedded := range device.Devices {
+			walk(embedded)
+		}
+	}
+	walk(device)
+	sort.Strings(services)
+
+	info.setMetadata("upnp_services", strings.Join(services, ","))
+
+	return info, !info.Empty()
+}
@@ -0,0 +1,103 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUPnP(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		response string
+		info     Info
+		ok       bool
+	}{
+		{
+			name: "search response",
+			response: "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\nST: upnp:rootdevice\r\n" +
+				"USN: uuid:1234::upnp:rootdevice\r\nEXT:\r\nServer: Linux/4.14 UPnP/1.0 MiniUPnPd/2.2\r\n" +
+				"Location: http://192.168.1.1:5000/rootDesc.xml\r\n\r\n",
+			info: Info{
+				Banner: "Linux/4.14 UPnP/1.0 MiniUPnPd/2.2",
+				Metadata: map[string]string{
+					"location":      "http://192.168.1.1:5000/rootDesc.xml",
+					"search_target": "upnp:rootdevice",
+					"usn":           "uuid:1234::upnp:rootdevice",
+				},
+			},
+			ok: true,
+		},
+		{
+			name:     "announcement",
+			response: "NOTIFY * HTTP/1.1\r\nNT: urn:schemas-upnp-org:device:MediaServer:1\r\nNTS: ssdp:alive\r\n\r\n",
+			info:     Info{Metadata: map[string]string{"search_target": "urn:schemas-upnp-org:device:MediaServer:1"}},
+			ok:       true,
+		},
+		{
+			name:     "response without headers of interest",
+			response: "HTTP/1.1 200 OK\r\nEXT:\r\n\r\n",
+			ok:       false,
+		},
+		{
+			name:     "not HTTP",
+			response: "\x00\x01Server: fake\r\n",
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseUPnP(nil, []byte(test.response))
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
+
+func TestParseUPnPDescription(t *testing.T) {
+
+	document := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <friendlyName>Home Router</friendlyName>
+    <manufacturer>Example Networks</manufacturer>
+    <modelName>XR-500</modelName>
+    <modelNumber>v2</modelNumber>
+    <serialNumber>00000001</serialNumber>
+    <serviceList>
+      <service><serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType></service>
+    </serviceList>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <serviceList>
+          <service><serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType></service>
+          <service><serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType></service>
+        </serviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+	want := Info{
+		Version: "XR-500 v2",
+		Metadata: map[string]string{
+			"device_type":   "urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+			"friendly_name": "Home Router",
+			"manufacturer":  "Example Networks",
+			"model":         "XR-500",
+			"model_number":  "v2",
+			"serial_number": "00000001",
+			"upnp_services": "urn:schemas-upnp-org:service:Layer3Forwarding:1,urn:schemas-upnp-org:service:WANIPConnection:1",
+		},
+	}
+	if info, ok := ParseUPnPDescription([]byte(document)); !ok || !reflect.DeepEqual(info, want) {
+		t.Errorf("got %+v, %v, want %+v, true", info, ok, want)
+	}
+	if info, ok := ParseUPnPDescription([]byte("<html>not found")); ok {
+		t.Errorf("got %+v, true for a document that is not a description, want false", info)
+	}
+}
@@ -0,0 +1,49 @@
+package proto
+
+import (
+	"bytes"
+)
+
+const (
+	WIREGUARD_HANDSHAKE_RESPONSE = 2
+	WIREGUARD_COOKIE_REPLY       = 3
+)
+
+var (
+	// WIREGUARD_MESSAGES maps the types of the messages WireGuard endpoints
+	// answer handshake initiations with to their length and names
+	WIREGUARD_MESSAGES = map[byte]struct {
+		length int
+		name   string
+	}{
+		WIREGUARD_HANDSHAKE_RESPONSE: {92, "handshake_response"},
+		WIREGUARD_COOKIE_REPLY:       {64, "cookie_reply"},
+	}
+)
+
+// ParseWireGuard accepts handshake responses and cookie replies addressed to
+// the sender index of the initiation. Endpoints only send either for
+// initiations to their public key, so most never answer at all.
+func ParseWireGuard(request []byte, response []byte) (info Info, ok bool) {
+
+	if len(response) < 1 {
+		return
+	}
+	message, found := WIREGUARD_MESSAGES[response[0]]
+
+	if !found || len(response) != message.length || !allZero(response[1:4]) {
+		return
+	}
+	// Handshake responses carry their own sender index before the receiver's
+	receiver := response[4:8]
+
+	if response[0] == WIREGUARD_HANDSHAKE_RESPONSE {
+		receiver = response[8:12]
+	}
+	if len(request) >= 8 && !bytes.Equal(receiver, request[4:8]) {
+		return
+	}
+	info.setMetadata("message", message.name)
+
+	return info, true
+}
@@ -0,0 +1,45 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWireGuard(t *testing.T) {
+
+	request := append([]byte{0x01, 0x00, 0x00, 0x00, 'u', 'd', 'p', 'z'}, make([]byte, 140)...)
+
+	handshakeResponse := make([]byte, 92)
+	handshakeResponse[0] = WIREGUARD_HANDSHAKE_RESPONSE
+	copy(handshakeResponse[4:8], "peer")
+	copy(handshakeResponse[8:12], "udpz")
+
+	cookieReply := make([]byte, 64)
+	cookieReply[0] = WIREGUARD_COOKIE_REPLY
+	copy(cookieReply[4:8], "udpz")
+
+	otherReceiver := append([]byte(nil), cookieReply...)
+	copy(otherReceiver[4:8], "peer")
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{name: "handshake response", response: handshakeResponse, info: Info{Metadata: map[string]string{"message": "handshake_response"}}, ok: true},
+		{name: "cookie reply", response: cookieReply, info: Info{Metadata: map[string]string{"message": "cookie_reply"}}, ok: true},
+		{name: "cookie reply to another sender", response: otherReceiver, ok: false},
+		{name: "truncated cookie reply", response: cookieReply[:32], ok: false},
+		{name: "echoed initiation", response: request, ok: false},
+		{name: "empty response", response: []byte{}, ok: false},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseWireGuard(request, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	// ONVIF_SCOPE starts the scopes ONVIF devices describe themselves with
+	ONVIF_SCOPE = "onvif://www.onvif.org/"
+)
+
+var (
+	// WSD_DEVICE_TYPES maps the types of WS-Discovery targets to the kind of
+	// device they are
+	WSD_DEVICE_TYPES = map[string]string{
+		"Computer":                "computer",
+		"PrintDeviceType":         "printer",
+		"ScanDeviceType":          "scanner",
+		"NetworkVideoTransmitter": "camera",
+		"NetworkVideoDisplay":     "display",
+	}
+	// ONVIF_SCOPES maps the scopes of ONVIF devices kept as metadata to their
+	// key
+	ONVIF_SCOPES = map[string]string{
+		"hardware": "model",
+		"location": "location",
+		"mac":      "mac_address",
+		"name":     "friendly_name",
+	}
+)
+
+// wsdProbeMatch is a target of a WS-Discovery ProbeMatches message
+type wsdProbeMatch struct {
+	Address         string `xml:"EndpointReference>Address"`
+	Types           string `xml:"Types"`
+	Scopes          string `xml:"Scopes"`
+	XAddrs          string `xml:"XAddrs"`
+	MetadataVersion string `xml:"MetadataVersion"`
+}
+
+// ParseWSD reports the endpoint, types, scopes and transport addresses of the
+// targets that answer a WS-Discovery Probe, such as Windows hosts, printers,
+// scanners and ONVIF cameras. The name, model and location of ONVIF devices
+// come from their scopes. SOAP faults, which answer the blank probe, are
+// reported by their reason.
+func ParseWSD(request []byte, response []byte) (info Info, ok bool) {
+
+	var envelope struct {
+		Matches []wsdProbeMatch `xml:"Body>ProbeMatches>ProbeMatch"`
+		Fault   string          `xml:"Body>Fault>Reason>Text"`
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(response))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(&envelope); err != nil {
+		return
+	}
+	info.setMetadata("fault", printable(envelope.Fault))
+
+	if len(envelope.Matches) == 0 {
+		return info, !info.Empty()
+	}
+	// Devices answer with one match, for themselves
+	match := envelope.Matches[0]
+	var types, devices []string
+
+	for _, name := range strings.Fields(match.Types) {
+		if _, local, found := strings.Cut(name, ":"); found {
+			name = local
+		}
+		types = append(types, printable(name))
+
+		if device, found := WSD_DEVICE_TYPES[name]; found {
+			devices = append(devices, device)
+		}
+	}
+	info.setMetadata("endpoint", printable(match.Address))
+	info.setMetadata("types", strings.Join(types, ","))
+	info.setMetadata("device_type", strings.Join(devices, ","))
+	info.setMetadata("xaddrs", strings.Join(strings.Fields(printable(match.XAddrs)), ","))
+	info.setMetadata("metadata_version", printable(match.MetadataVersion))
+
+	var scopes []string
+
+	for _, scope := range strings.Fields(match.Scopes) {
+		scopes = append(scopes, printable(scope))
+
+		// ONVIF scopes are a category and a value, as a path
+		category, value, found := strings.Cut(strings.TrimPrefix(scope, ONVIF_SCOPE), "/")
+
+		if !strings.HasPrefix(scope, ONVIF_SCOPE) || !found {
+			continue
+		}
+		if key, found := ONVIF_SCOPES[category]; found {
+			if unescaped, err := url.PathUnescape(value); err == nil {
+				value = unescaped
+			}
+			info.setMetadata(key, printable(value))
+		}
+	}
+	info.setMetadata("scopes", strings.Join(scopes, ","))
+	if info.Banner = info.Metadata["friendly_name"]; info.Banner == "" {
+		info.Banner = strings.Join(devices, ",")
+	}
+
+	return info, true
+}
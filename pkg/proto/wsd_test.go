@@ -0,0 +1,80 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+)
+
+// wsdProbeMatches builds a ProbeMatches message for a single target
+func wsdProbeMatches(types string, scopes string, xaddrs string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:wsa="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:wsd="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:wsdp="http://schemas.xmlsoap.org/ws/2006/02/devprof" xmlns:pub="http://schemas.microsoft.com/windows/pub/2005/07" xmlns:dn="http://www.onvif.org/ver10/network/wsdl">
+<soap:Header><wsa:Action>http://schemas.xmlsoap.org/ws/2005/04/discovery/ProbeMatches</wsa:Action></soap:Header>
+<soap:Body><wsd:ProbeMatches><wsd:ProbeMatch>
+<wsa:EndpointReference><wsa:Address>urn:uuid:7d7c3b4e-2b1f-4a3c-9c5e-0123456789ab</wsa:Address></wsa:EndpointReference>
+<wsd:Types>` + types + `</wsd:Types>
+<wsd:Scopes>` + scopes + `</wsd:Scopes>
+<wsd:XAddrs>` + xaddrs + `</wsd:XAddrs>
+<wsd:MetadataVersion>2</wsd:MetadataVersion>
+</wsd:ProbeMatch></wsd:ProbeMatches></soap:Body></soap:Envelope>`)
+}
+
+func TestParseWSD(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		response []byte
+		info     Info
+		ok       bool
+	}{
+		{
+			name:     "windows computer",
+			response: wsdProbeMatches("wsdp:Device pub:Computer", "", "http://10.10.14.7:5357/7d7c3b4e-2b1f-4a3c-9c5e-0123456789ab/"),
+			info: Info{Banner: "computer", Metadata: map[string]string{
+				"endpoint":         "urn:uuid:7d7c3b4e-2b1f-4a3c-9c5e-0123456789ab",
+				"types":            "Device,Computer",
+				"device_type":      "computer",
+				"xaddrs":           "http://10.10.14.7:5357/7d7c3b4e-2b1f-4a3c-9c5e-0123456789ab/",
+				"metadata_version": "2",
+			}},
+			ok: true,
+		},
+		{
+			name: "onvif camera",
+			response: wsdProbeMatches("dn:NetworkVideoTransmitter",
+				"onvif://www.onvif.org/type/video_encoder onvif://www.onvif.org/name/AXIS%20M3045 onvif://www.onvif.org/hardware/M3045-V onvif://www.onvif.org/location/Lobby",
+				"http://10.10.14.9/onvif/device_service http://[fe80::9]/onvif/device_service"),
+			info: Info{Banner: "AXIS M3045", Metadata: map[string]string{
+				"endpoint":         "urn:uuid:7d7c3b4e-2b1f-4a3c-9c5e-0123456789ab",
+				"types":            "NetworkVideoTransmitter",
+				"device_type":      "camera",
+				"xaddrs":           "http://10.10.14.9/onvif/device_service,http://[fe80::9]/onvif/device_service",
+				"metadata_version": "2",
+				"scopes":           "onvif://www.onvif.org/type/video_encoder,onvif://www.onvif.org/name/AXIS%20M3045,onvif://www.onvif.org/hardware/M3045-V,onvif://www.onvif.org/location/Lobby",
+				"friendly_name":    "AXIS M3045",
+				"model":            "M3045-V",
+				"location":         "Lobby",
+			}},
+			ok: true,
+		},
+		{
+			name:     "fault",
+			response: []byte(`<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body><soap:Fault><soap:Code><soap:Value>soap:Sender</soap:Value></soap:Code><soap:Reason><soap:Text xml:lang="en">Invalid message</soap:Text></soap:Reason></soap:Fault></soap:Body></soap:Envelope>`),
+			info:     Info{Metadata: map[string]string{"fault": "Invalid message"}},
+			ok:       true,
+		},
+		{
+			name:     "other protocol",
+			response: []byte("HTTP/1.1 400 Bad Request\r\n"),
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		info, ok := ParseWSD(nil, test.response)
+
+		if ok != test.ok || !reflect.DeepEqual(info, test.info) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, test.info, test.ok)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package scan
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"gopkg.in/yaml.v3"
+)
+
+// AmplificationCandidate is a probe a service answered, with the size of the
+// request and of the response it got back. Services answered over several
+// round trips have all of their responses counted against the first request.
+type AmplificationCandidate struct {
+	Host          string  `yaml:"host" json:"host"`
+	Port          uint16  `yaml:"port" json:"port"`
+	Service       string  `yaml:"service" json:"service"`
+	Probe         string  `yaml:"probe" json:"probe"`
+	RequestBytes  int     `yaml:"request_bytes" json:"request_bytes"`
+	ResponseBytes int     `yaml:"response_bytes" json:"response_bytes"`
+	Factor        float64 `yaml:"factor" json:"factor"`
+}
+
+// AmplificationCandidates ranks every probe that got a response across the
+// scan by how many times larger the response was than the request, as a
+// measure of how useful the service would be to reflect traffic at a spoofed
+// address
+func (sc *UdpProbeScanner) AmplificationCandidates() []AmplificationCandidate {
+
+	var candidates []AmplificationCandidate
+
+	for _, result := range sc.results {
+		if result.State != PORT_STATE_OPEN || result.Response == "" {
+			continue
+		}
+		request, err := base64.StdEncoding.DecodeString(result.Probe.EncodedData)
+
+		if err != nil || len(request) == 0 {
+			continue
+		}
+		response, err := base64.StdEncoding.DecodeString(result.Response)
+
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, AmplificationCandidate{
+			Host:          result.Host.Host,
+			Port:          result.Port,
+			Service:       result.Service.Slug,
+			Probe:         result.Probe.Slug,
+			RequestBytes:  len(request),
+			ResponseBytes: len(response),
+			Factor:        float64(len(response)) / float64(len(request)),
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Factor != candidates[j].Factor {
+			return candidates[i].Factor > candidates[j].Factor
+		}
+		if candidates[i].ResponseBytes != candidates[j].ResponseBytes {
+			return candidates[i].ResponseBytes > candidates[j].ResponseBytes
+		}
+		if candidates[i].Host != candidates[j].Host {
+			return candidates[i].Host < candidates[j].Host
+		}
+		return candidates[i].Port < candidates[j].Port
+	})
+	return candidates
+}
+
+// SaveAmplification writes the amplification candidates of the scan, most
+// amplifying first, as JSON, JSON lines, YAML or a table
+func (sc *UdpProbeScanner) SaveAmplification(format string, output io.Writer) error {
+
+	candidates := sc.AmplificationCandidates()
+
+	switch format {
+	case "json":
+		return json.NewEncoder(output).Encode(candidates)
+	case "jsonl":
+		encoder := json.NewEncoder(output)
+
+		for _, candidate := range candidates {
+			if err := encoder.Encode(&candidate); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "yml", "yaml":
+		return yaml.NewEncoder(output).Encode(candidates)
+	}
+	candidatesTable := table.NewWriter()
+	candidatesTable.AppendHeader(table.Row{"Rank", "Host", "Port", "Service", "Probe", "Request", "Response", "Amplification"})
+
+	for i, candidate := range candidates {
+		candidatesTable.AppendRow(table.Row{
+			i + 1,
+			candidate.Host,
+			fmt.Sprintf("%d/UDP", candidate.Port),
+			candidate.Service,
+			candidate.Probe,
+			candidate.RequestBytes,
+			candidate.ResponseBytes,
+			fmt.Sprintf("%.1fx", candidate.Factor),
+		})
+	}
+	candidatesTable.SetOutputMirror(output)
+	renderTable(candidatesTable, format)
+
+	return nil
+}
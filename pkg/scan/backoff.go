@@ -0,0 +1,56 @@
+package scan
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// retryTimeout returns how long to wait for a response to the given attempt,
+// growing the read timeout by the backoff factor with each retransmission. With
+// adaptive timeouts the read timeout follows the round trip time of the host.
+func (sc *UdpProbeScanner) retryTimeout(host Host, rtt *rttEstimator, attempt int) time.Duration {
+
+	readTimeout := sc.hostReadTimeout(host)
+
+	if rtt != nil {
+		readTimeout = rtt.timeout(readTimeout)
+	}
+	if sc.RetryBackoff <= 1 || attempt == 0 {
+		return readTimeout
+	}
+	timeout := float64(readTimeout) * math.Pow(sc.RetryBackoff, float64(attempt))
+
+	if timeout > float64(MAX_RETRY_TIMEOUT) {
+		return MAX_RETRY_TIMEOUT
+	}
+	return time.Duration(timeout)
+}
+
+// waitJitter sleeps for a random delay of up to the configured jitter before a
+// probe is sent, so packets do not leave in regular bursts
+func (sc *UdpProbeScanner) waitJitter(ctx context.Context) error {
+
+	if sc.Jitter <= 0 {
+		return nil
+	}
+	return sleepContext(ctx, time.Duration(rand.Int63n(int64(sc.Jitter))))
+}
+
+// sleepContext blocks for the given duration or until the context is canceled
+func sleepContext(ctx context.Context, delay time.Duration) error {
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
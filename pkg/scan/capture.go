@@ -0,0 +1,38 @@
+package scan
+
+import (
+	"encoding/hex"
+)
+
+// Capture holds the raw bytes of a response for offline analysis
+type Capture struct {
+	Length    int    `yaml:"length" json:"length"`
+	Hex       string `yaml:"hex" json:"hex"`
+	Printable string `yaml:"printable" json:"printable"`
+	Truncated bool   `yaml:"truncated" json:"truncated"`
+}
+
+// newCapture records up to maxLength bytes of the response, both as hex and
+// with non-printable bytes replaced by dots like a hex dump
+func newCapture(payload []byte, maxLength int) *Capture {
+
+	capture := &Capture{Length: len(payload)}
+
+	if len(payload) > maxLength {
+		payload = payload[:maxLength]
+		capture.Truncated = true
+	}
+	printable := make([]byte, len(payload))
+
+	for i, b := range payload {
+		if b >= 0x20 && b <= 0x7e {
+			printable[i] = b
+		} else {
+			printable[i] = '.'
+		}
+	}
+	capture.Hex = hex.EncodeToString(payload)
+	capture.Printable = string(printable)
+
+	return capture
+}
@@ -0,0 +1,170 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	RATE_ADAPT_INTERVAL = time.Second // How often the send rate is reconsidered
+	RATE_MIN_SAMPLES    = 20          // Probes to wait for before reconsidering the rate
+	RATE_DROP_THRESHOLD = 0.1         // Rise in the share of lost probes that counts as congestion
+	RATE_DECREASE       = 0.5         // Rate multiplier applied on congestion
+	RATE_INCREASE       = 1.1         // Rate multiplier applied otherwise
+	RATE_SLOW_START     = 2           // Rate multiplier applied until the first congestion
+	RATE_BASELINE_GAIN  = 0.125       // Weight of each interval in the baseline loss
+)
+
+// rateAdapter adjusts the send rate between bounds based on packet loss, much
+// like TCP congestion control. Probes only answered after a retransmission or
+// never answered, and ICMP errors other than port unreachable, are counted as
+// lost. Since most UDP ports never answer at any rate, loss only means
+// congestion, often a stateful firewall or rate limiter struggling under load,
+// once it rises above the baseline measured while the rate was low. The rate
+// then is halved, and raised again while loss stays near the baseline.
+type rateAdapter struct {
+	minRate float64
+	maxRate float64
+
+	answered uint64 // Probes answered on the first attempt
+	dropped  uint64 // Probes lost at least once, and ICMP errors
+
+	baseline  float64 // Smoothed share of lost probes without congestion
+	measured  bool    // Whether the baseline has been measured yet
+	slowStart bool    // Whether the rate is still doubled each interval
+}
+
+// newRateAdapter returns an adapter that ramps up from minRate in slow start
+func newRateAdapter(minRate float64, maxRate float64) *rateAdapter {
+	return &rateAdapter{minRate: minRate, maxRate: maxRate, slowStart: true}
+}
+
+// SetRateBounds lets the scanner adapt its send rate to packet loss, between
+// minRate and maxRate packets per second. A minRate of 0 defaults to a tenth
+// of maxRate. The scan starts at the rate set with SetRate if any, or at
+// minRate otherwise, so the loss baseline is measured before the rate ramps
+// up. A maxRate of 0 disables adaptation.
+func (sc *UdpProbeScanner) SetRateBounds(minRate float64, maxRate float64) error {
+
+	if maxRate <= 0 {
+		if minRate > 0 {
+			return errors.New("a minimum rate requires a maximum rate")
+		}
+		sc.adapter = nil
+		return nil
+	}
+	if minRate <= 0 {
+		minRate = maxRate / 10
+		if minRate < 1 {
+			minRate = 1
+		}
+	}
+	if minRate > maxRate {
+		return errors.New("minimum rate must not exceed the maximum rate")
+	}
+
+	if sc.limiter == nil {
+		sc.SetRate(minRate)
+	} else if sc.limiter.rate < minRate || sc.limiter.rate > maxRate {
+		return errors.New("rate must be between the minimum and maximum rates")
+	}
+	sc.adapter = newRateAdapter(minRate, maxRate)
+
+	return nil
+}
+
+// observeDelivery records that a probe was answered, with a response or a
+// port unreachable error, on the given attempt
+func (sc *UdpProbeScanner) observeDelivery(attempt int) {
+
+	if sc.adapter == nil {
+		return
+	}
+	if attempt > 0 {
+		atomic.AddUint64(&sc.adapter.dropped, 1)
+	} else {
+		atomic.AddUint64(&sc.adapter.answered, 1)
+	}
+}
+
+// observeLoss records a probe that was never answered, or an ICMP error other
+// than port unreachable
+func (sc *UdpProbeScanner) observeLoss() {
+
+	if sc.adapter != nil {
+		atomic.AddUint64(&sc.adapter.dropped, 1)
+	}
+}
+
+// adaptRate reconsiders the send rate every RATE_ADAPT_INTERVAL until the
+// context is canceled
+func (sc *UdpProbeScanner) adaptRate(ctx context.Context) {
+
+	ticker := time.NewTicker(RATE_ADAPT_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		// Too few probes finished to tell, such as at low rates or while
+		// waiting on timeouts, so the rate is held until more do
+		if atomic.LoadUint64(&sc.adapter.answered)+atomic.LoadUint64(&sc.adapter.dropped) < RATE_MIN_SAMPLES {
+			continue
+		}
+		answered := atomic.SwapUint64(&sc.adapter.answered, 0)
+		dropped := atomic.SwapUint64(&sc.adapter.dropped, 0)
+
+		rate := sc.limiter.currentRate()
+		newRate := sc.adapter.next(rate, float64(dropped)/float64(answered+dropped))
+
+		if newRate == rate {
+			continue
+		}
+		sc.limiter.setRate(newRate)
+
+		sc.Logger.Debug().
+			Uint64("answered", answered).
+			Uint64("dropped", dropped).
+			Float64("baseline_loss", sc.adapter.baseline).
+			Float64("old_rate", rate).
+			Float64("rate", newRate).
+			Msg("Adjusted send rate")
+	}
+}
+
+// next returns the rate to send at after an interval with the given share of
+// lost probes
+func (ra *rateAdapter) next(rate float64, loss float64) (newRate float64) {
+
+	if !ra.measured {
+		ra.baseline = loss
+		ra.measured = true
+	}
+
+	if loss > ra.baseline+RATE_DROP_THRESHOLD {
+		newRate = rate * RATE_DECREASE
+		ra.slowStart = false
+	} else {
+		ra.baseline += (loss - ra.baseline) * RATE_BASELINE_GAIN
+
+		if ra.slowStart {
+			newRate = rate * RATE_SLOW_START
+		} else {
+			newRate = rate * RATE_INCREASE
+		}
+	}
+
+	if newRate < ra.minRate {
+		newRate = ra.minRate
+	}
+	if newRate > ra.maxRate {
+		newRate = ra.maxRate
+	}
+	return
+}
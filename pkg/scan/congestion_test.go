@@ -0,0 +1,153 @@
+package scan
+
+import (
+	"testing"
+)
+
+func TestRateAdapterNext(t *testing.T) {
+
+	// Each step feeds the loss of an interval and expects the resulting rate
+	type step struct {
+		loss float64
+		rate float64
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "slow start doubles until the maximum",
+			steps: []step{
+				{loss: 0, rate: 20},
+				{loss: 0, rate: 40},
+				{loss: 0, rate: 80},
+				{loss: 0, rate: 100},
+				{loss: 0, rate: 100},
+			},
+		},
+		{
+			name: "congestion halves and ends slow start",
+			steps: []step{
+				{loss: 0, rate: 20},
+				{loss: 0.5, rate: 10},
+				{loss: 0, rate: 11},
+			},
+		},
+		{
+			name: "steady loss of silent ports is not congestion",
+			steps: []step{
+				{loss: 0.9, rate: 20},
+				{loss: 0.9, rate: 40},
+				{loss: 0.95, rate: 80},
+			},
+		},
+		{
+			name: "loss above the baseline is congestion",
+			steps: []step{
+				{loss: 0.3, rate: 20},
+				{loss: 0.45, rate: 10},
+			},
+		},
+		{
+			name: "never below the minimum",
+			steps: []step{
+				{loss: 0, rate: 20},
+				{loss: 1, rate: 10},
+				{loss: 1, rate: 10},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		adapter := newRateAdapter(10, 100)
+		rate := float64(10)
+
+		for i, step := range test.steps {
+			rate = adapter.next(rate, step.loss)
+
+			if diff := rate - step.rate; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("%s: step %d rate = %g, want %g", test.name, i+1, rate, step.rate)
+				break
+			}
+		}
+	}
+}
+
+func TestRateAdapterBaseline(t *testing.T) {
+
+	adapter := newRateAdapter(1, 1000)
+	adapter.next(10, 0.5)
+
+	if adapter.baseline != 0.5 {
+		t.Fatalf("first baseline = %g, want 0.5", adapter.baseline)
+	}
+
+	// The baseline follows loss below the congestion threshold slowly
+	adapter.next(10, 0.58)
+
+	if want := 0.5 + 0.08*RATE_BASELINE_GAIN; adapter.baseline != want {
+		t.Errorf("baseline = %g, want %g", adapter.baseline, want)
+	}
+
+	// Congestion does not move the baseline
+	baseline := adapter.baseline
+	adapter.next(10, 0.9)
+
+	if adapter.baseline != baseline {
+		t.Errorf("baseline = %g after congestion, want %g", adapter.baseline, baseline)
+	}
+}
+
+func TestSetRateBounds(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		rate    float64
+		minRate float64
+		maxRate float64
+		start   float64
+		floor   float64
+		invalid bool
+	}{
+		{name: "defaults to a tenth of the maximum", maxRate: 500, start: 50, floor: 50},
+		{name: "at least one packet per second", maxRate: 5, start: 1, floor: 1},
+		{name: "explicit minimum", minRate: 20, maxRate: 500, start: 20, floor: 20},
+		{name: "explicit starting rate", rate: 100, minRate: 20, maxRate: 500, start: 100, floor: 20},
+		{name: "minimum above maximum", minRate: 600, maxRate: 500, invalid: true},
+		{name: "minimum without maximum", minRate: 10, invalid: true},
+		{name: "starting rate out of bounds", rate: 1000, maxRate: 500, invalid: true},
+	}
+
+	for _, test := range tests {
+		sc := &UdpProbeScanner{}
+
+		if test.rate > 0 {
+			sc.SetRate(test.rate)
+		}
+		err := sc.SetRateBounds(test.minRate, test.maxRate)
+
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%s: SetRateBounds succeeded, want an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: SetRateBounds failed: %s", test.name, err)
+			continue
+		}
+		if rate := sc.limiter.currentRate(); rate != test.start {
+			t.Errorf("%s: starting rate = %g, want %g", test.name, rate, test.start)
+		}
+		if sc.adapter.minRate != test.floor {
+			t.Errorf("%s: minimum rate = %g, want %g", test.name, sc.adapter.minRate, test.floor)
+		}
+	}
+
+	sc := &UdpProbeScanner{}
+
+	if err := sc.SetRateBounds(0, 0); err != nil || sc.adapter != nil {
+		t.Errorf("SetRateBounds(0, 0) = %v with adapter %v, want adaptation disabled", err, sc.adapter)
+	}
+}
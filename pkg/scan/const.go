@@ -1,14 +1,34 @@
 package scan
 
-import "regexp"
+import (
+	"regexp"
+	"time"
+	"udpz/pkg/data"
+)
 
 var (
 	REGEX_HOSTNAME = regexp.MustCompile(`^[0-9A-Za-z_.-]{1,253}$`)
+
+	// UNKNOWN_SERVICE is reported for ports whose responses matched none of the
+	// rules of the probes sent to them
+	UNKNOWN_SERVICE = data.UdpService{
+		Slug:      "unknown",
+		Name:      "Unknown service",
+		NameShort: "Unknown",
+	}
 )
 
 const (
+	VERSION = "0.0.1-beta"
+
 	CAPTURE_SNAP_LEN   = 262144
+	MAX_RETRY_TIMEOUT  = 30 * time.Second
 	STATE_UNRESPONSIVE = 0
 	STATE_RESPONSIVE   = 1
 	STATE_CLOSED       = 2
+	STATE_UNMATCHED    = 3 // Responded, but not as the probed service
+
+	PORT_STATE_OPEN          = "OPEN"
+	PORT_STATE_OPEN_FILTERED = "OPEN|FILTERED"
+	PORT_STATE_CLOSED        = "CLOSED"
 )
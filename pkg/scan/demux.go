@@ -0,0 +1,200 @@
+package scan
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// demux hands the datagrams received on a socket shared by many probes to the
+// connection of the address they came from. Only one connection per address
+// may be open at a time, since replies could not be told apart otherwise.
+type demux struct {
+	done chan struct{}
+
+	mu    sync.Mutex
+	err   error
+	peers map[string]*demuxConn
+}
+
+// demuxMessage is a datagram, or an error such as a port unreachable message,
+// received for a connection
+type demuxMessage struct {
+	payload []byte
+	err     error
+}
+
+// demuxConn relays datagrams to a single destination over a shared socket
+type demuxConn struct {
+	demux    *demux
+	local    net.Addr
+	remote   *net.UDPAddr
+	key      string
+	match    func(response []byte) bool // Whether a datagram answers the probe, or nil for any
+	write    func(payload []byte) error
+	messages chan demuxMessage
+	unlock   func()
+	once     sync.Once
+
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func newDemux() *demux {
+	return &demux{
+		done:  make(chan struct{}),
+		peers: make(map[string]*demuxConn),
+	}
+}
+
+// demuxKey identifies the destination of a connection, or the source of a
+// received datagram
+func demuxKey(ip net.IP, port int) string {
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port))
+}
+
+// open registers a connection to remote, whose datagrams are sent with write.
+// Received datagrams match returns false for are dropped, unless it is nil.
+// unlock is called once the connection is closed.
+func (d *demux) open(local net.Addr, remote *net.UDPAddr, match func(response []byte) bool, write func(payload []byte) error, unlock func()) *demuxConn {
+
+	conn := &demuxConn{
+		demux:    d,
+		local:    local,
+		remote:   remote,
+		key:      demuxKey(remote.IP, remote.Port),
+		match:    match,
+		write:    write,
+		messages: make(chan demuxMessage, SOCKET_PEER_QUEUE),
+		unlock:   unlock,
+	}
+	d.mu.Lock()
+	d.peers[conn.key] = conn
+	d.mu.Unlock()
+
+	return conn
+}
+
+// deliver hands a datagram or error to the connection of the given source.
+// Datagrams no connection is waiting for are dropped.
+func (d *demux) deliver(source string, message demuxMessage) {
+
+	d.mu.Lock()
+	conn, ok := d.peers[source]
+	d.mu.Unlock()
+
+	if !ok || (message.err == nil && conn.match != nil && !conn.match(message.payload)) {
+		return
+	}
+	select {
+	case conn.messages <- message:
+	default:
+	}
+}
+
+// closed reports whether the shared socket has been closed
+func (d *demux) closed() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close fails reads of every connection with err. It returns false if the
+// demux was already closed.
+func (d *demux) close(err error) bool {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.err != nil {
+		return false
+	}
+	d.err = err
+	close(d.done)
+
+	return true
+}
+
+func (c *demuxConn) Write(payload []byte) (int, error) {
+
+	if err := c.write(payload); err != nil {
+		return 0, err
+	}
+	return len(payload), nil
+}
+
+// Read waits for the next datagram from the destination
+func (c *demuxConn) Read(buffer []byte) (int, error) {
+
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var expired <-chan time.Time
+
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case message := <-c.messages:
+		if message.err != nil {
+			return 0, message.err
+		}
+		return copy(buffer, message.payload), nil
+	case <-c.demux.done:
+		return 0, c.demux.err
+	case <-expired:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+// LocalAddr returns the local address of the shared socket
+func (c *demuxConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the destination of the relayed datagrams
+func (c *demuxConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *demuxConn) SetDeadline(deadline time.Time) error {
+	return c.SetReadDeadline(deadline)
+}
+
+func (c *demuxConn) SetReadDeadline(deadline time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = deadline
+	return nil
+}
+
+// SetWriteDeadline does nothing, since writes to the shared socket do not
+// block
+func (c *demuxConn) SetWriteDeadline(deadline time.Time) error {
+	return nil
+}
+
+// Close stops delivering datagrams from the destination, leaving the shared
+// socket open for other connections
+func (c *demuxConn) Close() error {
+
+	c.once.Do(func() {
+		c.demux.mu.Lock()
+		if c.demux.peers[c.key] == c {
+			delete(c.demux.peers, c.key)
+		}
+		c.demux.mu.Unlock()
+		c.unlock()
+	})
+	return nil
+}
@@ -0,0 +1,93 @@
+package scan
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDemux(t *testing.T) {
+
+	d := newDemux()
+	remote := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53}
+	other := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 123}
+
+	var written []byte
+	unlocked := 0
+
+	conn := d.open(nil, remote, nil, func(payload []byte) error {
+		written = payload
+		return nil
+	}, func() { unlocked++ })
+
+	if n, err := conn.Write([]byte("probe")); err != nil || n != 5 || string(written) != "probe" {
+		t.Errorf("Write = %d, %v with %q written, want 5, nil with \"probe\"", n, err, written)
+	}
+
+	// Datagrams from other sources are dropped
+	d.deliver(demuxKey(other.IP, other.Port), demuxMessage{payload: []byte("other")})
+	d.deliver(demuxKey(remote.IP, remote.Port), demuxMessage{payload: []byte("reply")})
+
+	buffer := make([]byte, 0x10)
+
+	if n, err := conn.Read(buffer); err != nil || string(buffer[:n]) != "reply" {
+		t.Errorf("Read = %q, %v, want \"reply\", nil", buffer[:n], err)
+	}
+
+	d.deliver(demuxKey(remote.IP, remote.Port), demuxMessage{err: syscall.ECONNREFUSED})
+
+	if _, err := conn.Read(buffer); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Read error = %v, want %v", err, syscall.ECONNREFUSED)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+
+	if _, err := conn.Read(buffer); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read error = %v after the deadline, want %v", err, os.ErrDeadlineExceeded)
+	}
+
+	conn.Close()
+	conn.Close()
+
+	if unlocked != 1 {
+		t.Errorf("unlocked %d times after closing twice, want once", unlocked)
+	}
+	if _, ok := d.peers[conn.key]; ok {
+		t.Errorf("closed connection still registered")
+	}
+
+	// Datagrams that do not answer the probe are dropped, but errors are not
+	conn = d.open(nil, remote, func(response []byte) bool {
+		return string(response) == "answer"
+	}, nil, func() {})
+
+	d.deliver(demuxKey(remote.IP, remote.Port), demuxMessage{payload: []byte("late")})
+	d.deliver(demuxKey(remote.IP, remote.Port), demuxMessage{payload: []byte("answer")})
+
+	if n, err := conn.Read(buffer); err != nil || string(buffer[:n]) != "answer" {
+		t.Errorf("Read = %q, %v, want \"answer\", nil", buffer[:n], err)
+	}
+	d.deliver(demuxKey(remote.IP, remote.Port), demuxMessage{err: syscall.ECONNREFUSED})
+
+	if _, err := conn.Read(buffer); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Read error = %v with a matcher, want %v", err, syscall.ECONNREFUSED)
+	}
+	conn.Close()
+
+	// Closing the demux fails reads of open connections
+	conn = d.open(nil, remote, nil, nil, func() {})
+	closeErr := errors.New("socket closed")
+
+	if !d.close(closeErr) || d.close(errors.New("again")) {
+		t.Errorf("close did not report the first close only")
+	}
+	if _, err := conn.Read(buffer); err != closeErr {
+		t.Errorf("Read error = %v after close, want %v", err, closeErr)
+	}
+	if !d.closed() {
+		t.Errorf("closed = false after close")
+	}
+}
@@ -0,0 +1,267 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CHANGE_OPENED  = "OPENED"
+	CHANGE_CLOSED  = "CLOSED"
+	CHANGE_CHANGED = "CHANGED"
+)
+
+// PortChange describes how a port differs between two scans
+type PortChange struct {
+	Change     string `yaml:"change" json:"change"`
+	Host       string `yaml:"host" json:"host"`
+	Hostname   string `yaml:"hostname,omitempty" json:"hostname,omitempty"` // Host name the host was resolved from
+	Port       uint16 `yaml:"port" json:"port"`
+	OldState   string `yaml:"old_state,omitempty" json:"old_state,omitempty"`
+	NewState   string `yaml:"new_state,omitempty" json:"new_state,omitempty"`
+	OldService string `yaml:"old_service,omitempty" json:"old_service,omitempty"`
+	NewService string `yaml:"new_service,omitempty" json:"new_service,omitempty"`
+	OldVersion string `yaml:"old_version,omitempty" json:"old_version,omitempty"`
+	NewVersion string `yaml:"new_version,omitempty" json:"new_version,omitempty"`
+}
+
+// portSummary is the state of a port condensed from all of its results
+type portSummary struct {
+	state    string
+	service  string
+	version  string
+	hostname string
+}
+
+// LoadResults reads results saved as JSON, JSON lines or YAML, as plain
+// results or as reports with host summaries. Files appended to by several
+// scans hold the results of all of them.
+func LoadResults(path string) (results []Result, err error) {
+
+	var content []byte
+
+	if content, err = os.ReadFile(path); err != nil {
+		return
+	}
+	trimmed := bytes.TrimSpace(content)
+
+	switch {
+	case len(trimmed) == 0:
+		return nil, nil
+
+	// Appending to a JSON output file adds another array, or another report
+	// with host summaries, for every scan
+	case trimmed[0] == '[' || (trimmed[0] == '{' && isReport(trimmed)):
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+
+		for {
+			var value json.RawMessage
+			var scanResults []Result
+
+			if err = decoder.Decode(&value); err == io.EOF {
+				return results, nil
+			} else if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if value[0] == '[' {
+				err = json.Unmarshal(value, &scanResults)
+			} else {
+				var report ScanReport
+
+				err = json.Unmarshal(value, &report)
+				scanResults = report.Results
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			results = append(results, scanResults...)
+		}
+
+	case trimmed[0] == '{':
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		scanner.Buffer(make([]byte, 0x10000), 0x1000000)
+
+		for line := 1; scanner.Scan(); line++ {
+			var result Result
+
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			if err = json.Unmarshal(scanner.Bytes(), &result); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			results = append(results, result)
+		}
+		err = scanner.Err()
+
+	// Reports start a YAML document of their own, so that appending another
+	// one does not repeat the keys of the first
+	default:
+		decoder := yaml.NewDecoder(bytes.NewReader(trimmed))
+
+		for {
+			var document yaml.Node
+			var scanResults []Result
+
+			if err = decoder.Decode(&document); err == io.EOF {
+				return results, nil
+			} else if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if len(document.Content) > 0 && document.Content[0].Kind == yaml.MappingNode {
+				var report ScanReport
+
+				err = document.Decode(&report)
+				scanResults = report.Results
+			} else {
+				err = document.Decode(&scanResults)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			results = append(results, scanResults...)
+		}
+	}
+	return
+}
+
+// isReport tells whether JSON starting with an object starts with a report
+// with host summaries rather than results written one per line
+func isReport(content []byte) bool {
+
+	var report map[string]json.RawMessage
+
+	if json.NewDecoder(bytes.NewReader(content)).Decode(&report) != nil {
+		return false
+	}
+	_, ok := report["results"]
+	return ok
+}
+
+// summarizePorts groups results by host and port. A port counts as open if any
+// of its results is open, and only open services are kept.
+func summarizePorts(results []Result) map[string]map[uint16]portSummary {
+
+	grouped := make(map[string]map[uint16][]Result)
+
+	for _, result := range results {
+		if _, ok := grouped[result.Host.Host]; !ok {
+			grouped[result.Host.Host] = make(map[uint16][]Result)
+		}
+		grouped[result.Host.Host][result.Port] = append(grouped[result.Host.Host][result.Port], result)
+	}
+
+	summaries := make(map[string]map[uint16]portSummary)
+
+	for host, ports := range grouped {
+		summaries[host] = make(map[uint16]portSummary)
+
+		for port, portResults := range ports {
+			summary := portSummary{state: portResults[0].State, hostname: portResults[0].Host.Hostname()}
+			var open []Result
+			services := make(map[string]bool)
+
+			for _, result := range portResults {
+				if result.State == PORT_STATE_OPEN {
+					open = append(open, result)
+					services[result.Service.Slug] = true
+				}
+			}
+			if len(open) > 0 {
+				names := make([]string, 0, len(services))
+
+				for name := range services {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+
+				summary.state = PORT_STATE_OPEN
+				summary.service = strings.Join(names, ",")
+				summary.version = versionSummary(open)
+			}
+			summaries[host][port] = summary
+		}
+	}
+	return summaries
+}
+
+// DiffResults reports ports that were opened or closed between two scans, and
+// open ports whose service or version changed. Changes are sorted by host and
+// port.
+func DiffResults(oldResults []Result, newResults []Result) (changes []PortChange) {
+
+	oldPorts := summarizePorts(oldResults)
+	newPorts := summarizePorts(newResults)
+
+	hosts := make(map[string]bool)
+
+	for host := range oldPorts {
+		hosts[host] = true
+	}
+	for host := range newPorts {
+		hosts[host] = true
+	}
+
+	for host := range hosts {
+		ports := make(map[uint16]bool)
+
+		for port := range oldPorts[host] {
+			ports[port] = true
+		}
+		for port := range newPorts[host] {
+			ports[port] = true
+		}
+
+		for port := range ports {
+			oldPort, hadPort := oldPorts[host][port]
+			newPort, hasPort := newPorts[host][port]
+			wasOpen := hadPort && oldPort.state == PORT_STATE_OPEN
+			isOpen := hasPort && newPort.state == PORT_STATE_OPEN
+
+			hostname := newPort.hostname
+
+			if hostname == "" {
+				hostname = oldPort.hostname
+			}
+			change := PortChange{
+				Host:       host,
+				Hostname:   hostname,
+				Port:       port,
+				OldState:   oldPort.state,
+				NewState:   newPort.state,
+				OldService: oldPort.service,
+				NewService: newPort.service,
+				OldVersion: oldPort.version,
+				NewVersion: newPort.version,
+			}
+
+			switch {
+			case isOpen && !wasOpen:
+				change.Change = CHANGE_OPENED
+			case wasOpen && !isOpen:
+				change.Change = CHANGE_CLOSED
+			case isOpen && (oldPort.service != newPort.service || oldPort.version != newPort.version):
+				change.Change = CHANGE_CHANGED
+			default:
+				continue
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Host != changes[j].Host {
+			return changes[i].Host < changes[j].Host
+		}
+		return changes[i].Port < changes[j].Port
+	})
+	return
+}
@@ -0,0 +1,160 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"udpz/pkg/data"
+)
+
+// diffResult builds a result for DiffResults, which only looks at the host,
+// port, state, service and version
+func diffResult(host string, port uint16, state string, service string, version string) Result {
+	return Result{
+		Host:    Host{Host: host},
+		Port:    port,
+		State:   state,
+		Service: data.UdpService{Slug: service},
+		Version: version,
+	}
+}
+
+func TestDiffResults(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		oldResults []Result
+		newResults []Result
+		changes    []PortChange
+	}{
+		{
+			name:       "unchanged",
+			oldResults: []Result{diffResult("a", 53, PORT_STATE_OPEN, "dns", "9.18")},
+			newResults: []Result{diffResult("a", 53, PORT_STATE_OPEN, "dns", "9.18")},
+		},
+		{
+			name:       "opened",
+			oldResults: []Result{diffResult("a", 53, PORT_STATE_OPEN_FILTERED, "", "")},
+			newResults: []Result{diffResult("a", 53, PORT_STATE_OPEN, "dns", "")},
+			changes: []PortChange{
+				{Change: CHANGE_OPENED, Host: "a", Port: 53, OldState: PORT_STATE_OPEN_FILTERED, NewState: PORT_STATE_OPEN, NewService: "dns"},
+			},
+		},
+		{
+			name:       "closed and newly seen",
+			oldResults: []Result{diffResult("a", 161, PORT_STATE_OPEN, "snmp", "")},
+			newResults: []Result{diffResult("b", 123, PORT_STATE_OPEN, "ntp", "")},
+			changes: []PortChange{
+				{Change: CHANGE_CLOSED, Host: "a", Port: 161, OldState: PORT_STATE_OPEN, OldService: "snmp"},
+				{Change: CHANGE_OPENED, Host: "b", Port: 123, NewState: PORT_STATE_OPEN, NewService: "ntp"},
+			},
+		},
+		{
+			name:       "version changed",
+			oldResults: []Result{diffResult("a", 53, PORT_STATE_OPEN, "dns", "9.16")},
+			newResults: []Result{diffResult("a", 53, PORT_STATE_OPEN, "dns", "9.18")},
+			changes: []PortChange{
+				{Change: CHANGE_CHANGED, Host: "a", Port: 53, OldState: PORT_STATE_OPEN, NewState: PORT_STATE_OPEN, OldService: "dns", NewService: "dns", OldVersion: "9.16", NewVersion: "9.18"},
+			},
+		},
+		{
+			name: "one of several probes still answered",
+			oldResults: []Result{
+				diffResult("a", 5353, PORT_STATE_OPEN, "mdns", ""),
+			},
+			newResults: []Result{
+				diffResult("a", 5353, PORT_STATE_OPEN_FILTERED, "", ""),
+				diffResult("a", 5353, PORT_STATE_OPEN, "mdns", ""),
+			},
+		},
+		{
+			name:       "closed ports that stay closed",
+			oldResults: []Result{diffResult("a", 69, PORT_STATE_CLOSED, "", "")},
+			newResults: []Result{diffResult("a", 69, PORT_STATE_OPEN_FILTERED, "", "")},
+		},
+		{
+			name:       "host name kept",
+			oldResults: []Result{diffResult("a", 53, PORT_STATE_OPEN, "dns", "")},
+			newResults: []Result{{Host: Host{Host: "a", Target: Target{Type: "hostname", Target: "ns.example"}}, Port: 53, State: PORT_STATE_CLOSED}},
+			changes: []PortChange{
+				{Change: CHANGE_CLOSED, Host: "a", Hostname: "ns.example", Port: 53, OldState: PORT_STATE_OPEN, NewState: PORT_STATE_CLOSED, OldService: "dns"},
+			},
+		},
+		{
+			name:       "sorted by host and port",
+			newResults: []Result{diffResult("b", 1, PORT_STATE_OPEN, "x", ""), diffResult("a", 2, PORT_STATE_OPEN, "y", ""), diffResult("a", 1, PORT_STATE_OPEN, "z", "")},
+			changes: []PortChange{
+				{Change: CHANGE_OPENED, Host: "a", Port: 1, NewState: PORT_STATE_OPEN, NewService: "z"},
+				{Change: CHANGE_OPENED, Host: "a", Port: 2, NewState: PORT_STATE_OPEN, NewService: "y"},
+				{Change: CHANGE_OPENED, Host: "b", Port: 1, NewState: PORT_STATE_OPEN, NewService: "x"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if changes := DiffResults(test.oldResults, test.newResults); !reflect.DeepEqual(changes, test.changes) {
+			t.Errorf("%s: got %+v, want %+v", test.name, changes, test.changes)
+		}
+	}
+}
+
+func TestLoadResults(t *testing.T) {
+
+	result := `{"host":{"host":"192.0.2.1"},"port":53,"state":"OPEN","service":{"slug":"dns"}}`
+
+	tests := []struct {
+		name    string
+		content string
+		count   int
+		invalid bool
+	}{
+		{name: "empty", content: "\n", count: 0},
+		{name: "json", content: "[" + result + "," + result + "]\n", count: 2},
+		{name: "appended json", content: "[" + result + "]\n[" + result + "," + result + "]", count: 3},
+		{name: "json lines", content: result + "\n\n" + result + "\n", count: 2},
+		{name: "yaml", content: "- host:\n    host: 192.0.2.1\n  port: 53\n  state: OPEN\n", count: 1},
+		{name: "report", content: `{"hosts":[],"results":[` + result + `]}`, count: 1},
+		{name: "appended reports", content: `{"hosts":[],"results":[` + result + `]}{"hosts":[],"results":[` + result + "," + result + `]}` + "\n", count: 3},
+		{name: "report appended to json", content: "[" + result + "]\n" + `{"hosts":[],"results":[` + result + `]}`, count: 2},
+		{name: "appended yaml reports", content: "---\nhosts: []\nresults:\n  - host:\n      host: 192.0.2.1\n    port: 53\n    state: OPEN\n---\nhosts: []\nresults:\n  - host:\n      host: 192.0.2.1\n    port: 53\n    state: OPEN\n", count: 2},
+		{name: "invalid appended reports", content: `{"hosts":[],"results":[` + result + `]}{"results":`, invalid: true},
+		{name: "invalid json", content: "[" + result, invalid: true},
+		{name: "invalid json lines", content: result + "\n{", invalid: true},
+	}
+
+	directory := t.TempDir()
+
+	for _, test := range tests {
+		path := filepath.Join(directory, test.name)
+
+		if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		results, err := LoadResults(path)
+
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%s: LoadResults succeeded, want an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: LoadResults failed: %s", test.name, err)
+			continue
+		}
+		if len(results) != test.count {
+			t.Errorf("%s: got %d results, want %d", test.name, len(results), test.count)
+		}
+		for _, loaded := range results {
+			if loaded.Host.Host != "192.0.2.1" || loaded.Port != 53 || loaded.State != PORT_STATE_OPEN {
+				t.Errorf("%s: got %+v", test.name, loaded)
+			}
+		}
+	}
+
+	if _, err := LoadResults(filepath.Join(directory, "missing")); !os.IsNotExist(err) {
+		t.Errorf("LoadResults of a missing file = %v, want a not exist error", err)
+	}
+}
@@ -0,0 +1,258 @@
+package scan
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"udpz/pkg/data"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	DISCOVERY_ICMP = "icmp" // Discover hosts with ICMP echo requests
+)
+
+// discoveryProbe is a UDP probe sent to find out whether a host is alive
+type discoveryProbe struct {
+	service string
+	port    uint16
+	payload []byte
+}
+
+// SetDiscovery checks that each host is alive before sending it the full set
+// of probes, which saves most of the scan time on sparsely populated networks.
+// Each method is either "icmp" for ICMP echo requests, or the name of a service
+// such as "dns" or "ntp" whose first probe is sent to its ports. A host is
+// alive once it answers any of them, including with a port unreachable error.
+// No methods disables discovery, so every host is scanned.
+func (sc *UdpProbeScanner) SetDiscovery(methods []string) error {
+
+	sc.discoveryPing = false
+	sc.discoveryProbes = nil
+
+	for _, method := range methods {
+		method = strings.ToLower(strings.TrimSpace(method))
+
+		if method == DISCOVERY_ICMP {
+			if sc.useProxy {
+				return errors.New("ICMP discovery cannot be relayed through a proxy")
+			}
+			conn, _, err := sc.listenEcho(net.IPv4zero)
+
+			if err != nil {
+				return errors.New("ICMP discovery needs raw sockets or unprivileged ping sockets: " + err.Error())
+			}
+			conn.Close()
+			sc.discoveryPing = true
+			continue
+		}
+
+		service, ok := data.LookupService(sc.Services, method)
+		if !ok {
+			service, ok = data.LookupService(data.UDP_SERVICES, method)
+		}
+		if !ok || len(service.Probes) == 0 {
+			return errors.New("unknown discovery method: " + method)
+		}
+		payload, err := base64.StdEncoding.DecodeString(service.Probes[0].EncodedData)
+
+		if err != nil {
+			return err
+		}
+		for _, port := range service.Ports {
+			sc.discoveryProbes = append(sc.discoveryProbes, discoveryProbe{method, port, payload})
+		}
+	}
+
+	if sc.discoveryEnabled() {
+		sc.Logger.Debug().
+			Strs("methods", methods).
+			Msg("Using host discovery")
+	}
+	return nil
+}
+
+// discoveryEnabled reports whether hosts are checked before being scanned
+func (sc *UdpProbeScanner) discoveryEnabled() bool {
+	return sc.discoveryPing || len(sc.discoveryProbes) > 0
+}
+
+// discover reports whether the host answers any of the discovery methods,
+// trying them all at once and stopping at the first answer
+func (sc *UdpProbeScanner) discover(ctx context.Context, host Host) bool {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	count := len(sc.discoveryProbes)
+	alive := make(chan bool, count+1)
+
+	for _, probe := range sc.discoveryProbes {
+		go func(probe discoveryProbe) {
+			alive <- sc.discoverUDP(ctx, host, probe)
+		}(probe)
+	}
+	if sc.discoveryPing {
+		count++
+		go func() {
+			alive <- sc.ping(ctx, host)
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		if <-alive {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverUDP sends a discovery probe and reports whether the host answered it
+// with either a response or a port unreachable error
+func (sc *UdpProbeScanner) discoverUDP(ctx context.Context, host Host, probe discoveryProbe) bool {
+
+	for i := 0; i <= int(sc.hostRetransmissions(host)) && ctx.Err() == nil; i++ {
+
+		_, err := sc.scanTask(ctx, host, probe.port, probe.service, probe.payload, nil, sc.retryTimeout(host, nil, i), nil)
+
+		if err == nil || strings.Contains(err.Error(), "connection refused") || sc.isUnreachable(host.ip, probe.port) {
+			sc.Logger.Debug().
+				Str("host", host.Host).
+				Str("service", probe.service).
+				Uint16("port", probe.port).
+				Msg("Host answered discovery probe")
+			return true
+		}
+	}
+	return false
+}
+
+// ping sends ICMP echo requests to the host and reports whether it replied
+func (sc *UdpProbeScanner) ping(ctx context.Context, host Host) bool {
+
+	for i := 0; i <= int(sc.hostRetransmissions(host)) && ctx.Err() == nil; i++ {
+
+		if sc.pace(ctx, host) != nil {
+			return false
+		}
+		replied, err := sc.echo(ctx, host, sc.retryTimeout(host, nil, i))
+
+		if err != nil {
+			sc.Logger.Debug().
+				Err(err).
+				Str("host", host.Host).
+				Msg("Could not send ICMP echo request")
+			return false
+		}
+		if replied {
+			sc.Logger.Debug().
+				Str("host", host.Host).
+				Msg("Host answered ICMP echo request")
+			return true
+		}
+	}
+	return false
+}
+
+// echo sends a single ICMP echo request and waits up to timeout for the reply
+func (sc *UdpProbeScanner) echo(ctx context.Context, host Host, timeout time.Duration) (bool, error) {
+
+	conn, privileged, err := sc.listenEcho(host.ip)
+
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Closing the socket interrupts the read when the context is canceled
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	id, seq := os.Getpid()&0xffff, rand.Intn(0x10000)
+
+	message := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("udpz")},
+	}
+	replyType, protocol := icmp.Type(ipv4.ICMPTypeEchoReply), ICMP_PROTOCOL_V4
+
+	if host.ip.To4() == nil {
+		message.Type = ipv6.ICMPTypeEchoRequest
+		replyType, protocol = ipv6.ICMPTypeEchoReply, ICMP_PROTOCOL_V6
+	}
+	request, err := message.Marshal(nil)
+
+	if err != nil {
+		return false, err
+	}
+	var destination net.Addr = &net.UDPAddr{IP: host.ip, Zone: host.zone}
+
+	if privileged {
+		destination = &net.IPAddr{IP: host.ip, Zone: host.zone}
+	}
+	if _, err = conn.WriteTo(request, destination); err != nil {
+		return false, err
+	}
+	atomic.AddUint64(&sc.counters.packetsSent, 1)
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buffer := make([]byte, 1500)
+
+	for {
+		readLen, peer, err := conn.ReadFrom(buffer)
+
+		if err != nil {
+			return false, nil
+		}
+		reply, err := icmp.ParseMessage(protocol, buffer[:readLen])
+
+		if err != nil || reply.Type != replyType || !peerIP(peer).Equal(host.ip) {
+			continue
+		}
+		// Unprivileged ping sockets replace the identifier with their own
+		if body, ok := reply.Body.(*icmp.Echo); ok && body.Seq == seq && (body.ID == id || !privileged) {
+			return true, nil
+		}
+	}
+}
+
+// listenEcho opens a socket to send ICMP echo requests to ip from, preferring
+// unprivileged ping sockets and falling back to raw sockets
+func (sc *UdpProbeScanner) listenEcho(ip net.IP) (conn *icmp.PacketConn, privileged bool, err error) {
+
+	networks := []string{"udp4", "ip4:icmp"}
+	address := "0.0.0.0"
+
+	if ip.To4() == nil {
+		networks = []string{"udp6", "ip6:ipv6-icmp"}
+		address = "::"
+	}
+	if source := sc.sourceFor(ip); source != nil {
+		address = source.String()
+	}
+
+	for _, network := range networks {
+		if conn, err = icmp.ListenPacket(network, address); err == nil {
+			return conn, strings.HasPrefix(network, "ip"), nil
+		}
+	}
+	return nil, false, err
+}
@@ -0,0 +1,233 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ELASTIC_BATCH_SIZE = 500
+	ELASTIC_TIMEOUT    = 30 * time.Second
+)
+
+// ElasticScan describes the scan that produced the indexed documents
+type ElasticScan struct {
+	ID      string    `json:"id"`
+	Started time.Time `json:"started"`
+	Command string    `json:"command,omitempty"`
+	Version string    `json:"version"`
+}
+
+// elasticDocument is the document indexed for each open port
+type elasticDocument struct {
+	Timestamp   time.Time         `json:"@timestamp"`
+	Scan        ElasticScan       `json:"scan"`
+	Host        string            `json:"host"`
+	HostType    string            `json:"host_type"`
+	Target      string            `json:"target"`
+	Port        uint16            `json:"port"`
+	Transport   string            `json:"transport"`
+	State       string            `json:"state"`
+	Service     string            `json:"service"`
+	ServiceName string            `json:"service_name"`
+	Tags        []string          `json:"tags,omitempty"`
+	Probe       string            `json:"probe,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	Banner      string            `json:"banner,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CPE         []string          `json:"cpe,omitempty"`
+}
+
+// elasticChange is the document indexed for each port that changed between
+// two scans when watching
+type elasticChange struct {
+	Timestamp  time.Time   `json:"@timestamp"`
+	Scan       ElasticScan `json:"scan"`
+	Change     string      `json:"change"`
+	Host       string      `json:"host"`
+	Port       uint16      `json:"port"`
+	OldState   string      `json:"old_state,omitempty"`
+	NewState   string      `json:"new_state,omitempty"`
+	OldService string      `json:"old_service,omitempty"`
+	NewService string      `json:"new_service,omitempty"`
+	OldVersion string      `json:"old_version,omitempty"`
+	NewVersion string      `json:"new_version,omitempty"`
+}
+
+// ElasticSink bulk-indexes open ports, or the ports that changed when
+// watching, into an Elasticsearch or OpenSearch index. Documents are buffered and sent in batches, and the remainder is sent
+// when the scan finishes.
+type ElasticSink struct {
+	mu       sync.Mutex
+	url      string
+	index    string
+	username string
+	password string
+	scan     ElasticScan
+	client   *http.Client
+	pending  bytes.Buffer
+	count    int
+}
+
+// NewElasticSink creates a sink indexing into index on the cluster at url
+func NewElasticSink(url string, index string, username string, password string, scan ElasticScan) *ElasticSink {
+	return &ElasticSink{
+		url:      strings.TrimRight(url, "/"),
+		index:    index,
+		username: username,
+		password: password,
+		scan:     scan,
+		client:   &http.Client{Timeout: ELASTIC_TIMEOUT},
+	}
+}
+
+func (es *ElasticSink) WriteResult(result Result) error {
+
+	if result.State != PORT_STATE_OPEN {
+		return nil
+	}
+	// Documents are keyed by scan, host, port and service so that resending a
+	// batch does not create duplicates
+	id := fmt.Sprintf("%s-%s-%d-%s", es.scan.ID, result.Host.Host, result.Port, result.Service.Slug)
+
+	return es.add(id, elasticDocument{
+		Timestamp:   time.Now().UTC(),
+		Scan:        es.scan,
+		Host:        result.Host.Host,
+		HostType:    result.Host.Type,
+		Target:      result.Host.Target.Target,
+		Port:        result.Port,
+		Transport:   result.Transport,
+		State:       result.State,
+		Service:     result.Service.Slug,
+		ServiceName: result.Service.Name,
+		Tags:        result.Service.Tags,
+		Probe:       result.Probe.Slug,
+		Version:     result.Version,
+		Banner:      result.Banner,
+		Metadata:    result.Metadata,
+		CPE:         result.CPE,
+	})
+}
+
+// WriteChange indexes a port that changed since the previous scan. Each change
+// is its own document, so the index holds the history of every port.
+func (es *ElasticSink) WriteChange(change PortChange) error {
+
+	timestamp := time.Now().UTC()
+	id := fmt.Sprintf("%s-%s-%d-%d", es.scan.ID, change.Host, change.Port, timestamp.UnixNano())
+
+	return es.add(id, elasticChange{
+		Timestamp:  timestamp,
+		Scan:       es.scan,
+		Change:     change.Change,
+		Host:       change.Host,
+		Port:       change.Port,
+		OldState:   change.OldState,
+		NewState:   change.NewState,
+		OldService: change.OldService,
+		NewService: change.NewService,
+		OldVersion: change.OldVersion,
+		NewVersion: change.NewVersion,
+	})
+}
+
+// add buffers a document, sending the batch once it is full
+func (es *ElasticSink) add(id string, document interface{}) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	action := map[string]map[string]string{
+		"index": {
+			"_index": es.index,
+			"_id":    id,
+		},
+	}
+	encoder := json.NewEncoder(&es.pending)
+
+	if err := encoder.Encode(action); err != nil {
+		return err
+	}
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	es.count++
+
+	if es.count >= ELASTIC_BATCH_SIZE {
+		return es.send()
+	}
+	return nil
+}
+
+// Flush indexes any buffered documents
+func (es *ElasticSink) Flush() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	return es.send()
+}
+
+// send posts the buffered documents to the bulk API and reports the first
+// document the cluster rejected
+func (es *ElasticSink) send() error {
+
+	if es.count == 0 {
+		return nil
+	}
+	defer func() {
+		es.pending.Reset()
+		es.count = 0
+	}()
+
+	request, err := http.NewRequest(http.MethodPost, es.url+"/_bulk", bytes.NewReader(es.pending.Bytes()))
+
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-ndjson")
+
+	if es.username != "" {
+		request.SetBasicAuth(es.username, es.password)
+	}
+	response, err := es.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("bulk request failed: %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	var reply struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(body, &reply); err != nil {
+		return fmt.Errorf("invalid bulk response: %w", err)
+	}
+	if reply.Errors {
+		for _, item := range reply.Items {
+			for _, status := range item {
+				if len(status.Error) > 0 {
+					return fmt.Errorf("document rejected: %s", status.Error)
+				}
+			}
+		}
+	}
+	return nil
+}
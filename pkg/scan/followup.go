@@ -0,0 +1,121 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"udpz/pkg/data"
+	"udpz/pkg/proto"
+)
+
+const (
+	FOLLOW_UP_BUFFER    = 0x10000 // Size of the buffer datagrams of a follow-up exchange are read into
+	FOLLOW_UP_DATAGRAMS = 16      // Most datagrams read over a follow-up exchange
+	FOLLOW_UP_LIMIT     = 0x40000 // Most bytes read over a follow-up exchange
+)
+
+// responseBuffer returns the buffer the first response to a probe of the
+// service is read into. Services with a follow-up exchange answer with larger
+// datagrams, such as ones carrying certificates.
+func responseBuffer(service string) []byte {
+
+	if _, ok := proto.FOLLOW_UPS[service]; ok {
+		return make([]byte, FOLLOW_UP_BUFFER)
+	}
+	return make([]byte, 0x400)
+}
+
+// followUp continues the exchange with a service that needs more than one
+// round trip, sending the requests its responses call for and reading until
+// the exchange is done or the timeout expires. It returns every response,
+// starting with the first.
+func (sc *UdpProbeScanner) followUp(ctx context.Context, conn net.Conn, host Host, followUp proto.FollowUp, request []byte, response []byte, timeout time.Duration) []byte {
+
+	responses := append([]byte(nil), response...)
+	buffer := make([]byte, FOLLOW_UP_BUFFER)
+
+	for datagrams := 1; datagrams < FOLLOW_UP_DATAGRAMS && len(responses) < FOLLOW_UP_LIMIT && !followUp.Done(responses); datagrams++ {
+		if next, ok := nextFollowUp(followUp, request, response); ok {
+			if err := sc.pace(ctx, host); err != nil {
+				break
+			}
+			sc.Logger.Trace().
+				Str("type", "connection.write").
+				Str("address", conn.RemoteAddr().String()).
+				Bytes("data", next).
+				Msg("(net.Conn).Write(data)")
+
+			if _, err := conn.Write(next); err != nil {
+				break
+			}
+			atomic.AddUint64(&sc.counters.packetsSent, 1)
+			sc.recordProbe(conn, host.ip, next)
+
+			if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+				break
+			}
+			request = next
+		}
+		readLen, ttl, err := sc.readWithTTL(conn, host.ip, buffer)
+
+		if err != nil {
+			break
+		}
+		sc.recordPacket(conn.RemoteAddr(), conn.LocalAddr(), buffer[:readLen], ttl)
+
+		response = buffer[:readLen]
+		responses = append(responses, response...)
+	}
+	return responses
+}
+
+// nextFollowUp returns the request answering the latest response, if the
+// exchange has requests to send after the first
+func nextFollowUp(followUp proto.FollowUp, request []byte, response []byte) ([]byte, bool) {
+
+	if followUp.Next == nil {
+		return nil, false
+	}
+	return followUp.Next(request, response)
+}
+
+// sequenceFollowUp returns the exchange that sends the steps of a probe in
+// turn, each built from the latest response, and ends once the last one is
+// answered or a response lacks a field a step needs. Fields extracted by a
+// step remain available to the later ones.
+func sequenceFollowUp(steps []data.UdpStep) proto.FollowUp {
+
+	var (
+		step    int
+		seen    int
+		pending []byte
+		values  = make(map[string][]byte)
+	)
+	return proto.FollowUp{
+		Next: func(request []byte, response []byte) ([]byte, bool) {
+			next := pending
+			pending = nil
+
+			return next, next != nil
+		},
+		Done: func(responses []byte) bool {
+			latest := responses[seen:]
+			seen = len(responses)
+
+			if step >= len(steps) {
+				return true
+			}
+			next, ok := steps[step].Request(latest, values)
+
+			if !ok {
+				return true
+			}
+			pending = next
+			step++
+
+			return false
+		},
+	}
+}
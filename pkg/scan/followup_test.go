@@ -0,0 +1,40 @@
+package scan
+
+import (
+	"testing"
+
+	"udpz/pkg/data"
+)
+
+func TestSequenceFollowUp(t *testing.T) {
+
+	followUp := sequenceFollowUp([]data.UdpStep{
+		{Extract: []data.UdpExtract{{Name: "block", Offset: 2, Length: 2}}, Template: "0004{{block}}"},
+		{Extract: []data.UdpExtract{{Name: "block", Offset: 2, Length: 2}}, Template: "0004{{block}}"},
+	})
+	responses := []byte("\x00\x03\x00\x01one")
+	want := []string{"\x00\x04\x00\x01", "\x00\x04\x00\x02"}
+
+	for i, block := range []string{"\x00\x03\x00\x02two", "\x00\x03\x00\x03end"} {
+
+		if followUp.Done(responses) {
+			t.Fatalf("step %d: got done, want another request", i)
+		}
+		if next, ok := followUp.Next(nil, nil); !ok || string(next) != want[i] {
+			t.Errorf("step %d: got %q, %v, want %q, %v", i, next, ok, want[i], true)
+		}
+		responses = append(responses, block...)
+	}
+	if !followUp.Done(responses) {
+		t.Errorf("got not done after the last step was answered")
+	}
+	// Responses missing a field end the exchange
+	followUp = sequenceFollowUp([]data.UdpStep{{Extract: []data.UdpExtract{{Name: "block", Offset: 2, Length: 2}}, Template: "0004{{block}}"}})
+
+	if !followUp.Done([]byte("\x00")) {
+		t.Errorf("got not done for a response without the field")
+	}
+	if next, ok := followUp.Next(nil, nil); ok {
+		t.Errorf("got request %q after the exchange ended", next)
+	}
+}
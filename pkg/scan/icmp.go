@@ -0,0 +1,252 @@
+package scan
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	ICMP_PROTOCOL_V4 = 1
+	ICMP_PROTOCOL_V6 = 58
+)
+
+// startICMPListener opens raw ICMP sockets to catch port unreachable messages
+// sent in response to probes. Raw sockets usually require elevated privileges,
+// so when they cannot be opened the scanner falls back to relying on socket
+// errors reported by the operating system.
+func (sc *UdpProbeScanner) startICMPListener() {
+
+	for _, listener := range []struct {
+		network  string
+		address  string
+		protocol int
+	}{
+		{"ip4:icmp", "0.0.0.0", ICMP_PROTOCOL_V4},
+		{"ip6:ipv6-icmp", "::", ICMP_PROTOCOL_V6},
+	} {
+		if conn, err := icmp.ListenPacket(listener.network, listener.address); err == nil {
+
+			sc.Logger.Debug().
+				Str("network", listener.network).
+				Msg("Listening for ICMP port unreachable messages")
+
+			sc.icmpConns = append(sc.icmpConns, conn)
+			go sc.readICMP(conn, listener.protocol)
+
+		} else {
+			sc.Logger.Debug().
+				Err(err).
+				Str("network", listener.network).
+				Msg("Could not open ICMP listener, falling back to socket errors")
+		}
+	}
+}
+
+// stopICMPListener closes any ICMP sockets opened by startICMPListener
+func (sc *UdpProbeScanner) stopICMPListener() {
+	for _, conn := range sc.icmpConns {
+		conn.Close()
+	}
+	sc.icmpConns = nil
+}
+
+func (sc *UdpProbeScanner) readICMP(conn *icmp.PacketConn, protocol int) {
+
+	buffer := make([]byte, 1500)
+
+	for {
+		readLen, ttl, local, peer, err := sc.readICMPFrom(conn, protocol, buffer)
+
+		if err != nil {
+			return
+		}
+		message, err := icmp.ParseMessage(protocol, buffer[:readLen])
+
+		if err != nil {
+			continue
+		}
+		switch body := message.Body.(type) {
+
+		case *icmp.DstUnreach:
+			ip, port, sourcePort, ok := parseEmbeddedUDP(body.Data)
+
+			if !ok {
+				continue
+			}
+			if sc.isWatched(ip) {
+				sc.recordICMP(peerIP(peer), localIP(local, body.Data), buffer[:readLen], ttl)
+			}
+			if (message.Type == ipv4.ICMPTypeDestinationUnreachable && message.Code == 3) ||
+				(message.Type == ipv6.ICMPTypeDestinationUnreachable && message.Code == 4) {
+
+				sc.Logger.Trace().
+					Str("from", peer.String()).
+					IPAddr("ip", ip).
+					Uint16("port", port).
+					Msg("Received ICMP port unreachable")
+
+				if sc.isWatched(ip) {
+					sc.unreachable.Store(unreachableKey(ip, port), true)
+				}
+				sc.notifyHop(ip, port, sourcePort, hopReply{peerIP(peer), HOP_UNREACHABLE})
+
+			} else {
+				if sc.isWatched(ip) {
+					sc.observeLoss()
+				}
+				if isProhibited(message) {
+					sc.notifyHop(ip, port, sourcePort, hopReply{peerIP(peer), HOP_FILTERED})
+				}
+			}
+
+		case *icmp.TimeExceeded:
+			if ip, port, sourcePort, ok := parseEmbeddedUDP(body.Data); ok {
+				if sc.isWatched(ip) {
+					sc.recordICMP(peerIP(peer), localIP(local, body.Data), buffer[:readLen], ttl)
+				}
+				sc.notifyHop(ip, port, sourcePort, hopReply{peerIP(peer), HOP_TIME_EXCEEDED})
+			}
+		}
+	}
+}
+
+// readICMPFrom reads an ICMP message, along with its TTL or hop limit and the
+// local address it was sent to when packets are recorded
+func (sc *UdpProbeScanner) readICMPFrom(conn *icmp.PacketConn, protocol int, buffer []byte) (readLen int, ttl int, local net.IP, peer net.Addr, err error) {
+
+	if sc.pcap != nil {
+		if packetConn := conn.IPv4PacketConn(); protocol == ICMP_PROTOCOL_V4 && packetConn != nil {
+			if packetConn.SetControlMessage(ipv4.FlagTTL|ipv4.FlagDst, true) == nil {
+				var cm *ipv4.ControlMessage
+
+				if readLen, cm, peer, err = packetConn.ReadFrom(buffer); cm != nil {
+					ttl, local = cm.TTL, cm.Dst
+				}
+				return
+			}
+		}
+		if packetConn := conn.IPv6PacketConn(); protocol == ICMP_PROTOCOL_V6 && packetConn != nil {
+			if packetConn.SetControlMessage(ipv6.FlagHopLimit|ipv6.FlagDst, true) == nil {
+				var cm *ipv6.ControlMessage
+
+				if readLen, cm, peer, err = packetConn.ReadFrom(buffer); cm != nil {
+					ttl, local = cm.HopLimit, cm.Dst
+				}
+				return
+			}
+		}
+	}
+	readLen, peer, err = conn.ReadFrom(buffer)
+	return
+}
+
+// localIP returns the local address an ICMP error was sent to, falling back
+// to the source address of the datagram it quotes
+func localIP(local net.IP, data []byte) net.IP {
+
+	if local != nil {
+		return local
+	}
+	if len(data) >= ipv4.HeaderLen && data[0]>>4 == 4 {
+		return net.IP(data[12:16])
+	}
+	if len(data) >= ipv6.HeaderLen && data[0]>>4 == 6 {
+		return net.IP(data[8:24])
+	}
+	return nil
+}
+
+// isProhibited reports whether a destination unreachable message says the
+// traffic was administratively prohibited, as firewalls often do
+func isProhibited(message *icmp.Message) bool {
+	if message.Type == ipv4.ICMPTypeDestinationUnreachable {
+		return message.Code == 9 || message.Code == 10 || message.Code == 13
+	}
+	return message.Type == ipv6.ICMPTypeDestinationUnreachable && message.Code == 1
+}
+
+// peerIP returns the address an ICMP message came from
+func peerIP(peer net.Addr) net.IP {
+	switch addr := peer.(type) {
+	case *net.IPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	}
+	return net.ParseIP(peer.String())
+}
+
+// watchHost records ICMP port unreachable messages about ip until unwatchHost
+// is called, so only messages about hosts being scanned are kept
+func (sc *UdpProbeScanner) watchHost(ip net.IP) {
+	sc.watched.Store(ip.String(), true)
+}
+
+func (sc *UdpProbeScanner) unwatchHost(ip net.IP) {
+	sc.watched.Delete(ip.String())
+}
+
+func (sc *UdpProbeScanner) isWatched(ip net.IP) bool {
+	_, ok := sc.watched.Load(ip.String())
+	return ok
+}
+
+// resetUnreachable forgets the ICMP port unreachable messages of earlier
+// scans, which may no longer hold when the scanner is reused
+func (sc *UdpProbeScanner) resetUnreachable() {
+	for _, m := range []*sync.Map{&sc.unreachable, &sc.watched} {
+		m.Range(func(key, _ interface{}) bool {
+			m.Delete(key)
+			return true
+		})
+	}
+}
+
+// isUnreachable reports whether an ICMP port unreachable message was received
+// for the given destination
+func (sc *UdpProbeScanner) isUnreachable(ip net.IP, port uint16) bool {
+	_, ok := sc.unreachable.Load(unreachableKey(ip, port))
+	return ok
+}
+
+func unreachableKey(ip net.IP, port uint16) string {
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+}
+
+// parseEmbeddedUDP extracts the destination and source port of the original
+// UDP datagram quoted inside an ICMP error message
+func parseEmbeddedUDP(data []byte) (ip net.IP, port uint16, sourcePort uint16, ok bool) {
+
+	var udpHeader []byte
+
+	if len(data) < 1 {
+		return
+	}
+	switch data[0] >> 4 {
+	case 4:
+		headerLen := int(data[0]&0x0f) * 4
+
+		if len(data) < headerLen+4 || headerLen < ipv4.HeaderLen || data[9] != 17 {
+			return
+		}
+		ip = net.IP(data[16:20])
+		udpHeader = data[headerLen:]
+
+	case 6:
+		if len(data) < ipv6.HeaderLen+4 || data[6] != 17 {
+			return
+		}
+		ip = net.IP(data[24:40])
+		udpHeader = data[ipv6.HeaderLen:]
+
+	default:
+		return
+	}
+	return ip, binary.BigEndian.Uint16(udpHeader[2:4]), binary.BigEndian.Uint16(udpHeader[0:2]), true
+}
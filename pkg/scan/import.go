@@ -0,0 +1,125 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+)
+
+// ImportedTargets are the live hosts of a previous scan, and the UDP ports
+// found open or open|filtered on any of them
+type ImportedTargets struct {
+	Hosts []string
+	Ports map[uint16]bool
+}
+
+type importNmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   uint16 `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+type importMasscanRecord struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port   uint16 `json:"port"`
+		Proto  string `json:"proto"`
+		Status string `json:"status"`
+	} `json:"ports"`
+}
+
+// masscanTrailingComma matches the comma masscan leaves after the last record
+// of its JSON output
+var masscanTrailingComma = regexp.MustCompile(`,\s*\]\s*$`)
+
+// add adds a host, keeping the order hosts were first seen in
+func (it *ImportedTargets) add(host string, seen map[string]bool) {
+	if !seen[host] {
+		seen[host] = true
+		it.Hosts = append(it.Hosts, host)
+	}
+}
+
+// ImportTargets reads the live hosts and their open or open|filtered UDP ports
+// from the nmap XML (-oX) or masscan JSON (-oJ) output of a previous scan, so
+// that a fast host sweep can feed the scan
+func ImportTargets(reader io.Reader) (imported ImportedTargets, err error) {
+
+	content, err := io.ReadAll(reader)
+
+	if err != nil {
+		return
+	}
+	content = bytes.TrimSpace(content)
+	imported.Ports = map[uint16]bool{}
+	seen := map[string]bool{}
+
+	switch {
+	case bytes.HasPrefix(content, []byte("<")):
+
+		var run importNmapRun
+
+		if err = xml.Unmarshal(content, &run); err != nil {
+			return imported, errors.New("invalid nmap XML: " + err.Error())
+		}
+		for _, host := range run.Hosts {
+
+			if host.Status.State != "up" {
+				continue
+			}
+			for _, address := range host.Addresses {
+
+				if address.AddrType != "ipv4" && address.AddrType != "ipv6" {
+					continue
+				}
+				imported.add(address.Addr, seen)
+
+				for _, port := range host.Ports {
+					if port.Protocol == "udp" && (port.State.State == "open" || port.State.State == "open|filtered") {
+						imported.Ports[port.PortID] = true
+					}
+				}
+			}
+		}
+
+	case bytes.HasPrefix(content, []byte("[")):
+
+		var records []importMasscanRecord
+
+		content = masscanTrailingComma.ReplaceAll(content, []byte("]"))
+
+		if err = json.Unmarshal(content, &records); err != nil {
+			return imported, errors.New("invalid masscan JSON: " + err.Error())
+		}
+		for _, record := range records {
+
+			imported.add(record.IP, seen)
+
+			for _, port := range record.Ports {
+				if port.Proto == "udp" && port.Status == "open" {
+					imported.Ports[port.Port] = true
+				}
+			}
+		}
+
+	default:
+		return imported, errors.New("unrecognized scan results, expected nmap XML or masscan JSON")
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package scan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportTargets(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		input string
+		hosts []string
+		ports []uint16
+		ok    bool
+	}{
+		{
+			"nmap",
+			`<?xml version="1.0"?>
+<nmaprun scanner="nmap">
+<host><status state="up"/><address addr="10.0.0.1" addrtype="ipv4"/><address addr="00:11:22:33:44:55" addrtype="mac"/>
+<ports><port protocol="udp" portid="161"><state state="open"/></port><port protocol="udp" portid="500"><state state="open|filtered"/></port><port protocol="udp" portid="53"><state state="closed"/></port><port protocol="tcp" portid="22"><state state="open"/></port></ports></host>
+<host><status state="down"/><address addr="10.0.0.2" addrtype="ipv4"/></host>
+<host><status state="up"/><address addr="2001:db8::1" addrtype="ipv6"/></host>
+</nmaprun>`,
+			[]string{"10.0.0.1", "2001:db8::1"},
+			[]uint16{161, 500},
+			true,
+		},
+		{
+			"masscan",
+			`[
+{   "ip": "10.0.0.1",   "timestamp": "1700000000", "ports": [ {"port": 161, "proto": "udp", "status": "open", "reason": "none", "ttl": 64} ] }
+,
+{   "ip": "10.0.0.2",   "timestamp": "1700000000", "ports": [ {"port": 80, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] }
+,
+{   "ip": "10.0.0.1",   "timestamp": "1700000000", "ports": [ {"port": 161, "proto": "udp", "service": {"name": "snmp", "banner": "x"} } ] }
+,
+]`,
+			[]string{"10.0.0.1", "10.0.0.2"},
+			[]uint16{161},
+			true,
+		},
+		{"list", "10.0.0.1\n10.0.0.2\n", nil, nil, false},
+		{"truncated", "<nmaprun><host>", nil, nil, false},
+	}
+	for _, test := range tests {
+
+		imported, err := ImportTargets(strings.NewReader(test.input))
+
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got error %v, want ok %v", test.name, err, test.ok)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		ports := map[uint16]bool{}
+
+		for _, port := range test.ports {
+			ports[port] = true
+		}
+		if !reflect.DeepEqual(imported.Hosts, test.hosts) || !reflect.DeepEqual(imported.Ports, ports) {
+			t.Errorf("%s: got %+v, want hosts %v and ports %v", test.name, imported, test.hosts, test.ports)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hostLookup is the lookup of a host name, which is done once however many
+// targets name the host
+type hostLookup struct {
+	name string
+	done chan struct{}
+	ips  []net.IP
+	err  error
+}
+
+// lookupCache resolves the host names of the targets of a scan with a
+// bounded pool of workers, ahead of the scan reaching them, and keeps their
+// addresses for the rest of the scan, so that hosts are not held up by the
+// lookups of the targets listed before them
+type lookupCache struct {
+	resolver *hostResolver
+	network  string
+
+	mu      sync.Mutex
+	lookups map[string]*hostLookup
+}
+
+func newLookupCache(resolver *hostResolver, network string) *lookupCache {
+	return &lookupCache{
+		resolver: resolver,
+		network:  network,
+		lookups:  make(map[string]*hostLookup),
+	}
+}
+
+// lookup returns the lookup of a host name, and whether it was just created,
+// in which case the caller runs it
+func (c *lookupCache) lookup(name string) (lookup *hostLookup, created bool) {
+
+	key := strings.ToLower(strings.TrimSuffix(name, "."))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if lookup, ok := c.lookups[key]; ok {
+		return lookup, false
+	}
+	lookup = &hostLookup{name: name, done: make(chan struct{})}
+	c.lookups[key] = lookup
+
+	return lookup, true
+}
+
+// run resolves the host name of the lookup
+func (c *lookupCache) run(ctx context.Context, lookup *hostLookup) {
+	lookup.ips, lookup.err = c.resolver.lookupIP(ctx, c.network, lookup.name)
+	close(lookup.done)
+}
+
+// lookupIP returns the addresses of a host name, waiting for its lookup if
+// a worker is already running it
+func (c *lookupCache) lookupIP(ctx context.Context, name string) ([]net.IP, error) {
+
+	lookup, created := c.lookup(name)
+
+	if created {
+		c.run(ctx, lookup)
+	}
+	select {
+	case <-lookup.done:
+		return lookup.ips, lookup.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// prefetch resolves the host names with the given number of workers, until
+// every name is resolved or the context is canceled
+func (c *lookupCache) prefetch(ctx context.Context, names []string, workers uint) {
+
+	queue := make(chan string)
+
+	for i := uint(0); i < workers; i++ {
+		go func() {
+			for name := range queue {
+				if lookup, created := c.lookup(name); created {
+					c.run(ctx, lookup)
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(queue)
+
+		for _, name := range names {
+			select {
+			case queue <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// isHostname tells whether a target specification is a host name rather
+// than an address, network or range
+func isHostname(targetSource string) bool {
+
+	if ip, _ := parseIP(targetSource); ip != nil {
+		return false
+	}
+	if _, _, err := net.ParseCIDR(targetSource); err == nil {
+		return false
+	}
+	if _, _, ok := parseRange(targetSource); ok {
+		return false
+	}
+	return REGEX_HOSTNAME.MatchString(targetSource)
+}
+
+// lookupNetwork returns the network host names are resolved on, restricted
+// to the address family of the scan if any
+func (sc *UdpProbeScanner) lookupNetwork() string {
+
+	if sc.addressFamily != 0 {
+		return "ip" + strconv.Itoa(sc.addressFamily)
+	}
+	return "ip"
+}
+
+// resolveTargets sends the hosts of the targets to the channel in the order
+// of the targets, while the host names among them are resolved concurrently
+// by up to ResolveConcurrency workers. Each host name is only looked up once.
+func (sc *UdpProbeScanner) resolveTargets(ctx context.Context, targetSourceList []string, hosts chan Host) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cache := newLookupCache(sc.resolver, sc.lookupNetwork())
+	names := []string{}
+
+	for _, targetSource := range targetSourceList {
+		if isHostname(targetSource) {
+			names = append(names, targetSource)
+		}
+	}
+	if len(names) > 1 && sc.ResolveConcurrency > 1 {
+
+		sc.Logger.Debug().
+			Int("hostnames", len(names)).
+			Uint("workers", sc.ResolveConcurrency).
+			Msg("Resolving target hostnames")
+
+		cache.prefetch(ctx, names, sc.ResolveConcurrency)
+	}
+	for _, targetSource := range targetSourceList {
+		if ctx.Err() != nil {
+			break
+		}
+		sc.resolveTarget(ctx, targetSource, hosts, cache)
+	}
+}
@@ -0,0 +1,76 @@
+package scan
+
+import "fmt"
+
+// PORT_STATE_RANKS orders port states by how much they reveal, so a port found
+// open by one scan is not reported as filtered because another missed it
+var PORT_STATE_RANKS = map[string]int{
+	PORT_STATE_CLOSED:        1,
+	PORT_STATE_OPEN_FILTERED: 2,
+	PORT_STATE_OPEN:          3,
+}
+
+// richness scores how much a result tells about its port: its state first,
+// then the details parsed from the response
+func richness(result Result) (state int, details int) {
+
+	details = len(result.Metadata) + len(result.CPE) + len(result.Vulnerabilities)
+
+	if result.Version != "" {
+		details++
+	}
+	if result.Banner != "" {
+		details++
+	}
+	if result.Capture != nil {
+		details++
+	}
+	return PORT_STATE_RANKS[result.State], details
+}
+
+// MergeResults deduplicates the results of several scans, such as shards or
+// repeated scans of the same hosts, by host, port and service. Of the results
+// of a service on a port, the richest is kept: the one with the most revealing
+// state, then with the most details, then the first. Each port then settles on
+// the most revealing state of its results, and the results of services in
+// other states are dropped, so that a port is never reported both closed
+// under the service it was probed for and open as another. Results are kept
+// in the order their service on their port was first seen.
+func MergeResults(scans ...[]Result) (merged []Result) {
+
+	index := map[string]int{}
+	states := map[string]int{}
+
+	for _, results := range scans {
+		for _, result := range results {
+
+			port := fmt.Sprintf("%s\x00%d", result.Host.Host, result.Port)
+			state, details := richness(result)
+
+			if state > states[port] {
+				states[port] = state
+			}
+			key := port + "\x00" + result.Service.Slug
+			i, ok := index[key]
+
+			if !ok {
+				index[key] = len(merged)
+				merged = append(merged, result)
+				continue
+			}
+			keptState, keptDetails := richness(merged[i])
+
+			if state > keptState || (state == keptState && details > keptDetails) {
+				merged[i] = result
+			}
+		}
+	}
+	settled := merged[:0]
+
+	for _, result := range merged {
+		if PORT_STATE_RANKS[result.State] == states[fmt.Sprintf("%s\x00%d", result.Host.Host, result.Port)] {
+			settled = append(settled, result)
+		}
+	}
+	return settled
+}
@@ -0,0 +1,37 @@
+package scan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeResults(t *testing.T) {
+
+	filtered := diffResult("a", 161, PORT_STATE_OPEN_FILTERED, "snmp", "")
+	open := diffResult("a", 161, PORT_STATE_OPEN, "snmp", "")
+	versioned := diffResult("a", 161, PORT_STATE_OPEN, "snmp", "net-snmp 5.9")
+	other := diffResult("a", 161, PORT_STATE_OPEN, "ntp", "")
+	dns := diffResult("b", 53, PORT_STATE_OPEN, "dns", "9.18")
+	closed := diffResult("a", 161, PORT_STATE_CLOSED, "snmp", "")
+	unknown := diffResult("a", 161, PORT_STATE_OPEN, "unknown", "")
+
+	tests := []struct {
+		name   string
+		scans  [][]Result
+		merged []Result
+	}{
+		{"empty", [][]Result{nil, {}}, nil},
+		{"duplicates", [][]Result{{dns}, {dns}}, []Result{dns}},
+		{"open over filtered", [][]Result{{filtered, dns}, {open}}, []Result{open, dns}},
+		{"state over details", [][]Result{{diffResult("a", 161, PORT_STATE_OPEN_FILTERED, "snmp", "x")}, {open}}, []Result{open}},
+		{"details", [][]Result{{open}, {versioned}, {open}}, []Result{versioned}},
+		{"services kept apart", [][]Result{{open}, {other}}, []Result{open, other}},
+		{"one state per port", [][]Result{{closed, dns}, {unknown}}, []Result{dns, unknown}},
+		{"open service over filtered service", [][]Result{{filtered}, {other}}, []Result{other}},
+	}
+	for _, test := range tests {
+		if merged := MergeResults(test.scans...); !reflect.DeepEqual(merged, test.merged) {
+			t.Errorf("%s: got %+v, want %+v", test.name, merged, test.merged)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package scan
+
+import (
+	"time"
+
+	"udpz/pkg/data"
+
+	"github.com/rs/zerolog"
+)
+
+// Options configures a UdpProbeScanner. Start from DefaultOptions so that
+// unset fields keep sensible values.
+type Options struct {
+	Logger zerolog.Logger
+
+	HostConcurrency    uint          // Maximum number of hosts scanned at once
+	PortConcurrency    uint          // Maximum number of probes in flight per host
+	ResolveConcurrency uint          // Maximum number of target host names resolved at once
+	Retransmissions    uint          // Number of times an unanswered probe is resent
+	RetryBackoff       float64       // Multiply the read timeout by this on each retransmission
+	Jitter             time.Duration // Wait a random delay of up to this before each probe
+	ReadTimeout        time.Duration // How long to wait for each response
+	AdaptiveTimeout    bool          // Wait as long as each host's measured round trip time needs, up to ReadTimeout
+	Rate               float64       // Maximum packets per second, or 0 for unlimited
+	MinRate            float64       // Lowest rate to back off to on packet loss
+	MaxRate            float64       // Highest rate to ramp up to, or 0 to keep the rate fixed
+
+	// RulesOfEngagement restricts the networks, rate, probes per host and
+	// times of day the scan may use, or nil for no restrictions
+	RulesOfEngagement *RulesOfEngagement
+
+	// Overrides change the rate, retransmissions, timeout or concurrency of
+	// the hosts of their targets
+	Overrides []TargetOverride
+
+	// Services is the probe database to scan with, or nil for the built-in
+	// database
+	Services map[string]data.UdpService
+
+	ScanAllAddresses bool     // Scan every address a hostname resolves to
+	Resolvers        []string // Resolve hostnames with these DNS servers, as IP or IP:PORT, instead of the system's
+	DoHURL           string   // Resolve hostnames with this DNS over HTTPS server instead
+	NoResolve        bool     // Never resolve hostnames, only scan literal addresses
+	AddressFamily    int      // Only scan IPv4 (4) or IPv6 (6) addresses, or both (0)
+	Exclude          []string // Hosts, CIDRs and ranges to skip
+
+	// Discovery only scans hosts that answer one of these methods, "icmp" or
+	// the name of a service such as "dns", or every host if empty
+	Discovery []string
+
+	Interface string // Send probes from this network interface
+	SourceIP  string // Send probes from this local address
+
+	// SourcePort sends probes from this local port, or range of ports such as
+	// "40000-40100"
+	SourcePort string
+	TTL        int // IP TTL or IPv6 hop limit of probes, or 0 for the default
+
+	Socks5Address  string // Relay probes through this SOCKS5 proxy as HOST:PORT
+	Socks5User     string
+	Socks5Password string
+	Socks5Timeout  time.Duration
+
+	// Sockets sends probes from a pool of this many shared sockets per address
+	// family instead of a socket per probe, or 0 for a socket per probe
+	Sockets int
+
+	// Batch sends and receives the probes of shared sockets in batches, with
+	// sendmmsg and recvmmsg. Linux only.
+	Batch bool
+
+	// UPnPDescriptions fetches the device description at the LOCATION of
+	// SSDP responses over HTTP, to report the model and services of devices
+	UPnPDescriptions bool
+
+	// Vulnerabilities annotates results with the CVEs affecting the products
+	// their versions were mapped to
+	Vulnerabilities VulnerabilitySource
+
+	// Ports confirmed open and open|filtered ports are reported by default.
+	// OpenOnly only reports the ports confirmed open, while AllStates also
+	// reports closed ports.
+	OpenOnly  bool
+	AllStates bool
+
+	CaptureLength int          // Include up to this many response bytes in results
+	Pcap          *PcapWriter  // Record every probe and response
+	Sinks         []ResultSink // Receive results as soon as they are confirmed
+}
+
+// DefaultOptions returns the options the udpz command line uses by default
+func DefaultOptions() Options {
+	return Options{
+		Logger:             zerolog.Nop(),
+		HostConcurrency:    10,
+		PortConcurrency:    50,
+		ResolveConcurrency: 16,
+		Retransmissions:    2,
+		RetryBackoff:       1,
+		ReadTimeout:        3 * time.Second,
+		ScanAllAddresses:   true,
+		Socks5Timeout:      3 * time.Second,
+	}
+}
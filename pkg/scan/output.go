@@ -3,14 +3,18 @@ package scan
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"udpz/pkg/proto"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"gopkg.in/yaml.v3"
 )
 
-func (sc *UdpProbeScanner) SaveJson(output *os.File) error {
+func (sc *UdpProbeScanner) SaveJson(output io.Writer) error {
 	if data, err := json.Marshal(&sc.results); err == nil {
 		output.Write(data)
 		return nil
@@ -19,7 +23,7 @@ func (sc *UdpProbeScanner) SaveJson(output *os.File) error {
 	}
 }
 
-func (sc *UdpProbeScanner) SaveYAML(output *os.File) error {
+func (sc *UdpProbeScanner) SaveYAML(output io.Writer) error {
 	if data, err := yaml.Marshal(&sc.results); err == nil {
 		output.Write(data)
 		return nil
@@ -28,51 +32,358 @@ func (sc *UdpProbeScanner) SaveYAML(output *os.File) error {
 	}
 }
 
-func (sc *UdpProbeScanner) SaveTable(format string, output *os.File) {
+// tableField is a column that can be selected for table output. Its value is
+// computed from the results of a single service on a port.
+type tableField struct {
+	header string
+	value  func(port uint16, service string, results []Result) interface{}
+}
+
+var (
+	// DEFAULT_FIELDS are the table columns shown when none are selected
+	DEFAULT_FIELDS = []string{"host", "port", "state", "service", "version", "probes"}
+
+	TABLE_FIELDS = map[string]tableField{
+		"host": {"Host", func(port uint16, service string, results []Result) interface{} {
+			return results[0].Host.Host
+		}},
+		"hostname": {"Hostname", func(port uint16, service string, results []Result) interface{} {
+			return results[0].Host.Hostname()
+		}},
+		"target": {"Target", func(port uint16, service string, results []Result) interface{} {
+			return results[0].Host.Target.Target
+		}},
+		"port": {"Port", func(port uint16, service string, results []Result) interface{} {
+			return fmt.Sprintf("%d/UDP", port)
+		}},
+		"state": {"State", func(port uint16, service string, results []Result) interface{} {
+			return results[0].State
+		}},
+		"service": {"Service", func(port uint16, service string, results []Result) interface{} {
+			return service
+		}},
+		"service_name": {"Service Name", func(port uint16, service string, results []Result) interface{} {
+			return results[0].Service.Name
+		}},
+		"version": {"Version", func(port uint16, service string, results []Result) interface{} {
+			return versionSummary(results)
+		}},
+		"banner": {"Banner", func(port uint16, service string, results []Result) interface{} {
+			var info proto.Info
+
+			for _, result := range results {
+				info = info.Merge(result.info())
+			}
+			return info.Banner
+		}},
+		"cpe": {"CPE", func(port uint16, service string, results []Result) interface{} {
+			var cpes []string
+
+			for _, result := range results {
+				cpes = appendUnique(cpes, result.CPE...)
+			}
+			return strings.Join(cpes, ",\n")
+		}},
+		"cves": {"CVEs", func(port uint16, service string, results []Result) interface{} {
+			var cves []string
+
+			for _, result := range results {
+				for _, vulnerability := range result.Vulnerabilities {
+					cves = appendUnique(cves, fmt.Sprintf("%s (%.1f)", vulnerability.ID, vulnerability.CVSS))
+				}
+			}
+			return strings.Join(cves, ",\n")
+		}},
+		"probes": {"Probes", func(port uint16, service string, results []Result) interface{} {
+			probeNamesMap := make(map[string]bool)
+			probeNames := []string{}
+
+			for _, result := range results {
+				if result.Probe.Name == "" {
+					continue
+				}
+				if stat, ok := probeNamesMap[result.Probe.Name]; !(ok && stat) {
+					probeNames = append(probeNames, result.Probe.Name)
+					probeNamesMap[result.Probe.Name] = true
+				}
+			}
+			return strings.Join(probeNames, ",\n")
+		}},
+	}
+)
+
+// CheckFields returns an error naming the first field that is not a known
+// table column
+func CheckFields(fields []string) error {
+
+	for _, field := range fields {
+		if _, ok := TABLE_FIELDS[field]; !ok {
+			names := make([]string, 0, len(TABLE_FIELDS))
+
+			for name := range TABLE_FIELDS {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			return fmt.Errorf("unknown field %q (valid fields: %s)", field, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// defaultFields returns the default table columns, with the host name next to
+// the host when any host was resolved from one
+func (sc *UdpProbeScanner) defaultFields() []string {
 
+	for _, result := range sc.results {
+		if result.Host.Hostname() != "" {
+			return append([]string{DEFAULT_FIELDS[0], "hostname"}, DEFAULT_FIELDS[1:]...)
+		}
+	}
+	return DEFAULT_FIELDS
+}
+
+// SaveTable renders the results as a table with the given columns, or the
+// default columns if none are given
+func (sc *UdpProbeScanner) SaveTable(format string, fields []string, output io.Writer) error {
+
+	if len(fields) == 0 {
+		fields = sc.defaultFields()
+	}
+	if err := CheckFields(fields); err != nil {
+		return err
+	}
+	header := make(table.Row, len(fields))
+
+	for i, field := range fields {
+		header[i] = TABLE_FIELDS[field].header
+	}
 	resultsTable := table.NewWriter()
-	resultsTable.AppendHeader(table.Row{"Host", "Port", "State", "Service", "Probes"})
+	resultsTable.AppendHeader(header)
 
-	for host, ports := range sc.resultsMap {
+	for _, ports := range sc.resultsMap {
 		for port, results := range ports {
-			resultMap := make(map[string][]PortResult)
+			resultMap := make(map[string][]Result)
 
 			for _, result := range results {
 				if _, ok := resultMap[result.Service.NameShort]; !ok {
-					resultMap[result.Service.NameShort] = []PortResult{}
+					resultMap[result.Service.NameShort] = []Result{}
 				}
 				resultMap[result.Service.NameShort] = append(resultMap[result.Service.NameShort], result)
 			}
 			for service, results := range resultMap {
-				probeNamesMap := make(map[string]bool)
-				probeNames := []string{}
-
-				for _, result := range results {
-					if stat, ok := probeNamesMap[result.Probe.Name]; !(ok && stat) {
-						probeNames = append(probeNames, result.Probe.Name)
-						probeNamesMap[result.Probe.Name] = true
-					}
+				row := make(table.Row, len(fields))
+
+				for i, field := range fields {
+					row[i] = TABLE_FIELDS[field].value(port, service, results)
 				}
-				resultsTable.AppendRow(table.Row{
-					host,
-					fmt.Sprintf("%d/UDP", port),
-					"OPEN",
-					service,
-					strings.Join(probeNames, ",\n"),
-				})
+				resultsTable.AppendRow(row)
 			}
 		}
 		resultsTable.AppendSeparator()
 	}
 	resultsTable.SetOutputMirror(output)
+	renderTable(resultsTable, format)
+
+	return nil
+}
+
+// renderTable renders a table in one of the table output formats
+func renderTable(writer table.Writer, format string) {
+
 	if format == "text" || format == "txt" {
-		resultsTable.Render()
+		writer.Render()
 	} else if format == "tsv" {
-		resultsTable.RenderTSV()
+		writer.RenderTSV()
 	} else if format == "csv" {
-		resultsTable.RenderCSV()
+		writer.RenderCSV()
+	} else if format == "markdown" || format == "md" {
+		writer.RenderMarkdown()
 	} else if format == "pretty" {
-		resultsTable.SetStyle(table.StyleRounded)
-		resultsTable.Render()
+		writer.SetStyle(table.StyleRounded)
+		writer.Render()
+	}
+}
+
+// SaveMarkdown writes a summary of the scan followed by the results as
+// GitHub-flavored Markdown tables, ready to paste into reports and wikis
+func (sc *UdpProbeScanner) SaveMarkdown(fields []string, output io.Writer) error {
+
+	stateCounts := make(map[string]int)
+	serviceCounts := make(map[string]int)
+	hosts := 0
+
+	for _, ports := range sc.resultsMap {
+		hosts++
+
+		for _, results := range ports {
+			seen := make(map[string]bool)
+
+			for _, result := range results {
+				if seen[result.Service.NameShort] {
+					continue
+				}
+				seen[result.Service.NameShort] = true
+				stateCounts[result.State]++
+
+				if result.State == PORT_STATE_OPEN {
+					serviceCounts[result.Service.NameShort]++
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(output, "# UDPz Scan Results\n\n")
+	fmt.Fprintf(output, "## Summary\n\n")
+	fmt.Fprintf(output, "- **Started:** %s\n", sc.startTime.Format(time.RFC1123))
+	fmt.Fprintf(output, "- **Duration:** %s\n", sc.endTime.Sub(sc.startTime).Round(time.Millisecond))
+	fmt.Fprintf(output, "- **Hosts with results:** %d\n", hosts)
+
+	for _, state := range []string{PORT_STATE_OPEN, PORT_STATE_OPEN_FILTERED, PORT_STATE_CLOSED} {
+		if stateCounts[state] > 0 {
+			fmt.Fprintf(output, "- **%s ports:** %d\n", state, stateCounts[state])
+		}
+	}
+	reflectors := reflectionExposure(sc.resultsMap)
+
+	if len(reflectors) > 0 {
+		fmt.Fprintf(output, "- **Ports exposed to UDP reflection:** %d\n", len(reflectors))
+	}
+
+	if len(serviceCounts) > 0 {
+		services := make([]string, 0, len(serviceCounts))
+
+		for service := range serviceCounts {
+			services = append(services, service)
+		}
+		sort.Slice(services, func(i, j int) bool {
+			if serviceCounts[services[i]] != serviceCounts[services[j]] {
+				return serviceCounts[services[i]] > serviceCounts[services[j]]
+			}
+			return services[i] < services[j]
+		})
+
+		servicesTable := table.NewWriter()
+		servicesTable.AppendHeader(table.Row{"Service", "Open Ports"})
+
+		for _, service := range services {
+			servicesTable.AppendRow(table.Row{service, serviceCounts[service]})
+		}
+		fmt.Fprintf(output, "\n### Open Services\n\n")
+		servicesTable.SetOutputMirror(output)
+		servicesTable.RenderMarkdown()
+	}
+
+	if len(reflectors) > 0 {
+		reflectorsTable := table.NewWriter()
+		reflectorsTable.AppendHeader(table.Row{"Host", "Port", "Service", "Reflection", "Amplification"})
+
+		for _, exposed := range reflectors {
+			factor := "-"
+
+			if exposed.amplification > 0 {
+				factor = fmt.Sprintf("%.1fx", exposed.amplification)
+			}
+			reflectorsTable.AppendRow(table.Row{exposed.host, fmt.Sprintf("%d/UDP", exposed.port), exposed.service, exposed.reflection, factor})
+		}
+		fmt.Fprintf(output, "\n### UDP Reflection Exposure\n\n")
+		reflectorsTable.SetOutputMirror(output)
+		reflectorsTable.RenderMarkdown()
+	}
+
+	fmt.Fprintf(output, "\n## Results\n\n")
+	return sc.SaveTable("markdown", fields, output)
+}
+
+// versionSummary combines the version information of the results for a port
+// into a single short line
+func versionSummary(results []Result) string {
+
+	const maxLength = 64
+
+	var info proto.Info
+
+	for _, result := range results {
+		info = info.Merge(result.info())
+	}
+	summary := info.Version
+
+	if summary == "" {
+		summary = info.Banner
+	}
+	if runes := []rune(summary); len(runes) > maxLength {
+		summary = string(runes[:maxLength-3]) + "..."
+	}
+	return summary
+}
+
+// reflector is a port whose responses are flagged for DDoS reflection, with
+// the largest amplification among them
+type reflector struct {
+	host          string
+	port          uint16
+	service       string
+	reflection    string
+	amplification float64
+}
+
+// reflectionExposure lists the ports whose responses are flagged for DDoS
+// reflection, such as those of the simple services, NTP and memcached, the
+// most amplifying first
+func reflectionExposure(resultsMap map[string]map[uint16][]Result) []reflector {
+
+	var reflectors []reflector
+
+	for host, ports := range resultsMap {
+		for port, results := range ports {
+			found := make(map[string]int)
+
+			for _, result := range results {
+				reflection := result.Metadata["ddos_reflection"]
+
+				if reflection == "" {
+					continue
+				}
+				factor, _ := strconv.ParseFloat(result.Metadata["amplification"], 64)
+				i, ok := found[result.Service.NameShort]
+
+				if !ok {
+					found[result.Service.NameShort] = len(reflectors)
+					reflectors = append(reflectors, reflector{host, port, result.Service.NameShort, reflection, factor})
+				} else if factor > reflectors[i].amplification {
+					reflectors[i].reflection, reflectors[i].amplification = reflection, factor
+				}
+			}
+		}
+	}
+	sort.Slice(reflectors, func(i, j int) bool {
+		if reflectors[i].amplification != reflectors[j].amplification {
+			return reflectors[i].amplification > reflectors[j].amplification
+		}
+		if reflectors[i].host != reflectors[j].host {
+			return reflectors[i].host < reflectors[j].host
+		}
+		return reflectors[i].port < reflectors[j].port
+	})
+	return reflectors
+}
+
+// appendUnique appends the values missing from the list
+func appendUnique(list []string, values ...string) []string {
+
+	for _, value := range values {
+
+		found := false
+
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
 	}
+	return list
 }
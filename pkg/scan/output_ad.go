@@ -0,0 +1,160 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type adReport struct {
+	Domains []*adDomain `json:"domains"`
+}
+
+// adDomain is an Active Directory domain and the domain controllers found
+// for it
+type adDomain struct {
+	Domain              string                `json:"domain"`
+	Forest              string                `json:"forest,omitempty"`
+	NetBIOSDomain       string                `json:"netbios_domain,omitempty"`
+	DomainGUID          string                `json:"domain_guid,omitempty"`
+	DomainFunctionality string                `json:"domain_functionality,omitempty"`
+	ForestFunctionality string                `json:"forest_functionality,omitempty"`
+	Sites               []string              `json:"sites"`
+	DomainControllers   []*adDomainController `json:"domain_controllers"`
+	BloodHoundPython    string                `json:"bloodhound_python"`
+}
+
+type adDomainController struct {
+	IP              string   `json:"ip"`
+	Hostname        string   `json:"hostname,omitempty"`
+	NetBIOSName     string   `json:"netbios_name,omitempty"`
+	Site            string   `json:"site,omitempty"`
+	Roles           []string `json:"roles"`
+	DCFunctionality string   `json:"dc_functionality,omitempty"`
+}
+
+// adDomains returns the Active Directory domains of the domain controllers
+// that answered CLDAP probes, in the order they were found. The root DSE and
+// Netlogon responses of a domain controller are merged, the first value of
+// each key winning.
+func (sc *UdpProbeScanner) adDomains() []*adDomain {
+
+	addresses := []string{}
+	metadataByIP := map[string]map[string]string{}
+
+	for _, result := range sc.results {
+
+		if result.State != PORT_STATE_OPEN || result.Service.Slug != "cldap" {
+			continue
+		}
+		ip := strings.Trim(result.Host.Host, "[]")
+		metadata, ok := metadataByIP[ip]
+
+		if !ok {
+			metadata = map[string]string{}
+			metadataByIP[ip] = metadata
+			addresses = append(addresses, ip)
+		}
+		for key, value := range result.Metadata {
+			if metadata[key] == "" {
+				metadata[key] = value
+			}
+		}
+	}
+	domains := []*adDomain{}
+	byName := map[string]*adDomain{}
+
+	for _, ip := range addresses {
+
+		metadata := metadataByIP[ip]
+
+		if metadata["domain"] == "" {
+			continue
+		}
+		name := strings.ToLower(metadata["domain"])
+		domain, ok := byName[name]
+
+		if !ok {
+			domain = &adDomain{
+				Domain:            name,
+				Sites:             []string{},
+				DomainControllers: []*adDomainController{},
+			}
+			byName[name] = domain
+			domains = append(domains, domain)
+		}
+		if domain.Forest == "" {
+			domain.Forest = strings.ToLower(metadata["forest"])
+		}
+		for field, key := range map[*string]string{
+			&domain.NetBIOSDomain:       "netbios_domain",
+			&domain.DomainGUID:          "domain_guid",
+			&domain.DomainFunctionality: "domain_functionality",
+			&domain.ForestFunctionality: "forest_functionality",
+		} {
+			if *field == "" {
+				*field = metadata[key]
+			}
+		}
+		controller := &adDomainController{
+			IP:              ip,
+			Hostname:        metadata["hostname"],
+			NetBIOSName:     metadata["netbios_name"],
+			Site:            metadata["site"],
+			Roles:           []string{},
+			DCFunctionality: metadata["dc_functionality"],
+		}
+		if metadata["dc_roles"] != "" {
+			controller.Roles = strings.Split(metadata["dc_roles"], ",")
+		}
+		if i := sort.SearchStrings(domain.Sites, controller.Site); controller.Site != "" && (i == len(domain.Sites) || domain.Sites[i] != controller.Site) {
+			domain.Sites = append(domain.Sites, controller.Site)
+			sort.Strings(domain.Sites)
+		}
+		domain.DomainControllers = append(domain.DomainControllers, controller)
+	}
+	for _, domain := range domains {
+		domain.BloodHoundPython = bloodHoundCommand(domain)
+	}
+	return domains
+}
+
+// bloodHoundCommand returns a bloodhound-python command line collecting the
+// domain from its first writable domain controller, leaving the credentials
+// to fill in
+func bloodHoundCommand(domain *adDomain) string {
+
+	controller := domain.DomainControllers[0]
+
+	for _, candidate := range domain.DomainControllers {
+
+		writable := false
+
+		for _, role := range candidate.Roles {
+			writable = writable || role == "writable"
+		}
+		if writable {
+			controller = candidate
+			break
+		}
+	}
+	name := controller.Hostname
+
+	if name == "" {
+		name = controller.IP
+	}
+	return fmt.Sprintf("bloodhound-python -d %s -dc %s -ns %s -c All -u USER -p PASSWORD", domain.Domain, name, controller.IP)
+}
+
+// SaveAD writes the Active Directory domains, domain controllers and sites
+// found over CLDAP as JSON, with a bloodhound-python command line for each
+// domain, to seed Active Directory collection
+func (sc *UdpProbeScanner) SaveAD(output io.Writer) error {
+
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(adReport{Domains: sc.adDomains()})
+}
@@ -0,0 +1,488 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FindingTemplate is a prepopulated finding, reported for the open ports of the
+// services it lists. Templates with a metadata key only apply to results with
+// that metadata value, such as NTP servers answering monlist.
+type FindingTemplate struct {
+	ID         string
+	Services   []string
+	Metadata   string
+	Value      string
+	Title      string // "{service}" is replaced by the short name of the service
+	Severity   string // Critical, High, Medium, Low or Info
+	CVE        []string
+	Impact     string
+	Mitigation string
+}
+
+// Finding is a finding of an open port of a host
+type Finding struct {
+	FindingTemplate
+	Result Result // First result the finding was made on
+}
+
+var (
+	// FINDING_TEMPLATES are the findings of open high-risk services. Every
+	// template matching a port is reported; ports matching none are reported
+	// as informational findings.
+	FINDING_TEMPLATES = []FindingTemplate{
+		{
+			ID:         "ipmi-cipher-zero",
+			Services:   []string{"ipmi"},
+			Metadata:   "cipher_zero",
+			Value:      "true",
+			Title:      "IPMI cipher suite 0 authentication bypass",
+			Severity:   "Critical",
+			CVE:        []string{"CVE-2013-4782"},
+			Impact:     "The BMC accepts cipher suite 0, which lets anyone log in as any user, including administrators, with any password and take control of the host.",
+			Mitigation: "Disable cipher suite 0 on the BMC and restrict access to IPMI to a dedicated management network.",
+		},
+		{
+			ID:         "ipmi",
+			Services:   []string{"ipmi"},
+			Title:      "Exposed IPMI service",
+			Severity:   "High",
+			CVE:        []string{"CVE-2013-4786"},
+			Impact:     "IPMI 2.0 hands out the salted password hash of any user that exists during RAKP authentication, which can be cracked offline, and BMCs give full control of the host.",
+			Mitigation: "Restrict access to IPMI to a dedicated management network and use long, unique BMC passwords.",
+		},
+		{
+			ID:         "memcache",
+			Services:   []string{"memcache"},
+			Title:      "Exposed memcached UDP service",
+			Severity:   "High",
+			CVE:        []string{"CVE-2018-1000115"},
+			Impact:     "memcached over UDP reflects small spoofed requests as responses up to tens of thousands of times larger for DDoS attacks, and exposes the cached data.",
+			Mitigation: "Disable the UDP listener of memcached (-U 0) and restrict access to the TCP listener to the hosts that use the cache.",
+		},
+		{
+			ID:         "ntp-monlist",
+			Services:   []string{"ntp"},
+			Metadata:   "ddos_reflection",
+			Value:      "monlist",
+			Title:      "NTP monlist enabled",
+			Severity:   "High",
+			CVE:        []string{"CVE-2013-5211"},
+			Impact:     "The NTP server answers monlist queries with the last 600 hosts it talked to, which discloses them and reflects spoofed requests as responses hundreds of times larger for DDoS attacks.",
+			Mitigation: "Upgrade ntpd to 4.2.7 or later, or disable monitoring queries with \"disable monitor\" and \"restrict default noquery\".",
+		},
+		{
+			ID:         "ntp-mode6",
+			Services:   []string{"ntp"},
+			Metadata:   "ddos_reflection",
+			Value:      "readvar",
+			Title:      "NTP mode 6 queries enabled",
+			Severity:   "Medium",
+			Impact:     "The NTP server answers mode 6 control queries, which disclose its configuration and version and reflect spoofed requests as larger responses for DDoS attacks.",
+			Mitigation: "Disable control queries from other hosts with \"restrict default noquery\".",
+		},
+		{
+			ID:         "dns-open-resolver",
+			Services:   []string{"dns"},
+			Metadata:   "recursion",
+			Value:      "available",
+			Title:      "Open DNS resolver",
+			Severity:   "Medium",
+			Impact:     "The DNS server resolves names for any host, which reflects spoofed requests as larger responses for DDoS attacks and exposes it to cache poisoning.",
+			Mitigation: "Only offer recursion to the networks the resolver serves.",
+		},
+		{
+			ID:         "wdbrpc",
+			Services:   []string{"wdbrpc"},
+			Title:      "Exposed VxWorks WDB debug agent",
+			Severity:   "Critical",
+			CVE:        []string{"CVE-2010-2965"},
+			Impact:     "The VxWorks WDB agent lets anyone read and write the memory of the device and call functions on it without authentication.",
+			Mitigation: "Disable the WDB agent in production firmware, or filter port 17185.",
+		},
+		{
+			ID:         "ics",
+			Services:   []string{"bacnet", "dnp3", "enip", "fins", "knxnet-ip", "melsec-q", "moxa-nport", "pcworx", "profinet"},
+			Title:      "Exposed {service} industrial control service",
+			Severity:   "High",
+			Impact:     "Industrial control protocols have little or no authentication, so anyone reaching the service can read from and often control the device.",
+			Mitigation: "Keep industrial control devices on an isolated network reached through a firewall or VPN.",
+		},
+		{
+			ID:         "snmp",
+			Services:   []string{"snmp"},
+			Title:      "SNMP accessible with a guessable community",
+			Severity:   "Medium",
+			Impact:     "The SNMP agent answers a default community, disclosing the configuration, interfaces and often the routes and processes of the host, and may allow changing it.",
+			Mitigation: "Change the community strings or use SNMPv3 with authentication, and restrict access to SNMP to the management network.",
+		},
+		{
+			ID:         "cldap",
+			Services:   []string{"cldap"},
+			Title:      "Exposed CLDAP service",
+			Severity:   "Medium",
+			Impact:     "CLDAP discloses the domain of the host and reflects spoofed requests as responses dozens of times larger for DDoS attacks.",
+			Mitigation: "Filter UDP port 389 from untrusted networks.",
+		},
+		{
+			ID:         "legacy",
+			Services:   []string{"chargen", "echo", "daytime", "time", "qotd"},
+			Title:      "Legacy {service} service enabled",
+			Severity:   "Medium",
+			CVE:        []string{"CVE-1999-0103"},
+			Impact:     "Legacy diagnostic services answer any datagram, which reflects spoofed requests for DDoS attacks and, between two such services, loops traffic indefinitely.",
+			Mitigation: "Disable the service.",
+		},
+		{
+			ID:         "portmap",
+			Services:   []string{"portmap"},
+			Title:      "Exposed portmapper",
+			Severity:   "Medium",
+			Impact:     "The portmapper lists the RPC services of the host, such as NFS, and reflects spoofed requests as larger responses for DDoS attacks.",
+			Mitigation: "Filter port 111 from untrusted networks.",
+		},
+		{
+			ID:         "upnp",
+			Services:   []string{"upnp"},
+			Title:      "Exposed UPnP SSDP service",
+			Severity:   "Medium",
+			Impact:     "SSDP discloses the device and its services, and reflects spoofed requests as responses dozens of times larger for DDoS attacks.",
+			Mitigation: "Disable UPnP on interfaces facing untrusted networks.",
+		},
+		{
+			ID:         "tftp",
+			Services:   []string{"tftp"},
+			Title:      "Exposed TFTP service",
+			Severity:   "Medium",
+			Impact:     "TFTP has no authentication, so anyone can download, and often upload, files such as device configurations.",
+			Mitigation: "Disable the TFTP server, or restrict access to it to the hosts that need it.",
+		},
+		{
+			ID:         "xdmcp",
+			Services:   []string{"xdmcp"},
+			Title:      "Exposed XDMCP service",
+			Severity:   "Medium",
+			Impact:     "XDMCP offers graphical logins over unencrypted X11 sessions.",
+			Mitigation: "Disable XDMCP and use an encrypted remote desktop protocol.",
+		},
+	}
+
+	// FARADAY_SEVERITIES are the severities of Faraday for the severities of
+	// the finding templates
+	FARADAY_SEVERITIES = map[string]string{
+		"Critical": "critical",
+		"High":     "high",
+		"Medium":   "medium",
+		"Low":      "low",
+		"Info":     "informational",
+	}
+)
+
+// matchesService reports whether the result is of one of the services, and
+// when a metadata key is given, has that metadata value
+func matchesService(result Result, services []string, metadata string, value string) bool {
+
+	for _, service := range services {
+		if service == result.Service.Slug {
+			return metadata == "" || result.Metadata[metadata] == value
+		}
+	}
+	return false
+}
+
+// matches reports whether the template applies to the result
+func (ft FindingTemplate) matches(result Result) bool {
+	return matchesService(result, ft.Services, ft.Metadata, ft.Value)
+}
+
+// Findings returns the findings of the open ports of the results, in the order
+// of the results. Ports matching none of the finding templates are reported
+// as an informational finding of the open service.
+func (sc *UdpProbeScanner) Findings() (findings []Finding) {
+
+	reported := map[string]bool{}
+	ports := []string{}
+	first := map[string]Result{}
+
+	for _, result := range sc.results {
+
+		if result.State != PORT_STATE_OPEN {
+			continue
+		}
+		port := fmt.Sprintf("%s:%d", result.Host.Host, result.Port)
+
+		if _, ok := first[port]; !ok {
+			ports = append(ports, port)
+			first[port] = result
+		}
+		for i, findingTemplate := range FINDING_TEMPLATES {
+
+			key := fmt.Sprintf("%s/%d", port, i)
+
+			if reported[key] || !findingTemplate.matches(result) {
+				continue
+			}
+			reported[key], reported[port] = true, true
+
+			findingTemplate.Title = strings.ReplaceAll(findingTemplate.Title, "{service}", result.Service.NameShort)
+			findings = append(findings, Finding{FindingTemplate: findingTemplate, Result: result})
+		}
+	}
+	for _, port := range ports {
+
+		if reported[port] {
+			continue
+		}
+		result := first[port]
+
+		findings = append(findings, Finding{
+			FindingTemplate: FindingTemplate{
+				ID:         "open-" + result.Service.Slug,
+				Title:      "Open " + result.Service.NameShort + " service",
+				Severity:   "Info",
+				Impact:     result.Service.Description,
+				Mitigation: "Filter the port from networks that do not need the service.",
+			},
+			Result: result,
+		})
+	}
+	return
+}
+
+// evidence describes what the response revealed of the service
+func (finding Finding) evidence() string {
+
+	lines := []string{fmt.Sprintf("%s answered the %s probe on %d/udp.",
+		strings.Trim(finding.Result.Host.label(), "[]"), finding.Result.Probe.Name, finding.Result.Port)}
+
+	if finding.Result.Version != "" {
+		lines = append(lines, "Version: "+finding.Result.Version)
+	}
+	if finding.Result.Banner != "" {
+		lines = append(lines, "Banner: "+finding.Result.Banner)
+	}
+	names := make([]string, 0, len(finding.Result.Metadata))
+
+	for name := range finding.Result.Metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		lines = append(lines, name+": "+finding.Result.Metadata[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// references returns the references of the finding's service
+func (finding Finding) references() []string {
+
+	if finding.Result.Service.References == nil {
+		return []string{}
+	}
+	return finding.Result.Service.References
+}
+
+type defectDojoReport struct {
+	Findings []defectDojoFinding `json:"findings"`
+}
+
+type defectDojoFinding struct {
+	Title            string               `json:"title"`
+	Description      string               `json:"description"`
+	Severity         string               `json:"severity"`
+	Impact           string               `json:"impact"`
+	Mitigation       string               `json:"mitigation"`
+	References       string               `json:"references,omitempty"`
+	Date             string               `json:"date"`
+	VulnIDFromTool   string               `json:"vuln_id_from_tool"`
+	VulnerabilityIDs []defectDojoVulnID   `json:"vulnerability_ids,omitempty"`
+	ComponentName    string               `json:"component_name,omitempty"`
+	ComponentVersion string               `json:"component_version,omitempty"`
+	Endpoints        []defectDojoEndpoint `json:"endpoints"`
+	StaticFinding    bool                 `json:"static_finding"`
+	DynamicFinding   bool                 `json:"dynamic_finding"`
+}
+
+type defectDojoVulnID struct {
+	ID string `json:"vulnerability_id"`
+}
+
+type defectDojoEndpoint struct {
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Port     uint16 `json:"port"`
+}
+
+// SaveDefectDojo writes the findings in DefectDojo's generic findings import
+// JSON, one finding per host, port and finding template
+func (sc *UdpProbeScanner) SaveDefectDojo(output io.Writer) error {
+
+	report := defectDojoReport{Findings: []defectDojoFinding{}}
+
+	for _, finding := range sc.Findings() {
+
+		entry := defectDojoFinding{
+			Title:            finding.Title,
+			Description:      finding.evidence(),
+			Severity:         finding.Severity,
+			Impact:           finding.Impact,
+			Mitigation:       finding.Mitigation,
+			References:       strings.Join(finding.references(), "\n"),
+			Date:             sc.endTime.Format("2006-01-02"),
+			VulnIDFromTool:   "udpz-" + finding.ID,
+			ComponentName:    finding.Result.Service.Name,
+			ComponentVersion: finding.Result.Version,
+			Endpoints: []defectDojoEndpoint{{
+				Protocol: "udp",
+				Host:     strings.Trim(finding.Result.Host.Host, "[]"),
+				Port:     finding.Result.Port,
+			}},
+			DynamicFinding: true,
+		}
+		for _, cve := range finding.CVE {
+			entry.VulnerabilityIDs = append(entry.VulnerabilityIDs, defectDojoVulnID{ID: cve})
+		}
+		report.Findings = append(report.Findings, entry)
+	}
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
+
+type faradayReport struct {
+	Hosts   []*faradayHost `json:"hosts"`
+	Command faradayCommand `json:"command"`
+}
+
+type faradayHost struct {
+	IP              string                 `json:"ip"`
+	Description     string                 `json:"description"`
+	Hostnames       []string               `json:"hostnames"`
+	Services        []*faradayService      `json:"services"`
+	Vulnerabilities []faradayVulnerability `json:"vulnerabilities"`
+}
+
+type faradayService struct {
+	Name            string                 `json:"name"`
+	Port            uint16                 `json:"port"`
+	Protocol        string                 `json:"protocol"`
+	Status          string                 `json:"status"`
+	Version         string                 `json:"version"`
+	Description     string                 `json:"description"`
+	Vulnerabilities []faradayVulnerability `json:"vulnerabilities"`
+}
+
+type faradayVulnerability struct {
+	Name       string       `json:"name"`
+	Desc       string       `json:"desc"`
+	Severity   string       `json:"severity"`
+	Type       string       `json:"type"`
+	Status     string       `json:"status"`
+	Resolution string       `json:"resolution"`
+	Data       string       `json:"data"`
+	Refs       []faradayRef `json:"refs"`
+	CVE        []string     `json:"cve"`
+	ExternalID string       `json:"external_id"`
+}
+
+type faradayRef struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type faradayCommand struct {
+	Tool      string `json:"tool"`
+	Command   string `json:"command"`
+	Params    string `json:"params"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// SaveFaraday writes the open ports and their findings in the layout of
+// Faraday's bulk create API, each host with its open services and the
+// findings of the services
+func (sc *UdpProbeScanner) SaveFaraday(output io.Writer, args []string) error {
+
+	report := faradayReport{
+		Hosts: []*faradayHost{},
+		Command: faradayCommand{
+			Tool:      "udpz",
+			Command:   "udpz",
+			Params:    strings.Join(args, " "),
+			StartDate: sc.startTime.Format(time.RFC3339),
+			EndDate:   sc.endTime.Format(time.RFC3339),
+		},
+	}
+	hosts := map[string]*faradayHost{}
+	services := map[string]*faradayService{}
+
+	for _, finding := range sc.Findings() {
+
+		result := finding.Result
+		ip := strings.Trim(result.Host.Host, "[]")
+
+		host, ok := hosts[ip]
+
+		if !ok {
+			host = &faradayHost{IP: ip, Hostnames: []string{}, Services: []*faradayService{}, Vulnerabilities: []faradayVulnerability{}}
+			hosts[ip] = host
+			report.Hosts = append(report.Hosts, host)
+		}
+		if hostname := result.Host.Hostname(); hostname != "" && len(host.Hostnames) == 0 {
+			host.Hostnames = append(host.Hostnames, hostname)
+		}
+		port := fmt.Sprintf("%s:%d", ip, result.Port)
+		service, ok := services[port]
+
+		if !ok {
+			service = &faradayService{
+				Name:            result.Service.Slug,
+				Port:            result.Port,
+				Protocol:        "udp",
+				Status:          "open",
+				Version:         result.Version,
+				Description:     result.Service.Name,
+				Vulnerabilities: []faradayVulnerability{},
+			}
+			services[port] = service
+			host.Services = append(host.Services, service)
+		}
+		// Open services are already listed as services, so informational
+		// findings are not repeated as vulnerabilities
+		if finding.Severity == "Info" {
+			continue
+		}
+		refs := []faradayRef{}
+
+		for _, reference := range finding.references() {
+			refs = append(refs, faradayRef{Name: reference, Type: "other"})
+		}
+		cve := finding.CVE
+
+		if cve == nil {
+			cve = []string{}
+		}
+		service.Vulnerabilities = append(service.Vulnerabilities, faradayVulnerability{
+			Name:       finding.Title,
+			Desc:       finding.Impact,
+			Severity:   FARADAY_SEVERITIES[finding.Severity],
+			Type:       "Vulnerability",
+			Status:     "open",
+			Resolution: finding.Mitigation,
+			Data:       finding.evidence(),
+			Refs:       refs,
+			CVE:        cve,
+			ExternalID: "udpz-" + finding.ID,
+		})
+	}
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
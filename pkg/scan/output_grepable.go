@@ -0,0 +1,54 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// grepableField escapes a value of the slash separated port fields, the way
+// nmap does, so that every result stays on one line
+var grepableField = strings.NewReplacer("/", "|", "\t", " ", "\r", " ", "\n", " ")
+
+// SaveGrepable writes one line per result in the layout of nmap's grepable
+// output (-oG), such as
+//
+//	Host: 10.0.0.1 ()	Ports: 161/open/udp//snmp//net-snmp 5.9/
+//
+// so that shell pipelines built around nmap work on the results. The host name
+// is given for targets that were host names, and the version field falls back
+// to the banner.
+func (sc *UdpProbeScanner) SaveGrepable(output io.Writer, args []string) (err error) {
+
+	if _, err = fmt.Fprintf(output, "# udpz %s scan initiated %s as: %s\n",
+		VERSION, sc.startTime.Format(time.ANSIC), strings.Join(args, " ")); err != nil {
+		return
+	}
+	for _, result := range sc.results {
+
+		name, ok := NMAP_SERVICE_NAMES[result.Service.Slug]
+
+		if !ok {
+			name = result.Service.Slug
+		}
+		version := result.Version
+
+		if version == "" {
+			version = result.Banner
+		}
+		if _, err = fmt.Fprintf(output, "Host: %s (%s)\tPorts: %d/%s/udp//%s//%s/\n",
+			strings.Trim(result.Host.Host, "[]"),
+			grepableField.Replace(result.Host.Hostname()),
+			result.Port,
+			nmapState(result.State).State,
+			grepableField.Replace(name),
+			grepableField.Replace(version)); err != nil {
+			return
+		}
+	}
+	_, err = fmt.Fprintf(output, "# udpz done at %s -- %d results in %.2f seconds\n",
+		sc.endTime.Format(time.ANSIC), len(sc.results), sc.endTime.Sub(sc.startTime).Seconds())
+
+	return
+}
@@ -0,0 +1,108 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// masscanRecord is an entry of masscan's JSON output (-oJ), holding either the
+// state of a port or the banner of the service on it
+type masscanRecord struct {
+	IP        string        `json:"ip"`
+	Timestamp string        `json:"timestamp"`
+	Ports     []masscanPort `json:"ports"`
+}
+
+type masscanPort struct {
+	Port    uint16          `json:"port"`
+	Proto   string          `json:"proto"`
+	Status  string          `json:"status,omitempty"`
+	Reason  string          `json:"reason,omitempty"`
+	Service *masscanService `json:"service,omitempty"`
+}
+
+type masscanService struct {
+	Name   string `json:"name"`
+	Banner string `json:"banner"`
+}
+
+// masscanBanner replaces line breaks in banners, which masscan's list output
+// keeps on one line
+var masscanBanner = strings.NewReplacer("\r", " ", "\n", " ")
+
+// masscanResults calls write with the address and banner masscan would report
+// for each open port of the results. masscan only reports open ports, so other
+// results are left out.
+func (sc *UdpProbeScanner) masscanResults(write func(ip string, result Result, banner string) error) error {
+
+	for _, result := range sc.results {
+
+		if result.State != PORT_STATE_OPEN {
+			continue
+		}
+		banner := result.Version
+
+		if banner == "" {
+			banner = result.Banner
+		}
+		if err := write(strings.Trim(result.Host.Host, "[]"), result, masscanBanner.Replace(banner)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveMasscanJSON writes the open ports in the layout of masscan's JSON output
+// (-oJ), with a record for the banner of each port that has one, so that tools
+// written for masscan results read them unchanged
+func (sc *UdpProbeScanner) SaveMasscanJSON(output io.Writer) error {
+
+	records := []masscanRecord{}
+	timestamp := strconv.FormatInt(sc.startTime.Unix(), 10)
+
+	sc.masscanResults(func(ip string, result Result, banner string) error {
+
+		records = append(records, masscanRecord{
+			IP:        ip,
+			Timestamp: timestamp,
+			Ports:     []masscanPort{{Port: result.Port, Proto: "udp", Status: "open", Reason: "response"}},
+		})
+		if banner != "" {
+			records = append(records, masscanRecord{
+				IP:        ip,
+				Timestamp: timestamp,
+				Ports:     []masscanPort{{Port: result.Port, Proto: "udp", Service: &masscanService{Name: result.Service.Slug, Banner: banner}}},
+			})
+		}
+		return nil
+	})
+	return json.NewEncoder(output).Encode(records)
+}
+
+// SaveMasscanList writes the open ports in the layout of masscan's list output
+// (-oL), one "open udp PORT IP TIMESTAMP" line per port followed by a banner
+// line for ports that have one
+func (sc *UdpProbeScanner) SaveMasscanList(output io.Writer) (err error) {
+
+	timestamp := sc.startTime.Unix()
+
+	if _, err = fmt.Fprintln(output, "#masscan"); err != nil {
+		return
+	}
+	err = sc.masscanResults(func(ip string, result Result, banner string) (err error) {
+
+		if _, err = fmt.Fprintf(output, "open udp %d %s %d\n", result.Port, ip, timestamp); err != nil || banner == "" {
+			return
+		}
+		_, err = fmt.Fprintf(output, "banner udp %d %s %d %s %s\n", result.Port, ip, timestamp, result.Service.Slug, banner)
+		return
+	})
+	if err != nil {
+		return
+	}
+	_, err = fmt.Fprintln(output, "# end")
+	return
+}
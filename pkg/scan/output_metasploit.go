@@ -0,0 +1,144 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetasploitModule is a Metasploit module run against the open ports of the
+// services it lists. Modules with a metadata key only run against results with
+// that metadata value, such as NTP servers answering monlist, and modules with
+// options set from metadata only against results that have it.
+type MetasploitModule struct {
+	Module      string
+	Services    []string
+	Metadata    string
+	Value       string
+	Options     map[string]string // Module options, set from these metadata keys of the results
+	DefaultPort bool              // The module only probes the default port of the service, so RPORT is not set
+}
+
+var (
+	// METASPLOIT_MODULES are the auxiliary modules the resource script runs
+	// against the open ports, in the order they are run
+	METASPLOIT_MODULES = []MetasploitModule{
+		{Module: "auxiliary/scanner/snmp/snmp_enum", Services: []string{"snmp"}, Options: map[string]string{"COMMUNITY": "community", "VERSION": "snmp_version"}},
+		{Module: "auxiliary/scanner/ipmi/ipmi_version", Services: []string{"ipmi"}},
+		{Module: "auxiliary/scanner/ipmi/ipmi_cipher_zero", Services: []string{"ipmi"}, Metadata: "cipher_zero", Value: "true"},
+		{Module: "auxiliary/scanner/ipmi/ipmi_dumphashes", Services: []string{"ipmi"}},
+		{Module: "auxiliary/scanner/ntp/ntp_monlist", Services: []string{"ntp"}, Metadata: "ddos_reflection", Value: "monlist"},
+		{Module: "auxiliary/scanner/ntp/ntp_readvar", Services: []string{"ntp"}, Metadata: "ddos_reflection", Value: "readvar"},
+		{Module: "auxiliary/scanner/memcached/memcached_udp_version", Services: []string{"memcache"}},
+		{Module: "auxiliary/scanner/memcached/memcached_amp", Services: []string{"memcache"}},
+		{Module: "auxiliary/scanner/dns/dns_amp", Services: []string{"dns"}, Metadata: "recursion", Value: "available"},
+		{Module: "auxiliary/scanner/chargen/chargen_probe", Services: []string{"chargen"}},
+		{Module: "auxiliary/scanner/portmap/portmap_amp", Services: []string{"portmap"}},
+		{Module: "auxiliary/scanner/upnp/ssdp_msearch", Services: []string{"upnp"}},
+		{Module: "auxiliary/scanner/upnp/ssdp_amp", Services: []string{"upnp"}},
+		{Module: "auxiliary/scanner/netbios/nbname", Services: []string{"netbios"}},
+		{Module: "auxiliary/scanner/mssql/mssql_ping", Services: []string{"mssql"}, DefaultPort: true},
+		{Module: "auxiliary/scanner/mdns/query", Services: []string{"mdns"}},
+		{Module: "auxiliary/scanner/llmnr/query", Services: []string{"llmnr"}},
+		{Module: "auxiliary/scanner/wsddp/wsdd_query", Services: []string{"wsd"}},
+		{Module: "auxiliary/scanner/sip/options", Services: []string{"sip"}},
+		{Module: "auxiliary/scanner/tftp/tftpbrute", Services: []string{"tftp"}},
+		{Module: "auxiliary/scanner/db2/discovery", Services: []string{"db2"}},
+		{Module: "auxiliary/scanner/vxworks/wdbrpc_version", Services: []string{"wdbrpc"}},
+		{Module: "auxiliary/gather/natpmp_external_address", Services: []string{"nat-pmp"}},
+	}
+)
+
+// metasploitRun is a run of a module against the hosts sharing a port and
+// module options
+type metasploitRun struct {
+	port    uint16
+	options []string // NAME VALUE
+	hosts   []string
+}
+
+// runs returns the runs of the module against the open ports of the results,
+// in the order of the results
+func (module MetasploitModule) runs(results []Result) (runs []*metasploitRun) {
+
+	names := make([]string, 0, len(module.Options))
+
+	for name := range module.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	byKey := map[string]*metasploitRun{}
+	hosts := map[string]bool{}
+
+	for _, result := range results {
+
+		if result.State != PORT_STATE_OPEN || !matchesService(result, module.Services, module.Metadata, module.Value) {
+			continue
+		}
+		run := &metasploitRun{options: []string{}}
+
+		if !module.DefaultPort {
+			run.port = result.Port
+		}
+		for _, name := range names {
+
+			value, ok := result.Metadata[module.Options[name]]
+
+			if !ok || value == "" {
+				break
+			}
+			run.options = append(run.options, name+" "+value)
+		}
+		if len(run.options) != len(names) {
+			continue
+		}
+		key := fmt.Sprintf("%d %s", run.port, strings.Join(run.options, "\n"))
+
+		if existing, ok := byKey[key]; ok {
+			run = existing
+		} else {
+			byKey[key] = run
+			runs = append(runs, run)
+		}
+		host := strings.Trim(result.Host.Host, "[]")
+
+		if !hosts[key+"\n"+host] {
+			hosts[key+"\n"+host] = true
+			run.hosts = append(run.hosts, host)
+		}
+	}
+	return
+}
+
+// SaveMetasploit writes an msfconsole resource script running the auxiliary
+// modules of the services found against their open ports, with RHOSTS, RPORT
+// and the options the responses revealed, such as SNMP communities, filled in
+func (sc *UdpProbeScanner) SaveMetasploit(output io.Writer, args []string) (err error) {
+
+	if _, err = fmt.Fprintf(output, "# udpz %s resource script for msfconsole -r, scan initiated %s as: %s\n",
+		VERSION, sc.startTime.Format(time.ANSIC), strings.Join(args, " ")); err != nil {
+		return
+	}
+	for _, module := range METASPLOIT_MODULES {
+		for _, run := range module.runs(sc.results) {
+
+			lines := []string{"", "use " + module.Module, "set RHOSTS " + strings.Join(run.hosts, " ")}
+
+			if run.port != 0 {
+				lines = append(lines, fmt.Sprintf("set RPORT %d", run.port))
+			}
+			for _, option := range run.options {
+				lines = append(lines, "set "+option)
+			}
+			lines = append(lines, "run")
+
+			if _, err = fmt.Fprintln(output, strings.Join(lines, "\n")); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
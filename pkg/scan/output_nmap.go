@@ -0,0 +1,307 @@
+package scan
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// NMAP_SERVICE_NAMES maps service slugs to the names nmap uses for them, so
+	// tools that key on nmap service names recognize the results
+	NMAP_SERVICE_NAMES = map[string]string{
+		"dns":      "domain",
+		"ike":      "isakmp",
+		"kerberos": "kerberos-sec",
+		"mdns":     "zeroconf",
+		"mssql":    "ms-sql-m",
+		"netbios":  "netbios-ns",
+		"pca":      "pcanywherestat",
+		"portmap":  "rpcbind",
+		"rip":      "route",
+		"slp":      "svrloc",
+		"ard":      "netassistant",
+		"ipmi":     "asf-rmcp",
+		"rdpudp":   "ms-wbt-server",
+		"wsd":      "ws-discovery",
+	}
+)
+
+type nmapRun struct {
+	XMLName          xml.Name     `xml:"nmaprun"`
+	Scanner          string       `xml:"scanner,attr"`
+	Args             string       `xml:"args,attr"`
+	Start            int64        `xml:"start,attr"`
+	StartStr         string       `xml:"startstr,attr"`
+	Version          string       `xml:"version,attr"`
+	XMLOutputVersion string       `xml:"xmloutputversion,attr"`
+	ScanInfo         nmapScanInfo `xml:"scaninfo"`
+	Hosts            []nmapHost   `xml:"host"`
+	RunStats         nmapRunStats `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapHost struct {
+	StartTime int64       `xml:"starttime,attr"`
+	EndTime   int64       `xml:"endtime,attr"`
+	Status    nmapStatus  `xml:"status"`
+	Address   nmapAddress `xml:"address"`
+	Hostnames []nmapName  `xml:"hostnames>hostname"`
+	Ports     []nmapPort  `xml:"ports>port"`
+}
+
+type nmapStatus struct {
+	State     string `xml:"state,attr"`
+	Reason    string `xml:"reason,attr"`
+	ReasonTTL int    `xml:"reason_ttl,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type nmapName struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   uint16       `xml:"portid,attr"`
+	State    nmapStatus   `xml:"state"`
+	Service  *nmapService `xml:"service,omitempty"`
+	Scripts  []nmapScript `xml:"script"`
+}
+
+type nmapService struct {
+	Name      string   `xml:"name,attr"`
+	Product   string   `xml:"product,attr,omitempty"`
+	Version   string   `xml:"version,attr,omitempty"`
+	ExtraInfo string   `xml:"extrainfo,attr,omitempty"`
+	Method    string   `xml:"method,attr"`
+	Conf      int      `xml:"conf,attr"`
+	CPE       []string `xml:"cpe,omitempty"`
+}
+
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished `xml:"finished"`
+	Hosts    nmapHosts    `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64   `xml:"time,attr"`
+	TimeStr string  `xml:"timestr,attr"`
+	Elapsed float64 `xml:"elapsed,attr"`
+	Summary string  `xml:"summary,attr"`
+	Exit    string  `xml:"exit,attr"`
+}
+
+type nmapHosts struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// nmapState converts a port state into the state and reason nmap would report
+func nmapState(state string) nmapStatus {
+	switch state {
+	case PORT_STATE_OPEN:
+		return nmapStatus{State: "open", Reason: "udp-response"}
+	case PORT_STATE_CLOSED:
+		return nmapStatus{State: "closed", Reason: "port-unreach"}
+	default:
+		return nmapStatus{State: "open|filtered", Reason: "no-response"}
+	}
+}
+
+// SaveNmapXML writes the results as nmap-compatible XML so they can be
+// imported by tools that consume nmap output, such as Metasploit's db_import
+func (sc *UdpProbeScanner) SaveNmapXML(output io.Writer, args []string) error {
+
+	run := nmapRun{
+		Scanner:          "udpz",
+		Args:             strings.Join(args, " "),
+		Start:            sc.startTime.Unix(),
+		StartStr:         sc.startTime.Format(time.ANSIC),
+		Version:          VERSION,
+		XMLOutputVersion: "1.05",
+		ScanInfo: nmapScanInfo{
+			Type:     "udp",
+			Protocol: "udp",
+		},
+	}
+
+	scannedPorts := make(map[uint16]bool)
+
+	for _, service := range sc.Services {
+		for _, port := range service.Ports {
+			scannedPorts[port] = true
+		}
+	}
+	portList := make([]int, 0, len(scannedPorts))
+
+	for port := range scannedPorts {
+		portList = append(portList, int(port))
+	}
+	sort.Ints(portList)
+
+	portStrings := make([]string, len(portList))
+
+	for i, port := range portList {
+		portStrings[i] = strconv.Itoa(port)
+	}
+	run.ScanInfo.NumServices = len(portList)
+	run.ScanInfo.Services = strings.Join(portStrings, ",")
+
+	hostIndex := make(map[string]int)
+
+	for _, result := range sc.results {
+
+		index, ok := hostIndex[result.Host.Host]
+
+		if !ok {
+			host := nmapHost{
+				StartTime: sc.startTime.Unix(),
+				EndTime:   sc.endTime.Unix(),
+				Status:    nmapStatus{State: "up", Reason: "udp-response"},
+				Address: nmapAddress{
+					Addr:     strings.Trim(result.Host.Host, "[]"),
+					AddrType: strings.ToLower(result.Host.Type),
+				},
+			}
+			if hostname := result.Host.Hostname(); hostname != "" {
+				host.Hostnames = []nmapName{{Name: hostname, Type: "user"}}
+			}
+			index = len(run.Hosts)
+			hostIndex[result.Host.Host] = index
+			run.Hosts = append(run.Hosts, host)
+		}
+
+		port := nmapPort{
+			Protocol: "udp",
+			PortID:   result.Port,
+			State:    nmapState(result.State),
+		}
+		if result.Service.Slug != "" {
+
+			name, ok := NMAP_SERVICE_NAMES[result.Service.Slug]
+			if !ok {
+				name = result.Service.Slug
+			}
+			port.Service = &nmapService{
+				Name:    name,
+				Product: result.Service.Name,
+				Method:  "table",
+				Conf:    3,
+			}
+			if result.State == PORT_STATE_OPEN {
+				port.Service.Method = "probed"
+				port.Service.Conf = 10
+				port.Service.ExtraInfo = "probe: " + result.Probe.Name
+				port.Service.Version = result.Version
+
+				for _, cpe := range result.CPE {
+					port.Service.CPE = append(port.Service.CPE, nmapCPE(cpe))
+				}
+			}
+			if result.Banner != "" {
+				port.Scripts = append(port.Scripts, nmapScript{ID: "banner", Output: result.Banner})
+			}
+			if len(result.Vulnerabilities) > 0 {
+				port.Scripts = append(port.Scripts, nmapScript{ID: "vulners", Output: nmapVulners(result.Vulnerabilities)})
+			}
+		}
+		run.Hosts[index].Ports = append(run.Hosts[index].Ports, port)
+	}
+
+	elapsed := sc.endTime.Sub(sc.startTime).Seconds()
+
+	run.RunStats = nmapRunStats{
+		Finished: nmapFinished{
+			Time:    sc.endTime.Unix(),
+			TimeStr: sc.endTime.Format(time.ANSIC),
+			Elapsed: elapsed,
+			Summary: fmt.Sprintf("udpz done; %d hosts up scanned in %.2f seconds", len(run.Hosts), elapsed),
+			Exit:    "success",
+		},
+		Hosts: nmapHosts{
+			Up:    len(run.Hosts),
+			Total: len(run.Hosts),
+		},
+	}
+
+	if _, err := io.WriteString(output, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(output)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(&run); err != nil {
+		return err
+	}
+	_, err := io.WriteString(output, "\n")
+	return err
+}
+
+// nmapCPE converts a CPE 2.3 formatted string to the CPE 2.2 URI nmap reports,
+// such as cpe:/a:ntp:ntp:4.2.8:p15
+func nmapCPE(cpe string) string {
+
+	fields := cpeFields(cpe)
+
+	if len(fields) < 3 {
+		return cpe
+	}
+	fields = fields[2:]
+
+	// Fields that match anything are empty, and left out at the end
+	for i, field := range fields {
+
+		if field == "*" {
+			fields[i] = ""
+			continue
+		}
+		var encoded strings.Builder
+
+		for _, char := range field {
+			if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') || char == '.' || char == '-' || char == '_' {
+				encoded.WriteRune(char)
+			} else {
+				fmt.Fprintf(&encoded, "%%%02x", char)
+			}
+		}
+		fields[i] = encoded.String()
+	}
+	for len(fields) > 1 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+	return "cpe:/" + strings.Join(fields, ":")
+}
+
+// nmapVulners formats vulnerabilities the way the output of the vulners NSE
+// script lists them
+func nmapVulners(vulnerabilities []Vulnerability) string {
+
+	var output strings.Builder
+
+	for _, vulnerability := range vulnerabilities {
+		fmt.Fprintf(&output, "\n  %s\t%.1f", vulnerability.ID, vulnerability.CVSS)
+	}
+	return output.String()
+}
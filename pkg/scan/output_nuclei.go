@@ -0,0 +1,36 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+)
+
+// SaveNucleiTargets writes the open ports as targets for nuclei or other
+// follow-up tools, a udp://HOST:PORT line for each and a line tagged with the
+// protocol of the service, such as snmp://HOST:PORT, so templates can be
+// matched to them. Ports answering several probes are written once.
+func (sc *UdpProbeScanner) SaveNucleiTargets(output io.Writer) error {
+
+	written := map[string]bool{}
+
+	for _, result := range sc.results {
+
+		if result.State != PORT_STATE_OPEN {
+			continue
+		}
+		address := fmt.Sprintf("%s:%d", result.Host.Host, result.Port)
+
+		for _, target := range []string{"udp://" + address, result.Service.Slug + "://" + address} {
+
+			if written[target] {
+				continue
+			}
+			written[target] = true
+
+			if _, err := fmt.Fprintln(output, target); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package scan
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// SQLITE_SCHEMA creates the results tables if they do not already exist, so
+	// a single database can collect the results of many scans. Probes are
+	// shared between scans and keyed by their slug.
+	SQLITE_SCHEMA = `
+CREATE TABLE IF NOT EXISTS scans (
+	id         INTEGER PRIMARY KEY,
+	started_at TIMESTAMP NOT NULL,
+	ended_at   TIMESTAMP NOT NULL,
+	command    TEXT NOT NULL,
+	version    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS hosts (
+	id      INTEGER PRIMARY KEY,
+	scan_id INTEGER NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	address TEXT NOT NULL,
+	type    TEXT NOT NULL,
+	target  TEXT NOT NULL,
+	UNIQUE (scan_id, address)
+);
+CREATE TABLE IF NOT EXISTS ports (
+	id           INTEGER PRIMARY KEY,
+	host_id      INTEGER NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+	port         INTEGER NOT NULL,
+	transport    TEXT NOT NULL,
+	state        TEXT NOT NULL,
+	service      TEXT NOT NULL,
+	service_name TEXT NOT NULL,
+	version      TEXT,
+	banner       TEXT,
+	metadata     TEXT,
+	UNIQUE (host_id, port, service)
+);
+CREATE TABLE IF NOT EXISTS probes (
+	id      INTEGER PRIMARY KEY,
+	slug    TEXT NOT NULL UNIQUE,
+	name    TEXT NOT NULL,
+	service TEXT NOT NULL,
+	data    BLOB
+);
+CREATE TABLE IF NOT EXISTS responses (
+	id       INTEGER PRIMARY KEY,
+	port_id  INTEGER NOT NULL REFERENCES ports(id) ON DELETE CASCADE,
+	probe_id INTEGER NOT NULL REFERENCES probes(id),
+	data     BLOB
+);
+CREATE INDEX IF NOT EXISTS ports_port ON ports (port);
+CREATE INDEX IF NOT EXISTS ports_service ON ports (service);
+`
+)
+
+// SaveSQLite records the scan and its results in the SQLite database at path,
+// creating the database and its tables if needed
+func (sc *UdpProbeScanner) SaveSQLite(path string, args []string) (err error) {
+
+	var db *sql.DB
+	var tx *sql.Tx
+
+	if db, err = sql.Open("sqlite3", path+"?_foreign_keys=on"); err != nil {
+		return
+	}
+	defer db.Close()
+
+	if _, err = db.Exec(SQLITE_SCHEMA); err != nil {
+		return
+	}
+	if tx, err = db.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var scanID int64
+
+	if scanID, err = insertRow(tx,
+		`INSERT INTO scans (started_at, ended_at, command, version) VALUES (?, ?, ?, ?)`,
+		sc.startTime.UTC(), sc.endTime.UTC(), strings.Join(args, " "), VERSION); err != nil {
+		return
+	}
+
+	hostIDs := make(map[string]int64)
+	portIDs := make(map[string]int64)
+
+	for _, result := range sc.results {
+
+		hostID, ok := hostIDs[result.Host.Host]
+
+		if !ok {
+			if hostID, err = insertRow(tx,
+				`INSERT INTO hosts (scan_id, address, type, target) VALUES (?, ?, ?, ?)`,
+				scanID, result.Host.Host, result.Host.Type, result.Host.Target.Target); err != nil {
+				return
+			}
+			hostIDs[result.Host.Host] = hostID
+		}
+
+		portKey := fmt.Sprintf("%s/%d/%s", result.Host.Host, result.Port, result.Service.Slug)
+
+		if _, ok := portIDs[portKey]; ok {
+			continue
+		}
+		var metadata []byte
+		var portID int64
+
+		if len(result.Metadata) > 0 {
+			if metadata, err = json.Marshal(result.Metadata); err != nil {
+				return
+			}
+		}
+		if portID, err = insertRow(tx,
+			`INSERT INTO ports (host_id, port, transport, state, service, service_name, version, banner, metadata)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			hostID, result.Port, result.Transport, result.State, result.Service.Slug, result.Service.Name,
+			nullString(result.Version), nullString(result.Banner), nullString(string(metadata))); err != nil {
+			return
+		}
+		portIDs[portKey] = portID
+
+		// Every probe answered on the port has its response recorded, not only
+		// the one that identified it
+		for _, response := range sc.resultsMap[result.Host.Host][result.Port] {
+			if err = insertResponse(tx, portID, response); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// insertResponse records the probe and the response it got on a port. Probes
+// are updated in place, since probe files may redefine a built-in probe.
+func insertResponse(tx *sql.Tx, portID int64, result Result) (err error) {
+
+	if result.Probe.Slug == "" {
+		return nil
+	}
+	var probeID int64
+	var probeData []byte
+
+	if probeData, err = base64.StdEncoding.DecodeString(result.Probe.EncodedData); err != nil {
+		return
+	}
+	if _, err = tx.Exec(
+		`INSERT INTO probes (slug, name, service, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (slug) DO UPDATE SET name = excluded.name, service = excluded.service, data = excluded.data`,
+		result.Probe.Slug, result.Probe.Name, result.Probe.Service, probeData); err != nil {
+		return
+	}
+	if err = tx.QueryRow(`SELECT id FROM probes WHERE slug = ?`, result.Probe.Slug).Scan(&probeID); err != nil {
+		return
+	}
+	_, err = tx.Exec(
+		`INSERT INTO responses (port_id, probe_id, data) VALUES (?, ?, ?)`,
+		portID, probeID, result.payload)
+	return
+}
+
+// insertRow executes an insert statement and returns the ID of the new row
+func insertRow(tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+
+	result, err := tx.Exec(query, args...)
+
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// nullString stores empty strings as NULL
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
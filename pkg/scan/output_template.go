@@ -0,0 +1,53 @@
+package scan
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is what templates given to SaveTemplate are executed with
+type TemplateData struct {
+	Results []Result
+	Version string
+	Start   time.Time
+	End     time.Time
+}
+
+var (
+	// TEMPLATE_FUNCS are the functions templates can call besides the built-in
+	// ones of text/template
+	TEMPLATE_FUNCS = template.FuncMap{
+		"join":    strings.Join,
+		"lower":   strings.ToLower,
+		"upper":   strings.ToUpper,
+		"replace": strings.ReplaceAll,
+		"trim":    strings.TrimSpace,
+	}
+)
+
+// LoadTemplate parses the output template at path
+func LoadTemplate(path string) (*template.Template, error) {
+
+	text, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+	return template.New(filepath.Base(path)).Funcs(TEMPLATE_FUNCS).Parse(string(text))
+}
+
+// SaveTemplate writes the results through a text/template, which is given the
+// results of the scan in order along with when it ran
+func (sc *UdpProbeScanner) SaveTemplate(outputTemplate *template.Template, output io.Writer) error {
+
+	return outputTemplate.Execute(output, TemplateData{
+		Results: sc.results,
+		Version: VERSION,
+		Start:   sc.startTime,
+		End:     sc.endTime,
+	})
+}
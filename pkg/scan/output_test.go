@@ -0,0 +1,439 @@
+package scan
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"udpz/pkg/data"
+)
+
+// reflectionResult builds a result for reflectionExposure, which only looks at
+// the service and metadata
+func reflectionResult(service string, metadata map[string]string) Result {
+	return Result{Service: data.UdpService{NameShort: service}, Metadata: metadata}
+}
+
+func TestReflectionExposure(t *testing.T) {
+
+	resultsMap := map[string]map[uint16][]Result{
+		"10.0.0.1": {
+			19: {reflectionResult("CharGen", map[string]string{"ddos_reflection": "chargen", "amplification": "74.0"})},
+			123: {
+				reflectionResult("NTP", map[string]string{"ddos_reflection": "readvar", "amplification": "4.2"}),
+				reflectionResult("NTP", map[string]string{"ddos_reflection": "monlist", "amplification": "55.0"}),
+			},
+			161: {reflectionResult("SNMP", map[string]string{"sysname": "udpz"})},
+		},
+		"10.0.0.2": {
+			7: {reflectionResult("Echo", map[string]string{"ddos_reflection": "echo", "amplification": "1.0"})},
+		},
+	}
+	want := []reflector{
+		{"10.0.0.1", 19, "CharGen", "chargen", 74},
+		{"10.0.0.1", 123, "NTP", "monlist", 55},
+		{"10.0.0.2", 7, "Echo", "echo", 1},
+	}
+	if reflectors := reflectionExposure(resultsMap); !reflect.DeepEqual(reflectors, want) {
+		t.Errorf("got %+v, want %+v", reflectors, want)
+	}
+}
+
+func TestAmplificationCandidates(t *testing.T) {
+
+	// amplificationResult builds a result answering a probe of the given size
+	// with a response of the given size
+	amplificationResult := func(host string, port uint16, state string, request int, response int) Result {
+		return Result{
+			Host:     Host{Host: host},
+			Port:     port,
+			State:    state,
+			Probe:    data.UdpProbe{Slug: "probe", EncodedData: base64.StdEncoding.EncodeToString(make([]byte, request))},
+			Response: base64.StdEncoding.EncodeToString(make([]byte, response)),
+			Service:  data.UdpService{Slug: "service"},
+		}
+	}
+	scanner := UdpProbeScanner{results: []Result{
+		amplificationResult("10.0.0.1", 7, PORT_STATE_OPEN, 4, 4),
+		amplificationResult("10.0.0.1", 19, PORT_STATE_OPEN, 1, 74),
+		amplificationResult("10.0.0.2", 123, PORT_STATE_OPEN, 8, 440),
+		amplificationResult("10.0.0.2", 161, PORT_STATE_OPEN_FILTERED, 40, 0),
+		amplificationResult("10.0.0.3", 17, PORT_STATE_OPEN, 1, 74),
+	}}
+	want := []AmplificationCandidate{
+		{"10.0.0.1", 19, "service", "probe", 1, 74, 74},
+		{"10.0.0.3", 17, "service", "probe", 1, 74, 74},
+		{"10.0.0.2", 123, "service", "probe", 8, 440, 55},
+		{"10.0.0.1", 7, "service", "probe", 4, 4, 1},
+	}
+	if candidates := scanner.AmplificationCandidates(); !reflect.DeepEqual(candidates, want) {
+		t.Errorf("got %+v, want %+v", candidates, want)
+	}
+}
+
+func TestNmapCPE(t *testing.T) {
+
+	tests := []struct {
+		cpe  string
+		want string
+	}{
+		{"cpe:2.3:a:ntp:ntp:4.2.8:p15:*:*:*:*:*:*", "cpe:/a:ntp:ntp:4.2.8:p15"},
+		{"cpe:2.3:a:thekelleys:dnsmasq:2.80:*:*:*:*:*:*:*", "cpe:/a:thekelleys:dnsmasq:2.80"},
+		{`cpe:2.3:o:cisco:ios:15.0\(2\)se11:*:*:*:*:*:*:*`, "cpe:/o:cisco:ios:15.0%282%29se11"},
+	}
+	for _, test := range tests {
+		if got := nmapCPE(test.cpe); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.cpe, got, test.want)
+		}
+	}
+}
+
+func TestSaveTemplate(t *testing.T) {
+
+	scanner := UdpProbeScanner{results: []Result{
+		{Host: Host{Host: "10.0.0.1"}, Port: 161, Service: data.UdpService{Slug: "snmp"}, Version: "net-snmp 5.9"},
+		{Host: Host{Host: "10.0.0.2"}, Port: 53, Service: data.UdpService{Slug: "dns"}, CPE: []string{"cpe:a", "cpe:b"}},
+	}}
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{"{{range .Results}}{{.Host.Host}}:{{.Port}} {{upper .Service.Slug}}\n{{end}}", "10.0.0.1:161 SNMP\n10.0.0.2:53 DNS\n", true},
+		{"{{range .Results}}|{{replace .Version \" \" \"_\"}}|{{join .CPE \",\"}}|\n{{end}}", "|net-snmp_5.9||\n||cpe:a,cpe:b|\n", true},
+		{"udpz {{.Version}}", "udpz " + VERSION, true},
+		{"{{.Missing}}", "", false},
+	}
+	for _, test := range tests {
+		var output strings.Builder
+
+		outputTemplate, err := template.New("test").Funcs(TEMPLATE_FUNCS).Parse(test.text)
+
+		if err == nil {
+			err = scanner.SaveTemplate(outputTemplate, &output)
+		}
+		if (err == nil) != test.ok || (test.ok && output.String() != test.want) {
+			t.Errorf("%q: got %q, %v, want %q", test.text, output.String(), err, test.want)
+		}
+	}
+}
+
+func TestSaveGrepable(t *testing.T) {
+
+	scanner := UdpProbeScanner{results: []Result{
+		{Host: Host{Host: "10.0.0.1"}, Port: 161, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "snmp"}, Version: "net-snmp 5.9/x"},
+		{Host: Host{Host: "[2001:db8::1]", Target: Target{Type: "hostname", Target: "ns.example"}}, Port: 53, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "dns"}, Banner: "bind\t9"},
+		{Host: Host{Host: "10.0.0.2"}, Port: 500, State: PORT_STATE_OPEN_FILTERED, Service: data.UdpService{Slug: "ike"}},
+	}}
+	var output strings.Builder
+
+	if err := scanner.SaveGrepable(&output, []string{"udpz", "10.0.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+
+	want := []string{
+		"Host: 10.0.0.1 ()\tPorts: 161/open/udp//snmp//net-snmp 5.9|x/",
+		"Host: 2001:db8::1 (ns.example)\tPorts: 53/open/udp//domain//bind 9/",
+		"Host: 10.0.0.2 ()\tPorts: 500/open|filtered/udp//isakmp///",
+	}
+	if len(lines) != len(want)+2 || !strings.HasSuffix(lines[0], "as: udpz 10.0.0.0/24") || !strings.Contains(lines[len(lines)-1], "3 results") {
+		t.Fatalf("got %q, want a header, %d results and a footer", lines, len(want))
+	}
+	for i, line := range want {
+		if lines[i+1] != line {
+			t.Errorf("result %d: got %q, want %q", i, lines[i+1], line)
+		}
+	}
+}
+
+func TestSaveMasscan(t *testing.T) {
+
+	scanner := UdpProbeScanner{startTime: time.Unix(1700000000, 0), results: []Result{
+		{Host: Host{Host: "10.0.0.1"}, Port: 161, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "snmp"}, Version: "net-snmp\n5.9"},
+		{Host: Host{Host: "[2001:db8::1]"}, Port: 53, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "dns"}},
+		{Host: Host{Host: "10.0.0.2"}, Port: 500, State: PORT_STATE_OPEN_FILTERED, Service: data.UdpService{Slug: "ike"}},
+	}}
+	var list, records strings.Builder
+
+	if err := scanner.SaveMasscanList(&list); err != nil {
+		t.Fatal(err)
+	}
+	want := "#masscan\n" +
+		"open udp 161 10.0.0.1 1700000000\n" +
+		"banner udp 161 10.0.0.1 1700000000 snmp net-snmp 5.9\n" +
+		"open udp 53 2001:db8::1 1700000000\n" +
+		"# end\n"
+
+	if list.String() != want {
+		t.Errorf("list: got %q, want %q", list.String(), want)
+	}
+	if err := scanner.SaveMasscanJSON(&records); err != nil {
+		t.Fatal(err)
+	}
+	want = `[{"ip":"10.0.0.1","timestamp":"1700000000","ports":[{"port":161,"proto":"udp","status":"open","reason":"response"}]},` +
+		`{"ip":"10.0.0.1","timestamp":"1700000000","ports":[{"port":161,"proto":"udp","service":{"name":"snmp","banner":"net-snmp 5.9"}}]},` +
+		`{"ip":"2001:db8::1","timestamp":"1700000000","ports":[{"port":53,"proto":"udp","status":"open","reason":"response"}]}]` + "\n"
+
+	if records.String() != want {
+		t.Errorf("json: got %s, want %s", records.String(), want)
+	}
+}
+
+func TestFindings(t *testing.T) {
+
+	scanner := UdpProbeScanner{results: []Result{
+		{Host: Host{Host: "10.0.0.1"}, Port: 623, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "ipmi"}, Metadata: map[string]string{"cipher_zero": "true"}},
+		{Host: Host{Host: "10.0.0.1"}, Port: 623, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "ipmi"}},
+		{Host: Host{Host: "10.0.0.1"}, Port: 123, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "ntp", NameShort: "NTP"}, Metadata: map[string]string{"ddos_reflection": "readvar"}},
+		{Host: Host{Host: "10.0.0.2"}, Port: 53, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "dns", NameShort: "DNS"}},
+		{Host: Host{Host: "10.0.0.2"}, Port: 47808, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "bacnet", NameShort: "BACnet"}},
+		{Host: Host{Host: "10.0.0.3"}, Port: 11211, State: PORT_STATE_OPEN_FILTERED, Service: data.UdpService{Slug: "memcache"}},
+	}}
+	want := []struct {
+		host     string
+		port     uint16
+		title    string
+		severity string
+	}{
+		{"10.0.0.1", 623, "IPMI cipher suite 0 authentication bypass", "Critical"},
+		{"10.0.0.1", 623, "Exposed IPMI service", "High"},
+		{"10.0.0.1", 123, "NTP mode 6 queries enabled", "Medium"},
+		{"10.0.0.2", 47808, "Exposed BACnet industrial control service", "High"},
+		{"10.0.0.2", 53, "Open DNS service", "Info"},
+	}
+	findings := scanner.Findings()
+
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings, want %d", len(findings), len(want))
+	}
+	for i, finding := range findings {
+		if finding.Result.Host.Host != want[i].host || finding.Result.Port != want[i].port || finding.Title != want[i].title || finding.Severity != want[i].severity {
+			t.Errorf("finding %d: got %s:%d %q %s, want %+v", i, finding.Result.Host.Host, finding.Result.Port, finding.Title, finding.Severity, want[i])
+		}
+	}
+}
+
+func TestSaveNucleiTargets(t *testing.T) {
+
+	scanner := UdpProbeScanner{results: []Result{
+		{Host: Host{Host: "10.0.0.1"}, Port: 161, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "snmp"}},
+		{Host: Host{Host: "10.0.0.1"}, Port: 161, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "snmp"}},
+		{Host: Host{Host: "[2001:db8::1]"}, Port: 53, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "dns"}},
+		{Host: Host{Host: "10.0.0.2"}, Port: 500, State: PORT_STATE_OPEN_FILTERED, Service: data.UdpService{Slug: "ike"}},
+	}}
+	var output strings.Builder
+
+	if err := scanner.SaveNucleiTargets(&output); err != nil {
+		t.Fatal(err)
+	}
+	want := "udp://10.0.0.1:161\nsnmp://10.0.0.1:161\nudp://[2001:db8::1]:53\ndns://[2001:db8::1]:53\n"
+
+	if output.String() != want {
+		t.Errorf("got %q, want %q", output.String(), want)
+	}
+}
+
+func TestSaveMetasploit(t *testing.T) {
+
+	scanner := UdpProbeScanner{results: []Result{
+		{Host: Host{Host: "10.0.0.1"}, Port: 161, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "snmp"}, Metadata: map[string]string{"community": "public", "snmp_version": "2c"}},
+		{Host: Host{Host: "10.0.0.2"}, Port: 161, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "snmp"}, Metadata: map[string]string{"community": "public", "snmp_version": "2c"}},
+		{Host: Host{Host: "10.0.0.2"}, Port: 161, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "snmp"}, Metadata: map[string]string{"community": "public", "snmp_version": "2c"}},
+		{Host: Host{Host: "10.0.0.3"}, Port: 161, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "snmp"}, Metadata: map[string]string{"snmp_version": "3"}},
+		{Host: Host{Host: "[2001:db8::1]"}, Port: 1623, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "ipmi"}, Metadata: map[string]string{"cipher_zero": "true"}},
+		{Host: Host{Host: "10.0.0.4"}, Port: 1434, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "mssql"}},
+		{Host: Host{Host: "10.0.0.5"}, Port: 123, State: PORT_STATE_OPEN_FILTERED, Service: data.UdpService{Slug: "ntp"}, Metadata: map[string]string{"ddos_reflection": "monlist"}},
+	}}
+	var output strings.Builder
+
+	if err := scanner.SaveMetasploit(&output, []string{"udpz", "10.0.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	blocks := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n\n")
+
+	want := []string{
+		"use auxiliary/scanner/snmp/snmp_enum\nset RHOSTS 10.0.0.1 10.0.0.2\nset RPORT 161\nset COMMUNITY public\nset VERSION 2c\nrun",
+		"use auxiliary/scanner/ipmi/ipmi_version\nset RHOSTS 2001:db8::1\nset RPORT 1623\nrun",
+		"use auxiliary/scanner/ipmi/ipmi_cipher_zero\nset RHOSTS 2001:db8::1\nset RPORT 1623\nrun",
+		"use auxiliary/scanner/ipmi/ipmi_dumphashes\nset RHOSTS 2001:db8::1\nset RPORT 1623\nrun",
+		"use auxiliary/scanner/mssql/mssql_ping\nset RHOSTS 10.0.0.4\nrun",
+	}
+	if len(blocks) != len(want)+1 || !strings.HasSuffix(blocks[0], "as: udpz 10.0.0.0/24") {
+		t.Fatalf("got %q, want a header and %d modules", blocks, len(want))
+	}
+	for i, block := range want {
+		if blocks[i+1] != block {
+			t.Errorf("module %d: got %q, want %q", i, blocks[i+1], block)
+		}
+	}
+}
+
+func TestSaveAD(t *testing.T) {
+
+	cldap := data.UdpService{Slug: "cldap"}
+	scanner := UdpProbeScanner{results: []Result{
+		{Host: Host{Host: "10.0.0.1"}, Port: 389, State: PORT_STATE_OPEN, Service: cldap, Metadata: map[string]string{"domain": "corp.example.com", "forest": "example.com", "hostname": "rodc1.corp.example.com", "site": "Branch", "dc_roles": "gc,ldap,rodc"}},
+		{Host: Host{Host: "10.0.0.2"}, Port: 389, State: PORT_STATE_OPEN, Service: cldap, Metadata: map[string]string{"domain": "CORP.example.com", "hostname": "dc1.corp.example.com", "site": "HQ"}},
+		{Host: Host{Host: "10.0.0.2"}, Port: 389, State: PORT_STATE_OPEN, Service: cldap, Metadata: map[string]string{"netbios_domain": "CORP", "netbios_name": "DC1", "dc_roles": "pdc,gc,ldap,writable"}},
+		{Host: Host{Host: "10.0.0.3"}, Port: 389, State: PORT_STATE_OPEN, Service: cldap, Metadata: map[string]string{"vendor": "OpenLDAP"}},
+		{Host: Host{Host: "10.0.0.4"}, Port: 161, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "snmp"}, Metadata: map[string]string{"domain": "other.example"}},
+	}}
+	var output strings.Builder
+
+	if err := scanner.SaveAD(&output); err != nil {
+		t.Fatal(err)
+	}
+	var report adReport
+
+	if err := json.Unmarshal([]byte(output.String()), &report); err != nil {
+		t.Fatal(err)
+	}
+	want := []*adDomain{{
+		Domain:        "corp.example.com",
+		Forest:        "example.com",
+		NetBIOSDomain: "CORP",
+		Sites:         []string{"Branch", "HQ"},
+		DomainControllers: []*adDomainController{
+			{IP: "10.0.0.1", Hostname: "rodc1.corp.example.com", Site: "Branch", Roles: []string{"gc", "ldap", "rodc"}},
+			{IP: "10.0.0.2", Hostname: "dc1.corp.example.com", NetBIOSName: "DC1", Site: "HQ", Roles: []string{"pdc", "gc", "ldap", "writable"}},
+		},
+		BloodHoundPython: "bloodhound-python -d corp.example.com -dc dc1.corp.example.com -ns 10.0.0.2 -c All -u USER -p PASSWORD",
+	}}
+	if !reflect.DeepEqual(report.Domains, want) {
+		got, _ := json.Marshal(report.Domains)
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestSaveTableHostname(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		target  Target
+		columns string
+	}{
+		{"address", Target{Type: "IP", Target: "10.0.0.1"}, "Host,Port,State,Service,Version,Probes"},
+		{"hostname", Target{Type: "hostname", Target: "ns.example"}, "Host,Hostname,Port,State,Service,Version,Probes"},
+	}
+	for _, test := range tests {
+
+		scanner := ScannerFromResults([]Result{
+			{Host: Host{Host: "10.0.0.1", Target: test.target}, Port: 53, State: PORT_STATE_OPEN, Service: data.UdpService{Slug: "dns", NameShort: "DNS"}},
+		}, time.Time{}, time.Time{})
+
+		var output strings.Builder
+
+		if err := scanner.SaveTable("csv", nil, &output); err != nil {
+			t.Fatal(err)
+		}
+		if header, _, _ := strings.Cut(output.String(), "\n"); header != test.columns {
+			t.Errorf("%s: got %q, want %q", test.name, header, test.columns)
+		}
+	}
+}
+
+func TestHostSummaries(t *testing.T) {
+
+	dns := data.UdpService{Slug: "dns", NameShort: "DNS"}
+	ntp := data.UdpService{Slug: "ntp", NameShort: "NTP"}
+	first := Host{Host: "10.0.0.10", Target: Target{Type: "hostname", Target: "ns.example"}}
+	second := Host{Host: "10.0.0.9", Target: Target{Type: "CIDR", Target: "10.0.0.0/24"}}
+
+	scanner := ScannerFromResults([]Result{
+		{Host: first, Port: 53, State: PORT_STATE_OPEN_FILTERED, Service: dns},
+		{Host: first, Port: 53, State: PORT_STATE_OPEN, Service: dns},
+		{Host: first, Port: 123, State: PORT_STATE_CLOSED, Service: ntp},
+		{Host: second, Port: 123, State: PORT_STATE_OPEN_FILTERED, Service: ntp},
+	}, time.Time{}, time.Time{})
+
+	stats := scanner.statsFor(first)
+	stats.sent()
+	stats.sent()
+	stats.sent()
+	stats.failed()
+	stats.observe(2 * time.Millisecond)
+	stats.observe(4 * time.Millisecond)
+	scanner.statsFor(Host{Host: "10.0.0.11"}).sent()
+
+	want := []HostSummary{
+		{Host: "10.0.0.9", OpenFiltered: 1},
+		{Host: "10.0.0.10", Hostname: "ns.example", ProbesSent: 3, Open: 1, Closed: 1, Errors: 1, RTT: &RTTStats{Samples: 2, Min: 2, Avg: 3, Max: 4}},
+		{Host: "10.0.0.11", ProbesSent: 1},
+	}
+	if got := scanner.HostSummaries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Reports can be compared and merged like plain results
+	path := t.TempDir() + "/report.json"
+	output, err := os.Create(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = scanner.SaveJsonReport(output); err != nil {
+		t.Fatal(err)
+	}
+	output.Close()
+
+	if results, err := LoadResults(path); err != nil || len(results) != 4 {
+		t.Errorf("loaded report: got %d results (%v), want 4", len(results), err)
+	}
+}
+
+func TestReportedStates(t *testing.T) {
+
+	host := Host{Host: "10.0.0.1", Target: Target{Type: "IP", Target: "10.0.0.1"}}
+	dns := data.UdpService{Slug: "dns", NameShort: "DNS"}
+
+	tests := []struct {
+		name      string
+		openOnly  bool
+		allStates bool
+		states    []string
+	}{
+		{"default", false, false, []string{PORT_STATE_OPEN, PORT_STATE_OPEN_FILTERED}},
+		{"open", true, false, []string{PORT_STATE_OPEN}},
+		{"all states", false, true, []string{PORT_STATE_OPEN, PORT_STATE_OPEN_FILTERED, PORT_STATE_CLOSED}},
+	}
+	for _, test := range tests {
+
+		sc := &UdpProbeScanner{
+			openOnly:   test.openOnly,
+			allStates:  test.allStates,
+			resultsMap: make(map[string]map[uint16][]Result),
+			counters:   new(scanCounters),
+		}
+		sc.handleResult(Result{Host: host, Port: 53, State: PORT_STATE_OPEN, Service: dns})
+		sc.handleResult(Result{Host: host, Port: 161, State: PORT_STATE_OPEN_FILTERED})
+		sc.handleResult(Result{Host: host, Port: 123, State: PORT_STATE_CLOSED})
+
+		states := []string{}
+
+		for _, result := range sc.results {
+			states = append(states, result.State)
+		}
+		if !reflect.DeepEqual(states, test.states) {
+			t.Errorf("%s: got %v, want %v", test.name, states, test.states)
+		}
+
+		// Host summaries count every port either way
+		summary := HostSummary{Host: "10.0.0.1", Open: 1, OpenFiltered: 1, Closed: 1}
+
+		if got := sc.HostSummaries(); len(got) != 1 || !reflect.DeepEqual(got[0], summary) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, summary)
+		}
+	}
+	options := DefaultOptions()
+	options.OpenOnly, options.AllStates = true, true
+
+	if _, err := NewUdpProbeScanner(options); err == nil {
+		t.Errorf("NewUdpProbeScanner with OpenOnly and AllStates succeeded, want an error")
+	}
+}
@@ -0,0 +1,155 @@
+package scan
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TargetOverride changes how the hosts of a target are scanned, so that
+// fragile networks such as printers or OT equipment are scanned gently while
+// the rest of the scope runs at full speed. Unset fields keep the settings of
+// the scan.
+type TargetOverride struct {
+	Target          string        // Host, CIDR or range the override applies to
+	Rate            float64       // Most packets per second sent to the hosts of the target together
+	Retransmissions *uint         // Number of times an unanswered probe is resent
+	ReadTimeout     time.Duration // How long to wait for each response
+	PortConcurrency uint          // Maximum number of probes in flight per host
+}
+
+// ParseTargetOverride parses an override given as TARGET:KEY=VALUE,... such as
+// "10.1.0.0/24:rate=50,retries=1". The keys are rate, retries, timeout in
+// milliseconds and port-tasks.
+func ParseTargetOverride(spec string) (override TargetOverride, err error) {
+
+	// Targets may be IPv6 addresses, while settings hold no colons
+	separator := strings.LastIndex(spec, ":")
+
+	if separator < 1 || separator == len(spec)-1 {
+		return override, errors.New("invalid target override, expected TARGET:KEY=VALUE,...: " + spec)
+	}
+	override.Target = spec[:separator]
+
+	for _, setting := range strings.Split(spec[separator+1:], ",") {
+
+		key, value, found := strings.Cut(strings.TrimSpace(setting), "=")
+
+		if !found {
+			return override, errors.New("invalid target override setting: " + setting)
+		}
+		number, err := strconv.ParseUint(value, 10, 32)
+
+		if err != nil {
+			return override, errors.New("invalid value for target override setting: " + setting)
+		}
+		switch key {
+		case "rate":
+			override.Rate = float64(number)
+		case "retries":
+			retransmissions := uint(number)
+			override.Retransmissions = &retransmissions
+		case "timeout":
+			override.ReadTimeout = time.Duration(number) * time.Millisecond
+		case "port-tasks":
+			override.PortConcurrency = uint(number)
+		default:
+			return override, errors.New("unknown target override setting: " + key)
+		}
+	}
+	return override, nil
+}
+
+// targetOverride is an override with its addresses resolved, and its own rate
+// limiter shared by the hosts of the target
+type targetOverride struct {
+	TargetOverride
+	ranges  []ipRange
+	limiter *rateLimiter
+}
+
+// newTargetOverride resolves the target of the override
+func newTargetOverride(override TargetOverride, resolver *hostResolver) (resolved *targetOverride, err error) {
+
+	resolved = &targetOverride{TargetOverride: override}
+
+	if resolved.ranges, err = parseIPRanges([]string{override.Target}, "override", resolver); err != nil {
+		return nil, err
+	}
+	if override.Rate > 0 {
+		resolved.limiter = newRateLimiter(override.Rate)
+	}
+	return
+}
+
+// newTargetOverrides resolves the targets of the overrides in turn
+func newTargetOverrides(overrides []TargetOverride, resolver *hostResolver) (resolved []*targetOverride, err error) {
+
+	for _, override := range overrides {
+
+		var target *targetOverride
+
+		if target, err = newTargetOverride(override, resolver); err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, target)
+	}
+	return
+}
+
+// SetOverrides scans the hosts of each target with its settings. Hosts use the
+// first override matching them, after those of the rules of engagement.
+func (sc *UdpProbeScanner) SetOverrides(overrides []TargetOverride) (err error) {
+
+	sc.overrides, err = newTargetOverrides(overrides, sc.resolver)
+	return
+}
+
+// overrideFor returns the override the address is scanned with, if any
+func (sc *UdpProbeScanner) overrideFor(ip net.IP) *targetOverride {
+
+	var overrides []*targetOverride
+
+	if sc.roe != nil {
+		overrides = append(overrides, sc.roe.overrides...)
+	}
+	for _, override := range append(overrides, sc.overrides...) {
+		for _, target := range override.ranges {
+			if target.contains(ip) {
+				return override
+			}
+		}
+	}
+	return nil
+}
+
+// hostRetransmissions returns the number of times unanswered probes to the
+// host are resent
+func (sc *UdpProbeScanner) hostRetransmissions(host Host) uint {
+
+	if host.override != nil && host.override.Retransmissions != nil {
+		return *host.override.Retransmissions
+	}
+	return sc.Retransmissions
+}
+
+// hostPortConcurrency returns the number of probes that may be in flight to
+// the host at once
+func (sc *UdpProbeScanner) hostPortConcurrency(host Host) uint {
+
+	if host.override != nil && host.override.PortConcurrency > 0 {
+		return host.override.PortConcurrency
+	}
+	return sc.PortConcurrency
+}
+
+// hostReadTimeout returns how long to wait for each response from the host
+func (sc *UdpProbeScanner) hostReadTimeout(host Host) time.Duration {
+
+	if host.override != nil && host.override.ReadTimeout > 0 {
+		return host.override.ReadTimeout
+	}
+	return sc.ReadTimeout
+}
@@ -0,0 +1,91 @@
+package scan
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseTargetOverride(t *testing.T) {
+
+	one := uint(1)
+
+	tests := []struct {
+		spec     string
+		override TargetOverride
+		ok       bool
+	}{
+		{"10.1.0.0/24:rate=50,retries=1", TargetOverride{Target: "10.1.0.0/24", Rate: 50, Retransmissions: &one}, true},
+		{"printer.local:timeout=5000, port-tasks=2", TargetOverride{Target: "printer.local", ReadTimeout: 5 * time.Second, PortConcurrency: 2}, true},
+		{"2001:db8::/64:rate=10", TargetOverride{Target: "2001:db8::/64", Rate: 10}, true},
+		{"10.1.0.0/24", TargetOverride{}, false},
+		{"10.1.0.0/24:", TargetOverride{}, false},
+		{":rate=5", TargetOverride{}, false},
+		{"10.1.0.0/24:rate", TargetOverride{}, false},
+		{"10.1.0.0/24:rate=fast", TargetOverride{}, false},
+		{"10.1.0.0/24:burst=5", TargetOverride{}, false},
+	}
+	for _, test := range tests {
+		override, err := ParseTargetOverride(test.spec)
+
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got %+v, %v, want ok %v", test.spec, override, err, test.ok)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		if override.Target != test.override.Target || override.Rate != test.override.Rate ||
+			override.ReadTimeout != test.override.ReadTimeout || override.PortConcurrency != test.override.PortConcurrency ||
+			(override.Retransmissions == nil) != (test.override.Retransmissions == nil) ||
+			(override.Retransmissions != nil && *override.Retransmissions != *test.override.Retransmissions) {
+			t.Errorf("%s: got %+v, want %+v", test.spec, override, test.override)
+		}
+	}
+}
+
+func TestOverrideFor(t *testing.T) {
+
+	zero := uint(0)
+
+	options := DefaultOptions()
+	options.RulesOfEngagement = &RulesOfEngagement{Overrides: []string{"10.1.0.0/24:retries=1"}}
+	options.Overrides = []TargetOverride{
+		{Target: "10.1.0.10", PortConcurrency: 1},
+		{Target: "10.0.0.0/8", Retransmissions: &zero, ReadTimeout: time.Second, Rate: 10},
+	}
+	sc, err := NewUdpProbeScanner(options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip              string
+		target          string
+		retransmissions uint
+		portConcurrency uint
+		readTimeout     time.Duration
+	}{
+		{"10.1.0.10", "10.1.0.0/24", 1, 50, 3 * time.Second},
+		{"10.2.0.1", "10.0.0.0/8", 0, 50, time.Second},
+		{"192.168.0.1", "", 2, 50, 3 * time.Second},
+	}
+	for _, test := range tests {
+		host := newHost(Target{}, net.ParseIP(test.ip), "")
+		host.override = sc.overrideFor(host.ip)
+
+		target := ""
+		if host.override != nil {
+			target = host.override.Target
+		}
+		if target != test.target || sc.hostRetransmissions(host) != test.retransmissions ||
+			sc.hostPortConcurrency(host) != test.portConcurrency || sc.hostReadTimeout(host) != test.readTimeout {
+			t.Errorf("%s: got %q, %d, %d, %v, want %q, %d, %d, %v", test.ip, target,
+				sc.hostRetransmissions(host), sc.hostPortConcurrency(host), sc.hostReadTimeout(host),
+				test.target, test.retransmissions, test.portConcurrency, test.readTimeout)
+		}
+	}
+	if override := sc.overrideFor(net.ParseIP("10.2.0.1")); override.limiter == nil || override.limiter.currentRate() != 10 {
+		t.Errorf("10.2.0.1: got limiter %+v, want a rate of %v", override.limiter, 10)
+	}
+}
@@ -0,0 +1,225 @@
+package scan
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// PCAP_DEFAULT_TTL is recorded when the TTL of a packet is unknown
+	PCAP_DEFAULT_TTL = 64
+)
+
+// PcapWriter records every probe and response as an IP/UDP packet, and every
+// ICMP error about a scanned host as an IP/ICMP packet, in pcap format.
+// Packets are synthesized from the socket addresses, payloads and TTLs rather
+// than captured off the wire, so no special privileges are needed, but IP
+// options, fragmentation and checksums are not those seen on the wire. Probes
+// relayed through a SOCKS5 proxy are recorded as if sent from the local socket
+// straight to the target, though the target sees them come from the relay.
+type PcapWriter struct {
+	mu     sync.Mutex
+	writer *pcapgo.Writer
+}
+
+func NewPcapWriter(output io.Writer) (*PcapWriter, error) {
+
+	writer := pcapgo.NewWriter(output)
+
+	if err := writer.WriteFileHeader(0x10000, layers.LinkTypeRaw); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{writer: writer}, nil
+}
+
+// WritePacket records a datagram sent from src to dst with the given TTL, or
+// PCAP_DEFAULT_TTL if it is 0
+func (pw *PcapWriter) WritePacket(src net.Addr, dst net.Addr, payload []byte, ttl int) error {
+
+	var network gopacket.SerializableLayer
+
+	srcAddr, srcOk := src.(*net.UDPAddr)
+	dstAddr, dstOk := dst.(*net.UDPAddr)
+
+	if !srcOk || !dstOk {
+		return nil
+	}
+	udp := &layers.UDP{
+		SrcPort: layers.UDPPort(srcAddr.Port),
+		DstPort: layers.UDPPort(dstAddr.Port),
+	}
+	if ttl <= 0 {
+		ttl = PCAP_DEFAULT_TTL
+	}
+
+	if srcAddr.IP.To4() != nil && dstAddr.IP.To4() != nil {
+		ip := &layers.IPv4{
+			Version:  4,
+			TTL:      uint8(ttl),
+			Protocol: layers.IPProtocolUDP,
+			SrcIP:    srcAddr.IP.To4(),
+			DstIP:    dstAddr.IP.To4(),
+		}
+		udp.SetNetworkLayerForChecksum(ip)
+		network = ip
+	} else {
+		ip := &layers.IPv6{
+			Version:    6,
+			HopLimit:   uint8(ttl),
+			NextHeader: layers.IPProtocolUDP,
+			SrcIP:      srcAddr.IP.To16(),
+			DstIP:      dstAddr.IP.To16(),
+		}
+		udp.SetNetworkLayerForChecksum(ip)
+		network = ip
+	}
+
+	return pw.write(network, udp, gopacket.Payload(payload))
+}
+
+// WriteICMP records an ICMP message, as received with its checksum, sent from
+// src to dst with the given TTL, or PCAP_DEFAULT_TTL if it is 0
+func (pw *PcapWriter) WriteICMP(src net.IP, dst net.IP, message []byte, ttl int) error {
+
+	if ttl <= 0 {
+		ttl = PCAP_DEFAULT_TTL
+	}
+	if src.To4() != nil && dst.To4() != nil {
+		return pw.write(&layers.IPv4{
+			Version:  4,
+			TTL:      uint8(ttl),
+			Protocol: layers.IPProtocolICMPv4,
+			SrcIP:    src.To4(),
+			DstIP:    dst.To4(),
+		}, gopacket.Payload(message))
+	}
+	return pw.write(&layers.IPv6{
+		Version:    6,
+		HopLimit:   uint8(ttl),
+		NextHeader: layers.IPProtocolICMPv6,
+		SrcIP:      src.To16(),
+		DstIP:      dst.To16(),
+	}, gopacket.Payload(message))
+}
+
+// write serializes the layers of a packet and records it
+func (pw *PcapWriter) write(packetLayers ...gopacket.SerializableLayer) error {
+
+	buffer := gopacket.NewSerializeBuffer()
+	options := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+
+	if err := gopacket.SerializeLayers(buffer, options, packetLayers...); err != nil {
+		return err
+	}
+	packet := buffer.Bytes()
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	return pw.writer.WritePacket(gopacket.CaptureInfo{
+		Timestamp:     time.Now(),
+		CaptureLength: len(packet),
+		Length:        len(packet),
+	}, packet)
+}
+
+// recordPacket writes a datagram to the pcap file, if one is set
+func (sc *UdpProbeScanner) recordPacket(src net.Addr, dst net.Addr, payload []byte, ttl int) {
+
+	if sc.pcap == nil {
+		return
+	}
+	if err := sc.pcap.WritePacket(src, dst, payload, ttl); err != nil {
+		sc.Logger.Error().
+			Err(err).
+			Msg("Failed to write packet to pcap file")
+
+		atomic.AddUint64(&sc.counters.errors, 1)
+	}
+}
+
+// recordProbe writes a probe sent on a connection to the pcap file, if one is
+// set, with the TTL of the connection
+func (sc *UdpProbeScanner) recordProbe(conn net.Conn, ip net.IP, payload []byte) {
+
+	if sc.pcap != nil {
+		sc.recordPacket(conn.LocalAddr(), conn.RemoteAddr(), payload, socketTTL(conn, ip))
+	}
+}
+
+// recordICMP writes an ICMP message to the pcap file, if one is set
+func (sc *UdpProbeScanner) recordICMP(src net.IP, dst net.IP, message []byte, ttl int) {
+
+	if sc.pcap == nil || src == nil || dst == nil {
+		return
+	}
+	if err := sc.pcap.WriteICMP(src, dst, message, ttl); err != nil {
+		sc.Logger.Error().
+			Err(err).
+			Msg("Failed to write packet to pcap file")
+
+		atomic.AddUint64(&sc.counters.errors, 1)
+	}
+}
+
+// socketTTL returns the TTL or hop limit of packets sent on a connection, or
+// 0 if it cannot be read
+func socketTTL(conn net.Conn, ip net.IP) (ttl int) {
+
+	if _, ok := conn.(syscall.Conn); !ok {
+		return 0
+	}
+	if ip.To4() != nil {
+		ttl, _ = ipv4.NewConn(conn).TTL()
+	} else {
+		ttl, _ = ipv6.NewConn(conn).HopLimit()
+	}
+	return
+}
+
+// readWithTTL reads a response along with its TTL or hop limit, which is only
+// asked of the operating system when packets are recorded
+func (sc *UdpProbeScanner) readWithTTL(conn net.Conn, ip net.IP, buffer []byte) (readLen int, ttl int, err error) {
+
+	udpConn, ok := conn.(*net.UDPConn)
+
+	if sc.pcap == nil || !ok {
+		readLen, err = conn.Read(buffer)
+		return
+	}
+	if ip.To4() != nil {
+		packetConn := ipv4.NewPacketConn(udpConn)
+
+		if packetConn.SetControlMessage(ipv4.FlagTTL, true) == nil {
+			var cm *ipv4.ControlMessage
+
+			if readLen, cm, _, err = packetConn.ReadFrom(buffer); cm != nil {
+				ttl = cm.TTL
+			}
+			return
+		}
+	} else {
+		packetConn := ipv6.NewPacketConn(udpConn)
+
+		if packetConn.SetControlMessage(ipv6.FlagHopLimit, true) == nil {
+			var cm *ipv6.ControlMessage
+
+			if readLen, cm, _, err = packetConn.ReadFrom(buffer); cm != nil {
+				ttl = cm.HopLimit
+			}
+			return
+		}
+	}
+	readLen, err = conn.Read(buffer)
+	return
+}
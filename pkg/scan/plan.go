@@ -0,0 +1,380 @@
+package scan
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	IPV4_UDP_HEADERS = 20 + 8 // Bytes of the IPv4 and UDP headers of each probe
+	IPV6_UDP_HEADERS = 40 + 8 // Bytes of the IPv6 and UDP headers of each probe
+	ICMP_ECHO_LENGTH = 8      // Bytes of an ICMP echo request without its IP header
+)
+
+// Plan is what a scan of the targets would send, worked out without sending
+// anything. Every probe is counted with all of its retransmissions, as if none
+// were answered, and so are the discovery probes of each host, so the counts
+// are what the scan sends at most.
+type Plan struct {
+	Hosts    []PlannedHost    `yaml:"hosts" json:"hosts"`
+	Services []PlannedService `yaml:"services" json:"services"`
+
+	HostCount    uint64 `yaml:"host_count" json:"host_count"`
+	SkippedHosts uint64 `yaml:"skipped_hosts" json:"skipped_hosts"` // Excluded or refused hosts
+	Probes       uint64 `yaml:"probes" json:"probes"`               // Distinct probes over every host
+	Packets      uint64 `yaml:"packets" json:"packets"`             // Packets with every retransmission and discovery probe
+	Bytes        uint64 `yaml:"bytes" json:"bytes"`                 // Bytes of those packets with their IP and UDP headers
+
+	// DurationSeconds estimates how long the scan takes at most, from the
+	// rate, concurrency, timeouts and retransmissions
+	DurationSeconds float64 `yaml:"duration_seconds" json:"duration_seconds"`
+}
+
+// PlannedHost is what a host would be sent
+type PlannedHost struct {
+	Host     Host   `yaml:"host" json:"host"`
+	Override string `yaml:"override,omitempty" json:"override,omitempty"` // Target of the override the host is scanned with
+	Probes   uint64 `yaml:"probes" json:"probes"`
+	Packets  uint64 `yaml:"packets" json:"packets"`
+	Bytes    uint64 `yaml:"bytes" json:"bytes"`
+
+	DurationSeconds float64 `yaml:"duration_seconds" json:"duration_seconds"`
+}
+
+// PlannedService is what every host would be sent for a service
+type PlannedService struct {
+	Service string   `yaml:"service" json:"service"`
+	Ports   []uint16 `yaml:"ports" json:"ports"`
+	Probes  uint64   `yaml:"probes" json:"probes"` // Distinct probes to each host
+	Packets uint64   `yaml:"packets" json:"packets"`
+	Bytes   uint64   `yaml:"bytes" json:"bytes"`
+}
+
+// planTally adds up the hosts of a plan into its totals and duration
+type planTally struct {
+	hostSeconds     float64
+	longestHost     float64
+	overridePackets map[*targetOverride]uint64
+}
+
+// plannedProbe is a probe of the scan with the length of its payload
+type plannedProbe struct {
+	service string
+	length  uint64
+}
+
+// Plan expands the targets the way Scan does, applying the exclusions, rules
+// of engagement and overrides, and returns what the scan would send
+func (sc *UdpProbeScanner) Plan(ctx context.Context, targetSourceList []string) (plan Plan, err error) {
+
+	probes := sc.plannedProbes()
+	services := plannedServices(sc, probes)
+	tally := planTally{overridePackets: make(map[*targetOverride]uint64)}
+
+	sc.resetCounters(estimateHosts(targetSourceList), uint64(len(probes)))
+
+	hosts := make(chan Host)
+
+	go func() {
+		sc.resolveTargets(ctx, targetSourceList, hosts)
+		close(hosts)
+	}()
+
+	for host := range hosts {
+
+		planned := sc.planHost(host, probes, services, 1)
+
+		plan.Hosts = append(plan.Hosts, planned)
+		tally.add(&plan, host.override, planned, 1)
+	}
+	plan.SkippedHosts = atomic.LoadUint64(&sc.counters.hostsSkipped)
+
+	sc.finishPlan(&plan, services, tally)
+
+	return plan, ctx.Err()
+}
+
+// Estimate works out what a scan of the targets would send from the size of
+// each target, without expanding them, so that ranges as large as a /8 are
+// estimated at once. The hosts of a target are taken to be scanned like its
+// first address, with its override, and exclusions are not subtracted, so the
+// estimate is what the scan sends at most.
+func (sc *UdpProbeScanner) Estimate(targetSourceList []string) (plan Plan) {
+
+	probes := sc.plannedProbes()
+	services := plannedServices(sc, probes)
+	tally := planTally{overridePackets: make(map[*targetOverride]uint64)}
+
+	for _, targetSource := range targetSourceList {
+
+		count := estimateHosts([]string{targetSource})
+		host := Host{Type: "IPv4", Host: targetSource, Target: Target{Target: targetSource}}
+
+		if ip := firstAddress(targetSource); ip != nil {
+			host = newHost(host.Target, ip, "")
+			host.override = sc.overrideFor(ip)
+		}
+		tally.add(&plan, host.override, sc.planHost(host, probes, services, count), count)
+	}
+	sc.finishPlan(&plan, services, tally)
+
+	return
+}
+
+// firstAddress returns the first address of a CIDR, range or IP target, or nil
+// for host names
+func firstAddress(targetSource string) net.IP {
+
+	if ip, _, err := net.ParseCIDR(targetSource); err == nil {
+		return ip
+	}
+	if start, _, ok := parseRange(targetSource); ok {
+		return start
+	}
+	return net.ParseIP(targetSource)
+}
+
+// plannedServices returns the services of the probes with their number of
+// probes to each host
+func plannedServices(sc *UdpProbeScanner, probes []plannedProbe) map[string]*PlannedService {
+
+	services := make(map[string]*PlannedService)
+
+	for _, probe := range probes {
+		if _, ok := services[probe.service]; !ok {
+			services[probe.service] = &PlannedService{Service: probe.service, Ports: sc.Services[probe.service].Ports}
+		}
+		services[probe.service].Probes++
+	}
+	return services
+}
+
+// add adds count hosts planned like the host to the totals of the plan
+func (tally *planTally) add(plan *Plan, override *targetOverride, host PlannedHost, count uint64) {
+
+	plan.HostCount = addCapped(plan.HostCount, count)
+	plan.Probes = addCapped(plan.Probes, mulCapped(host.Probes, count))
+	plan.Packets = addCapped(plan.Packets, mulCapped(host.Packets, count))
+	plan.Bytes = addCapped(plan.Bytes, mulCapped(host.Bytes, count))
+
+	tally.hostSeconds += float64(count) * host.DurationSeconds
+
+	if host.DurationSeconds > tally.longestHost {
+		tally.longestHost = host.DurationSeconds
+	}
+	if override != nil && override.limiter != nil {
+		tally.overridePackets[override] = addCapped(tally.overridePackets[override], mulCapped(host.Packets, count))
+	}
+}
+
+// finishPlan lists the services of the plan and works out its duration
+func (sc *UdpProbeScanner) finishPlan(plan *Plan, services map[string]*PlannedService, tally planTally) {
+
+	for _, service := range services {
+		plan.Services = append(plan.Services, *service)
+	}
+	sort.Slice(plan.Services, func(i, j int) bool {
+		return plan.Services[i].Service < plan.Services[j].Service
+	})
+
+	// Hosts are scanned HostConcurrency at a time, unless the rates, of the
+	// scan or of the overrides, are the tighter bound
+	plan.DurationSeconds = tally.hostSeconds / float64(sc.HostConcurrency)
+
+	if tally.longestHost > plan.DurationSeconds {
+		plan.DurationSeconds = tally.longestHost
+	}
+	for _, seconds := range sc.rateSeconds(plan.Packets, tally.overridePackets) {
+		if seconds > plan.DurationSeconds {
+			plan.DurationSeconds = seconds
+		}
+	}
+}
+
+// plannedProbes returns the probes of the scan, one for each port of their
+// service
+func (sc *UdpProbeScanner) plannedProbes() (probes []plannedProbe) {
+
+	for slug, service := range sc.Services {
+		for _, probe := range service.Probes {
+
+			payload, err := base64.StdEncoding.DecodeString(probe.EncodedData)
+
+			if err != nil {
+				continue
+			}
+			for range service.Ports {
+				probes = append(probes, plannedProbe{slug, uint64(len(payload))})
+			}
+		}
+	}
+	return
+}
+
+// planHost returns what the host would be sent, adding the packets of count
+// such hosts to those of the services
+func (sc *UdpProbeScanner) planHost(host Host, probes []plannedProbe, services map[string]*PlannedService, count uint64) (planned PlannedHost) {
+
+	planned.Host = host
+
+	if host.override != nil {
+		planned.Override = host.override.Target
+	}
+	headers := uint64(IPV4_UDP_HEADERS)
+
+	if host.Type == "IPv6" {
+		headers = IPV6_UDP_HEADERS
+	}
+	attempts := uint64(sc.hostRetransmissions(host)) + 1
+
+	// Each attempt waits out its timeout, after the jitter delay on average
+	var probeSeconds float64
+
+	for i := 0; i < int(attempts); i++ {
+		probeSeconds += (sc.retryTimeout(host, nil, i) + sc.Jitter/2).Seconds()
+	}
+	for _, probe := range probes {
+		planned.Probes++
+		planned.Packets += attempts
+		planned.Bytes += attempts * (probe.length + headers)
+
+		service := services[probe.service]
+		service.Packets = addCapped(service.Packets, mulCapped(attempts, count))
+		service.Bytes = addCapped(service.Bytes, mulCapped(attempts*(probe.length+headers), count))
+	}
+	concurrency := uint64(sc.hostPortConcurrency(host))
+	rounds := (planned.Probes + concurrency - 1) / concurrency
+
+	planned.DurationSeconds = float64(rounds) * probeSeconds
+
+	// Discovery methods are tried at once, each resent until it is answered
+	if sc.discoveryEnabled() {
+		for _, probe := range sc.discoveryProbes {
+			planned.Packets += attempts
+			planned.Bytes += attempts * (uint64(len(probe.payload)) + headers)
+		}
+		// Echo requests take the place of the UDP header
+		if sc.discoveryPing {
+			planned.Packets += attempts
+			planned.Bytes += attempts * (ICMP_ECHO_LENGTH + headers - 8)
+		}
+		planned.DurationSeconds += probeSeconds
+	}
+	// The rules of engagement refuse the packets over the cap of the host
+	if sc.roe != nil && sc.roe.maxProbes > 0 && planned.Packets > sc.roe.maxProbes {
+		planned.Bytes = planned.Bytes * sc.roe.maxProbes / planned.Packets
+		planned.Packets = sc.roe.maxProbes
+	}
+	return
+}
+
+// rateSeconds returns how long sending the packets takes at the rate of the
+// scan, and at the rates of the overrides for the packets to their hosts
+func (sc *UdpProbeScanner) rateSeconds(packets uint64, overridePackets map[*targetOverride]uint64) (seconds []float64) {
+
+	if sc.limiter != nil {
+		rate := sc.limiter.currentRate()
+
+		// Adaptive rates start low, but are expected to ramp up to the maximum
+		if sc.adapter != nil {
+			rate = sc.adapter.maxRate
+		}
+		seconds = append(seconds, float64(packets)/rate)
+	}
+	for override, packets := range overridePackets {
+		seconds = append(seconds, float64(packets)/override.Rate)
+	}
+	return
+}
+
+// addCapped adds counts, stopping at the largest count rather than wrapping
+// around for targets as large as IPv6 networks
+func addCapped(a uint64, b uint64) uint64 {
+
+	if sum, carry := bits.Add64(a, b, 0); carry == 0 {
+		return sum
+	}
+	return math.MaxUint64
+}
+
+// mulCapped multiplies counts, stopping at the largest count
+func mulCapped(a uint64, b uint64) uint64 {
+
+	if high, low := bits.Mul64(a, b); high == 0 {
+		return low
+	}
+	return math.MaxUint64
+}
+
+// Save writes the plan as JSON, YAML or tables of its totals, services and
+// hosts
+func (plan Plan) Save(format string, output io.Writer) error {
+
+	switch format {
+	case "json", "jsonl":
+		return json.NewEncoder(output).Encode(plan)
+	case "yml", "yaml":
+		return yaml.NewEncoder(output).Encode(plan)
+	}
+	totalsTable := table.NewWriter()
+	totalsTable.AppendHeader(table.Row{"Hosts", "Skipped", "Probes", "Packets", "Bytes", "Duration"})
+	totalsTable.AppendRow(table.Row{
+		plan.HostCount,
+		plan.SkippedHosts,
+		plan.Probes,
+		plan.Packets,
+		plan.Bytes,
+		(time.Duration(plan.DurationSeconds * float64(time.Second))).Round(time.Second).String(),
+	})
+	servicesTable := table.NewWriter()
+	servicesTable.AppendHeader(table.Row{"Service", "Ports", "Probes Per Host", "Packets", "Bytes"})
+
+	for _, service := range plan.Services {
+		servicesTable.AppendRow(table.Row{service.Service, formatPorts(service.Ports), service.Probes, service.Packets, service.Bytes})
+	}
+	hostsTable := table.NewWriter()
+	hostsTable.AppendHeader(table.Row{"Host", "Target", "Override", "Probes", "Packets", "Bytes"})
+
+	for _, host := range plan.Hosts {
+		hostsTable.AppendRow(table.Row{host.Host.Host, host.Host.Target.Target, host.Override, host.Probes, host.Packets, host.Bytes})
+	}
+	plannedTables := []table.Writer{totalsTable, servicesTable}
+
+	// Estimates have no hosts to list
+	if len(plan.Hosts) > 0 {
+		plannedTables = append(plannedTables, hostsTable)
+	}
+	for i, plannedTable := range plannedTables {
+		if i > 0 {
+			fmt.Fprintln(output)
+		}
+		plannedTable.SetOutputMirror(output)
+		renderTable(plannedTable, format)
+	}
+	return nil
+}
+
+// formatPorts lists ports separated by commas
+func formatPorts(ports []uint16) string {
+
+	texts := make([]string, len(ports))
+
+	for i, port := range ports {
+		texts[i] = strconv.Itoa(int(port))
+	}
+	return strings.Join(texts, ",")
+}
@@ -0,0 +1,116 @@
+package scan
+
+import (
+	"context"
+	"encoding/base64"
+	"math"
+	"testing"
+
+	"udpz/pkg/data"
+)
+
+func TestPlan(t *testing.T) {
+
+	zero := uint(0)
+
+	options := DefaultOptions()
+	options.Services = map[string]data.UdpService{
+		"echo": {
+			Slug:   "echo",
+			Ports:  []uint16{7, 1007},
+			Probes: []data.UdpProbe{{Slug: "echo", EncodedData: base64.StdEncoding.EncodeToString(make([]byte, 10))}},
+		},
+	}
+	options.Exclude = []string{"10.0.0.3"}
+	options.Overrides = []TargetOverride{{Target: "10.0.0.0/31", Retransmissions: &zero}}
+
+	sc, err := NewUdpProbeScanner(options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := sc.Plan(context.Background(), []string{"10.0.0.0/30"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each packet is 10 bytes of payload behind 28 bytes of headers, and hosts
+	// outside the override are sent each probe three times
+	if plan.HostCount != 3 || plan.SkippedHosts != 1 || plan.Probes != 6 || plan.Packets != 10 || plan.Bytes != 380 {
+		t.Errorf("totals: got %+v", plan)
+	}
+	tests := []struct {
+		host     string
+		override string
+		packets  uint64
+		bytes    uint64
+		seconds  float64
+	}{
+		{"10.0.0.0", "10.0.0.0/31", 2, 76, 3},
+		{"10.0.0.1", "10.0.0.0/31", 2, 76, 3},
+		{"10.0.0.2", "", 6, 228, 9},
+	}
+	if len(plan.Hosts) != len(tests) {
+		t.Fatalf("hosts: got %d, want %d", len(plan.Hosts), len(tests))
+	}
+	for i, test := range tests {
+		host := plan.Hosts[i]
+
+		if host.Host.Host != test.host || host.Override != test.override || host.Packets != test.packets ||
+			host.Bytes != test.bytes || host.DurationSeconds != test.seconds {
+			t.Errorf("%s: got %q, %d, %d, %v, want %q, %d, %d, %v", test.host, host.Override, host.Packets,
+				host.Bytes, host.DurationSeconds, test.override, test.packets, test.bytes, test.seconds)
+		}
+	}
+	// Three hosts fit in one round of host tasks, so the slowest sets the pace
+	if plan.DurationSeconds != 9 {
+		t.Errorf("duration: got %v, want %v", plan.DurationSeconds, 9)
+	}
+	if len(plan.Services) != 1 || plan.Services[0].Packets != 10 || plan.Services[0].Bytes != 380 {
+		t.Errorf("services: got %+v", plan.Services)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+
+	zero := uint(0)
+
+	options := DefaultOptions()
+	options.Services = map[string]data.UdpService{
+		"echo": {
+			Slug:   "echo",
+			Ports:  []uint16{7, 1007},
+			Probes: []data.UdpProbe{{Slug: "echo", EncodedData: base64.StdEncoding.EncodeToString(make([]byte, 10))}},
+		},
+	}
+	options.Overrides = []TargetOverride{{Target: "10.0.0.0/31", Retransmissions: &zero}}
+
+	sc, err := NewUdpProbeScanner(options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		targets []string
+		hosts   uint64
+		packets uint64
+		bytes   uint64
+		seconds float64
+	}{
+		// Every host of a target is scanned like its first address
+		{[]string{"10.0.0.0/30"}, 4, 8, 304, 3},
+		{[]string{"10.0.1.0/30"}, 4, 24, 912, 9},
+		{[]string{"printer.local", "10.0.1.1"}, 2, 12, 456, 9},
+		{[]string{"10.0.0.0/8"}, 1 << 24, 2 << 24, 76 << 24, 3 * (1 << 24) / 10.0},
+		{[]string{"2001:db8::/32", "10.0.1.1"}, math.MaxUint64, math.MaxUint64, math.MaxUint64, 0},
+	}
+	for _, test := range tests {
+		plan := sc.Estimate(test.targets)
+
+		if plan.HostCount != test.hosts || plan.Packets != test.packets || plan.Bytes != test.bytes ||
+			(test.seconds > 0 && plan.DurationSeconds != test.seconds) {
+			t.Errorf("%v: got %d, %d, %d, %v, want %d, %d, %d, %v", test.targets, plan.HostCount, plan.Packets,
+				plan.Bytes, plan.DurationSeconds, test.hosts, test.packets, test.bytes, test.seconds)
+		}
+	}
+}
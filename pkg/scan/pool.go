@@ -0,0 +1,349 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	MAX_SOCKETS           = 1024                   // Largest pool of shared sockets per address family
+	BATCH_SIZE            = 64                     // Most datagrams sent or received with one system call
+	SOCKET_QUEUE          = 1024                   // Datagrams waiting to be sent from a shared socket
+	SOCKET_BUFFER         = 0x1000                 // Bytes kept of each received datagram
+	SOCKET_PEER_QUEUE     = 16                     // Datagrams waiting to be read by each connection, such as a handshake flight
+	SOCKET_RECEIVE_BUFFER = 0x400000               // Receive buffer, which queued ICMP errors also count against
+	SOCKET_ERROR_INTERVAL = 100 * time.Millisecond // How often queued ICMP errors are read if not signalled
+)
+
+// sharedSocket is an unconnected socket shared by the probes to every
+// destination of an address family. Probes are queued and sent by one
+// goroutine, and responses received by another, in batches of a single system
+// call each when batching is enabled. Replies are told apart by the address
+// they came from, so only one probe to a destination is in flight on the
+// socket at a time, and by transaction ID, so late replies to an earlier probe
+// are not mistaken for replies to the next.
+type sharedSocket struct {
+	*demux
+	conn       *net.UDPConn
+	queue      chan ipv4.Message
+	size       int // Most datagrams per system call
+	readBatch  func(messages []ipv4.Message, flags int) (int, error)
+	writeBatch func(messages []ipv4.Message, flags int) (int, error)
+}
+
+// SetSockets sends probes from a pool of count shared sockets per address
+// family, instead of a socket per probe, which keeps the number of open file
+// descriptors low at high concurrency. 0 opens a socket per probe. Port
+// unreachable errors are only reported to shared sockets on Linux, so
+// elsewhere closed ports are reported as open|filtered.
+func (sc *UdpProbeScanner) SetSockets(count int) error {
+
+	sc.sockets = 0
+
+	if count == 0 {
+		return nil
+	}
+	if count < 0 || count > MAX_SOCKETS {
+		return errors.New("number of sockets must be between 0 and " + strconv.Itoa(MAX_SOCKETS))
+	}
+	if sc.useProxy {
+		return errors.New("shared sockets cannot be relayed through a proxy")
+	}
+	if sc.sourcePortLow != 0 && count > int(sc.sourcePortHigh-sc.sourcePortLow)+1 {
+		return errors.New("a pool of " + strconv.Itoa(count) + " sockets needs as many source ports")
+	}
+	sc.sockets = count
+
+	return nil
+}
+
+// SetBatch sends and receives the probes of shared sockets in batches, which
+// cuts the system calls made for each probe. It needs sendmmsg and recvmmsg,
+// and so Linux. Without a pool of sockets, a single one per address family is
+// used.
+func (sc *UdpProbeScanner) SetBatch(enabled bool) error {
+
+	sc.batch = false
+
+	if !enabled {
+		return nil
+	}
+	if !BATCH_SUPPORTED {
+		return errors.New("batched socket I/O needs sendmmsg and recvmmsg, which only Linux has")
+	}
+	if sc.useProxy {
+		return errors.New("batched socket I/O cannot be relayed through a proxy")
+	}
+	if sc.sockets == 0 {
+		sc.sockets = 1
+	}
+	sc.batch = true
+
+	return nil
+}
+
+// dialShared returns a connection to the host over the next socket of the pool
+// of its address family, opening the socket first if needed. It blocks while
+// another probe to the same destination is in flight on that socket. Responses
+// that match returns false for are dropped.
+func (sc *UdpProbeScanner) dialShared(ctx context.Context, host Host, port uint16, match func(response []byte) bool) (net.Conn, error) {
+
+	index := int(atomic.AddUint32(&sc.poolNext, 1)-1) % sc.sockets
+	unlock := sc.destinations.lock(unreachableKey(host.ip, port) + "/" + strconv.Itoa(index))
+	socket, err := sc.sharedSocketFor(ctx, host.ip, index)
+
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	remote := &net.UDPAddr{IP: host.ip, Port: int(port), Zone: host.zone}
+
+	write := func(payload []byte) error {
+		message := ipv4.Message{
+			Buffers: [][]byte{append([]byte(nil), payload...)},
+			Addr:    remote,
+		}
+		select {
+		case socket.queue <- message:
+			return nil
+		case <-socket.done:
+			return socket.err
+		}
+	}
+	return socket.open(socket.conn.LocalAddr(), remote, match, write, unlock), nil
+}
+
+// sharedSocketFor returns the socket of the pool of the address family of ip
+// at index, replacing it if it was closed
+func (sc *UdpProbeScanner) sharedSocketFor(ctx context.Context, ip net.IP, index int) (*sharedSocket, error) {
+
+	family := 6
+
+	if ip.To4() != nil {
+		family = 4
+	}
+	sc.poolMu.Lock()
+	defer sc.poolMu.Unlock()
+
+	if sc.pool == nil {
+		sc.pool = make(map[int][]*sharedSocket)
+	}
+	if sc.pool[family] == nil {
+		sc.pool[family] = make([]*sharedSocket, sc.sockets)
+	}
+	if socket := sc.pool[family][index]; socket != nil && !socket.closed() {
+		return socket, nil
+	}
+	socket, err := sc.listenShared(ctx, ip, index)
+
+	if err != nil {
+		return nil, err
+	}
+	sc.pool[family][index] = socket
+
+	return socket, nil
+}
+
+// closePool closes the shared sockets once the scan finishes
+func (sc *UdpProbeScanner) closePool() {
+
+	sc.poolMu.Lock()
+	defer sc.poolMu.Unlock()
+
+	for family, sockets := range sc.pool {
+		for _, socket := range sockets {
+			if socket != nil {
+				socket.close(net.ErrClosed)
+			}
+		}
+		delete(sc.pool, family)
+	}
+}
+
+// listenShared opens the socket of the pool at index for the address family of
+// ip, from the configured interface and source address, and from the source
+// port at index in the configured range
+func (sc *UdpProbeScanner) listenShared(ctx context.Context, ip net.IP, index int) (*sharedSocket, error) {
+
+	network, address, port := "udp4", "0.0.0.0", 0
+
+	if ip.To4() == nil {
+		network, address = "udp6", "::"
+	}
+	if source := sc.sourceFor(ip); source != nil {
+		address = source.String()
+	}
+	if sc.sourcePortLow != 0 {
+		port = int(sc.sourcePortLow) + index
+	}
+	config := net.ListenConfig{}
+
+	if sc.device != "" || sc.sourcePortLow != 0 {
+		config.Control = sc.controlSocket
+	}
+	packetConn, err := config.ListenPacket(ctx, network, net.JoinHostPort(address, strconv.Itoa(port)))
+
+	if err != nil {
+		return nil, err
+	}
+	conn := packetConn.(*net.UDPConn)
+
+	if sc.ttl > 0 {
+		if err = setHopLimit(conn, ip, sc.ttl); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err = enableErrorQueue(conn, ip); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The kernel caps the buffer to its own maximum, which is good enough
+	conn.SetReadBuffer(SOCKET_RECEIVE_BUFFER)
+
+	socket := &sharedSocket{
+		demux: newDemux(),
+		conn:  conn,
+		queue: make(chan ipv4.Message, SOCKET_QUEUE),
+		size:  1,
+	}
+	if !sc.batch {
+		socket.readBatch, socket.writeBatch = socket.readOne, socket.writeOne
+	} else if socket.size = BATCH_SIZE; ip.To4() != nil {
+		packetConn := ipv4.NewPacketConn(conn)
+		socket.readBatch, socket.writeBatch = packetConn.ReadBatch, packetConn.WriteBatch
+	} else {
+		packetConn := ipv6.NewPacketConn(conn)
+		socket.readBatch, socket.writeBatch = packetConn.ReadBatch, packetConn.WriteBatch
+	}
+	go socket.receive()
+	go socket.send()
+
+	return socket, nil
+}
+
+// readOne receives a single datagram into the first message
+func (ss *sharedSocket) readOne(messages []ipv4.Message, flags int) (int, error) {
+
+	n, source, err := ss.conn.ReadFromUDP(messages[0].Buffers[0])
+
+	if err != nil {
+		return 0, err
+	}
+	messages[0].N, messages[0].Addr = n, source
+
+	return 1, nil
+}
+
+// writeOne sends the first message as a single datagram
+func (ss *sharedSocket) writeOne(messages []ipv4.Message, flags int) (int, error) {
+
+	if _, err := ss.conn.WriteTo(messages[0].Buffers[0], messages[0].Addr); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// receive hands the received datagrams to the connections of the destinations
+// they came from, until the socket is closed
+func (ss *sharedSocket) receive() {
+
+	messages := make([]ipv4.Message, ss.size)
+
+	for i := range messages {
+		messages[i].Buffers = [][]byte{make([]byte, SOCKET_BUFFER)}
+	}
+
+	for {
+		count, err := ss.readBatch(messages, 0)
+
+		if err != nil {
+			// A pending error, such as a port unreachable message, is
+			// reported by the next read and means ICMP errors are queued
+			var errno syscall.Errno
+
+			if errors.As(err, &errno) {
+				ss.readErrors()
+				continue
+			}
+			ss.close(err)
+			return
+		}
+		for _, message := range messages[:count] {
+			if source, ok := message.Addr.(*net.UDPAddr); ok {
+				ss.deliver(demuxKey(source.IP, source.Port), demuxMessage{
+					payload: append([]byte(nil), message.Buffers[0][:message.N]...),
+				})
+			}
+		}
+	}
+}
+
+// send writes the queued datagrams until the socket is closed, and reads ICMP
+// errors in between in case they were queued without being reported
+func (ss *sharedSocket) send() {
+
+	batch := make([]ipv4.Message, 0, ss.size)
+	ticker := time.NewTicker(SOCKET_ERROR_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message := <-ss.queue:
+			batch = append(batch[:0], message)
+		case <-ticker.C:
+			ss.readErrors()
+			continue
+		case <-ss.done:
+			return
+		}
+
+	fill:
+		for len(batch) < ss.size {
+			select {
+			case message := <-ss.queue:
+				batch = append(batch, message)
+			default:
+				break fill
+			}
+		}
+
+		for sent := 0; sent < len(batch); {
+			count, err := ss.writeBatch(batch[sent:], 0)
+
+			// A pending port unreachable error about an earlier datagram
+			// fails the send, and is cleared by doing so. Any other
+			// datagram that cannot be sent, such as to an unreachable
+			// network, is skipped and left to be retransmitted.
+			if err != nil {
+				var errno syscall.Errno
+
+				if !errors.As(err, &errno) {
+					ss.close(err)
+					return
+				}
+				if errno == syscall.ECONNREFUSED {
+					ss.readErrors()
+					continue
+				}
+				count++
+			}
+			sent += count
+		}
+	}
+}
+
+// close closes the shared socket, failing reads of its connections with err
+func (ss *sharedSocket) close(err error) {
+	if ss.demux.close(err) {
+		ss.conn.Close()
+	}
+}
@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// BATCH_SUPPORTED reports whether sendmmsg and recvmmsg are available
+const BATCH_SUPPORTED = true
+
+// enableErrorQueue has ICMP errors about datagrams sent from an unconnected
+// socket queued on it, since they are otherwise only reported to connected
+// sockets
+func enableErrorQueue(conn *net.UDPConn, ip net.IP) error {
+
+	raw, err := conn.SyscallConn()
+
+	if err != nil {
+		return err
+	}
+	controlErr := raw.Control(func(fd uintptr) {
+		if ip.To4() != nil {
+			err = unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_RECVERR, 1)
+		} else {
+			err = unix.SetsockoptInt(int(fd), unix.SOL_IPV6, unix.IPV6_RECVERR, 1)
+		}
+	})
+	if controlErr != nil {
+		return controlErr
+	}
+	return err
+}
+
+// readErrors reads the ICMP errors queued on the socket, and fails the read of
+// the connection each port unreachable error is about
+func (ss *sharedSocket) readErrors() {
+
+	raw, err := ss.conn.SyscallConn()
+
+	if err != nil {
+		return
+	}
+	buffer := make([]byte, 0x200)
+	oob := make([]byte, 0x200)
+
+	raw.Control(func(fd uintptr) {
+		for {
+			_, oobLen, _, from, err := unix.Recvmsg(int(fd), buffer, oob, unix.MSG_ERRQUEUE|unix.MSG_DONTWAIT)
+
+			if err != nil {
+				return
+			}
+			messages, err := unix.ParseSocketControlMessage(oob[:oobLen])
+
+			if err != nil {
+				continue
+			}
+			for _, message := range messages {
+				if !(message.Header.Level == unix.SOL_IP && message.Header.Type == unix.IP_RECVERR) &&
+					!(message.Header.Level == unix.SOL_IPV6 && message.Header.Type == unix.IPV6_RECVERR) {
+					continue
+				}
+				if len(message.Data) < int(unsafe.Sizeof(unix.SockExtendedErr{})) {
+					continue
+				}
+				extended := (*unix.SockExtendedErr)(unsafe.Pointer(&message.Data[0]))
+
+				if syscall.Errno(extended.Errno) != syscall.ECONNREFUSED {
+					continue
+				}
+
+				// The address is the destination of the datagram that failed
+				switch destination := from.(type) {
+				case *unix.SockaddrInet4:
+					ss.deliver(demuxKey(net.IP(destination.Addr[:]), destination.Port), demuxMessage{err: syscall.ECONNREFUSED})
+				case *unix.SockaddrInet6:
+					ss.deliver(demuxKey(net.IP(destination.Addr[:]), destination.Port), demuxMessage{err: syscall.ECONNREFUSED})
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package scan
+
+import (
+	"net"
+)
+
+// BATCH_SUPPORTED reports whether sendmmsg and recvmmsg are available
+const BATCH_SUPPORTED = false
+
+// enableErrorQueue does nothing, since ICMP errors are not reported to
+// unconnected sockets outside Linux
+func enableErrorQueue(conn *net.UDPConn, ip net.IP) error {
+	return nil
+}
+
+func (ss *sharedSocket) readErrors() {}
@@ -0,0 +1,94 @@
+package scan
+
+import (
+	"encoding/base64"
+	"errors"
+	"plugin"
+
+	"udpz/pkg/data"
+	"udpz/pkg/proto"
+)
+
+// PROBER_SYMBOL is the function Go plugins export to hand their probers to
+// LoadProberPlugin, as func() []scan.Prober
+const PROBER_SYMBOL = "Probers"
+
+// Prober drives the exchange with a service in code, for protocols that need
+// more than a static payload and response patterns, such as handshakes whose
+// later requests depend on earlier answers. The probes of its service give
+// the first request of each exchange; Next and Done continue it the way
+// proto.FollowUp does for the built-in services, and Parse reads the
+// responses. Probers keep no state between calls: whatever a later request
+// needs is taken from the request and responses they are given, so that one
+// prober serves every host of a scan at once.
+type Prober interface {
+	// Service describes the service, its ports and its probes
+	Service() data.UdpService
+	// Next returns the request answering the latest response, if one is due
+	Next(request []byte, response []byte) (next []byte, ok bool)
+	// Done reports whether the responses received so far end the exchange
+	Done(responses []byte) bool
+	// Parse extracts information from the responses to a probe
+	Parse(request []byte, response []byte) (info proto.Info, ok bool)
+}
+
+// RegisterProber adds the service of the prober to the services, replacing
+// one with the same slug, and hands its responses to the prober. It must be
+// called before scanning starts.
+func RegisterProber(services map[string]data.UdpService, prober Prober) (map[string]data.UdpService, error) {
+
+	service := prober.Service()
+
+	if service.Slug == "" {
+		return nil, errors.New("prober service is missing a slug")
+	}
+	if len(service.Ports) == 0 {
+		return nil, errors.New("prober service has no ports: " + service.Slug)
+	}
+	if len(service.Probes) == 0 {
+		return nil, errors.New("prober service has no probes: " + service.Slug)
+	}
+	service.Probes = append([]data.UdpProbe(nil), service.Probes...)
+
+	for i, probe := range service.Probes {
+
+		if probe.Slug == "" {
+			return nil, errors.New("prober probe is missing a slug in service: " + service.Slug)
+		}
+		if _, err := base64.StdEncoding.DecodeString(probe.EncodedData); err != nil {
+			return nil, errors.New("invalid base64 payload in probe " + probe.Slug + ": " + err.Error())
+		}
+		if probe.Name == "" {
+			service.Probes[i].Name = probe.Slug
+		}
+		service.Probes[i].Service = service.Slug
+	}
+	services = data.MergeServices(services, map[string]data.UdpService{service.Slug: service})
+
+	proto.PARSERS[service.Slug] = prober.Parse
+	proto.FOLLOW_UPS[service.Slug] = proto.FollowUp{Next: prober.Next, Done: prober.Done}
+
+	return services, nil
+}
+
+// LoadProberPlugin opens a Go plugin built with -buildmode=plugin against the
+// same version of udpz and returns the probers its Probers function exports
+func LoadProberPlugin(path string) (probers []Prober, err error) {
+
+	var (
+		module *plugin.Plugin
+		symbol plugin.Symbol
+	)
+	if module, err = plugin.Open(path); err != nil {
+		return
+	}
+	if symbol, err = module.Lookup(PROBER_SYMBOL); err != nil {
+		return
+	}
+	newProbers, ok := symbol.(func() []Prober)
+
+	if !ok {
+		return nil, errors.New("plugin symbol " + PROBER_SYMBOL + " is not a func() []scan.Prober: " + path)
+	}
+	return newProbers(), nil
+}
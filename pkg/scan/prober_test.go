@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"bytes"
+	"testing"
+
+	"udpz/pkg/data"
+	"udpz/pkg/proto"
+)
+
+// echoProber answers the first response with a request carrying it back, and
+// reports the second
+type echoProber struct {
+	service data.UdpService
+}
+
+func (prober echoProber) Service() data.UdpService {
+	return prober.service
+}
+
+func (prober echoProber) Next(request []byte, response []byte) ([]byte, bool) {
+
+	if !bytes.HasPrefix(request, []byte("HELLO")) {
+		return nil, false
+	}
+	return append([]byte("COOKIE "), response...), true
+}
+
+func (prober echoProber) Done(responses []byte) bool {
+	return bytes.Contains(responses, []byte("WELCOME"))
+}
+
+func (prober echoProber) Parse(request []byte, response []byte) (info proto.Info, ok bool) {
+
+	if _, welcome, found := bytes.Cut(response, []byte("WELCOME ")); found {
+		info.Banner = string(welcome)
+		return info, true
+	}
+	return
+}
+
+func TestRegisterProber(t *testing.T) {
+
+	probe := data.UdpProbe{Slug: "echo-prober-hello", Name: "Hello", EncodedData: "SEVMTE8="}
+
+	tests := []struct {
+		name    string
+		service data.UdpService
+		ok      bool
+	}{
+		{"valid", data.UdpService{Slug: "echo-prober", Ports: []uint16{9999}, Probes: []data.UdpProbe{probe}}, true},
+		{"no slug", data.UdpService{Ports: []uint16{9999}, Probes: []data.UdpProbe{probe}}, false},
+		{"no ports", data.UdpService{Slug: "echo-prober", Probes: []data.UdpProbe{probe}}, false},
+		{"no probes", data.UdpService{Slug: "echo-prober", Ports: []uint16{9999}}, false},
+		{"bad payload", data.UdpService{Slug: "echo-prober", Ports: []uint16{9999}, Probes: []data.UdpProbe{{Slug: "bad", EncodedData: "!"}}}, false},
+	}
+	defer delete(proto.PARSERS, "echo-prober")
+	defer delete(proto.FOLLOW_UPS, "echo-prober")
+
+	for _, test := range tests {
+		services, err := RegisterProber(data.UDP_SERVICES, echoProber{test.service})
+
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got %v, want ok %v", test.name, err, test.ok)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		service, found := services["echo-prober"]
+
+		if !found || len(service.Probes) != 1 || service.Probes[0].Service != "echo-prober" || service.Name != "echo-prober" {
+			t.Errorf("%s: got %+v, %v, want the registered service", test.name, service, found)
+		}
+		if _, found := data.UDP_SERVICES["echo-prober"]; found {
+			t.Errorf("%s: registering changed the built-in services", test.name)
+		}
+		followUp := proto.FOLLOW_UPS["echo-prober"]
+
+		if next, ok := followUp.Next([]byte("HELLO"), []byte("1234")); !ok || string(next) != "COOKIE 1234" {
+			t.Errorf("%s: got %q, %v, want %q, %v", test.name, next, ok, "COOKIE 1234", true)
+		}
+		if info, ok := proto.Parse("echo-prober", []byte("COOKIE 1234"), []byte("1234WELCOME v1")); !ok || info.Banner != "v1" {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, info, ok, proto.Info{Banner: "v1"}, true)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"math/big"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Progress is a snapshot of how far a running scan has come. Totals are
+// estimated from the targets until every target has been resolved.
+type Progress struct {
+	ProbesDone  uint64        `yaml:"probes_done" json:"probes_done"`
+	ProbesTotal uint64        `yaml:"probes_total" json:"probes_total"`
+	PacketsSent uint64        `yaml:"packets_sent" json:"packets_sent"`
+	OpenPorts   uint64        `yaml:"open_ports" json:"open_ports"`
+	Errors      uint64        `yaml:"errors" json:"errors"`   // Targets, probes and results that failed
+	Elapsed     time.Duration `yaml:"elapsed" json:"elapsed"` // Nanoseconds when encoded as JSON
+}
+
+// scanCounters are updated atomically by the scan tasks. They are allocated
+// separately so the 64-bit fields stay aligned on 32-bit platforms.
+type scanCounters struct {
+	startTime     int64 // Unix nanoseconds
+	hostsEstimate uint64
+	hostsSeen     uint64
+	hostsSkipped  uint64
+	probesPerHost uint64
+	probesDone    uint64
+	packetsSent   uint64
+	openPorts     uint64
+	errors        uint64
+}
+
+// Progress reports the progress of the current scan. It is safe to call while
+// the scan is running.
+func (sc *UdpProbeScanner) Progress() (progress Progress) {
+
+	hosts := atomic.LoadUint64(&sc.counters.hostsEstimate)
+
+	if seen := atomic.LoadUint64(&sc.counters.hostsSeen); seen > hosts {
+		hosts = seen
+	}
+	probesPerHost := atomic.LoadUint64(&sc.counters.probesPerHost)
+
+	progress.ProbesTotal = hosts * probesPerHost
+	progress.ProbesDone = atomic.LoadUint64(&sc.counters.probesDone) +
+		atomic.LoadUint64(&sc.counters.hostsSkipped)*probesPerHost
+	progress.PacketsSent = atomic.LoadUint64(&sc.counters.packetsSent)
+	progress.OpenPorts = atomic.LoadUint64(&sc.counters.openPorts)
+	progress.Errors = atomic.LoadUint64(&sc.counters.errors)
+
+	if startTime := atomic.LoadInt64(&sc.counters.startTime); startTime != 0 {
+		progress.Elapsed = time.Since(time.Unix(0, startTime))
+	}
+	return
+}
+
+// resetCounters prepares the counters for a new scan
+func (sc *UdpProbeScanner) resetCounters(hostsEstimate uint64, probesPerHost uint64) {
+
+	counters := sc.counters
+
+	atomic.StoreUint64(&counters.hostsEstimate, hostsEstimate)
+	atomic.StoreUint64(&counters.hostsSeen, 0)
+	atomic.StoreUint64(&counters.hostsSkipped, 0)
+	atomic.StoreUint64(&counters.probesPerHost, probesPerHost)
+	atomic.StoreUint64(&counters.probesDone, 0)
+	atomic.StoreUint64(&counters.packetsSent, 0)
+	atomic.StoreUint64(&counters.openPorts, 0)
+	atomic.StoreUint64(&counters.errors, 0)
+	atomic.StoreInt64(&counters.startTime, time.Now().UnixNano())
+}
+
+// estimateHosts counts the hosts the target specifications describe without
+// resolving them. Hostnames are counted as a single host.
+func estimateHosts(targetSources []string) (count uint64) {
+
+	for _, targetSource := range targetSources {
+
+		size := big.NewInt(1)
+
+		if _, ipNet, err := net.ParseCIDR(targetSource); err == nil {
+			ones, bits := ipNet.Mask.Size()
+			size.Lsh(size, uint(bits-ones))
+
+		} else if start, end, ok := parseRange(targetSource); ok {
+			size.Sub(new(big.Int).SetBytes(end), new(big.Int).SetBytes(start))
+			size.Add(size, big.NewInt(1))
+		}
+
+		if !size.IsUint64() || count+size.Uint64() < count {
+			return ^uint64(0)
+		}
+		count += size.Uint64()
+	}
+	return
+}
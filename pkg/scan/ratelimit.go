@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by every scan task to cap the total
+// number of packets sent per second
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens added per second
+	burst  float64 // Maximum number of stored tokens
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter allowing rate packets per second. The
+// bucket holds at most one tenth of a second worth of tokens, which keeps
+// bursts small on fragile links.
+func newRateLimiter(rate float64) *rateLimiter {
+
+	burst := rate / 10
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before the
+// token may be used
+func (rl *rateLimiter) reserve() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	rl.last = now
+
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+	rl.tokens--
+
+	if rl.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+}
+
+// setRate changes the rate, keeping the bucket at one tenth of a second worth
+// of tokens
+func (rl *rateLimiter) setRate(rate float64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.rate = rate
+	rl.burst = rate / 10
+	if rl.burst < 1 {
+		rl.burst = 1
+	}
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+}
+
+// currentRate returns the number of packets allowed per second
+func (rl *rateLimiter) currentRate() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.rate
+}
+
+// wait blocks until the caller may send another packet or the context is
+// canceled
+func (rl *rateLimiter) wait(ctx context.Context) error {
+
+	if delay := rl.reserve(); delay > 0 {
+		return sleepContext(ctx, delay)
+	}
+	return nil
+}
+
+// SetRate limits the total number of packets sent per second across all hosts
+// and ports. A rate of 0 removes the limit.
+func (sc *UdpProbeScanner) SetRate(rate float64) {
+	if rate > 0 {
+		sc.limiter = newRateLimiter(rate)
+	} else {
+		sc.limiter = nil
+	}
+}
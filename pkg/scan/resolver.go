@@ -0,0 +1,213 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DOH_TIMEOUT      = 10 * time.Second
+	DOH_CONTENT_TYPE = "application/dns-message"
+)
+
+var (
+	// errResolutionDisabled is returned for host names when name resolution
+	// is disabled
+	errResolutionDisabled = errors.New("name resolution is disabled")
+
+	// checkResolver accepts any host name without resolving it, to check
+	// target specifications before the resolver of the scan is known
+	checkResolver = &hostResolver{checkOnly: true}
+)
+
+// hostResolver looks up the addresses of host names given as targets, in
+// exclusions, overrides and rules of engagement. A nil hostResolver uses the
+// system resolver.
+type hostResolver struct {
+	resolver  *net.Resolver // Resolver querying the configured servers, or nil for the system resolver
+	disabled  bool          // Refuse to resolve host names at all
+	checkOnly bool          // Resolve host names to no addresses
+}
+
+// newHostResolver returns a resolver querying the given DNS servers, as IP or
+// IP:PORT, or the DNS over HTTPS server at dohURL, instead of those of the
+// system. Disabled resolvers refuse to resolve host names, so that only
+// literal addresses are scanned.
+func newHostResolver(servers []string, dohURL string, disabled bool) (*hostResolver, error) {
+
+	if disabled && (len(servers) > 0 || dohURL != "") {
+		return nil, errors.New("DNS resolvers cannot be used when name resolution is disabled")
+	}
+	if len(servers) > 0 && dohURL != "" {
+		return nil, errors.New("DNS resolvers and DNS over HTTPS cannot be used together")
+	}
+	resolver := &hostResolver{disabled: disabled}
+
+	if len(servers) > 0 {
+
+		addresses := make([]string, len(servers))
+
+		for i, server := range servers {
+
+			if ip, _ := parseIP(server); ip != nil {
+				addresses[i] = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+
+			} else if host, _, err := net.SplitHostPort(server); err == nil && net.ParseIP(host) != nil {
+				addresses[i] = server
+
+			} else {
+				return nil, errors.New("invalid DNS resolver: " + server)
+			}
+		}
+		var next uint32
+		var dialer net.Dialer
+
+		// Lookups go to each server in turn, so the retry of an unanswered
+		// query is sent to the next one
+		resolver.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+				server := addresses[(atomic.AddUint32(&next, 1)-1)%uint32(len(addresses))]
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	if dohURL != "" {
+
+		parsed, err := url.Parse(dohURL)
+
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return nil, errors.New("invalid DNS over HTTPS URL: " + dohURL)
+		}
+		client := &http.Client{Timeout: DOH_TIMEOUT}
+
+		resolver.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+				return &dohConn{ctx: ctx, client: client, url: dohURL}, nil
+			},
+		}
+	}
+	return resolver, nil
+}
+
+// lookupIP returns the addresses of a host name on the network "ip", "ip4" or
+// "ip6"
+func (r *hostResolver) lookupIP(ctx context.Context, network string, host string) ([]net.IP, error) {
+
+	if r == nil || (r.resolver == nil && !r.disabled && !r.checkOnly) {
+		return net.DefaultResolver.LookupIP(ctx, network, host)
+	}
+	if r.checkOnly {
+		return nil, nil
+	}
+	if r.disabled {
+		return nil, errResolutionDisabled
+	}
+	return r.resolver.LookupIP(ctx, network, host)
+}
+
+// dohConn carries the DNS queries of the Go resolver to a DNS over HTTPS
+// server (RFC 8484). As it is not a net.PacketConn, the resolver writes
+// queries prefixed by their length as over TCP, and each complete query is
+// POSTed to the server, its answer read back with the same framing.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	url      string
+	deadline time.Time
+	queries  bytes.Buffer
+	answers  bytes.Buffer
+}
+
+// exchange sends a query to the server and returns its answer
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+
+	ctx := c.ctx
+
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(query))
+
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", DOH_CONTENT_TYPE)
+	request.Header.Set("Accept", DOH_CONTENT_TYPE)
+
+	response, err := c.client.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("DNS over HTTPS server returned " + response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, 0xffff))
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+
+	c.queries.Write(b)
+
+	for c.queries.Len() >= 2 {
+
+		length := int(binary.BigEndian.Uint16(c.queries.Bytes()))
+
+		if c.queries.Len() < 2+length {
+			break
+		}
+		query := c.queries.Next(2 + length)[2:]
+		answer, err := c.exchange(query)
+
+		if err != nil {
+			return 0, err
+		}
+		binary.Write(&c.answers, binary.BigEndian, uint16(len(answer)))
+		c.answers.Write(answer)
+	}
+	return len(b), nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.answers.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.answers.Read(b)
+}
+
+func (c *dohConn) Close() error                     { return nil }
+func (c *dohConn) LocalAddr() net.Addr              { return &net.TCPAddr{} }
+func (c *dohConn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+func (c *dohConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *dohConn) SetDeadline(deadline time.Time) error {
+	c.deadline = deadline
+	return nil
+}
+
+// SetResolver resolves host names with the given DNS servers, as IP or
+// IP:PORT, or with the DNS over HTTPS server at dohURL, instead of the
+// system's resolver, or disables name resolution so that only literal
+// addresses are scanned. Host names in exclusions, overrides and rules of
+// engagement set afterwards are resolved the same way.
+func (sc *UdpProbeScanner) SetResolver(servers []string, dohURL string, disabled bool) (err error) {
+
+	sc.resolver, err = newHostResolver(servers, dohURL, disabled)
+	return
+}
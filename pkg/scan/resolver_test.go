@@ -0,0 +1,182 @@
+package scan
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewHostResolver(t *testing.T) {
+
+	tests := []struct {
+		servers  []string
+		dohURL   string
+		disabled bool
+		ok       bool
+	}{
+		{servers: []string{"1.1.1.1", "10.0.0.53:5353", "2001:db8::53", "[2001:db8::53]:53"}, ok: true},
+		{dohURL: "https://cloudflare-dns.com/dns-query", ok: true},
+		{disabled: true, ok: true},
+		{servers: []string{"dns.example.com"}, ok: false},
+		{dohURL: "dns.example.com/dns-query", ok: false},
+		{dohURL: "ftp://dns.example.com/", ok: false},
+		{servers: []string{"1.1.1.1"}, dohURL: "https://cloudflare-dns.com/dns-query", ok: false},
+		{servers: []string{"1.1.1.1"}, disabled: true, ok: false},
+	}
+
+	for _, test := range tests {
+		if _, err := newHostResolver(test.servers, test.dohURL, test.disabled); (err == nil) != test.ok {
+			t.Errorf("newHostResolver(%q, %q, %v) error = %v, want ok %v", test.servers, test.dohURL, test.disabled, err, test.ok)
+		}
+	}
+}
+
+func TestHostResolverDisabled(t *testing.T) {
+
+	resolver, err := newHostResolver(nil, "", true)
+
+	if err != nil {
+		t.Fatalf("newHostResolver failed: %s", err)
+	}
+	if ips, err := resolver.lookupIP(context.Background(), "ip", "localhost"); err != errResolutionDisabled {
+		t.Errorf("lookupIP(localhost) = %v, %v, want %v", ips, err, errResolutionDisabled)
+	}
+	sc := &UdpProbeScanner{resolver: resolver}
+
+	if err := sc.Exclude([]string{"192.0.2.1"}); err != nil {
+		t.Errorf("Exclude of an address failed: %s", err)
+	}
+	if err := sc.Exclude([]string{"localhost"}); err == nil {
+		t.Errorf("Exclude of a host name succeeded, want an error")
+	}
+}
+
+// newDoHServer starts a DNS over HTTPS server answering the A queries of the
+// host names with their addresses, and others with no records. It counts the
+// queries for each host name.
+func newDoHServer(addresses map[string]string) (server *httptest.Server, queries map[string]int, mu *sync.Mutex) {
+
+	queries = map[string]int{}
+	mu = &sync.Mutex{}
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		query, _ := io.ReadAll(r.Body)
+
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != DOH_CONTENT_TYPE || len(query) < 12 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		labels := []string{}
+		end := 12
+
+		for end < len(query) && query[end] != 0 {
+			labels = append(labels, string(query[end+1:end+1+int(query[end])]))
+			end += int(query[end]) + 1
+		}
+		end += 5
+		name := strings.Join(labels, ".")
+		qtype := binary.BigEndian.Uint16(query[end-4:])
+
+		mu.Lock()
+		queries[name]++
+		mu.Unlock()
+
+		answer := append([]byte{}, query[:2]...)
+		answer = append(answer, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0)
+		answer = append(answer, query[12:end]...)
+
+		if ip := net.ParseIP(addresses[name]).To4(); qtype == 1 && ip != nil {
+			answer[7] = 1
+			answer = append(answer, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+			answer = append(answer, ip...)
+		}
+		w.Header().Set("Content-Type", DOH_CONTENT_TYPE)
+		w.Write(answer)
+	}))
+	return
+}
+
+func TestHostResolverDoH(t *testing.T) {
+
+	server, _, _ := newDoHServer(map[string]string{"printer.corp.test": "192.0.2.10"})
+	defer server.Close()
+
+	resolver, err := newHostResolver(nil, server.URL+"/dns-query", false)
+
+	if err != nil {
+		t.Fatalf("newHostResolver failed: %s", err)
+	}
+	ips, err := resolver.lookupIP(context.Background(), "ip4", "printer.corp.test")
+
+	if err != nil {
+		t.Fatalf("lookupIP failed: %s", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.10")) {
+		t.Errorf("lookupIP(printer.corp.test) = %v, want [192.0.2.10]", ips)
+	}
+}
+
+func TestResolveTargets(t *testing.T) {
+
+	addresses := map[string]string{}
+	targets := []string{}
+	want := []string{}
+
+	for i := 1; i <= 20; i++ {
+		name := fmt.Sprintf("h%d.corp.test", i)
+		addresses[name] = fmt.Sprintf("192.0.2.%d", i)
+		targets = append(targets, name)
+		want = append(want, addresses[name])
+	}
+	targets = append(targets, "198.51.100.1", "H1.corp.test")
+	want = append(want, "198.51.100.1", "192.0.2.1")
+
+	server, queries, mu := newDoHServer(addresses)
+	defer server.Close()
+
+	for _, workers := range []uint{1, 8} {
+
+		resolver, err := newHostResolver(nil, server.URL, false)
+
+		if err != nil {
+			t.Fatalf("newHostResolver failed: %s", err)
+		}
+		sc := &UdpProbeScanner{
+			ResolveConcurrency: workers,
+			resolver:           resolver,
+			addressFamily:      4,
+			counters:           new(scanCounters),
+		}
+		hosts := make(chan Host)
+
+		go func() {
+			sc.resolveTargets(context.Background(), targets, hosts)
+			close(hosts)
+		}()
+		got := []string{}
+
+		for host := range hosts {
+			got = append(got, host.Host)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers: got %v, want %v", workers, got, want)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	for name := range addresses {
+		if queries[name] != 2 {
+			t.Errorf("%s: got %d queries, want 1 per scan", name, queries[name])
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// errProbeRefused is returned for packets the rules of engagement forbid
+	errProbeRefused = errors.New("probe refused by the rules of engagement")
+)
+
+// RulesOfEngagement are the limits a scan agreed with the owners of the
+// targets must stay within. Hosts outside the allowed networks are refused,
+// the packet rate is capped, hosts stop being probed once they were sent
+// their share of probes, and no probes are sent during blackout windows.
+//
+//	allowed:
+//	  - 10.10.0.0/16
+//	max_rate: 200
+//	max_probes_per_host: 5000
+//	timezone: Europe/Berlin
+//	blackouts:
+//	  - start: "08:00"
+//	    end: "18:00"
+//	    days: [mon, tue, wed, thu, fri]
+//	overrides:
+//	  - 10.10.5.0/24:rate=20,retries=1
+type RulesOfEngagement struct {
+	Allowed          []string         `yaml:"allowed,omitempty" json:"allowed,omitempty"`                         // Hosts, CIDRs and ranges that may be scanned, or any if empty
+	MaxRate          float64          `yaml:"max_rate,omitempty" json:"max_rate,omitempty"`                       // Most packets per second, or 0 for no cap
+	MaxProbesPerHost uint64           `yaml:"max_probes_per_host,omitempty" json:"max_probes_per_host,omitempty"` // Most packets sent to each host, or 0 for no cap
+	Timezone         string           `yaml:"timezone,omitempty" json:"timezone,omitempty"`                       // Zone of the blackout windows, or local time if empty
+	Blackouts        []BlackoutWindow `yaml:"blackouts,omitempty" json:"blackouts,omitempty"`
+
+	// Overrides scan the hosts of their targets with other settings, given as
+	// TARGET:KEY=VALUE,... (see ParseTargetOverride)
+	Overrides []string `yaml:"overrides,omitempty" json:"overrides,omitempty"`
+}
+
+// BlackoutWindow is a time of day during which no probes may be sent, from
+// start to end as HH:MM. Windows ending before they start run past midnight.
+// Days restricts the window to the days it starts on, such as mon, or every
+// day if empty.
+type BlackoutWindow struct {
+	Start string   `yaml:"start" json:"start"`
+	End   string   `yaml:"end" json:"end"`
+	Days  []string `yaml:"days,omitempty" json:"days,omitempty"`
+}
+
+// LoadRulesOfEngagement reads rules of engagement from a YAML file
+func LoadRulesOfEngagement(path string) (roe *RulesOfEngagement, err error) {
+
+	var file *os.File
+
+	if file, err = os.Open(path); err != nil {
+		return
+	}
+	defer file.Close()
+
+	return ReadRulesOfEngagement(file)
+}
+
+// ReadRulesOfEngagement reads rules of engagement from YAML. Unknown options
+// are errors, so that a misspelled limit is never silently ignored. Host names
+// are only resolved once the rules are set on a scanner, with its resolver.
+func ReadRulesOfEngagement(reader io.Reader) (roe *RulesOfEngagement, err error) {
+
+	roe = &RulesOfEngagement{}
+
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+
+	if err = decoder.Decode(roe); err != nil {
+		return nil, err
+	}
+	if _, err = roe.guard(checkResolver); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// roeGuard enforces rules of engagement over a scan
+type roeGuard struct {
+	allowed   []ipRange
+	maxProbes uint64
+	location  *time.Location
+	windows   []blackout
+	overrides []*targetOverride
+
+	mu          sync.Mutex
+	probes      map[string]uint64 // Packets sent to each host
+	pausedUntil time.Time         // End of the blackout window probes are paused for
+}
+
+// blackout is a blackout window in minutes since midnight
+type blackout struct {
+	start int
+	end   int
+	days  map[time.Weekday]bool
+}
+
+// guard validates the rules and returns their enforcer, resolving host names
+// with the resolver
+func (roe *RulesOfEngagement) guard(resolver *hostResolver) (guard *roeGuard, err error) {
+
+	if roe.MaxRate < 0 {
+		return nil, errors.New("negative maximum rate in rules of engagement")
+	}
+	guard = &roeGuard{
+		maxProbes: roe.MaxProbesPerHost,
+		location:  time.Local,
+		probes:    make(map[string]uint64),
+	}
+	if guard.allowed, err = parseIPRanges(roe.Allowed, "allowed", resolver); err != nil {
+		return nil, err
+	}
+	if roe.Timezone != "" {
+		if guard.location, err = time.LoadLocation(roe.Timezone); err != nil {
+			return nil, err
+		}
+	}
+	for _, window := range roe.Blackouts {
+
+		var parsed blackout
+
+		if parsed.start, err = minuteOfDay(window.Start); err != nil {
+			return nil, err
+		}
+		if parsed.end, err = minuteOfDay(window.End); err != nil {
+			return nil, err
+		}
+		if parsed.start == parsed.end {
+			return nil, errors.New("blackout window starts when it ends: " + window.Start)
+		}
+		for _, day := range window.Days {
+
+			weekday, ok := parseWeekday(day)
+
+			if !ok {
+				return nil, errors.New("invalid blackout day: " + day)
+			}
+			if parsed.days == nil {
+				parsed.days = make(map[time.Weekday]bool)
+			}
+			parsed.days[weekday] = true
+		}
+		guard.windows = append(guard.windows, parsed)
+	}
+	for _, spec := range roe.Overrides {
+
+		override, err := ParseTargetOverride(spec)
+
+		if err != nil {
+			return nil, err
+		}
+		resolved, err := newTargetOverride(override, resolver)
+
+		if err != nil {
+			return nil, err
+		}
+		guard.overrides = append(guard.overrides, resolved)
+	}
+	return
+}
+
+// minuteOfDay parses a time of day given as HH:MM
+func minuteOfDay(clock string) (int, error) {
+
+	parsed, err := time.Parse("15:04", clock)
+
+	if err != nil {
+		return 0, errors.New("invalid blackout time: " + clock)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// parseWeekday parses the name of a day of the week, such as mon or Monday
+func parseWeekday(day string) (time.Weekday, bool) {
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+
+		name := strings.ToLower(weekday.String())
+
+		if strings.EqualFold(day, name) || strings.EqualFold(day, name[:3]) {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
+
+// until returns the end of the occurrence of the window the time falls in, if
+// any. Windows running past midnight started the day before.
+func (window blackout) until(now time.Time) (time.Time, bool) {
+
+	length := window.end - window.start
+
+	if length < 0 {
+		length += 24 * 60
+	}
+	for _, offset := range []int{0, -1} {
+
+		day := now.AddDate(0, 0, offset)
+
+		if window.days != nil && !window.days[day.Weekday()] {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, window.start, 0, 0, now.Location())
+		end := start.Add(time.Duration(length) * time.Minute)
+
+		if !now.Before(start) && now.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// blackedOut returns the latest end of the windows the time falls in, if any
+func (guard *roeGuard) blackedOut(now time.Time) (until time.Time, ok bool) {
+
+	now = now.In(guard.location)
+
+	for _, window := range guard.windows {
+		if end, in := window.until(now); in && end.After(until) {
+			until, ok = end, true
+		}
+	}
+	return
+}
+
+// allows reports whether the address is in the allowed networks
+func (guard *roeGuard) allows(ip net.IP) bool {
+
+	if len(guard.allowed) == 0 {
+		return true
+	}
+	for _, allowed := range guard.allowed {
+		if allowed.contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// count records a packet to the host, and reports whether the host was still
+// under its cap, and whether it just reached it
+func (guard *roeGuard) count(host string) (allowed bool, capped bool) {
+
+	if guard.maxProbes == 0 {
+		return true, false
+	}
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+
+	if guard.probes[host] >= guard.maxProbes {
+		return false, false
+	}
+	guard.probes[host]++
+
+	return true, guard.probes[host] == guard.maxProbes
+}
+
+// SetRulesOfEngagement enforces the rules over the scans of the scanner, or
+// stops enforcing any if nil. The rate is capped at the maximum rate of the
+// rules, so rates must be set first.
+func (sc *UdpProbeScanner) SetRulesOfEngagement(roe *RulesOfEngagement) (err error) {
+
+	if roe == nil {
+		sc.roe = nil
+		return nil
+	}
+	if sc.roe, err = roe.guard(sc.resolver); err != nil {
+		return err
+	}
+	if roe.MaxRate > 0 {
+		if sc.adapter != nil && sc.adapter.maxRate > roe.MaxRate {
+			sc.adapter.maxRate = roe.MaxRate
+
+			if sc.adapter.minRate > roe.MaxRate {
+				sc.adapter.minRate = roe.MaxRate
+			}
+		}
+		if sc.limiter == nil || sc.limiter.currentRate() > roe.MaxRate {
+			sc.Logger.Info().
+				Float64("rate", roe.MaxRate).
+				Msg("Capping packet rate to the rules of engagement")
+
+			if sc.limiter == nil {
+				sc.SetRate(roe.MaxRate)
+			} else {
+				sc.limiter.setRate(roe.MaxRate)
+			}
+		}
+	}
+	return nil
+}
+
+// pace waits until the rules of engagement and the rate limit let another
+// packet be sent to the host. Packets over the cap of the host are refused,
+// and packets due during a blackout window wait for its end.
+func (sc *UdpProbeScanner) pace(ctx context.Context, host Host) error {
+
+	if sc.roe != nil {
+		if err := sc.waitBlackout(ctx); err != nil {
+			return err
+		}
+		allowed, capped := sc.roe.count(host.Host)
+
+		if !allowed {
+			return errProbeRefused
+		}
+		if capped {
+			sc.Logger.Warn().
+				Str("target", host.Target.Target).
+				Str("host", host.Host).
+				Uint64("max_probes_per_host", sc.roe.maxProbes).
+				Msg("Host reached its probe cap under the rules of engagement, refusing further probes")
+		}
+	}
+	if host.override != nil && host.override.limiter != nil {
+		if err := host.override.limiter.wait(ctx); err != nil {
+			return err
+		}
+	}
+	if sc.limiter != nil {
+		return sc.limiter.wait(ctx)
+	}
+	return nil
+}
+
+// waitBlackout blocks while the time falls in a blackout window. The first
+// caller to wait for a window logs the pause, and the first to go on after it
+// logs the resumption.
+func (sc *UdpProbeScanner) waitBlackout(ctx context.Context) error {
+
+	for {
+		until, ok := sc.roe.blackedOut(time.Now())
+
+		sc.roe.mu.Lock()
+
+		if !ok {
+			if !sc.roe.pausedUntil.IsZero() {
+				sc.roe.pausedUntil = time.Time{}
+
+				sc.Logger.Info().
+					Msg("Blackout window of the rules of engagement ended, resuming probes")
+			}
+			sc.roe.mu.Unlock()
+			return nil
+		}
+		if !sc.roe.pausedUntil.Equal(until) {
+			sc.roe.pausedUntil = until
+
+			sc.Logger.Warn().
+				Time("until", until).
+				Msg("Pausing probes during a blackout window of the rules of engagement")
+		}
+		sc.roe.mu.Unlock()
+
+		if err := sleepContext(ctx, time.Until(until)); err != nil {
+			return err
+		}
+	}
+}
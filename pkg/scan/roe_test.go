@@ -0,0 +1,141 @@
+package scan
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRulesOfEngagement(t *testing.T) {
+
+	tests := []struct {
+		name string
+		yaml string
+		ok   bool
+	}{
+		{"valid", "allowed: [10.0.0.0/8, 192.168.1.10-20]\nmax_rate: 50\nmax_probes_per_host: 100\ntimezone: UTC\nblackouts:\n  - {start: \"22:00\", end: \"06:00\", days: [fri, Saturday]}\n", true},
+		{"empty", "max_rate: 10\n", true},
+		{"misspelled option", "max_pps: 10\n", false},
+		{"invalid network", "allowed: [10.0.0.0/33]\n", false},
+		{"negative rate", "max_rate: -1\n", false},
+		{"invalid timezone", "timezone: Mars/Olympus\n", false},
+		{"invalid time", "blackouts:\n  - {start: \"25:00\", end: \"06:00\"}\n", false},
+		{"empty window", "blackouts:\n  - {start: \"06:00\", end: \"06:00\"}\n", false},
+		{"invalid day", "blackouts:\n  - {start: \"01:00\", end: \"06:00\", days: [someday]}\n", false},
+	}
+	for _, test := range tests {
+		roe, err := ReadRulesOfEngagement(strings.NewReader(test.yaml))
+
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got %+v, %v, want ok %v", test.name, roe, err, test.ok)
+		}
+	}
+}
+
+func TestBlackedOut(t *testing.T) {
+
+	roe := RulesOfEngagement{
+		Timezone: "UTC",
+		Blackouts: []BlackoutWindow{
+			{Start: "22:00", End: "06:00", Days: []string{"fri"}},
+			{Start: "12:00", End: "13:00"},
+		},
+	}
+	guard, err := roe.guard(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-10-16 is a Friday
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		now   time.Time
+		until time.Time
+		ok    bool
+	}{
+		{"friday night", at(16, 23, 0), at(17, 6, 0), true},
+		{"saturday morning", at(17, 5, 59), at(17, 6, 0), true},
+		{"saturday after the window", at(17, 6, 0), time.Time{}, false},
+		{"thursday night", at(15, 23, 0), time.Time{}, false},
+		{"every day at noon", at(14, 12, 30), at(14, 13, 0), true},
+		{"before noon", at(14, 11, 59), time.Time{}, false},
+		{"other zone", at(16, 23, 0).In(time.FixedZone("UTC+2", 2*60*60)), at(17, 6, 0), true},
+	}
+	for _, test := range tests {
+		until, ok := guard.blackedOut(test.now)
+
+		if ok != test.ok || !until.Equal(test.until) {
+			t.Errorf("%s: got %v, %v, want %v, %v", test.name, until, ok, test.until, test.ok)
+		}
+	}
+}
+
+func TestRulesOfEngagementLimits(t *testing.T) {
+
+	roe := RulesOfEngagement{Allowed: []string{"10.0.0.0/24", "2001:db8::1"}, MaxProbesPerHost: 2}
+	guard, err := roe.guard(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		ip      string
+		allowed bool
+	}{
+		{"10.0.0.200", true},
+		{"10.0.1.1", false},
+		{"2001:db8::1", true},
+		{"2001:db8::2", false},
+	} {
+		if allowed := guard.allows(net.ParseIP(test.ip)); allowed != test.allowed {
+			t.Errorf("%s: got %v, want %v", test.ip, allowed, test.allowed)
+		}
+	}
+	for i, want := range [][2]bool{{true, false}, {true, true}, {false, false}} {
+		if allowed, capped := guard.count("10.0.0.1"); allowed != want[0] || capped != want[1] {
+			t.Errorf("probe %d: got %v, %v, want %v, %v", i, allowed, capped, want[0], want[1])
+		}
+	}
+	if allowed, _ := guard.count("10.0.0.2"); !allowed {
+		t.Errorf("other host: got %v, want %v", allowed, true)
+	}
+}
+
+func TestSetRulesOfEngagementRate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		rate    float64
+		maxRate float64
+		cap     float64
+		want    float64
+	}{
+		{"unlimited is capped", 0, 0, 50, 50},
+		{"faster is capped", 100, 0, 50, 50},
+		{"slower is kept", 20, 0, 50, 20},
+		{"adaptive maximum is capped", 0, 200, 50, 20},
+	}
+	for _, test := range tests {
+		options := DefaultOptions()
+		options.Rate = test.rate
+		options.MaxRate = test.maxRate
+		options.RulesOfEngagement = &RulesOfEngagement{MaxRate: test.cap}
+
+		sc, err := NewUdpProbeScanner(options)
+
+		if err != nil {
+			t.Errorf("%s: got %v", test.name, err)
+			continue
+		}
+		if rate := sc.limiter.currentRate(); rate != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, rate, test.want)
+		}
+		if sc.adapter != nil && sc.adapter.maxRate > test.cap {
+			t.Errorf("%s: got maximum %v, want at most %v", test.name, sc.adapter.maxRate, test.cap)
+		}
+	}
+}
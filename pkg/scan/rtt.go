@@ -0,0 +1,65 @@
+package scan
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// MIN_ADAPTIVE_TIMEOUT keeps adaptive timeouts from dropping below what
+	// slower services need to answer, even when a host replies in microseconds
+	MIN_ADAPTIVE_TIMEOUT = 100 * time.Millisecond
+)
+
+// rttEstimator tracks the round trip time of a host from the responses and
+// ICMP errors it sends back, the way TCP does (RFC 6298), so the probe timeout
+// can follow the host rather than a single global value
+type rttEstimator struct {
+	mu      sync.Mutex
+	samples int
+	srtt    time.Duration // Smoothed round trip time
+	rttvar  time.Duration // Round trip time variation
+}
+
+func newRTTEstimator() *rttEstimator {
+	return &rttEstimator{}
+}
+
+// observe records a measured round trip time
+func (r *rttEstimator) observe(rtt time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.samples == 0 {
+		r.srtt = rtt
+		r.rttvar = rtt / 2
+	} else {
+		delta := r.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		r.rttvar = (3*r.rttvar + delta) / 4
+		r.srtt = (7*r.srtt + rtt) / 8
+	}
+	r.samples++
+}
+
+// timeout returns how long to wait for a response, which is maxTimeout until
+// the host has answered at least once
+func (r *rttEstimator) timeout(maxTimeout time.Duration) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.samples == 0 {
+		return maxTimeout
+	}
+	timeout := r.srtt + 4*r.rttvar
+
+	if timeout < MIN_ADAPTIVE_TIMEOUT {
+		timeout = MIN_ADAPTIVE_TIMEOUT
+	}
+	if timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	return timeout
+}
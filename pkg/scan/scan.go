@@ -1,20 +1,38 @@
 package scan
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"udpz/pkg/data"
+	"udpz/pkg/proto"
 
 	"github.com/rs/zerolog"
-	//"github.com/txthinking/socks5"
 )
 
-func (sc *UdpProbeScanner) handleResult(pr PortResult) {
+// reports tells whether results in the state are recorded, rather than only
+// counted in the host summaries
+func (sc *UdpProbeScanner) reports(state string) bool {
+
+	switch state {
+	case PORT_STATE_OPEN:
+		return true
+	case PORT_STATE_CLOSED:
+		return sc.allStates
+	default:
+		return !sc.openOnly
+	}
+}
+
+func (sc *UdpProbeScanner) handleResult(pr Result) {
 
 	sc.Logger.Debug().
 		Str("target", pr.Host.Target.Target).
@@ -25,10 +43,22 @@ func (sc *UdpProbeScanner) handleResult(pr PortResult) {
 		Str("response", pr.Response).
 		Msg("Received response")
 
+	// Ports in the states left out are only counted in the host summaries
+	if !sc.reports(pr.State) {
+		sc.statsFor(pr.Host).hide(pr.Port, pr.State)
+		return
+	}
+	sc.annotateVulnerabilities(&pr)
+
 	if _, ok := sc.resultsMap[pr.Host.Host]; !ok {
-		sc.resultsMap[pr.Host.Host] = make(map[uint16][]PortResult)
+		sc.resultsMap[pr.Host.Host] = make(map[uint16][]Result)
 	}
-	if _, ok := sc.resultsMap[pr.Host.Host][pr.Port]; !ok {
+	if pr.State != PORT_STATE_OPEN {
+		sc.results = append(sc.results, pr)
+		sc.resultsMap[pr.Host.Host][pr.Port] = append(sc.resultsMap[pr.Host.Host][pr.Port], pr)
+		sc.emitResult(pr)
+
+	} else if _, ok := sc.resultsMap[pr.Host.Host][pr.Port]; !ok {
 		sc.Logger.Info().
 			Str("target", pr.Host.Target.Target).
 			Str("host", fmt.Sprintf("%s:%s", pr.Host.Type, pr.Host.Host)).
@@ -37,10 +67,24 @@ func (sc *UdpProbeScanner) handleResult(pr PortResult) {
 			Str("probe", pr.Probe.Slug).
 			Msg("Discovered UDP service")
 
+		atomic.AddUint64(&sc.counters.openPorts, 1)
+
 		sc.results = append(sc.results, pr)
-		sc.resultsMap[pr.Host.Host][pr.Port] = []PortResult{pr}
+		sc.resultsMap[pr.Host.Host][pr.Port] = []Result{pr}
+		sc.emitResult(pr)
 	} else {
 		sc.resultsMap[pr.Host.Host][pr.Port] = append(sc.resultsMap[pr.Host.Host][pr.Port], pr)
+
+		// Later probes may reveal version information the first one did not
+		if info := pr.info(); !info.Empty() {
+			for i := len(sc.results) - 1; i >= 0; i-- {
+				if sc.results[i].Host.Host == pr.Host.Host && sc.results[i].Port == pr.Port {
+					sc.results[i].setInfo(sc.results[i].info().Merge(info))
+					sc.annotateVulnerabilities(&sc.results[i])
+					break
+				}
+			}
+		}
 	}
 }
 
@@ -48,7 +92,14 @@ func (sc *UdpProbeScanner) Length() int {
 	return len(sc.results)
 }
 
-func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (err error) {
+// ResolveTarget sends the hosts of a target specification to the channel
+func (sc *UdpProbeScanner) ResolveTarget(ctx context.Context, targetSource string, hosts chan Host) (err error) {
+	return sc.resolveTarget(ctx, targetSource, hosts, nil)
+}
+
+// resolveTarget sends the hosts of a target specification to the channel,
+// taking the addresses of host names from the cache if one is given
+func (sc *UdpProbeScanner) resolveTarget(ctx context.Context, targetSource string, hosts chan Host, cache *lookupCache) (err error) {
 
 	sc.Logger.Trace().
 		Str("type", "call").
@@ -63,21 +114,11 @@ func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (
 
 	target.Target = targetSource
 
-	if ip := net.ParseIP(targetSource); ip != nil {
+	if ip, zone := parseIP(targetSource); ip != nil {
 
 		target.Type = "IP"
-		host.Target = target
-
-		if ip4 := ip.To4(); ip4 != nil {
-
-			host.Type = "IPv4"
-			host.Host = ip4.String()
+		host = newHost(target, ip, zone)
 
-		} else if ip16 := ip.To16(); ip16 != nil {
-
-			host.Type = "IPv6"
-			host.Host = fmt.Sprintf("[%s]", ip16)
-		}
 		sc.Logger.Debug().
 			Str("type", target.Type).
 			Str("target", target.Target).
@@ -85,12 +126,10 @@ func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (
 			IPAddr("ip", ip).
 			Msg("Target resolved")
 
-		hosts <- host
+		sc.sendHost(ctx, hosts, host)
 
 	} else if ip, ipNet, err := net.ParseCIDR(targetSource); err == nil {
 
-		var ipv6 bool
-
 		target.Type = "CIDR"
 		addrType := "Unknown"
 
@@ -98,7 +137,6 @@ func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (
 			addrType = "IPv4"
 		} else if ip16 := ip.To16(); ip16 != nil {
 			addrType = "IPv6"
-			ipv6 = true
 		}
 		sc.Logger.Debug().
 			Str("type", target.Type).
@@ -108,23 +146,34 @@ func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (
 			Str("cidr", ipNet.String()).
 			Msg("Target CIDR resolved")
 
-		for ipNet.Contains(ip) {
-			host = Host{
-				Target: target,
-				Type:   addrType,
-				Host:   ip.String(),
-				ip:     ip,
+		// Addresses are generated one at a time so large networks are never
+		// held in memory all at once
+		for ip := ipNet.IP.Mask(ipNet.Mask); ipNet.Contains(ip); {
+			if !sc.sendHost(ctx, hosts, newHost(target, ip, "")) && ctx.Err() != nil {
+				break
 			}
-			if ipv6 {
-				host.Host = "[" + host.Host + "]" // Faster than fmt.Sprintf
+			if !nextIP(ip) {
+				break
 			}
-			hosts <- host
+		}
 
-			for j := len(ip) - 1; j >= 0; j-- {
-				ip[j]++
-				if ip[j] != 0 {
-					break
-				}
+	} else if start, end, ok := parseRange(targetSource); ok {
+
+		target.Type = "range"
+
+		sc.Logger.Debug().
+			Str("type", target.Type).
+			Str("target", target.Target).
+			IPAddr("start", start).
+			IPAddr("end", end).
+			Msg("Target range resolved")
+
+		for ip := start; bytes.Compare(ip, end) <= 0; {
+			if !sc.sendHost(ctx, hosts, newHost(target, ip, "")) && ctx.Err() != nil {
+				break
+			}
+			if !nextIP(ip) {
+				break
 			}
 		}
 
@@ -132,7 +181,14 @@ func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (
 
 		target.Type = "hostname"
 
-		if ips, err := net.LookupIP(targetSource); err == nil {
+		var ips []net.IP
+
+		if cache != nil {
+			ips, err = cache.lookupIP(ctx, targetSource)
+		} else {
+			ips, err = sc.resolver.lookupIP(ctx, sc.lookupNetwork(), targetSource)
+		}
+		if err == nil {
 
 			sc.Logger.Debug().
 				Str("target", targetSource).
@@ -141,21 +197,7 @@ func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (
 
 			for _, ip := range ips {
 
-				host = Host{Target: target}
-
-				if ip4 := ip.To4(); ip4 != nil {
-					host.Type = "IPv4"
-					host.Host = ip4.String()
-					host.ip = ip4
-
-				} else if ip16 := ip.To16(); ip16 != nil {
-					host.Type = "IPv6"
-					host.Host = fmt.Sprintf("[%s]", ip16)
-					host.ip = ip16
-				}
-				hosts <- host
-
-				if !sc.scanAllAddresses {
+				if sc.sendHost(ctx, hosts, newHost(target, ip, "")) && !sc.scanAllAddresses {
 					break
 				}
 			}
@@ -164,6 +206,8 @@ func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (
 				Err(err).
 				Str("target", targetSource).
 				Msg("Failed to resolve target hostname")
+
+			atomic.AddUint64(&sc.counters.errors, 1)
 		}
 
 	} else {
@@ -171,6 +215,8 @@ func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (
 			Err(err).
 			Str("target", targetSource).
 			Msg("Could not resolve target. Invalid format")
+
+		atomic.AddUint64(&sc.counters.errors, 1)
 	}
 
 	sc.Logger.Trace().
@@ -186,8 +232,7 @@ func (sc *UdpProbeScanner) ResolveTarget(targetSource string, hosts chan Host) (
 	return nil
 }
 
-// TODO: ctx
-func (sc *UdpProbeScanner) scanTask(host Host, port uint16, payload []byte) (result PortResult, err error) {
+func (sc *UdpProbeScanner) scanTask(ctx context.Context, host Host, port uint16, service string, payload []byte, steps []data.UdpStep, timeout time.Duration, rtt *rttEstimator) (result Result, err error) {
 
 	sc.Logger.Trace().
 		Str("type", "call").
@@ -195,38 +240,71 @@ func (sc *UdpProbeScanner) scanTask(host Host, port uint16, payload []byte) (res
 		Dict("arguments", zerolog.Dict().
 			Interface("host", host).
 			Uint16("port", port).
-			Bytes("payload", payload)).
+			Str("service", service).
+			Bytes("payload", payload).
+			Dur("timeout", timeout)).
 		Msg("(*UdpProbeScanner).scanTask(...)")
 
 	var conn net.Conn
 	var readLen int
 
+	if err = sc.waitJitter(ctx); err != nil {
+		return
+	}
+	if err = sc.pace(ctx, host); err != nil {
+		return
+	}
+
 	for {
 		transport := "udp"
-		address := fmt.Sprintf("%s:%d", host.Host, port)
+		address := net.JoinHostPort(host.address(), strconv.Itoa(int(port)))
 
 		if sc.useProxy {
 			sc.Logger.Trace().
-				Str("type", "(*socks5.Client).Dial").
-				Str("proxy", ""). //sc.proxy.Server).
+				Str("type", "(*socks5Dialer).dial").
+				Str("proxy", sc.proxy.address).
+				Str("transport", transport).
+				Str("address", address).
+				Msg("(*socks5Dialer).dial(...)")
+
+			conn, err = sc.proxy.dial(ctx, host.ip, port)
+		} else if proto.OTHER_PORT_REPLIES[service] {
+			sc.Logger.Trace().
+				Str("type", "(*UdpProbeScanner).dialAnyPort").
+				Str("transport", transport).
+				Str("address", address).
+				Msg("(*UdpProbeScanner).dialAnyPort(...)")
+
+			conn, err = sc.dialAnyPort(ctx, host, port)
+		} else if sc.sockets > 0 {
+			sc.Logger.Trace().
+				Str("type", "(*UdpProbeScanner).dialShared").
 				Str("transport", transport).
 				Str("address", address).
-				Msg("(*socks5.Client).Dial(...)")
+				Msg("(*UdpProbeScanner).dialShared(...)")
 
-			//conn, err = sc.proxy.Dial(transport, address)
+			conn, err = sc.dialShared(ctx, host, port, func(response []byte) bool {
+				return proto.Matches(service, payload, response)
+			})
 		} else {
 			sc.Logger.Trace().
-				Str("type", "net.Dial").
+				Str("type", "(*UdpProbeScanner).dial").
 				Str("transport", transport).
 				Str("address", address).
-				Msg("net.Dial(...)")
+				Msg("(*UdpProbeScanner).dial(...)")
 
-			conn, err = net.Dial(transport, address)
+			conn, err = sc.dial(ctx, host, port)
+
+			if err == nil && sc.ttl > 0 {
+				if err = setHopLimit(conn, host.ip, sc.ttl); err != nil {
+					conn.Close()
+				}
+			}
 		}
 		if err == nil {
-			if err = conn.SetReadDeadline(time.Now().Add(sc.ReadTimeout)); err == nil {
+			if err = conn.SetReadDeadline(time.Now().Add(timeout)); err == nil {
 
-				response := make([]byte, 0x400)
+				response := responseBuffer(service)
 
 				sc.Logger.Trace().
 					Str("type", "connection.write").
@@ -236,8 +314,28 @@ func (sc *UdpProbeScanner) scanTask(host Host, port uint16, payload []byte) (res
 					Msg("(net.Conn).Write(data)")
 
 				conn.Write(payload)
+				atomic.AddUint64(&sc.counters.packetsSent, 1)
+				stats := sc.statsFor(host)
+				stats.sent()
+				sc.recordProbe(conn, host.ip, payload)
+
+				var responseTTL int
 
-				if readLen, err = bufio.NewReader(conn).Read(response); err == nil {
+				sent := time.Now()
+				readLen, responseTTL, err = sc.readWithTTL(conn, host.ip, response)
+
+				// Responses and port unreachable errors both measure the round trip
+				if err == nil || strings.Contains(err.Error(), "connection refused") {
+					elapsed := time.Since(sent)
+					stats.observe(elapsed)
+
+					if rtt != nil {
+						rtt.observe(elapsed)
+					}
+				}
+				if err == nil {
+
+					sc.recordPacket(conn.RemoteAddr(), conn.LocalAddr(), response[:readLen], responseTTL)
 
 					sc.Logger.Trace().
 						Str("type", "connection.read").
@@ -246,17 +344,29 @@ func (sc *UdpProbeScanner) scanTask(host Host, port uint16, payload []byte) (res
 						Bytes("data", response).
 						Msg("(net.Conn).Read(data)")
 
-					result = PortResult{
+					result = Result{
 						Port:      port,
 						Transport: transport,
 						Host:      host,
 					}
 
 					if readLen > 0 {
-						result.Response = base64.StdEncoding.EncodeToString(response[:readLen])
+						result.payload = response[:readLen]
+
+						if len(steps) > 0 {
+							result.payload = sc.followUp(ctx, conn, host, sequenceFollowUp(steps), payload, result.payload, timeout)
+						} else if followUp, ok := proto.FOLLOW_UPS[service]; ok {
+							result.payload = sc.followUp(ctx, conn, host, followUp, payload, result.payload, timeout)
+						}
+						result.Response = base64.StdEncoding.EncodeToString(result.payload)
+
+						if sc.captureLength > 0 {
+							result.Capture = newCapture(result.payload, sc.captureLength)
+						}
 					}
 				}
 			}
+			conn.Close()
 			break
 		} else {
 			if strings.Contains(err.Error(), "connect: resource temporarily unavailable") {
@@ -293,7 +403,11 @@ func (sc *UdpProbeScanner) scanTask(host Host, port uint16, payload []byte) (res
 	return
 }
 
-func (sc *UdpProbeScanner) Scan(targetSourceList []string) {
+// Scan probes every target until all probes finish or the context is canceled,
+// and returns the results. Canceling the context stops new probes from being
+// sent, while probes already in flight are given the chance to finish so their
+// results are kept and returned along with the context's error.
+func (sc *UdpProbeScanner) Scan(ctx context.Context, targetSourceList []string) ([]Result, error) {
 
 	sc.Logger.Trace().
 		Str("type", "call").
@@ -307,15 +421,28 @@ func (sc *UdpProbeScanner) Scan(targetSourceList []string) {
 	var probeCount uint
 	var totalCount uint
 
+	sc.startTime = time.Now()
+	defer func() {
+		sc.endTime = time.Now()
+	}()
+
+	sc.resultsLive = make(chan Result)
+	sc.results = nil
+	sc.resultsMap = make(map[string]map[uint16][]Result)
+
+	sc.statsMu.Lock()
+	sc.stats = nil
+	sc.statsMu.Unlock()
+
 	hostSem := make(chan struct{}, sc.HostConcurrency)
 	//portSem := make(chan struct{}, sc.PortConcurrency)
 	hosts := make(chan Host)
 
 	sc.Logger.Debug().
-		Int("service_count", len(data.UDP_SERVICES)).
+		Int("service_count", len(sc.Services)).
 		Msg("Calculating unique probe count")
 
-	for _, service := range data.UDP_SERVICES {
+	for _, service := range sc.Services {
 		probeCount += uint(len(service.Ports) * len(service.Probes))
 	}
 	sc.Logger.Debug().
@@ -324,37 +451,57 @@ func (sc *UdpProbeScanner) Scan(targetSourceList []string) {
 
 	totalCount = probeCount * (sc.Retransmissions + 1)
 
+	sc.resetCounters(estimateHosts(targetSourceList), uint64(probeCount))
+
 	sc.Logger.Debug().
 		Uint("total_probes", totalCount).
 		Msg("Calculated total probe count")
 
-	go func(wg *sync.WaitGroup, c chan Host) {
+	go func(c chan Host) {
 
 		sc.Logger.Debug().
 			Int("target_count", len(targetSourceList)).
 			Msg("Resolving targets")
 
-		for _, ts := range targetSourceList {
-			sc.ResolveTarget(ts, c)
-		}
-		wg.Wait()
+		sc.resolveTargets(ctx, targetSourceList, c)
+
+		// Every host is known once resolution finishes
+		atomic.StoreUint64(&sc.counters.hostsEstimate, atomic.LoadUint64(&sc.counters.hostsSeen))
 		close(c)
 
-	}(&hostWg, hosts)
+	}(hosts)
+
+	resultsDone := make(chan struct{})
 
 	go func() {
 		for r := range sc.resultsLive {
 			sc.handleResult(r)
 		}
+		close(resultsDone)
 	}()
 
+	sc.resetUnreachable()
+	sc.startICMPListener()
+	defer sc.stopICMPListener()
+
+	if sc.adapter != nil {
+		adaptCtx, stopAdapting := context.WithCancel(ctx)
+		defer stopAdapting()
+
+		go sc.adaptRate(adaptCtx)
+	}
+
 	for host := range hosts {
 
 		host := host // Shadow variable
 		portWg := sync.WaitGroup{}
-		portSem := make(chan struct{}, sc.PortConcurrency)
+		portSem := make(chan struct{}, sc.hostPortConcurrency(host))
 
-		hostSem <- struct{}{}
+		select {
+		case hostSem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
 		hostWg.Add(1)
 
 		go func() {
@@ -364,42 +511,76 @@ func (sc *UdpProbeScanner) Scan(targetSourceList []string) {
 				<-hostSem
 			}()
 
-			var portStatus uint8 = STATE_UNRESPONSIVE
+			sc.watchHost(host.ip)
+			defer sc.unwatchHost(host.ip)
 
-			for _, service := range data.UDP_SERVICES {
+			if sc.discoveryEnabled() && !sc.discover(ctx, host) {
+				sc.Logger.Debug().
+					Str("target", host.Target.Target).
+					Str("host", host.Host).
+					Msg("Skipping host that did not answer discovery")
+
+				atomic.AddUint64(&sc.counters.hostsSkipped, 1)
+				return
+			}
+
+			states := newPortStates()
+
+			var rtt *rttEstimator
+			if sc.AdaptiveTimeout {
+				rtt = newRTTEstimator()
+			}
+
+		probes:
+			for slug, service := range sc.Services {
 				for _, port := range service.Ports {
+
+					states.track(port, slug)
+
 					for _, probe := range service.Probes {
 
 						probe := probe
 
-						portSem <- struct{}{}
+						select {
+						case portSem <- struct{}{}:
+						case <-ctx.Done():
+							break probes
+						}
 						portWg.Add(1)
 
 						go func(wg *sync.WaitGroup,
-							h Host, port uint16, probe data.UdpProbe,
-							service *data.UdpService, portStatus *uint8) {
+							h Host, port uint16, slug string, probe data.UdpProbe,
+							states *portStates) {
 
 							defer func() {
+								atomic.AddUint64(&sc.counters.probesDone, 1)
 								wg.Done()
 								<-portSem
 							}()
 
 							if probeBytes, err := base64.StdEncoding.DecodeString(probe.EncodedData); err == nil {
-								for i := 0; i <= int(sc.Retransmissions); i++ {
+								retransmissions := int(sc.hostRetransmissions(h))
 
-									if *portStatus == STATE_CLOSED {
+								for i := 0; i <= retransmissions && ctx.Err() == nil; i++ {
+
+									if states.get(port) == STATE_CLOSED {
 
 										sc.Logger.Debug().
 											Str("target", h.Target.Target).
 											Str("host", h.Host).
 											Uint16("port", port).
 											Msg("Skipping closed port")
+										break
 									}
 
-									if result, err := sc.scanTask(h, port, probeBytes); err != nil {
+									if result, err := sc.scanTask(ctx, h, port, slug, probeBytes, probe.Steps, sc.retryTimeout(h, rtt, i), rtt); err != nil {
+
+										if errors.Is(err, context.Canceled) || errors.Is(err, errProbeRefused) {
+											break
 
-										if strings.Contains(err.Error(), "connection refused") {
-											*portStatus = STATE_CLOSED
+										} else if strings.Contains(err.Error(), "connection refused") {
+											states.set(port, STATE_CLOSED)
+											sc.observeDelivery(i)
 
 											sc.Logger.Debug().
 												Str("target", h.Target.Target).
@@ -408,12 +589,26 @@ func (sc *UdpProbeScanner) Scan(targetSourceList []string) {
 												Msg("Port closed")
 
 										} else if strings.Contains(err.Error(), "i/o timeout") {
-											sc.Logger.Debug().
-												Str("target", h.Target.Target).
-												Str("host", h.Host).
-												Uint16("port", port).
-												Str("probe", probe.Slug).
-												Msg("Port unresponsive")
+											if sc.isUnreachable(h.ip, port) {
+												states.set(port, STATE_CLOSED)
+												sc.observeDelivery(i)
+
+												sc.Logger.Debug().
+													Str("target", h.Target.Target).
+													Str("host", h.Host).
+													Uint16("port", port).
+													Msg("Port closed (ICMP port unreachable)")
+											} else {
+												if i == retransmissions {
+													sc.observeLoss()
+												}
+												sc.Logger.Debug().
+													Str("target", h.Target.Target).
+													Str("host", h.Host).
+													Uint16("port", port).
+													Str("probe", probe.Slug).
+													Msg("Port unresponsive")
+											}
 
 										} else {
 											sc.Logger.Error().
@@ -422,12 +617,48 @@ func (sc *UdpProbeScanner) Scan(targetSourceList []string) {
 												Str("host", h.Host).
 												Uint16("port", port).
 												Msg("Error in scan task")
+
+											atomic.AddUint64(&sc.counters.errors, 1)
+											sc.statsFor(h).failed()
 										}
+									} else if !probe.Accepts(result.payload) || !proto.Matches(probe.Service, probeBytes, result.payload) {
+										sc.observeDelivery(i)
+										result.Probe = probe
+										states.setUnmatched(port, result)
+
+										sc.Logger.Debug().
+											Str("target", h.Target.Target).
+											Str("host", h.Host).
+											Uint16("port", port).
+											Str("probe", probe.Slug).
+											Msg("Response did not match probe")
+										break
+
 									} else {
-										result.Service = data.UDP_SERVICES[probe.Service]
+										sc.observeDelivery(i)
+										result.State = PORT_STATE_OPEN
+										result.Service, _ = data.LookupService(sc.Services, probe.Service)
 										result.Probe = probe
+
+										if info, ok := proto.Parse(probe.Service, probeBytes, result.payload); ok {
+											if probe.Service == "upnp" && sc.upnpDescriptions {
+												if description, ok := sc.describeUPnP(ctx, h, info); ok {
+													info = info.Merge(description)
+												}
+											}
+											result.setInfo(info)
+
+										} else if _, parsed := proto.PARSERS[probe.Service]; parsed && len(result.payload) > 0 {
+											// Another service may answer on the port with what the
+											// probed service would not, such as an NTP server
+											if slug, identified, ok := proto.Identify(probe.Service, result.payload); ok {
+												result.Service = sc.lookupService(slug)
+												result.setInfo(identified)
+											}
+										}
 										sc.resultsLive <- result
-										*portStatus = STATE_RESPONSIVE
+										states.set(port, STATE_RESPONSIVE)
+										break
 									}
 								}
 							} else {
@@ -435,51 +666,152 @@ func (sc *UdpProbeScanner) Scan(targetSourceList []string) {
 									Interface("probe", probe).
 									Err(err).
 									Msg("Failed to decode probe data")
+
+								atomic.AddUint64(&sc.counters.errors, 1)
 							}
-						}(&portWg, host, port, probe, &service, &portStatus)
+						}(&portWg, host, port, slug, probe, states)
 					}
 				}
 			}
 			portWg.Wait()
+			sc.reportPortStates(host, states)
 		}()
 	}
 
 	hostWg.Wait()
+	close(sc.resultsLive)
+	<-resultsDone
+	sc.flushSinks()
+
+	if sc.useProxy {
+		sc.proxy.close()
+	}
+	if sc.sockets > 0 {
+		sc.closePool()
+	}
+
+	if ctx.Err() != nil {
+		sc.Logger.Warn().
+			Msg("Scan interrupted, kept results of completed probes")
+	}
+	return sc.Results(), ctx.Err()
 }
 
-func NewUdpProbeScanner(logger zerolog.Logger, scanAllAddresses bool,
-	hostConcurrency uint, portConcurrency uint, retransmissions uint, readTimeout time.Duration,
-	socks5Address string, socks5User string, socks5Password string, socks5Timeout int) (sc UdpProbeScanner, err error) {
+// Results returns the results of the last scan
+func (sc *UdpProbeScanner) Results() []Result {
+	return append([]Result(nil), sc.results...)
+}
 
-	sc.resultsLive = make(chan PortResult)
-	sc.resultsMap = make(map[string]map[uint16][]PortResult)
+// Stream starts a scan in the background and returns a channel that receives
+// every result as soon as it is confirmed. The channel is closed once the scan
+// finishes, and must be drained for the scan to make progress.
+func (sc *UdpProbeScanner) Stream(ctx context.Context, targetSourceList []string) <-chan Result {
 
-	sc.HostConcurrency = hostConcurrency
-	sc.PortConcurrency = portConcurrency
-	sc.Retransmissions = retransmissions
-	sc.ReadTimeout = readTimeout
+	results := make(chan Result, sc.PortConcurrency)
+	sc.stream = results
 
-	sc.Logger = logger
+	go func() {
+		sc.Scan(ctx, targetSourceList)
+		sc.stream = nil
+		close(results)
+	}()
+	return results
+}
+
+// NewUdpProbeScanner creates a scanner from the given options
+func NewUdpProbeScanner(options Options) (sc *UdpProbeScanner, err error) {
+
+	if options.HostConcurrency < 1 || options.PortConcurrency < 1 || options.ResolveConcurrency < 1 {
+		return nil, errors.New("concurrency value must be > 0")
+	}
+	if options.ReadTimeout <= 0 {
+		return nil, errors.New("timeout value must be > 0")
+	}
+	if options.OpenOnly && options.AllStates {
+		return nil, errors.New("only reporting open ports and reporting all states are exclusive")
+	}
+
+	sc = &UdpProbeScanner{
+		HostConcurrency:    options.HostConcurrency,
+		PortConcurrency:    options.PortConcurrency,
+		ResolveConcurrency: options.ResolveConcurrency,
+		Retransmissions:    options.Retransmissions,
+		RetryBackoff:       options.RetryBackoff,
+		Jitter:             options.Jitter,
+		ReadTimeout:        options.ReadTimeout,
+		AdaptiveTimeout:    options.AdaptiveTimeout,
+		Services:           options.Services,
+		Logger:             options.Logger,
+		scanAllAddresses:   options.ScanAllAddresses,
+		openOnly:           options.OpenOnly,
+		allStates:          options.AllStates,
+		captureLength:      options.CaptureLength,
+		pcap:               options.Pcap,
+		sinks:              options.Sinks,
+		vulnerabilities:    options.Vulnerabilities,
+		resultsMap:         make(map[string]map[uint16][]Result),
+		counters:           new(scanCounters),
+	}
+	if sc.Services == nil {
+		sc.Services = data.UDP_SERVICES
+	}
+	if options.Rate > 0 {
+		sc.SetRate(options.Rate)
+	}
+	if err = sc.SetRateBounds(options.MinRate, options.MaxRate); err != nil {
+		return nil, err
+	}
+	if err = sc.SetResolver(options.Resolvers, options.DoHURL, options.NoResolve); err != nil {
+		return nil, err
+	}
+	if err = sc.SetRulesOfEngagement(options.RulesOfEngagement); err != nil {
+		return nil, err
+	}
+	if err = sc.SetOverrides(options.Overrides); err != nil {
+		return nil, err
+	}
+	if err = sc.SetAddressFamily(options.AddressFamily); err != nil {
+		return nil, err
+	}
+	if err = sc.Exclude(options.Exclude); err != nil {
+		return nil, err
+	}
+	if err = sc.SetSource(options.Interface, options.SourceIP); err != nil {
+		return nil, err
+	}
+	if err = sc.SetSourcePort(options.SourcePort); err != nil {
+		return nil, err
+	}
+	if err = sc.SetTTL(options.TTL); err != nil {
+		return nil, err
+	}
 
-	if socks5Address != "" {
+	if options.Socks5Address != "" {
 
 		sc.useProxy = true
 		sc.Logger.Debug().
-			Str("address", socks5Address).
-			Str("user", socks5User).
+			Str("address", options.Socks5Address).
+			Str("user", options.Socks5User).
 			Msg("Using SOCKS5 proxy")
 
-		if true {
-			//if sc.proxy, err = socks5.NewClient(
-			//	socks5Address, socks5User, socks5Password,
-			//	socks5Timeout, socks5Timeout); err != nil {
+		if sc.proxy, err = newSocks5Dialer(
+			options.Socks5Address, options.Socks5User, options.Socks5Password,
+			options.Socks5Timeout); err != nil {
 
-			sc.Logger.Error().
-				Err(err).
-				Str("address", socks5Address).
-				Msg("Failed to initialize SOCKS5 proxy dialer")
+			return nil, err
 		}
 	}
-
+	if err = sc.SetSockets(options.Sockets); err != nil {
+		return nil, err
+	}
+	if err = sc.SetBatch(options.Batch); err != nil {
+		return nil, err
+	}
+	if err = sc.SetUPnPDescriptions(options.UPnPDescriptions); err != nil {
+		return nil, err
+	}
+	if err = sc.SetDiscovery(options.Discovery); err != nil {
+		return nil, err
+	}
 	return
 }
@@ -0,0 +1,61 @@
+package scan
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"testing"
+	"time"
+
+	"udpz/pkg/data"
+)
+
+func TestScanEmptyResponse(t *testing.T) {
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Answer every probe with an empty datagram
+	go func() {
+		buffer := make([]byte, 1500)
+
+		for {
+			_, addr, err := conn.ReadFrom(buffer)
+
+			if err != nil {
+				return
+			}
+			conn.WriteTo(nil, addr)
+		}
+	}()
+	port := uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+	query := []byte{0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01}
+
+	options := DefaultOptions()
+	options.Retransmissions = 0
+	options.ReadTimeout = time.Second
+	options.Services = map[string]data.UdpService{
+		"dns": {
+			Slug:   "dns",
+			Ports:  []uint16{port},
+			Probes: []data.UdpProbe{{Slug: "dns", Service: "dns", EncodedData: base64.StdEncoding.EncodeToString(query)}},
+		},
+	}
+	sc, err := NewUdpProbeScanner(options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := sc.Scan(context.Background(), []string{"127.0.0.1"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Port != port || results[0].State != PORT_STATE_OPEN {
+		t.Errorf("got %+v, want the port open", results)
+	}
+}
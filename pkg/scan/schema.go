@@ -0,0 +1,20 @@
+package scan
+
+import (
+	_ "embed"
+)
+
+const (
+	// SCHEMA_VERSION is the version of RESULTS_SCHEMA. Fields may be added to
+	// the results within a version, but removing, renaming or retyping one
+	// raises it.
+	SCHEMA_VERSION = 1
+)
+
+var (
+	// RESULTS_SCHEMA is the JSON schema of the results written by the json
+	// and jsonl output formats
+	//
+	//go:embed results.schema.json
+	RESULTS_SCHEMA []byte
+)
@@ -0,0 +1,106 @@
+package scan
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"udpz/pkg/data"
+)
+
+// checkSchema reports the fields of value that the schema does not describe,
+// and the required fields it is missing
+func checkSchema(t *testing.T, defs map[string]interface{}, schema map[string]interface{}, value interface{}, path string) {
+
+	if ref, ok := schema["$ref"].(string); ok {
+		schema = defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+
+		for _, required := range required {
+			if _, ok := value[required.(string)]; !ok {
+				t.Errorf("%s: got no %s, want it required", path, required)
+			}
+		}
+		if properties == nil {
+			return
+		}
+		for key, field := range value {
+			if property, ok := properties[key].(map[string]interface{}); !ok {
+				t.Errorf("%s: got %s, want it in the schema", path, key)
+			} else {
+				checkSchema(t, defs, property, field, path+"."+key)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			checkSchema(t, defs, schema["items"].(map[string]interface{}), item, path+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
+
+func TestResultsSchema(t *testing.T) {
+
+	var schema map[string]interface{}
+
+	if err := json.Unmarshal(RESULTS_SCHEMA, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if id := schema["$id"].(string); !strings.HasSuffix(id, "/v"+strconv.Itoa(SCHEMA_VERSION)+".json") {
+		t.Errorf("$id: got %s, want version %d", id, SCHEMA_VERSION)
+	}
+	probe := data.UdpProbe{
+		Slug:        "tftp-read",
+		Name:        "TFTP read",
+		Service:     "tftp",
+		EncodedData: "AAE=",
+		Matches:     []data.UdpMatch{{Pattern: "^\\x00", MinLength: 4, Offset: 1, Bytes: "03", Mask: "ff"}},
+		Steps:       []data.UdpStep{{Extract: []data.UdpExtract{{Name: "block", Offset: 2, Length: 2, Pattern: "(.)"}}, Template: "0004{{block}}"}},
+	}
+	result := Result{
+		Host:      newHost(Target{Type: "CIDR", Target: "10.0.0.0/24"}, net.ParseIP("10.0.0.1"), ""),
+		Port:      69,
+		State:     PORT_STATE_OPEN,
+		Transport: "udp",
+		Probe:     probe,
+		Response:  "AAM=",
+		Service:   data.UdpService{Slug: "tftp", Name: "TFTP", Ports: []uint16{69}, Probes: []data.UdpProbe{probe}, Tags: []string{"common"}},
+		Version:   "1.0",
+		Banner:    "tftpd",
+		Metadata:  map[string]string{"files": "running-config"},
+		CPE:       []string{"cpe:2.3:a:vendor:tftpd:1.0:*:*:*:*:*:*:*"},
+		Capture:   newCapture([]byte{0, 3}, 512),
+
+		Vulnerabilities: []Vulnerability{{ID: "CVE-2000-0001", CPE: "cpe:2.3:a:vendor:tftpd:1.0:*:*:*:*:*:*:*", CVSS: 9.8, Severity: "CRITICAL"}},
+	}
+	encoded, err := json.Marshal([]Result{result, {Host: result.Host, Service: UNKNOWN_SERVICE}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results interface{}
+
+	if err = json.Unmarshal(encoded, &results); err != nil {
+		t.Fatal(err)
+	}
+	checkSchema(t, schema["$defs"].(map[string]interface{}), schema, results, "results")
+
+	encoded, err = json.Marshal(ScanReport{
+		Hosts:   []HostSummary{{Host: "10.0.0.1", Hostname: "tftp.example", ProbesSent: 3, Open: 1, RTT: &RTTStats{Samples: 1, Min: 1, Avg: 1, Max: 1}}},
+		Results: []Result{result},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report interface{}
+
+	if err = json.Unmarshal(encoded, &report); err != nil {
+		t.Fatal(err)
+	}
+	checkSchema(t, schema["$defs"].(map[string]interface{}), map[string]interface{}{"$ref": "#/$defs/report"}, report, "report")
+}
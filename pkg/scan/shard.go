@@ -0,0 +1,173 @@
+package scan
+
+import (
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MAX_SHARDS bounds how finely a single target is split, so huge IPv6
+	// networks produce larger shards rather than billions of small ones
+	MAX_SHARDS = 65536
+)
+
+// ShardTargets divides the target specifications into groups of roughly
+// shardSize hosts. Networks and ranges larger than a shard are split into
+// smaller networks and ranges, and small targets are grouped together.
+// Hostnames count as a single host.
+func ShardTargets(targetSources []string, shardSize uint64) (shards [][]string) {
+
+	if shardSize < 1 {
+		shardSize = 1
+	}
+	var shard []string
+	var shardHosts uint64
+
+	for _, targetSource := range targetSources {
+		for _, piece := range splitTarget(targetSource, shardSize) {
+
+			hosts := estimateHosts([]string{piece})
+
+			if len(shard) > 0 && shardHosts+hosts > shardSize {
+				shards = append(shards, shard)
+				shard, shardHosts = nil, 0
+			}
+			shard = append(shard, piece)
+			shardHosts += hosts
+		}
+	}
+	if len(shard) > 0 {
+		shards = append(shards, shard)
+	}
+	return
+}
+
+// splitTarget splits a network or range into pieces of at most shardSize
+// hosts, or fewer larger pieces if there would be more than MAX_SHARDS
+func splitTarget(targetSource string, shardSize uint64) []string {
+
+	if _, ipNet, err := net.ParseCIDR(targetSource); err == nil {
+		ones, bits := ipNet.Mask.Size()
+
+		// Largest prefix whose networks hold at most shardSize hosts
+		prefix := bits
+		for prefix > ones && uint64(1)<<uint(bits-prefix+1) <= shardSize && bits-prefix+1 < 64 {
+			prefix--
+		}
+		for prefix-ones > 16 {
+			prefix--
+		}
+		if prefix == ones {
+			return []string{targetSource}
+		}
+		pieces := make([]string, 0, 1<<uint(prefix-ones))
+		step := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefix))
+		current := new(big.Int).SetBytes(ipNet.IP)
+
+		for i := 0; i < 1<<uint(prefix-ones); i++ {
+			pieces = append(pieces, bigIP(current, len(ipNet.IP)).String()+"/"+strconv.Itoa(prefix))
+			current.Add(current, step)
+		}
+		return pieces
+	}
+
+	if start, end, ok := parseRange(targetSource); ok {
+		first := new(big.Int).SetBytes(start)
+		last := new(big.Int).SetBytes(end)
+		size := new(big.Int).Sub(last, first)
+		size.Add(size, big.NewInt(1))
+
+		step := new(big.Int).SetUint64(shardSize)
+		maxStep := new(big.Int).Div(size, big.NewInt(MAX_SHARDS))
+
+		if step.Cmp(maxStep) < 0 {
+			step = maxStep
+		}
+		if step.Cmp(size) >= 0 {
+			return []string{targetSource}
+		}
+
+		var pieces []string
+
+		for current := first; current.Cmp(last) <= 0; {
+			pieceEnd := new(big.Int).Add(current, step)
+			pieceEnd.Sub(pieceEnd, big.NewInt(1))
+
+			if pieceEnd.Cmp(last) > 0 {
+				pieceEnd = last
+			}
+			if pieceEnd.Cmp(current) == 0 {
+				pieces = append(pieces, bigIP(current, len(start)).String())
+			} else {
+				pieces = append(pieces, bigIP(current, len(start)).String()+"-"+bigIP(pieceEnd, len(start)).String())
+			}
+			current = new(big.Int).Add(pieceEnd, big.NewInt(1))
+		}
+		return pieces
+	}
+	return []string{targetSource}
+}
+
+// bigIP converts an integer back into an address of the given length
+func bigIP(value *big.Int, length int) net.IP {
+
+	ip := make(net.IP, length)
+	value.FillBytes(ip)
+
+	return ip
+}
+
+// ShardPorts divides the ports into at most count groups of adjacent ports,
+// each formatted as a port specification
+func ShardPorts(ports map[uint16]bool, count int) (shards []string) {
+
+	sorted := make([]int, 0, len(ports))
+
+	for port := range ports {
+		sorted = append(sorted, int(port))
+	}
+	sort.Ints(sorted)
+
+	if count < 1 {
+		count = 1
+	}
+	size := (len(sorted) + count - 1) / count
+
+	for len(sorted) > 0 {
+		if size > len(sorted) {
+			size = len(sorted)
+		}
+		parts := make([]string, size)
+
+		for i, port := range sorted[:size] {
+			parts[i] = strconv.Itoa(port)
+		}
+		shards = append(shards, strings.Join(parts, ","))
+		sorted = sorted[size:]
+	}
+	return
+}
+
+// ScannerFromResults returns a scanner holding results gathered elsewhere, such
+// as from distributed agents, so they can be saved in any output format
+func ScannerFromResults(results []Result, startTime time.Time, endTime time.Time) *UdpProbeScanner {
+
+	sc := &UdpProbeScanner{
+		results:    results,
+		resultsMap: make(map[string]map[uint16][]Result),
+		startTime:  startTime,
+		endTime:    endTime,
+		counters:   new(scanCounters),
+	}
+	for _, result := range results {
+		if _, ok := sc.resultsMap[result.Host.Host]; !ok {
+			sc.resultsMap[result.Host.Host] = make(map[uint16][]Result)
+		}
+		sc.resultsMap[result.Host.Host][result.Port] = append(sc.resultsMap[result.Host.Host][result.Port], result)
+	}
+	return sc
+}
@@ -0,0 +1,28 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package scan
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// controlSocket lets sockets share a fixed source port. Binding to an
+// interface is not supported, so the source address selects the interface.
+func (sc *UdpProbeScanner) controlSocket(network string, address string, c syscall.RawConn) (err error) {
+
+	if sc.sourcePortLow == 0 {
+		return nil
+	}
+	controlErr := c.Control(func(fd uintptr) {
+		if err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+			return
+		}
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if controlErr != nil {
+		return controlErr
+	}
+	return
+}
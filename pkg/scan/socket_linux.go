@@ -0,0 +1,31 @@
+package scan
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// controlSocket binds a socket to the configured interface, so probes leave
+// through it regardless of the routing table, and lets sockets share a fixed
+// source port
+func (sc *UdpProbeScanner) controlSocket(network string, address string, c syscall.RawConn) (err error) {
+
+	controlErr := c.Control(func(fd uintptr) {
+		if sc.sourcePortLow != 0 {
+			if err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+				return
+			}
+			if err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); err != nil {
+				return
+			}
+		}
+		if sc.device != "" {
+			err = unix.BindToDevice(int(fd), sc.device)
+		}
+	})
+	if controlErr != nil {
+		return controlErr
+	}
+	return
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package scan
+
+import (
+	"syscall"
+)
+
+// controlSocket does nothing on platforms without SO_REUSEPORT or a way to
+// bind a socket to an interface, where the source address selects the
+// interface and a fixed source port can only be used by one probe at a time
+func (sc *UdpProbeScanner) controlSocket(network string, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,343 @@
+package scan
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SOCKS5_VERSION = 0x05
+
+	SOCKS5_METHOD_NONE     = 0x00
+	SOCKS5_METHOD_USERPASS = 0x02
+
+	SOCKS5_CMD_UDP_ASSOCIATE = 0x03
+
+	SOCKS5_ATYP_IPV4   = 0x01
+	SOCKS5_ATYP_DOMAIN = 0x03
+	SOCKS5_ATYP_IPV6   = 0x04
+)
+
+var (
+	// SOCKS5_REPLY_ERRORS describes the failure codes a SOCKS5 server may reply with
+	SOCKS5_REPLY_ERRORS = map[byte]string{
+		0x01: "general SOCKS server failure",
+		0x02: "connection not allowed by ruleset",
+		0x03: "network unreachable",
+		0x04: "host unreachable",
+		0x05: "connection refused",
+		0x06: "TTL expired",
+		0x07: "command not supported",
+		0x08: "address type not supported",
+	}
+)
+
+// socks5Dialer relays UDP datagrams through a SOCKS5 proxy using the UDP
+// ASSOCIATE command described in RFC 1928, with optional username and password
+// authentication from RFC 1929. A single association carries the datagrams of
+// every destination, and replies are told apart by the address the proxy
+// reports they came from, so datagrams to the same destination are sent one
+// socket at a time.
+type socks5Dialer struct {
+	address  string
+	username string
+	password string
+	timeout  time.Duration
+
+	mu           sync.Mutex
+	association  *socks5Association
+	destinations destinationLocks
+}
+
+// socks5Association is a UDP association with a SOCKS5 proxy, which lasts as
+// long as the control connection is open
+type socks5Association struct {
+	*demux
+	control net.Conn
+	relay   *net.UDPConn
+}
+
+func newSocks5Dialer(address string, username string, password string, timeout time.Duration) (*socks5Dialer, error) {
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, err
+	}
+	if len(username) > 255 || len(password) > 255 {
+		return nil, errors.New("SOCKS5 username and password must be at most 255 bytes")
+	}
+	return &socks5Dialer{address: address, username: username, password: password, timeout: timeout}, nil
+}
+
+// dial returns a connection relaying datagrams to ip:port, associating with
+// the proxy first if no association is open. It blocks while another
+// connection to the same destination is open.
+func (d *socks5Dialer) dial(ctx context.Context, ip net.IP, port uint16) (net.Conn, error) {
+
+	remote := &net.UDPAddr{IP: ip, Port: int(port)}
+	unlock := d.destinations.lock(demuxKey(ip, int(port)))
+
+	association, err := d.associated(ctx)
+
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	header := append([]byte{0x00, 0x00, 0x00}, socks5Address(ip, port)...)
+
+	// The payload is sent wrapped in a SOCKS5 UDP request header
+	write := func(payload []byte) error {
+		_, err := association.relay.Write(append(header[:len(header):len(header)], payload...))
+		return err
+	}
+	return association.open(association.relay.LocalAddr(), remote, nil, write, unlock), nil
+}
+
+// associated returns the open association, replacing it if the proxy ended it
+func (d *socks5Dialer) associated(ctx context.Context) (*socks5Association, error) {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.association != nil && !d.association.closed() {
+		return d.association, nil
+	}
+	association, err := d.open(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+	d.association = association
+
+	return association, nil
+}
+
+// open associates a UDP relay with the proxy
+func (d *socks5Dialer) open(ctx context.Context) (association *socks5Association, err error) {
+
+	var control net.Conn
+	var relay *net.UDPAddr
+	var udpConn *net.UDPConn
+
+	dialer := net.Dialer{Timeout: d.timeout}
+
+	if control, err = dialer.DialContext(ctx, "tcp", d.address); err != nil {
+		return
+	}
+	if d.timeout > 0 {
+		control.SetDeadline(time.Now().Add(d.timeout))
+	}
+	if relay, err = d.associate(control); err != nil {
+		control.Close()
+		return
+	}
+	control.SetDeadline(time.Time{})
+
+	// Servers commonly reply with an unspecified address, meaning the relay
+	// listens on the same host as the proxy itself
+	if relay.IP.IsUnspecified() {
+		if host, _, err := net.SplitHostPort(control.RemoteAddr().String()); err == nil {
+			relay.IP = net.ParseIP(host)
+		}
+	}
+	if udpConn, err = net.DialUDP("udp", nil, relay); err != nil {
+		control.Close()
+		return
+	}
+	association = &socks5Association{
+		demux:   newDemux(),
+		control: control,
+		relay:   udpConn,
+	}
+	go association.receive()
+	go association.watch()
+
+	return association, nil
+}
+
+// close ends the open association, if any
+func (d *socks5Dialer) close() {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.association != nil {
+		d.association.close(net.ErrClosed)
+		d.association = nil
+	}
+}
+
+// associate authenticates with the proxy and requests a UDP relay, returning
+// the address datagrams should be sent to
+func (d *socks5Dialer) associate(control net.Conn) (relay *net.UDPAddr, err error) {
+
+	method := byte(SOCKS5_METHOD_NONE)
+
+	if d.username != "" || d.password != "" {
+		method = SOCKS5_METHOD_USERPASS
+	}
+	if _, err = control.Write([]byte{SOCKS5_VERSION, 1, method}); err != nil {
+		return
+	}
+
+	reply := make([]byte, 2)
+
+	if _, err = io.ReadFull(control, reply); err != nil {
+		return
+	}
+	if reply[0] != SOCKS5_VERSION {
+		return nil, errors.New("invalid SOCKS5 version in reply: " + strconv.Itoa(int(reply[0])))
+	}
+	if reply[1] != method {
+		return nil, errors.New("SOCKS5 proxy rejected the authentication method")
+	}
+
+	if method == SOCKS5_METHOD_USERPASS {
+		request := []byte{0x01, byte(len(d.username))}
+		request = append(request, d.username...)
+		request = append(request, byte(len(d.password)))
+		request = append(request, d.password...)
+
+		if _, err = control.Write(request); err != nil {
+			return
+		}
+		if _, err = io.ReadFull(control, reply); err != nil {
+			return
+		}
+		if reply[1] != 0x00 {
+			return nil, errors.New("SOCKS5 authentication failed")
+		}
+	}
+
+	// The client address is left unspecified, since it is often behind NAT
+	request := append([]byte{SOCKS5_VERSION, SOCKS5_CMD_UDP_ASSOCIATE, 0x00},
+		socks5Address(net.IPv4zero, 0)...)
+
+	if _, err = control.Write(request); err != nil {
+		return
+	}
+
+	header := make([]byte, 4)
+
+	if _, err = io.ReadFull(control, header); err != nil {
+		return
+	}
+	if header[1] != 0x00 {
+		message, ok := SOCKS5_REPLY_ERRORS[header[1]]
+		if !ok {
+			message = "unknown error " + strconv.Itoa(int(header[1]))
+		}
+		return nil, errors.New("SOCKS5 UDP associate failed: " + message)
+	}
+
+	var address []byte
+
+	switch header[3] {
+	case SOCKS5_ATYP_IPV4:
+		address = make([]byte, net.IPv4len+2)
+	case SOCKS5_ATYP_IPV6:
+		address = make([]byte, net.IPv6len+2)
+	case SOCKS5_ATYP_DOMAIN:
+		length := make([]byte, 1)
+
+		if _, err = io.ReadFull(control, length); err != nil {
+			return
+		}
+		address = make([]byte, int(length[0])+2)
+	default:
+		return nil, errors.New("invalid SOCKS5 address type: " + strconv.Itoa(int(header[3])))
+	}
+	if _, err = io.ReadFull(control, address); err != nil {
+		return
+	}
+	port := binary.BigEndian.Uint16(address[len(address)-2:])
+	host := address[:len(address)-2]
+
+	if header[3] == SOCKS5_ATYP_DOMAIN {
+		return net.ResolveUDPAddr("udp", net.JoinHostPort(string(host), strconv.Itoa(int(port))))
+	}
+	return &net.UDPAddr{IP: net.IP(host), Port: int(port)}, nil
+}
+
+// socks5Address encodes an address as ATYP, DST.ADDR and DST.PORT
+func socks5Address(ip net.IP, port uint16) (address []byte) {
+
+	if ip4 := ip.To4(); ip4 != nil {
+		address = append([]byte{SOCKS5_ATYP_IPV4}, ip4...)
+	} else {
+		address = append([]byte{SOCKS5_ATYP_IPV6}, ip.To16()...)
+	}
+	return append(address, byte(port>>8), byte(port))
+}
+
+// receive hands each relayed datagram to the connection of the destination
+// it came from, until the association ends. Fragmented datagrams are not
+// supported and are dropped, as are datagrams no connection is waiting for.
+func (a *socks5Association) receive() {
+
+	datagram := make([]byte, 0x10000)
+
+	for {
+		n, err := a.relay.Read(datagram)
+
+		if err != nil {
+			a.close(err)
+			return
+		}
+		if n < 4 || datagram[2] != 0x00 {
+			continue
+		}
+
+		var source string
+		offset := 4
+
+		switch datagram[3] {
+		case SOCKS5_ATYP_IPV4, SOCKS5_ATYP_IPV6:
+			length := net.IPv4len
+
+			if datagram[3] == SOCKS5_ATYP_IPV6 {
+				length = net.IPv6len
+			}
+			if n < offset+length+2 {
+				continue
+			}
+			ip := net.IP(datagram[offset : offset+length])
+			port := binary.BigEndian.Uint16(datagram[offset+length:])
+			source = demuxKey(ip, int(port))
+			offset += length + 2
+
+		case SOCKS5_ATYP_DOMAIN:
+			if n < 5 || n < 5+int(datagram[4])+2 {
+				continue
+			}
+			host := string(datagram[5 : 5+int(datagram[4])])
+			port := binary.BigEndian.Uint16(datagram[5+int(datagram[4]):])
+			source = net.JoinHostPort(host, strconv.Itoa(int(port)))
+			offset += 1 + int(datagram[4]) + 2
+
+		default:
+			continue
+		}
+
+		a.deliver(source, demuxMessage{payload: append([]byte(nil), datagram[offset:n]...)})
+	}
+}
+
+// watch ends the association once the proxy closes the control connection
+func (a *socks5Association) watch() {
+	io.Copy(io.Discard, a.control)
+	a.close(errors.New("SOCKS5 proxy ended the UDP association"))
+}
+
+// close ends the association, failing reads of its connections with err
+func (a *socks5Association) close(err error) {
+	if a.demux.close(err) {
+		a.control.Close()
+		a.relay.Close()
+	}
+}
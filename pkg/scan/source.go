@@ -0,0 +1,379 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SetSource sends probes from the given interface and/or source address, for
+// hosts with several network interfaces. Without a source address, the first
+// address of each family assigned to the interface is used. Only addresses of
+// the available source families are scanned.
+func (sc *UdpProbeScanner) SetSource(interfaceName string, sourceIP string) (err error) {
+
+	sc.device = interfaceName
+	sc.sourceIPv4, sc.sourceIPv6 = nil, nil
+
+	if interfaceName == "" && sourceIP == "" {
+		return nil
+	}
+	var source net.IP
+
+	if sourceIP != "" {
+		if source = net.ParseIP(sourceIP); source == nil {
+			return errors.New("invalid source IP: " + sourceIP)
+		}
+	}
+
+	if interfaceName != "" {
+		if err = sc.setSourceInterface(interfaceName, source); err != nil {
+			return err
+		}
+	} else if source.To4() != nil {
+		sc.sourceIPv4 = source
+	} else {
+		sc.sourceIPv6 = source
+	}
+
+	switch {
+	case sc.sourceIPv4 == nil && sc.sourceIPv6 == nil:
+		return errors.New("no usable address on interface: " + interfaceName)
+	case sc.addressFamily == 4 && sc.sourceIPv4 == nil:
+		return errors.New("no IPv4 source address available")
+	case sc.addressFamily == 6 && sc.sourceIPv6 == nil:
+		return errors.New("no IPv6 source address available")
+	case sc.sourceIPv6 == nil:
+		sc.addressFamily = 4
+	case sc.sourceIPv4 == nil:
+		sc.addressFamily = 6
+	}
+
+	// Binding may require privileges or fail for addresses that are not local,
+	// so check it works now rather than failing every probe
+	for _, source := range []net.IP{sc.sourceIPv4, sc.sourceIPv6} {
+		if source == nil {
+			continue
+		}
+		listener := net.ListenConfig{Control: sc.controlSocket}
+		conn, err := listener.ListenPacket(context.Background(), "udp", net.JoinHostPort(source.String(), "0"))
+
+		if err != nil {
+			return errors.New("could not send from " + source.String() + ": " + err.Error())
+		}
+		conn.Close()
+	}
+
+	sc.Logger.Debug().
+		Str("interface", interfaceName).
+		IPAddr("source_ipv4", sc.sourceIPv4).
+		IPAddr("source_ipv6", sc.sourceIPv6).
+		Msg("Using source address")
+
+	return nil
+}
+
+// setSourceInterface picks the source addresses from those assigned to the
+// interface, checking that the source address belongs to it if one is given
+func (sc *UdpProbeScanner) setSourceInterface(interfaceName string, source net.IP) error {
+
+	iface, err := net.InterfaceByName(interfaceName)
+
+	if err != nil {
+		return errors.New("unknown network interface: " + interfaceName)
+	}
+	addrs, err := iface.Addrs()
+
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+
+		if source != nil {
+			if ip.Equal(source) {
+				if ip.To4() != nil {
+					sc.sourceIPv4 = ip.To4()
+				} else {
+					sc.sourceIPv6 = ip
+				}
+				return nil
+			}
+			continue
+		}
+
+		// Link-local addresses cannot reach other networks
+		if ip.To4() != nil && sc.sourceIPv4 == nil {
+			sc.sourceIPv4 = ip.To4()
+		} else if ip.To4() == nil && sc.sourceIPv6 == nil && !ip.IsLinkLocalUnicast() {
+			sc.sourceIPv6 = ip
+		}
+	}
+
+	if source != nil {
+		return errors.New("source IP " + source.String() + " is not assigned to interface " + interfaceName)
+	}
+	return nil
+}
+
+// SetSourcePort sends probes from a fixed local port, such as 53 or 123 for
+// networks that only let replies to those ports through, or from a range of
+// ports given as "LOW-HIGH" which are used in turn. Sockets are opened with
+// SO_REUSEPORT so concurrent probes can share a port, while probes to the same
+// destination wait for each other so their replies cannot reach the wrong
+// socket. An empty spec lets the operating system choose.
+func (sc *UdpProbeScanner) SetSourcePort(spec string) error {
+
+	sc.sourcePortLow, sc.sourcePortHigh = 0, 0
+
+	if spec == "" {
+		return nil
+	}
+	lowSpec, highSpec, isRange := strings.Cut(spec, "-")
+
+	if !isRange {
+		highSpec = lowSpec
+	}
+	low, lowErr := strconv.ParseUint(strings.TrimSpace(lowSpec), 10, 16)
+	high, highErr := strconv.ParseUint(strings.TrimSpace(highSpec), 10, 16)
+
+	if lowErr != nil || highErr != nil || low == 0 || high < low {
+		return errors.New("invalid source port: " + spec)
+	}
+	sc.sourcePortLow, sc.sourcePortHigh = uint16(low), uint16(high)
+
+	// Ports below 1024 usually require root, so check binding works now
+	// rather than failing every probe
+	var addresses []string
+
+	for _, source := range []net.IP{sc.sourceIPv4, sc.sourceIPv6} {
+		if source != nil {
+			addresses = append(addresses, source.String())
+		}
+	}
+	if len(addresses) == 0 {
+		addresses = []string{""}
+	}
+	for _, address := range addresses {
+		listener := net.ListenConfig{Control: sc.controlSocket}
+		conn, err := listener.ListenPacket(context.Background(), "udp", net.JoinHostPort(address, strconv.Itoa(int(low))))
+
+		if err != nil {
+			sc.sourcePortLow, sc.sourcePortHigh = 0, 0
+			return errors.New("could not send from port " + strconv.Itoa(int(low)) + ": " + err.Error())
+		}
+		conn.Close()
+	}
+
+	sc.Logger.Debug().
+		Uint16("low", sc.sourcePortLow).
+		Uint16("high", sc.sourcePortHigh).
+		Msg("Using source port")
+
+	return nil
+}
+
+// nextSourcePort returns the local port for the next probe, or 0 to let the
+// operating system choose
+func (sc *UdpProbeScanner) nextSourcePort() int {
+
+	if sc.sourcePortLow == 0 {
+		return 0
+	}
+	count := uint32(sc.sourcePortHigh-sc.sourcePortLow) + 1
+	next := atomic.AddUint32(&sc.sourcePortNext, 1) - 1
+
+	return int(sc.sourcePortLow) + int(next%count)
+}
+
+// sourceFor returns the source address to send probes to ip from, if any
+func (sc *UdpProbeScanner) sourceFor(ip net.IP) net.IP {
+	if ip.To4() != nil {
+		return sc.sourceIPv4
+	}
+	return sc.sourceIPv6
+}
+
+// destinationLocks serializes sockets to the same destination. With fixed
+// source ports, two such sockets could share the same addresses and ports, and
+// the replies of one would be delivered to the other.
+type destinationLocks struct {
+	mu    sync.Mutex
+	locks map[string]*destinationLock
+}
+
+type destinationLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock blocks until no other socket to the destination is open, and returns
+// the function releasing it
+func (dl *destinationLocks) lock(key string) func() {
+
+	dl.mu.Lock()
+	if dl.locks == nil {
+		dl.locks = make(map[string]*destinationLock)
+	}
+	lock, ok := dl.locks[key]
+	if !ok {
+		lock = &destinationLock{}
+		dl.locks[key] = lock
+	}
+	lock.refs++
+	dl.mu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		dl.mu.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(dl.locks, key)
+		}
+		dl.mu.Unlock()
+	}
+}
+
+// lockedConn releases its destination once closed
+type lockedConn struct {
+	net.Conn
+	once   sync.Once
+	unlock func()
+}
+
+func (c *lockedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.unlock)
+	return err
+}
+
+// dial opens a UDP socket to the host, from the configured interface and
+// source address
+func (sc *UdpProbeScanner) dial(ctx context.Context, host Host, port uint16) (net.Conn, error) {
+
+	if sc.sourcePortLow != 0 {
+		unlock := sc.destinations.lock(unreachableKey(host.ip, port))
+		conn, err := sc.dialFrom(ctx, host, port)
+
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		return &lockedConn{Conn: conn, unlock: unlock}, nil
+	}
+	return sc.dialFrom(ctx, host, port)
+}
+
+func (sc *UdpProbeScanner) dialFrom(ctx context.Context, host Host, port uint16) (net.Conn, error) {
+
+	dialer := net.Dialer{}
+
+	if sc.device != "" || sc.sourcePortLow != 0 {
+		dialer.Control = sc.controlSocket
+	}
+	if source, sourcePort := sc.sourceFor(host.ip), sc.nextSourcePort(); source != nil || sourcePort != 0 {
+		dialer.LocalAddr = &net.UDPAddr{IP: source, Port: sourcePort}
+	}
+	return dialer.DialContext(ctx, "udp", net.JoinHostPort(host.address(), strconv.Itoa(int(port))))
+}
+
+// dialAnyPort opens a UDP socket to the host like dial, but that accepts
+// responses from any port of the host, for services that answer from another
+// port than the one probed. Unlike connected sockets, it receives no port
+// unreachable errors.
+func (sc *UdpProbeScanner) dialAnyPort(ctx context.Context, host Host, port uint16) (net.Conn, error) {
+
+	if sc.sourcePortLow != 0 {
+		unlock := sc.destinations.lock(unreachableKey(host.ip, port))
+		conn, err := sc.listenFrom(ctx, host, port)
+
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		return &lockedConn{Conn: conn, unlock: unlock}, nil
+	}
+	return sc.listenFrom(ctx, host, port)
+}
+
+func (sc *UdpProbeScanner) listenFrom(ctx context.Context, host Host, port uint16) (net.Conn, error) {
+
+	network := "udp4"
+
+	if host.ip.To4() == nil {
+		network = "udp6"
+	}
+	config := net.ListenConfig{}
+
+	if sc.device != "" || sc.sourcePortLow != 0 {
+		config.Control = sc.controlSocket
+	}
+	local := &net.UDPAddr{IP: sc.sourceFor(host.ip), Port: sc.nextSourcePort()}
+	packetConn, err := config.ListenPacket(ctx, network, local.String())
+
+	if err != nil {
+		return nil, err
+	}
+	conn := packetConn.(*net.UDPConn)
+
+	if sc.ttl > 0 {
+		if err = setHopLimit(conn, host.ip, sc.ttl); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &anyPortConn{UDPConn: conn, remote: &net.UDPAddr{IP: host.ip, Port: int(port), Zone: host.zone}}, nil
+}
+
+// anyPortConn sends to the probed port of a host and reads what any of its
+// ports sends back. Once a response is read, RemoteAddr is the port it came
+// from, and later requests of the exchange are sent to that port, as TFTP
+// servers expect the ACKs of a transfer on the port they send its data from.
+type anyPortConn struct {
+	*net.UDPConn
+	remote *net.UDPAddr
+	source *net.UDPAddr
+}
+
+func (c *anyPortConn) Write(b []byte) (int, error) {
+
+	if c.source != nil {
+		return c.WriteToUDP(b, c.source)
+	}
+	return c.WriteToUDP(b, c.remote)
+}
+
+func (c *anyPortConn) Read(b []byte) (int, error) {
+
+	for {
+		n, source, err := c.ReadFromUDP(b)
+
+		if err != nil {
+			return n, err
+		}
+		if source.IP.Equal(c.remote.IP) {
+			c.source = source
+			return n, nil
+		}
+	}
+}
+
+func (c *anyPortConn) RemoteAddr() net.Addr {
+
+	if c.source != nil {
+		return c.source
+	}
+	return c.remote
+}
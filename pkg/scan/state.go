@@ -0,0 +1,169 @@
+package scan
+
+import (
+	"sort"
+	"sync"
+
+	"udpz/pkg/data"
+	"udpz/pkg/proto"
+)
+
+// portStates tracks the state of every port probed on a single host
+type portStates struct {
+	mu        sync.Mutex
+	states    map[uint16]uint8
+	services  map[uint16]string
+	unmatched map[uint16]Result
+}
+
+func newPortStates() *portStates {
+	return &portStates{
+		states:    make(map[uint16]uint8),
+		services:  make(map[uint16]string),
+		unmatched: make(map[uint16]Result),
+	}
+}
+
+// stateRank orders states by how much they reveal about a port
+func stateRank(state uint8) int {
+	switch state {
+	case STATE_RESPONSIVE:
+		return 3
+	case STATE_UNMATCHED:
+		return 2
+	case STATE_CLOSED:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// track registers a port before it is probed, remembering the first service
+// expected to listen on it
+func (ps *portStates) track(port uint16, service string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if _, ok := ps.states[port]; !ok {
+		ps.states[port] = STATE_UNRESPONSIVE
+		ps.services[port] = service
+	}
+}
+
+func (ps *portStates) get(port uint16) uint8 {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return ps.states[port]
+}
+
+// set updates the state of a port. A port is never downgraded to a state that
+// reveals less about it.
+func (ps *portStates) set(port uint16, state uint8) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if stateRank(state) > stateRank(ps.states[port]) {
+		ps.states[port] = state
+	}
+}
+
+// setUnmatched records a response that did not match the probed service, to
+// be reported if no other probe identifies the port
+func (ps *portStates) setUnmatched(port uint16, result Result) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if stateRank(STATE_UNMATCHED) > stateRank(ps.states[port]) {
+		ps.states[port] = STATE_UNMATCHED
+		ps.unmatched[port] = result
+	}
+}
+
+// reportPortStates records a result for every closed port on the host once all
+// of its probes have finished. Ports that never responded are reported as
+// open|filtered, but only for hosts that showed some sign of life, the same way
+// nmap omits the ports of hosts that appear to be down.
+func (sc *UdpProbeScanner) reportPortStates(host Host, states *portStates) {
+
+	var ports []uint16
+	var openCount, closedCount, openFilteredCount int
+
+	for port, state := range states.states {
+		ports = append(ports, port)
+
+		switch state {
+		case STATE_RESPONSIVE, STATE_UNMATCHED:
+			openCount++
+		case STATE_CLOSED:
+			closedCount++
+		default:
+			openFilteredCount++
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i] < ports[j]
+	})
+
+	sc.Logger.Debug().
+		Str("target", host.Target.Target).
+		Str("host", host.Host).
+		Int("open", openCount).
+		Int("closed", closedCount).
+		Int("open_filtered", openFilteredCount).
+		Msg("Finished scanning host")
+
+	if openCount+closedCount == 0 {
+		return
+	}
+	for _, port := range ports {
+
+		result := Result{
+			Host:      host,
+			Port:      port,
+			Transport: "udp",
+			Service:   sc.Services[states.services[port]],
+		}
+		switch states.states[port] {
+		case STATE_UNMATCHED:
+			result = states.unmatched[port]
+			result.State = PORT_STATE_OPEN
+			result.Service = UNKNOWN_SERVICE
+
+			// Another service may answer on the port, such as a DNS server
+			// on the port of mDNS
+			if len(result.payload) > 0 {
+				if slug, info, ok := proto.Identify(result.Probe.Service, result.payload); ok {
+					result.Service = sc.lookupService(slug)
+					result.setInfo(info)
+				}
+			}
+		case STATE_CLOSED:
+			result.State = PORT_STATE_CLOSED
+		case STATE_UNRESPONSIVE:
+			result.State = PORT_STATE_OPEN_FILTERED
+
+			// Services that drop probes they cannot authenticate stay
+			// silent where closed ports would have been reported
+			if info, ok := proto.Silent(states.services[port]); ok && closedCount > 0 {
+				result.setInfo(info)
+			}
+		default:
+			continue
+		}
+		sc.resultsLive <- result
+	}
+}
+
+// lookupService returns the service of the slug from the probe database, or
+// from the built-in services if the scan does not probe it
+func (sc *UdpProbeScanner) lookupService(slug string) data.UdpService {
+
+	if service, ok := data.LookupService(sc.Services, slug); ok {
+		return service
+	}
+	if service, ok := data.LookupService(data.UDP_SERVICES, slug); ok {
+		return service
+	}
+	return data.UdpService{Slug: slug, Name: slug, NameShort: slug}
+}
@@ -0,0 +1,76 @@
+package scan
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ResultSink receives each result as soon as the scanner confirms it
+type ResultSink interface {
+	WriteResult(result Result) error
+}
+
+// FlushingSink is a sink that buffers results, and is flushed once the scan
+// finishes
+type FlushingSink interface {
+	ResultSink
+	Flush() error
+}
+
+// ChangeSink receives the ports that changed since the previous scan when
+// watching, instead of every result
+type ChangeSink interface {
+	WriteChange(change PortChange) error
+}
+
+// JsonlStream writes every result to the output as a single line of JSON
+type JsonlStream struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewJsonlStream(output io.Writer) *JsonlStream {
+	return &JsonlStream{encoder: json.NewEncoder(output)}
+}
+
+func (js *JsonlStream) WriteResult(result Result) error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	return js.encoder.Encode(&result)
+}
+
+// flushSinks sends any results still buffered by the registered sinks
+func (sc *UdpProbeScanner) flushSinks() {
+	for _, sink := range sc.sinks {
+		if flusher, ok := sink.(FlushingSink); ok {
+			if err := flusher.Flush(); err != nil {
+				sc.Logger.Error().
+					Err(err).
+					Msg("Failed to flush results")
+
+				atomic.AddUint64(&sc.counters.errors, 1)
+			}
+		}
+	}
+}
+
+// emitResult forwards a newly recorded result to every registered sink
+func (sc *UdpProbeScanner) emitResult(pr Result) {
+	if sc.stream != nil {
+		sc.stream <- pr
+	}
+	for _, sink := range sc.sinks {
+		if err := sink.WriteResult(pr); err != nil {
+			sc.Logger.Error().
+				Err(err).
+				Str("host", pr.Host.Host).
+				Uint16("port", pr.Port).
+				Msg("Failed to stream result")
+
+			atomic.AddUint64(&sc.counters.errors, 1)
+		}
+	}
+}
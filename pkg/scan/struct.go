@@ -2,28 +2,86 @@ package scan
 
 import (
 	"net"
+	"sync"
 	"time"
 	"udpz/pkg/data"
+	"udpz/pkg/proto"
 
 	"github.com/rs/zerolog"
-	//"github.com/txthinking/socks5"
+	"golang.org/x/net/icmp"
 )
 
 type UdpProbeScanner struct {
-	HostConcurrency  uint
-	PortConcurrency  uint
-	ProbeCount       uint
-	Retransmissions  uint
-	scanAllAddresses bool
-	ReadTimeout      time.Duration
-
-	Logger zerolog.Logger
-	//proxy    *socks5.Client
+	HostConcurrency    uint
+	PortConcurrency    uint
+	ResolveConcurrency uint // Maximum number of host names resolved at once
+	Retransmissions    uint
+	RetryBackoff       float64       // Read timeout multiplier applied on each retransmission
+	Jitter             time.Duration // Maximum random delay before each probe
+	scanAllAddresses   bool
+	openOnly           bool // Only record ports confirmed open
+	allStates          bool // Also record closed ports
+	ReadTimeout        time.Duration
+	AdaptiveTimeout    bool // Shorten the read timeout of each host to its measured round trip time
+
+	// Services holds the probe database used for scanning
+	Services map[string]data.UdpService
+
+	resolver      *hostResolver // Resolves host names, or nil for the system resolver
+	excludes      []ipRange
+	addressFamily int
+	device        string // Interface to send probes from
+	sourceIPv4    net.IP
+	sourceIPv6    net.IP
+
+	sourcePortLow  uint16 // Local ports to send probes from, or 0 for any
+	sourcePortHigh uint16
+	sourcePortNext uint32
+	ttl            int // IP TTL of probes, or 0 for the default
+	limiter        *rateLimiter
+	roe            *roeGuard // Enforces the rules of engagement, if any
+	overrides      []*targetOverride
+	adapter        *rateAdapter
+	sinks          []ResultSink
+	captureLength  int
+	pcap           *PcapWriter
+
+	destinations destinationLocks // Serialize probes to a destination from fixed source ports
+
+	sockets  int  // Shared sockets per address family, or 0 for a socket per probe
+	batch    bool // Send and receive probes in batches over shared sockets
+	poolMu   sync.Mutex
+	pool     map[int][]*sharedSocket // Shared sockets by address family
+	poolNext uint32
+
+	upnpDescriptions bool     // Fetch the device descriptions of UPnP responses
+	upnpDescribed    sync.Map // Parsed device descriptions by location
+
+	vulnerabilities VulnerabilitySource // Annotates results with the CVEs of their CPE names
+
+	discoveryPing   bool // Discover hosts with ICMP echo requests
+	discoveryProbes []discoveryProbe
+
+	statsMu sync.Mutex
+	stats   map[string]*hostStats // Probes sent, round trip times and errors by host
+
+	startTime time.Time
+	endTime   time.Time
+	counters  *scanCounters
+
+	icmpConns   []*icmp.PacketConn
+	unreachable sync.Map
+	watched     sync.Map // Hosts whose ICMP port unreachable messages are recorded
+	hopWaiters  sync.Map // Channels of TTL sweep probes awaiting ICMP errors
+
+	Logger   zerolog.Logger
+	proxy    *socks5Dialer
 	useProxy bool
 
-	resultsLive chan PortResult
-	results     []PortResult
-	resultsMap  map[string]map[uint16][]PortResult
+	resultsLive chan Result
+	stream      chan<- Result
+	results     []Result
+	resultsMap  map[string]map[uint16][]Result
 }
 
 type Target struct {
@@ -36,13 +94,41 @@ type Host struct {
 	Host   string `yaml:"host" json:"host"`
 	Target Target `yaml:"target" json:"target"`
 	ip     net.IP
+	zone   string
+
+	override *targetOverride // Settings the host is scanned with, if any
 }
 
-type PortResult struct {
+type Result struct {
 	Host      Host            `yaml:"host" json:"host"`
 	Port      uint16          `yaml:"port" json:"port"`
+	State     string          `yaml:"state" json:"state"`
 	Transport string          `yaml:"transport" json:"transport"`
 	Probe     data.UdpProbe   `yaml:"probe" json:"probe"`
 	Response  string          `yaml:"response" json:"response"`
 	Service   data.UdpService `yaml:"service" json:"service"`
+
+	Version  string            `yaml:"version,omitempty" json:"version,omitempty"`
+	Banner   string            `yaml:"banner,omitempty" json:"banner,omitempty"`
+	Metadata map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	CPE      []string          `yaml:"cpe,omitempty" json:"cpe,omitempty"`
+	Capture  *Capture          `yaml:"capture,omitempty" json:"capture,omitempty"`
+
+	Vulnerabilities []Vulnerability `yaml:"vulnerabilities,omitempty" json:"vulnerabilities,omitempty"`
+
+	payload []byte // Raw response
+}
+
+// info returns the version information of the result
+func (pr Result) info() proto.Info {
+	return proto.Info{Version: pr.Version, Banner: pr.Banner, Metadata: pr.Metadata}
+}
+
+// setInfo replaces the version information of the result, and the CPE names
+// it maps to
+func (pr *Result) setInfo(info proto.Info) {
+	pr.Version = info.Version
+	pr.Banner = info.Banner
+	pr.Metadata = info.Metadata
+	pr.CPE = proto.CPEs(pr.Service.Slug, info)
 }
@@ -0,0 +1,286 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"gopkg.in/yaml.v3"
+)
+
+// HostSummary sums up the scan of a host: the probes sent to it, how many of
+// its ports were found in each state, how fast it answered and the errors
+// probing it ran into
+type HostSummary struct {
+	Host         string    `yaml:"host" json:"host"`
+	Hostname     string    `yaml:"hostname,omitempty" json:"hostname,omitempty"`
+	ProbesSent   uint64    `yaml:"probes_sent" json:"probes_sent"`
+	Open         int       `yaml:"open" json:"open"`
+	OpenFiltered int       `yaml:"open_filtered" json:"open_filtered"`
+	Closed       int       `yaml:"closed" json:"closed"`
+	Errors       uint64    `yaml:"errors" json:"errors"`
+	RTT          *RTTStats `yaml:"rtt,omitempty" json:"rtt,omitempty"`
+}
+
+// RTTStats are the round trip times measured from the responses and ICMP
+// port unreachable errors of a host, in milliseconds
+type RTTStats struct {
+	Samples int     `yaml:"samples" json:"samples"`
+	Min     float64 `yaml:"min_ms" json:"min_ms"`
+	Avg     float64 `yaml:"avg_ms" json:"avg_ms"`
+	Max     float64 `yaml:"max_ms" json:"max_ms"`
+}
+
+// ScanReport is the results of a scan with a summary of each host
+type ScanReport struct {
+	Hosts   []HostSummary `yaml:"hosts" json:"hosts"`
+	Results []Result      `yaml:"results" json:"results"`
+}
+
+// hostStats counts what the scan of a host sent and measured
+type hostStats struct {
+	mu         sync.Mutex
+	probesSent uint64
+	errors     uint64
+	rttSamples int
+	rttMin     time.Duration
+	rttMax     time.Duration
+	rttTotal   time.Duration
+	hidden     map[uint16]string // States of the ports left out of the results
+}
+
+func (s *hostStats) sent() {
+	s.mu.Lock()
+	s.probesSent++
+	s.mu.Unlock()
+}
+
+func (s *hostStats) failed() {
+	s.mu.Lock()
+	s.errors++
+	s.mu.Unlock()
+}
+
+// hide records the state of a port left out of the results
+func (s *hostStats) hide(port uint16, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hidden == nil {
+		s.hidden = make(map[uint16]string)
+	}
+	s.hidden[port] = state
+}
+
+func (s *hostStats) observe(rtt time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rttSamples == 0 || rtt < s.rttMin {
+		s.rttMin = rtt
+	}
+	if rtt > s.rttMax {
+		s.rttMax = rtt
+	}
+	s.rttTotal += rtt
+	s.rttSamples++
+}
+
+// statsFor returns the counters of the host in the current scan
+func (sc *UdpProbeScanner) statsFor(host Host) *hostStats {
+
+	sc.statsMu.Lock()
+	defer sc.statsMu.Unlock()
+
+	if sc.stats == nil {
+		sc.stats = make(map[string]*hostStats)
+	}
+	stats, ok := sc.stats[host.Host]
+
+	if !ok {
+		stats = &hostStats{}
+		sc.stats[host.Host] = stats
+	}
+	return stats
+}
+
+// HostSummaries sums up the scan of each host that was probed or has
+// results, ordered by address. Port states are counted once per port, which
+// is open if any of its services is, including the ports whose state is left
+// out of the results.
+func (sc *UdpProbeScanner) HostSummaries() []HostSummary {
+
+	summaries := map[string]*HostSummary{}
+
+	summaryFor := func(host string) *HostSummary {
+		summary, ok := summaries[host]
+
+		if !ok {
+			summary = &HostSummary{Host: host}
+			summaries[host] = summary
+		}
+		return summary
+	}
+	states := map[string]map[uint16]string{}
+
+	for _, result := range sc.results {
+
+		summary := summaryFor(result.Host.Host)
+
+		if summary.Hostname == "" {
+			summary.Hostname = result.Host.Hostname()
+		}
+		if states[result.Host.Host] == nil {
+			states[result.Host.Host] = map[uint16]string{}
+		}
+		if state, ok := states[result.Host.Host][result.Port]; !ok || PORT_STATE_RANKS[result.State] > PORT_STATE_RANKS[state] {
+			states[result.Host.Host][result.Port] = result.State
+		}
+	}
+	sc.statsMu.Lock()
+
+	for host, stats := range sc.stats {
+
+		stats.mu.Lock()
+
+		for port, state := range stats.hidden {
+			if states[host] == nil {
+				states[host] = map[uint16]string{}
+			}
+			if _, ok := states[host][port]; !ok {
+				states[host][port] = state
+			}
+		}
+		stats.mu.Unlock()
+	}
+	sc.statsMu.Unlock()
+
+	for host, ports := range states {
+		for _, state := range ports {
+			switch state {
+			case PORT_STATE_OPEN:
+				summaryFor(host).Open++
+			case PORT_STATE_OPEN_FILTERED:
+				summaryFor(host).OpenFiltered++
+			case PORT_STATE_CLOSED:
+				summaryFor(host).Closed++
+			}
+		}
+	}
+	sc.statsMu.Lock()
+
+	for host, stats := range sc.stats {
+
+		summary := summaryFor(host)
+
+		stats.mu.Lock()
+		summary.ProbesSent = stats.probesSent
+		summary.Errors = stats.errors
+
+		if stats.rttSamples > 0 {
+			summary.RTT = &RTTStats{
+				Samples: stats.rttSamples,
+				Min:     milliseconds(stats.rttMin),
+				Avg:     milliseconds(stats.rttTotal / time.Duration(stats.rttSamples)),
+				Max:     milliseconds(stats.rttMax),
+			}
+		}
+		stats.mu.Unlock()
+	}
+	sc.statsMu.Unlock()
+
+	sorted := make([]HostSummary, 0, len(summaries))
+
+	for _, summary := range summaries {
+		sorted = append(sorted, *summary)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+
+		a := net.ParseIP(strings.Trim(sorted[i].Host, "[]")).To16()
+		b := net.ParseIP(strings.Trim(sorted[j].Host, "[]")).To16()
+
+		if order := bytes.Compare(a, b); order != 0 {
+			return order < 0
+		}
+		return sorted[i].Host < sorted[j].Host
+	})
+	return sorted
+}
+
+// milliseconds returns a duration in milliseconds, to the microsecond
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Round(time.Microsecond)) / float64(time.Millisecond)
+}
+
+// SaveJsonReport writes the results as JSON, along with a summary of each
+// host
+func (sc *UdpProbeScanner) SaveJsonReport(output io.Writer) error {
+
+	results := sc.results
+
+	if results == nil {
+		results = []Result{}
+	}
+	data, err := json.Marshal(ScanReport{Hosts: sc.HostSummaries(), Results: results})
+
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(data)
+	return err
+}
+
+// SaveYAMLReport writes the results as YAML, along with a summary of each
+// host. The report starts a document of its own, so that reports appended to
+// the same file stay apart.
+func (sc *UdpProbeScanner) SaveYAMLReport(output io.Writer) error {
+
+	data, err := yaml.Marshal(ScanReport{Hosts: sc.HostSummaries(), Results: sc.results})
+
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(append([]byte("---\n"), data...))
+	return err
+}
+
+// SaveHostTable writes the summary of each host as a table in one of the
+// table output formats. It follows the results table, after a blank line, or
+// under a heading of its own in Markdown.
+func (sc *UdpProbeScanner) SaveHostTable(format string, output io.Writer) error {
+
+	hostsTable := table.NewWriter()
+	hostsTable.AppendHeader(table.Row{"Host", "Hostname", "Probes", "Open", "Open|Filtered", "Closed", "RTT min/avg/max", "Errors"})
+
+	for _, summary := range sc.HostSummaries() {
+
+		rtt := "-"
+
+		if summary.RTT != nil {
+			rtt = fmt.Sprintf("%.2f/%.2f/%.2f ms", summary.RTT.Min, summary.RTT.Avg, summary.RTT.Max)
+		}
+		hostsTable.AppendRow(table.Row{
+			summary.Host, summary.Hostname, summary.ProbesSent,
+			summary.Open, summary.OpenFiltered, summary.Closed, rtt, summary.Errors,
+		})
+	}
+	heading := "\n"
+
+	if format == "markdown" || format == "md" {
+		heading = "\n## Hosts\n\n"
+	}
+	if _, err := io.WriteString(output, heading); err != nil {
+		return err
+	}
+	hostsTable.SetOutputMirror(output)
+	renderTable(hostsTable, format)
+
+	return nil
+}
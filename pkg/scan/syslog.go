@@ -0,0 +1,208 @@
+package scan
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SYSLOG_FACILITY_LOCAL0 = 16
+
+	SYSLOG_SEVERITY_NOTICE = 5
+	SYSLOG_SEVERITY_INFO   = 6
+
+	// SYSLOG_SD_ID names the structured data element holding result fields. The
+	// enterprise number is the one reserved for documentation (RFC 5612).
+	SYSLOG_SD_ID = "udpz@32473"
+)
+
+// SyslogSink sends results and scan events to a syslog server as RFC 5424
+// messages over UDP, TCP or a unix socket
+type SyslogSink struct {
+	mu       sync.Mutex
+	network  string
+	address  string
+	conn     net.Conn
+	hostname string
+	procID   string
+}
+
+// NewSyslogSink connects to the syslog server at target, given as
+// udp://host:port, tcp://host:port, unix:///path or a bare host:port for UDP
+func NewSyslogSink(target string) (sink *SyslogSink, err error) {
+
+	sink = &SyslogSink{
+		network: "udp",
+		address: target,
+		procID:  strconv.Itoa(os.Getpid()),
+	}
+	if strings.Contains(target, "://") {
+		var parsed *url.URL
+
+		if parsed, err = url.Parse(target); err != nil {
+			return nil, err
+		}
+		switch parsed.Scheme {
+		case "udp", "tcp":
+			sink.network, sink.address = parsed.Scheme, parsed.Host
+		case "unix":
+			sink.network, sink.address = "unix", parsed.Path
+		default:
+			return nil, fmt.Errorf("unsupported syslog transport: %s", parsed.Scheme)
+		}
+	}
+	if sink.network != "unix" {
+		if _, _, err = net.SplitHostPort(sink.address); err != nil {
+			sink.address = net.JoinHostPort(sink.address, "514")
+		}
+	}
+	if sink.hostname, err = os.Hostname(); err != nil || sink.hostname == "" {
+		sink.hostname = "-"
+	}
+	if err = sink.connect(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// connect opens the connection to the server. Unix sockets are usually
+// datagram sockets, so a stream socket is only tried if that fails.
+func (ss *SyslogSink) connect() (err error) {
+
+	if ss.network == "unix" {
+		if ss.conn, err = net.Dial("unixgram", ss.address); err == nil {
+			return
+		}
+	}
+	ss.conn, err = net.Dial(ss.network, ss.address)
+	return
+}
+
+func (ss *SyslogSink) WriteResult(result Result) error {
+
+	severity := SYSLOG_SEVERITY_INFO
+	message := fmt.Sprintf("%s %d/udp %s", result.Host.label(), result.Port, result.State)
+
+	if result.State == PORT_STATE_OPEN {
+		severity = SYSLOG_SEVERITY_NOTICE
+		message += " " + result.Service.Slug
+	}
+	params := map[string]string{
+		"target":   result.Host.Target.Target,
+		"host":     result.Host.Host,
+		"hostname": result.Host.Hostname(),
+		"port":     strconv.Itoa(int(result.Port)),
+		"state":    result.State,
+		"service":  result.Service.Slug,
+		"probe":    result.Probe.Slug,
+		"version":  result.Version,
+		"banner":   result.Banner,
+	}
+	return ss.send(severity, "result", message, params)
+}
+
+// WriteChange sends a port that changed since the previous scan
+func (ss *SyslogSink) WriteChange(change PortChange) error {
+
+	message := fmt.Sprintf("%s %d/udp %s", change.Host, change.Port, change.Change)
+
+	if change.NewService != "" {
+		message += " " + change.NewService
+	}
+	params := map[string]string{
+		"change":      change.Change,
+		"host":        change.Host,
+		"hostname":    change.Hostname,
+		"port":        strconv.Itoa(int(change.Port)),
+		"old_state":   change.OldState,
+		"new_state":   change.NewState,
+		"old_service": change.OldService,
+		"new_service": change.NewService,
+		"old_version": change.OldVersion,
+		"new_version": change.NewVersion,
+	}
+	return ss.send(SYSLOG_SEVERITY_NOTICE, "port-change", message, params)
+}
+
+// Event sends a scan lifecycle event, such as the start or end of a scan
+func (ss *SyslogSink) Event(msgID string, message string, params map[string]string) error {
+	return ss.send(SYSLOG_SEVERITY_INFO, msgID, message, params)
+}
+
+// Close closes the connection to the server
+func (ss *SyslogSink) Close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return ss.conn.Close()
+}
+
+// send formats and writes a message, reconnecting once if the write fails
+func (ss *SyslogSink) send(severity int, msgID string, message string, params map[string]string) (err error) {
+
+	line := ss.format(severity, msgID, message, params)
+
+	// Stream transports need framing, octet counting is the least ambiguous
+	if ss.network == "tcp" {
+		line = strconv.Itoa(len(line)) + " " + line
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if _, err = ss.conn.Write([]byte(line)); err != nil {
+		ss.conn.Close()
+
+		if err = ss.connect(); err == nil {
+			_, err = ss.conn.Write([]byte(line))
+		}
+	}
+	return
+}
+
+// format builds an RFC 5424 message with the parameters as structured data
+func (ss *SyslogSink) format(severity int, msgID string, message string, params map[string]string) string {
+
+	var data strings.Builder
+
+	names := make([]string, 0, len(params))
+
+	for name, value := range params {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		data.WriteString("-")
+	} else {
+		data.WriteString("[" + SYSLOG_SD_ID)
+
+		for _, name := range names {
+			fmt.Fprintf(&data, ` %s="%s"`, name, syslogEscape(params[name]))
+		}
+		data.WriteString("]")
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s udpz %s %s %s %s",
+		SYSLOG_FACILITY_LOCAL0*8+severity,
+		time.Now().Format("2006-01-02T15:04:05.000000Z07:00"),
+		ss.hostname,
+		ss.procID,
+		msgID,
+		data.String(),
+		message)
+}
+
+// syslogEscape escapes the characters that may not appear unescaped in a
+// structured data parameter value
+func syslogEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
@@ -0,0 +1,268 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// newHost creates a scan host for the given address and IPv6 zone, if any. The
+// address is copied so callers may keep iterating over the original slice.
+func newHost(target Target, ip net.IP, zone string) (host Host) {
+
+	host.Target = target
+
+	if ip4 := ip.To4(); ip4 != nil {
+		host.Type = "IPv4"
+		host.ip = append(net.IP(nil), ip4...)
+		host.Host = host.ip.String()
+
+	} else {
+		host.Type = "IPv6"
+		host.ip = append(net.IP(nil), ip.To16()...)
+		host.zone = zone
+		host.Host = "[" + host.address() + "]" // Faster than fmt.Sprintf
+	}
+	return
+}
+
+// Hostname returns the host name the host was resolved from, or an empty string
+// for hosts given as addresses, CIDRs or ranges
+func (h Host) Hostname() string {
+	if h.Target.Type == "hostname" {
+		return h.Target.Target
+	}
+	return ""
+}
+
+// label returns the address of the host followed by the host name it was
+// resolved from, if any, such as "10.0.0.1 (ns.example)"
+func (h Host) label() string {
+	if hostname := h.Hostname(); hostname != "" {
+		return h.Host + " (" + hostname + ")"
+	}
+	return h.Host
+}
+
+// address returns the host address in the form accepted by the dialer,
+// including the IPv6 zone if one was given
+func (h Host) address() string {
+	if h.zone != "" {
+		return h.ip.String() + "%" + h.zone
+	}
+	return h.ip.String()
+}
+
+// parseIP parses a literal address, which may be an IPv6 address enclosed in
+// brackets and followed by a zone such as "[fe80::1%eth0]"
+func parseIP(targetSource string) (ip net.IP, zone string) {
+
+	if strings.HasPrefix(targetSource, "[") && strings.HasSuffix(targetSource, "]") {
+		targetSource = targetSource[1 : len(targetSource)-1]
+	}
+	if strings.Contains(targetSource, ":") {
+		targetSource, zone, _ = strings.Cut(targetSource, "%")
+	}
+	if ip = net.ParseIP(targetSource); ip == nil || (zone != "" && ip.To4() != nil) {
+		return nil, ""
+	}
+	return
+}
+
+// nextIP increments the address in place. It returns false once the address
+// wraps around past the end of its address space.
+func nextIP(ip net.IP) bool {
+	for j := len(ip) - 1; j >= 0; j-- {
+		ip[j]++
+		if ip[j] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange parses a dash separated address range such as
+// "192.168.1.10-192.168.1.50" or the shorthand "192.168.1.10-50". The returned
+// addresses are both either 4 or 16 bytes long.
+func parseRange(targetSource string) (start net.IP, end net.IP, ok bool) {
+
+	var startSource, endSource string
+
+	if startSource, endSource, ok = strings.Cut(targetSource, "-"); !ok {
+		return
+	}
+	ok = false
+
+	if start = net.ParseIP(startSource); start == nil {
+		return
+	}
+	if start4 := start.To4(); start4 != nil {
+		start = start4
+
+		if octet, err := strconv.ParseUint(endSource, 10, 8); err == nil {
+			end = append(net.IP(nil), start...)
+			end[3] = byte(octet)
+
+		} else if end = net.ParseIP(endSource).To4(); end == nil {
+			return
+		}
+	} else if end = net.ParseIP(endSource); end == nil || end.To4() != nil {
+		return
+	}
+
+	start = append(net.IP(nil), start...)
+	ok = bytes.Compare(start, end) <= 0
+	return
+}
+
+// ReadTargets reads target specifications from a reader. Targets may be
+// separated by any whitespace, and everything following a '#' on a line is
+// treated as a comment.
+func ReadTargets(reader io.Reader) (targets []string, err error) {
+
+	scanner := bufio.NewScanner(reader)
+
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		targets = append(targets, strings.Fields(line)...)
+	}
+	err = scanner.Err()
+	return
+}
+
+// ipRange is an inclusive range of addresses in their 16 byte form
+type ipRange struct {
+	start net.IP
+	end   net.IP
+}
+
+func (r ipRange) contains(ip net.IP) bool {
+	ip = ip.To16()
+	return bytes.Compare(ip, r.start) >= 0 && bytes.Compare(ip, r.end) <= 0
+}
+
+// Exclude prevents the hosts described by the given target specifications
+// from being scanned. Specifications use the same formats as scan targets.
+func (sc *UdpProbeScanner) Exclude(targetSources []string) error {
+
+	ranges, err := parseIPRanges(targetSources, "exclude", sc.resolver)
+
+	if err != nil {
+		return err
+	}
+	sc.excludes = append(sc.excludes, ranges...)
+
+	return nil
+}
+
+// parseIPRanges returns the addresses of target specifications as ranges,
+// resolving hostnames with the resolver. The kind of target names invalid
+// specifications in errors.
+func parseIPRanges(targetSources []string, kind string, resolver *hostResolver) (ranges []ipRange, err error) {
+
+	for _, targetSource := range targetSources {
+
+		if ip, _ := parseIP(targetSource); ip != nil {
+			ranges = append(ranges, ipRange{ip.To16(), ip.To16()})
+
+		} else if _, ipNet, err := net.ParseCIDR(targetSource); err == nil {
+			start := ipNet.IP.To16()
+			end := append(net.IP(nil), start...)
+			mask := ipNet.Mask
+
+			if len(mask) == net.IPv4len {
+				mask = append(net.CIDRMask(96, 128)[:12], mask...)
+			}
+			for i := range end {
+				end[i] |= ^mask[i]
+			}
+			ranges = append(ranges, ipRange{start, end})
+
+		} else if start, end, ok := parseRange(targetSource); ok {
+			ranges = append(ranges, ipRange{start.To16(), end.To16()})
+
+		} else if REGEX_HOSTNAME.MatchString(targetSource) {
+			ips, err := resolver.lookupIP(context.Background(), "ip", targetSource)
+
+			if err != nil {
+				return nil, err
+			}
+			for _, ip := range ips {
+				ranges = append(ranges, ipRange{ip.To16(), ip.To16()})
+			}
+		} else {
+			return nil, errors.New("invalid " + kind + " target: " + targetSource)
+		}
+	}
+	return
+}
+
+// sendHost queues a host for scanning unless it has been excluded or the scan
+// was canceled. It returns true if the host was queued.
+func (sc *UdpProbeScanner) sendHost(ctx context.Context, hosts chan Host, host Host) bool {
+
+	if sc.addressFamily != 0 && host.Type != "IPv"+strconv.Itoa(sc.addressFamily) {
+		sc.Logger.Debug().
+			Str("target", host.Target.Target).
+			Str("host", host.Host).
+			Msg("Skipping host outside of the selected address family")
+		sc.skipHost()
+		return false
+	}
+
+	for _, excluded := range sc.excludes {
+		if excluded.contains(host.ip) {
+			sc.Logger.Debug().
+				Str("target", host.Target.Target).
+				Str("host", host.Host).
+				Msg("Skipping excluded host")
+			sc.skipHost()
+			return false
+		}
+	}
+	if sc.roe != nil && !sc.roe.allows(host.ip) {
+		sc.Logger.Warn().
+			Str("target", host.Target.Target).
+			Str("host", host.Host).
+			Msg("Refusing host outside the networks allowed by the rules of engagement")
+		sc.skipHost()
+		return false
+	}
+	if host.override = sc.overrideFor(host.ip); host.override != nil {
+		sc.Logger.Debug().
+			Str("target", host.Target.Target).
+			Str("host", host.Host).
+			Str("override", host.override.Target).
+			Msg("Scanning host with target override")
+	}
+	select {
+	case hosts <- host:
+		atomic.AddUint64(&sc.counters.hostsSeen, 1)
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// skipHost counts a host that will not be scanned as already finished
+func (sc *UdpProbeScanner) skipHost() {
+	atomic.AddUint64(&sc.counters.hostsSeen, 1)
+	atomic.AddUint64(&sc.counters.hostsSkipped, 1)
+}
+
+// SetAddressFamily restricts the scan to IPv4 (4) or IPv6 (6) addresses.
+// Hostnames are then only resolved to addresses of that family. A family of 0
+// allows both.
+func (sc *UdpProbeScanner) SetAddressFamily(family int) error {
+	if family != 0 && family != 4 && family != 6 {
+		return errors.New("invalid address family: " + strconv.Itoa(family))
+	}
+	sc.addressFamily = family
+	return nil
+}
@@ -0,0 +1,168 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+
+	tests := []struct {
+		source string
+		start  string
+		end    string
+		ok     bool
+	}{
+		{source: "192.168.1.10-192.168.1.50", start: "192.168.1.10", end: "192.168.1.50", ok: true},
+		{source: "192.168.1.10-50", start: "192.168.1.10", end: "192.168.1.50", ok: true},
+		{source: "10.0.0.255-10.0.1.1", start: "10.0.0.255", end: "10.0.1.1", ok: true},
+		{source: "10.0.0.1-10.0.0.1", start: "10.0.0.1", end: "10.0.0.1", ok: true},
+		{source: "fe80::1-fe80::ff", start: "fe80::1", end: "fe80::ff", ok: true},
+		{source: "192.168.1.50-10", ok: false},
+		{source: "192.168.1.10-256", ok: false},
+		{source: "192.168.1.10-fe80::1", ok: false},
+		{source: "fe80::1-192.168.1.10", ok: false},
+		{source: "fe80::2-fe80::1", ok: false},
+		{source: "192.168.1.10", ok: false},
+		{source: "host-name", ok: false},
+	}
+
+	for _, test := range tests {
+		start, end, ok := parseRange(test.source)
+
+		if ok != test.ok {
+			t.Errorf("parseRange(%q) ok = %v, want %v", test.source, ok, test.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !start.Equal(net.ParseIP(test.start)) || !end.Equal(net.ParseIP(test.end)) {
+			t.Errorf("parseRange(%q) = %s-%s, want %s-%s", test.source, start, end, test.start, test.end)
+		}
+		if len(start) != len(end) {
+			t.Errorf("parseRange(%q) returned addresses of different lengths", test.source)
+		}
+	}
+}
+
+func TestParseIP(t *testing.T) {
+
+	tests := []struct {
+		source string
+		ip     string
+		zone   string
+	}{
+		{source: "192.0.2.1", ip: "192.0.2.1"},
+		{source: "2001:db8::1", ip: "2001:db8::1"},
+		{source: "[2001:db8::1]", ip: "2001:db8::1"},
+		{source: "fe80::1%eth0", ip: "fe80::1", zone: "eth0"},
+		{source: "[fe80::1%eth0]", ip: "fe80::1", zone: "eth0"},
+		{source: "192.0.2.1%eth0"},
+		{source: "example.com"},
+	}
+
+	for _, test := range tests {
+		ip, zone := parseIP(test.source)
+
+		if test.ip == "" {
+			if ip != nil {
+				t.Errorf("parseIP(%q) = %s, want nothing", test.source, ip)
+			}
+			continue
+		}
+		if !ip.Equal(net.ParseIP(test.ip)) || zone != test.zone {
+			t.Errorf("parseIP(%q) = %s %q, want %s %q", test.source, ip, zone, test.ip, test.zone)
+		}
+	}
+}
+
+func TestExclude(t *testing.T) {
+
+	sc := &UdpProbeScanner{}
+
+	if err := sc.Exclude([]string{"192.0.2.1", "10.0.0.0/24", "172.16.0.10-20", "2001:db8::/126"}); err != nil {
+		t.Fatalf("Exclude failed: %s", err)
+	}
+
+	tests := []struct {
+		ip       string
+		excluded bool
+	}{
+		{ip: "192.0.2.1", excluded: true},
+		{ip: "192.0.2.2", excluded: false},
+		{ip: "10.0.0.0", excluded: true},
+		{ip: "10.0.0.255", excluded: true},
+		{ip: "10.0.1.0", excluded: false},
+		{ip: "9.255.255.255", excluded: false},
+		{ip: "172.16.0.10", excluded: true},
+		{ip: "172.16.0.20", excluded: true},
+		{ip: "172.16.0.21", excluded: false},
+		{ip: "2001:db8::3", excluded: true},
+		{ip: "2001:db8::4", excluded: false},
+		{ip: "::ffff:192.0.2.1", excluded: true},
+	}
+
+	for _, test := range tests {
+		excluded := false
+
+		for _, r := range sc.excludes {
+			if r.contains(net.ParseIP(test.ip)) {
+				excluded = true
+			}
+		}
+		if excluded != test.excluded {
+			t.Errorf("%s excluded = %v, want %v", test.ip, excluded, test.excluded)
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "10.0.0.5-1", "not a host!"} {
+		if err := (&UdpProbeScanner{}).Exclude([]string{invalid}); err == nil {
+			t.Errorf("Exclude(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+
+	targets, err := ReadTargets(strings.NewReader("192.0.2.1 192.0.2.2\n# comment\n\t10.0.0.0/24 # trailing\n\n"))
+
+	if err != nil {
+		t.Fatalf("ReadTargets failed: %s", err)
+	}
+	want := []string{"192.0.2.1", "192.0.2.2", "10.0.0.0/24"}
+
+	if len(targets) != len(want) {
+		t.Fatalf("ReadTargets = %q, want %q", targets, want)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("ReadTargets = %q, want %q", targets, want)
+			break
+		}
+	}
+}
+
+func TestResolveTargetErrors(t *testing.T) {
+
+	sc, err := NewUdpProbeScanner(DefaultOptions())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.resetCounters(0, 0)
+
+	hosts := make(chan Host, 4)
+
+	for _, target := range []string{"192.0.2.0/31", "not a host!", "10.0.0.0/33"} {
+		sc.ResolveTarget(context.Background(), target, hosts)
+	}
+	if len(hosts) != 2 {
+		t.Errorf("hosts: got %d, want %d", len(hosts), 2)
+	}
+	if errors := sc.Progress().Errors; errors != 2 {
+		t.Errorf("errors: got %d, want %d", errors, 2)
+	}
+}
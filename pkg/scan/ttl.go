@@ -0,0 +1,266 @@
+package scan
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	HOP_TIME_EXCEEDED = "time-exceeded" // A router on the path discarded the probe
+	HOP_RESPONSE      = "response"      // The target answered the probe
+	HOP_UNREACHABLE   = "unreachable"   // The target reported the port closed
+	HOP_FILTERED      = "filtered"      // A router reported the traffic administratively prohibited
+	HOP_NO_REPLY      = "no-reply"      // Nothing came back
+
+	MAX_TTL = 255
+)
+
+// Hop is the outcome of a probe sent with a given TTL during a TTL sweep
+type Hop struct {
+	Host    Host   `yaml:"host" json:"host"`
+	Port    uint16 `yaml:"port" json:"port"`
+	Service string `yaml:"service" json:"service"`
+	TTL     int    `yaml:"ttl" json:"ttl"`
+	Router  string `yaml:"router,omitempty" json:"router,omitempty"`
+	Result  string `yaml:"result" json:"result"`
+}
+
+// hopReply is an ICMP error matched to a probe of a TTL sweep
+type hopReply struct {
+	from   net.IP
+	result string
+}
+
+// SetTTL sets the IP TTL, or IPv6 hop limit, of every probe. A TTL of 0 keeps
+// the operating system default.
+func (sc *UdpProbeScanner) SetTTL(ttl int) error {
+	if ttl < 0 || ttl > MAX_TTL {
+		return errors.New("TTL must be between 1 and " + strconv.Itoa(MAX_TTL) + ", or 0 for the default")
+	}
+	sc.ttl = ttl
+	return nil
+}
+
+// setHopLimit sets the TTL or hop limit of a connection to the given address
+func setHopLimit(conn net.Conn, ip net.IP, ttl int) error {
+	if ip.To4() != nil {
+		return ipv4.NewConn(conn).SetTTL(ttl)
+	}
+	return ipv6.NewConn(conn).SetHopLimit(ttl)
+}
+
+// SweepTTL sends the first probe of every selected service to each target with
+// TTLs from 1 to maxTTL, in the manner of firewalk. Routers that discard the
+// probe report it with ICMP time exceeded messages, so the last router that
+// answered before probes stop coming back shows where UDP traffic to the port
+// is dropped. Each sweep stops once the target itself answers, or a router
+// reports the traffic prohibited. Reading ICMP messages requires raw sockets,
+// and so usually root.
+func (sc *UdpProbeScanner) SweepTTL(ctx context.Context, targetSourceList []string, maxTTL int) (hops []Hop, err error) {
+
+	if maxTTL < 1 || maxTTL > MAX_TTL {
+		return nil, errors.New("maximum TTL must be between 1 and " + strconv.Itoa(MAX_TTL))
+	}
+	if sc.useProxy {
+		return nil, errors.New("TTL sweeps cannot be relayed through a proxy")
+	}
+	sc.resetUnreachable()
+	sc.startICMPListener()
+	defer sc.stopICMPListener()
+
+	if len(sc.icmpConns) == 0 {
+		return nil, errors.New("TTL sweeps need raw ICMP sockets, which usually requires root")
+	}
+	sc.startTime = time.Now()
+	defer func() {
+		sc.endTime = time.Now()
+	}()
+	var portCount uint64
+
+	for _, service := range sc.Services {
+		if len(service.Probes) > 0 {
+			portCount += uint64(len(service.Ports))
+		}
+	}
+	sc.resetCounters(estimateHosts(targetSourceList), portCount)
+
+	hosts := make(chan Host)
+
+	go func() {
+		sc.resolveTargets(ctx, targetSourceList, hosts)
+		close(hosts)
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, sc.PortConcurrency)
+
+	for host := range hosts {
+		sc.watchHost(host.ip)
+
+		for slug, service := range sc.Services {
+			if len(service.Probes) == 0 {
+				continue
+			}
+			payload, err := base64.StdEncoding.DecodeString(service.Probes[0].EncodedData)
+
+			if err != nil {
+				continue
+			}
+			for _, port := range service.Ports {
+
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					continue
+				}
+				wg.Add(1)
+
+				go func(host Host, port uint16, slug string) {
+					defer func() {
+						atomic.AddUint64(&sc.counters.probesDone, 1)
+						wg.Done()
+						<-sem
+					}()
+					portHops := sc.sweepPort(ctx, host, port, slug, payload, maxTTL)
+
+					mu.Lock()
+					hops = append(hops, portHops...)
+					mu.Unlock()
+				}(host, port, slug)
+			}
+		}
+	}
+	wg.Wait()
+
+	sort.SliceStable(hops, func(i, j int) bool {
+		if hops[i].Host.Host != hops[j].Host.Host {
+			return hops[i].Host.Host < hops[j].Host.Host
+		}
+		if hops[i].Port != hops[j].Port {
+			return hops[i].Port < hops[j].Port
+		}
+		return hops[i].TTL < hops[j].TTL
+	})
+	return hops, ctx.Err()
+}
+
+// sweepPort probes a single port with increasing TTLs until the target
+// answers, the port is reported closed or filtered, or maxTTL is reached
+func (sc *UdpProbeScanner) sweepPort(ctx context.Context, host Host, port uint16, service string, payload []byte, maxTTL int) (hops []Hop) {
+
+	for ttl := 1; ttl <= maxTTL && ctx.Err() == nil; ttl++ {
+
+		hop := Hop{
+			Host:    host,
+			Port:    port,
+			Service: service,
+			TTL:     ttl,
+			Result:  HOP_NO_REPLY,
+		}
+		if sc.pace(ctx, host) != nil {
+			return
+		}
+		replies := make(chan hopReply, 1)
+		conn, err := sc.dial(ctx, host, port)
+
+		if err != nil {
+			sc.Logger.Debug().
+				Err(err).
+				Str("host", host.Host).
+				Uint16("port", port).
+				Msg("Could not open socket for TTL sweep")
+			return
+		}
+		sourcePort := uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+		key := hopKey(host.ip, port, sourcePort)
+
+		sc.hopWaiters.Store(key, replies)
+
+		if err = setHopLimit(conn, host.ip, ttl); err == nil {
+			conn.SetReadDeadline(time.Now().Add(sc.ReadTimeout))
+			_, err = conn.Write(payload)
+		}
+		if err == nil {
+			atomic.AddUint64(&sc.counters.packetsSent, 1)
+			sc.recordProbe(conn, host.ip, payload)
+
+			// The response, if any, is read in the background so ICMP
+			// messages can be waited on at the same time
+			read := make(chan error, 1)
+
+			go func() {
+				response := make([]byte, 0x400)
+				readLen, responseTTL, readErr := sc.readWithTTL(conn, host.ip, response)
+
+				if readErr == nil {
+					sc.recordPacket(conn.RemoteAddr(), conn.LocalAddr(), response[:readLen], responseTTL)
+				}
+				read <- readErr
+			}()
+
+			select {
+			case reply := <-replies:
+				hop.Router = reply.from.String()
+				hop.Result = reply.result
+			case readErr := <-read:
+				if readErr == nil {
+					hop.Router = host.Host
+					hop.Result = HOP_RESPONSE
+				} else if sc.isUnreachable(host.ip, port) || !isTimeout(readErr) {
+					hop.Router = host.Host
+					hop.Result = HOP_UNREACHABLE
+				}
+			case <-ctx.Done():
+			}
+		}
+		sc.hopWaiters.Delete(key)
+		conn.Close()
+
+		sc.Logger.Debug().
+			Str("host", host.Host).
+			Uint16("port", port).
+			Int("ttl", ttl).
+			Str("router", hop.Router).
+			Str("result", hop.Result).
+			Msg("TTL sweep hop")
+
+		hops = append(hops, hop)
+
+		if hop.Result == HOP_RESPONSE || hop.Result == HOP_UNREACHABLE || hop.Result == HOP_FILTERED {
+			return
+		}
+	}
+	return
+}
+
+// notifyHop passes an ICMP error to the TTL sweep waiting for it, if any
+func (sc *UdpProbeScanner) notifyHop(ip net.IP, port uint16, sourcePort uint16, reply hopReply) {
+	if waiter, ok := sc.hopWaiters.Load(hopKey(ip, port, sourcePort)); ok {
+		select {
+		case waiter.(chan hopReply) <- reply:
+		default:
+		}
+	}
+}
+
+func hopKey(ip net.IP, port uint16, sourcePort uint16) string {
+	return unreachableKey(ip, port) + "/" + strconv.Itoa(int(sourcePort))
+}
+
+// isTimeout reports whether an error is a read deadline expiring
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,113 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"udpz/pkg/proto"
+)
+
+const (
+	UPNP_DESCRIPTION_LIMIT = 0x40000 // Largest device description read, in bytes
+)
+
+// SetUPnPDescriptions fetches the device description of UPnP devices from the
+// LOCATION of their SSDP responses, over HTTP, to report their model and
+// services. Descriptions are only fetched from the host that responded.
+func (sc *UdpProbeScanner) SetUPnPDescriptions(enabled bool) error {
+
+	sc.upnpDescriptions = false
+
+	if !enabled {
+		return nil
+	}
+	if sc.useProxy {
+		return errors.New("UPnP device descriptions cannot be fetched through a proxy")
+	}
+	sc.upnpDescriptions = true
+
+	return nil
+}
+
+// describeUPnP fetches and parses the device description at the location the
+// SSDP response of the host reported. Each location is only fetched once.
+func (sc *UdpProbeScanner) describeUPnP(ctx context.Context, host Host, info proto.Info) (proto.Info, bool) {
+
+	location := info.Metadata["location"]
+
+	if location == "" {
+		return proto.Info{}, false
+	}
+	if described, ok := sc.upnpDescribed.Load(location); ok {
+		return described.(proto.Info), true
+	}
+	description, err := sc.fetchUPnPDescription(ctx, host, location)
+
+	if err != nil {
+		sc.Logger.Debug().
+			Str("host", host.Host).
+			Str("location", location).
+			Err(err).
+			Msg("Failed to fetch UPnP device description")
+
+		return proto.Info{}, false
+	}
+	described, ok := proto.ParseUPnPDescription(description)
+	sc.upnpDescribed.Store(location, described)
+
+	return described, ok
+}
+
+// fetchUPnPDescription reads the device description document at location,
+// which must be on the host itself
+func (sc *UdpProbeScanner) fetchUPnPDescription(ctx context.Context, host Host, location string) ([]byte, error) {
+
+	target, err := url.Parse(location)
+
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme != "http" {
+		return nil, errors.New("description is not served over HTTP")
+	}
+	hostname, _, _ := strings.Cut(target.Hostname(), "%")
+
+	if ip := net.ParseIP(hostname); ip == nil || !ip.Equal(host.ip) {
+		return nil, errors.New("description is not on the responding host")
+	}
+	ctx, cancel := context.WithTimeout(ctx, sc.ReadTimeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+
+	if source := sc.sourceFor(host.ip); source != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: source}
+	}
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected status " + response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, UPNP_DESCRIPTION_LIMIT))
+}
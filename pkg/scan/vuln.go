@@ -0,0 +1,413 @@
+package scan
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	NVD_TIMEOUT = 30 * time.Second
+)
+
+// Vulnerability is a CVE affecting the product a result was mapped to
+type Vulnerability struct {
+	ID       string  `yaml:"id" json:"id"`
+	CPE      string  `yaml:"cpe" json:"cpe"`
+	CVSS     float64 `yaml:"cvss,omitempty" json:"cvss,omitempty"`
+	Severity string  `yaml:"severity,omitempty" json:"severity,omitempty"`
+}
+
+// VulnerabilitySource finds the vulnerabilities affecting the product named by
+// a CPE 2.3 formatted string
+type VulnerabilitySource interface {
+	Lookup(cpe string) ([]Vulnerability, error)
+}
+
+// nvdResponse is the format of NVD CVE API 2.0 responses, which the JSON files
+// the API is mirrored to share
+type nvdResponse struct {
+	Vulnerabilities []struct {
+		CVE nvdCVE `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+type nvdCVE struct {
+	ID      string `json:"id"`
+	Metrics struct {
+		V31 []nvdMetric `json:"cvssMetricV31"`
+		V30 []nvdMetric `json:"cvssMetricV30"`
+		V2  []nvdMetric `json:"cvssMetricV2"`
+	} `json:"metrics"`
+	Configurations []struct {
+		Nodes []struct {
+			CPEMatch []nvdCPEMatch `json:"cpeMatch"`
+		} `json:"nodes"`
+	} `json:"configurations"`
+}
+
+type nvdMetric struct {
+	Type     string `json:"type"`
+	CVSSData struct {
+		BaseScore    float64 `json:"baseScore"`
+		BaseSeverity string  `json:"baseSeverity"`
+	} `json:"cvssData"`
+	// CVSS v2 metrics give the severity next to the data
+	BaseSeverity string `json:"baseSeverity"`
+}
+
+type nvdCPEMatch struct {
+	Vulnerable            bool   `json:"vulnerable"`
+	Criteria              string `json:"criteria"`
+	VersionStartIncluding string `json:"versionStartIncluding"`
+	VersionStartExcluding string `json:"versionStartExcluding"`
+	VersionEndIncluding   string `json:"versionEndIncluding"`
+	VersionEndExcluding   string `json:"versionEndExcluding"`
+}
+
+// NVDFeed looks vulnerabilities up in a local copy of the NVD
+type NVDFeed struct {
+	cves []nvdCVE
+}
+
+// LoadNVDFeed reads a JSON file in the format of the NVD CVE API 2.0, gzipped
+// if its name ends in .gz
+func LoadNVDFeed(path string) (feed *NVDFeed, err error) {
+
+	var (
+		file   *os.File
+		reader io.Reader
+	)
+	if file, err = os.Open(path); err != nil {
+		return
+	}
+	defer file.Close()
+
+	reader = file
+
+	if strings.HasSuffix(path, ".gz") {
+		var gzipReader *gzip.Reader
+
+		if gzipReader, err = gzip.NewReader(file); err != nil {
+			return
+		}
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	}
+	var response nvdResponse
+
+	if err = json.NewDecoder(reader).Decode(&response); err != nil {
+		return
+	}
+	feed = &NVDFeed{}
+
+	for _, vulnerability := range response.Vulnerabilities {
+		feed.cves = append(feed.cves, vulnerability.CVE)
+	}
+	return feed, nil
+}
+
+// Lookup returns the CVEs of the feed affecting the CPE
+func (feed *NVDFeed) Lookup(cpe string) ([]Vulnerability, error) {
+	return nvdVulnerabilities(feed.cves, cpe), nil
+}
+
+// NVDClient looks vulnerabilities up with the NVD CVE API, once for each CPE
+type NVDClient struct {
+	url    string
+	apiKey string
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string][]Vulnerability
+}
+
+// NewNVDClient queries the NVD CVE API 2.0 at the URL, with an API key for the
+// higher rate limit if one is given
+func NewNVDClient(url string, apiKey string) *NVDClient {
+	return &NVDClient{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: NVD_TIMEOUT},
+		cache:  make(map[string][]Vulnerability),
+	}
+}
+
+// Lookup returns the CVEs the API lists for the CPE
+func (nc *NVDClient) Lookup(cpe string) ([]Vulnerability, error) {
+
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	if vulnerabilities, ok := nc.cache[cpe]; ok {
+		return vulnerabilities, nil
+	}
+	request, err := http.NewRequest(http.MethodGet, nc.url+"?cpeName="+url.QueryEscape(cpe), nil)
+
+	if err != nil {
+		return nil, err
+	}
+	if nc.apiKey != "" {
+		request.Header.Set("apiKey", nc.apiKey)
+	}
+	response, err := nc.client.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	// CPE names missing from the dictionary have no CVEs
+	if response.StatusCode == http.StatusNotFound {
+		nc.cache[cpe] = nil
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("NVD API error: " + response.Status)
+	}
+	var body nvdResponse
+
+	if err = json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	var cves []nvdCVE
+
+	for _, vulnerability := range body.Vulnerabilities {
+		cves = append(cves, vulnerability.CVE)
+	}
+	nc.cache[cpe] = nvdVulnerabilities(cves, cpe)
+
+	return nc.cache[cpe], nil
+}
+
+// nvdVulnerabilities returns the CVEs with a vulnerable configuration matching
+// the CPE, the most severe first. The platforms some configurations also
+// require are not checked, so products are flagged when in doubt.
+func nvdVulnerabilities(cves []nvdCVE, cpe string) (vulnerabilities []Vulnerability) {
+
+	product := cpeFields(cpe)
+
+	if len(product) < 6 {
+		return
+	}
+	for _, cve := range cves {
+		if cve.affects(product) {
+			score, severity := cve.severity()
+			vulnerabilities = append(vulnerabilities, Vulnerability{ID: cve.ID, CPE: cpe, CVSS: score, Severity: severity})
+		}
+	}
+	sort.SliceStable(vulnerabilities, func(i, j int) bool {
+		if vulnerabilities[i].CVSS != vulnerabilities[j].CVSS {
+			return vulnerabilities[i].CVSS > vulnerabilities[j].CVSS
+		}
+		return vulnerabilities[i].ID > vulnerabilities[j].ID
+	})
+	return
+}
+
+// affects reports whether a vulnerable configuration of the CVE matches the
+// fields of a CPE
+func (cve nvdCVE) affects(product []string) bool {
+
+	for _, configuration := range cve.Configurations {
+		for _, node := range configuration.Nodes {
+			for _, match := range node.CPEMatch {
+				if match.Vulnerable && match.matches(product) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// severity returns the base score and severity of the primary metric of the
+// most recent CVSS version the CVE was scored with
+func (cve nvdCVE) severity() (float64, string) {
+
+	for _, metrics := range [][]nvdMetric{cve.Metrics.V31, cve.Metrics.V30, cve.Metrics.V2} {
+
+		if len(metrics) == 0 {
+			continue
+		}
+		metric := metrics[0]
+
+		for _, candidate := range metrics {
+			if candidate.Type == "Primary" {
+				metric = candidate
+				break
+			}
+		}
+		severity := metric.CVSSData.BaseSeverity
+
+		if severity == "" {
+			severity = metric.BaseSeverity
+		}
+		return metric.CVSSData.BaseScore, severity
+	}
+	return 0, ""
+}
+
+// matches reports whether the criteria, and version range if any, include
+// the product. Products without an update only match criteria for any update
+// or none.
+func (match nvdCPEMatch) matches(product []string) bool {
+
+	criteria := cpeFields(match.Criteria)
+
+	if len(criteria) < 6 {
+		return false
+	}
+	// The part, vendor and product
+	for i := 2; i <= 4; i++ {
+		if criteria[i] != "*" && criteria[i] != product[i] {
+			return false
+		}
+	}
+	version, update := product[5], ""
+
+	if len(product) > 6 && product[6] != "*" && product[6] != "-" {
+		update = product[6]
+	}
+	if criteria[5] != "*" {
+		if criteria[5] != version {
+			return false
+		}
+		if len(criteria) > 6 && criteria[6] != "*" && criteria[6] != update && !(criteria[6] == "-" && update == "") {
+			return false
+		}
+		return true
+	}
+	// Ranges compare the version with its update, as in 4.2.8p15
+	version += update
+
+	if match.VersionStartIncluding != "" && compareVersions(version, match.VersionStartIncluding) < 0 {
+		return false
+	}
+	if match.VersionStartExcluding != "" && compareVersions(version, match.VersionStartExcluding) <= 0 {
+		return false
+	}
+	if match.VersionEndIncluding != "" && compareVersions(version, match.VersionEndIncluding) > 0 {
+		return false
+	}
+	if match.VersionEndExcluding != "" && compareVersions(version, match.VersionEndExcluding) >= 0 {
+		return false
+	}
+	return true
+}
+
+// cpeFields splits a CPE 2.3 formatted string into its lowercase fields,
+// unquoting the characters escaped with a backslash
+func cpeFields(cpe string) (fields []string) {
+
+	var (
+		field   strings.Builder
+		escaped bool
+	)
+	for _, char := range strings.ToLower(cpe) {
+
+		switch {
+		case escaped:
+			field.WriteRune(char)
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case char == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(char)
+		}
+	}
+	return append(fields, field.String())
+}
+
+// compareVersions orders versions by their runs of digits, compared as
+// numbers, and of letters, such as 2.80 < 2.86 and 4.2.8 < 4.2.8p15
+func compareVersions(a string, b string) int {
+
+	left, right := versionTokens(strings.ToLower(a)), versionTokens(strings.ToLower(b))
+
+	for i := 0; i < len(left) && i < len(right); i++ {
+
+		leftNumber, leftErr := strconv.Atoi(left[i])
+		rightNumber, rightErr := strconv.Atoi(right[i])
+
+		switch {
+		case leftErr == nil && rightErr == nil:
+			if leftNumber != rightNumber {
+				if leftNumber < rightNumber {
+					return -1
+				}
+				return 1
+			}
+		case left[i] != right[i]:
+			if left[i] < right[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(left) < len(right):
+		return -1
+	case len(left) > len(right):
+		return 1
+	}
+	return 0
+}
+
+// versionTokens splits a version into runs of digits and of letters
+func versionTokens(version string) (tokens []string) {
+
+	start := -1
+
+	for i, char := range version + "." {
+
+		isDigit, isLetter := unicode.IsDigit(char), unicode.IsLetter(char)
+
+		if start >= 0 && !(isDigit && unicode.IsDigit(rune(version[start])) || isLetter && unicode.IsLetter(rune(version[start]))) {
+			tokens = append(tokens, version[start:i])
+			start = -1
+		}
+		if start < 0 && (isDigit || isLetter) {
+			start = i
+		}
+	}
+	return
+}
+
+// annotateVulnerabilities replaces the vulnerabilities of the result with the
+// ones affecting its CPE names
+func (sc *UdpProbeScanner) annotateVulnerabilities(pr *Result) {
+
+	if sc.vulnerabilities == nil {
+		return
+	}
+	pr.Vulnerabilities = nil
+
+	for _, cpe := range pr.CPE {
+
+		vulnerabilities, err := sc.vulnerabilities.Lookup(cpe)
+
+		if err != nil {
+			sc.Logger.Warn().
+				Err(err).
+				Str("cpe", cpe).
+				Msg("Failed to look up vulnerabilities")
+			continue
+		}
+		pr.Vulnerabilities = append(pr.Vulnerabilities, vulnerabilities...)
+	}
+}
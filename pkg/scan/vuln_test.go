@@ -0,0 +1,111 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const NVD_TEST_FEED = `{
+  "vulnerabilities": [
+    {"cve": {
+      "id": "CVE-2017-14491",
+      "metrics": {"cvssMetricV31": [{"type": "Primary", "cvssData": {"baseScore": 9.8, "baseSeverity": "CRITICAL"}}]},
+      "configurations": [{"nodes": [{"cpeMatch": [
+        {"vulnerable": true, "criteria": "cpe:2.3:a:thekelleys:dnsmasq:*:*:*:*:*:*:*:*", "versionEndIncluding": "2.77"}
+      ]}]}]
+    }},
+    {"cve": {
+      "id": "CVE-2020-25681",
+      "metrics": {
+        "cvssMetricV31": [
+          {"type": "Secondary", "cvssData": {"baseScore": 7.5, "baseSeverity": "HIGH"}},
+          {"type": "Primary", "cvssData": {"baseScore": 8.1, "baseSeverity": "HIGH"}}
+        ]
+      },
+      "configurations": [{"nodes": [{"cpeMatch": [
+        {"vulnerable": true, "criteria": "cpe:2.3:a:thekelleys:dnsmasq:*:*:*:*:*:*:*:*", "versionEndExcluding": "2.83"}
+      ]}]}]
+    }},
+    {"cve": {
+      "id": "CVE-2016-7434",
+      "metrics": {"cvssMetricV2": [{"type": "Primary", "cvssData": {"baseScore": 5.0}, "baseSeverity": "MEDIUM"}]},
+      "configurations": [{"nodes": [{"cpeMatch": [
+        {"vulnerable": true, "criteria": "cpe:2.3:a:ntp:ntp:4.2.8:p8:*:*:*:*:*:*"},
+        {"vulnerable": false, "criteria": "cpe:2.3:o:linux:linux_kernel:-:*:*:*:*:*:*:*"}
+      ]}]}]
+    }},
+    {"cve": {
+      "id": "CVE-2019-8936",
+      "configurations": [{"nodes": [{"cpeMatch": [
+        {"vulnerable": true, "criteria": "cpe:2.3:a:ntp:ntp:*:*:*:*:*:*:*:*", "versionStartIncluding": "4.2.8", "versionEndExcluding": "4.2.8p13"}
+      ]}]}]
+    }}
+  ]
+}`
+
+func TestNVDFeed(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "nvd.json")
+
+	if err := os.WriteFile(path, []byte(NVD_TEST_FEED), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	feed, err := LoadNVDFeed(path)
+
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	tests := []struct {
+		cpe  string
+		want []Vulnerability
+	}{
+		{
+			"cpe:2.3:a:thekelleys:dnsmasq:2.76:*:*:*:*:*:*:*",
+			[]Vulnerability{
+				{"CVE-2017-14491", "cpe:2.3:a:thekelleys:dnsmasq:2.76:*:*:*:*:*:*:*", 9.8, "CRITICAL"},
+				{"CVE-2020-25681", "cpe:2.3:a:thekelleys:dnsmasq:2.76:*:*:*:*:*:*:*", 8.1, "HIGH"},
+			},
+		},
+		{
+			"cpe:2.3:a:thekelleys:dnsmasq:2.80:*:*:*:*:*:*:*",
+			[]Vulnerability{{"CVE-2020-25681", "cpe:2.3:a:thekelleys:dnsmasq:2.80:*:*:*:*:*:*:*", 8.1, "HIGH"}},
+		},
+		{"cpe:2.3:a:thekelleys:dnsmasq:2.86:*:*:*:*:*:*:*", nil},
+		{
+			"cpe:2.3:a:ntp:ntp:4.2.8:p8:*:*:*:*:*:*",
+			[]Vulnerability{
+				{"CVE-2016-7434", "cpe:2.3:a:ntp:ntp:4.2.8:p8:*:*:*:*:*:*", 5.0, "MEDIUM"},
+				{"CVE-2019-8936", "cpe:2.3:a:ntp:ntp:4.2.8:p8:*:*:*:*:*:*", 0, ""},
+			},
+		},
+		{"cpe:2.3:a:ntp:ntp:4.2.8:p15:*:*:*:*:*:*", nil},
+		{"cpe:2.3:o:linux:linux_kernel:5.4.0:*:*:*:*:*:*:*", nil},
+	}
+	for _, test := range tests {
+		if got, _ := feed.Lookup(test.cpe); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.cpe, got, test.want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2.80", "2.83", -1},
+		{"2.9", "2.10", -1},
+		{"4.2.8", "4.2.8p13", -1},
+		{"4.2.8p15", "4.2.8p13", 1},
+		{"1.6.9", "1.6.9", 0},
+		{"9.11.4-P2", "9.11.4p2", 0},
+	}
+	for _, test := range tests {
+		if got := compareVersions(test.a, test.b); got != test.want {
+			t.Errorf("%s, %s: got %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"udpz/pkg/scan"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	AGENT_DIAL_TIMEOUT     = 10 * time.Second // How long connecting to an agent may take
+	AGENT_RESPONSE_TIMEOUT = 30 * time.Second // How long an agent may take to start replying
+	AGENT_CALL_TIMEOUT     = 2 * time.Minute  // How long any call but a result stream may take
+
+	// STREAM_IDLE_TIMEOUT is how long a result stream may stay silent before
+	// the agent is considered dead. Agents send keepalives more often.
+	STREAM_IDLE_TIMEOUT = 4 * STREAM_KEEPALIVE_INTERVAL
+)
+
+// Shard is a slice of the target and port space assigned to one agent job
+type Shard struct {
+	Targets  []string
+	Ports    string // Empty to keep the ports of the job template
+	attempts int
+}
+
+// Controller spreads a scan over several agents running "udpz serve". Each
+// shard is submitted as a job to a free agent, and its results are kept once
+// the job completes. Shards whose job fails are retried on another agent, and
+// agents that keep failing are no longer used. Agents that stop answering, or
+// whose result stream goes silent, count as failed so their shards move on.
+//
+// Agents are driven through the same REST API that orchestration platforms
+// use rather than gRPC, so a single agent API serves both, stays reachable
+// with plain HTTP tooling and proxies, and needs no generated protocol code.
+type Controller struct {
+	Agents       []string   // Base URLs of the agents
+	Token        string     // Bearer token of the agents
+	Template     JobRequest // Options for every job, targets and ports are set per shard
+	JobsPerAgent int        // Shards each agent scans at once
+	MaxAttempts  int        // Times a shard is tried before giving up on it
+	MaxFailures  int        // Consecutive failures before an agent is no longer used
+	Logger       zerolog.Logger
+
+	client *http.Client
+}
+
+// agentState tracks the health of an agent
+type agentState struct {
+	url      string
+	mu       sync.Mutex
+	failures int
+	down     bool
+}
+
+// failed records a failure, returning true once the agent should be dropped
+func (a *agentState) failed(maxFailures int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.failures++
+	if a.failures >= maxFailures {
+		a.down = true
+	}
+	return a.down
+}
+
+func (a *agentState) succeeded() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.failures = 0
+}
+
+func (a *agentState) isDown() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.down
+}
+
+// Run scans every shard and returns the combined results. Shards that could
+// not be completed on any agent are reported in the error.
+func (c *Controller) Run(ctx context.Context, shards []Shard) (results []scan.Result, err error) {
+
+	if len(c.Agents) == 0 {
+		return nil, errors.New("no agents given")
+	}
+	if c.JobsPerAgent < 1 {
+		c.JobsPerAgent = 1
+	}
+	if c.MaxAttempts < 1 {
+		c.MaxAttempts = 1
+	}
+	if c.MaxFailures < 1 {
+		c.MaxFailures = 1
+	}
+	c.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: AGENT_DIAL_TIMEOUT, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   AGENT_DIAL_TIMEOUT,
+			ResponseHeaderTimeout: AGENT_RESPONSE_TIMEOUT,
+		},
+	}
+
+	var mu sync.Mutex
+	var failedShards []Shard
+	var workers sync.WaitGroup
+
+	queue := make(chan Shard, len(shards))
+	pending := len(shards)
+	finished := make(chan struct{})
+
+	for _, shard := range shards {
+		queue <- shard
+	}
+	if pending == 0 {
+		close(finished)
+	}
+
+	// complete marks a shard as done, either with its results or as failed
+	complete := func(shard Shard, shardResults []scan.Result, shardErr error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if shardErr != nil {
+			failedShards = append(failedShards, shard)
+		} else {
+			results = append(results, shardResults...)
+		}
+		if pending--; pending == 0 {
+			close(finished)
+		}
+	}
+
+	agents := make([]*agentState, len(c.Agents))
+
+	for i, url := range c.Agents {
+		agents[i] = &agentState{url: strings.TrimRight(url, "/")}
+
+		for j := 0; j < c.JobsPerAgent; j++ {
+			workers.Add(1)
+
+			go func(agent *agentState) {
+				defer workers.Done()
+
+				for !agent.isDown() {
+					var shard Shard
+
+					select {
+					case shard = <-queue:
+					case <-finished:
+						return
+					case <-ctx.Done():
+						return
+					}
+					shardResults, shardErr := c.runShard(ctx, agent.url, shard)
+
+					if ctx.Err() != nil {
+						complete(shard, nil, ctx.Err())
+						return
+					}
+					if shardErr == nil {
+						agent.succeeded()
+						complete(shard, shardResults, nil)
+
+						c.Logger.Info().
+							Str("agent", agent.url).
+							Strs("targets", shard.Targets).
+							Str("ports", shard.Ports).
+							Int("result_count", len(shardResults)).
+							Msg("Shard complete")
+						continue
+					}
+
+					// Failures of agents already known to be down are not held
+					// against the shard
+					if !agent.isDown() {
+						shard.attempts++
+					}
+					down := agent.failed(c.MaxFailures)
+
+					c.Logger.Warn().
+						Err(shardErr).
+						Str("agent", agent.url).
+						Strs("targets", shard.Targets).
+						Int("attempt", shard.attempts).
+						Bool("agent_down", down).
+						Msg("Shard failed")
+
+					if shard.attempts >= c.MaxAttempts {
+						complete(shard, nil, shardErr)
+					} else {
+						queue <- shard
+					}
+				}
+			}(agents[i])
+		}
+	}
+
+	// Stop waiting once every shard is done, or every agent is down
+	allDown := make(chan struct{})
+
+	go func() {
+		workers.Wait()
+		close(allDown)
+	}()
+
+	select {
+	case <-finished:
+	case <-allDown:
+	case <-ctx.Done():
+	}
+	workers.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if ctx.Err() != nil {
+		return results, ctx.Err()
+	}
+	remaining := len(failedShards) + len(queue)
+
+	if remaining > 0 {
+		return results, fmt.Errorf("%d of %d shards could not be scanned", remaining, len(shards))
+	}
+	return results, nil
+}
+
+// runShard submits a shard as a job to an agent and collects its results
+// while it runs. The job is canceled if the context is.
+func (c *Controller) runShard(ctx context.Context, agentURL string, shard Shard) (results []scan.Result, err error) {
+
+	request := c.Template
+	request.Targets = shard.Targets
+
+	if shard.Ports != "" {
+		request.Ports = shard.Ports
+		request.TopPorts = 0
+	}
+	var status JobStatus
+
+	if err = c.call(ctx, http.MethodPost, agentURL+"/jobs", request, &status); err != nil {
+		return
+	}
+	jobURL := agentURL + "/jobs/" + status.ID
+
+	// A job that did not complete is canceled, in case the agent is still
+	// scanning while the shard moves to another agent
+	defer func() {
+		if err != nil {
+			cancelCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			c.call(cancelCtx, http.MethodDelete, jobURL, nil, nil)
+		}
+	}()
+
+	if results, err = c.stream(ctx, jobURL+"/stream"); err != nil {
+		return nil, err
+	}
+	if err = c.call(ctx, http.MethodGet, jobURL, nil, &status); err != nil {
+		return nil, err
+	}
+	if status.Status != JOB_COMPLETED {
+		return nil, fmt.Errorf("job %s %s: %s", status.ID, status.Status, status.Error)
+	}
+
+	// The final results include version information merged from every probe
+	if err = c.call(ctx, http.MethodGet, jobURL+"/results", nil, &results); err != nil {
+		return nil, err
+	}
+	c.call(ctx, http.MethodDelete, jobURL, nil, nil)
+
+	return results, nil
+}
+
+// stream reads the results of a job as they are confirmed, until it finishes.
+// The stream is abandoned if the agent sends nothing, not even a keepalive,
+// for STREAM_IDLE_TIMEOUT.
+func (c *Controller) stream(ctx context.Context, url string) (results []scan.Result, err error) {
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	idle := time.AfterFunc(STREAM_IDLE_TIMEOUT, cancel)
+	defer idle.Stop()
+
+	defer func() {
+		if err != nil && ctx.Err() == nil && streamCtx.Err() != nil {
+			err = errors.New("agent stopped responding")
+		}
+	}()
+
+	request, err := c.newRequest(streamCtx, http.MethodGet, url, nil)
+
+	if err != nil {
+		return
+	}
+	response, err := c.client.Do(request)
+
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, responseError(response)
+	}
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0x10000), 0x1000000)
+
+	for scanner.Scan() {
+		var result scan.Result
+
+		idle.Reset(STREAM_IDLE_TIMEOUT)
+
+		// Blank lines are keepalives
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		if err = json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+
+		c.Logger.Debug().
+			Str("host", result.Host.Host).
+			Uint16("port", result.Port).
+			Str("state", result.State).
+			Msg("Received result from agent")
+	}
+	return results, scanner.Err()
+}
+
+// call sends a request to an agent and decodes the JSON reply into reply
+func (c *Controller) call(ctx context.Context, method string, url string, body interface{}, reply interface{}) error {
+
+	ctx, cancel := context.WithTimeout(ctx, AGENT_CALL_TIMEOUT)
+	defer cancel()
+
+	request, err := c.newRequest(ctx, method, url, body)
+
+	if err != nil {
+		return err
+	}
+	response, err := c.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return responseError(response)
+	}
+	if reply != nil {
+		return json.NewDecoder(response.Body).Decode(reply)
+	}
+	return nil
+}
+
+func (c *Controller) newRequest(ctx context.Context, method string, url string, body interface{}) (*http.Request, error) {
+
+	var content bytes.Buffer
+
+	if body != nil {
+		if err := json.NewEncoder(&content).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+	request, err := http.NewRequestWithContext(ctx, method, url, &content)
+
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return request, nil
+}
+
+// responseError turns an error reply from an agent into an error
+func responseError(response *http.Response) error {
+
+	var reply struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(response.Body).Decode(&reply) == nil && reply.Error != "" {
+		return fmt.Errorf("%s: %s", response.Status, reply.Error)
+	}
+	return errors.New(response.Status)
+}
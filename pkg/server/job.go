@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"udpz/pkg/data"
+	"udpz/pkg/scan"
+)
+
+const (
+	JOB_QUEUED    = "queued"
+	JOB_RUNNING   = "running"
+	JOB_COMPLETED = "completed"
+	JOB_CANCELED  = "canceled"
+	JOB_FAILED    = "failed"
+)
+
+// JobRequest describes a scan submitted to the server. Unset fields keep the
+// defaults of the server. The concurrency, retries, timeout and rate of the
+// server are limits, so larger values are lowered to them.
+type JobRequest struct {
+	Targets   []string `json:"targets"`
+	Exclude   []string `json:"exclude,omitempty"`
+	Ports     string   `json:"ports,omitempty"`
+	Services  []string `json:"services,omitempty"`
+	TopPorts  int      `json:"top_ports,omitempty"`
+	HostTasks uint     `json:"host_tasks,omitempty"`
+	PortTasks uint     `json:"port_tasks,omitempty"`
+	Retries   *uint    `json:"retries,omitempty"`
+	TimeoutMs uint     `json:"timeout_ms,omitempty"`
+	Rate      float64  `json:"rate,omitempty"`
+
+	AdaptiveTimeout bool `json:"adaptive_timeout,omitempty"`
+}
+
+// JobStatus is the state of a job as reported by the API
+type JobStatus struct {
+	ID          string        `json:"id"`
+	Status      string        `json:"status"`
+	Request     JobRequest    `json:"request"`
+	Created     time.Time     `json:"created"`
+	Started     *time.Time    `json:"started,omitempty"`
+	Finished    *time.Time    `json:"finished,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	ResultCount int           `json:"result_count"`
+	Progress    scan.Progress `json:"progress"`
+}
+
+// Job is a scan run by the server. Results are collected as they are
+// confirmed so they can be streamed to clients while the scan runs.
+type Job struct {
+	mu       sync.Mutex
+	id       string
+	status   string
+	request  JobRequest
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	err      error
+
+	scanner *scan.UdpProbeScanner
+	cancel  context.CancelFunc
+	results []scan.Result
+	updated chan struct{} // Closed and replaced whenever the job changes
+}
+
+// newJob validates the request and prepares a scanner for it
+func newJob(id string, request JobRequest, base scan.Options) (job *Job, err error) {
+
+	if len(request.Targets) == 0 {
+		return nil, errors.New("no targets given")
+	}
+	options := base
+	options.Exclude = append(append([]string(nil), base.Exclude...), request.Exclude...)
+
+	if request.HostTasks > 0 && request.HostTasks < base.HostConcurrency {
+		options.HostConcurrency = request.HostTasks
+	}
+	if request.PortTasks > 0 && request.PortTasks < base.PortConcurrency {
+		options.PortConcurrency = request.PortTasks
+	}
+	if request.Retries != nil && *request.Retries < base.Retransmissions {
+		options.Retransmissions = *request.Retries
+	}
+	if timeout := time.Duration(request.TimeoutMs) * time.Millisecond; timeout > 0 && timeout < base.ReadTimeout {
+		options.ReadTimeout = timeout
+	}
+	if request.AdaptiveTimeout {
+		options.AdaptiveTimeout = true
+	}
+	if request.Rate > 0 {
+		options.Rate = limitRate(request.Rate, base)
+		options.MinRate, options.MaxRate = 0, 0
+	}
+	if options.Services, err = selectServices(base.Services, request); err != nil {
+		return nil, err
+	}
+
+	job = &Job{
+		id:      id,
+		status:  JOB_QUEUED,
+		request: request,
+		created: time.Now(),
+		updated: make(chan struct{}),
+	}
+	options.Sinks = append(append([]scan.ResultSink(nil), base.Sinks...), job)
+
+	if job.scanner, err = scan.NewUdpProbeScanner(options); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// limitRate lowers a requested rate to the highest rate the server allows, if
+// it limits the rate
+func limitRate(rate float64, base scan.Options) float64 {
+
+	limit := base.Rate
+
+	if base.MaxRate > 0 {
+		limit = base.MaxRate
+	}
+	if limit > 0 && rate > limit {
+		return limit
+	}
+	return rate
+}
+
+// selectServices narrows the probe database to the services and ports of the
+// request, the same way the command line options do
+func selectServices(services map[string]data.UdpService, request JobRequest) (selected map[string]data.UdpService, err error) {
+
+	var ports map[uint16]bool
+
+	if services == nil {
+		services = data.UDP_SERVICES
+	}
+	selected = services
+
+	if request.Ports != "" {
+		if ports, err = data.ParsePorts(request.Ports); err != nil {
+			return nil, err
+		}
+	}
+	if len(request.Services) > 0 {
+		if selected, err = data.FilterServices(selected, request.Services); err != nil {
+			return nil, err
+		}
+	}
+	if request.TopPorts > 0 {
+		selected = data.FilterPorts(selected, data.TopPorts(selected, request.TopPorts))
+	} else if ports != nil && len(request.Services) > 0 {
+		selected = data.AssignPorts(selected, ports)
+	} else if ports != nil {
+		selected = data.FilterPorts(selected, ports)
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no probes match the selected ports")
+	}
+	return
+}
+
+// WriteResult records a result as soon as the scanner confirms it
+func (job *Job) WriteResult(result scan.Result) error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.results = append(job.results, result)
+	job.notify()
+
+	return nil
+}
+
+// notify wakes up clients waiting for the job to change. The lock must be held.
+func (job *Job) notify() {
+	close(job.updated)
+	job.updated = make(chan struct{})
+}
+
+// run waits for a free slot, then scans the targets of the job. Canceling the
+// job cancels the context.
+func (job *Job) run(ctx context.Context, slots chan struct{}) {
+
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		job.finish(nil, ctx.Err())
+		return
+	}
+
+	job.mu.Lock()
+	job.status = JOB_RUNNING
+	job.started = time.Now()
+	job.notify()
+	job.mu.Unlock()
+
+	results, err := job.scanner.Scan(ctx, job.request.Targets)
+	job.finish(results, err)
+}
+
+// finish records the final results of the job, which include version
+// information merged from every probe
+func (job *Job) finish(results []scan.Result, err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.finished = time.Now()
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.status = JOB_CANCELED
+	case err != nil:
+		job.status = JOB_FAILED
+		job.err = err
+	default:
+		job.status = JOB_COMPLETED
+	}
+	if results != nil {
+		job.results = results
+	}
+	job.notify()
+}
+
+// Cancel stops the job if it is still queued or running
+func (job *Job) Cancel() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.cancel()
+}
+
+// Done reports whether the job has finished
+func (job *Job) Done() bool {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	return !job.finished.IsZero()
+}
+
+// finishedAt returns when the job finished, or the zero time if it has not
+func (job *Job) finishedAt() time.Time {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	return job.finished
+}
+
+// Status returns a snapshot of the state of the job
+func (job *Job) Status() JobStatus {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	status := JobStatus{
+		ID:          job.id,
+		Status:      job.status,
+		Request:     job.request,
+		Created:     job.created,
+		ResultCount: len(job.results),
+	}
+	if !job.started.IsZero() {
+		started := job.started
+		status.Started = &started
+		status.Progress = job.scanner.Progress()
+	}
+	if !job.finished.IsZero() {
+		finished := job.finished
+		status.Finished = &finished
+	}
+	if job.err != nil {
+		status.Error = job.err.Error()
+	}
+	return status
+}
+
+// Results returns the results from the given index onwards, a channel that is
+// closed when more are available, and whether the job has finished
+func (job *Job) Results(from int) (results []scan.Result, updated <-chan struct{}, done bool) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	if from < len(job.results) {
+		results = append(results, job.results[from:]...)
+	}
+	return results, job.updated, !job.finished.IsZero()
+}
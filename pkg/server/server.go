@@ -0,0 +1,325 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"udpz/pkg/scan"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// STREAM_KEEPALIVE_INTERVAL is how often an idle result stream gets a
+	// blank line, so clients can tell a quiet scan from a dead agent
+	STREAM_KEEPALIVE_INTERVAL = 15 * time.Second
+)
+
+// Server exposes a REST API to submit scans, query their status and stream
+// their results:
+//
+//	POST   /jobs              submit a JobRequest
+//	GET    /jobs              list jobs
+//	GET    /jobs/{id}         job status and progress
+//	GET    /jobs/{id}/results results as a JSON array
+//	GET    /jobs/{id}/stream  results as JSON lines, until the job finishes
+//	DELETE /jobs/{id}         cancel a running job, or remove a finished one
+type Server struct {
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string
+
+	options     scan.Options
+	token       string
+	slots       chan struct{}
+	retention   time.Duration // How long finished jobs are kept, or 0 until deleted
+	maxFinished int           // Most finished jobs kept, or 0 for no limit
+	ctx         context.Context
+	log         zerolog.Logger
+}
+
+// NewServer creates a server that runs at most maxJobs scans at once, using
+// options as the defaults for every job. Requests must carry the token as a
+// bearer token, unless it is empty. Finished jobs are removed once they are
+// older than retention, or when more than maxFinished of them are kept, the
+// oldest first. Either limit is disabled by 0.
+func NewServer(ctx context.Context, log zerolog.Logger, options scan.Options, token string, maxJobs int, retention time.Duration, maxFinished int) *Server {
+
+	if maxJobs < 1 {
+		maxJobs = 1
+	}
+	return &Server{
+		jobs:        make(map[string]*Job),
+		options:     options,
+		token:       token,
+		slots:       make(chan struct{}, maxJobs),
+		retention:   retention,
+		maxFinished: maxFinished,
+		ctx:         ctx,
+		log:         log,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if s.token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+	}
+	s.evictJobs()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if parts[0] != "jobs" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			s.listJobs(w)
+		case http.MethodPost:
+			s.createJob(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	s.mu.Lock()
+	job, ok := s.jobs[parts[1]]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	action := ""
+	if len(parts) == 3 {
+		action = parts[2]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, job.Status())
+	case action == "" && r.Method == http.MethodDelete:
+		s.deleteJob(w, job)
+	case action == "results" && r.Method == http.MethodGet:
+		results, _, _ := job.Results(0)
+		if results == nil {
+			results = []scan.Result{}
+		}
+		writeJSON(w, http.StatusOK, results)
+	case action == "stream" && r.Method == http.MethodGet:
+		s.streamJob(w, r, job)
+	case action == "" || action == "results" || action == "stream":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) listJobs(w http.ResponseWriter) {
+	s.mu.Lock()
+	jobs := make([]*Job, len(s.order))
+
+	for i, id := range s.order {
+		jobs[i] = s.jobs[id]
+	}
+	s.mu.Unlock()
+
+	statuses := make([]JobStatus, len(jobs))
+
+	for i, job := range jobs {
+		statuses[i] = job.Status()
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) createJob(w http.ResponseWriter, r *http.Request) {
+
+	var request JobRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	id, err := newJobID()
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	job, err := newJob(id, request, s.options)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	job.cancel = cancel
+
+	s.mu.Lock()
+	s.jobs[id] = job
+	s.order = append(s.order, id)
+	s.mu.Unlock()
+
+	s.log.Info().
+		Str("job", id).
+		Strs("targets", request.Targets).
+		Msg("Job submitted")
+
+	go func() {
+		defer cancel()
+		job.run(ctx, s.slots)
+
+		status := job.Status()
+		s.log.Info().
+			Str("job", id).
+			Str("status", status.Status).
+			Int("result_count", status.ResultCount).
+			Msg("Job finished")
+
+		s.evictJobs()
+	}()
+	writeJSON(w, http.StatusCreated, job.Status())
+}
+
+func (s *Server) deleteJob(w http.ResponseWriter, job *Job) {
+
+	if !job.Done() {
+		job.Cancel()
+		writeJSON(w, http.StatusAccepted, job.Status())
+		return
+	}
+	s.mu.Lock()
+	s.removeJob(job.id)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeJob forgets a job. The lock must be held.
+func (s *Server) removeJob(jobID string) {
+
+	delete(s.jobs, jobID)
+
+	for i, id := range s.order {
+		if id == jobID {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// evictJobs removes the finished jobs older than the retention period, then
+// the oldest finished jobs over the limit, so that a long running server does
+// not keep the results of every job it ran
+func (s *Server) evictJobs() {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	finished := []string{}
+
+	for _, id := range append([]string(nil), s.order...) {
+
+		finishedAt := s.jobs[id].finishedAt()
+
+		if finishedAt.IsZero() {
+			continue
+		}
+		if s.retention > 0 && time.Since(finishedAt) > s.retention {
+			s.removeJob(id)
+			continue
+		}
+		finished = append(finished, id)
+	}
+	if s.maxFinished > 0 {
+		for len(finished) > s.maxFinished {
+			s.removeJob(finished[0])
+			finished = finished[1:]
+		}
+	}
+}
+
+// streamJob writes results as JSON lines as soon as they are confirmed, until
+// the job finishes or the client goes away
+func (s *Server) streamJob(w http.ResponseWriter, r *http.Request, job *Job) {
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	sent := 0
+
+	keepalive := time.NewTicker(STREAM_KEEPALIVE_INTERVAL)
+	defer keepalive.Stop()
+
+	for {
+		results, updated, done := job.Results(sent)
+
+		for _, result := range results {
+			if err := encoder.Encode(result); err != nil {
+				return
+			}
+		}
+		sent += len(results)
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+		select {
+		case <-updated:
+		case <-keepalive.C:
+			if _, err := w.Write([]byte("\n")); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// newJobID returns a random identifier for a job
+func newJobID() (string, error) {
+
+	id := make([]byte, 8)
+
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}